@@ -29,11 +29,17 @@ mkdir -p "$OUT_DIR"/bin
 DROPGZ_BUILD_DIR=$(mktemp -d -p "$GEN_DIR")
 PAYLOAD_DIR=$(mktemp -d -p "$GEN_DIR")
 DROPGZ_VERSION="${DROPGZ_VERSION:-v0.0.12}"
-DROPGZ_MOD_DOWNLOAD_PATH=""$ACN_PACKAGE_PATH"/dropgz@"$DROPGZ_VERSION""
-DROPGZ_MOD_DOWNLOAD_PATH=$(echo "$DROPGZ_MOD_DOWNLOAD_PATH" | tr '[:upper:]' '[:lower:]')
 
 mkdir -p "$DROPGZ_BUILD_DIR"
 
+# dropgz refuses unsigned payloads. an unset pipeline secret is passed through as the literal
+# "$(DROPGZ_SIGNING_KEY)", so anything that is not a key file falls back to a key generated for this build.
+if [[ ! -f "${DROPGZ_SIGNING_KEY:-}" ]]; then
+  echo >&2 "##[warning]DROPGZ_SIGNING_KEY is not set, signing the dropgz payload with an ephemeral key"
+  DROPGZ_SIGNING_KEY="$DROPGZ_BUILD_DIR"/signing-key.pem
+  openssl genpkey -algorithm EC -pkeyopt ec_paramgen_curve:P-256 -out "$DROPGZ_SIGNING_KEY"
+fi
+
 echo >&2 "##[section]Construct DropGZ Embedded Payload"
 pushd "$PAYLOAD_DIR"
   [[ -d "$OUT_DIR"/files ]] && cp "$OUT_DIR"/files/* . || true
@@ -43,6 +49,9 @@ pushd "$PAYLOAD_DIR"
   [[ $OS =~ windows ]] && files::remove_exe_extensions .
 
   sha256sum * > sum.txt
+  # sign the checksums, dropgz refuses unsigned or tampered payloads
+  openssl dgst -sha256 -sign "$DROPGZ_SIGNING_KEY" -out sum.txt.sig sum.txt
+  DROPGZ_PUBLIC_KEY=$(openssl pkey -in "$DROPGZ_SIGNING_KEY" -pubout -outform DER | base64 -w0)
   gzip --verbose --best --recursive .
 
   for file in $(find . -name '*.gz'); do
@@ -50,16 +59,14 @@ pushd "$PAYLOAD_DIR"
   done
 popd
 
-echo >&2 "##[section]Download DropGZ ($DROPGZ_VERSION)"
-GOPATH="$DROPGZ_BUILD_DIR" \
-  go mod download "$DROPGZ_MOD_DOWNLOAD_PATH"
-
 echo >&2 "##[section]Build DropGZ with Embedded Payload"
-pushd "$DROPGZ_BUILD_DIR"/pkg/mod/"$DROPGZ_MOD_DOWNLOAD_PATH"
+# build the in-tree dropgz, the released modules predate payload signature verification
+cp -r "$REPO_ROOT"/dropgz "$DROPGZ_BUILD_DIR"/dropgz
+pushd "$DROPGZ_BUILD_DIR"/dropgz
   mv "$PAYLOAD_DIR"/* pkg/embed/fs/
   GOOS="$OS" go build -v -trimpath -a \
     -o "$OUT_DIR"/bin/dropgz"$FILE_EXT" \
-    -ldflags "-s -w -X github.com/Azure/azure-container-networking/dropgz/internal/buildinfo.Version="$DROPGZ_VERSION"" \
+    -ldflags "-s -w -X github.com/Azure/azure-container-networking/dropgz/internal/buildinfo.Version="$DROPGZ_VERSION" -X github.com/Azure/azure-container-networking/dropgz/internal/buildinfo.PublicKey="$DROPGZ_PUBLIC_KEY"" \
     -gcflags="-dwarflocationlists=true" \
     main.go
 popd
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+const requestTimeout = 5 * time.Second
+
+// eventReporter sends node events, once for each distinct message until reset
+type eventReporter struct {
+	client      KubeClient
+	nodeName    string
+	lastMessage string
+}
+
+// report sends a warning event unless the same message was the last one reported. A nil reporter does nothing.
+func (e *eventReporter) report(reason, message string) {
+	if e == nil || message == e.lastMessage {
+		return
+	}
+	if err := createNodeEvent(e.client, e.nodeName, reason, message, corev1.EventTypeWarning); err != nil {
+		klog.Errorf("failed to create event: %v", err)
+		return
+	}
+	e.lastMessage = message
+}
+
+// reset allows the next message to be reported, even if it was the last one
+func (e *eventReporter) reset() {
+	if e == nil {
+		return
+	}
+	e.lastMessage = ""
+}
+
+// createNodeEvent creates a Kubernetes event for the specified node
+func createNodeEvent(clientset KubeClient, nodeName, reason, message, eventType string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	node, err := clientset.GetNode(ctx, nodeName)
+	if err != nil {
+		return fmt.Errorf("failed to get node UID for %s: %w", nodeName, err)
+	}
+
+	now := metav1.NewTime(time.Now())
+
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.%d", nodeName, now.UnixNano()),
+			Namespace: "default",
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind:       "Node",
+			Name:       nodeName,
+			UID:        node.UID, // required for event to show up in node describe
+			APIVersion: "v1",
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+		Source: corev1.EventSource{
+			Component: "azure-ip-masq-merger",
+		},
+	}
+	_, err = clientset.CreateEvent(ctx, "default", event)
+	if err != nil {
+		return fmt.Errorf("failed to create event for node %s: %w", nodeName, err)
+	}
+
+	klog.V(2).Infof("Created event for node %s: %s - %s", nodeName, reason, message)
+	return nil
+}
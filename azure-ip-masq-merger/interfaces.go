@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// IPTablesClient interface for iptables operations
+type IPTablesClient interface {
+	ChainExists(table, chain string) (bool, error)
+	List(table, chain string) ([]string, error)
+	Exists(table, chain string, rulespec ...string) (bool, error)
+	Append(table, chain string, rulespec ...string) error
+}
+
+// Restorer interface for applying a set of rules in a single transaction
+type Restorer interface {
+	Restore(data []byte) error
+}
+
+// KubeClient interface with direct methods for testing
+type KubeClient interface {
+	GetNode(ctx context.Context, name string) (*corev1.Node, error)
+	CreateEvent(ctx context.Context, namespace string, event *corev1.Event) (*corev1.Event, error)
+}
+
+// execRestorer applies rules with iptables-restore or ip6tables-restore, without flushing the other chains
+type execRestorer struct {
+	command string
+}
+
+func NewRestorer(ipv6 bool) Restorer {
+	if ipv6 {
+		return &execRestorer{command: "ip6tables-restore"}
+	}
+	return &execRestorer{command: "iptables-restore"}
+}
+
+func (r *execRestorer) Restore(data []byte) error {
+	cmd := exec.Command(r.command, "--noflush") // #nosec G204 -- command is a constant
+	cmd.Stdin = bytes.NewReader(data)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed: %w (output: %s)", r.command, err, string(output))
+	}
+	return nil
+}
+
+// realKubeClient wraps kubernetes.Interface to implement our KubeClient interface
+type realKubeClient struct {
+	client kubernetes.Interface
+}
+
+func NewKubeClient(client kubernetes.Interface) KubeClient {
+	return &realKubeClient{client: client}
+}
+
+func (k *realKubeClient) GetNode(ctx context.Context, name string) (*corev1.Node, error) {
+	return k.client.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{}) // nolint
+}
+
+func (k *realKubeClient) CreateEvent(ctx context.Context, namespace string, event *corev1.Event) (*corev1.Event, error) {
+	return k.client.CoreV1().Events(namespace).Create(ctx, event, metav1.CreateOptions{}) // nolint
+}
@@ -7,13 +7,17 @@ import (
 	"fmt"
 	"io/fs"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	goiptables "github.com/coreos/go-iptables/iptables"
 	"gopkg.in/yaml.v2"
 	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/component-base/logs"
 	"k8s.io/component-base/version/verflag"
 	"k8s.io/klog/v2"
@@ -27,8 +31,14 @@ var (
 	configPath = flag.String("input", "/etc/config/", `Name of the directory with configs to merge`)
 	// merged config written to this directory
 	outputPath = flag.String("output", "/etc/merged-config/", `Name of the directory to output the merged config`)
+	// own the IP-MASQ chain instead of leaving it to ip-masq-agent
+	manageIPTables = flag.Bool("manage-iptables", false, "Whether to reconcile the nat IP-MASQ chain to the merged config instead of only writing it for ip-masq-agent")
+	ipv6Enabled    = flag.Bool("ipv6", false, "Whether to also reconcile the ip6tables IP-MASQ chain")
+	metricsAddress = flag.String("metrics-address", "", "Address to serve the /metrics, /healthz and /config endpoints on, disabled when empty")
+	sendEvents     = flag.Bool("events", false, "Whether to send node events when the config files are rejected")
 	// errors
 	errAlignment = errors.New("ip not aligned to CIDR block")
+	errOverlap   = errors.New("CIDRs overlap")
 )
 
 const (
@@ -39,6 +49,15 @@ const (
 	cidrAlignErrFmt = "CIDR %q is not aligned to a CIDR block, ip: %q network: %q: %w"
 )
 
+// configError is an error in the config files. The daemon reports it and keeps the last valid config.
+type configError struct {
+	err error
+}
+
+func (e *configError) Error() string { return e.err.Error() }
+
+func (e *configError) Unwrap() error { return e.err }
+
 type FileSystem interface {
 	ReadFile(name string) ([]byte, error)
 	WriteFile(name string, data []byte, perm fs.FileMode) error
@@ -64,7 +83,6 @@ func (OSFileSystem) DeleteFile(name string) error {
 	return os.Remove(name) // nolint
 }
 
-// name of nat chain for iptables masquerade rules
 var resyncInterval = flag.Int("resync-interval", 60, "How often to refresh the config (in seconds)")
 
 // MasqConfig object
@@ -83,9 +101,27 @@ func EmptyMasqConfig() *MasqConfig {
 	}
 }
 
+// DefaultMasqConfig returns the config ip-masq-agent uses when it has no config file
+func DefaultMasqConfig() *MasqConfig {
+	return &MasqConfig{
+		NonMasqueradeCIDRs: []string{
+			"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "192.0.0.0/24", "192.0.2.0/24",
+			"192.88.99.0/24", "198.18.0.0/15", "198.51.100.0/24", "203.0.113.0/24", "240.0.0.0/4",
+		},
+		MasqLinkLocal:     false,
+		MasqLinkLocalIPv6: false,
+	}
+}
+
 // MasqDaemon object
 type MasqDaemon struct {
 	config *MasqConfig
+	// reconcilers own the IP-MASQ chains when iptables are managed
+	reconcilers []*chainReconciler
+	// merged is set once the config files have been merged successfully
+	merged bool
+	status syncStatus
+	events *eventReporter
 }
 
 // NewMasqDaemon returns a MasqDaemon with default values
@@ -110,6 +146,49 @@ func main() {
 	verflag.PrintAndExitIfRequested()
 
 	m := NewMasqDaemon(c)
+
+	if *sendEvents {
+		// get current node name from environment variable
+		nodeName := os.Getenv("NODE_NAME")
+		if nodeName == "" {
+			klog.Fatalf("NODE_NAME environment variable not set")
+		}
+		config, err := rest.InClusterConfig()
+		if err != nil {
+			klog.Fatalf("failed to create in-cluster config: %v", err)
+		}
+		clientset, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			klog.Fatalf("failed to create kubernetes clientset: %v", err)
+		}
+		m.events = &eventReporter{client: NewKubeClient(clientset), nodeName: nodeName}
+	}
+
+	if *manageIPTables {
+		iptablesClient, err := goiptables.New()
+		if err != nil {
+			klog.Fatalf("failed to create iptables client: %v", err)
+		}
+		m.reconcilers = append(m.reconcilers, &chainReconciler{family: familyIPv4, iptables: iptablesClient, restorer: NewRestorer(false)})
+		if *ipv6Enabled {
+			ip6tablesClient, err := goiptables.New(goiptables.IPFamily(goiptables.ProtocolIPv6))
+			if err != nil {
+				klog.Fatalf("failed to create ip6tables client: %v", err)
+			}
+			m.reconcilers = append(m.reconcilers, &chainReconciler{family: familyIPv6, iptables: ip6tablesClient, restorer: NewRestorer(true)})
+		}
+		klog.Infof("Managing the %s chain, IPv6: %v", masqChain, *ipv6Enabled)
+	}
+
+	if *metricsAddress != "" {
+		server := &http.Server{Addr: *metricsAddress, Handler: m.status.handler(), ReadHeaderTimeout: 5 * time.Second}
+		go func() {
+			if err := server.ListenAndServe(); err != nil {
+				klog.Fatalf("failed to serve metrics: %v", err)
+			}
+		}()
+	}
+
 	err := m.Run()
 	if err != nil {
 		klog.Fatalf("the daemon encountered an error: %v", err)
@@ -119,19 +198,52 @@ func main() {
 func (m *MasqDaemon) Run() error {
 	// Periodically resync
 	for {
-		// resync config
-		err := m.osMergeConfig()
+		err := m.syncWith(OSFileSystem{})
 		if err != nil {
-			return fmt.Errorf("error merging configuration: %w", err)
+			return err
 		}
 
 		time.Sleep(time.Duration(*resyncInterval) * time.Second)
 	}
 }
 
-func (m *MasqDaemon) osMergeConfig() error {
-	var fs FileSystem = OSFileSystem{}
-	return m.mergeConfig(fs)
+// syncWith merges the config files and reconciles the IP-MASQ chains to the merged config. Rejected
+// config files and failures to apply the rules are reported, and the last valid config stays in effect.
+func (m *MasqDaemon) syncWith(fileSys FileSystem) error {
+	var syncErr error
+	// resync config
+	err := m.mergeConfig(fileSys)
+	var cfgErr *configError
+	switch {
+	case errors.As(err, &cfgErr):
+		klog.Errorf("rejected config files, keeping the last valid config: %v", err)
+		configRejected.Inc()
+		m.events.report("InvalidIPMasqConfig", "ip-masq config files rejected: "+err.Error())
+		syncErr = err
+	case err != nil:
+		return fmt.Errorf("error merging configuration: %w", err)
+	default:
+		m.merged = true
+		m.events.reset()
+	}
+
+	if m.merged {
+		nonMasqueradeCIDRs.Set(float64(len(m.config.NonMasqueradeCIDRs)))
+		for _, r := range m.reconcilers {
+			drifted, count, err := r.reconcile(m.config)
+			if drifted {
+				driftTotal.WithLabelValues(r.family).Inc()
+			}
+			natRules.WithLabelValues(r.family).Set(float64(count))
+			if err != nil {
+				klog.Errorf("failed to reconcile %s chain: %v", masqChain, err)
+				reconcileErrors.WithLabelValues(r.family).Inc()
+				syncErr = errors.Join(syncErr, err)
+			}
+		}
+	}
+	m.status.set(m.config, syncErr)
+	return nil
 }
 
 // Syncs the config to the file at ConfigPath, or uses defaults if the file could not be found
@@ -171,24 +283,33 @@ func (m *MasqDaemon) mergeConfig(fileSys FileSystem) error {
 
 		json, err = utilyaml.ToJSON(yaml)
 		if err != nil {
-			return fmt.Errorf("failed to convert config file %q to JSON, error: %w", file.Name(), err)
+			return &configError{fmt.Errorf("failed to convert config file %q to JSON, error: %w", file.Name(), err)}
 		}
 
 		var newConfig MasqConfig
 		err = utiljson.Unmarshal(json, &newConfig)
 		if err != nil {
-			return fmt.Errorf("failed to unmarshal config file %q, error: %w", file.Name(), err)
+			return &configError{fmt.Errorf("failed to unmarshal config file %q, error: %w", file.Name(), err)}
 		}
 
 		err = newConfig.validate()
 		if err != nil {
-			return fmt.Errorf("config file %q is invalid: %w", file.Name(), err)
+			return &configError{fmt.Errorf("config file %q is invalid: %w", file.Name(), err)}
 		}
 		c.merge(&newConfig)
 
 		configAdded = true
 	}
 
+	// overlapping CIDRs are only a problem for the rules the merger writes itself, ip-masq-agent
+	// accepts them, so they are only rejected when the merger owns the IP-MASQ chains
+	if len(m.reconcilers) > 0 {
+		err = c.validateOverlaps()
+		if err != nil {
+			return &configError{fmt.Errorf("merged config is invalid: %w", err)}
+		}
+	}
+
 	mergedPath := filepath.Join(*outputPath, "ip-masq-agent")
 
 	if !configAdded {
@@ -199,6 +320,7 @@ func (m *MasqDaemon) mergeConfig(fileSys FileSystem) error {
 		if err != nil && !errors.Is(err, fs.ErrNotExist) {
 			return fmt.Errorf("failed to remove existing config file: %w", err)
 		}
+		m.config = DefaultMasqConfig()
 		return nil
 	}
 
@@ -229,6 +351,24 @@ func (c *MasqConfig) validate() error {
 	return nil
 }
 
+// validateOverlaps checks that no two distinct CIDRs overlap, as one would shadow the other
+func (c *MasqConfig) validateOverlaps() error {
+	nets := make([]*net.IPNet, 0, len(c.NonMasqueradeCIDRs))
+	for _, cidr := range c.NonMasqueradeCIDRs {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf(cidrParseErrFmt, cidr, err)
+		}
+		for _, other := range nets {
+			if ipnet.Contains(other.IP) || other.Contains(ipnet.IP) {
+				return fmt.Errorf("CIDRs %q and %q overlap: %w", other.String(), ipnet.String(), errOverlap)
+			}
+		}
+		nets = append(nets, ipnet)
+	}
+	return nil
+}
+
 // merge combines the existing MasqConfig with newConfig. The bools are OR'd together.
 func (c *MasqConfig) merge(newConfig *MasqConfig) {
 	if newConfig == nil {
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	natTable        = "nat"
+	postRouting     = "POSTROUTING"
+	masqChain       = "IP-MASQ"
+	linkLocalCIDR   = "169.254.0.0/16"
+	linkLocalCIDRv6 = "fe80::/10"
+	returnComment   = "ip-masq-merger: local traffic is not subject to MASQUERADE"
+	masqComment     = "ip-masq-merger: outbound traffic is subject to MASQUERADE (must be last in chain)"
+	jumpComment     = "ip-masq-merger: ensure nat POSTROUTING directs all non-LOCAL destination traffic to our custom IP-MASQ chain"
+	familyIPv4      = "ipv4"
+	familyIPv6      = "ipv6"
+)
+
+// jumpRule is the POSTROUTING rule which sends the traffic through the IP-MASQ chain
+var jumpRule = []string{"-m", "comment", "--comment", jumpComment, "-m", "addrtype", "!", "--dst-type", "LOCAL", "-j", masqChain}
+
+// desiredRules returns the rules of the IP-MASQ chain for the config and address family,
+// in the form they are listed by iptables -S
+func desiredRules(c *MasqConfig, family string) []string {
+	rules := []string{}
+	returnRule := func(cidr string) string {
+		return fmt.Sprintf("-A %s -d %s -m comment --comment %q -j RETURN", masqChain, cidr, returnComment)
+	}
+	if family == familyIPv4 && !c.MasqLinkLocal {
+		rules = append(rules, returnRule(linkLocalCIDR))
+	}
+	if family == familyIPv6 && !c.MasqLinkLocalIPv6 {
+		rules = append(rules, returnRule(linkLocalCIDRv6))
+	}
+	cidrs := slices.Clone(c.NonMasqueradeCIDRs)
+	slices.Sort(cidrs)
+	for _, cidr := range cidrs {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			// configs are validated before they are applied
+			continue
+		}
+		if (ipnet.IP.To4() != nil) != (family == familyIPv4) {
+			continue
+		}
+		rules = append(rules, returnRule(ipnet.String()))
+	}
+	return append(rules, fmt.Sprintf("-A %s -m comment --comment %q -j MASQUERADE", masqChain, masqComment))
+}
+
+// restoreData returns the iptables-restore input which replaces the IP-MASQ chain with the rules.
+// Declaring the chain flushes it, so the chain is replaced in one transaction.
+func restoreData(rules []string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%s\n:%s - [0:0]\n", natTable, masqChain)
+	for _, rule := range rules {
+		b.WriteString(rule)
+		b.WriteString("\n")
+	}
+	b.WriteString("COMMIT\n")
+	return []byte(b.String())
+}
+
+// chainReconciler owns the IP-MASQ chain of one address family
+type chainReconciler struct {
+	family   string
+	iptables IPTablesClient
+	restorer Restorer
+	// applied are the rules last written to the chain
+	applied []string
+}
+
+// reconcile makes the IP-MASQ chain match the config. It returns whether the chain had drifted from
+// the rules last applied, and the number of rules in the chain.
+func (r *chainReconciler) reconcile(c *MasqConfig) (drifted bool, ruleCount int, err error) {
+	desired := desiredRules(c, r.family)
+	current, err := r.currentRules()
+	if err != nil {
+		return false, 0, err
+	}
+	jumpExists, err := r.iptables.Exists(natTable, postRouting, jumpRule...)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to check %s jump rule: %w", postRouting, err)
+	}
+	if slices.Equal(current, desired) && jumpExists {
+		r.applied = desired
+		return false, len(desired), nil
+	}
+
+	// a difference from the rules we applied was made by something else
+	drifted = r.applied != nil && (!slices.Equal(current, r.applied) || !jumpExists)
+	if drifted {
+		klog.Warningf("%s %s chain drifted from the applied rules, current rules: %v, jump rule present: %v", r.family, masqChain, current, jumpExists)
+	}
+
+	klog.V(2).Infof("applying %d %s %s rules", len(desired), r.family, masqChain)
+	if err := r.restorer.Restore(restoreData(desired)); err != nil {
+		return drifted, len(current), fmt.Errorf("failed to apply %s rules: %w", r.family, err)
+	}
+	if !jumpExists {
+		if err := r.iptables.Append(natTable, postRouting, jumpRule...); err != nil {
+			return drifted, len(desired), fmt.Errorf("failed to add %s jump rule: %w", postRouting, err)
+		}
+	}
+	r.applied = desired
+	return drifted, len(desired), nil
+}
+
+// currentRules lists the rules of the IP-MASQ chain, or none if the chain does not exist
+func (r *chainReconciler) currentRules() ([]string, error) {
+	exists, err := r.iptables.ChainExists(natTable, masqChain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for %s chain: %w", masqChain, err)
+	}
+	if !exists {
+		return []string{}, nil
+	}
+	rules, err := r.iptables.List(natTable, masqChain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s rules: %w", masqChain, err)
+	}
+	current := []string{}
+	for _, rule := range rules {
+		// skip the chain declaration
+		if strings.HasPrefix(rule, "-A ") {
+			current = append(current, rule)
+		}
+	}
+	return current, nil
+}
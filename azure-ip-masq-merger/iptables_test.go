@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// mockIPTables keeps the nat rules of the IP-MASQ and POSTROUTING chains
+type mockIPTables struct {
+	chain []string // nil when the chain does not exist
+	jump  bool
+}
+
+func (m *mockIPTables) ChainExists(_, _ string) (bool, error) {
+	return m.chain != nil, nil
+}
+
+func (m *mockIPTables) List(_, chain string) ([]string, error) {
+	return append([]string{"-N " + chain}, m.chain...), nil
+}
+
+func (m *mockIPTables) Exists(_, _ string, _ ...string) (bool, error) {
+	return m.jump, nil
+}
+
+func (m *mockIPTables) Append(_, _ string, _ ...string) error {
+	m.jump = true
+	return nil
+}
+
+// mockRestorer applies the restore data to a mockIPTables
+type mockRestorer struct {
+	iptables *mockIPTables
+	calls    int
+	err      error
+}
+
+func (m *mockRestorer) Restore(data []byte) error {
+	m.calls++
+	if m.err != nil {
+		return m.err
+	}
+	m.iptables.chain = []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "-A ") {
+			m.iptables.chain = append(m.iptables.chain, line)
+		}
+	}
+	return nil
+}
+
+type mockKubeClient struct {
+	events []*corev1.Event
+}
+
+func (m *mockKubeClient) GetNode(_ context.Context, name string) (*corev1.Node, error) {
+	node := &corev1.Node{}
+	node.Name = name
+	return node, nil
+}
+
+func (m *mockKubeClient) CreateEvent(_ context.Context, _ string, event *corev1.Event) (*corev1.Event, error) {
+	m.events = append(m.events, event)
+	return event, nil
+}
+
+func TestDesiredRules(t *testing.T) {
+	c := &MasqConfig{
+		NonMasqueradeCIDRs: []string{"192.168.0.0/16", "fd00::/8", "10.0.0.0/8"},
+		MasqLinkLocal:      false,
+		MasqLinkLocalIPv6:  true,
+	}
+	require.Equal(t, []string{
+		`-A IP-MASQ -d 169.254.0.0/16 -m comment --comment "ip-masq-merger: local traffic is not subject to MASQUERADE" -j RETURN`,
+		`-A IP-MASQ -d 10.0.0.0/8 -m comment --comment "ip-masq-merger: local traffic is not subject to MASQUERADE" -j RETURN`,
+		`-A IP-MASQ -d 192.168.0.0/16 -m comment --comment "ip-masq-merger: local traffic is not subject to MASQUERADE" -j RETURN`,
+		`-A IP-MASQ -m comment --comment "ip-masq-merger: outbound traffic is subject to MASQUERADE (must be last in chain)" -j MASQUERADE`,
+	}, desiredRules(c, familyIPv4))
+	require.Equal(t, []string{
+		`-A IP-MASQ -d fd00::/8 -m comment --comment "ip-masq-merger: local traffic is not subject to MASQUERADE" -j RETURN`,
+		`-A IP-MASQ -m comment --comment "ip-masq-merger: outbound traffic is subject to MASQUERADE (must be last in chain)" -j MASQUERADE`,
+	}, desiredRules(c, familyIPv6))
+
+	data := string(restoreData(desiredRules(c, familyIPv6)))
+	require.True(t, strings.HasPrefix(data, "*nat\n:IP-MASQ - [0:0]\n"), "unexpected restore data: %s", data)
+	require.True(t, strings.HasSuffix(data, "COMMIT\n"), "unexpected restore data: %s", data)
+}
+
+func TestReconcile(t *testing.T) {
+	ipt := &mockIPTables{}
+	restorer := &mockRestorer{iptables: ipt}
+	r := &chainReconciler{family: familyIPv4, iptables: ipt, restorer: restorer}
+	c := &MasqConfig{NonMasqueradeCIDRs: []string{"10.0.0.0/8"}}
+
+	// the chain is created
+	drifted, count, err := r.reconcile(c)
+	require.NoError(t, err)
+	require.False(t, drifted)
+	require.Equal(t, 3, count)
+	require.True(t, ipt.jump, "expected the POSTROUTING jump rule")
+	require.Equal(t, desiredRules(c, familyIPv4), ipt.chain)
+
+	// in sync, nothing is applied
+	drifted, _, err = r.reconcile(c)
+	require.NoError(t, err)
+	require.False(t, drifted)
+	require.Equal(t, 1, restorer.calls)
+
+	// a config change is not drift
+	c = &MasqConfig{NonMasqueradeCIDRs: []string{"10.0.0.0/8", "192.168.0.0/16"}}
+	drifted, count, err = r.reconcile(c)
+	require.NoError(t, err)
+	require.False(t, drifted)
+	require.Equal(t, 4, count)
+	require.Equal(t, 2, restorer.calls)
+
+	// a rule removed by something else is drift, and is restored
+	ipt.chain = ipt.chain[1:]
+	drifted, _, err = r.reconcile(c)
+	require.NoError(t, err)
+	require.True(t, drifted)
+	require.Equal(t, desiredRules(c, familyIPv4), ipt.chain)
+
+	// so is a removed jump rule
+	ipt.jump = false
+	drifted, _, err = r.reconcile(c)
+	require.NoError(t, err)
+	require.True(t, drifted)
+	require.True(t, ipt.jump)
+
+	restorer.err = errors.New("iptables-restore failed")
+	ipt.chain = []string{}
+	_, _, err = r.reconcile(c)
+	require.Error(t, err)
+}
+
+func TestValidateOverlaps(t *testing.T) {
+	valid := &MasqConfig{NonMasqueradeCIDRs: []string{"10.0.0.0/16", "10.1.0.0/16", "fd00::/8"}}
+	require.NoError(t, valid.validateOverlaps())
+
+	overlapping := &MasqConfig{NonMasqueradeCIDRs: []string{"10.0.0.0/8", "192.168.0.0/16", "10.240.0.0/16"}}
+	err := overlapping.validateOverlaps()
+	require.ErrorIs(t, err, errOverlap)
+}
+
+func TestSyncRejectsInvalidConfig(t *testing.T) {
+	fs := newMockFS()
+	fs.files[filepath.Join(*configPath, "ip-masq-a.yaml")] = mockFile{data: []byte(`{"nonMasqueradeCIDRs":["10.0.0.0/8"]}`)}
+	fs.dirs[*configPath] = []string{"ip-masq-a.yaml"}
+
+	ipt := &mockIPTables{}
+	kubeClient := &mockKubeClient{}
+	daemon := &MasqDaemon{
+		reconcilers: []*chainReconciler{{family: familyIPv4, iptables: ipt, restorer: &mockRestorer{iptables: ipt}}},
+		events:      &eventReporter{client: kubeClient, nodeName: "node"},
+	}
+	require.NoError(t, daemon.syncWith(fs))
+	require.Equal(t, desiredRules(daemon.config, familyIPv4), ipt.chain)
+
+	rec := httptest.NewRecorder()
+	daemon.status.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", http.NoBody))
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = httptest.NewRecorder()
+	daemon.status.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/config", http.NoBody))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "10.0.0.0/8")
+
+	// an overlapping CIDR in another file is rejected with a single event, and the last valid rules stay in place
+	applied := ipt.chain
+	fs.files[filepath.Join(*configPath, "ip-masq-b.yaml")] = mockFile{data: []byte(`{"nonMasqueradeCIDRs":["10.240.0.0/16"]}`)}
+	fs.dirs[*configPath] = []string{"ip-masq-a.yaml", "ip-masq-b.yaml"}
+	require.NoError(t, daemon.syncWith(fs))
+	require.NoError(t, daemon.syncWith(fs))
+	require.Equal(t, applied, ipt.chain)
+	require.Len(t, kubeClient.events, 1)
+	require.Equal(t, "InvalidIPMasqConfig", kubeClient.events[0].Reason)
+	require.Equal(t, corev1.EventTypeWarning, kubeClient.events[0].Type)
+
+	rec = httptest.NewRecorder()
+	daemon.status.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", http.NoBody))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Contains(t, rec.Body.String(), "overlap")
+}
+
+func TestSyncAllowsOverlapsWithoutIPTables(t *testing.T) {
+	fs := newMockFS()
+	fs.files[filepath.Join(*configPath, "ip-masq-a.yaml")] = mockFile{data: []byte(`{"nonMasqueradeCIDRs":["10.0.0.0/8"]}`)}
+	fs.files[filepath.Join(*configPath, "ip-masq-b.yaml")] = mockFile{data: []byte(`{"nonMasqueradeCIDRs":["10.240.0.0/16"]}`)}
+	fs.dirs[*configPath] = []string{"ip-masq-a.yaml", "ip-masq-b.yaml"}
+
+	// ip-masq-agent owns the chain, so the overlapping CIDRs are merged as they were before
+	daemon := &MasqDaemon{}
+	require.NoError(t, daemon.syncWith(fs))
+	require.ElementsMatch(t, []string{"10.0.0.0/8", "10.240.0.0/16"}, daemon.config.NonMasqueradeCIDRs)
+	require.Contains(t, fs.files, filepath.Join(*outputPath, "ip-masq-agent"))
+
+	rec := httptest.NewRecorder()
+	daemon.status.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", http.NoBody))
+	require.Equal(t, http.StatusOK, rec.Code)
+}
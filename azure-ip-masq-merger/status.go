@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	natRules = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ip_masq_merger_nat_rules",
+		Help: "Number of rules in the IP-MASQ chain by address family.",
+	}, []string{"family"})
+	nonMasqueradeCIDRs = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ip_masq_merger_non_masquerade_cidrs",
+		Help: "Number of non-masquerade CIDRs in the effective merged config.",
+	})
+	driftTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ip_masq_merger_drift_total",
+		Help: "Number of times the IP-MASQ chain was found changed from the applied rules, by address family.",
+	}, []string{"family"})
+	reconcileErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ip_masq_merger_reconcile_errors_total",
+		Help: "Number of failures to apply the IP-MASQ chain, by address family.",
+	}, []string{"family"})
+	configRejected = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ip_masq_merger_config_rejected_total",
+		Help: "Number of resyncs where the config files were rejected as invalid.",
+	})
+	lastSync = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ip_masq_merger_last_successful_sync_timestamp_seconds",
+		Help: "Time of the last resync which merged and applied the config without errors.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(natRules, nonMasqueradeCIDRs, driftTotal, reconcileErrors, configRejected, lastSync)
+}
+
+// syncStatus is the outcome of the last resync, served on the health and config endpoints
+type syncStatus struct {
+	mu     sync.RWMutex
+	config *MasqConfig
+	err    error
+	time   time.Time
+}
+
+func (s *syncStatus) set(c *MasqConfig, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c != nil {
+		s.config = c
+	}
+	s.err = err
+	s.time = time.Now()
+	if err == nil {
+		lastSync.SetToCurrentTime()
+	}
+}
+
+// healthz reports whether the last resync succeeded
+func (s *syncStatus) healthz(w http.ResponseWriter, _ *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.time.IsZero() {
+		http.Error(w, "not synced yet", http.StatusServiceUnavailable)
+		return
+	}
+	if s.err != nil {
+		http.Error(w, s.err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	_, _ = w.Write([]byte("ok"))
+}
+
+// effectiveConfig serves the merged config in effect
+func (s *syncStatus) effectiveConfig(w http.ResponseWriter, _ *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.config == nil {
+		http.Error(w, "no config in effect", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.config)
+}
+
+func (s *syncStatus) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", s.healthz)
+	mux.HandleFunc("/config", s.effectiveConfig)
+	return mux
+}
@@ -23,6 +23,11 @@ type IPTablesClient interface {
 	List(table, chain string) ([]string, error)
 }
 
+// RuleDeleter interface for deleting iptables rules
+type RuleDeleter interface {
+	Delete(table, chain string, rulespec ...string) error
+}
+
 // KubeClient interface with direct methods for testing
 type KubeClient interface {
 	GetNode(ctx context.Context, name string) (*corev1.Node, error)
@@ -53,6 +58,8 @@ type Dependencies struct {
 	EBPFClient    EBPFClient
 	FileReader    FileLineReader
 	RouteManager  RouteManager
+	// Remediators apply the remediation actions chosen by the allow-list files, ActionReport needs none
+	Remediators map[RemediationAction]Remediator
 }
 
 // Config struct holds runtime configuration
@@ -67,6 +74,7 @@ type Config struct {
 	NodeName                         string
 	TerminateOnSuccess               bool
 	InstallRoutesForHealthProbeReply bool
+	MetricsAddress                   string
 }
 
 // Implementation types that wrap real k8s clients
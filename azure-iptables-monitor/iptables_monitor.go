@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"net/netip"
@@ -39,6 +40,7 @@ var (
 	pinPath                          = flag.String("mapPath", "/azure-block-iptables-bpf-map/iptables_block_event_counter", "Path to pinned bpf map")
 	terminateOnSuccess               = flag.Bool("terminateOnSuccess", false, "Whether to terminate the program when no user iptables rules found")
 	installRoutesForHealthProbeReply = flag.Bool("installRoutesForHealthProbeReply", false, "Whether to install loopback routes for replies sent to kubelet health probes")
+	metricsAddress                   = flag.String("metricsAddress", "", "Address to serve prometheus metrics and the last report on, disabled if empty")
 )
 
 const (
@@ -126,26 +128,37 @@ func (e *realEBPFClient) GetBPFMapValue(pinPath string) (uint64, error) {
 	return value, nil
 }
 
-// patchLabel sets a specified label to a certain value on a ciliumnode resource by patching it
+// patchLabel sets a specified label to a certain value on a ciliumnode resource by patching it,
+// along with the report of the check as an annotation
 // Requires proper rbac
-func patchLabel(clientset DynamicClient, labelValue bool, nodeName string) error {
+func patchLabel(clientset DynamicClient, labelValue bool, report Report, nodeName string) error {
 	gvr := schema.GroupVersionResource{
 		Group:    "cilium.io",
 		Version:  "v2",
 		Resource: "ciliumnodes",
 	}
 
-	patch := []byte(fmt.Sprintf(`{
-	"metadata": {
-		"labels": {
-		"%s": "%v"
-		}
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"labels": map[string]string{
+				label: fmt.Sprintf("%v", labelValue),
+			},
+			"annotations": map[string]string{
+				reportAnnotation: string(reportJSON),
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal patch: %w", err)
 	}
-	}`, label, labelValue))
 
 	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
 	defer cancel()
-	err := clientset.PatchResource(ctx, gvr, nodeName, types.MergePatchType, patch)
+	err = clientset.PatchResource(ctx, gvr, nodeName, types.MergePatchType, patch)
 	if err != nil {
 		return fmt.Errorf("failed to patch %s with label %s=%v: %w", nodeName, label, labelValue, err)
 	}
@@ -193,9 +206,28 @@ func createNodeEvent(clientset KubeClient, nodeName, reason, message, eventType
 	return nil
 }
 
+// chainRule is a rule along with the chain it was listed in
+type chainRule struct {
+	chain string
+	rule  string
+}
+
 // GetRules returns all rules as a slice of strings for the specified tableName
 func GetRules(client IPTablesClient, tableName string) ([]string, error) {
-	var allRules []string
+	chainRules, err := getChainRules(client, tableName)
+	if err != nil {
+		return nil, err
+	}
+	allRules := make([]string, 0, len(chainRules))
+	for _, r := range chainRules {
+		allRules = append(allRules, r.rule)
+	}
+	return allRules, nil
+}
+
+// getChainRules returns all rules of the specified tableName along with their chain
+func getChainRules(client IPTablesClient, tableName string) ([]chainRule, error) {
+	var allRules []chainRule
 	chains, err := client.ListChains(tableName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list chains for table %s: %w", tableName, err)
@@ -206,18 +238,16 @@ func GetRules(client IPTablesClient, tableName string) ([]string, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to list rules for table %s chain %s: %w", tableName, chain, err)
 		}
-		allRules = append(allRules, rules...)
+		for _, rule := range rules {
+			allRules = append(allRules, chainRule{chain: chain, rule: rule})
+		}
 	}
 
 	return allRules, nil
 }
 
-// hasUnexpectedRules checks if any rules in currentRules don't match any of the allowedPatterns
-// Returns true if there are unexpected rules, false if all rules match expected patterns
-func hasUnexpectedRules(currentRules, allowedPatterns []string) bool {
-	foundUnexpectedRules := false
-
-	// compile regex patterns
+// compilePatterns compiles the allowedPatterns, skipping the invalid ones
+func compilePatterns(allowedPatterns []string) []*regexp.Regexp {
 	compiledPatterns := make([]*regexp.Regexp, 0, len(allowedPatterns))
 	for _, pattern := range allowedPatterns {
 		compiled, err := regexp.Compile(pattern)
@@ -227,19 +257,30 @@ func hasUnexpectedRules(currentRules, allowedPatterns []string) bool {
 		}
 		compiledPatterns = append(compiledPatterns, compiled)
 	}
+	return compiledPatterns
+}
+
+// isExpectedRule returns true if rule matches any of the compiledPatterns
+func isExpectedRule(rule string, compiledPatterns []*regexp.Regexp) bool {
+	for _, pattern := range compiledPatterns {
+		if pattern.MatchString(rule) {
+			klog.V(3).Infof("MATCHED: '%s' -> pattern: '%s'", rule, pattern.String())
+			return true
+		}
+	}
+	klog.Infof("Unexpected rule: %s", rule)
+	return false
+}
+
+// hasUnexpectedRules checks if any rules in currentRules don't match any of the allowedPatterns
+// Returns true if there are unexpected rules, false if all rules match expected patterns
+func hasUnexpectedRules(currentRules, allowedPatterns []string) bool {
+	foundUnexpectedRules := false
+	compiledPatterns := compilePatterns(allowedPatterns)
 
 	// check each rule to see if it matches any allowed pattern
 	for _, rule := range currentRules {
-		ruleMatched := false
-		for _, pattern := range compiledPatterns {
-			if pattern.MatchString(rule) {
-				klog.V(3).Infof("MATCHED: '%s' -> pattern: '%s'", rule, pattern.String())
-				ruleMatched = true
-				break
-			}
-		}
-		if !ruleMatched {
-			klog.Infof("Unexpected rule: %s", rule)
+		if !isExpectedRule(rule, compiledPatterns) {
 			foundUnexpectedRules = true
 			// continue to iterate over remaining rules to identify all unexpected rules
 		}
@@ -248,66 +289,90 @@ func hasUnexpectedRules(currentRules, allowedPatterns []string) bool {
 	return foundUnexpectedRules
 }
 
-// nodeHasUserIPTablesRules returns true if the node has iptables rules that do not match the regex
+// readAllowList reads the patterns and the remediation action of the allow-list file of name in path
+func readAllowList(fileReader FileLineReader, path, name string) ([]string, RemediationAction) {
+	filename := filepath.Join(path, name)
+	lines, err := fileReader.Read(filename)
+	if err != nil {
+		klog.V(2).Infof("No reference patterns file found for %s", name)
+		return []string{}, ""
+	}
+	return parseAllowList(filename, lines)
+}
+
+// findViolations returns the iptables rules of family that do not match the regex
 // specified in the rule's respective table: nat, mangle, filter, raw, or security
 // The global file's regexes can match to a rule in any table
-func nodeHasUserIPTablesRules(fileReader FileLineReader, path string, iptablesClient IPTablesClient) bool {
+// The remediation action of a rule is the one of its table's file, else the one of the global file, else ActionReport
+func findViolations(fileReader FileLineReader, path, family string, iptablesClient IPTablesClient) []Violation {
 	tables := []string{"nat", "mangle", "filter", "raw", "security"}
 
-	globalPatterns, err := fileReader.Read(filepath.Join(path, "global"))
-	if err != nil {
-		globalPatterns = []string{}
-		klog.V(2).Infof("No global patterns file found, using empty patterns")
-	}
+	globalPatterns, globalAction := readAllowList(fileReader, path, "global")
 
-	userIPTablesRules := false
+	var violations []Violation
 
 	klog.V(2).Infof("Using reference patterns files in %s", path)
 
 	for _, table := range tables {
-		rules, err := GetRules(iptablesClient, table)
+		rules, err := getChainRules(iptablesClient, table)
 		if err != nil {
 			klog.Errorf("failed to get rules for table %s: %v", table, err)
 			continue
 		}
 
-		var referencePatterns []string
-		referencePatterns, err = fileReader.Read(filepath.Join(path, table))
-		if err != nil {
-			referencePatterns = []string{}
-			klog.V(2).Infof("No reference patterns file found for table %s", table)
-		}
-
+		referencePatterns, action := readAllowList(fileReader, path, table)
 		referencePatterns = append(referencePatterns, globalPatterns...)
+		if action == "" {
+			action = globalAction
+		}
+		if action == "" {
+			action = ActionReport
+		}
+		compiledPatterns := compilePatterns(referencePatterns)
 
 		klog.V(3).Infof("===== %s =====", table)
-		if hasUnexpectedRules(rules, referencePatterns) {
-			klog.Infof("Unexpected rules detected in table %s", table)
-			userIPTablesRules = true
+		tableViolations := 0
+		for _, r := range rules {
+			if !isExpectedRule(r.rule, compiledPatterns) {
+				violations = append(violations, newViolation(family, table, r.chain, r.rule, action))
+				tableViolations++
+			}
+		}
+		if tableViolations > 0 {
+			klog.Infof("%d unexpected rules detected in table %s", tableViolations, table)
 		}
 	}
 
-	return userIPTablesRules
+	return violations
+}
+
+// nodeHasUserIPTablesRules returns true if the node has iptables rules that do not match the allow-list files in path
+func nodeHasUserIPTablesRules(fileReader FileLineReader, path string, iptablesClient IPTablesClient) bool {
+	return len(findViolations(fileReader, path, familyIPv4, iptablesClient)) > 0
 }
 
 // Check returns true if the node has user iptables rules (ipv4 or ipv6, based on the config), false otherwise
 func Check(cfg Config, deps Dependencies, previousBlocks *uint64) bool {
-	userIPTablesRulesFound := nodeHasUserIPTablesRules(deps.FileReader, cfg.ConfigPath4, deps.IPTablesV4)
-	if userIPTablesRulesFound {
+	violations := findViolations(deps.FileReader, cfg.ConfigPath4, familyIPv4, deps.IPTablesV4)
+	if len(violations) > 0 {
 		klog.Info("Above user iptables rules detected in IPv4 iptables")
 	}
 
 	// check ip6tables rules if enabled
 	if cfg.IPv6Enabled {
-		userIP6TablesRulesFound := nodeHasUserIPTablesRules(deps.FileReader, cfg.ConfigPath6, deps.IPTablesV6)
-		if userIP6TablesRulesFound {
+		violations6 := findViolations(deps.FileReader, cfg.ConfigPath6, familyIPv6, deps.IPTablesV6)
+		if len(violations6) > 0 {
 			klog.Info("Above user iptables rules detected in IPv6 iptables")
 		}
-		userIPTablesRulesFound = userIPTablesRulesFound || userIP6TablesRulesFound
+		violations = append(violations, violations6...)
 	}
+	userIPTablesRulesFound := len(violations) > 0
 
-	// update label based on whether user iptables rules were found
-	err := patchLabel(deps.DynamicClient, userIPTablesRulesFound, cfg.NodeName)
+	report := newReport(violations, time.Now())
+	record(report, violations)
+
+	// update label and report based on whether user iptables rules were found
+	err := patchLabel(deps.DynamicClient, userIPTablesRulesFound, report, cfg.NodeName)
 	if err != nil {
 		klog.Errorf("failed to patch label: %v", err)
 	} else {
@@ -315,12 +380,30 @@ func Check(cfg Config, deps Dependencies, previousBlocks *uint64) bool {
 	}
 
 	if cfg.SendEvents && userIPTablesRulesFound {
-		err = createNodeEvent(deps.KubeClient, cfg.NodeName, "UnexpectedIPTablesRules", "Node has unexpected iptables rules", corev1.EventTypeWarning)
+		msg := fmt.Sprintf("Node has %d unexpected iptables rules, see the %s annotation of the ciliumnode", len(violations), reportAnnotation)
+		err = createNodeEvent(deps.KubeClient, cfg.NodeName, "UnexpectedIPTablesRules", msg, corev1.EventTypeWarning)
 		if err != nil {
 			klog.Errorf("failed to create event: %v", err)
 		}
 	}
 
+	// remediators only report the checks which changed the node, so each transition creates a single event
+	for action, result := range remediate(deps.Remediators, violations) {
+		if !cfg.SendEvents {
+			continue
+		}
+		reason, eventType, msg := "RemediatedIPTablesRules", corev1.EventTypeNormal, fmt.Sprintf("Remediation %s applied to unexpected iptables rules", action)
+		switch {
+		case result.Err != nil:
+			reason, eventType, msg = "IPTablesRemediationFailed", corev1.EventTypeWarning, fmt.Sprintf("Remediation %s failed: %v", action, result.Err)
+		case result.Reverted:
+			reason, msg = "RevertedIPTablesRemediation", fmt.Sprintf("Remediation %s reverted, no unexpected iptables rules are left", action)
+		}
+		if err := createNodeEvent(deps.KubeClient, cfg.NodeName, reason, msg, eventType); err != nil {
+			klog.Errorf("failed to create remediation event: %v", err)
+		}
+	}
+
 	// if disabled the number of blocks never increases from zero
 	currentBlocks := uint64(0)
 	if cfg.CheckMap {
@@ -371,6 +454,10 @@ func Run(cfg Config, deps Dependencies) {
 		installHealthProbeReplyRoutes(deps, cfg.IPv6Enabled)
 	}
 
+	if cfg.MetricsAddress != "" {
+		go serveMetrics(cfg.MetricsAddress)
+	}
+
 	blockCount := uint64(0)
 
 	for {
@@ -410,6 +497,7 @@ func main() {
 		PinPath:                          *pinPath,
 		TerminateOnSuccess:               *terminateOnSuccess,
 		InstallRoutesForHealthProbeReply: *installRoutesForHealthProbeReply,
+		MetricsAddress:                   *metricsAddress,
 		NodeName:                         currentNodeName,
 	}
 
@@ -426,28 +514,35 @@ func main() {
 		klog.Fatalf("failed to create dynamic client: %v", err)
 	}
 
-	var iptablesClient IPTablesClient
-	iptablesClient, err = goiptables.New()
+	iptablesClient, err := goiptables.New()
 	if err != nil {
 		klog.Fatalf("failed to create iptables client: %v", err)
 	}
 
 	var ip6tablesClient IPTablesClient
+	var ip6tablesDeleter RuleDeleter
 	if *ipv6Enabled {
-		ip6tablesClient, err = goiptables.New(goiptables.IPFamily(goiptables.ProtocolIPv6))
+		client, err := goiptables.New(goiptables.IPFamily(goiptables.ProtocolIPv6))
 		if err != nil {
 			klog.Fatalf("failed to create ip6tables client: %v", err)
 		}
+		ip6tablesClient, ip6tablesDeleter = client, client
 	}
 	klog.Infof("IPv6: %v", *ipv6Enabled)
 
+	kubeClient := NewKubeClient(clientset)
+	kubeDynamicClient := NewDynamicClient(dynamicClient)
 	deps := Dependencies{
-		KubeClient:    NewKubeClient(clientset),
-		DynamicClient: NewDynamicClient(dynamicClient),
+		KubeClient:    kubeClient,
+		DynamicClient: kubeDynamicClient,
 		IPTablesV4:    iptablesClient,
 		IPTablesV6:    ip6tablesClient,
 		EBPFClient:    NewEBPFClient(),
 		FileReader:    OSFileLineReader{},
+		Remediators: map[RemediationAction]Remediator{
+			ActionDeleteRule: NewRuleDeleter(iptablesClient, ip6tablesDeleter),
+			ActionCordon:     NewNodeCordoner(kubeClient, kubeDynamicClient, currentNodeName),
+		},
 	}
 
 	if *installRoutesForHealthProbeReply {
@@ -81,6 +81,9 @@ type MockKubeClient struct {
 	Node  *corev1.Node
 	Event *corev1.Event
 	Error error
+
+	// Events records the created events
+	Events []*corev1.Event
 }
 
 func NewMockKubeClient() *MockKubeClient {
@@ -91,7 +94,8 @@ func (m *MockKubeClient) GetNode(_ context.Context, _ string) (*corev1.Node, err
 	return m.Node, m.Error
 }
 
-func (m *MockKubeClient) CreateEvent(_ context.Context, _ string, _ *corev1.Event) (*corev1.Event, error) {
+func (m *MockKubeClient) CreateEvent(_ context.Context, _ string, event *corev1.Event) (*corev1.Event, error) {
+	m.Events = append(m.Events, event)
 	return m.Event, m.Error
 }
 
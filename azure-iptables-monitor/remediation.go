@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// RemediationAction is what is done with the rules which do not match an allow-list
+type RemediationAction string
+
+const (
+	// ActionReport only reports the rules, it is the default
+	ActionReport RemediationAction = "report"
+	// ActionDeleteRule deletes the rules
+	ActionDeleteRule RemediationAction = "delete-rule"
+	// ActionCordon cordons the node
+	ActionCordon RemediationAction = "cordon"
+)
+
+const (
+	// actionDirective is the comment in an allow-list file which chooses its remediation action
+	actionDirective = "action:"
+	// cordonedAnnotation marks a node cordoned by ActionCordon, so that only those are uncordoned
+	cordonedAnnotation = "kubernetes.azure.com/user-iptables-rules-cordoned"
+)
+
+var (
+	errUnknownAction     = errors.New("unknown remediation action")
+	errNoClient          = errors.New("no iptables client")
+	errRuleNotDeletable  = errors.New("only appended rules can be deleted")
+	errUnterminatedQuote = errors.New("unterminated quote")
+)
+
+func parseAction(s string) (RemediationAction, error) {
+	switch action := RemediationAction(s); action {
+	case ActionReport, ActionDeleteRule, ActionCordon:
+		return action, nil
+	default:
+		return "", fmt.Errorf("%q: %w", s, errUnknownAction)
+	}
+}
+
+// parseAllowList splits the lines of an allow-list file into its regex patterns and its remediation action.
+// Lines starting with # are comments, and a "# action: <action>" comment chooses the action of the file.
+// The action is empty if the file does not choose one.
+func parseAllowList(filename string, lines []string) ([]string, RemediationAction) {
+	var action RemediationAction
+	patterns := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+			continue
+		}
+		comment := strings.TrimSpace(strings.TrimPrefix(line, "#"))
+		if !strings.HasPrefix(comment, actionDirective) {
+			continue
+		}
+		parsed, err := parseAction(strings.TrimSpace(strings.TrimPrefix(comment, actionDirective)))
+		if err != nil {
+			klog.Errorf("Ignoring remediation action in %s: %v", filename, err)
+			continue
+		}
+		action = parsed
+	}
+	return patterns, action
+}
+
+// Remediator applies a remediation action to the rules which do not match an allow-list. It is called on every
+// check, with no violations once the node is clean, and returns whether it changed the node.
+type Remediator interface {
+	Remediate(violations []Violation) (bool, error)
+}
+
+// splitRule splits a rule in iptables -S format into its arguments, unquoting quoted arguments such as comments
+func splitRule(rule string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		inArg   bool
+		quoted  bool
+		escaped bool
+	)
+	for _, r := range rule {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+			inArg = true
+		case r == ' ' && !quoted:
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("rule %q: %w", rule, errUnterminatedQuote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// ruleDeleter deletes the rules from the iptables of their family
+type ruleDeleter struct {
+	clients map[string]RuleDeleter
+}
+
+// NewRuleDeleter returns the Remediator of ActionDeleteRule, ip6tables may be nil if ipv6 is not monitored
+func NewRuleDeleter(iptables, ip6tables RuleDeleter) Remediator {
+	clients := map[string]RuleDeleter{familyIPv4: iptables}
+	if ip6tables != nil {
+		clients[familyIPv6] = ip6tables
+	}
+	return &ruleDeleter{clients: clients}
+}
+
+func (d *ruleDeleter) Remediate(violations []Violation) (bool, error) {
+	var errs []error
+	deleted := false
+	for _, v := range violations {
+		client, ok := d.clients[v.Family]
+		if !ok {
+			errs = append(errs, fmt.Errorf("rule %s of family %s: %w", v.Fingerprint, v.Family, errNoClient))
+			continue
+		}
+		args, err := splitRule(v.Rule)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		// chains and their policies (-N, -P) are left in place
+		if len(args) < 2 || args[0] != "-A" {
+			errs = append(errs, fmt.Errorf("rule %q: %w", v.Rule, errRuleNotDeletable))
+			continue
+		}
+		if err := client.Delete(v.Table, args[1], args[2:]...); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete rule %q from table %s: %w", v.Rule, v.Table, err))
+			continue
+		}
+		deleted = true
+		klog.Infof("Deleted unexpected %s rule %s from table %s: %s", v.Family, v.Fingerprint, v.Table, v.Rule)
+	}
+	return deleted, errors.Join(errs...)
+}
+
+// nodeCordoner marks the node unschedulable when unexpected rules appear, and uncordons it once they are gone
+// if it was the one to cordon it
+type nodeCordoner struct {
+	kubeClient KubeClient
+	client     DynamicClient
+	nodeName   string
+	// synced is set once the node was read, violating is whether the last synced check had violations
+	synced    bool
+	violating bool
+}
+
+// NewNodeCordoner returns the Remediator of ActionCordon. Requires rbac to get and patch nodes.
+func NewNodeCordoner(kubeClient KubeClient, client DynamicClient, nodeName string) Remediator {
+	return &nodeCordoner{kubeClient: kubeClient, client: client, nodeName: nodeName}
+}
+
+func (c *nodeCordoner) Remediate(violations []Violation) (bool, error) {
+	violating := len(violations) > 0
+	if c.synced && violating == c.violating {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	node, err := c.kubeClient.GetNode(ctx, c.nodeName)
+	if err != nil {
+		return false, fmt.Errorf("failed to get node %s: %w", c.nodeName, err)
+	}
+
+	changed := false
+	switch {
+	case violating && node.Spec.Unschedulable:
+		// cordoned before a restart of the monitor, or by someone else whose cordon is left alone
+		klog.Infof("Node %s is already cordoned, %d unexpected iptables rules", c.nodeName, len(violations))
+	case violating:
+		if err := c.patch(ctx, true, "true"); err != nil {
+			return false, fmt.Errorf("failed to cordon node %s: %w", c.nodeName, err)
+		}
+		changed = true
+		klog.Infof("Cordoned node %s for %d unexpected iptables rules", c.nodeName, len(violations))
+	case node.Annotations[cordonedAnnotation] == "true":
+		if err := c.patch(ctx, false, nil); err != nil {
+			return false, fmt.Errorf("failed to uncordon node %s: %w", c.nodeName, err)
+		}
+		changed = true
+		klog.Infof("Uncordoned node %s, it has no unexpected iptables rules left", c.nodeName)
+	}
+	c.synced, c.violating = true, violating
+	return changed, nil
+}
+
+// patch sets the node schedulability and the cordonedAnnotation, a nil annotation removes it
+func (c *nodeCordoner) patch(ctx context.Context, unschedulable bool, annotation any) error {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "nodes"}
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"annotations": map[string]any{
+				cordonedAnnotation: annotation,
+			},
+		},
+		"spec": map[string]any{
+			"unschedulable": unschedulable,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal patch: %w", err)
+	}
+	return c.client.PatchResource(ctx, gvr, c.nodeName, types.MergePatchType, patch)
+}
+
+// remediationResult is the outcome of a remediation which changed the node or failed. Reverted is set when the
+// change undid an earlier remediation because the node has no violations of its action left.
+type remediationResult struct {
+	Reverted bool
+	Err      error
+}
+
+// remediate applies the remediation action of each violation, and returns the result of each action which changed
+// the node or failed. Every remediator is called, so that those of actions without violations can revert theirs.
+func remediate(remediators map[RemediationAction]Remediator, violations []Violation) map[RemediationAction]remediationResult {
+	byAction := map[RemediationAction][]Violation{}
+	for _, v := range violations {
+		if v.Action == ActionReport {
+			continue
+		}
+		byAction[v.Action] = append(byAction[v.Action], v)
+	}
+
+	for action, vs := range byAction {
+		if _, ok := remediators[action]; !ok {
+			klog.Errorf("No remediator for action %s, %d unexpected rules are only reported", action, len(vs))
+		}
+	}
+
+	results := map[RemediationAction]remediationResult{}
+	for action, remediator := range remediators {
+		vs := byAction[action]
+		changed, err := remediator.Remediate(vs)
+		switch {
+		case err != nil:
+			klog.Errorf("Remediation %s failed: %v", action, err)
+			remediations.WithLabelValues(string(action), "failure").Inc()
+		case changed:
+			remediations.WithLabelValues(string(action), "success").Inc()
+		default:
+			continue
+		}
+		results[action] = remediationResult{Reverted: len(vs) == 0, Err: err}
+	}
+	return results
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MockRuleDeleter records the deleted rules
+type MockRuleDeleter struct {
+	Deleted [][]string
+	Error   error
+}
+
+func (m *MockRuleDeleter) Delete(table, chain string, rulespec ...string) error {
+	m.Deleted = append(m.Deleted, append([]string{table, chain}, rulespec...))
+	return m.Error
+}
+
+// MockRemediator records the violations it was asked to remediate, and changes the node whenever there are some
+type MockRemediator struct {
+	Violations []Violation
+	Error      error
+}
+
+func (m *MockRemediator) Remediate(violations []Violation) (bool, error) {
+	m.Violations = append(m.Violations, violations...)
+	return len(violations) > 0, m.Error
+}
+
+func TestFingerprint(t *testing.T) {
+	rule := "-A INPUT -s 10.0.0.0/8 -j DROP"
+	require.Equal(t, fingerprint(familyIPv4, "filter", rule), fingerprint(familyIPv4, "filter", "-A INPUT  -s 10.0.0.0/8 -j DROP "),
+		"whitespace should not change the fingerprint")
+	require.NotEqual(t, fingerprint(familyIPv4, "filter", rule), fingerprint(familyIPv6, "filter", rule))
+	require.NotEqual(t, fingerprint(familyIPv4, "filter", rule), fingerprint(familyIPv4, "nat", rule))
+	require.Len(t, fingerprint(familyIPv4, "filter", rule), 16)
+}
+
+func TestParseAllowList(t *testing.T) {
+	testCases := []struct {
+		name             string
+		lines            []string
+		expectedPatterns []string
+		expectedAction   RemediationAction
+	}{
+		{
+			name:             "no directive",
+			lines:            []string{"^-A INPUT -j ACCEPT$", "# a comment"},
+			expectedPatterns: []string{"^-A INPUT -j ACCEPT$"},
+			expectedAction:   "",
+		},
+		{
+			name:             "delete directive",
+			lines:            []string{"# action: delete-rule", "^-A INPUT -j ACCEPT$"},
+			expectedPatterns: []string{"^-A INPUT -j ACCEPT$"},
+			expectedAction:   ActionDeleteRule,
+		},
+		{
+			name:             "unknown directive is ignored",
+			lines:            []string{"#action: reboot", "^-A INPUT -j ACCEPT$"},
+			expectedPatterns: []string{"^-A INPUT -j ACCEPT$"},
+			expectedAction:   "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			patterns, action := parseAllowList("test", tc.lines)
+			require.Equal(t, tc.expectedPatterns, patterns)
+			require.Equal(t, tc.expectedAction, action)
+		})
+	}
+}
+
+func TestSplitRule(t *testing.T) {
+	args, err := splitRule(`-A KUBE-SERVICES -m comment --comment "kubernetes service \"nodeports\"" -j KUBE-NODEPORTS`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"-A", "KUBE-SERVICES", "-m", "comment", "--comment", `kubernetes service "nodeports"`, "-j", "KUBE-NODEPORTS"}, args)
+
+	_, err = splitRule(`-A INPUT -m comment --comment "unterminated`)
+	require.ErrorIs(t, err, errUnterminatedQuote)
+}
+
+func TestFindViolations(t *testing.T) {
+	fileReader := NewMockFileLineReader()
+	fileReader.files["/etc/config/global"] = []string{"# action: cordon", "^-N "}
+	fileReader.files["/etc/config/filter"] = []string{"# action: delete-rule", "^-A INPUT -i lo -j ACCEPT$"}
+
+	iptablesClient := NewMockIPTablesClient()
+	iptablesClient.rules = map[string]map[string][]string{
+		"filter": {
+			"INPUT":   {"-A INPUT -i lo -j ACCEPT", "-A INPUT -j DROP"},
+			"CUSTOM":  {"-N CUSTOM", "-A CUSTOM -j ACCEPT"},
+			"FORWARD": {},
+		},
+		"nat": {
+			"POSTROUTING": {"-A POSTROUTING -j MASQUERADE"},
+		},
+	}
+
+	violations := findViolations(fileReader, "/etc/config", familyIPv4, iptablesClient)
+	report := newReport(violations, time.Now())
+	require.Equal(t, 3, report.Count)
+	require.Equal(t, []Violation{
+		newViolation(familyIPv4, "filter", "CUSTOM", "-A CUSTOM -j ACCEPT", ActionDeleteRule),
+		newViolation(familyIPv4, "filter", "INPUT", "-A INPUT -j DROP", ActionDeleteRule),
+		newViolation(familyIPv4, "nat", "POSTROUTING", "-A POSTROUTING -j MASQUERADE", ActionCordon),
+	}, report.Violations)
+}
+
+func TestNewReportTruncates(t *testing.T) {
+	violations := make([]Violation, maxReportedViolations+5)
+	report := newReport(violations, time.Now())
+	require.Equal(t, maxReportedViolations+5, report.Count)
+	require.Equal(t, 5, report.Truncated)
+	require.Len(t, report.Violations, maxReportedViolations)
+}
+
+func TestRuleDeleter(t *testing.T) {
+	deleter4, deleter6 := &MockRuleDeleter{}, &MockRuleDeleter{}
+	remediator := NewRuleDeleter(deleter4, deleter6)
+
+	deleted, err := remediator.Remediate([]Violation{
+		newViolation(familyIPv4, "nat", "POSTROUTING", `-A POSTROUTING -m comment --comment "masq all" -j MASQUERADE`, ActionDeleteRule),
+		newViolation(familyIPv6, "filter", "INPUT", "-A INPUT -j DROP", ActionDeleteRule),
+	})
+	require.NoError(t, err)
+	require.True(t, deleted)
+	require.Equal(t, [][]string{{"nat", "POSTROUTING", "-m", "comment", "--comment", "masq all", "-j", "MASQUERADE"}}, deleter4.Deleted)
+	require.Equal(t, [][]string{{"filter", "INPUT", "-j", "DROP"}}, deleter6.Deleted)
+
+	// chains are not deleted
+	deleted, err = remediator.Remediate([]Violation{newViolation(familyIPv4, "filter", "CUSTOM", "-N CUSTOM", ActionDeleteRule)})
+	require.ErrorIs(t, err, errRuleNotDeletable)
+	require.False(t, deleted)
+	require.Len(t, deleter4.Deleted, 1)
+
+	// a clean node has nothing to delete
+	deleted, err = remediator.Remediate(nil)
+	require.NoError(t, err)
+	require.False(t, deleted)
+
+	// without ipv6 there is nothing to delete ipv6 rules with
+	_, err = NewRuleDeleter(deleter4, nil).Remediate([]Violation{newViolation(familyIPv6, "filter", "INPUT", "-A INPUT -j DROP", ActionDeleteRule)})
+	require.ErrorIs(t, err, errNoClient)
+}
+
+func TestNodeCordoner(t *testing.T) {
+	violations := []Violation{newViolation(familyIPv4, "filter", "INPUT", "-A INPUT -j DROP", ActionCordon)}
+
+	t.Run("cordons on the transition into violation and uncordons once clean", func(t *testing.T) {
+		kubeClient := NewMockKubeClient()
+		kubeClient.Node = &corev1.Node{}
+		dynamicClient := NewMockDynamicClient()
+		remediator := NewNodeCordoner(kubeClient, dynamicClient, "test-node")
+
+		changed, err := remediator.Remediate(nil)
+		require.NoError(t, err)
+		require.False(t, changed)
+		require.Empty(t, dynamicClient.PatchCalls)
+
+		changed, err = remediator.Remediate(violations)
+		require.NoError(t, err)
+		require.True(t, changed)
+		require.Len(t, dynamicClient.PatchCalls, 1)
+		require.Equal(t, "nodes", dynamicClient.PatchCalls[0].GVR.Resource)
+		require.Equal(t, "test-node", dynamicClient.PatchCalls[0].Name)
+		require.JSONEq(t, `{"metadata":{"annotations":{"`+cordonedAnnotation+`":"true"}},"spec":{"unschedulable":true}}`,
+			string(dynamicClient.PatchCalls[0].Data))
+
+		// the node is not patched again while the violations remain
+		kubeClient.Node = &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{cordonedAnnotation: "true"}},
+			Spec:       corev1.NodeSpec{Unschedulable: true},
+		}
+		changed, err = remediator.Remediate(violations)
+		require.NoError(t, err)
+		require.False(t, changed)
+		require.Len(t, dynamicClient.PatchCalls, 1)
+
+		changed, err = remediator.Remediate(nil)
+		require.NoError(t, err)
+		require.True(t, changed)
+		require.Len(t, dynamicClient.PatchCalls, 2)
+		require.JSONEq(t, `{"metadata":{"annotations":{"`+cordonedAnnotation+`":null}},"spec":{"unschedulable":false}}`,
+			string(dynamicClient.PatchCalls[1].Data))
+	})
+
+	t.Run("leaves the cordon of someone else", func(t *testing.T) {
+		kubeClient := NewMockKubeClient()
+		kubeClient.Node = &corev1.Node{Spec: corev1.NodeSpec{Unschedulable: true}}
+		dynamicClient := NewMockDynamicClient()
+		remediator := NewNodeCordoner(kubeClient, dynamicClient, "test-node")
+
+		changed, err := remediator.Remediate(violations)
+		require.NoError(t, err)
+		require.False(t, changed)
+
+		changed, err = remediator.Remediate(nil)
+		require.NoError(t, err)
+		require.False(t, changed)
+		require.Empty(t, dynamicClient.PatchCalls)
+	})
+
+	t.Run("uncordons a node cordoned before a restart", func(t *testing.T) {
+		kubeClient := NewMockKubeClient()
+		kubeClient.Node = &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{cordonedAnnotation: "true"}},
+			Spec:       corev1.NodeSpec{Unschedulable: true},
+		}
+		dynamicClient := NewMockDynamicClient()
+		remediator := NewNodeCordoner(kubeClient, dynamicClient, "test-node")
+
+		changed, err := remediator.Remediate(nil)
+		require.NoError(t, err)
+		require.True(t, changed)
+		require.Len(t, dynamicClient.PatchCalls, 1)
+	})
+
+	t.Run("retries after a failed cordon", func(t *testing.T) {
+		kubeClient := NewMockKubeClient()
+		kubeClient.Node = &corev1.Node{}
+		dynamicClient := NewMockDynamicClient()
+		dynamicClient.Error = errors.New("patch failed")
+		remediator := NewNodeCordoner(kubeClient, dynamicClient, "test-node")
+
+		_, err := remediator.Remediate(violations)
+		require.Error(t, err)
+
+		dynamicClient.Error = nil
+		changed, err := remediator.Remediate(violations)
+		require.NoError(t, err)
+		require.True(t, changed)
+	})
+}
+
+func TestCheckCreatesRemediationEventsOnTransitions(t *testing.T) {
+	fileReader := NewMockFileLineReader()
+	fileReader.files["/etc/config/ipv4/filter"] = []string{"# action: cordon", "^-A INPUT -j ACCEPT$"}
+
+	iptablesV4 := NewMockIPTablesClient()
+	iptablesV4.rules = map[string]map[string][]string{
+		"filter": {
+			"INPUT": {"-A INPUT -j ACCEPT", "-A INPUT -j DROP"},
+		},
+	}
+
+	kubeClient := NewMockKubeClient()
+	kubeClient.Node = &corev1.Node{}
+	dynamicClient := NewMockDynamicClient()
+	deps := Dependencies{
+		KubeClient:    kubeClient,
+		DynamicClient: dynamicClient,
+		IPTablesV4:    iptablesV4,
+		IPTablesV6:    NewMockIPTablesClient(),
+		EBPFClient:    NewMockEBPFClient(),
+		FileReader:    fileReader,
+		Remediators: map[RemediationAction]Remediator{
+			ActionCordon: NewNodeCordoner(kubeClient, dynamicClient, "test-node"),
+		},
+	}
+	cfg := Config{ConfigPath4: "/etc/config/ipv4", NodeName: "test-node", SendEvents: true}
+	previousBlocks := uint64(0)
+
+	reasons := func() []string {
+		var r []string
+		for _, e := range kubeClient.Events {
+			if e.Reason != "UnexpectedIPTablesRules" {
+				r = append(r, e.Reason)
+			}
+		}
+		return r
+	}
+
+	require.True(t, Check(cfg, deps, &previousBlocks))
+	require.True(t, Check(cfg, deps, &previousBlocks))
+	require.Equal(t, []string{"RemediatedIPTablesRules"}, reasons())
+
+	iptablesV4.rules["filter"]["INPUT"] = []string{"-A INPUT -j ACCEPT"}
+	kubeClient.Node = &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{cordonedAnnotation: "true"}},
+		Spec:       corev1.NodeSpec{Unschedulable: true},
+	}
+	require.False(t, Check(cfg, deps, &previousBlocks))
+	require.False(t, Check(cfg, deps, &previousBlocks))
+	require.Equal(t, []string{"RemediatedIPTablesRules", "RevertedIPTablesRemediation"}, reasons())
+}
+
+func TestCheckRemediatesAndReports(t *testing.T) {
+	fileReader := NewMockFileLineReader()
+	fileReader.files["/etc/config/ipv4/filter"] = []string{"# action: delete-rule", "^-A INPUT -j ACCEPT$"}
+	fileReader.files["/etc/config/ipv4/nat"] = []string{"# action: cordon"}
+
+	iptablesV4 := NewMockIPTablesClient()
+	iptablesV4.rules = map[string]map[string][]string{
+		"filter": {
+			"INPUT": {"-A INPUT -j ACCEPT", "-A INPUT -j DROP"},
+		},
+		"raw": {
+			"PREROUTING": {"-A PREROUTING -j NOTRACK"},
+		},
+	}
+
+	deleter := &MockRemediator{}
+	cordoner := &MockRemediator{}
+	deps := Dependencies{
+		KubeClient:    NewMockKubeClient(),
+		DynamicClient: NewMockDynamicClient(),
+		IPTablesV4:    iptablesV4,
+		IPTablesV6:    NewMockIPTablesClient(),
+		EBPFClient:    NewMockEBPFClient(),
+		FileReader:    fileReader,
+		Remediators: map[RemediationAction]Remediator{
+			ActionDeleteRule: deleter,
+			ActionCordon:     cordoner,
+		},
+	}
+	cfg := Config{ConfigPath4: "/etc/config/ipv4", NodeName: "test-node"}
+
+	previousBlocks := uint64(0)
+	require.True(t, Check(cfg, deps, &previousBlocks))
+
+	// only the rules of tables whose file chose an action are remediated, nat has no rules
+	require.Len(t, deleter.Violations, 1)
+	require.Equal(t, "-A INPUT -j DROP", deleter.Violations[0].Rule)
+	require.Empty(t, cordoner.Violations)
+
+	// the label and the report are set in a single patch
+	mockDynamic := deps.DynamicClient.(*MockDynamicClient)
+	require.Len(t, mockDynamic.PatchCalls, 1)
+	var patch struct {
+		Metadata struct {
+			Labels      map[string]string `json:"labels"`
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+	}
+	require.NoError(t, json.Unmarshal(mockDynamic.PatchCalls[0].Data, &patch))
+	require.Equal(t, "true", patch.Metadata.Labels[label])
+
+	var report Report
+	require.NoError(t, json.Unmarshal([]byte(patch.Metadata.Annotations[reportAnnotation]), &report))
+	require.Equal(t, 2, report.Count)
+	require.Equal(t, "filter", report.Violations[0].Table)
+	require.Equal(t, ActionDeleteRule, report.Violations[0].Action)
+	require.Equal(t, "raw", report.Violations[1].Table)
+	require.Equal(t, ActionReport, report.Violations[1].Action)
+	require.Equal(t, fingerprint(familyIPv4, "raw", "-A PREROUTING -j NOTRACK"), report.Violations[1].Fingerprint)
+}
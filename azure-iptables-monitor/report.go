@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/klog/v2"
+)
+
+const (
+	familyIPv4 = "ipv4"
+	familyIPv6 = "ipv6"
+
+	// reportAnnotation holds the JSON Report of the last check on the ciliumnode resource
+	reportAnnotation = "kubernetes.azure.com/user-iptables-rules-report"
+	// maxReportedViolations bounds the size of the report annotation, the remaining violations are only counted
+	maxReportedViolations = 50
+)
+
+// Violation is a rule which did not match the allow-list of its table
+type Violation struct {
+	Family string `json:"family"`
+	Table  string `json:"table"`
+	Chain  string `json:"chain"`
+	Rule   string `json:"rule"`
+	// Fingerprint identifies the rule across checks and nodes
+	Fingerprint string `json:"fingerprint"`
+	// Action is the remediation chosen by the allow-list file of the table
+	Action RemediationAction `json:"action"`
+}
+
+// newViolation builds the violation for a rule listed in chain of table
+func newViolation(family, table, chain, rule string, action RemediationAction) Violation {
+	return Violation{
+		Family:      family,
+		Table:       table,
+		Chain:       chain,
+		Rule:        rule,
+		Fingerprint: fingerprint(family, table, rule),
+		Action:      action,
+	}
+}
+
+// fingerprint hashes the family, table and whitespace-normalized rule, so that the same rule
+// has the same fingerprint regardless of when or on which node it is found
+func fingerprint(family, table, rule string) string {
+	sum := sha256.Sum256([]byte(family + "\x00" + table + "\x00" + strings.Join(strings.Fields(rule), " ")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Report is the structured result of a check, published on the ciliumnode resource
+type Report struct {
+	Time       time.Time   `json:"time"`
+	Count      int         `json:"count"`
+	Truncated  int         `json:"truncated,omitempty"`
+	Violations []Violation `json:"violations,omitempty"`
+}
+
+// newReport sorts the violations by family, table, chain and rule, and keeps at most maxReportedViolations of them
+func newReport(violations []Violation, now time.Time) Report {
+	sorted := make([]Violation, len(violations))
+	copy(sorted, violations)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Family != b.Family {
+			return a.Family < b.Family
+		}
+		if a.Table != b.Table {
+			return a.Table < b.Table
+		}
+		if a.Chain != b.Chain {
+			return a.Chain < b.Chain
+		}
+		return a.Rule < b.Rule
+	})
+
+	report := Report{Time: now.UTC(), Count: len(sorted), Violations: sorted}
+	if len(sorted) > maxReportedViolations {
+		report.Violations = sorted[:maxReportedViolations]
+		report.Truncated = len(sorted) - maxReportedViolations
+	}
+	return report
+}
+
+var (
+	unexpectedRules = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "azure_iptables_monitor_unexpected_rules",
+		Help: "Number of rules not matching the allow-list in the last check, by family, table and chain.",
+	}, []string{"family", "table", "chain"})
+	unexpectedRuleInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "azure_iptables_monitor_unexpected_rule_info",
+		Help: "Rules not matching the allow-list in the last check, by fingerprint and remediation action.",
+	}, []string{"family", "table", "chain", "fingerprint", "action"})
+	remediations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "azure_iptables_monitor_remediations_total",
+		Help: "Number of remediation actions taken on unexpected rules, by action and result.",
+	}, []string{"action", "result"})
+	lastCheck = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "azure_iptables_monitor_last_check_timestamp_seconds",
+		Help: "Time of the last check of the iptables rules.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(unexpectedRules, unexpectedRuleInfo, remediations, lastCheck)
+}
+
+// lastReport is the report of the last check, served on /report
+var lastReport atomic.Pointer[Report]
+
+// record replaces the last report and the unexpected rule metrics with the result of the last check
+func record(report Report, violations []Violation) {
+	lastReport.Store(&report)
+	unexpectedRules.Reset()
+	unexpectedRuleInfo.Reset()
+	for _, v := range violations {
+		unexpectedRules.WithLabelValues(v.Family, v.Table, v.Chain).Inc()
+		unexpectedRuleInfo.WithLabelValues(v.Family, v.Table, v.Chain, v.Fingerprint, string(v.Action)).Set(1)
+	}
+	lastCheck.SetToCurrentTime()
+}
+
+// serveMetrics serves the prometheus metrics and the last report on address until the program exits
+func serveMetrics(address string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/report", func(w http.ResponseWriter, _ *http.Request) {
+		report := lastReport.Load()
+		if report == nil {
+			http.Error(w, "no check completed yet", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			klog.Errorf("failed to write report: %v", err)
+		}
+	})
+	server := &http.Server{Addr: address, Handler: mux, ReadHeaderTimeout: requestTimeout}
+	klog.Infof("Serving metrics on %s", address)
+	if err := server.ListenAndServe(); err != nil {
+		klog.Errorf("metrics server failed: %v", err)
+	}
+}
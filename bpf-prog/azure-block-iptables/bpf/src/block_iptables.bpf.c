@@ -22,6 +22,19 @@
 #define NFNL_SUBSYS_NFTABLES 10
 #define NFT_MSG_NEWRULE 6
 
+// policy flags, set by userspace in iptables_block_config. Zero blocks on both paths.
+#define POLICY_AUDIT_ONLY (1 << 0)   // report changes that would be blocked without blocking them
+#define POLICY_ALLOW_LEGACY (1 << 1) // do not block iptables-legacy (setsockopt)
+#define POLICY_ALLOW_NFT (1 << 2)    // do not block iptables-nft (netlink)
+
+#define HOOK_LEGACY 1
+#define HOOK_NFT 2
+#define ACTION_BLOCKED 0
+#define ACTION_AUDITED 1
+
+#define ALLOWLIST_MAX_ENTRIES 64
+#define EVENTS_RINGBUF_SIZE (256 * 1024)
+
 #define CILIUM_AGENT "cilium-agent"
 #define IP_MASQ "ip-masq"
 #define AZURE_CNS "azure-cns"
@@ -40,6 +53,56 @@ struct {
     __uint(pinning, LIBBPF_PIN_BY_NAME);
 } iptables_block_event_counter SEC(".maps");
 
+struct policy_config {
+    u32 flags;
+};
+
+// executables are identified by the inode and device of the file, resolved from their path by userspace
+struct exe_key {
+    u64 ino;
+    u32 dev;
+    u32 pad;
+};
+
+// block_event is emitted for every change that is blocked, or would be blocked in audit mode
+struct block_event {
+    u64 cgroup_id;
+    u32 pid;
+    u32 ppid;
+    u8 hook;
+    u8 action;
+    u8 pad[6];
+    char comm[TASK_COMM_LEN];
+    char parent_comm[TASK_COMM_LEN];
+};
+
+struct {
+    __uint(type, BPF_MAP_TYPE_ARRAY);
+    __uint(max_entries, 1);
+    __type(key, u32);
+    __type(value, struct policy_config);
+} iptables_block_config SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, ALLOWLIST_MAX_ENTRIES);
+    __type(key, struct exe_key);
+    __type(value, u8);
+} iptables_block_allowed_exes SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, ALLOWLIST_MAX_ENTRIES);
+    __type(key, u64);
+    __type(value, u8);
+} iptables_block_allowed_cgroups SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_RINGBUF);
+    __uint(max_entries, EVENTS_RINGBUF_SIZE);
+    __uint(pinning, LIBBPF_PIN_BY_NAME);
+} iptables_block_events SEC(".maps");
+
 // This function checks if the parent process of the current task is allowed to install iptables rules.
 // It checks the parent's command name against a predefined list of allowed prefixes.
 bool is_allowed_parent ()
@@ -76,12 +139,43 @@ bool is_allowed_parent ()
         }
     }
 
-    parent_comm[TASK_COMM_LEN - 1] = '\0'; // Ensure null termination
-    bpf_printk("Blocked iptables rule - parent comm: %s, pid: %d\n", parent_comm, BPF_CORE_READ(parent_task, pid));
-
     return 0; // Block
 }
 
+// check if the executable of the task is in the allow list
+static __always_inline bool is_allowed_exe(struct task_struct *task)
+{
+    struct exe_key key = {};
+    struct file *exe_file;
+
+    if (!task)
+        return 0;
+
+    exe_file = BPF_CORE_READ(task, mm, exe_file);
+    if (!exe_file)
+        return 0;
+
+    key.ino = BPF_CORE_READ(exe_file, f_inode, i_ino);
+    key.dev = BPF_CORE_READ(exe_file, f_inode, i_sb, s_dev);
+
+    return bpf_map_lookup_elem(&iptables_block_allowed_exes, &key) != NULL;
+}
+
+// check if the current task or its parent runs an allowed executable, or the current task is in an allowed cgroup
+static __always_inline bool is_allowed_by_policy()
+{
+    struct task_struct *task = (struct task_struct *)bpf_get_current_task();
+    u64 cgroup_id = bpf_get_current_cgroup_id();
+
+    if (bpf_map_lookup_elem(&iptables_block_allowed_cgroups, &cgroup_id))
+        return 1;
+
+    if (is_allowed_exe(task))
+        return 1;
+
+    return is_allowed_exe(BPF_CORE_READ(task, real_parent));
+}
+
 // check if the current task is in the host network namespace
 // This function compares the inode number of the current network namespace with the host's network namespace inode
 // The host's network namespace inode is initialized by userspace when the BPF program is loaded.
@@ -123,6 +217,59 @@ void increment_event_counter(bool isAllow) {
     }
 }
 
+// emit the details of a blocked, or audited, change to the events ring buffer
+static __always_inline void emit_block_event(u8 hook, u8 action)
+{
+    struct task_struct *task = (struct task_struct *)bpf_get_current_task();
+    struct task_struct *parent_task = BPF_CORE_READ(task, real_parent);
+    struct block_event *event;
+
+    event = bpf_ringbuf_reserve(&iptables_block_events, sizeof(*event), 0);
+    if (!event)
+        return;
+
+    __builtin_memset(event, 0, sizeof(*event));
+    event->cgroup_id = bpf_get_current_cgroup_id();
+    event->pid = bpf_get_current_pid_tgid() >> 32;
+    event->hook = hook;
+    event->action = action;
+    bpf_get_current_comm(&event->comm, sizeof(event->comm));
+    if (parent_task) {
+        event->ppid = BPF_CORE_READ(parent_task, tgid);
+        bpf_core_read_str(&event->parent_comm, sizeof(event->parent_comm), &parent_task->comm);
+    }
+
+    bpf_ringbuf_submit(event, 0);
+}
+
+// enforce applies the policy to an iptables change made through the hook.
+// It returns 0 to allow the change and -EPERM to block it.
+static __always_inline int enforce(u8 hook)
+{
+    u32 key = 0;
+    u32 flags = 0;
+    struct policy_config *config = bpf_map_lookup_elem(&iptables_block_config, &key);
+
+    if (config)
+        flags = config->flags;
+
+    if ((hook == HOOK_LEGACY && (flags & POLICY_ALLOW_LEGACY)) || (hook == HOOK_NFT && (flags & POLICY_ALLOW_NFT)) ||
+        is_allowed_parent() || is_allowed_by_policy()) {
+        increment_event_counter(true);
+        return 0; // Allow the operation
+    }
+
+    if (flags & POLICY_AUDIT_ONLY) {
+        emit_block_event(hook, ACTION_AUDITED);
+        increment_event_counter(true);
+        return 0;
+    }
+
+    emit_block_event(hook, ACTION_BLOCKED);
+    increment_event_counter(false);
+    return -EPERM;
+}
+
 // blocking hook for iptables-legacy rule installation
 SEC("lsm/socket_setsockopt")
 int BPF_PROG(iptables_legacy_block, struct socket *sock, int level, int optname)
@@ -135,15 +282,9 @@ int BPF_PROG(iptables_legacy_block, struct socket *sock, int level, int optname)
     if (level == IPPROTO_IP || level == IPPROTO_IP6) {
         //iptables-legacy uses IPT_SO_SET_REPLACE to install rules
         if (optname == IPT_SO_SET_REPLACE) {
-            // block if not in host network namespace, and if the parent process is not allowed
+            // enforce the policy in the host network namespace
             if (is_host_ns()) {
-                if (!is_allowed_parent()) {
-                    increment_event_counter(false);
-                    return -EPERM;
-                } else {
-                    increment_event_counter(true);
-                    return 0; // Allow the operation
-                }
+                return enforce(HOOK_LEGACY);
             }
         }
     }
@@ -210,17 +351,8 @@ int BPF_PROG(iptables_nftables_block, struct sock *sk, struct sk_buff *skb) {
         __u32 nlmsg_len = nlh.nlmsg_len;
 
         if (subsys_id == NFNL_SUBSYS_NFTABLES && cmd == NFT_MSG_NEWRULE) {
-            // If the message is a new rule, check if the parent process is allowed
-            // and whether we are in the host network namespace.
-            // If not allowed, increment the event counter and return -EPERM.
-            if(is_allowed_parent()) {
-                increment_event_counter(true);
-                // Allow the operation
-                return 0;
-            } else {
-                increment_event_counter(false);
-                return -EPERM;
-            }
+            // If the message is a new rule, enforce the policy on it
+            return enforce(HOOK_NFT);
         }
 
         data = data + nlmsg_len;
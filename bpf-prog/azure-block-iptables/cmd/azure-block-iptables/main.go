@@ -4,27 +4,38 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
 
+	"github.com/Azure/azure-container-networking/bpf-prog/azure-block-iptables/pkg/blockevents"
+	"github.com/Azure/azure-container-networking/bpf-prog/azure-block-iptables/pkg/blockpolicy"
 	"github.com/Azure/azure-container-networking/bpf-prog/azure-block-iptables/pkg/bpfprogram"
 	"github.com/pkg/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 )
 
 // ProgramVersion is set during build
 var (
 	version         = "unknown"
 	ErrModeRequired = errors.New("mode is required")
-	ErrInvalidMode  = errors.New("invalid mode. Use -mode=attach or -mode=detach")
+	ErrInvalidMode  = errors.New("invalid mode. Use -mode=attach, -mode=detach or -mode=events")
+	ErrNodeName     = errors.New("NODE_NAME environment variable not set")
 )
 
 // Config holds configuration for the application
 type Config struct {
-	Mode            string // "attach" or "detach"
+	Mode            string // "attach", "detach" or "events"
 	Overwrite       bool   // force detach before attach
 	AttacherFactory bpfprogram.AttacherFactory
+	EventsInterval  time.Duration // how often block events are reported as node events
 }
 
 // parseArgs parses command line arguments and returns the configuration
@@ -32,6 +43,8 @@ func parseArgs() (*Config, error) {
 	var (
 		mode        = flag.String("mode", "", "Operation mode: 'attach' or 'detach' (required)")
 		overwrite   = flag.Bool("overwrite", false, "Force detach before attach (only applies to attach mode)")
+		policyPath  = flag.String("policy", "", "Path to a JSON blocking policy (only applies to attach mode)")
+		interval    = flag.Duration("events-interval", time.Minute, "How often block events are reported as node events (only applies to events mode)")
 		showVersion = flag.Bool("version", false, "Show version information")
 		showHelp    = flag.Bool("help", false, "Show help information")
 	)
@@ -52,14 +65,24 @@ func parseArgs() (*Config, error) {
 		return nil, ErrModeRequired
 	}
 
-	if *mode != "attach" && *mode != "detach" {
+	if *mode != "attach" && *mode != "detach" && *mode != "events" {
 		return nil, ErrInvalidMode
 	}
 
+	factory := bpfprogram.NewProgram
+	if *policyPath != "" {
+		policy, err := blockpolicy.LoadPolicy(*policyPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load policy")
+		}
+		factory = func() bpfprogram.Attacher { return bpfprogram.NewProgramWithPolicy(policy) }
+	}
+
 	return &Config{
 		Mode:            *mode,
 		Overwrite:       *overwrite,
-		AttacherFactory: bpfprogram.NewProgram,
+		AttacherFactory: factory,
+		EventsInterval:  *interval,
 	}, nil
 }
 
@@ -103,6 +126,41 @@ func detachMode(config *Config) error {
 	return nil
 }
 
+// eventsMode reports the block events of the attached BPF program as node events until terminated
+func eventsMode(config *Config) error {
+	log.Println("Starting events mode...")
+
+	nodeName := os.Getenv("NODE_NAME")
+	if nodeName == "" {
+		return ErrNodeName
+	}
+	restConfig, err := rest.InClusterConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to create in-cluster config")
+	}
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return errors.Wrap(err, "failed to create kubernetes clientset")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	reporter := blockevents.NewReporter(blockevents.NewKubeClient(clientset), nodeName)
+	go reporter.Run(ctx, config.EventsInterval)
+
+	pinPath := filepath.Join(bpfprogram.BPFMapPinPath, bpfprogram.EventsMapName)
+	if err := blockevents.Read(ctx, pinPath, reporter.Add); err != nil {
+		return errors.Wrap(err, "failed to read block events")
+	}
+
+	// report the events read before termination
+	if err := reporter.Flush(context.Background()); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return nil
+}
+
 // run is the main application logic
 func run(config *Config) error {
 	switch config.Mode {
@@ -110,6 +168,8 @@ func run(config *Config) error {
 		return attachMode(config)
 	case "detach":
 		return detachMode(config)
+	case "events":
+		return eventsMode(config)
 	default:
 		return ErrInvalidMode
 	}
@@ -0,0 +1,126 @@
+package blockevents
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+type mockKubeClient struct {
+	events []*corev1.Event
+}
+
+func (m *mockKubeClient) GetNode(_ context.Context, name string) (*corev1.Node, error) {
+	node := &corev1.Node{}
+	node.Name = name
+	node.UID = "node-uid"
+	return node, nil
+}
+
+func (m *mockKubeClient) CreateEvent(_ context.Context, _ string, event *corev1.Event) (*corev1.Event, error) {
+	m.events = append(m.events, event)
+	return event, nil
+}
+
+func encode(t *testing.T, raw *rawEvent) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.NativeEndian, raw); err != nil {
+		t.Fatalf("failed to encode event: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	raw := &rawEvent{CgroupID: 4242, PID: 100, PPID: 99, Hook: uint8(HookNftables), Action: uint8(ActionAudited)}
+	copy(raw.Comm[:], "iptables-nft")
+	copy(raw.ParentComm[:], "my-agent")
+	record := encode(t, raw)
+	if len(record) != 56 {
+		t.Fatalf("expected a 56 byte record matching struct block_event, got %d", len(record))
+	}
+
+	event, err := Decode(record)
+	if err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	want := Event{CgroupID: 4242, PID: 100, PPID: 99, Hook: HookNftables, Action: ActionAudited, Comm: "iptables-nft", ParentComm: "my-agent"}
+	if event != want {
+		t.Errorf("expected %+v, got %+v", want, event)
+	}
+	if s := event.String(); s != "audited iptables-nft change by iptables-nft (pid 100), parent my-agent (pid 99), cgroup 4242" {
+		t.Errorf("unexpected string %q", s)
+	}
+
+	if _, err := Decode(record[:20]); err == nil {
+		t.Error("expected an error for a short record")
+	}
+}
+
+func TestReporterAggregates(t *testing.T) {
+	client := &mockKubeClient{}
+	reporter := NewReporter(client, "node")
+
+	blocked := Event{PID: 1, Hook: HookLegacy, Action: ActionBlocked, Comm: "iptables", ParentComm: "bad-agent"}
+	for i := 0; i < 3; i++ {
+		blocked.PID = uint32(i)
+		reporter.Add(blocked)
+	}
+	reporter.Add(Event{PID: 7, Hook: HookNftables, Action: ActionAudited, Comm: "iptables", ParentComm: "other-agent"})
+
+	if err := reporter.Flush(context.Background()); err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+	if len(client.events) != 2 {
+		t.Fatalf("expected 2 aggregated events, got %d", len(client.events))
+	}
+	reasons := map[string]int32{}
+	for _, e := range client.events {
+		reasons[e.Reason] = e.Count
+		if e.InvolvedObject.UID != "node-uid" || e.Type != corev1.EventTypeWarning {
+			t.Errorf("unexpected event %+v", e)
+		}
+	}
+	if reasons[ReasonBlocked] != 3 || reasons[ReasonAudited] != 1 {
+		t.Errorf("unexpected event counts %v", reasons)
+	}
+
+	// nothing new to report
+	if err := reporter.Flush(context.Background()); err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+	if len(client.events) != 2 {
+		t.Errorf("expected no new events, got %d", len(client.events)-2)
+	}
+}
+
+func TestReporterLogsFirstEventOfEachChangePerInterval(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	reporter := NewReporter(&mockKubeClient{}, "node")
+	blocked := Event{PID: 1, Hook: HookLegacy, Action: ActionBlocked, Comm: "iptables", ParentComm: "bad-agent"}
+	for i := 0; i < 3; i++ {
+		blocked.PID = uint32(i)
+		reporter.Add(blocked)
+	}
+	reporter.Add(Event{PID: 7, Hook: HookNftables, Action: ActionAudited, Comm: "iptables", ParentComm: "other-agent"})
+	if n := strings.Count(logs.String(), "iptables change"); n != 2 {
+		t.Fatalf("expected 2 logged changes, got %d:\n%s", n, logs.String())
+	}
+
+	if err := reporter.Flush(context.Background()); err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+	reporter.Add(blocked)
+	if n := strings.Count(logs.String(), "iptables change"); n != 3 {
+		t.Errorf("expected the change to be logged again after a flush, got %d logged changes", n)
+	}
+}
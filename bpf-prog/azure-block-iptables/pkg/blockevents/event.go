@@ -0,0 +1,106 @@
+// Package blockevents decodes the iptables block events emitted by the BPF program
+// and reports them as Kubernetes node events.
+package blockevents
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Hook is the path through which iptables was changed.
+type Hook uint8
+
+// Hooks, matching HOOK_* of the BPF program.
+const (
+	HookLegacy   Hook = 1
+	HookNftables Hook = 2
+)
+
+func (h Hook) String() string {
+	switch h {
+	case HookLegacy:
+		return "iptables-legacy"
+	case HookNftables:
+		return "iptables-nft"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(h))
+	}
+}
+
+// Action is what the BPF program did with the change.
+type Action uint8
+
+// Actions, matching ACTION_* of the BPF program.
+const (
+	ActionBlocked Action = 0
+	ActionAudited Action = 1
+)
+
+func (a Action) String() string {
+	if a == ActionAudited {
+		return "audited"
+	}
+	return "blocked"
+}
+
+const taskCommLen = 16
+
+// rawEvent matches struct block_event of the BPF program.
+type rawEvent struct {
+	CgroupID   uint64
+	PID        uint32
+	PPID       uint32
+	Hook       uint8
+	Action     uint8
+	Pad        [6]uint8
+	Comm       [taskCommLen]byte
+	ParentComm [taskCommLen]byte
+}
+
+// Event is an iptables change that was blocked, or would have been in audit mode.
+type Event struct {
+	CgroupID   uint64
+	PID        uint32
+	PPID       uint32
+	Hook       Hook
+	Action     Action
+	Comm       string
+	ParentComm string
+}
+
+var errShortEvent = errors.New("event record too short")
+
+// Decode decodes a record of the events ring buffer.
+func Decode(record []byte) (Event, error) {
+	var raw rawEvent
+	if len(record) < binary.Size(raw) {
+		return Event{}, errors.Wrapf(errShortEvent, "%d bytes", len(record))
+	}
+	if err := binary.Read(bytes.NewReader(record), binary.NativeEndian, &raw); err != nil {
+		return Event{}, errors.Wrap(err, "failed to decode event")
+	}
+	return Event{
+		CgroupID:   raw.CgroupID,
+		PID:        raw.PID,
+		PPID:       raw.PPID,
+		Hook:       Hook(raw.Hook),
+		Action:     Action(raw.Action),
+		Comm:       cString(raw.Comm[:]),
+		ParentComm: cString(raw.ParentComm[:]),
+	}, nil
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+func (e Event) String() string {
+	return fmt.Sprintf("%s %s change by %s (pid %d), parent %s (pid %d), cgroup %d",
+		e.Action, e.Hook, e.Comm, e.PID, e.ParentComm, e.PPID, e.CgroupID)
+}
@@ -0,0 +1,48 @@
+//go:build linux
+// +build linux
+
+package blockevents
+
+import (
+	"context"
+	"log"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/ringbuf"
+	"github.com/pkg/errors"
+)
+
+// Read reads the events from the ring buffer pinned at pinPath and passes them to handle
+// until the context is cancelled.
+func Read(ctx context.Context, pinPath string, handle func(Event)) error {
+	m, err := ebpf.LoadPinnedMap(pinPath, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load pinned map %s", pinPath)
+	}
+	defer m.Close()
+
+	reader, err := ringbuf.NewReader(m)
+	if err != nil {
+		return errors.Wrap(err, "failed to create ring buffer reader")
+	}
+	go func() {
+		<-ctx.Done()
+		reader.Close()
+	}()
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, ringbuf.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to read from ring buffer")
+		}
+		event, err := Decode(record.RawSample)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		handle(event)
+	}
+}
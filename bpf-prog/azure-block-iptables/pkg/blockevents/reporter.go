@@ -0,0 +1,175 @@
+package blockevents
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ReasonBlocked is the reason of node events for blocked iptables changes
+	ReasonBlocked = "IPTablesRuleBlocked"
+	// ReasonAudited is the reason of node events for iptables changes that would be blocked
+	ReasonAudited = "IPTablesRuleAudited"
+
+	requestTimeout = 5 * time.Second
+	component      = "azure-block-iptables"
+)
+
+// KubeClient is the subset of the Kubernetes API used to create node events.
+type KubeClient interface {
+	GetNode(ctx context.Context, name string) (*corev1.Node, error)
+	CreateEvent(ctx context.Context, namespace string, event *corev1.Event) (*corev1.Event, error)
+}
+
+type kubeClient struct {
+	client kubernetes.Interface
+}
+
+// NewKubeClient wraps a clientset as a KubeClient.
+func NewKubeClient(client kubernetes.Interface) KubeClient {
+	return &kubeClient{client: client}
+}
+
+func (k *kubeClient) GetNode(ctx context.Context, name string) (*corev1.Node, error) {
+	return k.client.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{}) //nolint:wrapcheck // passthrough
+}
+
+func (k *kubeClient) CreateEvent(ctx context.Context, namespace string, event *corev1.Event) (*corev1.Event, error) {
+	return k.client.CoreV1().Events(namespace).Create(ctx, event, metav1.CreateOptions{}) //nolint:wrapcheck // passthrough
+}
+
+// aggregateKey groups the events of the same process making the same change.
+type aggregateKey struct {
+	hook       Hook
+	action     Action
+	comm       string
+	parentComm string
+	cgroupID   uint64
+}
+
+type aggregate struct {
+	last  Event
+	count int32
+}
+
+// Reporter aggregates block events and reports each distinct one as a node event once per interval,
+// so that a process retrying in a loop does not flood the API server.
+type Reporter struct {
+	client   KubeClient
+	nodeName string
+
+	mu      sync.Mutex
+	pending map[aggregateKey]*aggregate
+}
+
+// NewReporter returns a Reporter creating events on the node.
+func NewReporter(client KubeClient, nodeName string) *Reporter {
+	return &Reporter{
+		client:   client,
+		nodeName: nodeName,
+		pending:  map[aggregateKey]*aggregate{},
+	}
+}
+
+// Add records an event to be reported on the next Flush. Only the first event of each distinct change
+// since the last Flush is logged.
+func (r *Reporter) Add(e Event) {
+	k := aggregateKey{hook: e.Hook, action: e.Action, comm: e.Comm, parentComm: e.ParentComm, cgroupID: e.CgroupID}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a, ok := r.pending[k]
+	if !ok {
+		log.Printf("iptables change %s", e)
+		a = &aggregate{}
+		r.pending[k] = a
+	}
+	a.last = e
+	a.count++
+}
+
+// Flush creates a node event for each distinct change recorded since the last Flush.
+func (r *Reporter) Flush(ctx context.Context) error {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = map[aggregateKey]*aggregate{}
+	r.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	node, err := r.client.GetNode(ctx, r.nodeName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get node UID for %s", r.nodeName)
+	}
+
+	aggregates := make([]*aggregate, 0, len(pending))
+	for _, a := range pending {
+		aggregates = append(aggregates, a)
+	}
+	// report in a stable order
+	sort.Slice(aggregates, func(i, j int) bool { return aggregates[i].last.String() < aggregates[j].last.String() })
+
+	var errs []error
+	now := metav1.NewTime(time.Now())
+	for i, a := range aggregates {
+		reason := ReasonBlocked
+		if a.last.Action == ActionAudited {
+			reason = ReasonAudited
+		}
+		event := &corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("%s.%d.%d", r.nodeName, now.UnixNano(), i),
+				Namespace: "default",
+			},
+			InvolvedObject: corev1.ObjectReference{
+				Kind:       "Node",
+				Name:       r.nodeName,
+				UID:        node.UID, // required for event to show up in node describe
+				APIVersion: "v1",
+			},
+			Reason:         reason,
+			Message:        fmt.Sprintf("%d %s", a.count, a.last),
+			Type:           corev1.EventTypeWarning,
+			FirstTimestamp: now,
+			LastTimestamp:  now,
+			Count:          a.count,
+			Source: corev1.EventSource{
+				Component: component,
+			},
+		}
+		if _, err := r.client.CreateEvent(ctx, "default", event); err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to create event for node %s", r.nodeName))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Errorf("failed to report %d of %d events: %v", len(errs), len(aggregates), errs)
+	}
+	return nil
+}
+
+// Run flushes the recorded events every interval until the context is cancelled.
+func (r *Reporter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Flush(ctx); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+	}
+}
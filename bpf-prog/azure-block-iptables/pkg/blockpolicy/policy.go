@@ -0,0 +1,84 @@
+// Package blockpolicy configures which iptables changes the azure-block-iptables BPF program blocks.
+// It writes to the BPF maps through the Map interface, so it builds and is tested without the
+// generated BPF bindings.
+package blockpolicy
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/cilium/ebpf"
+	"github.com/pkg/errors"
+)
+
+// Policy flags, matching the POLICY_* flags of the BPF program.
+const (
+	policyAuditOnly   uint32 = 1 << 0
+	policyAllowLegacy uint32 = 1 << 1
+	policyAllowNft    uint32 = 1 << 2
+)
+
+// maxAllowListEntries is the capacity of the allow list maps of the BPF program.
+const maxAllowListEntries = 64
+
+// Map is the subset of *ebpf.Map the policy is written to.
+type Map interface {
+	Update(key, value interface{}, flags ebpf.MapUpdateFlags) error
+}
+
+// Policy configures which iptables changes the BPF program blocks. The zero Policy blocks
+// changes made through both iptables-legacy and iptables-nft by processes outside the built-in allow list.
+type Policy struct {
+	// AuditOnly reports the changes that would be blocked without blocking them.
+	AuditOnly bool `json:"auditOnly"`
+	// AllowLegacy does not block changes made through iptables-legacy (setsockopt).
+	AllowLegacy bool `json:"allowLegacy"`
+	// AllowNftables does not block changes made through iptables-nft (netlink).
+	AllowNftables bool `json:"allowNftables"`
+	// AllowedExecutables are the paths of executables allowed to change iptables, directly or as the parent of the iptables process.
+	AllowedExecutables []string `json:"allowedExecutables,omitempty"`
+	// AllowedCgroups are cgroup v2 paths, relative to the cgroup mount or absolute, whose processes are allowed to change iptables.
+	AllowedCgroups []string `json:"allowedCgroups,omitempty"`
+}
+
+// LoadPolicy reads a JSON policy from the file at path.
+func LoadPolicy(path string) (*Policy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read policy %s", path)
+	}
+	policy := &Policy{}
+	if err := json.Unmarshal(b, policy); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse policy %s", path)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// Validate checks that the allow lists fit in the BPF maps.
+func (p *Policy) Validate() error {
+	if len(p.AllowedExecutables) > maxAllowListEntries {
+		return errors.Errorf("%d allowed executables exceed the maximum of %d", len(p.AllowedExecutables), maxAllowListEntries)
+	}
+	if len(p.AllowedCgroups) > maxAllowListEntries {
+		return errors.Errorf("%d allowed cgroups exceed the maximum of %d", len(p.AllowedCgroups), maxAllowListEntries)
+	}
+	return nil
+}
+
+// flags returns the policy flags of the BPF program config.
+func (p *Policy) flags() uint32 {
+	var flags uint32
+	if p.AuditOnly {
+		flags |= policyAuditOnly
+	}
+	if p.AllowLegacy {
+		flags |= policyAllowLegacy
+	}
+	if p.AllowNftables {
+		flags |= policyAllowNft
+	}
+	return flags
+}
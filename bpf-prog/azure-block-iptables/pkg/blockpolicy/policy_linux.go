@@ -0,0 +1,85 @@
+//go:build linux
+// +build linux
+
+package blockpolicy
+
+import (
+	"log"
+	"path/filepath"
+
+	"github.com/cilium/ebpf"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// CgroupMountPath is the mount point of the cgroup v2 hierarchy
+const CgroupMountPath = "/sys/fs/cgroup"
+
+// policyConfig matches struct policy_config of the BPF program
+type policyConfig struct {
+	Flags uint32
+}
+
+// exeKey matches struct exe_key of the BPF program
+type exeKey struct {
+	Ino uint64
+	Dev uint32
+	Pad uint32
+}
+
+// executableKey identifies the executable at path by its inode and the kernel encoding of its device
+func executableKey(path string) (exeKey, error) {
+	var stat unix.Stat_t
+	if err := unix.Stat(path, &stat); err != nil {
+		return exeKey{}, errors.Wrapf(err, "failed to stat executable %s", path)
+	}
+	// the kernel encodes dev_t as major << 20 | minor
+	dev := unix.Major(stat.Dev)<<20 | unix.Minor(stat.Dev)
+	return exeKey{Ino: stat.Ino, Dev: dev}, nil
+}
+
+// cgroupID returns the id of the cgroup v2 at path, which is the inode of its directory
+func cgroupID(path string) (uint64, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(CgroupMountPath, path)
+	}
+	var stat unix.Stat_t
+	if err := unix.Stat(path, &stat); err != nil {
+		return 0, errors.Wrapf(err, "failed to stat cgroup %s", path)
+	}
+	return stat.Ino, nil
+}
+
+// Apply writes the policy to the config and allow list maps of the loaded BPF program.
+func (p *Policy) Apply(config, allowedExes, allowedCgroups Map) error {
+	if err := config.Update(uint32(0), policyConfig{Flags: p.flags()}, ebpf.UpdateAny); err != nil {
+		return errors.Wrap(err, "failed to write policy config")
+	}
+
+	allowed := uint8(1)
+	for _, path := range p.AllowedExecutables {
+		key, err := executableKey(path)
+		if err != nil {
+			return err
+		}
+		if err := allowedExes.Update(key, allowed, ebpf.UpdateAny); err != nil {
+			return errors.Wrapf(err, "failed to allow executable %s", path)
+		}
+		log.Printf("Allowed executable %s (inode %d, device %d)", path, key.Ino, key.Dev)
+	}
+
+	for _, path := range p.AllowedCgroups {
+		id, err := cgroupID(path)
+		if err != nil {
+			return err
+		}
+		if err := allowedCgroups.Update(id, allowed, ebpf.UpdateAny); err != nil {
+			return errors.Wrapf(err, "failed to allow cgroup %s", path)
+		}
+		log.Printf("Allowed cgroup %s (id %d)", path, id)
+	}
+
+	log.Printf("Applied policy: audit only %v, allow legacy %v, allow nftables %v",
+		p.AuditOnly, p.AllowLegacy, p.AllowNftables)
+	return nil
+}
@@ -0,0 +1,146 @@
+package blockpolicy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/pkg/errors"
+)
+
+var errUpdate = errors.New("map is full")
+
+// fakeMap records the entries written to it, or fails every update with err.
+type fakeMap struct {
+	entries map[interface{}]interface{}
+	err     error
+}
+
+func newFakeMap() *fakeMap {
+	return &fakeMap{entries: map[interface{}]interface{}{}}
+}
+
+func (m *fakeMap) Update(key, value interface{}, _ ebpf.MapUpdateFlags) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.entries[key] = value
+	return nil
+}
+
+func TestApply(t *testing.T) {
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "kubelet")
+	if err := os.WriteFile(exe, []byte{}, 0o755); err != nil {
+		t.Fatalf("failed to write executable: %v", err)
+	}
+	exeKey, err := executableKey(exe)
+	if err != nil {
+		t.Fatalf("failed to get executable key: %v", err)
+	}
+	cgroup := filepath.Join(dir, "kubepods.slice")
+	if err := os.Mkdir(cgroup, 0o755); err != nil {
+		t.Fatalf("failed to create cgroup: %v", err)
+	}
+	cgroupIno, err := cgroupID(cgroup)
+	if err != nil {
+		t.Fatalf("failed to get cgroup id: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		policy      Policy
+		failMap     string
+		wantErr     bool
+		wantConfig  policyConfig
+		wantExes    map[interface{}]interface{}
+		wantCgroups map[interface{}]interface{}
+	}{
+		{
+			name:        "default policy",
+			wantExes:    map[interface{}]interface{}{},
+			wantCgroups: map[interface{}]interface{}{},
+		},
+		{
+			name:        "audit mode with allow lists",
+			policy:      Policy{AuditOnly: true, AllowedExecutables: []string{exe}, AllowedCgroups: []string{cgroup}},
+			wantConfig:  policyConfig{Flags: policyAuditOnly},
+			wantExes:    map[interface{}]interface{}{exeKey: uint8(1)},
+			wantCgroups: map[interface{}]interface{}{cgroupIno: uint8(1)},
+		},
+		{
+			name:    "missing executable",
+			policy:  Policy{AllowedExecutables: []string{filepath.Join(dir, "missing")}},
+			wantErr: true,
+		},
+		{
+			name:    "missing cgroup",
+			policy:  Policy{AllowedCgroups: []string{filepath.Join(dir, "missing.slice")}},
+			wantErr: true,
+		},
+		{
+			name:    "config map update fails",
+			failMap: "config",
+			wantErr: true,
+		},
+		{
+			name:    "allowed executables map update fails",
+			policy:  Policy{AllowedExecutables: []string{exe}},
+			failMap: "exes",
+			wantErr: true,
+		},
+		{
+			name:    "allowed cgroups map update fails",
+			policy:  Policy{AllowedCgroups: []string{cgroup}},
+			failMap: "cgroups",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maps := map[string]*fakeMap{"config": newFakeMap(), "exes": newFakeMap(), "cgroups": newFakeMap()}
+			if tt.failMap != "" {
+				maps[tt.failMap].err = errUpdate
+			}
+			err := tt.policy.Apply(maps["config"], maps["exes"], maps["cgroups"])
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				if tt.failMap != "" && !errors.Is(err, errUpdate) {
+					t.Errorf("expected the map update error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to apply policy: %v", err)
+			}
+			wantConfig := map[interface{}]interface{}{uint32(0): tt.wantConfig}
+			if !reflect.DeepEqual(wantConfig, maps["config"].entries) {
+				t.Errorf("expected config %+v, got %+v", wantConfig, maps["config"].entries)
+			}
+			if !reflect.DeepEqual(tt.wantExes, maps["exes"].entries) {
+				t.Errorf("expected allowed executables %+v, got %+v", tt.wantExes, maps["exes"].entries)
+			}
+			if !reflect.DeepEqual(tt.wantCgroups, maps["cgroups"].entries) {
+				t.Errorf("expected allowed cgroups %+v, got %+v", tt.wantCgroups, maps["cgroups"].entries)
+			}
+		})
+	}
+}
+
+func TestCgroupIDIsRelativeToTheMount(t *testing.T) {
+	want, err := cgroupID(CgroupMountPath)
+	if err != nil {
+		t.Skipf("no cgroup mount: %v", err)
+	}
+	got, err := cgroupID("")
+	if err != nil {
+		t.Fatalf("failed to get cgroup id: %v", err)
+	}
+	if got != want {
+		t.Errorf("expected cgroup id %d, got %d", want, got)
+	}
+}
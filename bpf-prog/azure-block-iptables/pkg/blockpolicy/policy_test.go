@@ -0,0 +1,74 @@
+package blockpolicy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadPolicy(t *testing.T) {
+	tooMany := `["/a"`
+	for i := 0; i < maxAllowListEntries; i++ {
+		tooMany += fmt.Sprintf(`, "/b%d"`, i)
+	}
+	tooMany += "]"
+
+	tests := []struct {
+		name      string
+		policy    string
+		wantErr   bool
+		wantFlags uint32
+	}{
+		{
+			name:   "empty policy blocks everything",
+			policy: `{}`,
+		},
+		{
+			name:      "audit mode",
+			policy:    `{"auditOnly": true}`,
+			wantFlags: policyAuditOnly,
+		},
+		{
+			name:      "allow legacy and nftables",
+			policy:    `{"allowLegacy": true, "allowNftables": true}`,
+			wantFlags: policyAllowLegacy | policyAllowNft,
+		},
+		{
+			name:    "malformed policy",
+			policy:  `{"auditOnly": `,
+			wantErr: true,
+		},
+		{
+			name:    "too many allowed executables",
+			policy:  `{"allowedExecutables": ` + tooMany + `}`,
+			wantErr: true,
+		},
+		{
+			name:    "too many allowed cgroups",
+			policy:  `{"allowedCgroups": ` + tooMany + `}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "policy.json")
+			if err := os.WriteFile(path, []byte(tt.policy), 0o600); err != nil {
+				t.Fatalf("failed to write policy: %v", err)
+			}
+			policy, err := LoadPolicy(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got policy %+v", policy)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to load policy: %v", err)
+			}
+			if flags := policy.flags(); flags != tt.wantFlags {
+				t.Errorf("expected flags %b, got %b", tt.wantFlags, flags)
+			}
+		})
+	}
+}
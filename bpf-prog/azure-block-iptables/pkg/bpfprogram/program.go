@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"syscall"
 
+	"github.com/Azure/azure-container-networking/bpf-prog/azure-block-iptables/pkg/blockpolicy"
 	blockservice "github.com/Azure/azure-container-networking/bpf-prog/azure-block-iptables/pkg/blockservice"
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
@@ -21,6 +22,8 @@ const (
 	BPFMapPinPath = "/sys/fs/bpf/azure-block-iptables"
 	// EventCounterMapName is the name used for pinning the event counter map
 	EventCounterMapName = "iptables_block_event_counter"
+	// EventsMapName is the name used for pinning the ring buffer of block events
+	EventsMapName = "iptables_block_events"
 	// IptablesLegacyBlockProgramName is the name used for pinning the legacy iptables block program
 	IptablesLegacyBlockProgramName = "iptables_legacy_block"
 	// IptablesNftablesBlockProgramName is the name used for pinning the nftables block program
@@ -36,11 +39,17 @@ type Program struct {
 	objs     *blockservice.BlockIptablesObjects
 	links    []link.Link
 	attached bool
+	policy   *blockpolicy.Policy
 }
 
-// NewProgram creates a new BPF program manager instance.
+// NewProgram creates a new BPF program manager instance with the default policy.
 func NewProgram() Attacher {
-	return &Program{}
+	return &Program{policy: &blockpolicy.Policy{}}
+}
+
+// NewProgramWithPolicy creates a new BPF program manager instance which enforces the policy.
+func NewProgramWithPolicy(policy *blockpolicy.Policy) Attacher {
+	return &Program{policy: policy}
 }
 
 // CreatePinPath ensures the BPF map pin directory exists.
@@ -80,6 +89,18 @@ func (p *Program) unpinEventCounterMap() error {
 	return nil
 }
 
+// unpinEventsMap unpins the events ring buffer from the filesystem
+func (p *Program) unpinEventsMap() error {
+	pinPath := filepath.Join(BPFMapPinPath, EventsMapName)
+
+	if err := os.Remove(pinPath); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to remove pinned map %s", pinPath)
+	}
+
+	log.Printf("Events map unpinned from %s", pinPath)
+	return nil
+}
+
 // unpinLinks unpins the links to BPF programs from the filesystem
 func (p *Program) unpinLinks() error {
 	var errs []error
@@ -170,6 +191,13 @@ func (p *Program) Attach() error {
 	}
 	p.objs = objs
 
+	// Write the policy before the programs are attached
+	if err = p.policy.Apply(objs.IptablesBlockConfig, objs.IptablesBlockAllowedExes, objs.IptablesBlockAllowedCgroups); err != nil {
+		p.objs.Close()
+		p.objs = nil
+		return errors.Wrap(err, "failed to apply policy")
+	}
+
 	// Pin the event counter map to filesystem
 	if err = p.pinEventCounterMap(); err != nil {
 		return errors.Wrap(err, "failed to pin event counter map")
@@ -253,6 +281,11 @@ func (p *Program) cleanupPinnedResources() error {
 		log.Printf("Warning: failed to unpin event counter map: %v", err)
 	}
 
+	// Try to unpin the events map
+	if err := p.unpinEventsMap(); err != nil {
+		log.Printf("Warning: failed to unpin events map: %v", err)
+	}
+
 	log.Println("Pinned resources cleanup completed")
 	return nil
 }
@@ -1,92 +1,89 @@
 package main
 
 import (
-	"bytes"
-	"net"
-	"os/exec"
-
-	"github.com/Azure/azure-container-networking/bpf-prog/ipv6-hp-bpf/pkg/egress"
-	"github.com/Azure/azure-container-networking/bpf-prog/ipv6-hp-bpf/pkg/ingress"
-	"github.com/vishvananda/netlink"
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/Azure/azure-container-networking/bpf-prog/ipv6-hp-bpf/pkg/manager"
 	"github.com/cilium/ebpf/rlimit"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 )
 
+// Version is populated by make during build.
+var version string
+
+var (
+	interfaces        = flag.String("interfaces", "", "Comma separated names of the interfaces to attach the programs to. If empty, the interfaces of the default IPv6 routes are used")
+	pinPath           = flag.String("pin-path", "/sys/fs/bpf/ipv6-hp-bpf", "Directory on a bpf filesystem to pin the programs and maps in")
+	reconcileInterval = flag.Duration("reconcile-interval", 30*time.Second, "How often to check that the programs are attached, in addition to when interfaces change")
+	metricsAddress    = flag.String("metrics-address", ":9095", "Address to serve prometheus metrics on, disabled if empty")
+	uninstall         = flag.Bool("uninstall", false, "Detach the programs and remove the pinned programs and maps, then exit")
+)
+
 var logger *zap.Logger
 
 func main() {
+	flag.Parse()
+
 	// Set up logger
 	config := zap.NewProductionConfig()
 	config.OutputPaths = []string{"stdout", "/var/log/azure-ipv6-hp-bpf.log"}
 	logger, _ = config.Build()
+	logger.Info("Starting ipv6-hp-bpf", zap.String("version", version))
 
 	// Remove resource limits for kernels <5.11.
 	if err := rlimit.RemoveMemlock(); err != nil {
 		logger.Error("Removing memlock", zap.Error(err))
-		return
+		os.Exit(1)
 	}
 
-	// Check 'nft -n list tables ip6' to see if table exists
-	cmd := exec.Command("nft", "-n", "list", "tables", "ip6")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		logger.Error("error running 'nft -n list tables ip6'", zap.Error(err), zap.String("output", string(output)))
-		return
+	cfg := manager.Config{
+		PinPath:           *pinPath,
+		ReconcileInterval: *reconcileInterval,
 	}
-
-	// if azureSLBProbe table exists, delete it
-	if bytes.Contains(output, []byte("azureSLBProbe")) {
-		cmd := exec.Command("nft", "delete", "table", "ip6", "azureSLBProbe")
-		err = cmd.Run()
-		if err != nil {
-			logger.Error("failed to run 'nft delete table ip6 azureSLBProbe'", zap.Error(err))
-			return
-		}
+	if *interfaces != "" {
+		cfg.Interfaces = strings.Split(*interfaces, ",")
 	}
+	m := manager.New(cfg, manager.NewLoader(cfg.PinPath, logger), manager.NewTC(), manager.NewLinks(), manager.NewNFTables(), logger)
 
-	ifname := "eth0"
-	iface, err := net.InterfaceByName(ifname)
-	if err != nil {
-		logger.Error("Getting interface", zap.String("interface", ifname), zap.Error(err))
-	}
-	logger.Info("Interface has index", zap.String("interface", ifname), zap.Int("index", iface.Index))
-
-	// Create a qdisc filter for traffic on the interface.
-	fq := &netlink.GenericQdisc{
-		QdiscAttrs: netlink.QdiscAttrs{
-			LinkIndex: iface.Index,
-			Handle:    netlink.MakeHandle(0xffff, 0),
-			Parent:    netlink.HANDLE_CLSACT,
-		},
-		QdiscType: "clsact",
-	}
-	if err := netlink.QdiscReplace(fq); err != nil {
-		logger.Error("failed setting egress qdisc", zap.Error(err))
+	if *uninstall {
+		if err := m.Uninstall(); err != nil {
+			logger.Error("Failed to uninstall", zap.Error(err))
+			os.Exit(1)
+		}
+		logger.Info("Uninstalled")
 		return
 	}
 
-	// Load the compiled eBPF ELF and load it into the kernel.
-	// Set up ingress and egress filters to attach to eth0 clsact qdisc
-	var objsEgress egress.EgressObjects
-	defer objsEgress.Close()
-	if err := egress.LoadEgressObjects(&objsEgress, nil); err != nil {
-		logger.Error("Failed to load eBPF egress objects", zap.Error(err))
-	}
-	if err := egress.SetupEgressFilter(iface.Index, &objsEgress, logger); err != nil {
-		logger.Error("Setting up egress filter", zap.Error(err))
-	} else {
-		logger.Info("Successfully set egress filter on", zap.String("interface", ifname))
+	if *metricsAddress != "" {
+		prometheus.MustRegister(m.Collectors()...)
+		go serveMetrics(*metricsAddress)
 	}
 
-	var objsIngress ingress.IngressObjects
-	if err := ingress.LoadIngressObjects(&objsIngress, nil); err != nil {
-		logger.Error("Loading eBPF ingress objects", zap.Error(err))
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	err := m.Run(ctx)
+	stop()
+	if err != nil {
+		logger.Error("Manager failed", zap.Error(err))
+		os.Exit(1)
 	}
-	defer objsIngress.Close()
-	if err := ingress.SetupIngressFilter(iface.Index, &objsIngress, logger); err != nil {
-		logger.Error("Setting up ingress filter", zap.Error(err))
-	} else {
-		logger.Info("Successfully set ingress filter on", zap.String("interface", ifname))
+}
+
+func serveMetrics(address string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	server := &http.Server{Addr: address, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	logger.Info("Serving metrics", zap.String("address", address))
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("Metrics server failed", zap.Error(err))
 	}
 }
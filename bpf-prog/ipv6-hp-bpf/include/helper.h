@@ -4,6 +4,18 @@
 #define L4_HDR_OFF (ETH_HLEN + sizeof(struct ipv6hdr))
 #define BPF_F_PSEUDO_HDR (1ULL << 4)
 
+// Keys of the per-cpu stats map of each program
+#define STAT_REWRITTEN 0
+#define STAT_ERRORS 1
+#define STAT_MAX 2
+
+static __always_inline void count_stat(void *stats, __u32 key)
+{
+    __u64 *value = bpf_map_lookup_elem(stats, &key);
+    if (value)
+        (*value)++;
+}
+
 static __always_inline bool compare_ipv6_addr(const struct in6_addr *addr1, const struct in6_addr *addr2)
 {
 #pragma unroll
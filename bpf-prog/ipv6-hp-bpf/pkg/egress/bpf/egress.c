@@ -12,6 +12,16 @@
 #include <stdbool.h>
 #include "../../../include/helper.h"
 
+// Counts the rewritten packets and the rewrite failures. Pinned by name so the counts survive restarts.
+struct
+{
+    __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+    __uint(max_entries, STAT_MAX);
+    __type(key, __u32);
+    __type(value, __u64);
+    __uint(pinning, LIBBPF_PIN_BY_NAME);
+} egress_stats SEC(".maps");
+
 SEC("classifier")
 int gua_to_linklocal(struct __sk_buff *skb)
 {
@@ -59,6 +69,7 @@ int gua_to_linklocal(struct __sk_buff *skb)
         if (ret != 0)
         {
             bpf_printk("bpf_skb_store_bytes failed to store new destination address with error code %d.\n", ret);
+            count_stat(&egress_stats, STAT_ERRORS);
             return TC_ACT_SHOT;
         }
 
@@ -72,8 +83,11 @@ int gua_to_linklocal(struct __sk_buff *skb)
         if (ret < 0)
         {
             bpf_printk("csum_l4_replace failed to update checksum: %d", ret);
+            count_stat(&egress_stats, STAT_ERRORS);
             return TC_ACT_SHOT;
         }
+
+        count_stat(&egress_stats, STAT_REWRITTEN);
     }
 
     return TC_ACT_UNSPEC;
@@ -1,57 +1,10 @@
 package ingress
 
-import (
-	"syscall"
-
-	"github.com/vishvananda/netlink"
-	"go.uber.org/zap"
+const (
+	// ProgramName is the name of the TC program which rewrites the source of health probes to global unicast
+	ProgramName = "linklocal_to_gua"
+	// StatsMapName is the name of the per-cpu map counting the rewritten packets and the rewrite failures
+	StatsMapName = "ingress_stats"
+	// FilterName is the name of the TC filter the program is attached with
+	FilterName = "ipv6_hp_ingress"
 )
-
-// SetupIngressFilter sets up the ingress filter
-func SetupIngressFilter(ifaceIndex int, objs *IngressObjects, logger *zap.Logger) error {
-	link, err := netlink.LinkByIndex(ifaceIndex)
-	if err != nil {
-		logger.Error("Failed to get link", zap.Error(err))
-		return err
-	}
-
-	// Get the list of filters on the link
-	filters, err := netlink.FilterList(link, netlink.HANDLE_MIN_INGRESS)
-	if err != nil {
-		logger.Error("Failed to get filter list", zap.Error(err))
-		return err
-	}
-
-	// Check if egress filter exists and delete it. Filter is identified by its name.
-	// this is to avoid duplicate filters after restarting the daemonsetS
-	for _, filter := range filters {
-		if filter, ok := filter.(*netlink.BpfFilter); ok && filter.Name == "ipv6_hp_ingress" {
-			if err := netlink.FilterDel(filter); err != nil {
-				logger.Error("Failed to delete filter", zap.Error(err))
-				return err
-			}
-			break
-		}
-	}
-
-	ingressFilter := &netlink.BpfFilter{
-		FilterAttrs: netlink.FilterAttrs{
-			LinkIndex: ifaceIndex,
-			Parent:    netlink.HANDLE_MIN_INGRESS,
-			Protocol:  syscall.ETH_P_ALL,
-			Priority:  1,
-		},
-		Fd:           objs.LinklocalToGua.FD(),
-		Name:         "ipv6_hp_ingress",
-		DirectAction: true,
-	}
-
-	if err := netlink.FilterReplace(ingressFilter); err != nil {
-		logger.Error("failed setting ingress filter", zap.Error(err))
-		return err
-	} else {
-		logger.Info("Successfully set ingress filter on", zap.Int("ifaceIndex", ifaceIndex))
-	}
-
-	return nil
-}
@@ -0,0 +1,247 @@
+// Package manager keeps the ipv6 health probe programs attached to the links health probes are received on.
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+var errNotLoaded = errors.New("programs not loaded")
+
+// directions are the hooks the programs are attached to, in order
+var directions = []Direction{Egress, Ingress}
+
+// Config of the Manager
+type Config struct {
+	// Interfaces are the names of the links to attach the programs to. If empty, the links of the default ipv6 routes are used.
+	Interfaces []string
+	// PinPath is the directory of the pinned programs and maps, on a bpf filesystem
+	PinPath string
+	// ReconcileInterval is how often the filters are checked, in addition to when links change
+	ReconcileInterval time.Duration
+}
+
+// Manager loads the programs once, and attaches them to the links again whenever their filters are missing,
+// for example after a link is recreated. The filters and pins are left in place when the Manager stops,
+// so that the traffic is still rewritten while it restarts.
+type Manager struct {
+	cfg    Config
+	loader Loader
+	tc     TC
+	links  Links
+	nft    NFTables
+	logger *zap.Logger
+
+	mu       sync.Mutex
+	programs map[Direction]Program
+
+	attaches        *prometheus.CounterVec
+	attached        *prometheus.GaugeVec
+	reconcileErrors prometheus.Counter
+}
+
+// New returns a Manager of the programs of loader
+func New(cfg Config, loader Loader, tc TC, links Links, nft NFTables, logger *zap.Logger) *Manager {
+	return &Manager{
+		cfg:    cfg,
+		loader: loader,
+		tc:     tc,
+		links:  links,
+		nft:    nft,
+		logger: logger,
+		attaches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ipv6_hp_bpf_attach_total",
+			Help: "Number of times a program was attached to a link, by link and direction.",
+		}, []string{"interface", "direction"}),
+		attached: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "ipv6_hp_bpf_attached",
+			Help: "Whether both programs are attached to the link, as of the last reconcile.",
+		}, []string{"interface"}),
+		reconcileErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "ipv6_hp_bpf_reconcile_errors_total",
+			Help: "Number of reconciles which failed to attach the programs or to remove the legacy nft rules.",
+		}),
+	}
+}
+
+// Load loads the programs of both directions
+func (m *Manager) Load() error {
+	programs := map[Direction]Program{}
+	for _, dir := range directions {
+		p, err := m.loader.Load(dir)
+		if err != nil {
+			for _, loaded := range programs {
+				loaded.Close()
+			}
+			return fmt.Errorf("failed to load %s program: %w", dir, err)
+		}
+		programs[dir] = p
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.programs = programs
+	return nil
+}
+
+// Close closes the loaded programs. They stay attached and pinned.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var errs []error
+	for _, p := range m.programs {
+		errs = append(errs, p.Close())
+	}
+	m.programs = nil
+	return errors.Join(errs...)
+}
+
+// Reconcile attaches the programs to the links they are not attached to, and removes the legacy nft rules
+// once the programs are attached to all links
+func (m *Manager) Reconcile() error {
+	err := m.reconcile()
+	if err != nil {
+		m.reconcileErrors.Inc()
+	}
+	return err
+}
+
+func (m *Manager) reconcile() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.programs == nil {
+		return errNotLoaded
+	}
+
+	links, err := m.links.Discover(m.cfg.Interfaces)
+	if err != nil {
+		return fmt.Errorf("failed to discover links: %w", err)
+	}
+
+	m.attached.Reset()
+	var errs []error
+	for _, link := range links {
+		linkAttached := true
+		for _, dir := range directions {
+			if err := m.ensureAttached(link, dir); err != nil {
+				errs = append(errs, err)
+				linkAttached = false
+			}
+		}
+		if linkAttached {
+			m.attached.WithLabelValues(link.Name).Set(1)
+		} else {
+			m.attached.WithLabelValues(link.Name).Set(0)
+		}
+	}
+	if len(errs) > 0 {
+		// the legacy rules are kept until the programs replace them
+		return errors.Join(errs...)
+	}
+
+	return m.removeLegacyRules()
+}
+
+// ensureAttached attaches the program of dir to link, unless the filter of the program is already there
+func (m *Manager) ensureAttached(link Link, dir Direction) error {
+	program := m.programs[dir]
+	name := programSpecs[dir].filter
+
+	id, err := program.ID()
+	if err != nil {
+		return fmt.Errorf("failed to get %s program id: %w", dir, err)
+	}
+	attachedID, err := m.tc.FilterProgramID(link, dir, name)
+	if err != nil {
+		return err
+	}
+	if attachedID == id {
+		return nil
+	}
+
+	if err := m.tc.Attach(link, dir, name, program.FD()); err != nil {
+		return err
+	}
+	m.attaches.WithLabelValues(link.Name, string(dir)).Inc()
+	m.logger.Info("Attached program",
+		zap.String("interface", link.Name), zap.Int("index", link.Index), zap.String("direction", string(dir)),
+		zap.Int("previousProgramID", attachedID), zap.Int("programID", id))
+	return nil
+}
+
+// removeLegacyRules deletes the nft table of the rules superseded by the programs, if it exists
+func (m *Manager) removeLegacyRules() error {
+	exists, err := m.nft.HasTable("ip6", LegacyTable)
+	if err != nil {
+		return fmt.Errorf("failed to check for legacy nft table: %w", err)
+	}
+	if !exists {
+		return nil
+	}
+	if err := m.nft.DeleteTable("ip6", LegacyTable); err != nil {
+		return fmt.Errorf("failed to delete legacy nft table: %w", err)
+	}
+	m.logger.Info("Deleted legacy nft table", zap.String("table", LegacyTable))
+	return nil
+}
+
+// Run loads the programs and reconciles them whenever links change, and every ReconcileInterval, until ctx is done
+func (m *Manager) Run(ctx context.Context) error {
+	if err := m.Load(); err != nil {
+		return err
+	}
+	defer m.Close()
+
+	updates, err := m.links.Subscribe(ctx.Done())
+	if err != nil {
+		m.logger.Warn("Not watching links, relying on the reconcile interval", zap.Error(err))
+	}
+
+	ticker := time.NewTicker(m.cfg.ReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Reconcile(); err != nil {
+			m.logger.Error("Reconcile failed", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			m.logger.Info("Stopping, the programs stay attached")
+			return nil
+		case <-ticker.C:
+		case _, ok := <-updates:
+			if !ok {
+				// updates stops when ctx is done, or on a netlink error
+				updates = nil
+			}
+		}
+	}
+}
+
+// Uninstall detaches the programs from the links, and removes the pinned programs and maps
+func (m *Manager) Uninstall() error {
+	var errs []error
+	links, err := m.links.Discover(m.cfg.Interfaces)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to discover links: %w", err))
+	}
+	for _, link := range links {
+		for _, dir := range directions {
+			if err := m.tc.Detach(link, dir, programSpecs[dir].filter); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			m.logger.Info("Detached program", zap.String("interface", link.Name), zap.String("direction", string(dir)))
+		}
+	}
+	if err := os.RemoveAll(m.cfg.PinPath); err != nil {
+		errs = append(errs, fmt.Errorf("failed to remove pin path %s: %w", m.cfg.PinPath, err))
+	}
+	return errors.Join(errs...)
+}
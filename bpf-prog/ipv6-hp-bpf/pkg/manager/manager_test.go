@@ -0,0 +1,256 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// mockProgram is a loaded program whose fd is also its id
+type mockProgram struct {
+	fd     int
+	stats  Stats
+	closed bool
+}
+
+func (p *mockProgram) FD() int               { return p.fd }
+func (p *mockProgram) ID() (int, error)      { return p.fd, nil }
+func (p *mockProgram) Stats() (Stats, error) { return p.stats, nil }
+func (p *mockProgram) Close() error          { p.closed = true; return nil }
+
+type mockLoader struct {
+	programs map[Direction]*mockProgram
+}
+
+func (l *mockLoader) Load(dir Direction) (Program, error) {
+	return l.programs[dir], nil
+}
+
+type filterKey struct {
+	index int
+	dir   Direction
+	name  string
+}
+
+// mockTC keeps the program ids of the filters of each link
+type mockTC struct {
+	mu        sync.Mutex
+	filters   map[filterKey]int
+	attaches  int
+	attachErr error
+}
+
+func newMockTC() *mockTC {
+	return &mockTC{filters: map[filterKey]int{}}
+}
+
+func (t *mockTC) FilterProgramID(link Link, dir Direction, name string) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.filters[filterKey{link.Index, dir, name}], nil
+}
+
+func (t *mockTC) Attach(link Link, dir Direction, name string, fd int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.attachErr != nil {
+		return t.attachErr
+	}
+	t.attaches++
+	t.filters[filterKey{link.Index, dir, name}] = fd
+	return nil
+}
+
+func (t *mockTC) Detach(link Link, dir Direction, name string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.filters, filterKey{link.Index, dir, name})
+	return nil
+}
+
+func (t *mockTC) attachCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.attaches
+}
+
+type mockLinks struct {
+	mu      sync.Mutex
+	links   []Link
+	updates chan struct{}
+}
+
+func (l *mockLinks) Discover(_ []string) ([]Link, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.links, nil
+}
+
+func (l *mockLinks) Subscribe(_ <-chan struct{}) (<-chan struct{}, error) {
+	return l.updates, nil
+}
+
+// recreate gives the link a new index, as when it is deleted and added again
+func (l *mockLinks) recreate(name string, index int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := range l.links {
+		if l.links[i].Name == name {
+			l.links[i].Index = index
+		}
+	}
+}
+
+type mockNFTables struct {
+	mu      sync.Mutex
+	tables  map[string]bool
+	deleted int
+}
+
+func (n *mockNFTables) HasTable(family, name string) (bool, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.tables[family+" "+name], nil
+}
+
+func (n *mockNFTables) DeleteTable(family, name string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.tables, family+" "+name)
+	n.deleted++
+	return nil
+}
+
+type fixture struct {
+	loader *mockLoader
+	tc     *mockTC
+	links  *mockLinks
+	nft    *mockNFTables
+	m      *Manager
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	f := &fixture{
+		loader: &mockLoader{programs: map[Direction]*mockProgram{
+			Egress:  {fd: 10, stats: Stats{Rewritten: 5, Errors: 1}},
+			Ingress: {fd: 11, stats: Stats{Rewritten: 7}},
+		}},
+		tc:    newMockTC(),
+		links: &mockLinks{links: []Link{{Name: "eth0", Index: 2}}, updates: make(chan struct{}, 1)},
+		nft:   &mockNFTables{tables: map[string]bool{"ip6 " + LegacyTable: true}},
+	}
+	cfg := Config{PinPath: t.TempDir(), ReconcileInterval: time.Hour}
+	f.m = New(cfg, f.loader, f.tc, f.links, f.nft, zap.NewNop())
+	return f
+}
+
+func TestReconcileAttachesAndRemovesLegacyRules(t *testing.T) {
+	f := newFixture(t)
+	require.ErrorIs(t, f.m.Reconcile(), errNotLoaded)
+	require.NoError(t, f.m.Load())
+
+	require.NoError(t, f.m.Reconcile())
+	require.Equal(t, 10, f.tc.filters[filterKey{2, Egress, "ipv6_hp_egress"}])
+	require.Equal(t, 11, f.tc.filters[filterKey{2, Ingress, "ipv6_hp_ingress"}])
+	require.Equal(t, 1, f.nft.deleted, "the legacy table should be deleted once the programs are attached")
+	require.InDelta(t, 1, testutil.ToFloat64(f.m.attached.WithLabelValues("eth0")), 0)
+
+	// attached programs are left as they are
+	require.NoError(t, f.m.Reconcile())
+	require.Equal(t, 2, f.tc.attachCount())
+	require.Equal(t, 1, f.nft.deleted)
+}
+
+func TestReconcileReusesAttachedPrograms(t *testing.T) {
+	f := newFixture(t)
+	// the filters of the pinned programs are there from before a restart
+	f.tc.filters[filterKey{2, Egress, "ipv6_hp_egress"}] = 10
+	f.tc.filters[filterKey{2, Ingress, "ipv6_hp_ingress"}] = 11
+	require.NoError(t, f.m.Load())
+
+	require.NoError(t, f.m.Reconcile())
+	require.Equal(t, 0, f.tc.attachCount())
+}
+
+func TestReconcileReplacesOutdatedPrograms(t *testing.T) {
+	f := newFixture(t)
+	// the filters are of the programs of a previous version
+	f.tc.filters[filterKey{2, Egress, "ipv6_hp_egress"}] = 3
+	f.tc.filters[filterKey{2, Ingress, "ipv6_hp_ingress"}] = 4
+	require.NoError(t, f.m.Load())
+
+	require.NoError(t, f.m.Reconcile())
+	require.Equal(t, 2, f.tc.attachCount())
+	require.Equal(t, 10, f.tc.filters[filterKey{2, Egress, "ipv6_hp_egress"}])
+}
+
+func TestReconcileKeepsLegacyRulesOnAttachFailure(t *testing.T) {
+	f := newFixture(t)
+	f.tc.attachErr = errors.New("no such device")
+	require.NoError(t, f.m.Load())
+
+	require.Error(t, f.m.Reconcile())
+	require.Equal(t, 0, f.nft.deleted, "the legacy table should be kept until the programs are attached")
+	require.InDelta(t, 0, testutil.ToFloat64(f.m.attached.WithLabelValues("eth0")), 0)
+	require.InDelta(t, 1, testutil.ToFloat64(f.m.reconcileErrors), 0)
+}
+
+func TestRunReattachesRecreatedLinks(t *testing.T) {
+	f := newFixture(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- f.m.Run(ctx) }()
+
+	require.Eventually(t, func() bool { return f.tc.attachCount() == 2 }, time.Second, 10*time.Millisecond)
+
+	f.links.recreate("eth0", 7)
+	f.links.updates <- struct{}{}
+	require.Eventually(t, func() bool { return f.tc.attachCount() == 4 }, time.Second, 10*time.Millisecond)
+	f.tc.mu.Lock()
+	require.Equal(t, 10, f.tc.filters[filterKey{7, Egress, "ipv6_hp_egress"}])
+	f.tc.mu.Unlock()
+	require.InDelta(t, 2, testutil.ToFloat64(f.m.attaches.WithLabelValues("eth0", "egress")), 0)
+
+	cancel()
+	require.NoError(t, <-done)
+	require.True(t, f.loader.programs[Egress].closed)
+	f.tc.mu.Lock()
+	require.Len(t, f.tc.filters, 4, "the filters should stay attached when the manager stops")
+	f.tc.mu.Unlock()
+}
+
+func TestStatsCollector(t *testing.T) {
+	f := newFixture(t)
+	require.NoError(t, f.m.Load())
+
+	expected := `
+# HELP ipv6_hp_bpf_rewritten_packets_total Number of health probe packets whose address was rewritten, by direction. Kept across restarts.
+# TYPE ipv6_hp_bpf_rewritten_packets_total counter
+ipv6_hp_bpf_rewritten_packets_total{direction="egress"} 5
+ipv6_hp_bpf_rewritten_packets_total{direction="ingress"} 7
+`
+	require.NoError(t, testutil.CollectAndCompare(statsCollector{m: f.m}, strings.NewReader(expected), "ipv6_hp_bpf_rewritten_packets_total"))
+	require.Equal(t, 4, testutil.CollectAndCount(statsCollector{m: f.m}))
+}
+
+func TestUninstall(t *testing.T) {
+	f := newFixture(t)
+	require.NoError(t, f.m.Load())
+	require.NoError(t, f.m.Reconcile())
+	require.NoError(t, os.WriteFile(filepath.Join(f.m.cfg.PinPath, "ipv6_hp_egress"), nil, 0o600))
+
+	require.NoError(t, f.m.Uninstall())
+	require.Empty(t, f.tc.filters)
+	_, err := os.Stat(f.m.cfg.PinPath)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,48 @@
+package manager
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+var (
+	rewrittenDesc = prometheus.NewDesc("ipv6_hp_bpf_rewritten_packets_total",
+		"Number of health probe packets whose address was rewritten, by direction. Kept across restarts.",
+		[]string{"direction"}, nil)
+	rewriteErrorsDesc = prometheus.NewDesc("ipv6_hp_bpf_rewrite_errors_total",
+		"Number of health probe packets dropped because their address could not be rewritten, by direction. Kept across restarts.",
+		[]string{"direction"}, nil)
+)
+
+// statsCollector collects the counters of the stats maps of the programs when scraped
+type statsCollector struct {
+	m *Manager
+}
+
+func (c statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- rewrittenDesc
+	ch <- rewriteErrorsDesc
+}
+
+func (c statsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+	for _, dir := range directions {
+		program, ok := c.m.programs[dir]
+		if !ok {
+			continue
+		}
+		stats, err := program.Stats()
+		if err != nil {
+			c.m.logger.Error("Failed to read program stats", zap.String("direction", string(dir)), zap.Error(err))
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(rewrittenDesc, prometheus.CounterValue, float64(stats.Rewritten), string(dir))
+		ch <- prometheus.MustNewConstMetric(rewriteErrorsDesc, prometheus.CounterValue, float64(stats.Errors), string(dir))
+	}
+}
+
+// Collectors returns the metrics of the Manager and the packet counters of its programs
+func (m *Manager) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{statsCollector{m: m}, m.attaches, m.attached, m.reconcileErrors}
+}
@@ -0,0 +1,45 @@
+package manager
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+)
+
+// LegacyTable is the nft table of the health probe rules the programs supersede
+const LegacyTable = "azureSLBProbe"
+
+// NFTables removes the legacy nft rules
+type NFTables interface {
+	HasTable(family, name string) (bool, error)
+	DeleteTable(family, name string) error
+}
+
+// execNFTables runs the nft command
+type execNFTables struct{}
+
+// NewNFTables returns the NFTables which runs the nft command
+func NewNFTables() NFTables {
+	return execNFTables{}
+}
+
+func (execNFTables) HasTable(family, name string) (bool, error) {
+	output, err := exec.Command("nft", "-n", "list", "tables", family).CombinedOutput()
+	if err != nil {
+		return false, fmt.Errorf("failed to run 'nft -n list tables %s': %w (output: %s)", family, err, string(output))
+	}
+	for _, line := range bytes.Split(output, []byte("\n")) {
+		if string(bytes.TrimSpace(line)) == "table "+family+" "+name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (execNFTables) DeleteTable(family, name string) error {
+	output, err := exec.Command("nft", "delete", "table", family, name).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to run 'nft delete table %s %s': %w (output: %s)", family, name, err, string(output))
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/azure-container-networking/bpf-prog/ipv6-hp-bpf/pkg/egress"
+	"github.com/Azure/azure-container-networking/bpf-prog/ipv6-hp-bpf/pkg/ingress"
+	"github.com/cilium/ebpf"
+	"go.uber.org/zap"
+)
+
+// Direction is the TC hook a program is attached to
+type Direction string
+
+const (
+	Egress  Direction = "egress"
+	Ingress Direction = "ingress"
+)
+
+var (
+	errUnknownDirection = errors.New("unknown direction")
+	errNoProgramID      = errors.New("program id not available")
+)
+
+// Keys of the stats maps, matching STAT_* of helper.h
+const (
+	statRewritten uint32 = iota
+	statErrors
+	statMax // number of entries of the stats maps
+)
+
+// Stats are the counters of a program, summed over all cpus
+type Stats struct {
+	Rewritten uint64
+	Errors    uint64
+}
+
+// Program is a loaded TC program
+type Program interface {
+	FD() int
+	// ID is the kernel id of the program, which is reported by the filters it is attached with
+	ID() (int, error)
+	Stats() (Stats, error)
+	Close() error
+}
+
+// Loader loads the program of a direction
+type Loader interface {
+	Load(dir Direction) (Program, error)
+}
+
+// programSpec describes the bindings generated for the program of a direction
+type programSpec struct {
+	load    func() (*ebpf.CollectionSpec, error)
+	program string
+	stats   string
+	filter  string
+}
+
+var programSpecs = map[Direction]programSpec{
+	Egress:  {load: egress.LoadEgress, program: egress.ProgramName, stats: egress.StatsMapName, filter: egress.FilterName},
+	Ingress: {load: ingress.LoadIngress, program: ingress.ProgramName, stats: ingress.StatsMapName, filter: ingress.FilterName},
+}
+
+// bpfProgram is a Program loaded into the kernel
+type bpfProgram struct {
+	prog  *ebpf.Program
+	stats *ebpf.Map
+}
+
+func (p *bpfProgram) FD() int {
+	return p.prog.FD()
+}
+
+func (p *bpfProgram) ID() (int, error) {
+	info, err := p.prog.Info()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get program info: %w", err)
+	}
+	id, ok := info.ID()
+	if !ok {
+		return 0, errNoProgramID
+	}
+	return int(id), nil
+}
+
+func (p *bpfProgram) Stats() (Stats, error) {
+	var stats Stats
+	for key, stat := range map[uint32]*uint64{statRewritten: &stats.Rewritten, statErrors: &stats.Errors} {
+		var perCPU []uint64
+		if err := p.stats.Lookup(key, &perCPU); err != nil {
+			return Stats{}, fmt.Errorf("failed to lookup stat %d: %w", key, err)
+		}
+		for _, v := range perCPU {
+			*stat += v
+		}
+	}
+	return stats, nil
+}
+
+func (p *bpfProgram) Close() error {
+	return errors.Join(p.prog.Close(), p.stats.Close())
+}
+
+// bpfLoader loads the programs from their bindings and pins them, with their stats maps, in pinPath
+type bpfLoader struct {
+	pinPath string
+	logger  *zap.Logger
+}
+
+// NewLoader returns a Loader which pins the programs and their maps in pinPath, on a bpf filesystem.
+// A pinned program is reused as long as it is the same as the one of the bindings, so that a restart does not
+// replace the attached programs, and the stats maps are reused so that the counters survive restarts.
+// The programs are pinned with the tag of their spec in their name, as the tag the kernel reports is not
+// computed the same way on all kernels.
+func NewLoader(pinPath string, logger *zap.Logger) Loader {
+	return &bpfLoader{pinPath: pinPath, logger: logger}
+}
+
+func (l *bpfLoader) Load(dir Direction) (Program, error) {
+	ps, ok := programSpecs[dir]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", dir, errUnknownDirection)
+	}
+	if err := os.MkdirAll(l.pinPath, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create pin path %s: %w", l.pinPath, err)
+	}
+
+	spec, err := ps.load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s spec: %w", dir, err)
+	}
+	progSpec, ok := spec.Programs[ps.program]
+	if !ok {
+		return nil, fmt.Errorf("program %s not found in %s spec", ps.program, dir)
+	}
+	tag, err := progSpec.Tag()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute tag of %s: %w", ps.program, err)
+	}
+
+	progPath := filepath.Join(l.pinPath, ps.filter+"_"+tag)
+	if p := l.loadPinned(progPath, ps.stats); p != nil {
+		l.logger.Info("Reusing pinned program", zap.String("direction", string(dir)), zap.String("path", progPath))
+		return p, nil
+	}
+
+	coll, err := l.newCollection(spec, ps.stats)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s objects: %w", dir, err)
+	}
+	p := &bpfProgram{prog: coll.DetachProgram(ps.program), stats: coll.DetachMap(ps.stats)}
+	coll.Close()
+
+	if err := p.prog.Pin(progPath); err != nil {
+		p.Close()
+		return nil, fmt.Errorf("failed to pin program %s: %w", progPath, err)
+	}
+	l.logger.Info("Loaded and pinned program", zap.String("direction", string(dir)), zap.String("path", progPath))
+
+	// remove the pins of previous versions, the filters they are attached with keep them loaded until they are replaced
+	previous, err := filepath.Glob(filepath.Join(l.pinPath, ps.filter+"_*"))
+	if err != nil {
+		return p, nil
+	}
+	for _, path := range previous {
+		if path == progPath {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			l.logger.Warn("Failed to remove pinned program of a previous version", zap.String("path", path), zap.Error(err))
+		}
+	}
+	return p, nil
+}
+
+// loadPinned returns the program pinned at progPath with its stats map, or nil if there is none
+func (l *bpfLoader) loadPinned(progPath, statsName string) *bpfProgram {
+	prog, err := ebpf.LoadPinnedProgram(progPath, nil)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			l.logger.Warn("Failed to load pinned program", zap.String("path", progPath), zap.Error(err))
+		}
+		return nil
+	}
+	stats, err := ebpf.LoadPinnedMap(filepath.Join(l.pinPath, statsName), nil)
+	if err != nil {
+		prog.Close()
+		return nil
+	}
+	return &bpfProgram{prog: prog, stats: stats}
+}
+
+// newCollection loads the spec, reusing the maps pinned by name. A pinned map which is incompatible with the spec
+// is left from a previous version and is replaced.
+func (l *bpfLoader) newCollection(spec *ebpf.CollectionSpec, statsName string) (*ebpf.Collection, error) {
+	opts := ebpf.CollectionOptions{Maps: ebpf.MapOptions{PinPath: l.pinPath}}
+	coll, err := ebpf.NewCollectionWithOptions(spec, opts)
+	if err == nil || !errors.Is(err, ebpf.ErrMapIncompatible) {
+		return coll, err
+	}
+	statsPath := filepath.Join(l.pinPath, statsName)
+	l.logger.Warn("Replacing incompatible pinned map", zap.String("path", statsPath))
+	if err := os.Remove(statsPath); err != nil {
+		return nil, fmt.Errorf("failed to remove pinned map %s: %w", statsPath, err)
+	}
+	return ebpf.NewCollectionWithOptions(spec, opts)
+}
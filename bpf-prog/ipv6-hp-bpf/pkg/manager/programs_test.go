@@ -0,0 +1,32 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBindings checks that the generated bindings have the programs and stats maps the manager uses
+func TestBindings(t *testing.T) {
+	for dir, ps := range programSpecs {
+		t.Run(string(dir), func(t *testing.T) {
+			spec, err := ps.load()
+			require.NoError(t, err)
+
+			prog, ok := spec.Programs[ps.program]
+			require.True(t, ok, "program %s not found", ps.program)
+			require.Equal(t, ebpf.SchedCLS, prog.Type)
+			_, err = prog.Tag()
+			require.NoError(t, err)
+
+			stats, ok := spec.Maps[ps.stats]
+			require.True(t, ok, "map %s not found", ps.stats)
+			require.Equal(t, ebpf.PerCPUArray, stats.Type)
+			require.Equal(t, uint32(4), stats.KeySize)
+			require.Equal(t, uint32(8), stats.ValueSize)
+			require.Equal(t, statMax, stats.MaxEntries)
+			require.Equal(t, ebpf.PinByName, stats.Pinning, "the stats map must be pinned to survive restarts")
+		})
+	}
+}
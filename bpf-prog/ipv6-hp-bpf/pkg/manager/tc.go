@@ -0,0 +1,243 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+)
+
+// filterHandle is the handle of the filters of the programs. It is fixed so that a filter is replaced in place,
+// without a window where the traffic is not rewritten.
+const filterHandle uint32 = 0x6b70
+
+// Link is a network interface the programs are attached to
+type Link struct {
+	Name  string
+	Index int
+}
+
+// TC attaches the programs to the TC hooks of links
+type TC interface {
+	// FilterProgramID returns the id of the program of the filter named name on the hook of the link, or 0 if there is none
+	FilterProgramID(link Link, dir Direction, name string) (int, error)
+	// Attach attaches the program fd with a filter named name on the hook of the link, replacing any filter of that name
+	Attach(link Link, dir Direction, name string, fd int) error
+	// Detach deletes the filters named name on the hook of the link
+	Detach(link Link, dir Direction, name string) error
+}
+
+// Links finds the links to attach the programs to
+type Links interface {
+	// Discover returns the links with the names, or the links of the default IPv6 routes if there are no names
+	Discover(names []string) ([]Link, error)
+	// Subscribe notifies when links are added, changed or removed, until done is closed
+	Subscribe(done <-chan struct{}) (<-chan struct{}, error)
+}
+
+// fallbackLink is the link the programs are attached to when there is no default ipv6 route
+const fallbackLink = "eth0"
+
+var errNoLinks = errors.New("no links found")
+
+func hookParent(dir Direction) uint32 {
+	if dir == Ingress {
+		return netlink.HANDLE_MIN_INGRESS
+	}
+	return netlink.HANDLE_MIN_EGRESS
+}
+
+// netlinkTC is the TC of the host network namespace
+type netlinkTC struct{}
+
+// NewTC returns the TC of the host network namespace
+func NewTC() TC {
+	return netlinkTC{}
+}
+
+// filters returns the bpf filters named name on the hook of the link
+func (netlinkTC) filters(link Link, dir Direction, name string) ([]*netlink.BpfFilter, error) {
+	l, err := netlink.LinkByIndex(link.Index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get link %s: %w", link.Name, err)
+	}
+	filters, err := netlink.FilterList(l, hookParent(dir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s filters of %s: %w", dir, link.Name, err)
+	}
+	var named []*netlink.BpfFilter
+	for _, filter := range filters {
+		if bpfFilter, ok := filter.(*netlink.BpfFilter); ok && bpfFilter.Name == name {
+			named = append(named, bpfFilter)
+		}
+	}
+	return named, nil
+}
+
+func (t netlinkTC) FilterProgramID(link Link, dir Direction, name string) (int, error) {
+	filters, err := t.filters(link, dir, name)
+	if err != nil {
+		return 0, err
+	}
+	for _, filter := range filters {
+		if filter.Handle == filterHandle {
+			return filter.Id, nil
+		}
+	}
+	return 0, nil
+}
+
+// ensureClsact adds the clsact qdisc to the link if it does not have one yet, an existing one (of cilium) is left as is
+func ensureClsact(link Link) error {
+	l, err := netlink.LinkByIndex(link.Index)
+	if err != nil {
+		return fmt.Errorf("failed to get link %s: %w", link.Name, err)
+	}
+	qdiscs, err := netlink.QdiscList(l)
+	if err != nil {
+		return fmt.Errorf("failed to list qdiscs of %s: %w", link.Name, err)
+	}
+	for _, qdisc := range qdiscs {
+		if qdisc.Type() == "clsact" {
+			return nil
+		}
+	}
+	clsact := &netlink.GenericQdisc{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: link.Index,
+			Handle:    netlink.MakeHandle(0xffff, 0),
+			Parent:    netlink.HANDLE_CLSACT,
+		},
+		QdiscType: "clsact",
+	}
+	if err := netlink.QdiscAdd(clsact); err != nil {
+		return fmt.Errorf("failed to add clsact qdisc to %s: %w", link.Name, err)
+	}
+	return nil
+}
+
+func (t netlinkTC) Attach(link Link, dir Direction, name string, fd int) error {
+	if err := ensureClsact(link); err != nil {
+		return err
+	}
+
+	filter := &netlink.BpfFilter{
+		FilterAttrs: netlink.FilterAttrs{
+			LinkIndex: link.Index,
+			Parent:    hookParent(dir),
+			Handle:    filterHandle,
+			Protocol:  syscall.ETH_P_ALL,
+			Priority:  1,
+		},
+		Fd:           fd,
+		Name:         name,
+		DirectAction: true,
+	}
+	if err := netlink.FilterReplace(filter); err != nil {
+		return fmt.Errorf("failed to set %s filter on %s: %w", dir, link.Name, err)
+	}
+
+	// delete the filters of the same name added by previous versions, which had no fixed handle
+	filters, err := t.filters(link, dir, name)
+	if err != nil {
+		return err
+	}
+	for _, old := range filters {
+		if old.Handle == filterHandle {
+			continue
+		}
+		if err := netlink.FilterDel(old); err != nil {
+			return fmt.Errorf("failed to delete previous %s filter of %s: %w", dir, link.Name, err)
+		}
+	}
+	return nil
+}
+
+func (t netlinkTC) Detach(link Link, dir Direction, name string) error {
+	filters, err := t.filters(link, dir, name)
+	if err != nil {
+		return err
+	}
+	for _, filter := range filters {
+		if err := netlink.FilterDel(filter); err != nil {
+			return fmt.Errorf("failed to delete %s filter of %s: %w", dir, link.Name, err)
+		}
+	}
+	return nil
+}
+
+// netlinkLinks are the links of the host network namespace
+type netlinkLinks struct{}
+
+// NewLinks returns the Links of the host network namespace
+func NewLinks() Links {
+	return netlinkLinks{}
+}
+
+func (netlinkLinks) Discover(names []string) ([]Link, error) {
+	if len(names) > 0 {
+		links := make([]Link, 0, len(names))
+		for _, name := range names {
+			iface, err := net.InterfaceByName(name)
+			if err != nil {
+				// the link may be being recreated, it is retried on the next reconcile
+				continue
+			}
+			links = append(links, Link{Name: iface.Name, Index: iface.Index})
+		}
+		if len(links) == 0 {
+			return nil, fmt.Errorf("%v: %w", names, errNoLinks)
+		}
+		return links, nil
+	}
+
+	// health probes are received on the links of the default routes
+	routes, err := netlink.RouteList(nil, netlink.FAMILY_V6)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ipv6 routes: %w", err)
+	}
+	seen := map[int]bool{}
+	var links []Link
+	for i := range routes {
+		route := &routes[i]
+		if route.Dst != nil || route.LinkIndex == 0 || seen[route.LinkIndex] {
+			continue
+		}
+		l, err := netlink.LinkByIndex(route.LinkIndex)
+		if err != nil {
+			continue
+		}
+		seen[route.LinkIndex] = true
+		links = append(links, Link{Name: l.Attrs().Name, Index: route.LinkIndex})
+	}
+	if len(links) == 0 {
+		// the programs were only ever attached to eth0 before discovery
+		iface, err := net.InterfaceByName(fallbackLink)
+		if err != nil {
+			return nil, fmt.Errorf("no default ipv6 route and no %s: %w", fallbackLink, errNoLinks)
+		}
+		links = append(links, Link{Name: iface.Name, Index: iface.Index})
+	}
+	return links, nil
+}
+
+func (netlinkLinks) Subscribe(done <-chan struct{}) (<-chan struct{}, error) {
+	updates := make(chan netlink.LinkUpdate)
+	if err := netlink.LinkSubscribe(updates, done); err != nil {
+		return nil, fmt.Errorf("failed to subscribe to link updates: %w", err)
+	}
+	notify := make(chan struct{}, 1)
+	go func() {
+		defer close(notify)
+		// updates is closed when done is
+		for range updates {
+			select {
+			case notify <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return notify, nil
+}
@@ -59,10 +59,13 @@ type IPResultInfo struct {
 	hostGateway          string
 	nicType              cns.NICType
 	macAddress           string
+	interfaceName        string
 	skipDefaultRoutes    bool
+	sourceBasedRouting   bool
 	routes               []cns.Route
 	pnpID                string
 	endpointPolicies     []policy.Policy
+	mtu                  int
 }
 
 func getIPConfigGatewayAddress(podIP string, ipConfig cns.IPConfiguration) string {
@@ -190,6 +193,10 @@ func (invoker *CNSIPAMInvoker) Add(addConfig IPAMAddConfig) (IPAMAddResult, erro
 		} else {
 			logger.Info("Failed to get IP address from CNS",
 				zap.Any("response", response))
+			// the error ends up in the events of the pod, so explain the failure there when CNS diagnosed it
+			if diagnosis := cnscli.IPAllocationDiagnosis(err); diagnosis != nil {
+				return IPAMAddResult{}, errors.Wrapf(err, "Failed to get IP address from CNS (%s)", diagnosis)
+			}
 			return IPAMAddResult{}, errors.Wrap(err, "Failed to get IP address from CNS")
 		}
 	}
@@ -212,10 +219,13 @@ func (invoker *CNSIPAMInvoker) Add(addConfig IPAMAddConfig) (IPAMAddResult, erro
 			hostGateway:          response.PodIPInfo[i].HostPrimaryIPInfo.Gateway,
 			nicType:              response.PodIPInfo[i].NICType,
 			macAddress:           response.PodIPInfo[i].MacAddress,
+			interfaceName:        response.PodIPInfo[i].InterfaceName,
 			skipDefaultRoutes:    response.PodIPInfo[i].SkipDefaultRoutes,
+			sourceBasedRouting:   response.PodIPInfo[i].SourceBasedRouting,
 			routes:               response.PodIPInfo[i].Routes,
 			pnpID:                response.PodIPInfo[i].PnPID,
 			endpointPolicies:     response.PodIPInfo[i].EndpointPolicies,
+			mtu:                  response.PodIPInfo[i].MTU,
 		}
 
 		logger.Info("Received info for pod",
@@ -228,7 +238,8 @@ func (invoker *CNSIPAMInvoker) Add(addConfig IPAMAddConfig) (IPAMAddResult, erro
 		switch info.nicType {
 		case cns.NodeNetworkInterfaceFrontendNIC:
 			// only handling single v4 PodIPInfo for NodeNetworkInterfaceFrontendNIC and AccelnetNIC at the moment, will have to update once v6 gets added
-			if !info.skipDefaultRoutes {
+			// the routes of a NIC with source based routing stay out of the main table
+			if !info.skipDefaultRoutes && !info.sourceBasedRouting {
 				numInterfacesWithDefaultRoutes++
 			}
 
@@ -526,6 +537,7 @@ func configureDefaultAddResult(info *IPResultInfo, addConfig *IPAMAddConfig, add
 			Routes:            resRoute,
 			HostSubnetPrefix:  *hostIPNet,
 			EndpointPolicies:  info.endpointPolicies,
+			MTU:               info.mtu,
 		}
 	}
 
@@ -559,6 +571,7 @@ func configureSecondaryAddResult(info *IPResultInfo, addResult *IPAMAddResult, p
 	}
 
 	addResult.interfaceInfo[key] = network.InterfaceInfo{
+		Name: info.interfaceName,
 		IPConfigs: []*network.IPConfig{
 			{
 				Address: net.IPNet{
@@ -568,10 +581,12 @@ func configureSecondaryAddResult(info *IPResultInfo, addResult *IPAMAddResult, p
 				Gateway: net.ParseIP(info.ncGatewayIPAddress),
 			},
 		},
-		Routes:            routes,
-		NICType:           info.nicType,
-		MacAddress:        macAddress,
-		SkipDefaultRoutes: info.skipDefaultRoutes,
+		Routes:             routes,
+		NICType:            info.nicType,
+		MacAddress:         macAddress,
+		SkipDefaultRoutes:  info.skipDefaultRoutes,
+		SourceBasedRouting: info.sourceBasedRouting,
+		MTU:                info.mtu,
 	}
 
 	// Append IPv6 IPConfig if NetworkContainerIPv6Config was populated
@@ -1286,6 +1286,106 @@ func TestRequestIPAPIsFail(t *testing.T) {
 	}
 }
 
+func TestRequestIPsFailureExplained(t *testing.T) {
+	require := require.New(t) //nolint further usage of require without passing t
+
+	invoker := &CNSIPAMInvoker{
+		podName:      testPodInfo.PodName,
+		podNamespace: testPodInfo.PodNamespace,
+		cnsClient: &MockCNSClient{
+			require: require,
+			requestIPs: requestIPsHandler{
+				ipconfigArgument: getTestIPConfigsRequest(),
+				err: &cnscli.IPAllocationError{
+					Message: "not enough IPs available",
+					Diagnosis: &cns.IPAllocationDiagnosis{
+						FailedStep: cns.IPAllocationStepAssignIPs,
+						Pool:       cns.IPPoolDiagnosis{Assigned: 16, PendingProgramming: 16, RequestedIPCount: 32, ScaleUpInFlight: true},
+						NetworkContainers: []cns.NCVersionDiagnosis{
+							{NetworkContainerID: "nc1", ExpectedVersion: 2, ProgrammedVersion: 1, PendingProgrammingIPs: 16},
+						},
+					},
+				},
+			},
+		},
+	}
+	_, err := invoker.Add(IPAMAddConfig{
+		nwCfg:   &cni.NetworkConfig{},
+		args:    &cniSkel.CmdArgs{ContainerID: "testcontainerid", Netns: "testnetns", IfName: "testifname"},
+		options: map[string]interface{}{},
+	})
+	require.EqualError(err, "Failed to get IP address from CNS (failed at AssignIPs; "+
+		"pool: 0 available, 16 assigned, 0 pending release, 16 pending programming, 32 requested, scale up in flight; "+
+		"NC nc1: version 2, programmed 1, 16 IPs pending programming): not enough IPs available")
+}
+
+func TestCNSIPAMInvoker_AddMultipleDelegatedNICs(t *testing.T) {
+	require := require.New(t) //nolint further usage of require without passing t
+
+	invoker := &CNSIPAMInvoker{
+		podName:      testPodInfo.PodName,
+		podNamespace: testPodInfo.PodNamespace,
+		ipamMode:     util.Overlay,
+		cnsClient: &MockCNSClient{
+			require: require,
+			requestIPs: requestIPsHandler{
+				ipconfigArgument: getTestIPConfigsRequest(),
+				result: &cns.IPConfigsResponse{
+					PodIPInfo: []cns.PodIpInfo{
+						{
+							PodIPConfig: cns.IPSubnet{IPAddress: "10.0.1.10", PrefixLength: 24},
+							NetworkContainerPrimaryIPConfig: cns.IPConfiguration{
+								IPSubnet:         cns.IPSubnet{IPAddress: "10.0.1.0", PrefixLength: 24},
+								GatewayIPAddress: "10.0.0.1",
+							},
+							HostPrimaryIPInfo: cns.HostIPInfo{Gateway: "10.0.0.1", PrimaryIP: "10.0.0.1", Subnet: "10.0.0.0/24"},
+							NICType:           cns.InfraNIC,
+							SkipDefaultRoutes: true,
+						},
+						{
+							PodIPConfig: cns.IPSubnet{IPAddress: "20.240.1.242", PrefixLength: 24},
+							NICType:     cns.NodeNetworkInterfaceFrontendNIC,
+							MacAddress:  "12:34:56:78:9a:bc",
+							Routes: []cns.Route{
+								{IPAddress: "169.254.1.1/32"},
+								{IPAddress: "0.0.0.0/0", GatewayIPAddress: "169.254.1.1"},
+							},
+						},
+						{
+							PodIPConfig:        cns.IPSubnet{IPAddress: "30.240.1.242", PrefixLength: 24},
+							NICType:            cns.NodeNetworkInterfaceFrontendNIC,
+							MacAddress:         "bc:9a:78:56:34:12",
+							InterfaceName:      "net1",
+							SourceBasedRouting: true,
+							Routes: []cns.Route{
+								{IPAddress: "169.254.1.1/32"},
+								{IPAddress: "0.0.0.0/0", GatewayIPAddress: "169.254.1.1"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	ipamAddResult, err := invoker.Add(IPAMAddConfig{
+		nwCfg:   &cni.NetworkConfig{},
+		args:    &cniSkel.CmdArgs{ContainerID: "testcontainerid", Netns: "testnetns", IfName: "testifname"},
+		options: map[string]interface{}{},
+	})
+	require.NoError(err)
+
+	primary := ipamAddResult.interfaceInfo["12:34:56:78:9a:bc"]
+	require.Empty(primary.Name)
+	require.False(primary.SourceBasedRouting)
+
+	// the second delegated NIC keeps its routes in a table of its own and is named per its PodNetworkInstance
+	secondary := ipamAddResult.interfaceInfo["bc:9a:78:56:34:12"]
+	require.Equal("net1", secondary.Name)
+	require.True(secondary.SourceBasedRouting)
+	require.False(secondary.SkipDefaultRoutes)
+	require.Len(secondary.Routes, 2)
+}
+
 func TestCNSIPAMInvoker_Delete(t *testing.T) {
 	require := require.New(t) //nolint further usage of require without passing t
 	type fields struct {
@@ -3138,3 +3238,22 @@ func TestConfigureSecondaryAddResult_IPv6(t *testing.T) {
 		})
 	}
 }
+
+// Test configureSecondaryAddResult carries the MTU computed by CNS onto the interface info.
+func TestConfigureSecondaryAddResult_MTU(t *testing.T) {
+	macAddress := "12:34:56:78:9a:bc"
+	info := IPResultInfo{
+		podIPAddress:       "10.0.1.10",
+		ncGatewayIPAddress: "10.0.0.1",
+		macAddress:         macAddress,
+		nicType:            cns.NodeNetworkInterfaceFrontendNIC,
+		mtu:                9000,
+	}
+	addResult := &IPAMAddResult{
+		interfaceInfo: make(map[string]network.InterfaceInfo),
+	}
+
+	err := configureSecondaryAddResult(&info, addResult, &cns.IPSubnet{IPAddress: "10.0.1.10", PrefixLength: 24}, macAddress)
+	require.NoError(t, err)
+	require.Equal(t, 9000, addResult.interfaceInfo[macAddress].MTU)
+}
@@ -6,6 +6,7 @@ package network
 import (
 	"context"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"net"
 	"regexp"
@@ -26,6 +27,7 @@ import (
 	"github.com/Azure/azure-container-networking/netlink"
 	"github.com/Azure/azure-container-networking/network"
 	"github.com/Azure/azure-container-networking/network/policy"
+	"github.com/Azure/azure-container-networking/ovsctl"
 	"github.com/Azure/azure-container-networking/platform"
 	nnscontracts "github.com/Azure/azure-container-networking/proto/nodenetworkservice/3.302.0.744"
 	"github.com/Azure/azure-container-networking/store"
@@ -154,7 +156,7 @@ func NewPlugin(name string,
 
 	nl := netlink.NewNetlink()
 	// Setup network manager.
-	nm, err := network.NewNetworkManager(nl, platform.NewExecClient(logger), &netio.NetIO{}, network.NewNamespaceClient(), iptables.NewClient(), dhcp.New(logger))
+	nm, err := network.NewNetworkManager(nl, platform.NewExecClient(logger), &netio.NetIO{}, network.NewNamespaceClient(), iptables.NewClient(), dhcp.New(logger), ovsctl.NewOvsctl())
 	if err != nil {
 		return nil, err
 	}
@@ -742,6 +744,16 @@ type createEpInfoOpt struct {
 	endpointIndex int
 }
 
+// endpointMTU returns the MTU of the pod interface of ifInfo. An MTU set explicitly in the conflist takes precedence
+// over the one computed by CNS, but only for infra interfaces: the conflist describes the infra network, and
+// delegated and backend NICs keep the MTU of their own NIC.
+func endpointMTU(nwCfg *cni.NetworkConfig, ifInfo *network.InterfaceInfo) int {
+	if nwCfg.MTU > 0 && ifInfo.NICType.IsInfraOrLegacy() {
+		return nwCfg.MTU
+	}
+	return ifInfo.MTU
+}
+
 func (plugin *NetPlugin) createEpInfo(opt *createEpInfoOpt) (*network.EndpointInfo, error) { // you can modify to pass in whatever else you need
 	// ensure we can find the master interface
 	opt.ifInfo.HostSubnetPrefix.IP = opt.ifInfo.HostSubnetPrefix.IP.Mask(opt.ifInfo.HostSubnetPrefix.Mask)
@@ -833,16 +845,19 @@ func (plugin *NetPlugin) createEpInfo(opt *createEpInfoOpt) (*network.EndpointIn
 		NATInfo:            opt.natInfo,
 		NICType:            opt.ifInfo.NICType,
 		SkipDefaultRoutes:  opt.ifInfo.SkipDefaultRoutes,
+		SourceBasedRouting: opt.ifInfo.SourceBasedRouting,
 		Routes:             opt.ifInfo.Routes,
 		// added the following for delegated vm nic
 		IPAddresses: addresses,
 		MacAddress:  opt.ifInfo.MacAddress,
+		PodIfName:   opt.ifInfo.Name,
 		// the following is used for creating an external interface if we can't find an existing network
 		HostSubnetPrefix:         opt.ifInfo.HostSubnetPrefix.String(),
 		PnPID:                    opt.ifInfo.PnPID,
 		NetworkContainerID:       opt.ifInfo.NetworkContainerID,
 		AllowInboundFromHostToNC: opt.ifInfo.AllowHostToNCCommunication,
 		AllowInboundFromNCToHost: opt.ifInfo.AllowNCToHostCommunication,
+		MTU:                      endpointMTU(opt.nwCfg, opt.ifInfo),
 	}
 	if opt.ifInfo.NCResponse != nil {
 		endpointInfo.PrimaryInterfaceIP = opt.ifInfo.NCResponse.PrimaryInterfaceIdentifier
@@ -1105,7 +1120,7 @@ func (plugin *NetPlugin) Delete(args *cniSkel.CmdArgs) error {
 	if plugin.ipamInvoker == nil {
 		switch nwCfg.IPAM.Type {
 		case network.AzureCNS:
-			cnsClient, cnsErr := cnscli.New("", defaultRequestTimeout)
+			cnsClient, cnsErr := cnscli.New(nwCfg.CNSUrl, defaultRequestTimeout)
 			if cnsErr != nil {
 				logger.Error("failed to create cns client", zap.Error(cnsErr))
 				return errors.Wrap(cnsErr, "failed to create cns client")
@@ -1195,16 +1210,24 @@ func (plugin *NetPlugin) Delete(args *cniSkel.CmdArgs) error {
 	logger.Info("Deleting the endpoints", zap.Any("endpointInfos", epInfos))
 	// populate ep infos here in loop if necessary
 	// delete endpoints
+	// every endpoint is attempted even if another one fails, so a broken interface of a multi-NIC pod
+	// doesn't leave the others behind; the retried DEL only has the failed ones left to do
+	var deleteErrs []error
 	for _, epInfo := range epInfos {
 		// in stateless, network id is not populated in epInfo, but in stateful cni, it is (nw id is used in stateful)
-		if err = plugin.nm.DeleteEndpoint(epInfo.NetworkID, epInfo.EndpointID, epInfo, nwCfg.Mode); err != nil {
-			// An error will not be returned if the endpoint is not found
-			// return a retriable error so the container runtime will retry this DEL later
-			// the implementation of this function returns nil if the endpoint doens't exist, so
-			// we don't have to check that here
-			return plugin.RetriableError(fmt.Errorf("failed to delete endpoint: %w", err))
+		if epErr := plugin.nm.DeleteEndpoint(epInfo.NetworkID, epInfo.EndpointID, epInfo, nwCfg.Mode); epErr != nil {
+			logger.Error("Failed to delete endpoint", zap.String("endpointID", epInfo.EndpointID), zap.Error(epErr))
+			deleteErrs = append(deleteErrs, fmt.Errorf("endpoint %s: %w", epInfo.EndpointID, epErr))
 		}
 	}
+	if len(deleteErrs) > 0 {
+		// An error will not be returned if the endpoint is not found
+		// return a retriable error so the container runtime will retry this DEL later
+		// the implementation of this function returns nil if the endpoint doens't exist, so
+		// we don't have to check that here
+		err = stderrors.Join(deleteErrs...)
+		return plugin.RetriableError(fmt.Errorf("failed to delete endpoint: %w", err))
+	}
 	logger.Info("Deleting the endpoints from the ipam")
 	// delete endpoint state in cns and in statefile
 	for _, epInfo := range epInfos {
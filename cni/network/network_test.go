@@ -1790,6 +1790,30 @@ func TestFindMasterInterface(t *testing.T) {
 	}
 }
 
+func TestEndpointMTU(t *testing.T) {
+	tests := []struct {
+		name     string
+		nwCfgMTU int
+		nicType  cns.NICType
+		cnsMTU   int
+		want     int
+	}{
+		{name: "infra NIC uses the cns mtu", nicType: cns.InfraNIC, cnsMTU: 1450, want: 1450},
+		{name: "conflist mtu overrides the cns mtu of infra NICs", nwCfgMTU: 9000, nicType: cns.InfraNIC, cnsMTU: 1450, want: 9000},
+		{name: "conflist mtu overrides the cns mtu of legacy NICs", nwCfgMTU: 9000, cnsMTU: 1450, want: 9000},
+		{name: "conflist mtu does not apply to delegated NICs", nwCfgMTU: 9000, nicType: cns.NodeNetworkInterfaceFrontendNIC, cnsMTU: 1500, want: 1500},
+		{name: "conflist mtu does not apply to accelnet NICs", nwCfgMTU: 9000, nicType: cns.NodeNetworkInterfaceAccelnetFrontendNIC, want: 0},
+		{name: "conflist mtu does not apply to backend NICs", nwCfgMTU: 9000, nicType: cns.BackendNIC, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nwCfg := &cni.NetworkConfig{MTU: tt.nwCfgMTU}
+			ifInfo := &acnnetwork.InterfaceInfo{NICType: tt.nicType, MTU: tt.cnsMTU}
+			require.Equal(t, tt.want, endpointMTU(nwCfg, ifInfo))
+		})
+	}
+}
+
 func TestValidateArgs(t *testing.T) {
 	p, _ := cni.NewPlugin("name", "0.3.0")
 	plugin := &NetPlugin{
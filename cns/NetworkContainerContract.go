@@ -6,6 +6,7 @@ import (
 	"net"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-container-networking/cns/types"
 	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig/api/v1alpha"
@@ -41,6 +42,8 @@ const (
 	PathDebugIPAddresses                     = "/debug/ipaddresses"
 	PathDebugPodContext                      = "/debug/podcontext"
 	PathDebugRestData                        = "/debug/restdata"
+	PathDebugIPHistory                       = "/debug/iphistory"
+	PathDebugIPAllocationFailure             = "/debug/ipallocationfailure"
 	NumberOfCPUCores                         = NumberOfCPUCoresPath
 	NMAgentSupportedAPIs                     = NmAgentSupportedApisPath
 	EndpointAPI                              = EndpointPath
@@ -158,6 +161,30 @@ func (req *CreateNetworkContainerRequest) Validate() error {
 	if req.IPConfiguration.GatewayIPAddress != "" && !isValidIP(req.IPConfiguration.GatewayIPAddress) {
 		return errors.Wrapf(ErrInvalidIP, "GatewayIPAddress %s is not a valid ip address", req.IPConfiguration.GatewayIPAddress)
 	}
+	if err := req.IPv6Configuration.validateIPv6(); err != nil {
+		return errors.Wrap(err, "IPv6Configuration is invalid")
+	}
+	return nil
+}
+
+// validateIPv6 checks the IPv6 configuration of a dual-stack NC, which CNI adds to the pod next to the IPv4 one.
+// An empty configuration is valid, the NC is then IPv4 only.
+func (ipConfig *IPConfiguration) validateIPv6() error {
+	if ipConfig.IPSubnet.IPAddress == "" {
+		if ipConfig.GatewayIPAddress != "" {
+			return errors.Wrapf(ErrInvalidIP, "GatewayIPAddress %s is set without an IPSubnet", ipConfig.GatewayIPAddress)
+		}
+		return nil
+	}
+	if !isValidIPv6(ipConfig.IPSubnet.IPAddress) {
+		return errors.Wrapf(ErrInvalidIP, "IPSubnet %s is not a valid ipv6 address", ipConfig.IPSubnet.IPAddress)
+	}
+	if int(ipConfig.IPSubnet.PrefixLength) > net.IPv6len*8 {
+		return errors.Wrapf(ErrInvalidIP, "IPSubnet prefix length %d is longer than %d", ipConfig.IPSubnet.PrefixLength, net.IPv6len*8)
+	}
+	if ipConfig.GatewayIPAddress != "" && !isValidIPv6(ipConfig.GatewayIPAddress) {
+		return errors.Wrapf(ErrInvalidIP, "GatewayIPAddress %s is not a valid ipv6 address", ipConfig.GatewayIPAddress)
+	}
 	return nil
 }
 
@@ -170,6 +197,14 @@ func isValidIP(ipStr string) bool {
 	return ip != nil
 }
 
+func isValidIPv6(ipStr string) bool {
+	ip, _, err := net.ParseCIDR(ipStr)
+	if err != nil {
+		ip = net.ParseIP(ipStr)
+	}
+	return ip != nil && ip.To4() == nil
+}
+
 // CreateNetworkContainerRequest implements fmt.Stringer for logging
 func (req *CreateNetworkContainerRequest) String() string {
 	return fmt.Sprintf("CreateNetworkContainerRequest"+
@@ -532,6 +567,9 @@ type PodIpInfo struct {
 	SharedNIC bool `json:"sharedNic,omitempty"`
 	// SkipDefaultRoutes is true if default routes should not be added on interface
 	SkipDefaultRoutes bool
+	// SourceBasedRouting is true if the routes of the interface go in a routing table of its own,
+	// used for traffic sourced from the interface's IPs, rather than in the main table.
+	SourceBasedRouting bool `json:"sourceBasedRouting,omitempty"`
 	// Routes to configure on interface
 	Routes []Route
 	// PnpId is set for backend interfaces, Pnp Id identifies VF. Plug and play id(pnp) is also called as PCI ID
@@ -544,6 +582,9 @@ type PodIpInfo struct {
 	AllowNCToHostCommunication bool
 	// NetworkContainerID is the ID of the network container to which this Pod IP belongs
 	NetworkContainerID string
+	// MTU is the effective MTU for the pod interface backed by this NC/NIC. Zero means CNS did not compute one
+	// and the CNI should keep its default.
+	MTU int `json:"mtu,omitempty"`
 }
 
 type HostIPInfo struct {
@@ -579,9 +620,77 @@ type IPConfigResponse struct {
 }
 
 // IPConfigsResponse is used in CNS IPAM mode to return a slice of IP configs as a response to CNI ADD
+// Diagnosis is only set when the request failed.
 type IPConfigsResponse struct {
-	PodIPInfo []PodIpInfo `json:"podIPInfo"`
-	Response  Response    `json:"response"`
+	PodIPInfo []PodIpInfo            `json:"podIPInfo"`
+	Response  Response               `json:"response"`
+	Diagnosis *IPAllocationDiagnosis `json:"diagnosis,omitempty"`
+}
+
+// IPAllocationStep is a step of an IPConfigsRequest, including the steps of the SWIFT v2 middleware.
+type IPAllocationStep string
+
+const (
+	IPAllocationStepValidateRequest     IPAllocationStep = "ValidateRequest"
+	IPAllocationStepBackendNIC          IPAllocationStep = "BackendNIC"
+	IPAllocationStepAssignIPs           IPAllocationStep = "AssignIPs"
+	IPAllocationStepUpdateEndpointState IPAllocationStep = "UpdateEndpointState"
+	IPAllocationStepSWIFTv2GetPod       IPAllocationStep = "SWIFTv2GetPod"
+	IPAllocationStepSWIFTv2GetMTPNC     IPAllocationStep = "SWIFTv2GetMTPNC"
+	IPAllocationStepSWIFTv2UpdateReq    IPAllocationStep = "SWIFTv2UpdateIPConfigsRequest"
+	IPAllocationStepSWIFTv2IPConfig     IPAllocationStep = "SWIFTv2GetIPConfig"
+	IPAllocationStepSWIFTv2Routes       IPAllocationStep = "SWIFTv2SetRoutes"
+)
+
+// IPAllocationDiagnosis explains why CNS failed an IPConfigsRequest, from the state of CNS when it failed.
+type IPAllocationDiagnosis struct {
+	PodName           string
+	PodNamespace      string
+	Time              time.Time
+	ReturnCode        types.ResponseCode
+	Message           string
+	FailedStep        IPAllocationStep
+	Pool              IPPoolDiagnosis
+	NetworkContainers []NCVersionDiagnosis
+}
+
+// IPPoolDiagnosis counts the IPs of the pool by state.
+// ScaleUpInFlight is true while CNS has requested more IPs than the NCs have.
+type IPPoolDiagnosis struct {
+	Available          int
+	Assigned           int
+	PendingRelease     int
+	PendingProgramming int
+	RequestedIPCount   int64
+	ScaleUpInFlight    bool
+}
+
+// NCVersionDiagnosis compares the NC version CNS expects with the version NMAgent has programmed, -1 if unknown.
+type NCVersionDiagnosis struct {
+	NetworkContainerID    string
+	ExpectedVersion       int64
+	ProgrammedVersion     int64
+	PendingProgrammingIPs int
+}
+
+// String summarizes the diagnosis in one line, to be shown in the events of the pod.
+func (d *IPAllocationDiagnosis) String() string {
+	var sb strings.Builder
+	if d.FailedStep != "" {
+		fmt.Fprintf(&sb, "failed at %s; ", d.FailedStep)
+	}
+	fmt.Fprintf(&sb, "pool: %d available, %d assigned, %d pending release, %d pending programming, %d requested",
+		d.Pool.Available, d.Pool.Assigned, d.Pool.PendingRelease, d.Pool.PendingProgramming, d.Pool.RequestedIPCount)
+	if d.Pool.ScaleUpInFlight {
+		sb.WriteString(", scale up in flight")
+	}
+	for _, nc := range d.NetworkContainers {
+		fmt.Fprintf(&sb, "; NC %s: version %d, programmed %d", nc.NetworkContainerID, nc.ExpectedVersion, nc.ProgrammedVersion)
+		if nc.PendingProgrammingIPs > 0 {
+			fmt.Fprintf(&sb, ", %d IPs pending programming", nc.PendingProgrammingIPs)
+		}
+	}
+	return sb.String()
 }
 
 // ClaimResourceInfoRequest is the request for the RequestClaimResourceInfo API. ClaimUID identifies
@@ -626,6 +735,44 @@ type GetPodContextResponse struct {
 	Response   Response
 }
 
+// IPAssignmentRecord is one assignment of an IP to a Pod in the CNS IP history.
+// End is zero while the IP is still assigned to the Pod.
+type IPAssignmentRecord struct {
+	IPAddress    string
+	PodName      string
+	PodNamespace string
+	ContainerID  string
+	InterfaceID  string
+	Start        time.Time
+	End          time.Time
+}
+
+// GetIPHistoryRequest is used in CNS Client debug mode to get the assignment history of an IP.
+// An empty IPAddress matches all IPs, and a zero From or To leaves that end of the time window open.
+type GetIPHistoryRequest struct {
+	IPAddress string
+	From      time.Time
+	To        time.Time
+}
+
+// GetIPHistoryResponse is used in CNS Client debug mode as a response to get the assignment history of an IP
+type GetIPHistoryResponse struct {
+	Records  []IPAssignmentRecord
+	Response Response
+}
+
+// GetIPAllocationFailureRequest is used in CNS Client debug mode to get why the last IPConfigsRequest of a Pod failed.
+type GetIPAllocationFailureRequest struct {
+	PodName      string
+	PodNamespace string
+}
+
+// GetIPAllocationFailureResponse is used in CNS Client debug mode as a response to get why the last IPConfigsRequest of a Pod failed.
+type GetIPAllocationFailureResponse struct {
+	Diagnosis *IPAllocationDiagnosis
+	Response  Response
+}
+
 // IPAddressState Only used in the GetIPConfig API to return IPs that match a filter
 type IPAddressState struct {
 	IPAddress string
@@ -135,6 +135,62 @@ func TestCreateNetworkContainerRequestValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid dual-stack",
+			req: CreateNetworkContainerRequest{
+				NetworkContainerid: "f47ac10b-58cc-0372-8567-0e02b2c3d479",
+				IPConfiguration: IPConfiguration{
+					IPSubnet:         IPSubnet{IPAddress: "10.0.0.5", PrefixLength: 24},
+					GatewayIPAddress: "10.0.0.1",
+				},
+				IPv6Configuration: IPConfiguration{
+					IPSubnet:         IPSubnet{IPAddress: "2001:db8::5", PrefixLength: 64},
+					GatewayIPAddress: "2001:db8::1",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "ipv6 configuration with an ipv4 address",
+			req: CreateNetworkContainerRequest{
+				NetworkContainerid: "f47ac10b-58cc-0372-8567-0e02b2c3d479",
+				IPv6Configuration: IPConfiguration{
+					IPSubnet: IPSubnet{IPAddress: "10.0.0.5", PrefixLength: 24},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "ipv6 configuration with an ipv4 gateway",
+			req: CreateNetworkContainerRequest{
+				NetworkContainerid: "f47ac10b-58cc-0372-8567-0e02b2c3d479",
+				IPv6Configuration: IPConfiguration{
+					IPSubnet:         IPSubnet{IPAddress: "2001:db8::5", PrefixLength: 64},
+					GatewayIPAddress: "10.0.0.1",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "ipv6 configuration with a prefix longer than 128",
+			req: CreateNetworkContainerRequest{
+				NetworkContainerid: "f47ac10b-58cc-0372-8567-0e02b2c3d479",
+				IPv6Configuration: IPConfiguration{
+					IPSubnet: IPSubnet{IPAddress: "2001:db8::5", PrefixLength: 129},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "ipv6 gateway without an ipv6 subnet",
+			req: CreateNetworkContainerRequest{
+				NetworkContainerid: "f47ac10b-58cc-0372-8567-0e02b2c3d479",
+				IPv6Configuration: IPConfiguration{
+					GatewayIPAddress: "2001:db8::1",
+				},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
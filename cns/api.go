@@ -303,6 +303,8 @@ type IpamPoolMonitorStateSnapshot struct {
 	MaximumFreeIps           int64
 	UpdatingIpsNotInUseCount int64
 	CachedNNC                v1alpha.NodeNetworkConfig
+	// LastScaleEvent is the most recent change to the requested IP count, nil if the pool has not scaled.
+	LastScaleEvent *v1alpha.ScaleEvent
 }
 
 // Response describes generic response from CNS.
@@ -374,6 +376,15 @@ type GetHomeAzResponse struct {
 	HomeAzResponse HomeAzResponse `json:"homeAzResponse"`
 }
 
+// HealthReportResponse is returned by the health report API. Response is embedded
+// so the payload stays compatible with clients expecting a plain Response.
+type HealthReportResponse struct {
+	Response
+	// NMAgentCircuitBreakers maps each NMAgent endpoint CNS has called to the state
+	// of its circuit breaker (closed, open or half-open).
+	NMAgentCircuitBreakers map[string]string `json:"NMAgentCircuitBreakers,omitempty"`
+}
+
 // Used by EndpointHandler API to update endpoint state.
 type EndpointRequest struct {
 	HnsEndpointID string `json:"hnsEndpointID"`
@@ -0,0 +1,190 @@
+// Package authz authorizes callers of the CNS REST API against a policy which maps
+// client identities to the API paths and methods they may call.
+package authz
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/pkg/errors"
+)
+
+// Permission allows calls to an API path.
+type Permission struct {
+	// Path is the API path, or a path prefix when it ends with "*".
+	// Versioned paths such as /v0.2/network/create are matched by their unversioned form.
+	Path string
+	// Methods are the allowed HTTP methods. Empty allows every method.
+	Methods []string `json:",omitempty"`
+}
+
+// Rule assigns a profile to the callers it matches.
+type Rule struct {
+	// Subjects match the common name or a DNS SAN of a client certificate, case-insensitively.
+	// A leading "*." matches any subdomain.
+	Subjects []string `json:",omitempty"`
+	// UIDs match the user of a caller on a local Unix socket, i.e. on CNISocketPath.
+	// Callers over TCP, such as the CNI plugin with its default CNS URL, are Anonymous and never match.
+	UIDs    []uint32 `json:",omitempty"`
+	Profile string
+}
+
+// Settings is the authorization policy of CNS.
+type Settings struct {
+	Enable bool
+	// AuditOnly logs calls that would be denied without rejecting them.
+	AuditOnly bool
+	// DefaultProfile applies to callers that match no rule, including anonymous ones.
+	// When empty, those callers are denied.
+	DefaultProfile string
+	// CNISocketPath, when set, is the path of a Unix socket serving the local API besides TCP.
+	// CNI plugins configured with the CNS URL unix://<CNISocketPath> are identified by their UID there,
+	// so that a rule can grant them the cni profile and DefaultProfile can deny anonymous callers.
+	CNISocketPath string `json:",omitempty"`
+	// Profiles are added to, or replace, the default profiles.
+	Profiles map[string][]Permission `json:",omitempty"`
+	// Rules are evaluated in order and the first match assigns the caller's profile.
+	Rules []Rule `json:",omitempty"`
+}
+
+// Authorizer enforces the authorization policy on the requests served by the CNS listener.
+type Authorizer struct {
+	profiles       map[string][]Permission
+	rules          []Rule
+	defaultProfile string
+	auditOnly      bool
+}
+
+// New validates the settings and returns an Authorizer enforcing them.
+func New(settings *Settings) (*Authorizer, error) {
+	profiles := DefaultProfiles()
+	for name, permissions := range settings.Profiles {
+		normalized := make([]Permission, len(permissions))
+		for i, p := range permissions {
+			if p.Path == "" {
+				return nil, errors.Errorf("profile %s has a permission without a path", name)
+			}
+			normalized[i] = Permission{Path: p.Path, Methods: make([]string, len(p.Methods))}
+			for j, m := range p.Methods {
+				normalized[i].Methods[j] = strings.ToUpper(m)
+			}
+		}
+		profiles[name] = normalized
+	}
+	for i, rule := range settings.Rules {
+		if _, ok := profiles[rule.Profile]; !ok {
+			return nil, errors.Errorf("rule %d references unknown profile %q", i, rule.Profile)
+		}
+		if len(rule.Subjects) == 0 && len(rule.UIDs) == 0 {
+			return nil, errors.Errorf("rule %d matches no caller", i)
+		}
+	}
+	if _, ok := profiles[settings.DefaultProfile]; settings.DefaultProfile != "" && !ok {
+		return nil, errors.Errorf("unknown default profile %q", settings.DefaultProfile)
+	}
+	return &Authorizer{
+		profiles:       profiles,
+		rules:          settings.Rules,
+		defaultProfile: settings.DefaultProfile,
+		auditOnly:      settings.AuditOnly,
+	}, nil
+}
+
+// Authorize returns the profile of the caller and whether it allows the method on the path.
+func (a *Authorizer) Authorize(id Identity, method, path string) (profile string, allowed bool) {
+	profile = a.profileFor(id)
+	if profile == "" {
+		return "", false
+	}
+	path = strings.TrimPrefix(path, cns.V2Prefix)
+	for _, p := range a.profiles[profile] {
+		if p.matches(method, path) {
+			return profile, true
+		}
+	}
+	return profile, false
+}
+
+func (a *Authorizer) profileFor(id Identity) string {
+	for _, rule := range a.rules {
+		if rule.matches(id) {
+			return rule.Profile
+		}
+	}
+	return a.defaultProfile
+}
+
+// Middleware rejects the requests the caller is not allowed to make with 403 Forbidden
+// and writes an audit log entry for each of them.
+func (a *Authorizer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := IdentityFromRequest(r)
+		profile, allowed := a.Authorize(id, r.Method, r.URL.Path)
+		if !allowed {
+			if a.auditOnly {
+				logger.Printf("[Azure CNS] authz audit: would deny %s %s from %s %s with profile %q", r.Method, r.URL.Path, r.RemoteAddr, id, profile)
+			} else {
+				logger.Errorf("[Azure CNS] authz: denied %s %s from %s %s with profile %q", r.Method, r.URL.Path, r.RemoteAddr, id, profile)
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ConnContext records the peer credentials of connections on a Unix socket.
+func (a *Authorizer) ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return ConnContext(ctx, c)
+}
+
+func (r *Rule) matches(id Identity) bool {
+	switch id.Kind {
+	case Certificate:
+		for _, subject := range r.Subjects {
+			for _, name := range id.Names {
+				if subjectMatches(subject, name) {
+					return true
+				}
+			}
+		}
+	case UnixPeer:
+		for _, uid := range r.UIDs {
+			if uid == id.UID {
+				return true
+			}
+		}
+	case Anonymous:
+	}
+	return false
+}
+
+func subjectMatches(pattern, name string) bool {
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok && strings.HasPrefix(suffix, ".") {
+		return len(name) > len(suffix) && strings.HasSuffix(strings.ToLower(name), strings.ToLower(suffix))
+	}
+	return strings.EqualFold(pattern, name)
+}
+
+func (p *Permission) matches(method, path string) bool {
+	if prefix, ok := strings.CutSuffix(p.Path, "*"); ok {
+		if !strings.HasPrefix(path, prefix) {
+			return false
+		}
+	} else if p.Path != path {
+		return false
+	}
+	if len(p.Methods) == 0 {
+		return true
+	}
+	for _, m := range p.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package authz
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSettings() *Settings {
+	return &Settings{
+		Enable:         true,
+		DefaultProfile: ProfileCNI,
+		Profiles: map[string][]Permission{
+			"ops": {{Path: cns.GetHealthReportPath, Methods: []string{"get"}}},
+		},
+		Rules: []Rule{
+			{Subjects: []string{"dnc.example.com"}, Profile: ProfileDNC},
+			{Subjects: []string{"*.debug.example.com"}, Profile: ProfileDebug},
+			{UIDs: []uint32{1000}, Profile: "ops"},
+		},
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	a, err := New(testSettings())
+	require.NoError(t, err)
+
+	dnc := Identity{Kind: Certificate, Names: []string{"DNC.example.com"}}
+	debug := Identity{Kind: Certificate, Names: []string{"cns", "node1.debug.example.com"}}
+	ops := Identity{Kind: UnixPeer, UID: 1000}
+	anonymous := Identity{Kind: Anonymous}
+
+	tests := []struct {
+		name        string
+		id          Identity
+		method      string
+		path        string
+		wantProfile string
+		wantAllowed bool
+	}{
+		{"dnc may publish", dnc, http.MethodPost, cns.PublishNetworkContainer, ProfileDNC, true},
+		{"dnc may use versioned paths", dnc, http.MethodPost, cns.V2Prefix + cns.CreateNetworkPath, ProfileDNC, true},
+		{"debug may read debug data", debug, http.MethodPost, cns.PathDebugIPAddresses, ProfileDebug, true},
+		{"debug may list NCs with GET", debug, http.MethodGet, cns.NetworkContainersURLPath, ProfileDebug, true},
+		{"debug may not refresh NCs with POST", debug, http.MethodPost, cns.NetworkContainersURLPath, ProfileDebug, false},
+		{"debug may not release IPs", debug, http.MethodPost, cns.ReleaseIPConfigs, ProfileDebug, false},
+		{"wildcard does not match the bare domain", Identity{Kind: Certificate, Names: []string{"debug.example.com"}}, http.MethodPost, cns.PublishNetworkContainer, ProfileCNI, false},
+		{"unix peer gets its profile", ops, http.MethodGet, cns.GetHealthReportPath, "ops", true},
+		{"custom profile methods are case-insensitive", ops, http.MethodPost, cns.GetHealthReportPath, "ops", false},
+		{"unmatched caller gets the default profile", anonymous, http.MethodPost, cns.RequestIPConfigs, ProfileCNI, true},
+		{"cni may manage endpoints", anonymous, http.MethodPatch, cns.EndpointPath + "abc", ProfileCNI, true},
+		{"cni may not delete networks", anonymous, http.MethodPost, cns.DeleteNetworkPath, ProfileCNI, false},
+		{"cni may not publish NCs", anonymous, http.MethodPost, cns.V2Prefix + cns.PublishNetworkContainer, ProfileCNI, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, allowed := a.Authorize(tt.id, tt.method, tt.path)
+			assert.Equal(t, tt.wantProfile, profile)
+			assert.Equal(t, tt.wantAllowed, allowed)
+		})
+	}
+}
+
+func TestAuthorizeWithoutDefaultProfile(t *testing.T) {
+	settings := testSettings()
+	settings.DefaultProfile = ""
+	a, err := New(settings)
+	require.NoError(t, err)
+
+	profile, allowed := a.Authorize(Identity{Kind: Anonymous}, http.MethodPost, cns.RequestIPConfigs)
+	assert.Empty(t, profile)
+	assert.False(t, allowed)
+}
+
+func TestNewValidatesSettings(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Settings)
+	}{
+		{"unknown rule profile", func(s *Settings) { s.Rules[0].Profile = "missing" }},
+		{"rule without identities", func(s *Settings) { s.Rules[0].Subjects = nil }},
+		{"unknown default profile", func(s *Settings) { s.DefaultProfile = "missing" }},
+		{"permission without path", func(s *Settings) { s.Profiles["ops"] = []Permission{{Methods: []string{http.MethodGet}}} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := testSettings()
+			tt.modify(settings)
+			_, err := New(settings)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	logger.InitLogger("azure-cns.log", 0, 0, "/")
+	var called bool
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true })
+
+	newRequest := func(path, commonName string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, path, http.NoBody)
+		r.TLS = &tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: commonName}}},
+		}
+		return r
+	}
+
+	a, err := New(testSettings())
+	require.NoError(t, err)
+	handler := a.Middleware(next)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest(cns.PublishNetworkContainer, "dnc.example.com"))
+	assert.True(t, called)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	called = false
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest(cns.ReleaseIPConfigs, "node1.debug.example.com"))
+	assert.False(t, called)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// audit only mode lets denied calls through
+	settings := testSettings()
+	settings.AuditOnly = true
+	a, err = New(settings)
+	require.NoError(t, err)
+	w = httptest.NewRecorder()
+	a.Middleware(next).ServeHTTP(w, newRequest(cns.ReleaseIPConfigs, "node1.debug.example.com"))
+	assert.True(t, called)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,76 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// IdentityKind is how the caller of a request was identified.
+type IdentityKind string
+
+const (
+	// Certificate callers presented a client certificate over mTLS.
+	Certificate IdentityKind = "certificate"
+	// UnixPeer callers connected over a local Unix socket and are identified by their peer credentials.
+	UnixPeer IdentityKind = "unix"
+	// Anonymous callers could not be identified, e.g. plain HTTP over TCP.
+	Anonymous IdentityKind = "anonymous"
+)
+
+// Identity is the caller of a request.
+type Identity struct {
+	Kind IdentityKind
+	// Names are the subject common name and DNS SANs of a Certificate caller.
+	Names []string
+	// UID, GID and PID are the peer credentials of a UnixPeer caller.
+	UID uint32
+	GID uint32
+	PID int32
+}
+
+func (i Identity) String() string {
+	switch i.Kind {
+	case Certificate:
+		return fmt.Sprintf("certificate(%s)", strings.Join(i.Names, ","))
+	case UnixPeer:
+		return fmt.Sprintf("unix(uid=%d,gid=%d,pid=%d)", i.UID, i.GID, i.PID)
+	default:
+		return string(Anonymous)
+	}
+}
+
+type peerCredKey struct{}
+
+// ConnContext is the http.Server ConnContext hook which records the peer credentials of
+// connections accepted on a Unix socket so that they are available to IdentityFromRequest.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	uc, ok := c.(*net.UnixConn)
+	if !ok {
+		return ctx
+	}
+	id, err := peerCredentials(uc)
+	if err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, peerCredKey{}, id)
+}
+
+// IdentityFromRequest returns the identity of the caller of the request.
+func IdentityFromRequest(r *http.Request) Identity {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		cert := r.TLS.PeerCertificates[0]
+		names := make([]string, 0, len(cert.DNSNames)+1)
+		if cert.Subject.CommonName != "" {
+			names = append(names, cert.Subject.CommonName)
+		}
+		names = append(names, cert.DNSNames...)
+		return Identity{Kind: Certificate, Names: names}
+	}
+	if id, ok := r.Context().Value(peerCredKey{}).(Identity); ok {
+		return id
+	}
+	return Identity{Kind: Anonymous}
+}
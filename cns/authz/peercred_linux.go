@@ -0,0 +1,28 @@
+package authz
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+func peerCredentials(c *net.UnixConn) (Identity, error) {
+	raw, err := c.SyscallConn()
+	if err != nil {
+		return Identity{}, errors.Wrap(err, "failed to get raw connection")
+	}
+	var (
+		cred    *unix.Ucred
+		credErr error
+	)
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil {
+		return Identity{}, errors.Wrap(err, "failed to access connection")
+	}
+	if credErr != nil {
+		return Identity{}, errors.Wrap(credErr, "failed to get peer credentials")
+	}
+	return Identity{Kind: UnixPeer, UID: cred.Uid, GID: cred.Gid, PID: cred.Pid}, nil
+}
@@ -0,0 +1,43 @@
+package authz
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentityFromUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "cns.sock")
+	l, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	ids := make(chan Identity, 1)
+	server := &http.Server{ //nolint:gosec // test server
+		ConnContext: ConnContext,
+		Handler: http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			ids <- IdentityFromRequest(r)
+		}),
+	}
+	go server.Serve(l) //nolint:errcheck // closed below
+	defer server.Close()
+
+	client := http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	resp, err := client.Get("http://cns/debug/ipaddresses")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	id := <-ids
+	assert.Equal(t, UnixPeer, id.Kind)
+	assert.Equal(t, uint32(os.Getuid()), id.UID)
+	assert.Equal(t, int32(os.Getpid()), id.PID)
+}
@@ -0,0 +1,47 @@
+package authz
+
+import (
+	"net/http"
+
+	"github.com/Azure/azure-container-networking/cns"
+)
+
+// Default profiles.
+const (
+	// ProfileCNI allows the calls the CNI plugin makes to allocate and release pod IPs.
+	ProfileCNI = "cni"
+	// ProfileDNC allows every call, for the DNC control plane which manages the node's network containers.
+	ProfileDNC = "dnc"
+	// ProfileDebug allows read-only debugging and health calls.
+	ProfileDebug = "debug-readonly"
+)
+
+// DefaultProfiles returns a new copy of the default profiles.
+func DefaultProfiles() map[string][]Permission {
+	return map[string][]Permission{
+		ProfileCNI: {
+			{Path: cns.RequestIPConfig},
+			{Path: cns.RequestIPConfigs},
+			{Path: cns.ReleaseIPConfig},
+			{Path: cns.ReleaseIPConfigs},
+			{Path: cns.GetNetworkContainerByOrchestratorContext},
+			{Path: cns.GetAllNetworkContainers},
+			{Path: cns.CreateHostNCApipaEndpointPath},
+			{Path: cns.DeleteHostNCApipaEndpointPath},
+			{Path: cns.EndpointPath + "*"},
+			{Path: cns.NmAgentSupportedApisPath},
+			{Path: cns.GetHomeAz},
+		},
+		ProfileDNC: {
+			{Path: "*"},
+		},
+		ProfileDebug: {
+			{Path: "/debug/*"},
+			{Path: cns.GetHealthReportPath},
+			{Path: cns.GetHomeAz},
+			{Path: cns.NumberOfCPUCoresPath},
+			{Path: cns.GetAllNetworkContainers},
+			{Path: cns.NetworkContainersURLPath, Methods: []string{http.MethodGet}},
+		},
+	}
+}
@@ -6,8 +6,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/Azure/azure-container-networking/cns"
@@ -19,6 +21,10 @@ import (
 const (
 	contentTypeJSON = "application/json"
 	defaultBaseURL  = "http://localhost:10090"
+	// unixURLPrefix selects the CNS Unix socket at the path which follows, e.g. unix:///var/run/azure-cns/cni.sock.
+	unixURLPrefix = "unix://"
+	// unixBaseURL is the base URL of requests sent over the Unix socket, whose host is ignored.
+	unixBaseURL = "http://localhost"
 	// DefaultTimeout default timeout duration for CNS Client.
 	DefaultTimeout    = 5 * time.Second
 	headerContentType = "Content-Type"
@@ -36,6 +42,8 @@ var clientPaths = []string{
 	cns.PathDebugIPAddresses,
 	cns.PathDebugPodContext,
 	cns.PathDebugRestData,
+	cns.PathDebugIPHistory,
+	cns.PathDebugIPAllocationFailure,
 	cns.UnpublishNetworkContainer,
 	cns.PublishNetworkContainer,
 	cns.CreateOrUpdateNetworkContainer,
@@ -46,6 +54,7 @@ var clientPaths = []string{
 	cns.NetworkContainersURLPath,
 	cns.GetHomeAz,
 	cns.EndpointAPI,
+	cns.GetHealthReportPath,
 }
 
 type do interface {
@@ -71,20 +80,35 @@ func NewConnectionFailureErr(cause error) *ConnectionFailureErr {
 }
 
 // New returns a new CNS client configured with the passed URL and timeout.
+// A unix:// URL connects to CNS over the Unix socket at its path.
 func New(baseURL string, requestTimeout time.Duration) (*Client, error) {
 	if baseURL == "" {
 		baseURL = defaultBaseURL
 	}
 
+	httpClient := &http.Client{
+		Timeout: requestTimeout,
+	}
+	if socketPath, ok := strings.CutPrefix(baseURL, unixURLPrefix); ok {
+		if socketPath == "" {
+			return nil, errors.Errorf("no socket path in CNS URL %s", baseURL)
+		}
+		baseURL = unixBaseURL
+		httpClient.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socketPath)
+			},
+		}
+	}
+
 	routes, err := buildRoutes(baseURL, clientPaths)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Client{
-		client: &http.Client{
-			Timeout: requestTimeout,
-		},
+		client: httpClient,
 		routes: routes,
 	}, nil
 }
@@ -438,6 +462,12 @@ func (c *Client) RequestIPs(ctx context.Context, ipconfig cns.IPConfigsRequest)
 	}
 
 	if response.Response.ReturnCode != 0 {
+		if response.Diagnosis != nil {
+			return nil, &IPAllocationError{
+				Message:   response.Response.Message,
+				Diagnosis: response.Diagnosis,
+			}
+		}
 		return nil, errors.New(response.Response.Message)
 	}
 
@@ -566,6 +596,91 @@ func (c *Client) GetPodOrchestratorContext(ctx context.Context) (map[string][]st
 	return resp.PodContext, nil
 }
 
+// GetIPAllocationFailure returns why the last IPConfigsRequest of the Pod failed.
+func (c *Client) GetIPAllocationFailure(ctx context.Context, podName, podNamespace string) (*cns.IPAllocationDiagnosis, error) {
+	payload := cns.GetIPAllocationFailureRequest{
+		PodName:      podName,
+		PodNamespace: podNamespace,
+	}
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(payload); err != nil {
+		return nil, errors.Wrap(err, "failed to encode GetIPAllocationFailureRequest")
+	}
+
+	u := c.routes[cns.PathDebugIPAllocationFailure]
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), &body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build request")
+	}
+	req.Header.Set(headerContentType, contentTypeJSON)
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "http request failed")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("http response %d", res.StatusCode)
+	}
+
+	var resp cns.GetIPAllocationFailureResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return nil, errors.Wrap(err, "failed to decode GetIPAllocationFailureResponse")
+	}
+
+	if resp.Response.ReturnCode != 0 {
+		return nil, &CNSClientError{
+			Code: resp.Response.ReturnCode,
+			Err:  errors.New(resp.Response.Message),
+		}
+	}
+
+	return resp.Diagnosis, nil
+}
+
+// GetIPHistory returns the Pods the IP was assigned to in the time window from-to.
+// An empty IP matches all IPs, and a zero from or to leaves that end of the window open.
+func (c *Client) GetIPHistory(ctx context.Context, ip string, from, to time.Time) ([]cns.IPAssignmentRecord, error) {
+	payload := cns.GetIPHistoryRequest{
+		IPAddress: ip,
+		From:      from,
+		To:        to,
+	}
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(payload); err != nil {
+		return nil, errors.Wrap(err, "failed to encode GetIPHistoryRequest")
+	}
+
+	u := c.routes[cns.PathDebugIPHistory]
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), &body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build request")
+	}
+	req.Header.Set(headerContentType, contentTypeJSON)
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "http request failed")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("http response %d", res.StatusCode)
+	}
+
+	var resp cns.GetIPHistoryResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return nil, errors.Wrap(err, "failed to decode GetIPHistoryResponse")
+	}
+
+	if resp.Response.ReturnCode != 0 {
+		return nil, errors.New(resp.Response.Message)
+	}
+
+	return resp.Records, nil
+}
+
 // GetHTTPServiceData gets all public in-memory struct details for debugging purpose
 func (c *Client) GetHTTPServiceData(ctx context.Context) (*restserver.GetHTTPServiceDataResponse, error) {
 	u := c.routes[cns.PathDebugRestData]
@@ -1027,6 +1142,43 @@ func (c *Client) GetHomeAz(ctx context.Context) (*cns.GetHomeAzResponse, error)
 	return &getHomeAzResponse, nil
 }
 
+// GetHealthReport gets the health report of CNS, including the state of its NMAgent circuit breakers
+func (c *Client) GetHealthReport(ctx context.Context) (*cns.HealthReportResponse, error) {
+	// build the request
+	u := c.routes[cns.GetHealthReportPath]
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "building http request")
+	}
+
+	// submit the request
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "sending HTTP request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("http response %d", resp.StatusCode)
+	}
+
+	// decode the response
+	var healthReport cns.HealthReportResponse
+	err = json.NewDecoder(resp.Body).Decode(&healthReport)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding response as JSON")
+	}
+
+	if healthReport.ReturnCode != 0 {
+		return nil, &CNSClientError{
+			Code: healthReport.ReturnCode,
+			Err:  errors.New(healthReport.Message),
+		}
+	}
+
+	return &healthReport, nil
+}
+
 // GetEndpoint calls the EndpointHandlerAPI in CNS to retrieve the state of a given EndpointID
 func (c *Client) GetEndpoint(ctx context.Context, endpointID string) (*restserver.GetEndpointResponse, error) {
 	// build the request
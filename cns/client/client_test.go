@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"testing"
@@ -392,6 +393,28 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNewUnixSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "cns.sock")
+	l, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	mux := http.NewServeMux()
+	mux.HandleFunc(cns.GetHomeAz, func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(cns.GetHomeAzResponse{HomeAzResponse: cns.HomeAzResponse{IsSupported: true, HomeAz: 2}})
+	})
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: time.Second}
+	go func() { _ = srv.Serve(l) }()
+	t.Cleanup(func() { _ = srv.Close() })
+
+	client, err := New("unix://"+socketPath, time.Second)
+	require.NoError(t, err)
+	resp, err := client.GetHomeAz(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint(2), resp.HomeAzResponse.HomeAz)
+
+	_, err = New("unix://", time.Second)
+	require.Error(t, err)
+}
+
 func TestBuildRoutes(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -1921,6 +1944,53 @@ func TestRequestIPs(t *testing.T) {
 	}
 }
 
+func TestRequestIPsDiagnosis(t *testing.T) {
+	emptyRoutes, _ := buildRoutes(defaultBaseURL, clientPaths)
+	diagnosis := &cns.IPAllocationDiagnosis{
+		FailedStep: cns.IPAllocationStepSWIFTv2GetMTPNC,
+		Pool:       cns.IPPoolDiagnosis{Available: 3},
+	}
+	client := &Client{
+		client: &mockdo{
+			objToReturn: &cns.IPConfigsResponse{
+				Response: cns.Response{
+					ReturnCode: types.UnexpectedError,
+					Message:    "network is not ready - mtpnc is not ready",
+				},
+				Diagnosis: diagnosis,
+			},
+			httpStatusCodeToReturn: http.StatusOK,
+		},
+		routes: emptyRoutes,
+	}
+	_, err := client.RequestIPs(context.TODO(), cns.IPConfigsRequest{})
+	require.EqualError(t, err, "network is not ready - mtpnc is not ready")
+	assert.Equal(t, diagnosis, IPAllocationDiagnosis(err))
+	assert.Nil(t, IPAllocationDiagnosis(errors.New("other")))
+}
+
+func TestGetIPAllocationFailure(t *testing.T) {
+	emptyRoutes, _ := buildRoutes(defaultBaseURL, clientPaths)
+	diagnosis := &cns.IPAllocationDiagnosis{PodName: "pod", PodNamespace: "default", FailedStep: cns.IPAllocationStepAssignIPs}
+	client := &Client{
+		client: &mockdo{
+			objToReturn:            &cns.GetIPAllocationFailureResponse{Diagnosis: diagnosis},
+			httpStatusCodeToReturn: http.StatusOK,
+		},
+		routes: emptyRoutes,
+	}
+	got, err := client.GetIPAllocationFailure(context.TODO(), "pod", "default")
+	require.NoError(t, err)
+	assert.Equal(t, diagnosis, got)
+
+	client.client = &mockdo{
+		objToReturn:            &cns.GetIPAllocationFailureResponse{Response: cns.Response{ReturnCode: types.NotFound, Message: "no failed IP allocation"}},
+		httpStatusCodeToReturn: http.StatusOK,
+	}
+	_, err = client.GetIPAllocationFailure(context.TODO(), "pod", "default")
+	require.Error(t, err)
+}
+
 func TestReleaseIPs(t *testing.T) {
 	emptyRoutes, _ := buildRoutes(defaultBaseURL, clientPaths)
 	tests := []struct {
@@ -2701,6 +2771,77 @@ func TestGetHomeAz(t *testing.T) {
 	}
 }
 
+func TestGetHealthReport(t *testing.T) {
+	emptyRoutes, _ := buildRoutes(defaultBaseURL, clientPaths)
+	tests := []struct {
+		name       string
+		statusCode int
+		shouldErr  bool
+		exp        *cns.HealthReportResponse
+	}{
+		{
+			"happy path",
+			http.StatusOK,
+			false,
+			&cns.HealthReportResponse{
+				Response: cns.Response{
+					ReturnCode: 0,
+				},
+				NMAgentCircuitBreakers: map[string]string{
+					"GetHomeAz": "closed",
+					"PutNC":     "open",
+				},
+			},
+		},
+		{
+			"error",
+			http.StatusOK,
+			true,
+			&cns.HealthReportResponse{
+				Response: cns.Response{
+					ReturnCode: types.UnexpectedError,
+					Message:    "unexpected error",
+				},
+			},
+		},
+		{
+			"forbidden",
+			http.StatusForbidden,
+			true,
+			&cns.HealthReportResponse{},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := &Client{
+				client: &mockdo{
+					errToReturn:            nil,
+					objToReturn:            test.exp,
+					httpStatusCodeToReturn: test.statusCode,
+				},
+				routes: emptyRoutes,
+			}
+
+			got, err := client.GetHealthReport(context.Background())
+			if err != nil && !test.shouldErr {
+				t.Fatal("unexpected error: err:", err)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatal("expected an error but received none")
+			}
+
+			if !test.shouldErr && !cmp.Equal(got, test.exp) {
+				t.Error("received response differs from expectation: diff:", cmp.Diff(got, test.exp))
+			}
+		})
+	}
+}
+
 func TestUpdateEndpoint(t *testing.T) {
 	// the CNS client has to be provided with routes going somewhere, so create a
 	// bunch of routes mapped to the localhost
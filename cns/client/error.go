@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/Azure/azure-container-networking/cns"
 	"github.com/Azure/azure-container-networking/cns/types"
 )
 
@@ -41,3 +42,23 @@ func IsUnsupportedAPI(err error) bool {
 	e := &CNSClientError{}
 	return errors.As(err, &e) && (e.Code == types.UnsupportedAPI)
 }
+
+// IPAllocationError is returned by RequestIPs when CNS failed the request and diagnosed why.
+type IPAllocationError struct {
+	Message   string
+	Diagnosis *cns.IPAllocationDiagnosis
+}
+
+func (e *IPAllocationError) Error() string {
+	return e.Message
+}
+
+// IPAllocationDiagnosis returns the diagnosis of the failed IP allocation if the provided error
+// is of type IPAllocationError, or nil.
+func IPAllocationDiagnosis(err error) *cns.IPAllocationDiagnosis {
+	e := &IPAllocationError{}
+	if errors.As(err, &e) {
+		return e.Diagnosis
+	}
+	return nil
+}
@@ -3,13 +3,16 @@ package cli
 import (
 	"context"
 	"fmt"
+	"net"
 	"os"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-container-networking/cns"
 	"github.com/Azure/azure-container-networking/cns/client"
 	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/pkg/errors"
 )
 
 const (
@@ -18,6 +21,7 @@ const (
 	getCmdArg       = "get"
 	getInMemoryData = "getInMemory"
 	getPodCmdArg    = "getPodContexts"
+	explainCmdArg   = "explain"
 )
 
 func HandleCNSClientCommands(ctx context.Context, cmd string, arg string) error {
@@ -36,12 +40,21 @@ func HandleCNSClientCommands(ctx context.Context, cmd string, arg string) error
 		return getPodCmd(ctx, cnsClient)
 	case strings.EqualFold(getInMemoryData, cmd):
 		return getInMemory(ctx, cnsClient)
+	case strings.EqualFold(explainCmdArg, cmd):
+		return explainCmd(ctx, cnsClient, arg)
 	default:
 		return fmt.Errorf("No debug cmd supplied, options are: %v", getCmdArg)
 	}
 }
 
 func getCmd(ctx context.Context, client *client.Client, arg string) error {
+	if ip, from, to, ok, err := parseIPHistoryArg(arg); ok {
+		if err != nil {
+			return err
+		}
+		return getIPHistory(ctx, client, ip, from, to)
+	}
+
 	var states []types.IPState
 
 	switch types.IPState(arg) {
@@ -66,6 +79,21 @@ func getCmd(ctx context.Context, client *client.Client, arg string) error {
 	return nil
 }
 
+// explainCmd prints why the last IP allocation of the pod, given as <namespace>/<name>, failed.
+func explainCmd(ctx context.Context, client *client.Client, arg string) error {
+	namespace, name, found := strings.Cut(arg, "/")
+	if !found || namespace == "" || name == "" {
+		return errors.Errorf("expected <namespace>/<name> of a pod, got %q", arg)
+	}
+	d, err := client.GetIPAllocationFailure(ctx, name, namespace)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s/%s failed at %s with code %s: %s\n", d.PodNamespace, d.PodName, d.Time.Format(time.RFC3339), d.ReturnCode, d.Message)
+	fmt.Println(d.String())
+	return nil
+}
+
 // Sort the addresses based on IP, then write to stdout
 func printIPAddresses(addrSlice []cns.IPConfigurationStatus) {
 	sort.Slice(addrSlice, func(i, j int) bool {
@@ -77,6 +105,44 @@ func printIPAddresses(addrSlice []cns.IPConfigurationStatus) {
 	}
 }
 
+// parseIPHistoryArg parses a get argument of the form <ip>, <ip>@<time> or <ip>@<from>/<to>, with times in RFC3339.
+// ok is false if the argument is not an IP, in which case it is an IP state.
+func parseIPHistoryArg(arg string) (ip string, from, to time.Time, ok bool, err error) {
+	ip, window, hasWindow := strings.Cut(arg, "@")
+	if net.ParseIP(ip) == nil {
+		return "", time.Time{}, time.Time{}, false, nil
+	}
+	if !hasWindow {
+		return ip, time.Time{}, time.Time{}, true, nil
+	}
+	fromStr, toStr, isRange := strings.Cut(window, "/")
+	if from, err = time.Parse(time.RFC3339, fromStr); err != nil {
+		return "", time.Time{}, time.Time{}, true, errors.Wrapf(err, "invalid time %s", fromStr)
+	}
+	if !isRange {
+		return ip, from, from, true, nil
+	}
+	if to, err = time.Parse(time.RFC3339, toStr); err != nil {
+		return "", time.Time{}, time.Time{}, true, errors.Wrapf(err, "invalid time %s", toStr)
+	}
+	return ip, from, to, true, nil
+}
+
+func getIPHistory(ctx context.Context, client *client.Client, ip string, from, to time.Time) error {
+	records, err := client.GetIPHistory(ctx, ip, from, to)
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		end := "assigned"
+		if !r.End.IsZero() {
+			end = r.End.Format(time.RFC3339)
+		}
+		fmt.Printf("%s %s/%s container %s from %s to %s\n", r.IPAddress, r.PodNamespace, r.PodName, r.ContainerID, r.Start.Format(time.RFC3339), end)
+	}
+	return nil
+}
+
 func getPodCmd(ctx context.Context, client *client.Client) error {
 	resp, err := client.GetPodOrchestratorContext(ctx)
 	if err != nil {
@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIPHistoryArg(t *testing.T) {
+	t1 := time.Date(2026, 1, 2, 14, 5, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	tests := []struct {
+		name     string
+		arg      string
+		wantIP   string
+		wantFrom time.Time
+		wantTo   time.Time
+		wantOK   bool
+		wantErr  bool
+	}{
+		{name: "state", arg: "Assigned"},
+		{name: "empty", arg: ""},
+		{name: "ip", arg: "10.1.2.3", wantIP: "10.1.2.3", wantOK: true},
+		{name: "ip at time", arg: "10.1.2.3@2026-01-02T14:05:00Z", wantIP: "10.1.2.3", wantFrom: t1, wantTo: t1, wantOK: true},
+		{name: "ip in window", arg: "fd00::1@2026-01-02T14:05:00Z/2026-01-02T15:05:00Z", wantIP: "fd00::1", wantFrom: t1, wantTo: t2, wantOK: true},
+		{name: "invalid time", arg: "10.1.2.3@14:05", wantOK: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ip, from, to, ok, err := parseIPHistoryArg(tt.arg)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantIP, ip)
+			assert.True(t, tt.wantFrom.Equal(from))
+			assert.True(t, tt.wantTo.Equal(to))
+		})
+	}
+}
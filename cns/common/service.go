@@ -44,6 +44,7 @@ type ServiceConfig struct {
 	ChannelMode string
 	TLSSettings tls.TlsSettings
 	Logger      *zap.Logger
+	Authorizer  acn.RequestAuthorizer
 }
 
 // server struct to store primaryInterfaceIP from VM, port where customer provides by -p and temporary flag EnableLocalServer
@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/authz"
 	"github.com/Azure/azure-container-networking/cns/logger"
 	loggerv2 "github.com/Azure/azure-container-networking/cns/logger/v2"
 	"github.com/Azure/azure-container-networking/common"
@@ -25,16 +26,21 @@ const (
 type CNSConfig struct {
 	AZRSettings                     AZRSettings
 	AsyncPodDeletePath              string
+	AuthzSettings                   authz.Settings
 	CNIConflistFilepath             string
 	CNIConflistScenario             string
 	ChannelMode                     string
 	EnableAPIServerHealthPing       bool
 	EnableAsyncPodDelete            bool
 	EnableCNIConflistGeneration     bool
+	EnableConfigReload              bool
 	EnableHomeAZ                    bool
+	EnableIPAMReport                bool
+	EnableIPHistory                 bool
 	EnableIPAMv2                    bool
 	EnableK8sDevicePlugin           bool
 	EnableLoggerV2                  bool
+	EnableOverlayExtensionConfig    bool
 	EnablePprof                     bool
 	EnableStateMigration            bool
 	EnableSubnetScarcity            bool
@@ -47,6 +53,7 @@ type CNSConfig struct {
 	KeyVaultSettings                KeyVaultSettings
 	Logger                          loggerv2.Config
 	MSISettings                     MSISettings
+	MTUSettings                     MTUSettings
 	ManageEndpointState             bool
 	ManagedSettings                 ManagedSettings
 	MellanoxMonitorIntervalSecs     int
@@ -54,7 +61,9 @@ type CNSConfig struct {
 	ProgramSNATIPTables             bool
 	SyncHostNCTimeoutMs             int
 	SyncHostNCVersionIntervalMs     int
+	TLSCABundlePath                 string
 	TLSCertificatePath              string
+	TLSRefreshIntervalSecs          int
 	TLSEndpoint                     string
 	TLSPort                         string
 	TLSSubjectName                  string
@@ -111,6 +120,18 @@ type AZRSettings struct {
 	PopulateHomeAzCacheRetryIntervalSecs int
 }
 
+// MTUSettings describe how CNS computes the effective MTU reported for each pod interface.
+type MTUSettings struct {
+	// Enable turns on MTU reporting in PodIpInfo.
+	Enable bool
+	// HostNICMTU overrides the MTU discovered on the host primary interface. Zero means discover it.
+	HostNICMTU int
+	// OverlayOverheadBytes is subtracted from the host NIC MTU for infra interfaces whose traffic is encapsulated.
+	OverlayOverheadBytes int
+	// DelegatedNICMTU is the MTU of delegated VM NICs. Zero means use the host NIC MTU.
+	DelegatedNICMTU int
+}
+
 type MSISettings struct {
 	ResourceID string
 }
@@ -250,6 +271,9 @@ func SetCNSConfigDefaults(config *CNSConfig) {
 	if config.MinTLSVersion == "" {
 		config.MinTLSVersion = "TLS 1.2"
 	}
+	if config.TLSRefreshIntervalSecs == 0 {
+		config.TLSRefreshIntervalSecs = 60 //nolint:gomnd // default times
+	}
 	// Validate IPv6PrefixClamp to avoid invalid prefix lengths reaching netip.PrefixFrom.
 	// If IPv6PrefixClamp less than 120, large amount of IPs will be generated which could lead to OOM.
 	// If IPv6PrefixClamp greater than 128, it's an error in config since max prefix length for IPv6 is 128.
@@ -228,6 +228,7 @@ func TestSetCNSConfigDefaults(t *testing.T) {
 				},
 				MinTLSVersion:             "TLS 1.2",
 				MtlsClientCertSubjectName: "",
+				TLSRefreshIntervalSecs:    60,
 			},
 		},
 		{
@@ -261,6 +262,7 @@ func TestSetCNSConfigDefaults(t *testing.T) {
 				},
 				MinTLSVersion:             "TLS 1.3",
 				MtlsClientCertSubjectName: "example.com",
+				TLSRefreshIntervalSecs:    5,
 			},
 			want: CNSConfig{
 				ChannelMode: "Other",
@@ -293,6 +295,7 @@ func TestSetCNSConfigDefaults(t *testing.T) {
 				},
 				MinTLSVersion:             "TLS 1.3",
 				MtlsClientCertSubjectName: "example.com",
+				TLSRefreshIntervalSecs:    5,
 			},
 		},
 	}
@@ -0,0 +1,247 @@
+package configuration
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-container-networking/aitelemetry"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/pkg/errors"
+)
+
+// reloadableFields are the fields of the CNSConfig which CNS applies at runtime when the config file changes.
+// A change to any other field only takes effect after CNS restarts.
+var reloadableFields = map[string]struct{}{
+	"Logger.Level":                                   {},
+	"SyncHostNCVersionIntervalMs":                    {},
+	"TelemetrySettings.ConfigSnapshotIntervalInMins": {},
+	"TelemetrySettings.HeartBeatIntervalInMins":      {},
+	"TelemetrySettings.SnapshotIntervalInMins":       {},
+}
+
+// IsReloadable returns whether a change to the field can be applied to the running config without restarting CNS.
+// Logger.Level is only applied to the v2 logger, so it needs a restart unless EnableLoggerV2 is set.
+func IsReloadable(running *CNSConfig, field string) bool {
+	if field == "Logger.Level" && !running.EnableLoggerV2 {
+		return false
+	}
+	_, ok := reloadableFields[field]
+	return ok
+}
+
+// Validate checks that a config with its defaults set can be run by CNS.
+func (cnsconfig *CNSConfig) Validate() error {
+	if cnsconfig.SyncHostNCVersionIntervalMs <= 0 {
+		return errors.Errorf("SyncHostNCVersionIntervalMs must be positive, got %d", cnsconfig.SyncHostNCVersionIntervalMs)
+	}
+	if cnsconfig.SyncHostNCTimeoutMs <= 0 {
+		return errors.Errorf("SyncHostNCTimeoutMs must be positive, got %d", cnsconfig.SyncHostNCTimeoutMs)
+	}
+	ts := cnsconfig.TelemetrySettings
+	if ts.HeartBeatIntervalInMins <= 0 || ts.SnapshotIntervalInMins <= 0 || ts.ConfigSnapshotIntervalInMins < 0 {
+		return errors.Errorf("telemetry intervals must be positive, got HeartBeatIntervalInMins %d, SnapshotIntervalInMins %d, ConfigSnapshotIntervalInMins %d",
+			ts.HeartBeatIntervalInMins, ts.SnapshotIntervalInMins, ts.ConfigSnapshotIntervalInMins)
+	}
+	if cnsconfig.TLSRefreshIntervalSecs < 0 {
+		return errors.Errorf("TLSRefreshIntervalSecs must not be negative, got %d", cnsconfig.TLSRefreshIntervalSecs)
+	}
+	if cnsconfig.MinTLSVersion != "TLS 1.2" && cnsconfig.MinTLSVersion != "TLS 1.3" {
+		return errors.Errorf("unsupported MinTLSVersion %q", cnsconfig.MinTLSVersion)
+	}
+	return nil
+}
+
+// Diff returns the sorted paths of the fields which differ between the configs, such as
+// "TelemetrySettings.HeartBeatIntervalInMins". Fields that are not read from the config file are ignored.
+func Diff(old, updated *CNSConfig) []string {
+	var changed []string
+	diffFields("", reflect.ValueOf(old).Elem(), reflect.ValueOf(updated).Elem(), &changed)
+	sort.Strings(changed)
+	return changed
+}
+
+func diffFields(prefix string, old, updated reflect.Value, changed *[]string) {
+	t := old.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Tag.Get("json") == "-" {
+			continue
+		}
+		path := prefix + field.Name
+		o, u := old.Field(i), updated.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			diffFields(path+".", o, u, changed)
+			continue
+		}
+		if !reflect.DeepEqual(o.Interface(), u.Interface()) {
+			*changed = append(*changed, path)
+		}
+	}
+}
+
+func fieldByPath(v reflect.Value, path string) reflect.Value {
+	for _, name := range strings.Split(path, ".") {
+		v = v.FieldByName(name)
+	}
+	return v
+}
+
+// ReloadResult is the outcome of a config reload.
+type ReloadResult struct {
+	// Applied are the changed fields which are now in effect.
+	Applied []string
+	// Refused are the changed fields which need a restart of CNS and were not applied.
+	Refused []string
+}
+
+// Watcher watches the CNS config file and applies the changes to the fields that can change at runtime.
+type Watcher struct {
+	path     string
+	mu       sync.Mutex
+	checksum [sha256.Size]byte
+	// baseline is the config file as it is in effect. Changes are diffed against it rather than against
+	// the running config, which CNS amends at startup.
+	baseline *CNSConfig
+	current  atomic.Pointer[CNSConfig]
+	handlers []func(old, updated *CNSConfig, applied []string)
+}
+
+// NewWatcher returns a Watcher for the config file CNS was started with, which is running the initial config.
+func NewWatcher(cmdLineConfigPath string, initial *CNSConfig) (*Watcher, error) {
+	path, err := getConfigFilePath(cmdLineConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{path: path}
+	w.current.Store(initial)
+	baseline := CNSConfig{}
+	if content, err := os.ReadFile(path); err == nil {
+		w.checksum = sha256.Sum256(content)
+		if err := json.Unmarshal(content, &baseline); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal config")
+		}
+	}
+	SetCNSConfigDefaults(&baseline)
+	w.baseline = &baseline
+	return w, nil
+}
+
+// Current returns the config currently in effect. It must not be modified.
+func (w *Watcher) Current() *CNSConfig {
+	return w.current.Load()
+}
+
+// OnChange registers a handler which is called with the previous and the new config after changes are applied.
+// Handlers are called one at a time and should not block.
+func (w *Watcher) OnChange(handler func(old, updated *CNSConfig, applied []string)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers = append(w.handlers, handler)
+}
+
+// Run reloads the config file every interval until the context is cancelled.
+func (w *Watcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := w.Reload(); err != nil {
+				logger.Errorf("[Configuration] Failed to reload config: %v", err)
+			}
+		}
+	}
+}
+
+// Reload reads the config file and, if it changed, validates it and applies the changes to reloadable fields.
+// Changes to the other fields are refused and reported. A nil result means the file has not changed.
+func (w *Watcher) Reload() (*ReloadResult, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	content, err := os.ReadFile(w.path)
+	if errors.Is(err, os.ErrNotExist) {
+		// CNS runs with the default config until a config file is created
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read config file %s", w.path)
+	}
+	checksum := sha256.Sum256(content)
+	if checksum == w.checksum {
+		return nil, nil
+	}
+	// only report each version of the file once
+	w.checksum = checksum
+
+	var candidate CNSConfig
+	if err := json.Unmarshal(content, &candidate); err != nil {
+		err = errors.Wrap(err, "failed to unmarshal config")
+		logReloadEvent(nil, err)
+		return nil, err
+	}
+	SetCNSConfigDefaults(&candidate)
+	if err := candidate.Validate(); err != nil {
+		err = errors.Wrap(err, "invalid config")
+		logReloadEvent(nil, err)
+		return nil, err
+	}
+
+	result := &ReloadResult{}
+	for _, field := range Diff(w.baseline, &candidate) {
+		if IsReloadable(w.current.Load(), field) {
+			result.Applied = append(result.Applied, field)
+		} else {
+			result.Refused = append(result.Refused, field)
+		}
+	}
+	if len(result.Applied) == 0 && len(result.Refused) == 0 {
+		return result, nil
+	}
+
+	if len(result.Applied) > 0 {
+		old := w.current.Load()
+		updated, baseline := *old, *w.baseline
+		from := reflect.ValueOf(&candidate).Elem()
+		for _, field := range result.Applied {
+			fieldByPath(reflect.ValueOf(&updated).Elem(), field).Set(fieldByPath(from, field))
+			fieldByPath(reflect.ValueOf(&baseline).Elem(), field).Set(fieldByPath(from, field))
+		}
+		w.baseline = &baseline
+		w.current.Store(&updated)
+		logger.Printf("[Configuration] Applied config changes to %v", result.Applied)
+		for _, handler := range w.handlers {
+			handler(old, &updated, result.Applied)
+		}
+	}
+	if len(result.Refused) > 0 {
+		logger.Errorf("[Configuration] Refused config changes to %v, CNS must be restarted to apply them", result.Refused)
+	}
+	logReloadEvent(result, nil)
+	return result, nil
+}
+
+func logReloadEvent(result *ReloadResult, err error) {
+	event := aitelemetry.Event{
+		EventName:  logger.CnsConfigReloadEventStr,
+		Properties: map[string]string{},
+	}
+	if result != nil {
+		event.Properties[logger.AppliedFieldsStr] = strings.Join(result.Applied, ",")
+		event.Properties[logger.RefusedFieldsStr] = strings.Join(result.Refused, ",")
+	}
+	if err != nil {
+		event.Properties[logger.ReloadErrorStr] = err.Error()
+	}
+	logger.LogEvent(event)
+}
@@ -0,0 +1,150 @@
+package configuration
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, path string, config *CNSConfig) {
+	t.Helper()
+	b, err := json.Marshal(config) //nolint:musttag // no tag needed for config
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, b, 0o600))
+}
+
+func TestDiff(t *testing.T) {
+	old := &CNSConfig{ChannelMode: "Direct", WatchPods: true}
+	SetCNSConfigDefaults(old)
+	updated := *old
+	updated.ChannelMode = "CRD"
+	updated.WatchPods = false
+	updated.TelemetrySettings.HeartBeatIntervalInMins = 5
+	updated.Logger.Level = "debug"
+	updated.KeyVaultSettings.URL = "https://kv"
+
+	assert.Equal(t, []string{
+		"ChannelMode",
+		"KeyVaultSettings.URL",
+		"Logger.Level",
+		"TelemetrySettings.HeartBeatIntervalInMins",
+	}, Diff(old, &updated))
+	assert.Empty(t, Diff(old, old))
+}
+
+func TestValidate(t *testing.T) {
+	config := &CNSConfig{}
+	SetCNSConfigDefaults(config)
+	require.NoError(t, config.Validate())
+
+	invalid := *config
+	invalid.SyncHostNCVersionIntervalMs = -1
+	require.Error(t, invalid.Validate())
+
+	invalid = *config
+	invalid.TelemetrySettings.HeartBeatIntervalInMins = -1
+	require.Error(t, invalid.Validate())
+
+	invalid = *config
+	invalid.MinTLSVersion = "TLS 1.0"
+	require.Error(t, invalid.Validate())
+}
+
+func TestWatcherReload(t *testing.T) {
+	logger.InitLogger("azure-cns.log", 0, 0, "/")
+	path := filepath.Join(t.TempDir(), "cns_config.json")
+	fileConfig := &CNSConfig{ChannelMode: "CRD"}
+	writeConfig(t, path, fileConfig)
+
+	initial := &CNSConfig{ChannelMode: "CRD"}
+	SetCNSConfigDefaults(initial)
+	// CNS amends the running config at startup, which must not be reported as a change
+	initial.MetricsBindAddress = ":9091"
+
+	w, err := NewWatcher(path, initial)
+	require.NoError(t, err)
+	var applied []string
+	var updated *CNSConfig
+	w.OnChange(func(_, u *CNSConfig, a []string) {
+		updated, applied = u, a
+	})
+
+	// unchanged file
+	result, err := w.Reload()
+	require.NoError(t, err)
+	assert.Nil(t, result)
+
+	// reloadable and restart-only changes
+	fileConfig.SyncHostNCVersionIntervalMs = 5000
+	fileConfig.ChannelMode = "Direct"
+	writeConfig(t, path, fileConfig)
+	result, err = w.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SyncHostNCVersionIntervalMs"}, result.Applied)
+	assert.Equal(t, []string{"ChannelMode"}, result.Refused)
+	assert.Equal(t, []string{"SyncHostNCVersionIntervalMs"}, applied)
+	assert.Same(t, updated, w.Current())
+	assert.Equal(t, 5000, w.Current().SyncHostNCVersionIntervalMs)
+	assert.Equal(t, "CRD", w.Current().ChannelMode)
+	assert.Equal(t, ":9091", w.Current().MetricsBindAddress)
+	assert.Equal(t, 1000, initial.SyncHostNCVersionIntervalMs, "the initial config must not be modified")
+
+	// the refused change is still pending on the next change
+	fileConfig.TelemetrySettings.HeartBeatIntervalInMins = 5
+	writeConfig(t, path, fileConfig)
+	result, err = w.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"TelemetrySettings.HeartBeatIntervalInMins"}, result.Applied)
+	assert.Equal(t, []string{"ChannelMode"}, result.Refused)
+	assert.Equal(t, 5000, w.Current().SyncHostNCVersionIntervalMs)
+	assert.Equal(t, 5, w.Current().TelemetrySettings.HeartBeatIntervalInMins)
+
+	// an invalid config is rejected
+	current := w.Current()
+	fileConfig.SyncHostNCVersionIntervalMs = -1
+	writeConfig(t, path, fileConfig)
+	_, err = w.Reload()
+	require.Error(t, err)
+	assert.Same(t, current, w.Current())
+
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+	_, err = w.Reload()
+	require.Error(t, err)
+	assert.Same(t, current, w.Current())
+}
+
+func TestWatcherReloadLoggerLevel(t *testing.T) {
+	logger.InitLogger("azure-cns.log", 0, 0, "/")
+	tests := []struct {
+		name           string
+		enableLoggerV2 bool
+		wantApplied    []string
+		wantRefused    []string
+	}{
+		{"applied to the v2 logger", true, []string{"Logger.Level"}, nil},
+		{"refused without the v2 logger", false, nil, []string{"Logger.Level"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cns_config.json")
+			fileConfig := &CNSConfig{EnableLoggerV2: tt.enableLoggerV2}
+			writeConfig(t, path, fileConfig)
+			initial := &CNSConfig{EnableLoggerV2: tt.enableLoggerV2}
+			SetCNSConfigDefaults(initial)
+			w, err := NewWatcher(path, initial)
+			require.NoError(t, err)
+
+			fileConfig.Logger.Level = "debug"
+			writeConfig(t, path, fileConfig)
+			result, err := w.Reload()
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantApplied, result.Applied)
+			assert.Equal(t, tt.wantRefused, result.Refused)
+		})
+	}
+}
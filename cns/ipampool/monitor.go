@@ -6,6 +6,7 @@ import (
 	"net/netip"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Azure/azure-container-networking/cns"
@@ -18,6 +19,7 @@ import (
 	"github.com/avast/retry-go/v4"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 const (
@@ -39,6 +41,7 @@ type nodeNetworkConfigSpecUpdater interface {
 type metaState struct {
 	batch              int64
 	exhausted          bool
+	fairShare          int64
 	max                int64
 	maxFreeCount       int64
 	minFreeCount       int64
@@ -64,6 +67,7 @@ type Monitor struct {
 	nncSource   chan v1alpha.NodeNetworkConfig
 	started     chan interface{}
 	once        sync.Once
+	lastScale   atomic.Pointer[v1alpha.ScaleEvent]
 }
 
 func NewMonitor(httpService cns.HTTPService, nnccli nodeNetworkConfigSpecUpdater, cssSource <-chan v1alpha1.ClusterSubnetState, opts *Options) *Monitor {
@@ -105,7 +109,9 @@ func (pm *Monitor) Start(ctx context.Context) error {
 			}
 		case css := <-pm.cssSource: // received an updated ClusterSubnetState
 			pm.metastate.exhausted = css.Status.Exhausted
-			logger.Printf("subnet exhausted status = %t", pm.metastate.exhausted)
+			pm.metastate.fairShare = css.Status.NodeFairShare
+			logger.Printf("subnet exhausted status = %t, node fair share = %d, subnet free/total IPs = %d/%d",
+				pm.metastate.exhausted, pm.metastate.fairShare, css.Status.FreeIPs, css.Status.TotalIPs)
 			metrics.IpamSubnetExhaustionCount.With(prometheus.Labels{
 				metrics.SubnetLabel: pm.metastate.subnet, metrics.SubnetCIDRLabel: pm.metastate.subnetCIDR,
 				metrics.PodnetARMIDLabel: pm.metastate.subnetARMID, metrics.SubnetExhaustionStateLabel: strconv.FormatBool(pm.metastate.exhausted),
@@ -222,6 +228,10 @@ func (pm *Monitor) reconcile(ctx context.Context) error {
 		meta.batch = 1
 		meta.minFreeCount = 1
 		meta.maxFreeCount = 2
+		if meta.fairShare > 0 {
+			// a node within its fair share of the subnet keeps its free IPs, only the surplus above it is released
+			meta.maxFreeCount = max(meta.maxFreeCount, meta.fairShare-state.allocatedToPods+1)
+		}
 	}
 
 	switch {
@@ -292,6 +302,7 @@ func (pm *Monitor) increasePoolSize(ctx context.Context, meta metaState, state i
 	logger.Printf("[ipam-pool-monitor] Increasing pool size: UpdateCRDSpec succeeded for spec %+v", tempNNCSpec)
 	// start an alloc timer
 	metric.StartPoolIncreaseTimer(batchSize)
+	pm.lastScale.Store(&v1alpha.ScaleEvent{Time: metav1.Now(), From: previouslyRequestedIPCount, To: tempNNCSpec.RequestedIPCount})
 	// save the updated state to cachedSpec
 	pm.spec = tempNNCSpec
 	return nil
@@ -321,6 +332,11 @@ func (pm *Monitor) decreasePoolSize(ctx context.Context, meta metaState, state i
 	}
 
 	decreaseIPCountBy := previouslyRequestedIPCount - updatedRequestedIPCount
+	if meta.exhausted && meta.fairShare > 0 {
+		// release the whole surplus above the fair share at once instead of one IP per reconcile
+		decreaseIPCountBy = max(decreaseIPCountBy, calculateSurplusIPs(meta, state))
+		updatedRequestedIPCount = previouslyRequestedIPCount - decreaseIPCountBy
+	}
 
 	logger.Printf("[ipam-pool-monitor] updatedRequestedIPCount %d", updatedRequestedIPCount)
 
@@ -366,6 +382,7 @@ func (pm *Monitor) decreasePoolSize(ctx context.Context, meta metaState, state i
 	logger.Printf("[ipam-pool-monitor] Decreasing pool size: UpdateCRDSpec succeeded for spec %+v", tempNNCSpec)
 	// start a dealloc timer
 	metric.StartPoolDecreaseTimer(batchSize)
+	pm.lastScale.Store(&v1alpha.ScaleEvent{Time: metav1.Now(), From: previouslyRequestedIPCount, To: tempNNCSpec.RequestedIPCount})
 
 	// save the updated state to cachedSpec
 	pm.spec = tempNNCSpec
@@ -421,6 +438,7 @@ func (pm *Monitor) GetStateSnapshot() cns.IpamPoolMonitorStateSnapshot {
 		CachedNNC: v1alpha.NodeNetworkConfig{
 			Spec: spec,
 		},
+		LastScaleEvent: pm.lastScale.Load(),
 	}
 }
 
@@ -504,6 +522,13 @@ func observeIPPoolState(state ipPoolState, meta metaState) {
 	}
 }
 
+// calculateSurplusIPs calculates how many Available IPs the node holds above its fair share of an exhausted subnet,
+// keeping at least the minimum free IPs above the IPs allocated to Pods.
+func calculateSurplusIPs(meta metaState, state ipPoolState) int64 {
+	keep := max(meta.fairShare, state.allocatedToPods+meta.minFreeCount)
+	return max(0, min(state.requestedIPs-keep, state.available))
+}
+
 // CalculateMinFreeIPs calculates the minimum free IP quantity based on the Scaler
 // in the passed NodeNetworkConfig.
 // Half of odd batches are rounded up!
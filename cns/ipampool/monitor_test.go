@@ -45,6 +45,7 @@ type testState struct {
 	assigned                int
 	batch                   int64
 	exhausted               bool
+	fairShare               int64
 	max                     int64
 	pendingRelease          int64
 	releaseThresholdPercent int64
@@ -77,6 +78,7 @@ func initFakes(state testState, nnccli nodeNetworkConfigSpecUpdater) (*fakes.HTT
 		batch:     state.batch,
 		max:       state.max,
 		exhausted: state.exhausted,
+		fairShare: state.fairShare,
 	}
 	fakecns.PoolMonitor = &directUpdatePoolMonitor{m: poolmonitor}
 	if err := fakecns.SetNumberOfAssignedIPs(state.assigned); err != nil {
@@ -339,6 +341,38 @@ func TestPoolDecrease(t *testing.T) {
 			want:           16,
 			wantReleased:   4,
 		},
+		{
+			name: "exhausted above fair share",
+			in: testState{
+				allocated:               30,
+				assigned:                25,
+				batch:                   10,
+				exhausted:               true,
+				fairShare:               20,
+				max:                     30,
+				releaseThresholdPercent: 150,
+				requestThresholdPercent: 50,
+			},
+			targetAssigned: 5,
+			want:           20,
+			wantReleased:   10,
+		},
+		{
+			name: "exhausted fair share below assigned",
+			in: testState{
+				allocated:               20,
+				assigned:                15,
+				batch:                   10,
+				exhausted:               true,
+				fairShare:               10,
+				max:                     30,
+				releaseThresholdPercent: 150,
+				requestThresholdPercent: 50,
+			},
+			targetAssigned: 15,
+			want:           16,
+			wantReleased:   4,
+		},
 	}
 	for _, tt := range tests {
 		tt := tt
@@ -374,6 +408,29 @@ func TestPoolDecrease(t *testing.T) {
 	}
 }
 
+func TestPoolDecreaseReleasesFairShareSurplusAtOnce(t *testing.T) {
+	initState := testState{
+		allocated:               30,
+		assigned:                5,
+		batch:                   10,
+		exhausted:               true,
+		fairShare:               20,
+		max:                     30,
+		releaseThresholdPercent: 150,
+		requestThresholdPercent: 50,
+	}
+	_, fakerc, poolmonitor := initFakes(initState, nil)
+	assert.NoError(t, fakerc.Reconcile(true))
+
+	assert.NoError(t, poolmonitor.reconcile(context.Background()))
+	assert.EqualValues(t, 20, poolmonitor.spec.RequestedIPCount)
+	assert.Len(t, poolmonitor.spec.IPsNotInUse, 10)
+
+	// the node is now within its fair share and keeps its free IPs
+	assert.NoError(t, poolmonitor.reconcile(context.Background()))
+	assert.EqualValues(t, 20, poolmonitor.spec.RequestedIPCount)
+}
+
 func TestPoolSizeDecreaseWhenDecreaseHasAlreadyBeenRequested(t *testing.T) {
 	initState := testState{
 		batch:                   10,
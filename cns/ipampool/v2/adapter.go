@@ -26,7 +26,9 @@ func (m *adapter) Update(nnc *v1alpha.NodeNetworkConfig) error {
 }
 
 func (m *adapter) GetStateSnapshot() cns.IpamPoolMonitorStateSnapshot {
-	return cns.IpamPoolMonitorStateSnapshot{}
+	return cns.IpamPoolMonitorStateSnapshot{
+		LastScaleEvent: m.lastScale.Load(),
+	}
 }
 
 func PodIPDemandListener(ch chan<- int) func([]v1.Pod) {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Azure/azure-container-networking/cns"
@@ -11,6 +12,7 @@ import (
 	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig/api/v1alpha"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 const (
@@ -33,6 +35,8 @@ type scaler struct {
 	batch     int64
 	buffer    float64
 	exhausted bool
+	// fairShare is the number of IPs the node may hold while the subnet is exhausted. Zero means unset.
+	fairShare int64
 	max       int64
 }
 
@@ -49,6 +53,7 @@ type Monitor struct {
 	started               chan interface{}
 	once                  sync.Once
 	legacyMetricsObserver func(context.Context) error
+	lastScale             atomic.Pointer[v1alpha.ScaleEvent]
 }
 
 func NewMonitor(z *zap.Logger, store ipStateStore, nnccli nodeNetworkConfigSpecUpdater, demandSource <-chan int, nncSource <-chan v1alpha.NodeNetworkConfig, cssSource <-chan v1alpha1.ClusterSubnetState) *Monitor { //nolint:lll // it's fine
@@ -80,7 +85,9 @@ func (pm *Monitor) Start(ctx context.Context) error {
 			pm.z.Info("demand update", zap.Int64("demand", pm.demand))
 		case css := <-pm.cssSource: // received an updated ClusterSubnetState, recalculate request
 			pm.scaler.exhausted = css.Status.Exhausted
-			pm.z.Info("exhaustion update", zap.Bool("exhausted", pm.scaler.exhausted))
+			pm.scaler.fairShare = css.Status.NodeFairShare
+			pm.z.Info("exhaustion update", zap.Bool("exhausted", pm.scaler.exhausted), zap.Int64("fairShare", pm.scaler.fairShare),
+				zap.Int64("subnetFree", css.Status.FreeIPs), zap.Int64("subnetTotal", css.Status.TotalIPs))
 		case nnc := <-pm.nncSource: // received a new NodeNetworkConfig, extract the data from it and recalculate request
 			pm.scaler.max = int64(math.Min(float64(nnc.Status.Scaler.MaxIPCount), DefaultMaxIPs))
 			pm.scaler.batch = int64(math.Min(math.Max(float64(nnc.Status.Scaler.BatchSize), 1), float64(pm.scaler.max)))
@@ -121,6 +128,11 @@ func (pm *Monitor) reconcile(ctx context.Context) error {
 
 	// calculate the target state from the current pool state and scaler
 	target := calculateTargetIPCountOrMax(pm.demand, s.batch, s.max, s.buffer)
+	if s.exhausted && s.fairShare > 0 {
+		// the node may keep its usual buffer as long as it stays within its fair share of the subnet,
+		// and releases any surplus above it.
+		target = calculateFairShareTarget(target, calculateTargetIPCountOrMax(pm.demand, pm.scaler.batch, pm.scaler.max, pm.scaler.buffer), s.fairShare)
+	}
 	pm.z.Info("calculated new request", zap.Int64("demand", pm.demand), zap.Int64("batch", s.batch), zap.Int64("max", s.max), zap.Float64("buffer", s.buffer), zap.Int64("target", target))
 	delta := target - pm.request
 	if delta == 0 {
@@ -136,6 +148,7 @@ func (pm *Monitor) reconcile(ctx context.Context) error {
 	if _, err := pm.nnccli.PatchSpec(ctx, &spec, fieldManager); err != nil {
 		return errors.Wrap(err, "failed to UpdateSpec with NNC client")
 	}
+	pm.lastScale.Store(&v1alpha.ScaleEvent{Time: metav1.Now(), From: pm.request, To: target})
 	pm.request = target
 	pm.z.Info("scaled pool", zap.Int64("request", pm.request))
 	return nil
@@ -170,6 +183,12 @@ func calculateTargetIPCountOrMax(demand, batch, max int64, buffer float64) int64
 	return targetRequest
 }
 
+// calculateFairShareTarget calculates the target IP count request while the subnet is exhausted:
+// the unconstrained target clamped to the fair share, but never less than the minimal exhausted target.
+func calculateFairShareTarget(exhaustedTarget, target, fairShare int64) int64 {
+	return max(exhaustedTarget, min(target, fairShare))
+}
+
 // calculateTargetIPCount calculates an IP count request based on the
 // current demand, batch size, and buffer.
 // ref: https://github.com/Azure/azure-container-networking/blob/master/docs/feature/ipammath/0-background.md
@@ -330,6 +330,88 @@ func TestReconcile(t *testing.T) {
 			wantRequest:        16,
 			wantPendingRelease: 32,
 		},
+		// subnet exhaustion
+		{
+			name:    "exhausted without fair share",
+			demand:  5,
+			request: 32,
+			scaler: scaler{
+				batch:     16,
+				buffer:    .5,
+				max:       250,
+				exhausted: true,
+			},
+			nnccli:             nncClientMock{},
+			store:              ipStateStoreMock{},
+			wantRequest:        6,
+			wantPendingRelease: 26,
+		},
+		{
+			name:    "exhausted within fair share",
+			demand:  5,
+			request: 16,
+			scaler: scaler{
+				batch:     16,
+				buffer:    .5,
+				max:       250,
+				exhausted: true,
+				fairShare: 20,
+			},
+			nnccli: nncClientMock{
+				req: v1alpha.NodeNetworkConfigSpec{
+					RequestedIPCount: 16,
+				},
+			},
+			store:       ipStateStoreMock{},
+			wantRequest: 16,
+		},
+		{
+			name:    "exhausted releases surplus above fair share",
+			demand:  5,
+			request: 128,
+			scaler: scaler{
+				batch:     16,
+				buffer:    .5,
+				max:       250,
+				exhausted: true,
+				fairShare: 20,
+			},
+			nnccli:             nncClientMock{},
+			store:              ipStateStoreMock{},
+			wantRequest:        16,
+			wantPendingRelease: 112,
+		},
+		{
+			name:    "exhausted demand above fair share",
+			demand:  30,
+			request: 128,
+			scaler: scaler{
+				batch:     16,
+				buffer:    .5,
+				max:       250,
+				exhausted: true,
+				fairShare: 20,
+			},
+			nnccli:             nncClientMock{},
+			store:              ipStateStoreMock{},
+			wantRequest:        31,
+			wantPendingRelease: 97,
+		},
+		{
+			name:    "exhausted scale up capped at fair share",
+			demand:  15,
+			request: 16,
+			scaler: scaler{
+				batch:     16,
+				buffer:    .5,
+				max:       250,
+				exhausted: true,
+				fairShare: 20,
+			},
+			nnccli:      nncClientMock{},
+			store:       ipStateStoreMock{},
+			wantRequest: 20,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
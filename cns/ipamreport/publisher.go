@@ -0,0 +1,143 @@
+// Package ipamreport publishes a summary of the CNS IPAM state to the NodeNetworkConfig status, so that
+// IP health can be seen from Kubernetes without reaching the CNS debug endpoints on the node.
+package ipamreport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig/api/v1alpha"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultInterval is the default minimum time between two reports.
+const DefaultInterval = 30 * time.Second
+
+var ipStates = []types.IPState{types.Available, types.Assigned, types.PendingRelease, types.PendingProgramming}
+
+type nncClient interface {
+	Get(context.Context) (*v1alpha.NodeNetworkConfig, error)
+	PatchIPAMReport(context.Context, *v1alpha.IPAMReport) error
+}
+
+// Sources are the parts of CNS the report is built from.
+type Sources struct {
+	IPConfigs    func() map[string]cns.IPConfigurationStatus
+	NCVersions   func() []v1alpha.NCVersionReport
+	PoolSnapshot func() cns.IpamPoolMonitorStateSnapshot
+}
+
+// Publisher builds an IPAMReport every interval and patches it into the NodeNetworkConfig status when it differs
+// from the report currently in the status.
+type Publisher struct {
+	z        *zap.Logger
+	interval time.Duration
+	src      Sources
+	cli      nncClient
+	now      func() time.Time
+}
+
+// NewPublisher creates a Publisher. An interval of zero uses DefaultInterval.
+func NewPublisher(z *zap.Logger, interval time.Duration, src Sources, cli nncClient) *Publisher {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Publisher{
+		z:        z.With(zap.String("component", "ipam-report")),
+		interval: interval,
+		src:      src,
+		cli:      cli,
+		now:      time.Now,
+	}
+}
+
+// Start publishes the report every interval until the context is closed.
+func (p *Publisher) Start(ctx context.Context) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "ipam report publisher context closed")
+		case <-ticker.C:
+			if err := p.publish(ctx); err != nil {
+				p.z.Error("failed to publish ipam report", zap.Error(err))
+			}
+		}
+	}
+}
+
+// publish patches the report if it differs from the one in the NodeNetworkConfig status. Comparing against the
+// live status, rather than the last report sent, means a report which was lost or overwritten is published again.
+func (p *Publisher) publish(ctx context.Context) error {
+	report := p.build()
+	nnc, err := p.cli.Get(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get nnc")
+	}
+	changed, err := reportChanged(nnc.Status.IPAMReport, report)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return nil
+	}
+	report.UpdatedAt = metav1.NewTime(p.now())
+	if err := p.cli.PatchIPAMReport(ctx, report); err != nil {
+		return errors.Wrap(err, "failed to patch ipam report")
+	}
+	p.z.Debug("published ipam report", zap.Any("ipStates", report.IPStates))
+	return nil
+}
+
+// reportChanged compares the published report with the current one, ignoring UpdatedAt. The serialized forms are
+// compared since that is what is stored, so that sub-second timestamps don't cause churn.
+func reportChanged(published, current *v1alpha.IPAMReport) (bool, error) {
+	if published == nil {
+		return true, nil
+	}
+	published = published.DeepCopy()
+	published.UpdatedAt = metav1.Time{}
+	old, err := json.Marshal(published)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to marshal published ipam report")
+	}
+	cur, err := json.Marshal(current)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to marshal ipam report")
+	}
+	return !bytes.Equal(old, cur), nil
+}
+
+// build summarizes the current IPAM state. UpdatedAt is left unset.
+func (p *Publisher) build() *v1alpha.IPAMReport {
+	report := &v1alpha.IPAMReport{
+		IPStates: make(map[string]int64, len(ipStates)),
+	}
+	for _, state := range ipStates {
+		report.IPStates[string(state)] = 0
+	}
+
+	var oldest time.Time
+	for _, ipConfig := range p.src.IPConfigs() {
+		state := ipConfig.GetState()
+		report.IPStates[string(state)]++
+		if state == types.PendingRelease && !ipConfig.LastStateTransition.IsZero() &&
+			(oldest.IsZero() || ipConfig.LastStateTransition.Before(oldest)) {
+			oldest = ipConfig.LastStateTransition
+		}
+	}
+	if !oldest.IsZero() {
+		report.OldestPendingRelease = &metav1.Time{Time: oldest}
+	}
+
+	report.NetworkContainers = p.src.NCVersions()
+	report.LastScaleEvent = p.src.PoolSnapshot().LastScaleEvent
+	return report
+}
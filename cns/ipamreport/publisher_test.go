@@ -0,0 +1,106 @@
+package ipamreport
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig/api/v1alpha"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// nncMock stores the patched report in the status of its NodeNetworkConfig, like the apiserver.
+type nncMock struct {
+	nnc     v1alpha.NodeNetworkConfig
+	reports []*v1alpha.IPAMReport
+	err     error
+}
+
+func (m *nncMock) Get(context.Context) (*v1alpha.NodeNetworkConfig, error) {
+	return m.nnc.DeepCopy(), nil
+}
+
+func (m *nncMock) PatchIPAMReport(_ context.Context, report *v1alpha.IPAMReport) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.reports = append(m.reports, report)
+	m.nnc.Status.IPAMReport = report.DeepCopy()
+	return nil
+}
+
+func ipConfig(state types.IPState, transition time.Time) cns.IPConfigurationStatus {
+	ip := cns.IPConfigurationStatus{}
+	ip.SetState(state)
+	ip.LastStateTransition = transition
+	return ip
+}
+
+func TestPublish(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	ipConfigs := map[string]cns.IPConfigurationStatus{
+		"a": ipConfig(types.Assigned, now),
+		"b": ipConfig(types.Available, now),
+		"c": ipConfig(types.PendingRelease, now.Add(-time.Minute)),
+		"d": ipConfig(types.PendingRelease, now.Add(-time.Hour)),
+	}
+	scale := &v1alpha.ScaleEvent{Time: metav1.NewTime(now), From: 16, To: 32}
+	ncs := []v1alpha.NCVersionReport{{ID: "nc", Version: 2, HostVersion: 1}}
+	patcher := &nncMock{}
+	p := NewPublisher(zap.NewNop(), 0, Sources{
+		IPConfigs:  func() map[string]cns.IPConfigurationStatus { return ipConfigs },
+		NCVersions: func() []v1alpha.NCVersionReport { return ncs },
+		PoolSnapshot: func() cns.IpamPoolMonitorStateSnapshot {
+			return cns.IpamPoolMonitorStateSnapshot{LastScaleEvent: scale}
+		},
+	}, patcher)
+	p.now = func() time.Time { return now }
+
+	require.NoError(t, p.publish(context.Background()))
+	require.Len(t, patcher.reports, 1)
+	assert.Equal(t, &v1alpha.IPAMReport{
+		UpdatedAt: metav1.NewTime(now),
+		IPStates: map[string]int64{
+			string(types.Available):          1,
+			string(types.Assigned):           1,
+			string(types.PendingRelease):     2,
+			string(types.PendingProgramming): 0,
+		},
+		OldestPendingRelease: &metav1.Time{Time: now.Add(-time.Hour)},
+		NetworkContainers:    ncs,
+		LastScaleEvent:       scale,
+	}, patcher.reports[0])
+
+	// an unchanged report is not published again
+	require.NoError(t, p.publish(context.Background()))
+	assert.Len(t, patcher.reports, 1)
+
+	ncs = []v1alpha.NCVersionReport{{ID: "nc", Version: 2, HostVersion: 2}}
+	require.NoError(t, p.publish(context.Background()))
+	assert.Len(t, patcher.reports, 2)
+
+	// a report which was removed from the status is published again
+	patcher.nnc.Status.IPAMReport = nil
+	require.NoError(t, p.publish(context.Background()))
+	assert.Len(t, patcher.reports, 3)
+}
+
+func TestPublishRetriesAfterFailure(t *testing.T) {
+	patcher := &nncMock{err: errors.New("apiserver unavailable")}
+	p := NewPublisher(zap.NewNop(), 0, Sources{
+		IPConfigs:    func() map[string]cns.IPConfigurationStatus { return nil },
+		NCVersions:   func() []v1alpha.NCVersionReport { return nil },
+		PoolSnapshot: func() cns.IpamPoolMonitorStateSnapshot { return cns.IpamPoolMonitorStateSnapshot{} },
+	}, patcher)
+
+	require.Error(t, p.publish(context.Background()))
+	patcher.err = nil
+	require.NoError(t, p.publish(context.Background()))
+	assert.Len(t, patcher.reports, 1)
+}
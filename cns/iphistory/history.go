@@ -0,0 +1,228 @@
+// Package iphistory keeps a bounded, persisted history of which Pod each IP was assigned to, so that an IP
+// seen in a log or a flow can still be attributed to a Pod after that Pod is gone.
+package iphistory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/store"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultMaxRecordsPerIP is the default number of assignments kept for each IP.
+	DefaultMaxRecordsPerIP = 32
+	// DefaultRetention is the default time a closed assignment is kept for.
+	DefaultRetention = 7 * 24 * time.Hour
+	// DefaultFlushInterval is the default time between two writes of the history to the store.
+	DefaultFlushInterval = 10 * time.Second
+
+	storeKey = "IPHistory"
+)
+
+// Config bounds the history. Zero values use the defaults.
+type Config struct {
+	MaxRecordsPerIP int
+	Retention       time.Duration
+	FlushInterval   time.Duration
+}
+
+// History records the assignments and releases of IPs to Pods.
+// It is safe for concurrent use.
+type History struct {
+	mu      sync.Mutex
+	records map[string][]cns.IPAssignmentRecord // IP address is key, records are oldest first
+	dirty   bool
+	store   store.KeyValueStore
+	cfg     Config
+	onClose func(cns.IPAssignmentRecord)
+	z       *zap.Logger
+	now     func() time.Time
+}
+
+// New creates a History persisted in the passed store and loads any history already in it.
+// onClose, if not nil, is called with every record when its IP is released, for example to export it to telemetry.
+func New(z *zap.Logger, kvs store.KeyValueStore, cfg Config, onClose func(cns.IPAssignmentRecord)) (*History, error) {
+	if cfg.MaxRecordsPerIP <= 0 {
+		cfg.MaxRecordsPerIP = DefaultMaxRecordsPerIP
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = DefaultRetention
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = DefaultFlushInterval
+	}
+	h := &History{
+		records: map[string][]cns.IPAssignmentRecord{},
+		store:   kvs,
+		cfg:     cfg,
+		onClose: onClose,
+		z:       z.With(zap.String("component", "ip-history")),
+		now:     time.Now,
+	}
+	if err := kvs.Read(storeKey, &h.records); err != nil && !errors.Is(err, store.ErrKeyNotFound) && !errors.Is(err, store.ErrStoreEmpty) {
+		return nil, errors.Wrap(err, "failed to read ip history")
+	}
+	return h, nil
+}
+
+// Assigned records that the IP was assigned to the Pod. Assigning the IP to the Pod it is already assigned to,
+// as happens when CNS restores its state after a restart, does not open a new record.
+func (h *History) Assigned(ip string, podInfo cns.PodInfo) {
+	if podInfo == nil {
+		return
+	}
+	h.mu.Lock()
+	now := h.now()
+	records := h.records[ip]
+	var closed []cns.IPAssignmentRecord
+	if n := len(records); n > 0 && records[n-1].End.IsZero() {
+		last := records[n-1]
+		if last.PodName == podInfo.Name() && last.PodNamespace == podInfo.Namespace() && last.ContainerID == podInfo.InfraContainerID() {
+			h.mu.Unlock()
+			return
+		}
+		// the IP was reassigned without a release being seen, so the previous owner held it until now
+		if record, ok := h.close(ip, now); ok {
+			closed = append(closed, record)
+		}
+	}
+	h.records[ip] = append(h.records[ip], cns.IPAssignmentRecord{
+		IPAddress:    ip,
+		PodName:      podInfo.Name(),
+		PodNamespace: podInfo.Namespace(),
+		ContainerID:  podInfo.InfraContainerID(),
+		InterfaceID:  podInfo.InterfaceID(),
+		Start:        now,
+	})
+	h.prune(ip, now)
+	h.dirty = true
+	h.mu.Unlock()
+	h.notify(closed)
+}
+
+// Released records that the IPs are no longer assigned to a Pod.
+func (h *History) Released(ips ...string) {
+	h.mu.Lock()
+	now := h.now()
+	var closed []cns.IPAssignmentRecord
+	for _, ip := range ips {
+		if record, ok := h.close(ip, now); ok {
+			closed = append(closed, record)
+		}
+	}
+	if len(closed) > 0 {
+		h.dirty = true
+	}
+	h.mu.Unlock()
+	h.notify(closed)
+}
+
+// close ends the open record of the IP, if there is one, and returns it. The caller must hold the lock.
+func (h *History) close(ip string, now time.Time) (cns.IPAssignmentRecord, bool) {
+	records := h.records[ip]
+	n := len(records)
+	if n == 0 || !records[n-1].End.IsZero() {
+		return cns.IPAssignmentRecord{}, false
+	}
+	records[n-1].End = now
+	return records[n-1], true
+}
+
+// notify passes the closed records to onClose. It is called without the lock held, since onClose may be slow.
+func (h *History) notify(closed []cns.IPAssignmentRecord) {
+	if h.onClose == nil {
+		return
+	}
+	for i := range closed {
+		h.onClose(closed[i])
+	}
+}
+
+// prune drops the oldest records of the IP beyond the per-IP limit and the closed records older than the
+// retention. The caller must hold the lock.
+func (h *History) prune(ip string, now time.Time) {
+	records := h.records[ip]
+	if over := len(records) - h.cfg.MaxRecordsPerIP; over > 0 {
+		records = records[over:]
+	}
+	cutoff := now.Add(-h.cfg.Retention)
+	i := 0
+	for i < len(records) && !records[i].End.IsZero() && records[i].End.Before(cutoff) {
+		i++
+	}
+	records = records[i:]
+	if len(records) == 0 {
+		delete(h.records, ip)
+		return
+	}
+	h.records[ip] = records
+}
+
+// Query returns the records of the IP that overlap the window from-to, oldest first. An empty IP matches all IPs,
+// and a zero from or to leaves that end of the window open.
+func (h *History) Query(ip string, from, to time.Time) []cns.IPAssignmentRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := []cns.IPAssignmentRecord{}
+	for addr, records := range h.records {
+		if ip != "" && addr != ip {
+			continue
+		}
+		for i := range records {
+			if !to.IsZero() && records[i].Start.After(to) {
+				continue
+			}
+			if !from.IsZero() && !records[i].End.IsZero() && records[i].End.Before(from) {
+				continue
+			}
+			out = append(out, records[i])
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].IPAddress != out[j].IPAddress {
+			return out[i].IPAddress < out[j].IPAddress
+		}
+		return out[i].Start.Before(out[j].Start)
+	})
+	return out
+}
+
+// Flush writes the history to the store if it changed since the last write.
+func (h *History) Flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.dirty {
+		return nil
+	}
+	now := h.now()
+	for ip := range h.records {
+		h.prune(ip, now)
+	}
+	if err := h.store.Write(storeKey, h.records); err != nil {
+		return errors.Wrap(err, "failed to write ip history")
+	}
+	h.dirty = false
+	return nil
+}
+
+// Run flushes the history every flush interval, and a last time when the context is closed.
+func (h *History) Run(ctx context.Context) error {
+	ticker := time.NewTicker(h.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(h.Flush(), "failed to flush ip history on shutdown")
+		case <-ticker.C:
+			if err := h.Flush(); err != nil {
+				h.z.Error("failed to flush ip history", zap.Error(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package iphistory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const testIP = "10.0.0.4"
+
+var t0 = time.Date(2026, 1, 2, 14, 0, 0, 0, time.UTC)
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func newTestHistory(t *testing.T, kvs store.KeyValueStore, cfg Config, onClose func(cns.IPAssignmentRecord)) (*History, *clock) {
+	t.Helper()
+	h, err := New(zap.NewNop(), kvs, cfg, onClose)
+	require.NoError(t, err)
+	c := &clock{t: t0}
+	h.now = c.now
+	return h, c
+}
+
+func record(pod, container string, start, end time.Time) cns.IPAssignmentRecord {
+	return cns.IPAssignmentRecord{
+		IPAddress:    testIP,
+		PodName:      pod,
+		PodNamespace: "default",
+		ContainerID:  container,
+		InterfaceID:  container + "-eth0",
+		Start:        start,
+		End:          end,
+	}
+}
+
+func TestAssignAndRelease(t *testing.T) {
+	var closed []cns.IPAssignmentRecord
+	h, c := newTestHistory(t, store.NewMockStore(""), Config{}, func(r cns.IPAssignmentRecord) { closed = append(closed, r) })
+
+	h.Assigned(testIP, cns.NewPodInfo("c1", "c1-eth0", "pod-a", "default"))
+	// re-assigning to the same pod, as happens on restart, keeps the open record
+	c.t = t0.Add(time.Minute)
+	h.Assigned(testIP, cns.NewPodInfo("c1", "c1-eth0", "pod-a", "default"))
+	c.t = t0.Add(5 * time.Minute)
+	h.Released(testIP)
+	// releasing an IP that is not assigned is a no-op
+	h.Released(testIP)
+	c.t = t0.Add(10 * time.Minute)
+	h.Assigned(testIP, cns.NewPodInfo("c2", "c2-eth0", "pod-b", "default"))
+	// an assignment to another pod without a release closes the previous record
+	c.t = t0.Add(20 * time.Minute)
+	h.Assigned(testIP, cns.NewPodInfo("c3", "c3-eth0", "pod-c", "default"))
+
+	want := []cns.IPAssignmentRecord{
+		record("pod-a", "c1", t0, t0.Add(5*time.Minute)),
+		record("pod-b", "c2", t0.Add(10*time.Minute), t0.Add(20*time.Minute)),
+		record("pod-c", "c3", t0.Add(20*time.Minute), time.Time{}),
+	}
+	assert.Equal(t, want, h.Query(testIP, time.Time{}, time.Time{}))
+	assert.Equal(t, want[:2], closed)
+}
+
+func TestOnCloseIsCalledWithoutTheLock(t *testing.T) {
+	var h *History
+	var queried []cns.IPAssignmentRecord
+	// onClose reads the history, which would deadlock if it was called with the lock held
+	h, c := newTestHistory(t, store.NewMockStore(""), Config{}, func(cns.IPAssignmentRecord) {
+		queried = h.Query("", time.Time{}, time.Time{})
+	})
+	h.Assigned(testIP, cns.NewPodInfo("c1", "c1-eth0", "pod-a", "default"))
+	h.Assigned("10.0.0.5", cns.NewPodInfo("c2", "c2-eth0", "pod-b", "default"))
+	c.t = t0.Add(time.Minute)
+	h.Released(testIP, "10.0.0.5")
+
+	require.Len(t, queried, 2)
+	for _, r := range queried {
+		assert.Equal(t, t0.Add(time.Minute), r.End)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	h, c := newTestHistory(t, store.NewMockStore(""), Config{}, nil)
+	h.Assigned(testIP, cns.NewPodInfo("c1", "c1-eth0", "pod-a", "default"))
+	c.t = t0.Add(5 * time.Minute)
+	h.Released(testIP)
+	c.t = t0.Add(10 * time.Minute)
+	h.Assigned(testIP, cns.NewPodInfo("c2", "c2-eth0", "pod-b", "default"))
+	h.Assigned("10.0.0.5", cns.NewPodInfo("c3", "c3-eth0", "pod-c", "default"))
+
+	podA := record("pod-a", "c1", t0, t0.Add(5*time.Minute))
+	podB := record("pod-b", "c2", t0.Add(10*time.Minute), time.Time{})
+	tests := []struct {
+		name     string
+		ip       string
+		from, to time.Time
+		want     []cns.IPAssignmentRecord
+	}{
+		{
+			name: "at an instant",
+			ip:   testIP,
+			from: t0.Add(2 * time.Minute),
+			to:   t0.Add(2 * time.Minute),
+			want: []cns.IPAssignmentRecord{podA},
+		},
+		{
+			name: "while unassigned",
+			ip:   testIP,
+			from: t0.Add(7 * time.Minute),
+			to:   t0.Add(8 * time.Minute),
+			want: []cns.IPAssignmentRecord{},
+		},
+		{
+			name: "window overlapping both",
+			ip:   testIP,
+			from: t0.Add(4 * time.Minute),
+			to:   t0.Add(11 * time.Minute),
+			want: []cns.IPAssignmentRecord{podA, podB},
+		},
+		{
+			name: "open ended window includes the current assignment",
+			ip:   testIP,
+			from: t0.Add(time.Hour),
+			want: []cns.IPAssignmentRecord{podB},
+		},
+		{
+			name: "unknown ip",
+			ip:   "10.0.0.6",
+			want: []cns.IPAssignmentRecord{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, h.Query(tt.ip, tt.from, tt.to))
+		})
+	}
+
+	assert.Len(t, h.Query("", time.Time{}, time.Time{}), 3)
+}
+
+func TestBounds(t *testing.T) {
+	h, c := newTestHistory(t, store.NewMockStore(""), Config{MaxRecordsPerIP: 2, Retention: time.Hour}, nil)
+	for i, pod := range []string{"pod-a", "pod-b", "pod-c"} {
+		c.t = t0.Add(time.Duration(i) * time.Minute)
+		h.Assigned(testIP, cns.NewPodInfo(pod, pod+"-eth0", pod, "default"))
+	}
+	records := h.Query(testIP, time.Time{}, time.Time{})
+	require.Len(t, records, 2)
+	assert.Equal(t, "pod-b", records[0].PodName)
+
+	// closed records past the retention are dropped, the open one is kept however old it is
+	c.t = t0.Add(2 * time.Hour)
+	h.Assigned("10.0.0.5", cns.NewPodInfo("c", "c-eth0", "pod-d", "default"))
+	require.NoError(t, h.Flush())
+	records = h.Query(testIP, time.Time{}, time.Time{})
+	require.Len(t, records, 1)
+	assert.Equal(t, "pod-c", records[0].PodName)
+}
+
+func TestPersistence(t *testing.T) {
+	kvs := store.NewMockStore("")
+	h, c := newTestHistory(t, kvs, Config{}, nil)
+	h.Assigned(testIP, cns.NewPodInfo("c1", "c1-eth0", "pod-a", "default"))
+	c.t = t0.Add(time.Minute)
+	h.Released(testIP)
+	require.NoError(t, h.Flush())
+
+	restored, _ := newTestHistory(t, kvs, Config{}, nil)
+	assert.Equal(t, []cns.IPAssignmentRecord{record("pod-a", "c1", t0, t0.Add(time.Minute))}, restored.Query(testIP, time.Time{}, time.Time{}))
+}
@@ -0,0 +1,25 @@
+package iphistory
+
+import (
+	"time"
+
+	"github.com/Azure/azure-container-networking/aitelemetry"
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/logger"
+)
+
+// LogRecord sends a closed assignment record to App Insights telemetry. It can be passed to New to export the
+// history off the node.
+func LogRecord(record cns.IPAssignmentRecord) {
+	logger.LogEvent(aitelemetry.Event{
+		EventName:  logger.CnsIPAssignmentEventStr,
+		ResourceID: record.IPAddress,
+		Properties: map[string]string{
+			logger.PodNameStr:      record.PodName,
+			logger.PodNamespaceStr: record.PodNamespace,
+			logger.ContainerIDStr:  record.ContainerID,
+			logger.AssignedAtStr:   record.Start.UTC().Format(time.RFC3339),
+			logger.ReleasedAtStr:   record.End.UTC().Format(time.RFC3339),
+		},
+	})
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig/api/v1alpha"
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -191,13 +192,7 @@ func (r *Reconciler) SetupWithManager(mgr ctrl.Manager, node *v1.Node, filterGen
 				return false
 			},
 			UpdateFunc: func(ue event.UpdateEvent) bool {
-				if ue.ObjectOld == nil || ue.ObjectNew == nil {
-					return false
-				}
-				if filterGenerationChange {
-					return ue.ObjectOld.GetGeneration() == ue.ObjectNew.GetGeneration()
-				}
-				return true
+				return updateFilter(ue, filterGenerationChange)
 			},
 		}).
 		WithEventFilter(predicate.NewPredicateFuncs(func(object client.Object) bool {
@@ -214,3 +209,39 @@ func (r *Reconciler) SetupWithManager(mgr ctrl.Manager, node *v1.Node, filterGen
 	}
 	return nil
 }
+
+// updateFilter decides whether an update event should be reconciled. Updates which only change the IPAMReport
+// are ignored, since the report is published by CNS itself and reconciling on it would loop.
+func updateFilter(ue event.UpdateEvent, filterGenerationChange bool) bool {
+	if ue.ObjectOld == nil || ue.ObjectNew == nil {
+		return false
+	}
+	if ipamReportOnlyUpdate(ue.ObjectOld, ue.ObjectNew) {
+		return false
+	}
+	if filterGenerationChange {
+		return ue.ObjectOld.GetGeneration() == ue.ObjectNew.GetGeneration()
+	}
+	return true
+}
+
+// ipamReportOnlyUpdate returns true if the IPAMReport is the only part of the NodeNetworkConfig that changed.
+func ipamReportOnlyUpdate(oldObj, newObj client.Object) bool {
+	oldNNC, ok := oldObj.(*v1alpha.NodeNetworkConfig)
+	if !ok {
+		return false
+	}
+	newNNC, ok := newObj.(*v1alpha.NodeNetworkConfig)
+	if !ok {
+		return false
+	}
+	if oldNNC.GetGeneration() != newNNC.GetGeneration() {
+		return false
+	}
+	if equality.Semantic.DeepEqual(oldNNC.Status.IPAMReport, newNNC.Status.IPAMReport) {
+		return false
+	}
+	oldStatus, newStatus := oldNNC.Status.DeepCopy(), newNNC.Status.DeepCopy()
+	oldStatus.IPAMReport, newStatus.IPAMReport = nil, nil
+	return equality.Semantic.DeepEqual(oldStatus, newStatus)
+}
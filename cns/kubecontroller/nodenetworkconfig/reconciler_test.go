@@ -12,8 +12,10 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -339,3 +341,81 @@ func TestReconcileInitializerRetriesAfterFailure(t *testing.T) {
 	assert.Equal(t, 1, createCalls)
 	assert.Nil(t, r.initializer)
 }
+
+func TestUpdateFilter(t *testing.T) {
+	nnc := func(generation int64, assigned int, report *v1alpha.IPAMReport) *v1alpha.NodeNetworkConfig {
+		return &v1alpha.NodeNetworkConfig{
+			ObjectMeta: metav1.ObjectMeta{Generation: generation},
+			Status: v1alpha.NodeNetworkConfigStatus{
+				AssignedIPCount: assigned,
+				IPAMReport:      report,
+			},
+		}
+	}
+	report := &v1alpha.IPAMReport{IPStates: map[string]int64{string(cnstypes.Assigned): 1}}
+	changedReport := &v1alpha.IPAMReport{IPStates: map[string]int64{string(cnstypes.Assigned): 2}}
+
+	tests := []struct {
+		name                   string
+		old, new               *v1alpha.NodeNetworkConfig
+		filterGenerationChange bool
+		want                   bool
+	}{
+		{
+			name: "status change is reconciled",
+			old:  nnc(1, 1, report),
+			new:  nnc(1, 2, report),
+			want: true,
+		},
+		{
+			name: "ipam report only change is ignored",
+			old:  nnc(1, 1, report),
+			new:  nnc(1, 1, changedReport),
+			want: false,
+		},
+		{
+			name: "first ipam report is ignored",
+			old:  nnc(1, 1, nil),
+			new:  nnc(1, 1, report),
+			want: false,
+		},
+		{
+			name:                   "ipam report only change is ignored when filtering generation changes",
+			old:                    nnc(1, 1, report),
+			new:                    nnc(1, 1, changedReport),
+			filterGenerationChange: true,
+			want:                   false,
+		},
+		{
+			name: "ipam report and status change is reconciled",
+			old:  nnc(1, 1, report),
+			new:  nnc(1, 2, changedReport),
+			want: true,
+		},
+		{
+			name: "spec change is reconciled",
+			old:  nnc(1, 1, report),
+			new:  nnc(2, 1, changedReport),
+			want: true,
+		},
+		{
+			name:                   "spec change is ignored when filtering generation changes",
+			old:                    nnc(1, 1, report),
+			new:                    nnc(2, 1, report),
+			filterGenerationChange: true,
+			want:                   false,
+		},
+		{
+			name: "unchanged object is reconciled",
+			old:  nnc(1, 1, report),
+			new:  nnc(1, 1, report),
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ue := event.UpdateEvent{ObjectOld: tt.old, ObjectNew: tt.new}
+			assert.Equal(t, tt.want, updateFilter(ue, tt.filterGenerationChange))
+		})
+	}
+}
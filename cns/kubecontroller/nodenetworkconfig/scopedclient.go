@@ -34,3 +34,9 @@ func (sc *ScopedClient) PatchSpec(ctx context.Context, spec *v1alpha.NodeNetwork
 	nnc, err := sc.Client.PatchSpec(ctx, sc.NamespacedName, spec, fieldManager)
 	return nnc, errors.Wrapf(err, "failed to patch nnc %v", sc.NamespacedName)
 }
+
+// PatchIPAMReport updates the IPAMReport in the status of the associated NodeNetworkConfig.
+func (sc *ScopedClient) PatchIPAMReport(ctx context.Context, report *v1alpha.IPAMReport) error {
+	err := sc.Client.PatchIPAMReport(ctx, sc.NamespacedName, report)
+	return errors.Wrapf(err, "failed to patch nnc %v ipam report", sc.NamespacedName)
+}
@@ -0,0 +1,145 @@
+package overlayextensionconfig
+
+import (
+	"net"
+
+	"github.com/Azure/azure-container-networking/iptables"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// routeProtocol marks the routes owned by the reconciler so that they can be found again after a restart.
+	routeProtocol = 0xac
+	// forwardChain accepts forwarded traffic of the extension CIDRs. FORWARD jumps to it from its end, after the
+	// chains of NPM and kube-proxy which insert their jumps at the start, so network policies still apply.
+	forwardChain = "AZURECNIOECFORWARD"
+)
+
+var errNoDefaultRoute = errors.New("no default route")
+
+type ipTablesClient interface {
+	ApplyBatch(*iptables.Batch) error
+}
+
+// linuxDataplane routes the extension CIDR through the node's default gateway, accepts its forwarded traffic
+// which network policies allow and exempts it from SNAT, so that pod IPs are seen unmodified by the extension.
+type linuxDataplane struct {
+	nl  netlink.NetlinkInterface
+	ipt ipTablesClient
+}
+
+func newDataplane() dataplane {
+	return &linuxDataplane{
+		nl:  netlink.NewNetlink(),
+		ipt: iptables.NewClient(),
+	}
+}
+
+func (d *linuxDataplane) Program(cidr *net.IPNet) error {
+	if err := d.addRoute(cidr); err != nil {
+		return err
+	}
+	version, match := ipTablesVersion(cidr), cidr.String()
+	batch := iptables.NewBatch().
+		CreateChain(version, iptables.Filter, forwardChain).
+		AppendRule(version, iptables.Filter, iptables.Forward, "", forwardChain).
+		InsertRule(version, iptables.Filter, forwardChain, "-s "+match, iptables.Accept).
+		InsertRule(version, iptables.Filter, forwardChain, "-d "+match, iptables.Accept).
+		InsertRule(version, iptables.Nat, iptables.Postrouting, "-d "+match, iptables.Return)
+	return errors.Wrapf(d.ipt.ApplyBatch(batch), "failed to program iptables rules for %s", match)
+}
+
+func (d *linuxDataplane) Remove(cidr *net.IPNet) error {
+	version, match := ipTablesVersion(cidr), cidr.String()
+	batch := iptables.NewBatch().
+		DeleteRule(version, iptables.Filter, forwardChain, "-s "+match, iptables.Accept).
+		DeleteRule(version, iptables.Filter, forwardChain, "-d "+match, iptables.Accept).
+		DeleteRule(version, iptables.Nat, iptables.Postrouting, "-d "+match, iptables.Return)
+	if err := d.ipt.ApplyBatch(batch); err != nil {
+		return errors.Wrapf(err, "failed to remove iptables rules for %s", match)
+	}
+
+	routes, err := d.nl.GetIPRoute(&netlink.Route{Family: family(cidr), Dst: cidr, Protocol: routeProtocol})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get route for %s", match)
+	}
+	for _, route := range routes {
+		if err := d.nl.DeleteIPRoute(route); err != nil {
+			return errors.Wrapf(err, "failed to delete route for %s", match)
+		}
+	}
+	return nil
+}
+
+func (d *linuxDataplane) Programmed() ([]*net.IPNet, error) {
+	var cidrs []*net.IPNet
+	for _, f := range []int{unix.AF_INET, unix.AF_INET6} {
+		routes, err := d.nl.GetIPRoute(&netlink.Route{Family: f, Protocol: routeProtocol})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get oec routes")
+		}
+		for _, route := range routes {
+			if route.Dst != nil {
+				cidrs = append(cidrs, route.Dst)
+			}
+		}
+	}
+	return cidrs, nil
+}
+
+// addRoute routes the CIDR through the default gateway unless the main table already has a route for it.
+func (d *linuxDataplane) addRoute(cidr *net.IPNet) error {
+	existing, err := d.nl.GetIPRoute(&netlink.Route{Family: family(cidr), Dst: cidr})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get route for %s", cidr.String())
+	}
+	if len(existing) > 0 {
+		return nil
+	}
+
+	routes, err := d.nl.GetIPRoute(&netlink.Route{Family: family(cidr)})
+	if err != nil {
+		return errors.Wrap(err, "failed to get routes")
+	}
+	for _, route := range routes {
+		if !isDefault(route) {
+			continue
+		}
+		err := d.nl.AddIPRoute(&netlink.Route{
+			Family:    family(cidr),
+			Dst:       cidr,
+			Gw:        route.Gw,
+			LinkIndex: route.LinkIndex,
+			Protocol:  routeProtocol,
+		})
+		return errors.Wrapf(err, "failed to add route for %s", cidr.String())
+	}
+	return errors.Wrapf(errNoDefaultRoute, "failed to add route for %s", cidr.String())
+}
+
+func isDefault(route *netlink.Route) bool {
+	if route.Gw == nil {
+		return false
+	}
+	if route.Dst == nil {
+		return true
+	}
+	ones, _ := route.Dst.Mask.Size()
+	return ones == 0
+}
+
+func family(cidr *net.IPNet) int {
+	if cidr.IP.To4() != nil {
+		return unix.AF_INET
+	}
+	return unix.AF_INET6
+}
+
+func ipTablesVersion(cidr *net.IPNet) string {
+	if cidr.IP.To4() != nil {
+		return iptables.V4
+	}
+	return iptables.V6
+}
@@ -0,0 +1,100 @@
+package overlayextensionconfig
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/iptables"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+type fakeRouteNetlink struct {
+	*netlink.MockNetlink
+	routes []*netlink.Route
+}
+
+func (f *fakeRouteNetlink) GetIPRoute(filter *netlink.Route) ([]*netlink.Route, error) {
+	var out []*netlink.Route
+	for _, r := range f.routes {
+		if r.Family != filter.Family {
+			continue
+		}
+		if filter.Protocol != 0 && filter.Protocol != r.Protocol {
+			continue
+		}
+		if filter.Dst != nil && (r.Dst == nil || r.Dst.String() != filter.Dst.String()) {
+			continue
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func (f *fakeRouteNetlink) AddIPRoute(r *netlink.Route) error {
+	f.routes = append(f.routes, r)
+	return nil
+}
+
+func (f *fakeRouteNetlink) DeleteIPRoute(r *netlink.Route) error {
+	for i := range f.routes {
+		if f.routes[i] == r {
+			f.routes = append(f.routes[:i], f.routes[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func TestLinuxDataplane(t *testing.T) {
+	gw := net.ParseIP("10.224.0.1")
+	nl := &fakeRouteNetlink{
+		MockNetlink: netlink.NewMockNetlink(false, ""),
+		routes:      []*netlink.Route{{Family: unix.AF_INET, Gw: gw, LinkIndex: 2}},
+	}
+	ipt := iptables.NewMockClient(false)
+	d := &linuxDataplane{nl: nl, ipt: ipt}
+	_, cidr, _ := net.ParseCIDR("10.1.0.0/24")
+
+	require.NoError(t, d.Program(cidr))
+	assert.Equal(t, []iptables.BatchOp{
+		{Version: iptables.V4, Table: iptables.Filter, Chain: forwardChain, Action: "N"},
+		{Version: iptables.V4, Table: iptables.Filter, Chain: iptables.Forward, Action: iptables.Append, Target: forwardChain},
+		{Version: iptables.V4, Table: iptables.Filter, Chain: forwardChain, Action: iptables.Insert, Match: "-s 10.1.0.0/24", Target: iptables.Accept},
+		{Version: iptables.V4, Table: iptables.Filter, Chain: forwardChain, Action: iptables.Insert, Match: "-d 10.1.0.0/24", Target: iptables.Accept},
+		{Version: iptables.V4, Table: iptables.Nat, Chain: iptables.Postrouting, Action: iptables.Insert, Match: "-d 10.1.0.0/24", Target: iptables.Return},
+	}, ipt.Ops)
+	require.Len(t, nl.routes, 2)
+	assert.Equal(t, &netlink.Route{Family: unix.AF_INET, Dst: cidr, Gw: gw, LinkIndex: 2, Protocol: routeProtocol}, nl.routes[1])
+
+	programmed, err := d.Programmed()
+	require.NoError(t, err)
+	assert.Equal(t, []*net.IPNet{cidr}, programmed)
+
+	// programming again does not add a second route
+	require.NoError(t, d.Program(cidr))
+	assert.Len(t, nl.routes, 2)
+
+	ipt.Ops = nil
+	require.NoError(t, d.Remove(cidr))
+	assert.Equal(t, []iptables.BatchOp{
+		{Version: iptables.V4, Table: iptables.Filter, Chain: forwardChain, Action: iptables.Delete, Match: "-s 10.1.0.0/24", Target: iptables.Accept},
+		{Version: iptables.V4, Table: iptables.Filter, Chain: forwardChain, Action: iptables.Delete, Match: "-d 10.1.0.0/24", Target: iptables.Accept},
+		{Version: iptables.V4, Table: iptables.Nat, Chain: iptables.Postrouting, Action: iptables.Delete, Match: "-d 10.1.0.0/24", Target: iptables.Return},
+	}, ipt.Ops, "the chain and its jump are shared by every extension CIDR and stay")
+	assert.Len(t, nl.routes, 1)
+	programmed, err = d.Programmed()
+	require.NoError(t, err)
+	assert.Empty(t, programmed)
+}
+
+func TestLinuxDataplaneNoDefaultRoute(t *testing.T) {
+	d := &linuxDataplane{
+		nl:  &fakeRouteNetlink{MockNetlink: netlink.NewMockNetlink(false, "")},
+		ipt: iptables.NewMockClient(false),
+	}
+	_, cidr, _ := net.ParseCIDR("10.1.0.0/24")
+	require.ErrorIs(t, d.Program(cidr), errNoDefaultRoute)
+}
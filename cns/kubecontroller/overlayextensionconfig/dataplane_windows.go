@@ -0,0 +1,27 @@
+package overlayextensionconfig
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+var errUnsupported = errors.New("overlay extension config is not supported on windows")
+
+type unsupportedDataplane struct{}
+
+func newDataplane() dataplane {
+	return unsupportedDataplane{}
+}
+
+func (unsupportedDataplane) Program(*net.IPNet) error {
+	return errUnsupported
+}
+
+func (unsupportedDataplane) Remove(*net.IPNet) error {
+	return nil
+}
+
+func (unsupportedDataplane) Programmed() ([]*net.IPNet, error) {
+	return nil, nil
+}
@@ -0,0 +1,35 @@
+package overlayextensionconfig
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	resultLabel     = "result"
+	resultSucceeded = "succeeded"
+	resultFailed    = "failed"
+)
+
+var (
+	oecReconcilerCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "overlay_extension_config_reconciler_total",
+			Help: "Number of OverlayExtensionConfig reconciles by result",
+		},
+		[]string{resultLabel},
+	)
+	oecProgrammedCount = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "overlay_extension_config_programmed",
+			Help: "Number of OverlayExtensionConfigs programmed on the node",
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		oecReconcilerCount,
+		oecProgrammedCount,
+	)
+}
@@ -0,0 +1,227 @@
+// Package overlayextensionconfig reconciles OverlayExtensionConfigs into node routing so that the
+// ExtensionIPRange (for example an App Gateway for Containers subnet) can reach overlay pod IPs directly.
+package overlayextensionconfig
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/Azure/azure-container-networking/crd/overlayextensionconfig"
+	"github.com/Azure/azure-container-networking/crd/overlayextensionconfig/api/v1alpha1"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// staleRetryInterval is how soon the removal of stale programming is retried after it failed.
+const staleRetryInterval = 30 * time.Second
+
+type oecClient interface {
+	Get(context.Context, types.NamespacedName) (*v1alpha1.OverlayExtensionConfig, error)
+	List(context.Context) ([]v1alpha1.OverlayExtensionConfig, error)
+	UpdateNodeStatus(context.Context, types.NamespacedName, v1alpha1.OECNodeStatus, map[string]struct{}) error
+}
+
+// nodeLister lists the nodes of the cluster, so that the results of nodes which have left are dropped from the status.
+type nodeLister interface {
+	NodeNames(context.Context) (map[string]struct{}, error)
+}
+
+// cachedNodeLister lists the nodes from the metadata informer of the manager cache. Every reconcile lists the
+// nodes, so they are served from a cache of their metadata rather than by a List of the API server from every CNS.
+type cachedNodeLister struct {
+	reader client.Reader
+}
+
+func (l *cachedNodeLister) NodeNames(ctx context.Context) (map[string]struct{}, error) {
+	nodes := &metav1.PartialObjectMetadataList{}
+	nodes.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("NodeList"))
+	if err := l.reader.List(ctx, nodes); err != nil {
+		return nil, errors.Wrap(err, "failed to list nodes")
+	}
+	names := make(map[string]struct{}, len(nodes.Items))
+	for i := range nodes.Items {
+		names[nodes.Items[i].Name] = struct{}{}
+	}
+	return names, nil
+}
+
+// dataplane programs the node so that traffic from an extension CIDR can reach pod IPs.
+type dataplane interface {
+	// Program installs the route and iptables exemptions for the CIDR. It is idempotent.
+	Program(*net.IPNet) error
+	// Remove deletes the route and iptables exemptions for the CIDR. It is idempotent.
+	Remove(*net.IPNet) error
+	// Programmed returns the CIDRs currently programmed on the node, including any left over from a previous run.
+	Programmed() ([]*net.IPNet, error)
+}
+
+// Reconciler programs every OverlayExtensionConfig on this node and reports the result in the
+// OverlayExtensionConfig's status under the node's name.
+type Reconciler struct {
+	cli        oecClient
+	nodes      nodeLister
+	dp         dataplane
+	nodeName   string
+	z          *zap.Logger
+	programmed map[types.NamespacedName]*net.IPNet
+	// staleRemoved is set once the programming left by OverlayExtensionConfigs deleted while CNS was down is removed
+	staleRemoved bool
+}
+
+// New creates a Reconciler for the node.
+func New(nodeName string, z *zap.Logger) *Reconciler {
+	return &Reconciler{
+		dp:         newDataplane(),
+		nodeName:   nodeName,
+		z:          z.With(zap.String("component", "oec-reconciler")),
+		programmed: map[types.NamespacedName]*net.IPNet{},
+	}
+}
+
+// Reconcile programs the OverlayExtensionConfig on the node, or removes the programming if it has been deleted.
+// Reconciles are serialized by controller-runtime, so the programmed map is not locked.
+func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	// the first reconcile runs after the cache has synced, so it is the earliest point at which
+	// programming for OverlayExtensionConfigs deleted while CNS was down can be told apart
+	if !r.staleRemoved {
+		if err := r.removeStale(ctx); err != nil {
+			r.z.Error("failed to remove stale oec programming", zap.Error(err))
+		} else {
+			r.staleRemoved = true
+		}
+	}
+
+	result, err := r.reconcile(ctx, req)
+	if err == nil && !r.staleRemoved {
+		// requeue until the stale programming is removed, errors are requeued already
+		result.RequeueAfter = staleRetryInterval
+	}
+	return result, err
+}
+
+func (r *Reconciler) reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	oec, err := r.cli.Get(ctx, req.NamespacedName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return reconcile.Result{}, r.remove(req.NamespacedName)
+		}
+		oecReconcilerCount.WithLabelValues(resultFailed).Inc()
+		return reconcile.Result{}, errors.Wrapf(err, "failed to get oec %s", req.String())
+	}
+	if !oec.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, r.remove(req.NamespacedName)
+	}
+
+	_, cidr, err := net.ParseCIDR(oec.Spec.ExtensionIPRange)
+	if err != nil {
+		// the spec is immutable, so retrying will not help until the OverlayExtensionConfig is recreated
+		oecReconcilerCount.WithLabelValues(resultFailed).Inc()
+		return reconcile.Result{}, r.updateNodeStatus(ctx, req.NamespacedName, v1alpha1.Failed, "invalid extensionIPRange "+oec.Spec.ExtensionIPRange)
+	}
+
+	if prev, ok := r.programmed[req.NamespacedName]; ok && prev.String() != cidr.String() {
+		if err := r.remove(req.NamespacedName); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	if err := r.dp.Program(cidr); err != nil {
+		oecReconcilerCount.WithLabelValues(resultFailed).Inc()
+		if statusErr := r.updateNodeStatus(ctx, req.NamespacedName, v1alpha1.Failed, err.Error()); statusErr != nil {
+			r.z.Error("failed to report oec programming failure", zap.String("oec", req.String()), zap.Error(statusErr))
+		}
+		return reconcile.Result{}, errors.Wrapf(err, "failed to program oec %s", req.String())
+	}
+	r.programmed[req.NamespacedName] = cidr
+	r.z.Info("programmed oec", zap.String("oec", req.String()), zap.String("cidr", cidr.String()))
+	oecReconcilerCount.WithLabelValues(resultSucceeded).Inc()
+	oecProgrammedCount.Set(float64(len(r.programmed)))
+	return reconcile.Result{}, r.updateNodeStatus(ctx, req.NamespacedName, v1alpha1.Succeeded, "")
+}
+
+// remove deletes the programming of a deleted OverlayExtensionConfig, unless another OverlayExtensionConfig
+// still uses the same CIDR.
+func (r *Reconciler) remove(key types.NamespacedName) error {
+	cidr, ok := r.programmed[key]
+	if !ok {
+		return nil
+	}
+	delete(r.programmed, key)
+	oecProgrammedCount.Set(float64(len(r.programmed)))
+	for _, other := range r.programmed {
+		if other.String() == cidr.String() {
+			return nil
+		}
+	}
+	if err := r.dp.Remove(cidr); err != nil {
+		// keep tracking the CIDR so the next reconcile retries the removal
+		r.programmed[key] = cidr
+		oecReconcilerCount.WithLabelValues(resultFailed).Inc()
+		return errors.Wrapf(err, "failed to remove oec %s programming", key.String())
+	}
+	r.z.Info("removed oec programming", zap.String("oec", key.String()), zap.String("cidr", cidr.String()))
+	return nil
+}
+
+// removeStale deletes programming left behind by OverlayExtensionConfigs that were deleted while CNS was not running.
+func (r *Reconciler) removeStale(ctx context.Context) error {
+	oecs, err := r.cli.List(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to list oecs")
+	}
+	want := map[string]struct{}{}
+	for i := range oecs {
+		if _, cidr, err := net.ParseCIDR(oecs[i].Spec.ExtensionIPRange); err == nil {
+			want[cidr.String()] = struct{}{}
+		}
+	}
+	programmed, err := r.dp.Programmed()
+	if err != nil {
+		return errors.Wrap(err, "failed to list programmed oec cidrs")
+	}
+	for _, cidr := range programmed {
+		if _, ok := want[cidr.String()]; ok {
+			continue
+		}
+		if err := r.dp.Remove(cidr); err != nil {
+			return errors.Wrapf(err, "failed to remove stale oec cidr %s", cidr.String())
+		}
+		r.z.Info("removed stale oec programming", zap.String("cidr", cidr.String()))
+	}
+	return nil
+}
+
+// updateNodeStatus writes the result of the reconcile. It is written once per reconcile, as every write is an
+// update of the OverlayExtensionConfig which all nodes share.
+func (r *Reconciler) updateNodeStatus(ctx context.Context, key types.NamespacedName, state v1alpha1.OECState, msg string) error {
+	nodes, err := r.nodes.NodeNames(ctx)
+	if err != nil {
+		// keep the results of every node rather than fail the reconcile over the cleanup of others
+		r.z.Error("failed to list nodes, not dropping the status of nodes which left", zap.Error(err))
+		nodes = nil
+	}
+	err = r.cli.UpdateNodeStatus(ctx, key, v1alpha1.OECNodeStatus{NodeName: r.nodeName, State: state, Message: msg}, nodes)
+	return errors.Wrap(err, "failed to update oec node status")
+}
+
+// SetupWithManager sets up the reconciler with the manager.
+func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.cli = overlayextensionconfig.NewClient(mgr.GetClient())
+	// a metadata-only List through the cached client starts a metadata informer of the nodes
+	r.nodes = &cachedNodeLister{reader: mgr.GetClient()}
+	// status updates, of this and every other node, do not change the generation and need no reconcile
+	err := ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.OverlayExtensionConfig{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+	return errors.Wrap(err, "failed to setup overlayextensionconfig reconciler with manager")
+}
@@ -0,0 +1,280 @@
+package overlayextensionconfig
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/crd/overlayextensionconfig"
+	"github.com/Azure/azure-container-networking/crd/overlayextensionconfig/api/v1alpha1"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const testNode = "node-a"
+
+var testKey = types.NamespacedName{Namespace: "default", Name: "agc"}
+
+type fakeOECClient struct {
+	oecs map[types.NamespacedName]*v1alpha1.OverlayExtensionConfig
+	// statusUpdates counts the calls to UpdateNodeStatus
+	statusUpdates int
+	listErr       error
+}
+
+func (f *fakeOECClient) Get(_ context.Context, key types.NamespacedName) (*v1alpha1.OverlayExtensionConfig, error) {
+	oec, ok := f.oecs[key]
+	if !ok {
+		return nil, errors.Wrap(apierrors.NewNotFound(schema.GroupResource{}, key.Name), "failed to get oec")
+	}
+	return oec.DeepCopy(), nil
+}
+
+func (f *fakeOECClient) List(context.Context) ([]v1alpha1.OverlayExtensionConfig, error) {
+	if f.listErr != nil {
+		return nil, f.listErr
+	}
+	out := []v1alpha1.OverlayExtensionConfig{}
+	for _, oec := range f.oecs {
+		out = append(out, *oec)
+	}
+	return out, nil
+}
+
+func (f *fakeOECClient) UpdateNodeStatus(_ context.Context, key types.NamespacedName, nodeStatus v1alpha1.OECNodeStatus, nodes map[string]struct{}) error {
+	f.statusUpdates++
+	oec := f.oecs[key]
+	oec.Status = overlayextensionconfig.SetNodeStatus(oec.Status, nodeStatus, nodes)
+	return nil
+}
+
+type fakeNodeLister struct {
+	nodes map[string]struct{}
+	err   error
+}
+
+func (f *fakeNodeLister) NodeNames(context.Context) (map[string]struct{}, error) {
+	return f.nodes, f.err
+}
+
+type fakeDataplane struct {
+	programmed map[string]bool
+	programErr error
+}
+
+func (f *fakeDataplane) Program(cidr *net.IPNet) error {
+	if f.programErr != nil {
+		return f.programErr
+	}
+	f.programmed[cidr.String()] = true
+	return nil
+}
+
+func (f *fakeDataplane) Remove(cidr *net.IPNet) error {
+	delete(f.programmed, cidr.String())
+	return nil
+}
+
+func (f *fakeDataplane) Programmed() ([]*net.IPNet, error) {
+	out := []*net.IPNet{}
+	for c := range f.programmed {
+		_, cidr, _ := net.ParseCIDR(c)
+		out = append(out, cidr)
+	}
+	return out, nil
+}
+
+func newTestReconciler(cli *fakeOECClient, dp *fakeDataplane) *Reconciler {
+	r := New(testNode, zap.NewNop())
+	r.cli = cli
+	r.dp = dp
+	r.nodes = &fakeNodeLister{nodes: map[string]struct{}{testNode: {}}}
+	return r
+}
+
+func newOEC(cidr string) *v1alpha1.OverlayExtensionConfig {
+	return &v1alpha1.OverlayExtensionConfig{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testKey.Namespace, Name: testKey.Name},
+		Spec:       v1alpha1.OverlayExtensionConfigSpec{ExtensionIPRange: cidr},
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	tests := []struct {
+		name           string
+		oec            *v1alpha1.OverlayExtensionConfig
+		programErr     error
+		wantErr        bool
+		wantProgrammed map[string]bool
+		wantStatus     v1alpha1.OECNodeStatus
+	}{
+		{
+			name:           "programs the extension range",
+			oec:            newOEC("10.1.0.0/24"),
+			wantProgrammed: map[string]bool{"10.1.0.0/24": true},
+			wantStatus:     v1alpha1.OECNodeStatus{NodeName: testNode, State: v1alpha1.Succeeded},
+		},
+		{
+			name:           "invalid extension range",
+			oec:            newOEC("10.1.0.0"),
+			wantProgrammed: map[string]bool{},
+			wantStatus:     v1alpha1.OECNodeStatus{NodeName: testNode, State: v1alpha1.Failed, Message: "invalid extensionIPRange 10.1.0.0"},
+		},
+		{
+			name:           "programming fails",
+			oec:            newOEC("10.1.0.0/24"),
+			programErr:     errors.New("no default route"),
+			wantErr:        true,
+			wantProgrammed: map[string]bool{},
+			wantStatus:     v1alpha1.OECNodeStatus{NodeName: testNode, State: v1alpha1.Failed, Message: "no default route"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := &fakeOECClient{oecs: map[types.NamespacedName]*v1alpha1.OverlayExtensionConfig{testKey: tt.oec}}
+			dp := &fakeDataplane{programmed: map[string]bool{}, programErr: tt.programErr}
+			r := newTestReconciler(cli, dp)
+
+			_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: testKey})
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantProgrammed, dp.programmed)
+			assert.Equal(t, []v1alpha1.OECNodeStatus{tt.wantStatus}, cli.oecs[testKey].Status.NodeStatuses)
+			assert.Equal(t, tt.wantStatus.State, cli.oecs[testKey].Status.State)
+			assert.Equal(t, 1, cli.statusUpdates, "the status must be written once per reconcile")
+		})
+	}
+}
+
+func TestReconcilePrunesNodesWhichLeft(t *testing.T) {
+	oec := newOEC("10.1.0.0/24")
+	oec.Status.NodeStatuses = []v1alpha1.OECNodeStatus{
+		{NodeName: "gone", State: v1alpha1.Failed, Message: "no default route"},
+		{NodeName: "node-b", State: v1alpha1.Succeeded},
+	}
+	tests := []struct {
+		name  string
+		nodes *fakeNodeLister
+		want  []v1alpha1.OECNodeStatus
+	}{
+		{
+			name:  "drops nodes which left",
+			nodes: &fakeNodeLister{nodes: map[string]struct{}{testNode: {}, "node-b": {}}},
+			want: []v1alpha1.OECNodeStatus{
+				{NodeName: testNode, State: v1alpha1.Succeeded},
+				{NodeName: "node-b", State: v1alpha1.Succeeded},
+			},
+		},
+		{
+			name:  "keeps every node when listing fails",
+			nodes: &fakeNodeLister{err: errors.New("forbidden")},
+			want: []v1alpha1.OECNodeStatus{
+				{NodeName: "gone", State: v1alpha1.Failed, Message: "no default route"},
+				{NodeName: testNode, State: v1alpha1.Succeeded},
+				{NodeName: "node-b", State: v1alpha1.Succeeded},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := &fakeOECClient{oecs: map[types.NamespacedName]*v1alpha1.OverlayExtensionConfig{testKey: oec.DeepCopy()}}
+			r := newTestReconciler(cli, &fakeDataplane{programmed: map[string]bool{}})
+			r.nodes = tt.nodes
+
+			_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: testKey})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, cli.oecs[testKey].Status.NodeStatuses)
+		})
+	}
+}
+
+func TestCachedNodeLister(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-b"}},
+	).Build()
+
+	nodes, err := (&cachedNodeLister{reader: reader}).NodeNames(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]struct{}{"node-a": {}, "node-b": {}}, nodes)
+}
+
+func TestReconcileDelete(t *testing.T) {
+	cli := &fakeOECClient{oecs: map[types.NamespacedName]*v1alpha1.OverlayExtensionConfig{testKey: newOEC("10.1.0.0/24")}}
+	dp := &fakeDataplane{programmed: map[string]bool{}}
+	r := newTestReconciler(cli, dp)
+
+	_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: testKey})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"10.1.0.0/24": true}, dp.programmed)
+
+	delete(cli.oecs, testKey)
+	_, err = r.Reconcile(context.Background(), reconcile.Request{NamespacedName: testKey})
+	require.NoError(t, err)
+	assert.Empty(t, dp.programmed)
+}
+
+func TestReconcileDeleteKeepsSharedCIDR(t *testing.T) {
+	other := types.NamespacedName{Namespace: "other", Name: "agc"}
+	otherOEC := newOEC("10.1.0.0/24")
+	otherOEC.Namespace = other.Namespace
+	cli := &fakeOECClient{oecs: map[types.NamespacedName]*v1alpha1.OverlayExtensionConfig{testKey: newOEC("10.1.0.0/24"), other: otherOEC}}
+	dp := &fakeDataplane{programmed: map[string]bool{}}
+	r := newTestReconciler(cli, dp)
+
+	for _, key := range []types.NamespacedName{testKey, other} {
+		_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: key})
+		require.NoError(t, err)
+	}
+
+	delete(cli.oecs, testKey)
+	_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: testKey})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"10.1.0.0/24": true}, dp.programmed)
+}
+
+func TestReconcileRemovesStaleProgramming(t *testing.T) {
+	cli := &fakeOECClient{oecs: map[types.NamespacedName]*v1alpha1.OverlayExtensionConfig{testKey: newOEC("10.1.0.0/24")}}
+	dp := &fakeDataplane{programmed: map[string]bool{"10.1.0.0/24": true, "10.2.0.0/24": true}}
+	r := newTestReconciler(cli, dp)
+
+	_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: testKey})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"10.1.0.0/24": true}, dp.programmed)
+}
+
+func TestReconcileRetriesStaleProgrammingRemoval(t *testing.T) {
+	cli := &fakeOECClient{
+		oecs:    map[types.NamespacedName]*v1alpha1.OverlayExtensionConfig{testKey: newOEC("10.1.0.0/24")},
+		listErr: errors.New("cache not synced"),
+	}
+	dp := &fakeDataplane{programmed: map[string]bool{"10.2.0.0/24": true}}
+	r := newTestReconciler(cli, dp)
+
+	// the reconcile still programs its range, and is requeued to retry the removal
+	result, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: testKey})
+	require.NoError(t, err)
+	assert.Equal(t, staleRetryInterval, result.RequeueAfter)
+	assert.Equal(t, map[string]bool{"10.1.0.0/24": true, "10.2.0.0/24": true}, dp.programmed)
+
+	cli.listErr = nil
+	result, err = r.Reconcile(context.Background(), reconcile.Request{NamespacedName: testKey})
+	require.NoError(t, err)
+	assert.Zero(t, result.RequeueAfter)
+	assert.Equal(t, map[string]bool{"10.1.0.0/24": true}, dp.programmed)
+}
@@ -30,4 +30,18 @@ const (
 	AllowHostToNCCommunicationStr = "AllowHostToNCCommunication"
 	NetworkContainerTypeStr       = "NetworkContainerType"
 	OrchestratorContextStr        = "OrchestratorContext"
+
+	// CNS IP history properties
+	CnsIPAssignmentEventStr = "CNSIPAssignment"
+	PodNameStr              = "PodName"
+	PodNamespaceStr         = "PodNamespace"
+	ContainerIDStr          = "ContainerID"
+	AssignedAtStr           = "AssignedAt"
+	ReleasedAtStr           = "ReleasedAt"
+
+	// CNS config reload properties
+	CnsConfigReloadEventStr = "CNSConfigReload"
+	AppliedFieldsStr        = "AppliedFields"
+	RefusedFieldsStr        = "RefusedFields"
+	ReloadErrorStr          = "ReloadError"
 )
@@ -4,10 +4,25 @@ package logger
 
 import (
 	cores "github.com/Azure/azure-container-networking/cns/logger/v2/cores"
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
+// level is the general logging level, which can be changed at runtime with SetLevel.
+var level = zap.NewAtomicLevel()
+
+// SetLevel changes the general logging level of loggers built with New.
+// Cores with a more specific level are not affected.
+func SetLevel(l string) error {
+	lvl, err := zapcore.ParseLevel(l)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse level")
+	}
+	level.SetLevel(lvl)
+	return nil
+}
+
 type compoundCloser []func()
 
 func (c compoundCloser) Close() {
@@ -19,7 +34,8 @@ func (c compoundCloser) Close() {
 // New creates a v2 CNS logger built with Zap.
 func New(cfg *Config) (*zap.Logger, func(), error) {
 	cfg.Normalize()
-	core := cores.StdoutCore(cfg.level)
+	level.SetLevel(cfg.level)
+	core := cores.StdoutCore(level)
 	closer := compoundCloser{}
 	if cfg.File != nil {
 		fileCore, fileCloser, err := cores.FileCore(cfg.File)
@@ -54,10 +54,21 @@ func (k *K8sSWIFTv2Middleware) log() *zap.Logger {
 var _ cns.IPConfigsHandlerMiddleware = (*K8sSWIFTv2Middleware)(nil)
 
 func (k *K8sSWIFTv2Middleware) GetPodInfoForIPConfigsRequest(ctx context.Context, req *cns.IPConfigsRequest) (podInfo cns.PodInfo, respCode types.ResponseCode, message string) {
+	podInfo, _, respCode, message = k.getPodInfoForIPConfigsRequest(ctx, req)
+	return podInfo, respCode, message
+}
+
+// getPodInfoForIPConfigsRequest is GetPodInfoForIPConfigsRequest which also returns the step that failed.
+func (k *K8sSWIFTv2Middleware) getPodInfoForIPConfigsRequest(ctx context.Context, req *cns.IPConfigsRequest) (
+	podInfo cns.PodInfo,
+	failedStep cns.IPAllocationStep,
+	respCode types.ResponseCode,
+	message string,
+) {
 	// gets pod info for the specified request
 	podInfo, pod, respCode, message := k.GetPodInfo(ctx, req)
 	if respCode != types.Success {
-		return nil, respCode, message
+		return nil, cns.IPAllocationStepSWIFTv2GetPod, respCode, message
 	}
 
 	// validates if pod is swiftv2
@@ -68,21 +79,21 @@ func (k *K8sSWIFTv2Middleware) GetPodInfoForIPConfigsRequest(ctx context.Context
 	if isSwiftv2 {
 		mtpnc, respCode, message = k.getMTPNC(ctx, podInfo)
 		if respCode != types.Success {
-			return nil, respCode, message
+			return nil, cns.IPAllocationStepSWIFTv2GetMTPNC, respCode, message
 		}
 		if mtpnc.IsDeleting() {
-			return nil, types.UnexpectedError, errMTPNCDeleting.Error()
+			return nil, cns.IPAllocationStepSWIFTv2GetMTPNC, types.UnexpectedError, errMTPNCDeleting.Error()
 		}
 		// update ipConfigRequest
 		respCode, message = k.UpdateIPConfigRequest(mtpnc, req)
 		if respCode != types.Success {
-			return nil, respCode, message
+			return nil, cns.IPAllocationStepSWIFTv2UpdateReq, respCode, message
 		}
 	}
 	logger.Printf("[SWIFTv2Middleware] pod %s has secondary interface : %v", podInfo.Name(), req.SecondaryInterfacesExist)
 	logger.Printf("[SWIFTv2Middleware] pod %s has backend interface : %v", podInfo.Name(), req.BackendInterfaceExist)
 
-	return podInfo, types.Success, ""
+	return podInfo, "", types.Success, ""
 }
 
 // getIPConfig returns the pod's SWIFT V2 IP configuration.
@@ -173,7 +184,8 @@ func (k *K8sSWIFTv2Middleware) getSwiftV2IpConfigHelper(ctx context.Context, pod
 					NICType:           nicType,
 					SharedNIC:         interfaceInfo.SharedNIC,
 					SkipDefaultRoutes: false,
-					// InterfaceName is empty for DelegatedVMNIC and AccelnetFrontendNIC
+					// InterfaceName is the name requested in the PodNetworkInstance, empty keeps the VM NIC name
+					InterfaceName: interfaceInfo.InterfaceName,
 				}
 				// for windows scenario, it is required to add additional fields with the exact subnetAddressSpace
 				// received from MTPNC, this function assigns them for windows while linux is a no-op
@@ -118,7 +118,7 @@ func (k *K8sSWIFTv2Middleware) addDefaultRoute(*cns.PodIpInfo, string) {}
 // and release IP configs handlers.
 func (k *K8sSWIFTv2Middleware) IPConfigsRequestHandlerWrapper(defaultHandler, failureHandler cns.IPConfigsHandlerFunc) cns.IPConfigsHandlerFunc {
 	return func(ctx context.Context, req cns.IPConfigsRequest) (*cns.IPConfigsResponse, error) {
-		podInfo, respCode, message := k.GetPodInfoForIPConfigsRequest(ctx, &req)
+		podInfo, failedStep, respCode, message := k.getPodInfoForIPConfigsRequest(ctx, &req)
 
 		if respCode != types.Success {
 			return &cns.IPConfigsResponse{
@@ -126,6 +126,7 @@ func (k *K8sSWIFTv2Middleware) IPConfigsRequestHandlerWrapper(defaultHandler, fa
 					ReturnCode: respCode,
 					Message:    message,
 				},
+				Diagnosis: &cns.IPAllocationDiagnosis{FailedStep: failedStep},
 			}, errors.New("failed to validate IP configs request")
 		}
 		ipConfigsResp, err := defaultHandler(ctx, req)
@@ -154,10 +155,12 @@ func (k *K8sSWIFTv2Middleware) IPConfigsRequestHandlerWrapper(defaultHandler, fa
 					Message:    fmt.Sprintf("AllocateIPConfig failed: %v, IP config request is %v", err, req),
 				},
 				PodIPInfo: []cns.PodIpInfo{},
+				Diagnosis: &cns.IPAllocationDiagnosis{FailedStep: cns.IPAllocationStepSWIFTv2IPConfig},
 			}, errors.Wrapf(err, "failed to get SWIFTv2 IP config : %v", req)
 		}
 		ipConfigsResp.PodIPInfo = append(ipConfigsResp.PodIPInfo, SWIFTv2PodIPInfos...)
 		// Set routes for the pod
+		delegatedNICSeen := false
 		for i := range ipConfigsResp.PodIPInfo {
 			ipInfo := &ipConfigsResp.PodIPInfo[i]
 			// Backend nics doesn't need routes to be set
@@ -170,9 +173,16 @@ func (k *K8sSWIFTv2Middleware) IPConfigsRequestHandlerWrapper(defaultHandler, fa
 							Message:    fmt.Sprintf("AllocateIPConfig failed: %v, IP config request is %v", err, req),
 						},
 						PodIPInfo: []cns.PodIpInfo{},
+						Diagnosis: &cns.IPAllocationDiagnosis{FailedStep: cns.IPAllocationStepSWIFTv2Routes},
 					}, errors.Wrapf(err, "failed to set routes for pod %s", podInfo.Name())
 				}
 			}
+			// Only the first delegated NIC carries the pod's default route. The CNI keeps the routes of
+			// any further delegated NIC in a table of its own, used for traffic sourced from that NIC's IP.
+			if ipInfo.NICType == cns.DelegatedVMNIC {
+				ipInfo.SourceBasedRouting = delegatedNICSeen
+				delegatedNICSeen = true
+			}
 		}
 		return ipConfigsResp, nil
 	}
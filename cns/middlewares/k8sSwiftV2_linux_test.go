@@ -98,6 +98,53 @@ func TestIPConfigsRequestHandlerWrapperSuccess(t *testing.T) {
 	assert.Equal(t, resp.PodIPInfo[2].MacAddress, "00:00:00:00:00:00")
 }
 
+func TestIPConfigsRequestHandlerWrapperMultiNIC(t *testing.T) {
+	middleware := K8sSWIFTv2Middleware{Cli: mock.NewClient()}
+	t.Setenv(configuration.EnvPodCIDRs, "10.0.1.10/24,16A0:0010:AB00:001E::2/32")
+	t.Setenv(configuration.EnvServiceCIDRs, "10.0.0.0/16,16A0:0010:AB00:0000::/32")
+	t.Setenv(configuration.EnvInfraVNETCIDRs, "10.240.0.1/16,16A0:0020:AB00:0000::/32")
+	defaultHandler := func(context.Context, cns.IPConfigsRequest) (*cns.IPConfigsResponse, error) {
+		return &cns.IPConfigsResponse{
+			PodIPInfo: []cns.PodIpInfo{
+				{
+					PodIPConfig: cns.IPSubnet{
+						IPAddress:    "10.0.1.10",
+						PrefixLength: 32,
+					},
+					NICType: cns.InfraNIC,
+				},
+			},
+		}, nil
+	}
+	failureHandler := func(context.Context, cns.IPConfigsRequest) (*cns.IPConfigsResponse, error) {
+		return nil, nil
+	}
+	wrappedHandler := middleware.IPConfigsRequestHandlerWrapper(defaultHandler, failureHandler)
+	req := cns.IPConfigsRequest{
+		PodInterfaceID:   testPod7Info.InterfaceID(),
+		InfraContainerID: testPod7Info.InfraContainerID(),
+	}
+	req.OrchestratorContext, _ = testPod7Info.OrchestratorContext()
+	resp, err := wrappedHandler(context.TODO(), req)
+	assert.NilError(t, err)
+
+	var delegated []cns.PodIpInfo
+	for _, info := range resp.PodIPInfo {
+		if info.NICType == cns.DelegatedVMNIC {
+			delegated = append(delegated, info)
+		}
+	}
+	assert.Equal(t, len(delegated), 2)
+	// the first delegated NIC carries the default route, the second one is named per its PodNetworkInstance
+	assert.Equal(t, delegated[0].SkipDefaultRoutes, false)
+	assert.Equal(t, delegated[0].SourceBasedRouting, false)
+	assert.Equal(t, delegated[0].InterfaceName, "")
+	assert.Equal(t, delegated[1].SkipDefaultRoutes, false)
+	assert.Equal(t, delegated[1].SourceBasedRouting, true)
+	assert.Equal(t, delegated[1].InterfaceName, "net1")
+	assert.Equal(t, len(delegated[1].Routes), 2)
+}
+
 func TestIPConfigsRequestHandlerWrapperFailure(t *testing.T) {
 	middleware := K8sSWIFTv2Middleware{Cli: mock.NewClient()}
 	defaultHandler := func(context.Context, cns.IPConfigsRequest) (*cns.IPConfigsResponse, error) {
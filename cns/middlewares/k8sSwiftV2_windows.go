@@ -133,7 +133,7 @@ func createEndpointPolicy(direction string) ([]byte, error) {
 // and release IP configs handlers.
 func (k *K8sSWIFTv2Middleware) IPConfigsRequestHandlerWrapper(defaultHandler, failureHandler cns.IPConfigsHandlerFunc) cns.IPConfigsHandlerFunc {
 	return func(ctx context.Context, req cns.IPConfigsRequest) (*cns.IPConfigsResponse, error) {
-		podInfo, respCode, message := k.GetPodInfoForIPConfigsRequest(ctx, &req)
+		podInfo, failedStep, respCode, message := k.getPodInfoForIPConfigsRequest(ctx, &req)
 
 		if respCode != types.Success {
 			return &cns.IPConfigsResponse{
@@ -141,6 +141,7 @@ func (k *K8sSWIFTv2Middleware) IPConfigsRequestHandlerWrapper(defaultHandler, fa
 					ReturnCode: respCode,
 					Message:    message,
 				},
+				Diagnosis: &cns.IPAllocationDiagnosis{FailedStep: failedStep},
 			}, errors.New("failed to validate IP configs request")
 		}
 		ipConfigsResp, err := defaultHandler(ctx, req)
@@ -171,6 +172,7 @@ func (k *K8sSWIFTv2Middleware) IPConfigsRequestHandlerWrapper(defaultHandler, fa
 					ReturnCode: respCode,
 					Message:    message,
 				},
+				Diagnosis: &cns.IPAllocationDiagnosis{FailedStep: cns.IPAllocationStepSWIFTv2GetMTPNC},
 			}, errors.New("failed to validate IP configs request")
 		}
 
@@ -208,6 +210,7 @@ func (k *K8sSWIFTv2Middleware) IPConfigsRequestHandlerWrapper(defaultHandler, fa
 					Message:    fmt.Sprintf("AllocateIPConfig failed: %v, IP config request is %v", err, req),
 				},
 				PodIPInfo: []cns.PodIpInfo{},
+				Diagnosis: &cns.IPAllocationDiagnosis{FailedStep: cns.IPAllocationStepSWIFTv2IPConfig},
 			}, errors.Wrapf(err, "failed to get SWIFTv2 IP config : %v", req)
 		}
 		ipConfigsResp.PodIPInfo = append(ipConfigsResp.PodIPInfo, SWIFTv2PodIPInfos...)
@@ -224,6 +227,7 @@ func (k *K8sSWIFTv2Middleware) IPConfigsRequestHandlerWrapper(defaultHandler, fa
 							Message:    fmt.Sprintf("AllocateIPConfig failed: %v, IP config request is %v", err, req),
 						},
 						PodIPInfo: []cns.PodIpInfo{},
+						Diagnosis: &cns.IPAllocationDiagnosis{FailedStep: cns.IPAllocationStepSWIFTv2Routes},
 					}, errors.Wrapf(err, "failed to set routes for pod %s", podInfo.Name())
 				}
 			}
@@ -88,6 +88,7 @@ func NewClient() *Client {
 		DeviceType:         v1alpha1.DeviceTypeVnetNIC,
 		AccelnetEnabled:    false,
 		SubnetAddressSpace: "192.168.0.0/24",
+		InterfaceName:      "net1",
 	}
 	testInterfaceInfos5 := v1alpha1.InterfaceInfo{
 		NCID:               "testncid",
@@ -429,7 +429,14 @@ func (service *HTTPRestService) getHealthReport(w http.ResponseWriter, r *http.R
 	logger.Printf("[Azure CNS] getHealthReport")
 	logger.Request(service.Name, "getHealthReport", nil)
 
-	resp := &cns.Response{ReturnCode: 0}
+	resp := &cns.HealthReportResponse{Response: cns.Response{ReturnCode: 0}}
+	if getter, ok := service.nma.(breakerStateGetter); ok {
+		states := getter.BreakerStates()
+		resp.NMAgentCircuitBreakers = make(map[string]string, len(states))
+		for endpoint, state := range states {
+			resp.NMAgentCircuitBreakers[endpoint] = string(state)
+		}
+	}
 	err := common.Encode(w, &resp)
 
 	logger.Response(service.Name, resp, resp.ReturnCode, err)
@@ -731,6 +738,7 @@ func (service *HTTPRestService) deleteNetworkContainer(w http.ResponseWriter, r
 		service.Lock()
 		defer service.Unlock()
 
+		service.releaseNCIPHistoryUntransacted(ncid)
 		if service.state.ContainerStatus != nil {
 			delete(service.state.ContainerStatus, ncid)
 		}
@@ -1231,6 +1231,39 @@ func TestNMAgentNCListHandler(t *testing.T) {
 	require.Equal(t, params.ncID, nmAgentNCListResponse.NCList[0])
 }
 
+// breakerNMAgentFake is an NMAgent client that reports circuit breaker states.
+type breakerNMAgentFake struct {
+	fakes.NMAgentClientFake
+	states map[string]nmagent.BreakerState
+}
+
+func (b *breakerNMAgentFake) BreakerStates() map[string]nmagent.BreakerState {
+	return b.states
+}
+
+func TestGetHealthReportBreakerStates(t *testing.T) {
+	prev := svc.nma
+	svc.nma = &breakerNMAgentFake{states: map[string]nmagent.BreakerState{
+		nmagent.EndpointGetHomeAz:        nmagent.BreakerOpen,
+		nmagent.EndpointGetNCVersionList: nmagent.BreakerClosed,
+	}}
+	defer func() { svc.nma = prev }()
+
+	req, err := http.NewRequestWithContext(context.TODO(), http.MethodGet, cns.GetHealthReportPath, http.NoBody)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var resp cns.HealthReportResponse
+	require.NoError(t, decodeResponse(w, &resp))
+	require.Equal(t, types.Success, resp.ReturnCode)
+	require.Equal(t, map[string]string{
+		nmagent.EndpointGetHomeAz:        "open",
+		nmagent.EndpointGetNCVersionList: "closed",
+	}, resp.NMAgentCircuitBreakers)
+}
+
 // Testing GetHomeAz API handler, return UnsupportedVerb if http method is not supported
 func TestGetHomeAz_UnsupportedHttpMethod(t *testing.T) {
 	req, err := http.NewRequestWithContext(context.TODO(), http.MethodPost, cns.GetHomeAz, http.NoBody)
@@ -13,6 +13,7 @@ import (
 	"net/http/httptest"
 	"net/netip"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -190,6 +191,31 @@ func (service *HTTPRestService) SyncHostNCVersion(ctx context.Context, channelMo
 
 var errNonExistentContainerStatus = errors.New("nonExistantContainerstatus")
 
+// GetNCVersionReports returns the version of each NC as programmed by CNS and by NMAgent, sorted by NC ID.
+// Versions that cannot be parsed are reported as -1.
+func (service *HTTPRestService) GetNCVersionReports() []v1alpha.NCVersionReport {
+	service.RLock()
+	defer service.RUnlock()
+	reports := make([]v1alpha.NCVersionReport, 0, len(service.state.ContainerStatus))
+	for _, nc := range service.state.ContainerStatus {
+		reports = append(reports, v1alpha.NCVersionReport{
+			ID:          nc.ID,
+			Version:     parseNCVersion(nc.CreateNetworkContainerRequest.Version),
+			HostVersion: parseNCVersion(nc.HostVersion),
+		})
+	}
+	slices.SortFunc(reports, func(a, b v1alpha.NCVersionReport) int { return strings.Compare(a.ID, b.ID) })
+	return reports
+}
+
+func parseNCVersion(version string) int64 {
+	v, err := strconv.ParseInt(version, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return v
+}
+
 // syncHostVersion updates the CNS state with the latest programmed versions of NCs attached to the VM. If any NC in local CNS state
 // does not match the version that DNC claims to have published, this function will call NMAgent and list the latest programmed versions of
 // all NCs and update the CNS state accordingly. This function returns the the total number of NCs on this VM that have been programmed to
@@ -531,6 +557,7 @@ func (service *HTTPRestService) DeleteNetworkContainerInternal(
 
 	service.Lock()
 	defer service.Unlock()
+	service.releaseNCIPHistoryUntransacted(ncid)
 	if service.state.ContainerStatus != nil {
 		delete(service.state.ContainerStatus, ncid)
 	}
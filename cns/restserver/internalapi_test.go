@@ -1758,3 +1758,20 @@ func setupIMDSMockAPIsWithCustomIDs(svc *HTTPRestService, interfaceIDs []string)
 	// Return cleanup function
 	return func() { svc.imdsClient = originalIMDS }
 }
+
+func TestGetNCVersionReports(t *testing.T) {
+	service := &HTTPRestService{
+		state: &httpRestServiceState{
+			ContainerStatus: map[string]containerstatus{
+				"nc-b": {ID: "nc-b", HostVersion: "-1", CreateNetworkContainerRequest: cns.CreateNetworkContainerRequest{Version: "3"}},
+				"nc-a": {ID: "nc-a", HostVersion: "2", CreateNetworkContainerRequest: cns.CreateNetworkContainerRequest{Version: "2"}},
+				"nc-c": {ID: "nc-c", HostVersion: "", CreateNetworkContainerRequest: cns.CreateNetworkContainerRequest{Version: "bad"}},
+			},
+		},
+	}
+	assert.Equal(t, []v1alpha.NCVersionReport{
+		{ID: "nc-a", Version: 2, HostVersion: 2},
+		{ID: "nc-b", Version: 3, HostVersion: -1},
+		{ID: "nc-c", Version: -1, HostVersion: -1},
+	}, service.GetNCVersionReports())
+}
@@ -0,0 +1,166 @@
+package restserver
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/Azure/azure-container-networking/common"
+)
+
+// maxIPAllocationFailures bounds the number of Pods whose last failure is kept. The oldest failure is dropped first.
+const maxIPAllocationFailures = 250
+
+// ipAllocationFailures keeps the diagnosis of the last failed IPConfigsRequest of each Pod, until the Pod gets its IPs.
+type ipAllocationFailures struct {
+	sync.Mutex
+	byPod map[string]cns.IPAllocationDiagnosis
+}
+
+func ipAllocationFailureKey(podName, podNamespace string) string {
+	return podNamespace + "/" + podName
+}
+
+func (f *ipAllocationFailures) record(d cns.IPAllocationDiagnosis) {
+	f.Lock()
+	defer f.Unlock()
+	if f.byPod == nil {
+		f.byPod = make(map[string]cns.IPAllocationDiagnosis)
+	}
+	key := ipAllocationFailureKey(d.PodName, d.PodNamespace)
+	if _, found := f.byPod[key]; !found && len(f.byPod) >= maxIPAllocationFailures {
+		oldest := ""
+		for k := range f.byPod {
+			if oldest == "" || f.byPod[k].Time.Before(f.byPod[oldest].Time) {
+				oldest = k
+			}
+		}
+		delete(f.byPod, oldest)
+	}
+	f.byPod[key] = d
+}
+
+func (f *ipAllocationFailures) clear(podName, podNamespace string) {
+	f.Lock()
+	defer f.Unlock()
+	delete(f.byPod, ipAllocationFailureKey(podName, podNamespace))
+}
+
+func (f *ipAllocationFailures) get(podName, podNamespace string) (cns.IPAllocationDiagnosis, bool) {
+	f.Lock()
+	defer f.Unlock()
+	d, found := f.byPod[ipAllocationFailureKey(podName, podNamespace)]
+	return d, found
+}
+
+// AttachPoolMonitorSnapshot lets the diagnosis of failed IPConfigsRequests tell whether the pool is scaling up.
+func (service *HTTPRestService) AttachPoolMonitorSnapshot(snapshot func() cns.IpamPoolMonitorStateSnapshot) {
+	service.poolSnapshot = snapshot
+}
+
+// diagnoseIPConfigsFailure completes the diagnosis of a failed IPConfigsRequest with the state of the pool and the NCs,
+// attaches it to the response and keeps it for the Pod. The handlers set the step which failed in the response.
+func (service *HTTPRestService) diagnoseIPConfigsFailure(req cns.IPConfigsRequest, resp *cns.IPConfigsResponse) {
+	if resp.Diagnosis == nil {
+		resp.Diagnosis = &cns.IPAllocationDiagnosis{}
+	}
+	d := resp.Diagnosis
+	d.Time = time.Now()
+	d.ReturnCode = resp.Response.ReturnCode
+	d.Message = resp.Response.Message
+	d.Pool, d.NetworkContainers = service.ipAllocationState()
+
+	podInfo, err := cns.UnmarshalPodInfo(req.OrchestratorContext)
+	if err != nil {
+		// the request can't be attributed to a Pod, so there's nothing to keep
+		return
+	}
+	d.PodName = podInfo.Name()
+	d.PodNamespace = podInfo.Namespace()
+	service.ipAllocationFailures.record(*d)
+}
+
+// clearIPConfigsFailure forgets the last failure of the Pod once it got its IPs.
+func (service *HTTPRestService) clearIPConfigsFailure(req cns.IPConfigsRequest) {
+	podInfo, err := cns.UnmarshalPodInfo(req.OrchestratorContext)
+	if err != nil {
+		return
+	}
+	service.ipAllocationFailures.clear(podInfo.Name(), podInfo.Namespace())
+}
+
+// ipAllocationState counts the IPs of the pool by state and compares the versions of the NCs.
+func (service *HTTPRestService) ipAllocationState() (cns.IPPoolDiagnosis, []cns.NCVersionDiagnosis) {
+	reports := service.GetNCVersionReports()
+
+	service.RLock()
+	var pool cns.IPPoolDiagnosis
+	pendingProgrammingByNC := map[string]int{}
+	for _, ipConfig := range service.PodIPConfigState { //nolint:gocritic // ignore copy
+		switch ipConfig.GetState() {
+		case types.Available:
+			pool.Available++
+		case types.Assigned:
+			pool.Assigned++
+		case types.PendingRelease:
+			pool.PendingRelease++
+		case types.PendingProgramming:
+			pool.PendingProgramming++
+			pendingProgrammingByNC[ipConfig.NCID]++
+		}
+	}
+	total := len(service.PodIPConfigState)
+	service.RUnlock()
+
+	if service.poolSnapshot != nil {
+		snapshot := service.poolSnapshot()
+		pool.RequestedIPCount = snapshot.CachedNNC.Spec.RequestedIPCount
+		// IPAM v2 doesn't cache the NNC, but the last scale is the current request
+		if pool.RequestedIPCount == 0 && snapshot.LastScaleEvent != nil {
+			pool.RequestedIPCount = snapshot.LastScaleEvent.To
+		}
+		pool.ScaleUpInFlight = pool.RequestedIPCount > int64(total)
+	}
+
+	ncs := make([]cns.NCVersionDiagnosis, 0, len(reports))
+	for _, report := range reports {
+		ncs = append(ncs, cns.NCVersionDiagnosis{
+			NetworkContainerID:    report.ID,
+			ExpectedVersion:       report.Version,
+			ProgrammedVersion:     report.HostVersion,
+			PendingProgrammingIPs: pendingProgrammingByNC[report.ID],
+		})
+	}
+	return pool, ncs
+}
+
+// HandleDebugIPAllocationFailure returns why the last IPConfigsRequest of the requested Pod failed.
+func (service *HTTPRestService) HandleDebugIPAllocationFailure(w http.ResponseWriter, r *http.Request) {
+	opName := "handleDebugIPAllocationFailure"
+	var req cns.GetIPAllocationFailureRequest
+	if err := common.Decode(w, r, &req); err != nil {
+		resp := cns.GetIPAllocationFailureResponse{
+			Response: cns.Response{
+				ReturnCode: types.UnexpectedError,
+				Message:    err.Error(),
+			},
+		}
+		err = common.Encode(w, &resp)
+		logger.ResponseEx(opName, req, resp, resp.Response.ReturnCode, err)
+		return
+	}
+	var resp cns.GetIPAllocationFailureResponse
+	if d, found := service.ipAllocationFailures.get(req.PodName, req.PodNamespace); found {
+		resp.Diagnosis = &d
+	} else {
+		resp.Response = cns.Response{
+			ReturnCode: types.NotFound,
+			Message:    "no failed IP allocation for pod " + ipAllocationFailureKey(req.PodName, req.PodNamespace),
+		}
+	}
+	err := common.Encode(w, &resp)
+	logger.ResponseEx(opName, req, resp, resp.Response.ReturnCode, err)
+}
@@ -0,0 +1,94 @@
+package restserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig/api/v1alpha"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveJSON(t *testing.T, handler http.HandlerFunc, req, resp any) {
+	t.Helper()
+	var body bytes.Buffer
+	require.NoError(t, json.NewEncoder(&body).Encode(req))
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/", &body))
+	require.NoError(t, json.NewDecoder(w.Body).Decode(resp))
+}
+
+func TestIPConfigsFailureDiagnosis(t *testing.T) {
+	svc := getTestService(cns.KubernetesCRD)
+	svc.AttachPoolMonitorSnapshot(func() cns.IpamPoolMonitorStateSnapshot {
+		return cns.IpamPoolMonitorStateSnapshot{LastScaleEvent: &v1alpha.ScaleEvent{From: 2, To: 4}}
+	})
+	// a new NC version which NMAgent hasn't programmed yet leaves its IPs pending programming
+	createAndValidateNCRequest(t, map[string]cns.SecondaryIPConfig{
+		testIPID1: newSecondaryIPConfig(testIP1, 1),
+		testIPID2: newSecondaryIPConfig(testIP2, 1),
+	}, testNCID, "1")
+
+	req := cns.IPConfigsRequest{
+		PodInterfaceID:   testPod1Info.InterfaceID(),
+		InfraContainerID: testPod1Info.InfraContainerID(),
+	}
+	req.OrchestratorContext, _ = testPod1Info.OrchestratorContext()
+
+	var resp cns.IPConfigsResponse
+	serveJSON(t, svc.RequestIPConfigsHandler, req, &resp)
+	assert.Equal(t, types.FailedToAllocateIPConfig, resp.Response.ReturnCode)
+	require.NotNil(t, resp.Diagnosis)
+	assert.Equal(t, cns.IPAllocationStepAssignIPs, resp.Diagnosis.FailedStep)
+	assert.Equal(t, cns.IPPoolDiagnosis{PendingProgramming: 2, RequestedIPCount: 4, ScaleUpInFlight: true}, resp.Diagnosis.Pool)
+	require.Len(t, resp.Diagnosis.NetworkContainers, 1)
+	assert.Equal(t, testNCID, resp.Diagnosis.NetworkContainers[0].NetworkContainerID)
+	assert.Equal(t, int64(1), resp.Diagnosis.NetworkContainers[0].ExpectedVersion)
+	assert.Equal(t, 2, resp.Diagnosis.NetworkContainers[0].PendingProgrammingIPs)
+
+	// the diagnosis is kept for the pod
+	debugReq := cns.GetIPAllocationFailureRequest{PodName: testPod1Info.Name(), PodNamespace: testPod1Info.Namespace()}
+	var debugResp cns.GetIPAllocationFailureResponse
+	serveJSON(t, svc.HandleDebugIPAllocationFailure, debugReq, &debugResp)
+	assert.Equal(t, types.Success, debugResp.Response.ReturnCode)
+	require.NotNil(t, debugResp.Diagnosis)
+	assert.Equal(t, testPod1Info.Name(), debugResp.Diagnosis.PodName)
+	assert.Equal(t, resp.Diagnosis.Message, debugResp.Diagnosis.Message)
+	assert.Equal(t, resp.Diagnosis.Pool, debugResp.Diagnosis.Pool)
+
+	// and forgotten once the pod gets its IP
+	svc.Lock()
+	for id, ipConfig := range svc.PodIPConfigState { //nolint:gocritic // ignore copy
+		ipConfig.SetState(types.Available)
+		svc.PodIPConfigState[id] = ipConfig
+	}
+	svc.Unlock()
+	resp = cns.IPConfigsResponse{}
+	serveJSON(t, svc.RequestIPConfigsHandler, req, &resp)
+	require.Equal(t, types.Success, resp.Response.ReturnCode)
+	assert.Nil(t, resp.Diagnosis)
+
+	debugResp = cns.GetIPAllocationFailureResponse{}
+	serveJSON(t, svc.HandleDebugIPAllocationFailure, debugReq, &debugResp)
+	assert.Equal(t, types.NotFound, debugResp.Response.ReturnCode)
+}
+
+func TestIPAllocationFailuresBounded(t *testing.T) {
+	var failures ipAllocationFailures
+	start := time.Now()
+	for i := 0; i <= maxIPAllocationFailures; i++ {
+		failures.record(cns.IPAllocationDiagnosis{PodName: strconv.Itoa(i), PodNamespace: "default", Time: start.Add(time.Duration(i) * time.Second)})
+	}
+	assert.Len(t, failures.byPod, maxIPAllocationFailures)
+	_, found := failures.get("0", "default")
+	assert.False(t, found, "the oldest failure should be dropped")
+	_, found = failures.get(strconv.Itoa(maxIPAllocationFailures), "default")
+	assert.True(t, found)
+}
@@ -46,6 +46,7 @@ func (service *HTTPRestService) requestIPConfigHandlerHelper(ctx context.Context
 				ReturnCode: returnCode,
 				Message:    returnMessage,
 			},
+			Diagnosis: &cns.IPAllocationDiagnosis{FailedStep: cns.IPAllocationStepValidateRequest},
 		}, errors.New("failed to validate ip config request")
 	}
 
@@ -60,6 +61,7 @@ func (service *HTTPRestService) requestIPConfigHandlerHelper(ctx context.Context
 						Message:    fmt.Sprintf("BackendNIC allocation failed: %v, config request is %v", err, ipconfigsRequest),
 					},
 					PodIPInfo: []cns.PodIpInfo{},
+					Diagnosis: &cns.IPAllocationDiagnosis{FailedStep: cns.IPAllocationStepBackendNIC},
 				}, err
 			}
 			podBackendInfo := cns.PodIpInfo{
@@ -81,6 +83,7 @@ func (service *HTTPRestService) requestIPConfigHandlerHelper(ctx context.Context
 				Message:    fmt.Sprintf("AllocateIPConfig failed: %v, IP config request is %v", err, ipconfigsRequest),
 			},
 			PodIPInfo: podIPInfo,
+			Diagnosis: &cns.IPAllocationDiagnosis{FailedStep: cns.IPAllocationStepAssignIPs},
 		}, err
 	}
 
@@ -102,6 +105,7 @@ func (service *HTTPRestService) requestIPConfigHandlerHelper(ctx context.Context
 					Message:    fmt.Sprintf("Update endpoint state failed: %v ", err),
 				},
 				PodIPInfo: podIPInfo,
+				Diagnosis: &cns.IPAllocationDiagnosis{FailedStep: cns.IPAllocationStepUpdateEndpointState},
 			}, err
 		}
 	}
@@ -281,6 +285,7 @@ func (service *HTTPRestService) RequestIPConfigHandler(w http.ResponseWriter, r
 		logger.ResponseEx(opName, ipconfigRequest, reserveResp, reserveResp.Response.ReturnCode, err)
 		return
 	}
+	service.updatePodIPInfoWithMTU(ipConfigsResp.PodIPInfo)
 	// As this API is expected to return IPConfigResponse, generate it from the IPConfigsResponse returned above.
 	reserveResp := &cns.IPConfigResponse{
 		Response:  ipConfigsResp.Response,
@@ -321,12 +326,15 @@ func (service *HTTPRestService) RequestIPConfigsHandler(w http.ResponseWriter, r
 	}
 
 	if err != nil {
+		service.diagnoseIPConfigsFailure(ipconfigsRequest, ipConfigsResp)
 		w.Header().Set(cnsReturnCode, ipConfigsResp.Response.ReturnCode.String())
 		err = common.Encode(w, &ipConfigsResp)
 		logger.ResponseEx(opName, ipconfigsRequest, ipConfigsResp, ipConfigsResp.Response.ReturnCode, err)
 		return
 	}
+	service.clearIPConfigsFailure(ipconfigsRequest)
 
+	service.updatePodIPInfoWithMTU(ipConfigsResp.PodIPInfo)
 	w.Header().Set(cnsReturnCode, ipConfigsResp.Response.ReturnCode.String())
 	err = common.Encode(w, &ipConfigsResp)
 	logger.ResponseEx(opName, ipconfigsRequest, ipConfigsResp, ipConfigsResp.Response.ReturnCode, err)
@@ -646,9 +654,17 @@ func (service *HTTPRestService) MarkNIPsPendingRelease(n int) (map[string]cns.IP
 func (service *HTTPRestService) updateIPConfigState(ipID string, updatedState types.IPState, podInfo cns.PodInfo) (cns.IPConfigurationStatus, error) {
 	if ipConfig, found := service.PodIPConfigState[ipID]; found {
 		logger.Printf("[updateIPConfigState] Changing IpId [%s] state to [%s], podInfo [%+v]. Current config [%+v]", ipID, updatedState, podInfo, ipConfig)
+		wasAssigned := ipConfig.GetState() == types.Assigned
 		ipConfig.SetState(updatedState)
 		ipConfig.PodInfo = podInfo
 		service.PodIPConfigState[ipID] = ipConfig
+		if service.ipHistory != nil {
+			if updatedState == types.Assigned {
+				service.ipHistory.Assigned(ipConfig.IPAddress, podInfo)
+			} else if wasAssigned {
+				service.ipHistory.Released(ipConfig.IPAddress)
+			}
+		}
 		return ipConfig, nil
 	}
 
@@ -656,6 +672,24 @@ func (service *HTTPRestService) updateIPConfigState(ipID string, updatedState ty
 	return cns.IPConfigurationStatus{}, fmt.Errorf("[updateIPConfigState] Failed to update state %s for the IPConfig. ID %s not found PodIPConfigState", updatedState, ipID)
 }
 
+// releaseNCIPHistoryUntransacted records the release of the Assigned IPs of the NC in the IP history, for when
+// the IPs go away with their NC.
+// Note: this func is an untransacted API as the caller will take a Service lock
+func (service *HTTPRestService) releaseNCIPHistoryUntransacted(ncID string) {
+	if service.ipHistory == nil {
+		return
+	}
+	var ips []string
+	for _, ipConfig := range service.PodIPConfigState { //nolint:gocritic // intentional value copy
+		if ipConfig.NCID == ncID && ipConfig.GetState() == types.Assigned {
+			ips = append(ips, ipConfig.IPAddress)
+		}
+	}
+	if len(ips) > 0 {
+		service.ipHistory.Released(ips...)
+	}
+}
+
 // MarkIpsAsAvailableUntransacted will update pending programming IPs to available if NMAgent side's programmed nc version keep up with nc version.
 // Note: this func is an untransacted API as the caller will take a Service lock
 func (service *HTTPRestService) MarkIpsAsAvailableUntransacted(ncID string, newHostNCVersion int) {
@@ -747,6 +781,34 @@ func (service *HTTPRestService) HandleDebugIPAddresses(w http.ResponseWriter, r
 	logger.ResponseEx(opName, req, resp, resp.Response.ReturnCode, err)
 }
 
+// HandleDebugIPHistory returns the Pods the requested IP was assigned to in the requested time window.
+func (service *HTTPRestService) HandleDebugIPHistory(w http.ResponseWriter, r *http.Request) {
+	opName := "handleDebugIPHistory"
+	var req cns.GetIPHistoryRequest
+	if err := common.Decode(w, r, &req); err != nil {
+		resp := cns.GetIPHistoryResponse{
+			Response: cns.Response{
+				ReturnCode: types.UnexpectedError,
+				Message:    err.Error(),
+			},
+		}
+		err = common.Encode(w, &resp)
+		logger.ResponseEx(opName, req, resp, resp.Response.ReturnCode, err)
+		return
+	}
+	var resp cns.GetIPHistoryResponse
+	if service.ipHistory == nil {
+		resp.Response = cns.Response{
+			ReturnCode: types.UnsupportedAPI,
+			Message:    "ip history is not enabled",
+		}
+	} else {
+		resp.Records = service.ipHistory.Query(req.IPAddress, req.From, req.To)
+	}
+	err := common.Encode(w, &resp)
+	logger.ResponseEx(opName, req, resp, resp.Response.ReturnCode, err)
+}
+
 // GetAssignedIPConfigs returns a filtered list of IPs which are in
 // Assigned State.
 func (service *HTTPRestService) GetAssignedIPConfigs() []cns.IPConfigurationStatus {
@@ -8,6 +8,7 @@ import (
 	"net/netip"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/Azure/azure-container-networking/cns"
 	"github.com/Azure/azure-container-networking/cns/common"
@@ -2514,3 +2515,71 @@ func TestStatelessCNIStateFile(t *testing.T) {
 		})
 	}
 }
+
+type ipHistoryRecorder struct {
+	events []string
+}
+
+func (r *ipHistoryRecorder) Assigned(ip string, podInfo cns.PodInfo) {
+	r.events = append(r.events, "assigned "+ip+" "+podInfo.Name())
+}
+
+func (r *ipHistoryRecorder) Released(ips ...string) {
+	for _, ip := range ips {
+		r.events = append(r.events, "released "+ip)
+	}
+}
+
+func (r *ipHistoryRecorder) Query(string, time.Time, time.Time) []cns.IPAssignmentRecord {
+	return nil
+}
+
+func TestIPAMRecordsIPHistory(t *testing.T) {
+	svc := getTestService(cns.KubernetesCRD)
+	history := &ipHistoryRecorder{}
+	svc.AttachIPHistory(history)
+
+	ipconfigs := map[string]cns.IPConfigurationStatus{
+		testIPID1: newPodState(testIP1, testIPID1, testNCID, types.Available, 0),
+	}
+	require.NoError(t, updatePodIPConfigState(t, svc, ipconfigs, testNCID))
+
+	req := cns.IPConfigsRequest{
+		PodInterfaceID:   testPod1Info.InterfaceID(),
+		InfraContainerID: testPod1Info.InfraContainerID(),
+	}
+	req.OrchestratorContext, _ = testPod1Info.OrchestratorContext()
+	_, err := requestIPConfigsHelper(svc, req)
+	require.NoError(t, err)
+	require.NoError(t, svc.releaseIPConfigs(testPod1Info))
+	// marking an unassigned IP as pending release is not a release
+	_, err = svc.MarkIPAsPendingRelease(1)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"assigned " + testIP1 + " " + testPod1Info.Name(), "released " + testIP1}, history.events)
+}
+
+func TestIPHistoryRecordsReleaseWhenNCIsDeleted(t *testing.T) {
+	svc := getTestService(cns.KubernetesCRD)
+	history := &ipHistoryRecorder{}
+	svc.AttachIPHistory(history)
+
+	ipconfigs := map[string]cns.IPConfigurationStatus{
+		testIPID1: newPodState(testIP1, testIPID1, testNCID, types.Available, 0),
+		testIPID2: newPodState(testIP2, testIPID2, testNCID, types.Available, 0),
+	}
+	require.NoError(t, updatePodIPConfigState(t, svc, ipconfigs, testNCID))
+
+	req := cns.IPConfigsRequest{
+		PodInterfaceID:   testPod1Info.InterfaceID(),
+		InfraContainerID: testPod1Info.InfraContainerID(),
+	}
+	req.OrchestratorContext, _ = testPod1Info.OrchestratorContext()
+	_, err := requestIPConfigsHelper(svc, req)
+	require.NoError(t, err)
+
+	// only the assigned IP is released with its NC
+	assert.Equal(t, types.Success, svc.DeleteNetworkContainerInternal(cns.DeleteNetworkContainerRequest{NetworkContainerid: testNCID}))
+	assert.Len(t, history.events, 2)
+	assert.Contains(t, history.events[1], "released ")
+}
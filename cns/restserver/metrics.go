@@ -9,6 +9,7 @@ import (
 	"github.com/Azure/azure-container-networking/cns"
 	"github.com/Azure/azure-container-networking/cns/logger"
 	"github.com/Azure/azure-container-networking/cns/types"
+	nma "github.com/Azure/azure-container-networking/nmagent"
 	"github.com/prometheus/client_golang/prometheus"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
@@ -121,6 +122,14 @@ var (
 			Help: "Number of NodeInfo device MAC addresses that failed to parse while building the NIC resources response.",
 		},
 	)
+	// nmagentBreakerState is 1 for the current state of each NMAgent endpoint's circuit breaker and 0 otherwise.
+	nmagentBreakerState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cns_nmagent_circuit_breaker_state",
+			Help: "State of the circuit breaker for each NMAgent endpoint.",
+		},
+		[]string{"endpoint", "state"},
+	)
 )
 
 func init() {
@@ -137,9 +146,22 @@ func init() {
 		pendingProgrammingIPCount,
 		pendingReleaseIPCount,
 		nicResourceMACParseErrors,
+		nmagentBreakerState,
 	)
 }
 
+// RecordNMAgentBreakerState publishes the state of an NMAgent endpoint's circuit breaker.
+// It is meant to be used as the NMAgent client's OnBreakerStateChange hook.
+func RecordNMAgentBreakerState(endpoint string, state nma.BreakerState) {
+	for _, s := range []nma.BreakerState{nma.BreakerClosed, nma.BreakerHalfOpen, nma.BreakerOpen} {
+		value := 0.0
+		if s == state {
+			value = 1
+		}
+		nmagentBreakerState.WithLabelValues(endpoint, string(s)).Set(value)
+	}
+}
+
 // Every http response is 200 so we really want cns  response code.
 // Hard tto do with middleware unless we derserialize the responses but making it an explit header works around it.
 // if that doesn't work we could have a separate countervec just for response codes.
@@ -0,0 +1,101 @@
+package restserver
+
+import (
+	stderrors "errors"
+	"net"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/configuration"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/pkg/errors"
+)
+
+const (
+	// minimumIPv6MTU is the smallest MTU an IPv6 link may have (RFC 8200). CNS never reports anything below it.
+	minimumIPv6MTU = 1280
+)
+
+var errInterfaceNotFound = errors.New("no interface found with ip")
+
+// SetMTUSettings configures how the effective MTU is computed for the pod interfaces returned by CNS.
+func (service *HTTPRestService) SetMTUSettings(settings configuration.MTUSettings) {
+	service.mtuSettings = settings
+	if service.interfaceMTUByIP == nil {
+		service.interfaceMTUByIP = interfaceMTUByIP
+	}
+}
+
+// hostNICMTU returns the MTU of the host primary interface, preferring the configured override.
+func (service *HTTPRestService) hostNICMTU(hostPrimaryIP string) (int, error) {
+	if service.mtuSettings.HostNICMTU > 0 {
+		return service.mtuSettings.HostNICMTU, nil
+	}
+	return service.interfaceMTUByIP(hostPrimaryIP)
+}
+
+// effectiveMTU computes the MTU the CNI should program on a pod interface of the given NIC type.
+// A zero return means no MTU is reported for the interface.
+func (service *HTTPRestService) effectiveMTU(nicType cns.NICType, hostMTU int) int {
+	var mtu int
+	switch nicType {
+	case cns.InfraNIC, "":
+		mtu = hostMTU - service.mtuSettings.OverlayOverheadBytes
+	case cns.DelegatedVMNIC, cns.NodeNetworkInterfaceAccelnetFrontendNIC:
+		mtu = hostMTU
+		if service.mtuSettings.DelegatedNICMTU > 0 {
+			mtu = service.mtuSettings.DelegatedNICMTU
+		}
+	default:
+		// backend and apipa interfaces are not veth backed, leave them alone
+		return 0
+	}
+	if mtu < minimumIPv6MTU {
+		return minimumIPv6MTU
+	}
+	return mtu
+}
+
+// updatePodIPInfoWithMTU sets the effective MTU on every PodIpInfo when MTU reporting is enabled.
+// A PodIpInfo whose host NIC MTU cannot be found is left without an MTU, the others are still set.
+func (service *HTTPRestService) updatePodIPInfoWithMTU(podIPInfo []cns.PodIpInfo) {
+	if !service.mtuSettings.Enable {
+		return
+	}
+	var errs []error
+	for i := range podIPInfo {
+		hostMTU, err := service.hostNICMTU(podIPInfo[i].HostPrimaryIPInfo.PrimaryIP)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "%s interface", podIPInfo[i].NICType))
+			continue
+		}
+		podIPInfo[i].MTU = service.effectiveMTU(podIPInfo[i].NICType, hostMTU)
+	}
+	if len(errs) > 0 {
+		logger.Errorf("[updatePodIPInfoWithMTU] failed to get host NIC MTU, not reporting MTU for %d of %d interfaces: %v",
+			len(errs), len(podIPInfo), stderrors.Join(errs...))
+	}
+}
+
+// interfaceMTUByIP returns the MTU of the local interface that owns the given IP.
+func interfaceMTUByIP(ip string) (int, error) {
+	target := net.ParseIP(ip)
+	if target == nil {
+		return 0, errors.Wrapf(errInterfaceNotFound, "invalid ip %q", ip)
+	}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to list interfaces")
+	}
+	for i := range ifaces {
+		addrs, err := ifaces[i].Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(target) {
+				return ifaces[i].MTU, nil
+			}
+		}
+	}
+	return 0, errors.Wrap(errInterfaceNotFound, ip)
+}
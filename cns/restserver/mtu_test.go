@@ -0,0 +1,151 @@
+package restserver
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/configuration"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEffectiveMTU(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings configuration.MTUSettings
+		nicType  cns.NICType
+		hostMTU  int
+		want     int
+	}{
+		{
+			name:    "infra nic uses host mtu",
+			nicType: cns.InfraNIC,
+			hostMTU: 9000,
+			want:    9000,
+		},
+		{
+			name:     "infra nic subtracts overlay overhead",
+			settings: configuration.MTUSettings{OverlayOverheadBytes: 50},
+			nicType:  cns.InfraNIC,
+			hostMTU:  9000,
+			want:     8950,
+		},
+		{
+			name:     "legacy empty nic type is treated as infra",
+			settings: configuration.MTUSettings{OverlayOverheadBytes: 50},
+			nicType:  "",
+			hostMTU:  1500,
+			want:     1450,
+		},
+		{
+			name:     "delegated nic ignores overlay overhead",
+			settings: configuration.MTUSettings{OverlayOverheadBytes: 50},
+			nicType:  cns.DelegatedVMNIC,
+			hostMTU:  1500,
+			want:     1500,
+		},
+		{
+			name:     "delegated nic uses configured mtu",
+			settings: configuration.MTUSettings{DelegatedNICMTU: 9000},
+			nicType:  cns.NodeNetworkInterfaceAccelnetFrontendNIC,
+			hostMTU:  1500,
+			want:     9000,
+		},
+		{
+			name:     "mtu is clamped to the ipv6 minimum",
+			settings: configuration.MTUSettings{OverlayOverheadBytes: 1000},
+			nicType:  cns.InfraNIC,
+			hostMTU:  1500,
+			want:     minimumIPv6MTU,
+		},
+		{
+			name:    "backend nic reports no mtu",
+			nicType: cns.BackendNIC,
+			hostMTU: 1500,
+			want:    0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &HTTPRestService{mtuSettings: tt.settings}
+			assert.Equal(t, tt.want, svc.effectiveMTU(tt.nicType, tt.hostMTU))
+		})
+	}
+}
+
+func TestUpdatePodIPInfoWithMTU(t *testing.T) {
+	podIPInfo := func() []cns.PodIpInfo {
+		return []cns.PodIpInfo{
+			{NICType: cns.InfraNIC, HostPrimaryIPInfo: cns.HostIPInfo{PrimaryIP: "10.0.0.4"}},
+			{NICType: cns.DelegatedVMNIC, HostPrimaryIPInfo: cns.HostIPInfo{PrimaryIP: "10.0.0.4"}},
+		}
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		svc := &HTTPRestService{}
+		info := podIPInfo()
+		svc.updatePodIPInfoWithMTU(info)
+		assert.Zero(t, info[0].MTU)
+		assert.Zero(t, info[1].MTU)
+	})
+
+	t.Run("discovers host mtu", func(t *testing.T) {
+		svc := &HTTPRestService{
+			mtuSettings: configuration.MTUSettings{Enable: true, OverlayOverheadBytes: 50},
+			interfaceMTUByIP: func(ip string) (int, error) {
+				assert.Equal(t, "10.0.0.4", ip)
+				return 9000, nil
+			},
+		}
+		info := podIPInfo()
+		svc.updatePodIPInfoWithMTU(info)
+		assert.Equal(t, 8950, info[0].MTU)
+		assert.Equal(t, 9000, info[1].MTU)
+	})
+
+	t.Run("configured host mtu skips discovery", func(t *testing.T) {
+		svc := &HTTPRestService{
+			mtuSettings: configuration.MTUSettings{Enable: true, HostNICMTU: 4000},
+			interfaceMTUByIP: func(string) (int, error) {
+				t.Fatal("discovery should not be called")
+				return 0, nil
+			},
+		}
+		info := podIPInfo()
+		svc.updatePodIPInfoWithMTU(info)
+		assert.Equal(t, 4000, info[0].MTU)
+	})
+
+	t.Run("discovery failure leaves mtu unset", func(t *testing.T) {
+		svc := &HTTPRestService{
+			mtuSettings: configuration.MTUSettings{Enable: true},
+			interfaceMTUByIP: func(string) (int, error) {
+				return 0, errors.New("boom")
+			},
+		}
+		info := podIPInfo()
+		svc.updatePodIPInfoWithMTU(info)
+		assert.Zero(t, info[0].MTU)
+	})
+
+	t.Run("discovery failure of one interface does not stop the others", func(t *testing.T) {
+		svc := &HTTPRestService{
+			mtuSettings: configuration.MTUSettings{Enable: true},
+			interfaceMTUByIP: func(ip string) (int, error) {
+				if ip == "10.0.0.4" {
+					return 0, errors.New("boom")
+				}
+				return 1500, nil
+			},
+		}
+		info := []cns.PodIpInfo{
+			{NICType: cns.InfraNIC, HostPrimaryIPInfo: cns.HostIPInfo{PrimaryIP: "10.0.0.4"}},
+			{NICType: cns.DelegatedVMNIC, HostPrimaryIPInfo: cns.HostIPInfo{PrimaryIP: "10.1.0.4"}},
+			{NICType: cns.InfraNIC, HostPrimaryIPInfo: cns.HostIPInfo{PrimaryIP: "10.2.0.4"}},
+		}
+		svc.updatePodIPInfoWithMTU(info)
+		assert.Zero(t, info[0].MTU)
+		assert.Equal(t, 1500, info[1].MTU)
+		assert.Equal(t, 1500, info[2].MTU)
+	})
+}
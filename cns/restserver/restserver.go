@@ -10,6 +10,7 @@ import (
 
 	"github.com/Azure/azure-container-networking/cns"
 	"github.com/Azure/azure-container-networking/cns/common"
+	"github.com/Azure/azure-container-networking/cns/configuration"
 	"github.com/Azure/azure-container-networking/cns/dockerclient"
 	"github.com/Azure/azure-container-networking/cns/imds"
 	"github.com/Azure/azure-container-networking/cns/logger"
@@ -46,6 +47,11 @@ type nmagentClient interface {
 	GetInterfaceIPInfo(ctx context.Context) (nma.Interfaces, error)
 }
 
+// breakerStateGetter is implemented by NMAgent clients that circuit break their endpoints.
+type breakerStateGetter interface {
+	BreakerStates() map[string]nma.BreakerState
+}
+
 type wireserverProxy interface {
 	JoinNetwork(ctx context.Context, vnetID string) (*http.Response, error)
 	PublishNC(ctx context.Context, ncParams cns.NetworkContainerParameters, payload []byte) (*http.Response, error)
@@ -122,6 +128,17 @@ type HTTPRestService struct {
 	mtpncClient                mtpncClient
 	nodeinfoClient             nodeinfoClient
 	nodeName                   string
+	mtuSettings                configuration.MTUSettings
+	interfaceMTUByIP           func(ip string) (int, error)
+	ipHistory                  ipHistory
+	poolSnapshot               func() cns.IpamPoolMonitorStateSnapshot
+	ipAllocationFailures       ipAllocationFailures
+}
+
+type ipHistory interface {
+	Assigned(ip string, podInfo cns.PodInfo)
+	Released(ips ...string)
+	Query(ip string, from, to time.Time) []cns.IPAssignmentRecord
 }
 
 type CNIConflistGenerator interface {
@@ -320,8 +337,11 @@ func (service *HTTPRestService) Init(config *common.ServiceConfig) error {
 	listener.AddHandler(cns.PathDebugIPAddresses, service.HandleDebugIPAddresses)
 	listener.AddHandler(cns.PathDebugPodContext, service.HandleDebugPodContext)
 	listener.AddHandler(cns.PathDebugRestData, service.HandleDebugRestData)
+	listener.AddHandler(cns.PathDebugIPHistory, service.HandleDebugIPHistory)
+	listener.AddHandler(cns.PathDebugIPAllocationFailure, service.HandleDebugIPAllocationFailure)
 	listener.AddHandler(cns.NetworkContainersURLPath, service.getOrRefreshNetworkContainers)
 	listener.AddHandler(cns.GetHomeAz, service.getHomeAz)
+	listener.AddHandler(cns.GetHealthReportPath, service.getHealthReport)
 	listener.AddHandler(cns.EndpointPath, service.EndpointHandlerAPI)
 	listener.AddHandler(cns.GetNICResources, service.getNICResources)
 	listener.AddHandler(cns.RequestClaimResourceInfo, service.requestClaimResourceInfo)
@@ -422,6 +442,11 @@ func (service *HTTPRestService) AttachIPConfigsHandlerMiddleware(middleware cns.
 	service.IPConfigsHandlerMiddleware = middleware
 }
 
+// AttachIPHistory makes the service record every IP assignment and release in the history.
+func (service *HTTPRestService) AttachIPHistory(history ipHistory) {
+	service.ipHistory = history
+}
+
 func (service *HTTPRestService) AttachNICNCClient(client nicncClient) {
 	service.nicncClient = client
 }
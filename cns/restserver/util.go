@@ -387,6 +387,10 @@ func (service *HTTPRestService) removeToBeDeletedIPStateUntransacted(
 	logger.Printf("[Azure-Cns] Delete the PodIpConfigState, IpId: %s, IPConfigStatus: %v",
 		ipID,
 		service.PodIPConfigState[ipID])
+	if ipConfigStatus, exists := service.PodIPConfigState[ipID]; exists && service.ipHistory != nil &&
+		ipConfigStatus.GetState() == types.Assigned {
+		service.ipHistory.Released(ipConfigStatus.IPAddress)
+	}
 	delete(service.PodIPConfigState, ipID)
 	return 0, ""
 }
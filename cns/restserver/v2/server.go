@@ -2,26 +2,64 @@ package v2
 
 import (
 	"context"
+	"io/fs"
+	"net"
 	"net/http"
+	"os"
 
 	"github.com/Azure/azure-container-networking/cns"
 	"github.com/Azure/azure-container-networking/cns/logger"
 	"github.com/Azure/azure-container-networking/cns/restserver"
+	acn "github.com/Azure/azure-container-networking/common"
 	"github.com/labstack/echo/v4"
 	"github.com/pkg/errors"
 )
 
 type Server struct {
 	*restserver.HTTPRestService
+	authorizer acn.RequestAuthorizer
 }
 
 func New(s *restserver.HTTPRestService) *Server {
-	return &Server{s}
+	return &Server{HTTPRestService: s}
+}
+
+// SetAuthorizer authorizes every request served by the local server. It must be called before Start.
+func (s *Server) SetAuthorizer(authorizer acn.RequestAuthorizer) {
+	s.authorizer = authorizer
 }
 
 func (s Server) Start(ctx context.Context, addr string) error {
+	return s.serve(ctx, s.newEcho(), addr)
+}
+
+// StartUnix serves the same API as Start on a Unix socket, which identifies its callers by their
+// peer credentials. A socket left behind by a previous run is replaced.
+func (s Server) StartUnix(ctx context.Context, socketPath string) error {
+	if err := os.Remove(socketPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return errors.Wrap(err, "failed to remove stale socket")
+	}
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to listen on socket")
+	}
+	// only privileged callers, such as the CNI plugin, may connect
+	if err := os.Chmod(socketPath, 0o600); err != nil {
+		_ = l.Close()
+		return errors.Wrap(err, "failed to restrict socket permissions")
+	}
+	e := s.newEcho()
+	e.Listener = l
+	return s.serve(ctx, e, "")
+}
+
+func (s Server) newEcho() *echo.Echo {
 	e := echo.New()
 	e.HideBanner = true
+	if s.authorizer != nil {
+		e.Use(echo.WrapMiddleware(s.authorizer.Middleware))
+		e.Server.ConnContext = s.authorizer.ConnContext
+	}
 	e.POST(cns.RequestIPConfig, echo.WrapHandler(restserver.NewHandlerFuncWithHistogram(s.RequestIPConfigHandler, restserver.HTTPRequestLatency)))
 	e.POST(cns.RequestIPConfigs, echo.WrapHandler(restserver.NewHandlerFuncWithHistogram(s.RequestIPConfigsHandler, restserver.HTTPRequestLatency)))
 	e.POST(cns.ReleaseIPConfig, echo.WrapHandler(restserver.NewHandlerFuncWithHistogram(s.ReleaseIPConfigHandler, restserver.HTTPRequestLatency)))
@@ -39,7 +77,10 @@ func (s Server) Start(ctx context.Context, addr string) error {
 	e.POST(cns.V2Prefix+cns.GetAllNetworkContainers, echo.WrapHandler(http.HandlerFunc(s.GetAllNetworkContainers)))
 	e.POST(cns.V2Prefix+cns.CreateHostNCApipaEndpointPath, echo.WrapHandler(http.HandlerFunc(s.CreateHostNCApipaEndpoint)))
 	e.POST(cns.V2Prefix+cns.DeleteHostNCApipaEndpointPath, echo.WrapHandler(http.HandlerFunc(s.DeleteHostNCApipaEndpoint)))
+	return e
+}
 
+func (s Server) serve(ctx context.Context, e *echo.Echo, addr string) error {
 	if err := e.Start(addr); err != nil {
 		logger.Errorf("failed to run echo server due to %+v", err)
 		return errors.Wrap(err, "failed to start echo server")
@@ -0,0 +1,77 @@
+package v2
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/authz"
+	"github.com/Azure/azure-container-networking/cns/common"
+	"github.com/Azure/azure-container-networking/cns/fakes"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/cns/restserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartUnixIdentifiesCallersByUID(t *testing.T) {
+	logger.InitLogger("testlogs", 0, 0, "./")
+	service, err := restserver.NewHTTPRestService(&common.ServiceConfig{}, &fakes.WireserverClientFake{},
+		&fakes.WireserverProxyFake{}, &restserver.IPtablesProvider{}, &fakes.NMAgentClientFake{}, nil, nil, nil,
+		fakes.NewMockIMDSClient())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		rules      []authz.Rule
+		wantDenied bool
+	}{
+		{
+			name:  "rule matches the caller's uid",
+			rules: []authz.Rule{{UIDs: []uint32{uint32(os.Getuid())}, Profile: authz.ProfileDebug}},
+		},
+		{
+			name:       "no rule matches the caller's uid",
+			rules:      []authz.Rule{{UIDs: []uint32{uint32(os.Getuid()) + 1}, Profile: authz.ProfileDebug}},
+			wantDenied: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authorizer, err := authz.New(&authz.Settings{Enable: true, Rules: tt.rules})
+			require.NoError(t, err)
+			server := New(service)
+			server.SetAuthorizer(authorizer)
+
+			socketPath := filepath.Join(t.TempDir(), "cni.sock")
+			ctx, cancel := context.WithCancel(context.Background())
+			t.Cleanup(cancel)
+			go func() { _ = server.StartUnix(ctx, socketPath) }()
+
+			client := &http.Client{Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socketPath)
+				},
+			}}
+			var resp *http.Response
+			require.Eventually(t, func() bool {
+				req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost"+cns.PathDebugIPAddresses, http.NoBody)
+				require.NoError(t, err)
+				resp, err = client.Do(req) //nolint:bodyclose // closed below
+				return err == nil
+			}, 5*time.Second, 10*time.Millisecond)
+			defer resp.Body.Close()
+			assert.Equal(t, tt.wantDenied, resp.StatusCode == http.StatusForbidden)
+
+			info, err := os.Stat(socketPath)
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+		})
+	}
+}
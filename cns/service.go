@@ -35,6 +35,9 @@ type Service struct {
 	*common.Service
 	EndpointType string
 	Listener     *acn.Listener
+	// ctx is cancelled on Uninitialize, stopping the background work of the listener such as TLS reloads.
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // NewService creates a new Service object.
@@ -44,8 +47,11 @@ func NewService(name, version, channelMode string, store store.KeyValueStore) (*
 		return nil, err
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Service{
 		Service: service,
+		ctx:     ctx,
+		cancel:  cancel,
 	}, nil
 }
 
@@ -102,6 +108,10 @@ func (service *Service) AddListener(config *common.ServiceConfig) error {
 		return errors.Wrap(err, "Failed to construct url for node listener")
 	}
 
+	if config.Authorizer != nil {
+		nodeListener.SetAuthorizer(config.Authorizer)
+	}
+
 	// only use TLS connection for DNC/CNS listener:
 	if config.TLSSettings.TLSPort != "" {
 		// listener.URL.Host will always be hostname:port, passed in to CNS via CNS command
@@ -111,7 +121,7 @@ func (service *Service) AddListener(config *common.ServiceConfig) error {
 		tlsAddress := net.JoinHostPort(hostParts[0], config.TLSSettings.TLSPort)
 
 		// Start the listener and HTTP and HTTPS server.
-		tlsConfig, err := getTLSConfig(config.TLSSettings, config.ErrChan) //nolint
+		tlsConfig, err := getTLSConfig(service.ctx, config.TLSSettings, config.ErrChan) //nolint
 		if err != nil {
 			logger.Printf("Failed to compose Tls Configuration with error: %+v", err)
 			return errors.Wrap(err, "could not get tls config")
@@ -144,13 +154,13 @@ func (service *Service) Initialize(config *common.ServiceConfig) error {
 	return nil
 }
 
-func getTLSConfig(tlsSettings localtls.TlsSettings, errChan chan<- error) (*tls.Config, error) {
+func getTLSConfig(ctx context.Context, tlsSettings localtls.TlsSettings, errChan chan<- error) (*tls.Config, error) {
 	if tlsSettings.TLSCertificatePath != "" {
-		return getTLSConfigFromFile(tlsSettings)
+		return getTLSConfigFromFile(ctx, tlsSettings)
 	}
 
 	if tlsSettings.KeyVaultURL != "" {
-		return getTLSConfigFromKeyVault(tlsSettings, errChan)
+		return getTLSConfigFromKeyVault(ctx, tlsSettings, errChan)
 	}
 
 	return nil, errors.Errorf("invalid tls settings: %+v", tlsSettings)
@@ -204,7 +214,34 @@ func maskHalf(s string) string {
 	return s[:half] + strings.Repeat("*", n-half)
 }
 
-func getTLSConfigFromFile(tlsSettings localtls.TlsSettings) (*tls.Config, error) {
+// getTLSConfigFromFile returns a TLS config which reloads the certificate until ctx is done.
+func getTLSConfigFromFile(ctx context.Context, tlsSettings localtls.TlsSettings) (*tls.Config, error) {
+	minTLSVersionNumber, err := parseTLSVersionName(tlsSettings.MinTLSVersion)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing MinTLSVersion from config")
+	}
+
+	// the certificate file is re-read on every reload so that a rotated certificate is picked up
+	rotator := &tlsRotator{
+		base:         baseTLSConfig(tlsSettings, minTLSVersionNumber),
+		loadCert:     func() (*tls.Certificate, error) { return loadCertificateFromFile(tlsSettings) },
+		useMTLS:      tlsSettings.UseMTLS,
+		caBundlePath: tlsSettings.TLSCABundlePath,
+	}
+	tlsConfig, err := rotator.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if tlsSettings.TLSRefreshInterval > 0 {
+		go rotator.watch(ctx, tlsSettings.TLSRefreshInterval)
+	}
+	logger.Debugf("TLS configured successfully from file: %+v", tlsSettings)
+
+	return tlsConfig, nil
+}
+
+func loadCertificateFromFile(tlsSettings localtls.TlsSettings) (*tls.Certificate, error) {
 	tlsCertRetriever, err := localtls.GetTlsCertificateRetriever(tlsSettings)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get certificate retriever")
@@ -224,42 +261,30 @@ func getTLSConfigFromFile(tlsSettings localtls.TlsSettings) (*tls.Config, error)
 		return nil, errors.Wrap(err, "failed to get certificate private key")
 	}
 
-	tlsCert := tls.Certificate{
+	return &tls.Certificate{
 		Certificate: [][]byte{leafCertificate.Raw},
 		PrivateKey:  privateKey,
 		Leaf:        leafCertificate,
-	}
-	minTLSVersionNumber, err := parseTLSVersionName(tlsSettings.MinTLSVersion)
-	if err != nil {
-		return nil, errors.Wrap(err, "parsing MinTLSVersion from config")
-	}
+	}, nil
+}
 
+// baseTLSConfig returns the TLS config shared by every handshake, without certificates or CAs.
+func baseTLSConfig(tlsSettings localtls.TlsSettings, minTLSVersionNumber uint16) *tls.Config {
 	tlsConfig := &tls.Config{
 		MaxVersion: tls.VersionTLS13,
 		MinVersion: minTLSVersionNumber,
-		Certificates: []tls.Certificate{
-			tlsCert,
-		},
 	}
 
 	if tlsSettings.UseMTLS {
-		rootCAs, err := mtlsRootCAsFromCertificate(&tlsCert)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to get root CAs for configuring mTLS")
-		}
 		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
-		tlsConfig.ClientCAs = rootCAs
-		tlsConfig.RootCAs = rootCAs
 		tlsConfig.VerifyPeerCertificate = func(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
 			return verifyPeerCertificate(verifiedChains, tlsSettings.MtlsClientCertSubjectName)
 		}
 	}
-	logger.Debugf("TLS configured successfully from file: %+v", tlsSettings)
-
-	return tlsConfig, nil
+	return tlsConfig
 }
 
-func getTLSConfigFromKeyVault(tlsSettings localtls.TlsSettings, errChan chan<- error) (*tls.Config, error) {
+func getTLSConfigFromKeyVault(ctx context.Context, tlsSettings localtls.TlsSettings, errChan chan<- error) (*tls.Config, error) {
 	credOpts := azidentity.ManagedIdentityCredentialOptions{ID: azidentity.ResourceID(tlsSettings.MSIResourceID)}
 	cred, err := azidentity.NewManagedIdentityCredential(&credOpts)
 	if err != nil {
@@ -271,15 +296,15 @@ func getTLSConfigFromKeyVault(tlsSettings localtls.TlsSettings, errChan chan<- e
 		return nil, errors.Wrap(err, "could not create new keyvault shim")
 	}
 
-	ctx := context.TODO()
+	refreshCtx := context.TODO()
 
-	cr, err := keyvault.NewCertRefresher(ctx, kvs, logger.Log, tlsSettings.KeyVaultCertificateName)
+	cr, err := keyvault.NewCertRefresher(refreshCtx, kvs, logger.Log, tlsSettings.KeyVaultCertificateName)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not create new cert refresher")
 	}
 
 	go func() {
-		errChan <- cr.Refresh(ctx, tlsSettings.KeyVaultCertificateRefreshInterval)
+		errChan <- cr.Refresh(refreshCtx, tlsSettings.KeyVaultCertificateRefreshInterval)
 	}()
 
 	minTLSVersionNumber, err := parseTLSVersionName(tlsSettings.MinTLSVersion)
@@ -287,31 +312,28 @@ func getTLSConfigFromKeyVault(tlsSettings localtls.TlsSettings, errChan chan<- e
 		return nil, errors.Wrap(err, "parsing MinTLSVersion from config")
 	}
 
-	tlsConfig := tls.Config{
-		MinVersion: minTLSVersionNumber,
-		MaxVersion: tls.VersionTLS13,
-		GetCertificate: func(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
-			return cr.GetCertificate(), nil
-		},
+	// the cert refresher swaps in a new certificate when it is rotated in KeyVault, so the
+	// rotator reloads as soon as a handshake sees a certificate it is not serving yet
+	rotator := &tlsRotator{
+		base:         baseTLSConfig(tlsSettings, minTLSVersionNumber),
+		loadCert:     func() (*tls.Certificate, error) { return cr.GetCertificate(), nil },
+		changed:      func(current *tls.Certificate) bool { return cr.GetCertificate() != current },
+		useMTLS:      tlsSettings.UseMTLS,
+		caBundlePath: tlsSettings.TLSCABundlePath,
+	}
+	tlsConfig, err := rotator.tlsConfig()
+	if err != nil {
+		return nil, err
 	}
 
-	if tlsSettings.UseMTLS {
-		tlsCert := cr.GetCertificate()
-		rootCAs, err := mtlsRootCAsFromCertificate(tlsCert)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to get root CAs for configuring mTLS")
-		}
-		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
-		tlsConfig.ClientCAs = rootCAs
-		tlsConfig.RootCAs = rootCAs
-		tlsConfig.VerifyPeerCertificate = func(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
-			return verifyPeerCertificate(verifiedChains, tlsSettings.MtlsClientCertSubjectName)
-		}
+	// the CA bundle is only picked up by polling
+	if tlsSettings.UseMTLS && tlsSettings.TLSCABundlePath != "" && tlsSettings.TLSRefreshInterval > 0 {
+		go rotator.watch(ctx, tlsSettings.TLSRefreshInterval)
 	}
 
 	logger.Debugf("TLS configured successfully from KV: %+v", tlsSettings)
 
-	return &tlsConfig, nil
+	return tlsConfig, nil
 }
 
 // Given a TLS cert, return the root CAs
@@ -364,6 +386,9 @@ func (service *Service) StartListener(config *common.ServiceConfig) error {
 
 // Uninitialize cleans up the plugin.
 func (service *Service) Uninitialize() {
+	if service.cancel != nil {
+		service.cancel()
+	}
 	service.Listener.Stop()
 	service.Service.Uninitialize()
 }
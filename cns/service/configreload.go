@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns/configuration"
+)
+
+// configReloadInterval is how often the config file is checked for changes when EnableConfigReload is set.
+const configReloadInterval = 30 * time.Second
+
+// configWatcher applies changes to the config file at runtime. It is nil unless EnableConfigReload is set.
+var configWatcher *configuration.Watcher
+
+// runWithConfig runs fn in a goroutine with the config, and restarts it with the updated config whenever
+// a change to one of the fields is applied at runtime. With no fields, fn is restarted after every change.
+// fn must return soon after its context is cancelled: it is restarted only once the previous run has returned,
+// so that two runs never overlap.
+func runWithConfig(ctx context.Context, config *configuration.CNSConfig, fields []string, fn func(context.Context, *configuration.CNSConfig)) {
+	if configWatcher == nil {
+		go fn(ctx, config)
+		return
+	}
+
+	var mu sync.Mutex
+	runCtx, cancel := context.WithCancel(ctx)
+	done := startRun(runCtx, configWatcher.Current(), fn)
+	configWatcher.OnChange(func(_, updated *configuration.CNSConfig, applied []string) {
+		if !anyFieldIn(fields, applied) {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		cancel()
+		<-done
+		if ctx.Err() != nil {
+			return
+		}
+		runCtx, cancel = context.WithCancel(ctx) //nolint:govet // cancelled on the next change or with the parent context
+		done = startRun(runCtx, updated, fn)
+	})
+}
+
+// startRun runs fn in a goroutine and returns a channel which is closed once it returns.
+func startRun(ctx context.Context, config *configuration.CNSConfig, fn func(context.Context, *configuration.CNSConfig)) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(ctx, config)
+	}()
+	return done
+}
+
+func anyFieldIn(fields, applied []string) bool {
+	if len(fields) == 0 {
+		return true
+	}
+	for _, f := range fields {
+		for _, a := range applied {
+			if f == a {
+				return true
+			}
+		}
+	}
+	return false
+}
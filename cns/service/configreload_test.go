@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns/configuration"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunWithConfigRestartsOnChange(t *testing.T) {
+	logger.InitLogger("azure-cns.log", 0, 0, "/")
+	path := filepath.Join(t.TempDir(), "cns_config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{}`), 0o600))
+	config := &configuration.CNSConfig{}
+	configuration.SetCNSConfigDefaults(config)
+
+	var err error
+	configWatcher, err = configuration.NewWatcher(path, config)
+	require.NoError(t, err)
+	t.Cleanup(func() { configWatcher = nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	intervals := make(chan int, 2)
+	runWithConfig(ctx, config, []string{"SyncHostNCVersionIntervalMs"}, func(ctx context.Context, c *configuration.CNSConfig) {
+		intervals <- c.SyncHostNCVersionIntervalMs
+		<-ctx.Done()
+	})
+	assert.Equal(t, 1000, <-intervals)
+
+	// a change to another field does not restart
+	b, err := json.Marshal(map[string]any{"TelemetrySettings": map[string]any{"HeartBeatIntervalInMins": 5}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, b, 0o600))
+	_, err = configWatcher.Reload()
+	require.NoError(t, err)
+	select {
+	case <-intervals:
+		t.Fatal("should not restart on an unrelated change")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	b, err = json.Marshal(map[string]any{"SyncHostNCVersionIntervalMs": 2000})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, b, 0o600))
+	_, err = configWatcher.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, 2000, <-intervals)
+}
+
+func TestRunWithConfigWaitsForThePreviousRun(t *testing.T) {
+	logger.InitLogger("azure-cns.log", 0, 0, "/")
+	path := filepath.Join(t.TempDir(), "cns_config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{}`), 0o600))
+	config := &configuration.CNSConfig{}
+	configuration.SetCNSConfigDefaults(config)
+
+	var err error
+	configWatcher, err = configuration.NewWatcher(path, config)
+	require.NoError(t, err)
+	t.Cleanup(func() { configWatcher = nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var running atomic.Int32
+	started := make(chan int32, 2)
+	runWithConfig(ctx, config, nil, func(ctx context.Context, _ *configuration.CNSConfig) {
+		started <- running.Add(1)
+		<-ctx.Done()
+		// a slow shutdown must not overlap with the next run
+		time.Sleep(50 * time.Millisecond)
+		running.Add(-1)
+	})
+	assert.Equal(t, int32(1), <-started)
+
+	b, err := json.Marshal(map[string]any{"SyncHostNCVersionIntervalMs": 2000})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, b, 0o600))
+	_, err = configWatcher.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), <-started)
+}
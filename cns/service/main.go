@@ -20,6 +20,7 @@ import (
 
 	"github.com/Azure/azure-container-networking/aitelemetry"
 	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/authz"
 	cnsclient "github.com/Azure/azure-container-networking/cns/client"
 	cnscli "github.com/Azure/azure-container-networking/cns/cmd/cli"
 	"github.com/Azure/azure-container-networking/cns/cniconflist"
@@ -35,10 +36,13 @@ import (
 	"github.com/Azure/azure-container-networking/cns/ipampool"
 	"github.com/Azure/azure-container-networking/cns/ipampool/metrics"
 	ipampoolv2 "github.com/Azure/azure-container-networking/cns/ipampool/v2"
+	"github.com/Azure/azure-container-networking/cns/ipamreport"
+	"github.com/Azure/azure-container-networking/cns/iphistory"
 	cssctrl "github.com/Azure/azure-container-networking/cns/kubecontroller/clustersubnetstate"
 	mtpncctrl "github.com/Azure/azure-container-networking/cns/kubecontroller/multitenantpodnetworkconfig"
 	nicncctrl "github.com/Azure/azure-container-networking/cns/kubecontroller/nicnetworkconfig"
 	nncctrl "github.com/Azure/azure-container-networking/cns/kubecontroller/nodenetworkconfig"
+	oecctrl "github.com/Azure/azure-container-networking/cns/kubecontroller/overlayextensionconfig"
 	podctrl "github.com/Azure/azure-container-networking/cns/kubecontroller/pod"
 	"github.com/Azure/azure-container-networking/cns/logger"
 	loggerv2 "github.com/Azure/azure-container-networking/cns/logger/v2"
@@ -59,6 +63,7 @@ import (
 	mtv1alpha1 "github.com/Azure/azure-container-networking/crd/multitenancy/api/v1alpha1"
 	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig"
 	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig/api/v1alpha"
+	oecv1alpha1 "github.com/Azure/azure-container-networking/crd/overlayextensionconfig/api/v1alpha1"
 	acnfs "github.com/Azure/azure-container-networking/internal/fs"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/nmagent"
@@ -95,6 +100,7 @@ const (
 	name                              = "azure-cns"
 	pluginName                        = "azure-vnet"
 	endpointStoreName                 = "azure-endpoints"
+	ipHistoryStoreName                = "azure-cns-iphistory"
 	endpointStoreLocationLinux        = "/var/run/azure-cns/"
 	endpointStoreLocationWindows      = "/k/azurecns/"
 	defaultCNINetworkConfigFileName   = "10-azure.conflist"
@@ -568,6 +574,14 @@ func main() {
 	}
 	configuration.SetCNSConfigDefaults(cnsconfig)
 
+	if cnsconfig.EnableConfigReload {
+		configWatcher, err = configuration.NewWatcher(cmdLineConfigPath, cnsconfig)
+		if err != nil {
+			logger.Errorf("Failed to create config watcher, err:%v.\n", err)
+			return
+		}
+	}
+
 	disableTelemetry := cnsconfig.TelemetrySettings.DisableAll
 	if !disableTelemetry {
 		ts := cnsconfig.TelemetrySettings
@@ -592,9 +606,12 @@ func main() {
 			logger.InitAI(aiConfig, ts.DisableTrace, ts.DisableMetric, ts.DisableEvent)
 		}
 
-		if cnsconfig.TelemetrySettings.ConfigSnapshotIntervalInMins > 0 {
-			go metric.SendCNSConfigSnapshot(rootCtx, cnsconfig)
-		}
+		// re-emit the config snapshot after every change to the config
+		runWithConfig(rootCtx, cnsconfig, nil, func(ctx context.Context, c *configuration.CNSConfig) {
+			if c.TelemetrySettings.ConfigSnapshotIntervalInMins > 0 {
+				metric.SendCNSConfigSnapshot(ctx, c)
+			}
+		})
 	}
 	logger.Printf("[Azure CNS] Using config: %+v", cnsconfig)
 
@@ -666,6 +683,16 @@ func main() {
 		os.Exit(1)
 	}
 	z = z.With(hostMetadataFields...)
+	if configWatcher != nil {
+		configWatcher.OnChange(func(_, updated *configuration.CNSConfig, applied []string) {
+			if !anyFieldIn([]string{"Logger.Level"}, applied) {
+				return
+			}
+			if err := loggerv2.SetLevel(updated.Logger.Level); err != nil {
+				logger.Errorf("Failed to set log level, err:%v.", err)
+			}
+		})
+	}
 	config.Logger = z.With(zap.String("module", "cns service"))
 	// Set the v2 logger to the global logger if v2 logger enabled.
 	if cnsconfig.EnableLoggerV2 {
@@ -698,6 +725,7 @@ func main() {
 		logger.Errorf("[Azure CNS] Failed to produce NMAgent config from the supplied wireserver ip: %v", err)
 		return
 	}
+	nmaConfig.OnBreakerStateChange = restserver.RecordNMAgentBreakerState
 
 	nmaClient, err := nmagent.NewClient(nmaConfig)
 	if err != nil {
@@ -807,6 +835,30 @@ func main() {
 	httpRemoteRestService.SetOption(acn.OptProgramSNATIPTables, cnsconfig.ProgramSNATIPTables)
 	httpRemoteRestService.SetOption(acn.OptManageEndpointState, cnsconfig.ManageEndpointState)
 	httpRemoteRestService.SetOption(acn.OptEnableStaleHNSCleanupOnNCCreate, cnsconfig.EnableStaleHNSCleanupOnNCCreate)
+	httpRemoteRestService.SetMTUSettings(cnsconfig.MTUSettings)
+
+	// Record the assignment history of IPs to pods if enabled. This must be attached before CNS restores its IPAM
+	// state, so that IPs already assigned are attributed to their pods.
+	if cnsconfig.EnableIPHistory {
+		historyStoreFileName := storeFileLocation + ipHistoryStoreName + ".json"
+		historyStore, err := store.NewJsonFileStore(historyStoreFileName, nil, nil)
+		if err != nil {
+			logger.Errorf("Failed to create ip history store file: %s, due to error %v\n", historyStoreFileName, err)
+			return
+		}
+		history, err := iphistory.New(z, historyStore, iphistory.Config{}, iphistory.LogRecord)
+		if err != nil {
+			logger.Errorf("Failed to load ip history, err:%v.\n", err)
+			return
+		}
+		httpRemoteRestService.AttachIPHistory(history)
+		go func() {
+			if err := history.Run(rootCtx); err != nil {
+				logger.Errorf("[Azure CNS] %v", err)
+			}
+		}()
+		logger.Printf("[Azure CNS] IP history enabled, stored in %s", historyStoreFileName)
+	}
 
 	// Create default ext network if commandline option is set
 	if len(strings.TrimSpace(createDefaultExtNetworkType)) > 0 {
@@ -832,9 +884,20 @@ func main() {
 				UseMTLS:                            cnsconfig.UseMTLS,
 				MinTLSVersion:                      cnsconfig.MinTLSVersion,
 				MtlsClientCertSubjectName:          cnsconfig.MtlsClientCertSubjectName,
+				TLSCABundlePath:                    cnsconfig.TLSCABundlePath,
+				TLSRefreshInterval:                 time.Duration(cnsconfig.TLSRefreshIntervalSecs) * time.Second,
 			}
 		}
 
+		if cnsconfig.AuthzSettings.Enable {
+			authorizer, authzErr := authz.New(&cnsconfig.AuthzSettings)
+			if authzErr != nil {
+				logger.Errorf("Failed to create authorizer, err:%v.\n", authzErr)
+				return
+			}
+			config.Authorizer = authorizer
+		}
+
 		err = httpRemoteRestService.Init(&config)
 		if err != nil {
 			logger.Errorf("Failed to init HTTPService, err:%v.\n", err)
@@ -1059,6 +1122,9 @@ func main() {
 
 		httpLocalRestService := restserverv2.New(httpRemoteRestService)
 		if httpLocalRestService != nil {
+			if config.Authorizer != nil {
+				httpLocalRestService.SetAuthorizer(config.Authorizer)
+			}
 			go func() {
 				err = httpLocalRestService.Start(rootCtx, localServerURL)
 				if err != nil {
@@ -1066,6 +1132,14 @@ func main() {
 					return
 				}
 			}()
+			if cnsconfig.AuthzSettings.Enable && cnsconfig.AuthzSettings.CNISocketPath != "" {
+				logger.Printf("[Azure CNS] Start local server for CNI on %s", cnsconfig.AuthzSettings.CNISocketPath)
+				go func() {
+					if err := httpLocalRestService.StartUnix(rootCtx, cnsconfig.AuthzSettings.CNISocketPath); err != nil {
+						logger.Errorf("Failed to start local echo server for CNI, err:%v.\n", err)
+					}
+				}()
+			}
 		}
 	}
 
@@ -1101,8 +1175,16 @@ func main() {
 	}
 
 	if !disableTelemetry {
-		go metric.SendHeartBeat(rootCtx, time.Minute*time.Duration(cnsconfig.TelemetrySettings.HeartBeatIntervalInMins), homeAzMonitor, cnsconfig.ChannelMode)
-		go httpRemoteRestService.SendNCSnapShotPeriodically(rootCtx, cnsconfig.TelemetrySettings.SnapshotIntervalInMins)
+		runWithConfig(rootCtx, cnsconfig, []string{"TelemetrySettings.HeartBeatIntervalInMins"}, func(ctx context.Context, c *configuration.CNSConfig) {
+			metric.SendHeartBeat(ctx, time.Minute*time.Duration(c.TelemetrySettings.HeartBeatIntervalInMins), homeAzMonitor, c.ChannelMode)
+		})
+		runWithConfig(rootCtx, cnsconfig, []string{"TelemetrySettings.SnapshotIntervalInMins"}, func(ctx context.Context, c *configuration.CNSConfig) {
+			httpRemoteRestService.SendNCSnapShotPeriodically(ctx, c.TelemetrySettings.SnapshotIntervalInMins)
+		})
+	}
+
+	if configWatcher != nil {
+		go configWatcher.Run(rootCtx, configReloadInterval)
 	}
 
 	// If CNS is running on managed DNC mode
@@ -1315,20 +1397,21 @@ func InitializeMultiTenantController(ctx context.Context, httpRestService cns.HT
 
 	// TODO: do we need this to be running?
 	logger.Printf("Starting SyncHostNCVersion")
-	go func() {
+	runWithConfig(ctx, &cnsconfig, []string{"SyncHostNCVersionIntervalMs"}, func(ctx context.Context, c *configuration.CNSConfig) {
 		// Periodically poll vfp programmed NC version from NMAgent
-		tickerChannel := time.Tick(time.Duration(cnsconfig.SyncHostNCVersionIntervalMs) * time.Millisecond)
+		ticker := time.NewTicker(time.Duration(c.SyncHostNCVersionIntervalMs) * time.Millisecond)
+		defer ticker.Stop()
 		for {
 			select {
-			case <-tickerChannel:
-				timedCtx, cancel := context.WithTimeout(ctx, time.Duration(cnsconfig.SyncHostNCVersionIntervalMs)*time.Millisecond)
-				httpRestServiceImpl.SyncHostNCVersion(timedCtx, cnsconfig.ChannelMode)
+			case <-ticker.C:
+				timedCtx, cancel := context.WithTimeout(ctx, time.Duration(c.SyncHostNCVersionIntervalMs)*time.Millisecond)
+				httpRestServiceImpl.SyncHostNCVersion(timedCtx, c.ChannelMode)
 				cancel()
 			case <-ctx.Done():
 				return
 			}
 		}
-	}()
+	})
 
 	return nil
 }
@@ -1479,6 +1562,9 @@ func InitializeCRDState(ctx context.Context, z *zap.Logger, httpRestService cns.
 	if err = mtv1alpha1.AddToScheme(scheme); err != nil {
 		return errors.Wrap(err, "failed to add multitenantpodnetworkconfig/v1alpha1 to scheme")
 	}
+	if err = oecv1alpha1.AddToScheme(scheme); err != nil {
+		return errors.Wrap(err, "failed to add overlayextensionconfig/v1alpha1 to scheme")
+	}
 
 	// Set Selector options on the Manager cache which are used
 	// to perform *server-side* filtering of the cached objects. This is very important
@@ -1555,6 +1641,7 @@ func InitializeCRDState(ctx context.Context, z *zap.Logger, httpRestService cns.
 		}
 		poolMonitor = ipampool.NewMonitor(httpRestServiceImplementation, cachedscopedcli, cssCh, &poolOpts)
 	}
+	httpRestServiceImplementation.AttachPoolMonitorSnapshot(poolMonitor.GetStateSnapshot)
 
 	// Start building the NNC Reconciler
 
@@ -1577,6 +1664,14 @@ func InitializeCRDState(ctx context.Context, z *zap.Logger, httpRestService cns.
 		}
 	}
 
+	if cnsconfig.EnableOverlayExtensionConfig {
+		// OverlayExtensionConfig reconciler
+		oecReconciler := oecctrl.New(nodeName, z)
+		if err := oecReconciler.SetupWithManager(manager); err != nil {
+			return errors.Wrapf(err, "failed to setup oec reconciler with manager")
+		}
+	}
+
 	// TODO: add pod listeners based on Swift V1 vs MT/V2 configuration
 	if cnsconfig.WatchPods {
 		pw := podctrl.New(z)
@@ -1630,6 +1725,19 @@ func InitializeCRDState(ctx context.Context, z *zap.Logger, httpRestService cns.
 	}()
 	logger.Printf("initialized and started IPAM pool monitor")
 
+	if cnsconfig.EnableIPAMReport {
+		reportPublisher := ipamreport.NewPublisher(z, ipamreport.DefaultInterval, ipamreport.Sources{
+			IPConfigs:    httpRestServiceImplementation.GetPodIPConfigState,
+			NCVersions:   httpRestServiceImplementation.GetNCVersionReports,
+			PoolSnapshot: poolMonitor.GetStateSnapshot,
+		}, cachedscopedcli)
+		go func() {
+			if e := reportPublisher.Start(ctx); e != nil {
+				logger.Errorf("[Azure CNS] IPAM report publisher exited with err: %v", e)
+			}
+		}()
+	}
+
 	// Start the Manager which starts the reconcile loop.
 	// The Reconciler will send an initial NodeNetworkConfig update to the PoolMonitor, starting the
 	// Monitor's internal loop.
@@ -1663,22 +1771,23 @@ func InitializeCRDState(ctx context.Context, z *zap.Logger, httpRestService cns.
 		break
 	}
 
-	go func() {
+	runWithConfig(ctx, cnsconfig, []string{"SyncHostNCVersionIntervalMs"}, func(ctx context.Context, c *configuration.CNSConfig) {
 		logger.Printf("Starting SyncHostNCVersion loop.")
 		// Periodically poll vfp programmed NC version from NMAgent
-		tickerChannel := time.Tick(time.Duration(cnsconfig.SyncHostNCVersionIntervalMs) * time.Millisecond)
+		ticker := time.NewTicker(time.Duration(c.SyncHostNCVersionIntervalMs) * time.Millisecond)
+		defer ticker.Stop()
 		for {
 			select {
-			case <-tickerChannel:
-				timedCtx, cancel := context.WithTimeout(ctx, time.Duration(cnsconfig.SyncHostNCVersionIntervalMs)*time.Millisecond)
-				httpRestServiceImplementation.SyncHostNCVersion(timedCtx, cnsconfig.ChannelMode)
+			case <-ticker.C:
+				timedCtx, cancel := context.WithTimeout(ctx, time.Duration(c.SyncHostNCVersionIntervalMs)*time.Millisecond)
+				httpRestServiceImplementation.SyncHostNCVersion(timedCtx, c.ChannelMode)
 				cancel()
 			case <-ctx.Done():
 				logger.Printf("Stopping SyncHostNCVersion loop.")
 				return
 			}
 		}
-	}()
+	})
 	logger.Printf("Initialized SyncHostNCVersion loop.")
 	return nil
 }
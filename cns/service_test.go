@@ -193,7 +193,7 @@ func TestNewService(t *testing.T) {
 				err = svc.StartListener(config)
 				require.NoError(t, err)
 
-				mTLSConfig, err := getTLSConfigFromFile(config.TLSSettings)
+				mTLSConfig, err := getTLSConfigFromFile(context.TODO(), config.TLSSettings)
 				require.NoError(t, err)
 
 				client := &http.Client{
@@ -434,3 +434,69 @@ func TestMaskHalf(t *testing.T) {
 		})
 	}
 }
+
+func TestTLSRotatorReload(t *testing.T) {
+	logger.InitLogger("azure-cns.log", 0, 0, "/")
+	certPath := createTestCertificate(t)
+	caBundlePath := filepath.Join(t.TempDir(), "ca.pem")
+	caCert, err := os.ReadFile(createTestCertificate(t))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(caBundlePath, caCert, 0o600))
+
+	tlsSettings := serverTLS.TlsSettings{
+		TLSCertificatePath: certPath,
+		TLSSubjectName:     "localhost",
+		UseMTLS:            true,
+		TLSCABundlePath:    caBundlePath,
+	}
+	rotator := &tlsRotator{
+		base:         baseTLSConfig(tlsSettings, tls.VersionTLS12),
+		loadCert:     func() (*tls.Certificate, error) { return loadCertificateFromFile(tlsSettings) },
+		useMTLS:      true,
+		caBundlePath: caBundlePath,
+	}
+	tlsConfig, err := rotator.tlsConfig()
+	require.NoError(t, err)
+	require.NotNil(t, tlsConfig.GetConfigForClient)
+
+	initial, err := tlsConfig.GetConfigForClient(nil)
+	require.NoError(t, err)
+	require.Len(t, initial.Certificates, 1)
+	assert.Equal(t, tlsConfig.Certificates[0].Leaf, initial.Certificates[0].Leaf)
+	initialCAs, _, err := caPoolFromPEM(caCert)
+	require.NoError(t, err)
+	assert.True(t, initialCAs.Equal(initial.ClientCAs), "client CAs should come from the CA bundle")
+
+	// an unchanged source is not swapped
+	require.NoError(t, rotator.reload())
+	same, err := tlsConfig.GetConfigForClient(nil)
+	require.NoError(t, err)
+	assert.Equal(t, initial.Certificates[0].Leaf.SerialNumber, same.Certificates[0].Leaf.SerialNumber)
+	assert.Same(t, initial.ClientCAs, same.ClientCAs, "client CAs should not be rebuilt")
+
+	// rotate the certificate and the CA bundle on disk
+	rotatedCert, err := os.ReadFile(createTestCertificate(t))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certPath, rotatedCert, 0o600))
+	rotatedCA, err := os.ReadFile(createTestCertificate(t))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(caBundlePath, append(caCert, rotatedCA...), 0o600))
+	require.NoError(t, rotator.reload())
+
+	rotated, err := tlsConfig.GetConfigForClient(nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, initial.Certificates[0].Leaf.SerialNumber, rotated.Certificates[0].Leaf.SerialNumber)
+	rotatedCAs, _, err := caPoolFromPEM(append(caCert, rotatedCA...))
+	require.NoError(t, err)
+	assert.True(t, rotatedCAs.Equal(rotated.ClientCAs), "client CAs should be rotated with the bundle")
+	assert.Equal(t, tls.RequireAndVerifyClientCert, rotated.ClientAuth)
+	assert.NotNil(t, rotated.VerifyPeerCertificate)
+
+	// a broken source keeps serving the last good certificate
+	require.NoError(t, os.WriteFile(caBundlePath, []byte("not a certificate"), 0o600))
+	require.Error(t, rotator.reload())
+	current, err := tlsConfig.GetConfigForClient(nil)
+	require.NoError(t, err)
+	assert.Equal(t, rotated.Certificates[0].Leaf.SerialNumber, current.Certificates[0].Leaf.SerialNumber)
+	assert.True(t, rotatedCAs.Equal(current.ClientCAs))
+}
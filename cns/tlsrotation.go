@@ -0,0 +1,250 @@
+package cns
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	tlsCertificateLabel = "certificate"
+	servingCertificate  = "serving"
+	caCertificate       = "ca"
+)
+
+var (
+	// tlsCertificateExpiry is the NotAfter time of the serving certificate and of the earliest
+	// expiring CA trusted for mTLS, as unix seconds.
+	tlsCertificateExpiry = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cns_tls_certificate_expiry_timestamp_seconds",
+			Help: "Expiry time of the TLS certificates currently served by CNS, in unix seconds.",
+		},
+		[]string{tlsCertificateLabel},
+	)
+	// tlsLastRotation is the time the serving certificate or the CA bundle was last swapped in.
+	tlsLastRotation = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cns_tls_last_rotation_timestamp_seconds",
+			Help: "Time the TLS certificate or CA bundle was last rotated, in unix seconds.",
+		},
+	)
+	// tlsReloadFailures counts reloads that failed and left the previous certificates in place.
+	tlsReloadFailures = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cns_tls_reload_failures_total",
+			Help: "Number of times reloading the TLS certificate or CA bundle has failed.",
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		tlsCertificateExpiry,
+		tlsLastRotation,
+		tlsReloadFailures,
+	)
+}
+
+// tlsMaterial is a serving certificate and the CAs trusted for mTLS, which are swapped together.
+type tlsMaterial struct {
+	cert        *tls.Certificate
+	clientCAs   *x509.CertPool
+	fingerprint [sha256.Size]byte
+}
+
+// tlsRotator serves the latest certificate and CA bundle from its source to every new TLS handshake.
+type tlsRotator struct {
+	// base is the config every handshake is served from, without certificates or CAs.
+	base *tls.Config
+	// loadCert returns the current serving certificate from the source.
+	loadCert func() (*tls.Certificate, error)
+	// changed, if set, is checked on every handshake and triggers a reload when it returns true.
+	// Sources which are cheap to query use it to rotate without waiting for the next poll.
+	changed      func(*tls.Certificate) bool
+	useMTLS      bool
+	caBundlePath string
+
+	mu      sync.Mutex
+	current atomic.Pointer[tlsMaterial]
+}
+
+// reload loads the certificate and CA bundle from the source and swaps them in if they have changed.
+// On error the material currently served is left in place.
+func (r *tlsRotator) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cert, err := r.loadCert()
+	if err != nil {
+		return errors.Wrap(err, "failed to load certificate")
+	}
+	if cert == nil || len(cert.Certificate) == 0 {
+		return errors.New("certificate retrieval returned empty")
+	}
+
+	var caBundle []byte
+	if r.useMTLS && r.caBundlePath != "" {
+		if caBundle, err = os.ReadFile(r.caBundlePath); err != nil {
+			return errors.Wrapf(err, "failed to read CA bundle %s", r.caBundlePath)
+		}
+	}
+
+	h := sha256.New()
+	for _, c := range cert.Certificate {
+		h.Write(c)
+	}
+	h.Write(caBundle)
+	var fingerprint [sha256.Size]byte
+	copy(fingerprint[:], h.Sum(nil))
+	if cur := r.current.Load(); cur != nil && cur.fingerprint == fingerprint {
+		if cur.cert != cert {
+			// same certificate re-issued by the source, track it so changed stops firing
+			r.current.Store(&tlsMaterial{cert: cert, clientCAs: cur.clientCAs, fingerprint: fingerprint})
+		}
+		return nil
+	}
+
+	next := &tlsMaterial{cert: cert, fingerprint: fingerprint}
+	if r.useMTLS {
+		var caExpiry time.Time
+		if caBundle != nil {
+			next.clientCAs, caExpiry, err = caPoolFromPEM(caBundle)
+		} else {
+			next.clientCAs, caExpiry, err = mtlsRootCAsWithExpiry(cert)
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to get root CAs for configuring mTLS")
+		}
+		tlsCertificateExpiry.WithLabelValues(caCertificate).Set(float64(caExpiry.Unix()))
+	}
+
+	leaf := cert.Leaf
+	if leaf == nil {
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return errors.Wrap(err, "failed to parse serving certificate")
+		}
+	}
+	tlsCertificateExpiry.WithLabelValues(servingCertificate).Set(float64(leaf.NotAfter.Unix()))
+
+	rotated := r.current.Swap(next) != nil
+	tlsLastRotation.SetToCurrentTime()
+	if rotated {
+		logger.Printf("[Azure CNS] Rotated TLS certificate, serving certificate %s expires at %s", leaf.Subject, leaf.NotAfter)
+	}
+	return nil
+}
+
+// watch reloads the certificate and CA bundle every interval until the context is cancelled.
+func (r *tlsRotator) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.reload(); err != nil {
+				tlsReloadFailures.Inc()
+				logger.Errorf("[Azure CNS] Failed to reload TLS certificate, keeping the current one: %v", err)
+			}
+		}
+	}
+}
+
+// config returns a copy of the base config serving the current certificate and CAs.
+func (r *tlsRotator) config() *tls.Config {
+	m := r.current.Load()
+	cfg := r.base.Clone()
+	cfg.Certificates = []tls.Certificate{*m.cert}
+	if r.useMTLS {
+		cfg.ClientCAs = m.clientCAs
+		cfg.RootCAs = m.clientCAs
+	}
+	return cfg
+}
+
+// getConfigForClient is the tls.Config.GetConfigForClient hook which picks up rotations for every new handshake.
+func (r *tlsRotator) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	if r.changed != nil && r.changed(r.current.Load().cert) {
+		if err := r.reload(); err != nil {
+			tlsReloadFailures.Inc()
+			logger.Errorf("[Azure CNS] Failed to reload TLS certificate, keeping the current one: %v", err)
+		}
+	}
+	return r.config(), nil
+}
+
+// tlsConfig loads the initial certificate and returns the config to serve with. The returned config
+// carries the initial certificate and CAs for callers that read them directly, while handshakes are
+// served the latest ones through GetConfigForClient.
+func (r *tlsRotator) tlsConfig() (*tls.Config, error) {
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	cfg := r.config()
+	cfg.GetConfigForClient = r.getConfigForClient
+	return cfg, nil
+}
+
+// caPoolFromPEM returns a pool of all certificates in the PEM bundle and the earliest expiry among them.
+func caPoolFromPEM(bundle []byte) (*x509.CertPool, time.Time, error) {
+	pool := x509.NewCertPool()
+	var expiry time.Time
+	rest := bytes.TrimSpace(bundle)
+	for len(rest) > 0 {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, time.Time{}, errors.Wrap(err, "parsing CA bundle")
+		}
+		pool.AddCert(cert)
+		if expiry.IsZero() || cert.NotAfter.Before(expiry) {
+			expiry = cert.NotAfter
+		}
+	}
+	if expiry.IsZero() {
+		return nil, time.Time{}, errors.New("no certificates found in CA bundle")
+	}
+	return pool, expiry, nil
+}
+
+// mtlsRootCAsWithExpiry returns the root CAs of the TLS cert and the earliest expiry among them.
+func mtlsRootCAsWithExpiry(tlsCert *tls.Certificate) (*x509.CertPool, time.Time, error) {
+	pool, err := mtlsRootCAsFromCertificate(tlsCert)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	cas := tlsCert.Certificate
+	if len(cas) > 1 {
+		cas = cas[1:]
+	}
+	var expiry time.Time
+	for _, certBytes := range cas {
+		// already parsed successfully by mtlsRootCAsFromCertificate
+		cert, _ := x509.ParseCertificate(certBytes)
+		if expiry.IsZero() || cert.NotAfter.Before(expiry) {
+			expiry = cert.NotAfter
+		}
+	}
+	return pool, expiry, nil
+}
@@ -4,6 +4,7 @@
 package common
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"net"
@@ -25,6 +26,15 @@ type Listener struct {
 	listener     net.Listener
 	tlsListener  net.Listener
 	mux          *http.ServeMux
+	authorizer   RequestAuthorizer
+}
+
+// RequestAuthorizer authorizes the requests served by a Listener.
+type RequestAuthorizer interface {
+	// ConnContext is called for every accepted connection, see http.Server.ConnContext.
+	ConnContext(ctx context.Context, c net.Conn) context.Context
+	// Middleware wraps the handler of every request.
+	Middleware(next http.Handler) http.Handler
 }
 
 // NewListener creates a new Listener.
@@ -40,12 +50,25 @@ func NewListener(u *url.URL) (*Listener, error) {
 	return &listener, nil
 }
 
+// SetAuthorizer authorizes every request served by the listener. It must be called before the listener is started.
+func (l *Listener) SetAuthorizer(authorizer RequestAuthorizer) {
+	l.authorizer = authorizer
+}
+
+func (l *Listener) newServer() *http.Server {
+	if l.authorizer == nil {
+		return &http.Server{Handler: l.mux}
+	}
+	return &http.Server{
+		Handler:     l.authorizer.Middleware(l.mux),
+		ConnContext: l.authorizer.ConnContext,
+	}
+}
+
 // StartTLS creates the listener socket and starts the HTTPS server.
 func (l *Listener) StartTLS(errChan chan<- error, tlsConfig *tls.Config, address string) error {
-	server := http.Server{
-		TLSConfig: tlsConfig,
-		Handler:   l.mux,
-	}
+	server := l.newServer()
+	server.TLSConfig = tlsConfig
 
 	// listen on a separate endpoint for secure tls connections
 	list, err := net.Listen(l.protocol, address)
@@ -78,8 +101,9 @@ func (l *Listener) Start(errChan chan<- error) error {
 	log.Printf("[Listener] Started listening on %s.", l.localAddress)
 
 	// Launch goroutine for servicing requests.
+	server := l.newServer()
 	go func() {
-		errChan <- http.Serve(l.listener, l.mux)
+		errChan <- server.Serve(l.listener)
 	}()
 
 	l.active = true
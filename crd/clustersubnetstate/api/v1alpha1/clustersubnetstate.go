@@ -16,6 +16,9 @@ import (
 // +kubebuilder:resource:scope=Namespaced
 // +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:name="Exhausted",type=string,JSONPath=`.status.exhausted`
+// +kubebuilder:printcolumn:name="Free",type=integer,JSONPath=`.status.freeIPs`
+// +kubebuilder:printcolumn:name="Total",type=integer,JSONPath=`.status.totalIPs`
+// +kubebuilder:printcolumn:name="Fair Share",type=integer,priority=1,JSONPath=`.status.nodeFairShare`
 // +kubebuilder:printcolumn:name="Updated",type=string,JSONPath=`.status.timestamp`
 type ClusterSubnetState struct {
 	metav1.TypeMeta   `json:",inline"`
@@ -26,8 +29,19 @@ type ClusterSubnetState struct {
 
 // ClusterSubnetStateStatus defines the observed state of ClusterSubnetState
 type ClusterSubnetStateStatus struct {
+	// Exhausted is set when the subnet is at or near exhaustion.
 	Exhausted bool   `json:"exhausted"`
 	Timestamp string `json:"timestamp"`
+	// FreeIPs is the number of IPs in the subnet that are not allocated to any node.
+	// +kubebuilder:validation:Optional
+	FreeIPs int64 `json:"freeIPs,omitempty"`
+	// TotalIPs is the number of allocatable IPs in the subnet.
+	// +kubebuilder:validation:Optional
+	TotalIPs int64 `json:"totalIPs,omitempty"`
+	// NodeFairShare is the number of IPs each node in the subnet may hold while the subnet is Exhausted.
+	// Nodes holding more than their fair share release their surplus Available IPs. Zero means unset.
+	// +kubebuilder:validation:Optional
+	NodeFairShare int64 `json:"nodeFairShare,omitempty"`
 }
 
 // +kubebuilder:object:root=true
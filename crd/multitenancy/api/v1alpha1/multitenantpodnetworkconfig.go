@@ -131,6 +131,10 @@ type InterfaceInfo struct {
 	// Denormalized from PodNetwork.Spec.SubnetResourceID.
 	// +kubebuilder:validation:Optional
 	SubnetResourceID string `json:"subnetResourceID,omitempty"`
+	// InterfaceName is the name of this interface inside the Pod.
+	// Denormalized from PodNetworkInstance.Spec.PodNetworkConfigs[].InterfaceName.
+	// +kubebuilder:validation:Optional
+	InterfaceName string `json:"interfaceName,omitempty"`
 }
 
 // MultitenantPodNetworkConfigStatus defines the observed state of PodNetworkConfig
@@ -50,6 +50,13 @@ type PodNetworkConfig struct {
 	// +kubebuilder:validation:MaxLength=18
 	// +kubebuilder:validation:Pattern=`^$|^((25[0-5]|(2[0-4]|1\d|[1-9]|)\d)\.){3}(25[0-5]|(2[0-4]|1\d|[1-9]|)\d)\/32$`
 	IPConstraint string `json:"ipConstraint,omitempty"`
+	// InterfaceName is the name of the interface backed by this PodNetwork inside the Pod.
+	// This is an optional field, when empty the interface keeps the name of the VM NIC.
+	// Example: net1
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxLength=15
+	// +kubebuilder:validation:Pattern=`^$|^[a-zA-Z0-9_][a-zA-Z0-9_.-]*$`
+	InterfaceName string `json:"interfaceName,omitempty"`
 }
 
 // PodNetworkInstanceSpec defines the desired state of PodNetworkInstance
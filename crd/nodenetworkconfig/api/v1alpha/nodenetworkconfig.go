@@ -25,6 +25,7 @@ import (
 // +kubebuilder:printcolumn:name="NC Mode",type=string,priority=0,JSONPath=`.status.networkContainers[*].assignmentMode`
 // +kubebuilder:printcolumn:name="NC Type",type=string,priority=1,JSONPath=`.status.networkContainers[*].type`
 // +kubebuilder:printcolumn:name="NC Version",type=integer,priority=0,JSONPath=`.status.networkContainers[*].version`
+// +kubebuilder:printcolumn:name="Pending Release IPs",type=integer,priority=1,JSONPath=`.status.ipamReport.ipStates.PendingRelease`
 type NodeNetworkConfig struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -84,6 +85,40 @@ type NodeNetworkConfigStatus struct {
 	Scaler            Scaler             `json:"scaler,omitempty"`
 	Status            Status             `json:"status,omitempty"`
 	NetworkContainers []NetworkContainer `json:"networkContainers,omitempty"`
+	// IPAMReport is a summary of the IPAM state of CNS on the node. It is published by CNS
+	// for observability only and is not read by any controller.
+	// +kubebuilder:validation:Optional
+	IPAMReport *IPAMReport `json:"ipamReport,omitempty"`
+}
+
+// IPAMReport summarizes the IPAM state of CNS on the node.
+type IPAMReport struct {
+	// UpdatedAt is when CNS last published a change to the report.
+	UpdatedAt metav1.Time `json:"updatedAt"`
+	// IPStates is the number of IPs in the CNS pool in each IP state.
+	IPStates map[string]int64 `json:"ipStates,omitempty"`
+	// OldestPendingRelease is when the IP that has been PendingRelease the longest entered that state.
+	OldestPendingRelease *metav1.Time `json:"oldestPendingRelease,omitempty"`
+	// NetworkContainers compares the version of each NC programmed by CNS with the version programmed in NMAgent.
+	NetworkContainers []NCVersionReport `json:"networkContainers,omitempty"`
+	// LastScaleEvent is the most recent change CNS made to the requested IP count.
+	LastScaleEvent *ScaleEvent `json:"lastScaleEvent,omitempty"`
+}
+
+// NCVersionReport is the version of an NC as programmed by CNS and by NMAgent.
+type NCVersionReport struct {
+	ID string `json:"id"`
+	// Version is the NC version CNS has programmed.
+	Version int64 `json:"version"`
+	// HostVersion is the NC version NMAgent has programmed, -1 if unknown.
+	HostVersion int64 `json:"hostVersion"`
+}
+
+// ScaleEvent is a change to the requested IP count.
+type ScaleEvent struct {
+	Time metav1.Time `json:"time"`
+	From int64       `json:"from"`
+	To   int64       `json:"to"`
 }
 
 // Scaler groups IP request params together
@@ -8,6 +8,43 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPAMReport) DeepCopyInto(out *IPAMReport) {
+	*out = *in
+	in.UpdatedAt.DeepCopyInto(&out.UpdatedAt)
+	if in.IPStates != nil {
+		in, out := &in.IPStates, &out.IPStates
+		*out = make(map[string]int64, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.OldestPendingRelease != nil {
+		in, out := &in.OldestPendingRelease, &out.OldestPendingRelease
+		*out = (*in).DeepCopy()
+	}
+	if in.NetworkContainers != nil {
+		in, out := &in.NetworkContainers, &out.NetworkContainers
+		*out = make([]NCVersionReport, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastScaleEvent != nil {
+		in, out := &in.LastScaleEvent, &out.LastScaleEvent
+		*out = new(ScaleEvent)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPAMReport.
+func (in *IPAMReport) DeepCopy() *IPAMReport {
+	if in == nil {
+		return nil
+	}
+	out := new(IPAMReport)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IPAssignment) DeepCopyInto(out *IPAssignment) {
 	*out = *in
@@ -23,6 +60,21 @@ func (in *IPAssignment) DeepCopy() *IPAssignment {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NCVersionReport) DeepCopyInto(out *NCVersionReport) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NCVersionReport.
+func (in *NCVersionReport) DeepCopy() *NCVersionReport {
+	if in == nil {
+		return nil
+	}
+	out := new(NCVersionReport)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NetworkContainer) DeepCopyInto(out *NetworkContainer) {
 	*out = *in
@@ -133,6 +185,11 @@ func (in *NodeNetworkConfigStatus) DeepCopyInto(out *NodeNetworkConfigStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.IPAMReport != nil {
+		in, out := &in.IPAMReport, &out.IPAMReport
+		*out = new(IPAMReport)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeNetworkConfigStatus.
@@ -145,6 +202,22 @@ func (in *NodeNetworkConfigStatus) DeepCopy() *NodeNetworkConfigStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScaleEvent) DeepCopyInto(out *ScaleEvent) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScaleEvent.
+func (in *ScaleEvent) DeepCopy() *ScaleEvent {
+	if in == nil {
+		return nil
+	}
+	out := new(ScaleEvent)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Scaler) DeepCopyInto(out *Scaler) {
 	*out = *in
@@ -2,6 +2,7 @@ package nodenetworkconfig
 
 import (
 	"context"
+	"encoding/json"
 	"reflect"
 
 	"github.com/Azure/azure-container-networking/crd"
@@ -115,6 +116,20 @@ func (c *Client) PatchSpec(ctx context.Context, key types.NamespacedName, spec *
 	return obj, nil
 }
 
+// PatchIPAMReport replaces the IPAMReport in the status of the NodeNetworkConfig specified by the NamespacedName.
+// It uses a JSON merge patch so that the rest of the status, which is owned by the control plane, is untouched.
+func (c *Client) PatchIPAMReport(ctx context.Context, key types.NamespacedName, report *v1alpha.IPAMReport) error {
+	patch, err := json.Marshal(map[string]any{"status": map[string]any{"ipamReport": report}})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal ipam report patch")
+	}
+	obj := genPatchSkel(key)
+	if err := c.cli.Status().Patch(ctx, obj, client.RawPatch(types.MergePatchType, patch)); err != nil {
+		return errors.Wrap(err, "failed to patch nnc status")
+	}
+	return nil
+}
+
 // UpdateSpec does a fetch, deepcopy, and update of the NodeNetworkConfig with the passed spec.
 // Deprecated: UpdateSpec is deprecated and usage should migrate to PatchSpec.
 func (c *Client) UpdateSpec(ctx context.Context, key types.NamespacedName, spec *v1alpha.NodeNetworkConfigSpec) (*v1alpha.NodeNetworkConfig, error) {
@@ -60,6 +60,18 @@ type OverlayExtensionConfigStatus struct {
 	// +kubebuilder:default="None"
 	State   OECState `json:"state,omitempty"`
 	Message string   `json:"message,omitempty"`
+	// NodeStatuses holds the result of programming the ExtensionIPRange on each node.
+	// +listType=map
+	// +listMapKey=nodeName
+	NodeStatuses []OECNodeStatus `json:"nodeStatuses,omitempty"`
+}
+
+// OECNodeStatus is the result of programming an OverlayExtensionConfig on a single node.
+type OECNodeStatus struct {
+	NodeName string `json:"nodeName"`
+	// +kubebuilder:validation:Enum=None;Pending;Succeeded;Failed
+	State   OECState `json:"state"`
+	Message string   `json:"message,omitempty"`
 }
 
 func init() {
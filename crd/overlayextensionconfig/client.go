@@ -2,7 +2,10 @@ package overlayextensionconfig
 
 import (
 	"context"
+	"fmt"
 	"reflect"
+	"slices"
+	"strings"
 
 	"github.com/Azure/azure-container-networking/crd"
 	"github.com/Azure/azure-container-networking/crd/overlayextensionconfig/api/v1alpha1"
@@ -12,8 +15,11 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // Scheme is a runtime scheme containing the client-go scheme and the OverlayExtensionConfig scheme.
@@ -73,3 +79,97 @@ func (i *Installer) InstallOrUpdate(ctx context.Context) (*v1.CustomResourceDefi
 	}
 	return current, nil
 }
+
+// Client provides methods to interact with instances of the OverlayExtensionConfig custom resource.
+type Client struct {
+	cli client.Client
+}
+
+// NewClient creates a new OverlayExtensionConfig client from the passed ctrlcli.Client.
+func NewClient(cli client.Client) *Client {
+	return &Client{
+		cli: cli,
+	}
+}
+
+// Get returns the OverlayExtensionConfig identified by the NamespacedName.
+func (c *Client) Get(ctx context.Context, key types.NamespacedName) (*v1alpha1.OverlayExtensionConfig, error) {
+	oec := &v1alpha1.OverlayExtensionConfig{}
+	err := c.cli.Get(ctx, key, oec)
+	return oec, errors.Wrapf(err, "failed to get oec %v", key)
+}
+
+// List returns the OverlayExtensionConfigs in all namespaces.
+func (c *Client) List(ctx context.Context) ([]v1alpha1.OverlayExtensionConfig, error) {
+	oecList := &v1alpha1.OverlayExtensionConfigList{}
+	err := c.cli.List(ctx, oecList)
+	return oecList.Items, errors.Wrap(err, "failed to list oec")
+}
+
+// UpdateNodeStatus records the result of programming the OverlayExtensionConfig on a node and recomputes the
+// overall State and Message from the results of every node. Results of nodes which are not in nodes, the nodes
+// of the cluster, are dropped; a nil nodes keeps them all. Conflicting writes from other nodes are retried.
+func (c *Client) UpdateNodeStatus(ctx context.Context, key types.NamespacedName, nodeStatus v1alpha1.OECNodeStatus, nodes map[string]struct{}) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		oec := &v1alpha1.OverlayExtensionConfig{}
+		if err := c.cli.Get(ctx, key, oec); err != nil {
+			return err //nolint:wrapcheck // wrapped below
+		}
+		status := SetNodeStatus(oec.Status, nodeStatus, nodes)
+		if reflect.DeepEqual(status, oec.Status) {
+			return nil
+		}
+		oec.Status = status
+		return c.cli.Status().Update(ctx, oec) //nolint:wrapcheck // wrapped below
+	})
+	return errors.Wrapf(err, "failed to update oec %v status for node %s", key, nodeStatus.NodeName)
+}
+
+// SetNodeStatus returns a copy of the status with the node's result replaced, the results of nodes which are no
+// longer in nodes dropped (unless nodes is nil) and the overall State and Message recomputed: Failed if any node
+// failed, Pending if any node is still pending and Succeeded once every node has succeeded.
+func SetNodeStatus(status v1alpha1.OverlayExtensionConfigStatus, nodeStatus v1alpha1.OECNodeStatus, nodes map[string]struct{}) v1alpha1.OverlayExtensionConfigStatus {
+	out := *status.DeepCopy()
+	if nodes != nil {
+		out.NodeStatuses = slices.DeleteFunc(out.NodeStatuses, func(s v1alpha1.OECNodeStatus) bool {
+			_, ok := nodes[s.NodeName]
+			return !ok && s.NodeName != nodeStatus.NodeName
+		})
+	}
+	i := slices.IndexFunc(out.NodeStatuses, func(s v1alpha1.OECNodeStatus) bool { return s.NodeName == nodeStatus.NodeName })
+	if i < 0 {
+		out.NodeStatuses = append(out.NodeStatuses, nodeStatus)
+		slices.SortFunc(out.NodeStatuses, func(a, b v1alpha1.OECNodeStatus) int { return strings.Compare(a.NodeName, b.NodeName) })
+	} else {
+		out.NodeStatuses[i] = nodeStatus
+	}
+
+	var failed, pending int
+	var firstFailure string
+	for _, s := range out.NodeStatuses {
+		switch s.State {
+		case v1alpha1.Failed:
+			if failed == 0 {
+				firstFailure = fmt.Sprintf("node %s: %s", s.NodeName, s.Message)
+			}
+			failed++
+		case v1alpha1.Succeeded:
+		default:
+			pending++
+		}
+	}
+
+	total := len(out.NodeStatuses)
+	switch {
+	case failed > 0:
+		out.State = v1alpha1.Failed
+		out.Message = fmt.Sprintf("%d of %d nodes failed, %s", failed, total, firstFailure)
+	case pending > 0:
+		out.State = v1alpha1.Pending
+		out.Message = fmt.Sprintf("%d of %d nodes pending", pending, total)
+	default:
+		out.State = v1alpha1.Succeeded
+		out.Message = ""
+	}
+	return out
+}
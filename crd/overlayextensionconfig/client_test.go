@@ -0,0 +1,107 @@
+package overlayextensionconfig
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/crd/overlayextensionconfig/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetNodeStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     v1alpha1.OverlayExtensionConfigStatus
+		nodeStatus v1alpha1.OECNodeStatus
+		nodes      map[string]struct{}
+		want       v1alpha1.OverlayExtensionConfigStatus
+	}{
+		{
+			name:       "first node pending",
+			nodeStatus: v1alpha1.OECNodeStatus{NodeName: "node-a", State: v1alpha1.Pending},
+			want: v1alpha1.OverlayExtensionConfigStatus{
+				State:        v1alpha1.Pending,
+				Message:      "1 of 1 nodes pending",
+				NodeStatuses: []v1alpha1.OECNodeStatus{{NodeName: "node-a", State: v1alpha1.Pending}},
+			},
+		},
+		{
+			name: "all nodes succeeded",
+			status: v1alpha1.OverlayExtensionConfigStatus{
+				State:   v1alpha1.Pending,
+				Message: "1 of 2 nodes pending",
+				NodeStatuses: []v1alpha1.OECNodeStatus{
+					{NodeName: "node-a", State: v1alpha1.Pending},
+					{NodeName: "node-b", State: v1alpha1.Succeeded},
+				},
+			},
+			nodeStatus: v1alpha1.OECNodeStatus{NodeName: "node-a", State: v1alpha1.Succeeded},
+			want: v1alpha1.OverlayExtensionConfigStatus{
+				State: v1alpha1.Succeeded,
+				NodeStatuses: []v1alpha1.OECNodeStatus{
+					{NodeName: "node-a", State: v1alpha1.Succeeded},
+					{NodeName: "node-b", State: v1alpha1.Succeeded},
+				},
+			},
+		},
+		{
+			name: "new node is kept sorted",
+			status: v1alpha1.OverlayExtensionConfigStatus{
+				State:        v1alpha1.Succeeded,
+				NodeStatuses: []v1alpha1.OECNodeStatus{{NodeName: "node-b", State: v1alpha1.Succeeded}},
+			},
+			nodeStatus: v1alpha1.OECNodeStatus{NodeName: "node-a", State: v1alpha1.Pending},
+			want: v1alpha1.OverlayExtensionConfigStatus{
+				State:   v1alpha1.Pending,
+				Message: "1 of 2 nodes pending",
+				NodeStatuses: []v1alpha1.OECNodeStatus{
+					{NodeName: "node-a", State: v1alpha1.Pending},
+					{NodeName: "node-b", State: v1alpha1.Succeeded},
+				},
+			},
+		},
+		{
+			name: "failure wins over pending",
+			status: v1alpha1.OverlayExtensionConfigStatus{
+				NodeStatuses: []v1alpha1.OECNodeStatus{{NodeName: "node-b", State: v1alpha1.Pending}},
+			},
+			nodeStatus: v1alpha1.OECNodeStatus{NodeName: "node-a", State: v1alpha1.Failed, Message: "no default route"},
+			want: v1alpha1.OverlayExtensionConfigStatus{
+				State:   v1alpha1.Failed,
+				Message: "1 of 2 nodes failed, node node-a: no default route",
+				NodeStatuses: []v1alpha1.OECNodeStatus{
+					{NodeName: "node-a", State: v1alpha1.Failed, Message: "no default route"},
+					{NodeName: "node-b", State: v1alpha1.Pending},
+				},
+			},
+		},
+		{
+			name: "nodes which left the cluster are dropped",
+			status: v1alpha1.OverlayExtensionConfigStatus{
+				State:   v1alpha1.Failed,
+				Message: "1 of 3 nodes failed, node node-c: no default route",
+				NodeStatuses: []v1alpha1.OECNodeStatus{
+					{NodeName: "node-a", State: v1alpha1.Pending},
+					{NodeName: "node-b", State: v1alpha1.Succeeded},
+					{NodeName: "node-c", State: v1alpha1.Failed, Message: "no default route"},
+				},
+			},
+			nodeStatus: v1alpha1.OECNodeStatus{NodeName: "node-a", State: v1alpha1.Succeeded},
+			nodes:      map[string]struct{}{"node-b": {}},
+			want: v1alpha1.OverlayExtensionConfigStatus{
+				State: v1alpha1.Succeeded,
+				NodeStatuses: []v1alpha1.OECNodeStatus{
+					{NodeName: "node-a", State: v1alpha1.Succeeded},
+					{NodeName: "node-b", State: v1alpha1.Succeeded},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := tt.status.DeepCopy()
+			got := SetNodeStatus(tt.status, tt.nodeStatus, tt.nodes)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, *before, tt.status, "input status must not be modified")
+		})
+	}
+}
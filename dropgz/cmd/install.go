@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/dropgz/pkg/install"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// defaultManifestPath is in the CNI binary directory, which is mounted wherever dropgz deploys.
+const defaultManifestPath = "/opt/cni/bin/.dropgz-manifest.json"
+
+// rollback subcommand
+var rollback = &cobra.Command{
+	Use:   "rollback",
+	Short: "restore the files replaced by the last deploy",
+	RunE: func(*cobra.Command, []string) error {
+		if err := setLogLevel(); err != nil {
+			return err
+		}
+		log := z.With(zap.String("manifest", manifestPath), zap.String("cmd", "rollback"))
+		m := install.Load(log, manifestPath)
+		if err := install.Rollback(log, m); err != nil {
+			return errors.Wrap(err, "failed to roll back")
+		}
+		if err := m.Save(manifestPath); err != nil {
+			return errors.Wrap(err, "failed to record rollback")
+		}
+		log.Info("rolled back", zap.String("version", m.Current.Version))
+		return nil
+	},
+	Args: cobra.NoArgs,
+}
+
+// status subcommand
+var status = &cobra.Command{
+	Use:   "status",
+	Short: "compare the installed files to the manifest",
+	RunE: func(*cobra.Command, []string) error {
+		if err := setLogLevel(); err != nil {
+			return err
+		}
+		m := install.Load(z.With(zap.String("cmd", "status")), manifestPath)
+		if m.Current == nil {
+			return errors.Errorf("no install recorded in %s", manifestPath)
+		}
+		statuses, err := m.Current.Status()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("version %s installed at %s\n", m.Current.Version, m.Current.Timestamp)
+		drifted := 0
+		for _, s := range statuses {
+			fmt.Printf("\t%s\t%s\t%s\n", s.State, s.Path, s.Source)
+			if s.State != install.Installed {
+				drifted++
+			}
+		}
+		if m.Previous != nil {
+			fmt.Printf("previous version %s can be restored with rollback\n", m.Previous.Version)
+		}
+		if drifted > 0 {
+			return errors.Errorf("%d files do not match the manifest", drifted)
+		}
+		return nil
+	},
+	Args: cobra.NoArgs,
+}
+
+func init() {
+	rollback.Flags().StringVar(&manifestPath, "manifest", defaultManifestPath, "install manifest path")
+	root.AddCommand(rollback)
+
+	status.Flags().StringVar(&manifestPath, "manifest", defaultManifestPath, "install manifest path")
+	root.AddCommand(status)
+}
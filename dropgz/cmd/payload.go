@@ -1,19 +1,35 @@
 package cmd
 
 import (
+	"bytes"
+	"crypto"
 	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
 
+	"github.com/Azure/azure-container-networking/dropgz/internal/buildinfo"
 	"github.com/Azure/azure-container-networking/dropgz/pkg/embed"
 	"github.com/Azure/azure-container-networking/dropgz/pkg/hash"
+	"github.com/Azure/azure-container-networking/dropgz/pkg/install"
+	"github.com/Azure/azure-container-networking/dropgz/pkg/signature"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
 
+const (
+	checksumFile  = "sum.txt"
+	signatureFile = "sum.txt.sig"
+)
+
 var (
-	compression embed.Compression
-	skipVerify  bool
-	outs        []string
+	compression   embed.Compression
+	skipVerify    bool
+	outs          []string
+	manifestPath  string
+	publicKeyPath string
 )
 
 // list subcommand
@@ -34,19 +50,76 @@ var list = &cobra.Command{
 	},
 }
 
-func checksum(srcs, dests []string) error {
-	if len(srcs) != len(dests) {
-		return errors.Wrapf(embed.ErrArgsMismatched, "%d and %d", len(srcs), len(dests))
+// errNoPublicKey is returned when dropgz has neither a --public-key nor a key given at build,
+// so the payload signature can't be verified.
+var errNoPublicKey = errors.New("no public key to verify the payload signature with")
+
+// publicKey returns the key which signs the payload checksums, from the --public-key file
+// or else from the build.
+func publicKey() (crypto.PublicKey, error) {
+	if publicKeyPath != "" {
+		b, err := os.ReadFile(publicKeyPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read public key %s", publicKeyPath)
+		}
+		return signature.ParsePublicKey(b)
 	}
-	rc, err := embed.Extract("sum.txt", compression)
+	if buildinfo.PublicKey != "" {
+		return signature.ParsePublicKey([]byte(buildinfo.PublicKey))
+	}
+	return nil, errNoPublicKey
+}
+
+func extractFile(name string) ([]byte, error) {
+	rc, err := embed.Extract(name, compression)
 	if err != nil {
-		return errors.Wrap(err, "failed to extract checksum file")
+		return nil, err
 	}
 	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	return b, errors.Wrapf(err, "failed to read %s", name)
+}
+
+// loadChecksums extracts the payload checksums and only returns them if their detached
+// signature is valid for the public key.
+func loadChecksums(log *zap.Logger) (hash.Checksums, error) {
+	key, err := publicKey()
+	if err != nil {
+		return nil, err
+	}
+	return verifyChecksums(log, key, extractFile)
+}
 
-	checksums, err := hash.Parse(rc)
+// verifyChecksums extracts the checksum and signature files with extract and parses the
+// checksums once the signature is verified. A missing signature is refused.
+func verifyChecksums(log *zap.Logger, key crypto.PublicKey, extract func(name string) ([]byte, error)) (hash.Checksums, error) {
+	sums, err := extract(checksumFile)
 	if err != nil {
-		return errors.Wrap(err, "failed to parse checksums")
+		return nil, errors.Wrap(err, "failed to extract checksum file")
+	}
+	sig, err := extract(signatureFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, errors.Wrap(err, "failed to extract signature file")
+	}
+	if err := signature.Verify(key, sums, sig); err != nil {
+		return nil, errors.Wrap(err, "refusing payload")
+	}
+	log.Info("verified payload signature")
+
+	checksums, err := hash.Parse(bytes.NewReader(sums))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse checksums")
+	}
+	return checksums, nil
+}
+
+func checksum(log *zap.Logger, srcs, dests []string) error {
+	if len(srcs) != len(dests) {
+		return errors.Wrapf(embed.ErrArgsMismatched, "%d and %d", len(srcs), len(dests))
+	}
+	checksums, err := loadChecksums(log)
+	if err != nil {
+		return err
 	}
 	for i := range srcs {
 		valid, err := checksums.Check(srcs[i], dests[i])
@@ -74,17 +147,28 @@ var deploy = &cobra.Command{
 			return errors.Wrapf(embed.ErrArgsMismatched, "%d files, %d outputs", len(srcs), len(outs))
 		}
 		log := z.With(zap.Strings("sources", srcs), zap.Strings("outputs", outs), zap.String("cmd", "deploy"))
-		if err := embed.Deploy(log, srcs, outs, compression); err != nil {
-			return errors.Wrapf(err, "failed to deploy %s", srcs)
-		}
-		log.Info("successfully wrote files")
+		var checksums hash.Checksums
 		if skipVerify {
-			return nil
+			if _, err := publicKey(); !errors.Is(err, errNoPublicKey) {
+				return errors.New("checksum validation can not be skipped for a signed payload")
+			}
+		} else {
+			var err error
+			if checksums, err = loadChecksums(log); err != nil {
+				return err
+			}
 		}
-		if err := checksum(srcs, outs); err != nil {
-			return err
+		m := install.Load(log, manifestPath)
+		installed, previous, err := embed.Deploy(log, srcs, outs, compression, checksums)
+		if err != nil {
+			return errors.Wrapf(err, "failed to deploy %s", srcs)
+		}
+		log.Info("successfully wrote files", zap.Bool("verified", !skipVerify))
+		m.Record(buildinfo.Version, installed, previous, time.Now())
+		if err := m.Save(manifestPath); err != nil {
+			return errors.Wrap(err, "failed to record install")
 		}
-		log.Info("verified file integrity")
+		log.Info("recorded install", zap.String("manifest", manifestPath), zap.String("version", buildinfo.Version))
 		return nil
 	},
 	Args: cobra.OnlyValidArgs,
@@ -104,7 +188,7 @@ var verify = &cobra.Command{
 			return errors.Wrapf(embed.ErrArgsMismatched, "%d sources, %d destinations", len(srcs), len(outs))
 		}
 		log := z.With(zap.Strings("sources", srcs), zap.Strings("outputs", outs), zap.String("cmd", "verify"))
-		if err := checksum(srcs, outs); err != nil {
+		if err := checksum(log, srcs, outs); err != nil {
 			return err
 		}
 		log.Info("verified files")
@@ -118,11 +202,14 @@ func init() {
 
 	verify.ValidArgs, _ = embed.Contents()
 	verify.Flags().StringSliceVarP(&outs, "output", "o", []string{}, "output file path")
+	verify.Flags().StringVar(&publicKeyPath, "public-key", "", "public key (PEM) to verify the payload signature with, instead of the key given at build")
 	root.AddCommand(verify)
 
 	deploy.ValidArgs, _ = embed.Contents() // setting this after the command is initialized is required
 	deploy.Flags().StringVarP((*string)(&compression), "compression", "c", "none", "compression type (default none)")
 	deploy.Flags().BoolVar(&skipVerify, "skip-verify", false, "set to disable checksum validation")
 	deploy.Flags().StringSliceVarP(&outs, "output", "o", []string{}, "output file path")
+	deploy.Flags().StringVar(&publicKeyPath, "public-key", "", "public key (PEM) to verify the payload signature with, instead of the key given at build")
+	deploy.Flags().StringVar(&manifestPath, "manifest", defaultManifestPath, "install manifest path")
 	root.AddCommand(deploy)
 }
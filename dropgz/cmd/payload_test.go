@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/dropgz/internal/buildinfo"
+	"github.com/Azure/azure-container-networking/dropgz/pkg/signature"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const sums = "0123456789abcdef  azure-vnet\n"
+
+// extractFrom returns an extract func over the files, which returns fs.ErrNotExist for the others.
+func extractFrom(files map[string][]byte) func(string) ([]byte, error) {
+	return func(name string) ([]byte, error) {
+		b, ok := files[name]
+		if !ok {
+			return nil, errors.Wrapf(fs.ErrNotExist, "failed to open file %s", name)
+		}
+		return b, nil
+	}
+}
+
+func TestVerifyChecksums(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	sig := ed25519.Sign(priv, []byte(sums))
+
+	tests := []struct {
+		name    string
+		key     ed25519.PublicKey
+		files   map[string][]byte
+		wantErr error
+	}{
+		{
+			name:  "valid signature",
+			key:   pub,
+			files: map[string][]byte{checksumFile: []byte(sums), signatureFile: sig},
+		},
+		{
+			name:    "tampered checksum file",
+			key:     pub,
+			files:   map[string][]byte{checksumFile: []byte("fedcba9876543210  azure-vnet\n"), signatureFile: sig},
+			wantErr: signature.ErrInvalidSignature,
+		},
+		{
+			name:    "wrong key",
+			key:     otherPub,
+			files:   map[string][]byte{checksumFile: []byte(sums), signatureFile: sig},
+			wantErr: signature.ErrInvalidSignature,
+		},
+		{
+			name:    "missing signature",
+			key:     pub,
+			files:   map[string][]byte{checksumFile: []byte(sums)},
+			wantErr: signature.ErrUnsigned,
+		},
+		{
+			name:    "missing checksum file",
+			key:     pub,
+			files:   map[string][]byte{signatureFile: sig},
+			wantErr: fs.ErrNotExist,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checksums, err := verifyChecksums(zap.NewNop(), tt.key, extractFrom(tt.files))
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "0123456789abcdef", checksums["azure-vnet"])
+		})
+	}
+}
+
+func TestPublicKey(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		file      []byte
+		buildKey  string
+		wantErr   bool
+		wantNoKey bool
+	}{
+		{
+			name: "key from flag",
+			file: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}),
+		},
+		{
+			name:    "malformed key from flag",
+			file:    []byte("-----BEGIN PUBLIC KEY-----\nbm90IGEga2V5\n-----END PUBLIC KEY-----\n"),
+			wantErr: true,
+		},
+		{
+			name:     "malformed key from build",
+			buildKey: "not a key!",
+			wantErr:  true,
+		},
+		{
+			name:      "no key",
+			wantErr:   true,
+			wantNoKey: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := ""
+			if tt.file != nil {
+				path = filepath.Join(t.TempDir(), "key.pem")
+				require.NoError(t, os.WriteFile(path, tt.file, 0o600))
+			}
+			defer func(path, key string) { publicKeyPath, buildinfo.PublicKey = path, key }(publicKeyPath, buildinfo.PublicKey)
+			publicKeyPath, buildinfo.PublicKey = path, tt.buildKey
+
+			key, err := publicKey()
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.Equal(t, tt.wantNoKey, errors.Is(err, errNoPublicKey))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, pub, key)
+		})
+	}
+}
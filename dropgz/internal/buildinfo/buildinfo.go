@@ -1,3 +1,7 @@
 package buildinfo
 
 var Version string
+
+// PublicKey is the base64 encoded DER (PKIX) public key which signs the payload checksums.
+// dropgz refuses payloads without a valid signature, and any payload when no key is given.
+var PublicKey string
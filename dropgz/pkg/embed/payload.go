@@ -6,18 +6,16 @@ import (
 	"embed"
 	"io"
 	"io/fs"
-	"os"
 	"path"
 	"path/filepath"
 
+	"github.com/Azure/azure-container-networking/dropgz/pkg/hash"
+	"github.com/Azure/azure-container-networking/dropgz/pkg/install"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
 
-const (
-	cwd           = "fs"
-	oldFileSuffix = ".old"
-)
+const cwd = "fs"
 
 var ErrArgsMismatched = errors.New("mismatched argument count")
 
@@ -94,39 +92,44 @@ func Extract(p string, compression Compression) (*compoundReadCloser, error) {
 	return &compoundReadCloser{closer: f, readcloser: rc}, nil
 }
 
-func deploy(src, dest string, compression Compression) error {
-	rc, err := Extract(src, compression)
-	if err != nil {
-		return err
+// Deploy installs the srcs to the dests all together. Every file is staged next to its dest and,
+// when checksums are given, verified before any dest is replaced. It returns the installed files
+// and the files they replaced, which are kept with the install.BackupSuffix.
+func Deploy(log *zap.Logger, srcs, dests []string, compression Compression, checksums hash.Checksums) (installed, previous []install.File, err error) {
+	if len(srcs) != len(dests) {
+		return nil, nil, errors.Wrapf(ErrArgsMismatched, "%d and %d", len(srcs), len(dests))
 	}
-	defer rc.Close()
-	// check if the file exists at dest already and rename it as an old one
-	if _, err := os.Stat(dest); err == nil {
-		oldDest := dest + oldFileSuffix
-		if err = os.Rename(dest, oldDest); err != nil {
-			return errors.Wrapf(err, "failed to rename the %s to %s", dest, oldDest)
+	tx := &install.Transaction{}
+	defer tx.Abort()
+	for i := range srcs {
+		f, err := stage(tx, srcs[i], dests[i], compression)
+		if err != nil {
+			return nil, nil, err
+		}
+		if checksums != nil {
+			valid, err := checksums.Match(f.Source, f.SHA256)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "failed to validate %s", f.Source)
+			}
+			if !valid {
+				return nil, nil, errors.Errorf("%s checksum validation failed", f.Source)
+			}
 		}
+		log.Debug("staged file", zap.String("src", f.Source), zap.String("dest", f.Path))
+		installed = append(installed, f)
 	}
-	target, err := os.OpenFile(dest, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o755) //nolint:gomnd // executable file bitmask
+	previous, err = tx.Commit(log)
 	if err != nil {
-		return errors.Wrapf(err, "failed to create file %s", dest)
+		return nil, nil, err
 	}
-	defer target.Close()
-	_, err = io.Copy(bufio.NewWriter(target), rc)
-	return errors.Wrapf(err, "failed to copy %s to %s", src, dest)
+	return installed, previous, nil
 }
 
-func Deploy(log *zap.Logger, srcs, dests []string, compression Compression) error {
-	if len(srcs) != len(dests) {
-		return errors.Wrapf(ErrArgsMismatched, "%d and %d", len(srcs), len(dests))
-	}
-	for i := range srcs {
-		src := srcs[i]
-		dest := dests[i]
-		if err := deploy(src, dest, compression); err != nil {
-			return err
-		}
-		log.Info("wrote file", zap.String("src", src), zap.String("dest", dest))
+func stage(tx *install.Transaction, src, dest string, compression Compression) (install.File, error) {
+	rc, err := Extract(src, compression)
+	if err != nil {
+		return install.File{}, err
 	}
-	return nil
+	defer rc.Close()
+	return tx.Stage(src, dest, rc)
 }
@@ -30,14 +30,35 @@ func Parse(r io.Reader) (Checksums, error) {
 }
 
 func (sums Checksums) Check(src, dst string) (bool, error) {
+	if _, ok := sums[src]; !ok {
+		return false, errors.Errorf("unknown path %s", src)
+	}
+	have, err := File(dst)
+	if err != nil {
+		return false, err
+	}
+	return sums.Match(src, have)
+}
+
+// Match returns whether the hex encoded sha256 sum is the checksum of src.
+func (sums Checksums) Match(src, sum string) (bool, error) {
 	want, ok := sums[src]
 	if !ok {
 		return false, errors.Errorf("unknown path %s", src)
 	}
-	buf, err := os.ReadFile(dst)
+	return want == sum, nil
+}
+
+// File returns the hex encoded sha256 sum of the file at path.
+func File(path string) (string, error) {
+	f, err := os.Open(path)
 	if err != nil {
-		return false, errors.Wrapf(err, "unable to read file %s", dst)
+		return "", errors.Wrapf(err, "unable to read file %s", path)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", errors.Wrapf(err, "unable to read file %s", path)
 	}
-	have := sha256.Sum256(buf)
-	return want == fmt.Sprintf("%x", have), nil
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
@@ -0,0 +1,126 @@
+package install
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/Azure/azure-container-networking/dropgz/pkg/hash"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const manifestMode = 0o644 //nolint:gomnd // manifest file bitmask
+
+// File is an installed file.
+type File struct {
+	// Source is the name of the embedded file, when it is known.
+	Source string `json:"source,omitempty"`
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// Release is a set of files installed together.
+type Release struct {
+	Version   string    `json:"version"`
+	Timestamp time.Time `json:"timestamp"`
+	Files     []File    `json:"files"`
+}
+
+// Manifest records the installed release and the previous one, whose files are kept as backups.
+type Manifest struct {
+	Current  *Release `json:"current,omitempty"`
+	Previous *Release `json:"previous,omitempty"`
+}
+
+// Load reads the manifest at path. A missing, unreadable or corrupt manifest is returned empty,
+// as if there was no previous install, so that a bad manifest never blocks a deploy.
+func Load(log *zap.Logger, path string) *Manifest {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Manifest{}
+	}
+	if err != nil {
+		log.Warn("failed to read manifest, starting fresh", zap.String("manifest", path), zap.Error(err))
+		return &Manifest{}
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(b, m); err != nil {
+		log.Warn("failed to parse manifest, starting fresh", zap.String("manifest", path), zap.Error(err))
+		return &Manifest{}
+	}
+	return m
+}
+
+// Save atomically writes the manifest to path.
+func (m *Manifest) Save(path string) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal manifest")
+	}
+	tmp, _, err := writeTemp(path, bytes.NewReader(b), manifestMode)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return errors.Wrapf(err, "failed to rename %s to %s", tmp, path)
+	}
+	return syncDirs([]string{path})
+}
+
+// Record makes the installed files the current release, and the files they replaced the previous one.
+func (m *Manifest) Record(version string, installed, previous []File, now time.Time) {
+	m.Previous = nil
+	if len(previous) > 0 {
+		m.Previous = &Release{Files: previous}
+		if m.Current != nil {
+			m.Previous.Version = m.Current.Version
+			m.Previous.Timestamp = m.Current.Timestamp
+			sources := map[string]string{}
+			for _, f := range m.Current.Files {
+				sources[f.Path] = f.Source
+			}
+			for i := range m.Previous.Files {
+				m.Previous.Files[i].Source = sources[m.Previous.Files[i].Path]
+			}
+		}
+	}
+	m.Current = &Release{Version: version, Timestamp: now.UTC(), Files: installed}
+}
+
+// State is the state of an installed file compared to the manifest.
+type State string
+
+const (
+	Installed State = "installed"
+	Modified  State = "modified"
+	Missing   State = "missing"
+)
+
+// FileStatus is the state of an installed file.
+type FileStatus struct {
+	File
+	State State
+}
+
+// Status compares the files on disk to the files of the release.
+func (r *Release) Status() ([]FileStatus, error) {
+	statuses := make([]FileStatus, len(r.Files))
+	for i, f := range r.Files {
+		statuses[i] = FileStatus{File: f, State: Installed}
+		if _, err := os.Stat(f.Path); os.IsNotExist(err) {
+			statuses[i].State = Missing
+			continue
+		}
+		sum, err := hash.File(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		if sum != f.SHA256 {
+			statuses[i].State = Modified
+		}
+	}
+	return statuses, nil
+}
@@ -0,0 +1,64 @@
+package install
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestLoad(t *testing.T) {
+	saved := &Manifest{}
+	saved.Record("v1", []File{{Source: "azure-vnet", Path: "/opt/cni/bin/azure-vnet", SHA256: "abc"}}, nil, time.Unix(0, 0))
+
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, path string)
+		wantNil bool
+	}{
+		{
+			name:    "missing manifest",
+			setup:   func(*testing.T, string) {},
+			wantNil: true,
+		},
+		{
+			name: "corrupt manifest",
+			setup: func(t *testing.T, path string) {
+				require.NoError(t, os.WriteFile(path, []byte(`{"current": {"version": `), manifestMode))
+			},
+			wantNil: true,
+		},
+		{
+			name: "unreadable manifest",
+			setup: func(t *testing.T, path string) {
+				// a directory can't be read as a file
+				require.NoError(t, os.Mkdir(path, 0o755))
+			},
+			wantNil: true,
+		},
+		{
+			name: "saved manifest",
+			setup: func(t *testing.T, path string) {
+				require.NoError(t, saved.Save(path))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "manifest.json")
+			tt.setup(t, path)
+			m := Load(zap.NewNop(), path)
+			require.NotNil(t, m)
+			if tt.wantNil {
+				assert.Nil(t, m.Current)
+				assert.Nil(t, m.Previous)
+				return
+			}
+			assert.Equal(t, saved, m)
+		})
+	}
+}
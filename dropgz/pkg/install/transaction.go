@@ -0,0 +1,226 @@
+// Package install atomically installs sets of files and records them in a manifest so that
+// the installed files can be checked and the previous set restored.
+package install
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/Azure/azure-container-networking/dropgz/pkg/hash"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// BackupSuffix is appended to the path of an installed file to keep the file it replaced.
+const BackupSuffix = ".old"
+
+const fileMode = 0o755 //nolint:gomnd // executable file bitmask
+
+var ErrNoPrevious = errors.New("no previous install to roll back to")
+
+type staged struct {
+	File
+	tmp string
+}
+
+type committed struct {
+	File
+	replaced bool
+}
+
+// Transaction installs a set of files all together. Files are staged next to their destination
+// and only replace it on Commit, so a failure never leaves a partially written file in place.
+type Transaction struct {
+	staged []staged
+}
+
+// Stage writes the content of r to a temporary file in the directory of dest and syncs it to disk.
+// It returns the File that will be installed at dest, with the sha256 sum of the content.
+func (t *Transaction) Stage(src, dest string, r io.Reader) (File, error) {
+	tmp, sum, err := writeTemp(dest, r, fileMode)
+	if err != nil {
+		return File{}, err
+	}
+	f := File{Source: src, Path: dest, SHA256: sum}
+	t.staged = append(t.staged, staged{File: f, tmp: tmp})
+	return f, nil
+}
+
+// Abort removes the staged files which have not been committed.
+func (t *Transaction) Abort() {
+	for _, s := range t.staged {
+		_ = os.Remove(s.tmp)
+	}
+	t.staged = nil
+}
+
+// Commit renames every staged file over its destination. The file previously at a destination
+// is kept with the BackupSuffix and returned as part of the previous set. If any file fails to
+// install, the destinations which were already replaced are restored.
+func (t *Transaction) Commit(log *zap.Logger) (previous []File, err error) {
+	defer t.Abort()
+	done := []committed{}
+	defer func() {
+		if err == nil {
+			return
+		}
+		for i := len(done) - 1; i >= 0; i-- {
+			if rerr := restore(done[i].Path, done[i].replaced); rerr != nil {
+				log.Error("failed to restore file", zap.String("dest", done[i].Path), zap.Error(rerr))
+			}
+		}
+	}()
+	for _, s := range t.staged {
+		replaced := false
+		if _, serr := os.Stat(s.Path); serr == nil {
+			sum, herr := hash.File(s.Path)
+			if herr != nil {
+				return nil, herr
+			}
+			if err := backup(s.Path); err != nil {
+				return nil, err
+			}
+			previous = append(previous, File{Path: s.Path, SHA256: sum})
+			replaced = true
+		}
+		if err := os.Rename(s.tmp, s.Path); err != nil {
+			return nil, errors.Wrapf(err, "failed to rename %s to %s", s.tmp, s.Path)
+		}
+		done = append(done, committed{File: s.File, replaced: replaced})
+		log.Info("wrote file", zap.String("src", s.Source), zap.String("dest", s.Path))
+	}
+	paths := make([]string, len(done))
+	for i := range done {
+		paths[i] = done[i].Path
+	}
+	if err := syncDirs(paths); err != nil {
+		return nil, err
+	}
+	return previous, nil
+}
+
+// Rollback restores the previous set of files of the manifest from their backups, after checking
+// that the backups are intact, and removes the installed files which had no previous version.
+func Rollback(log *zap.Logger, m *Manifest) error {
+	if m.Current == nil || m.Previous == nil {
+		return ErrNoPrevious
+	}
+	previous := map[string]File{}
+	for _, f := range m.Previous.Files {
+		sum, err := hash.File(f.Path + BackupSuffix)
+		if err != nil {
+			return err
+		}
+		if sum != f.SHA256 {
+			return errors.Errorf("backup %s does not match the manifest", f.Path+BackupSuffix)
+		}
+		previous[f.Path] = f
+	}
+	paths := make([]string, 0, len(m.Current.Files))
+	for _, f := range m.Current.Files {
+		_, replaced := previous[f.Path]
+		if err := restore(f.Path, replaced); err != nil {
+			return err
+		}
+		paths = append(paths, f.Path)
+		log.Info("restored file", zap.String("dest", f.Path), zap.Bool("removed", !replaced))
+	}
+	if err := syncDirs(paths); err != nil {
+		return err
+	}
+	m.Current, m.Previous = m.Previous, nil
+	return nil
+}
+
+// backup links or copies the file at path to path+BackupSuffix, so that the file stays in place.
+func backup(path string) error {
+	old := path + BackupSuffix
+	if err := os.Remove(old); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to remove %s", old)
+	}
+	if err := os.Link(path, old); err == nil {
+		return nil
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %s", path)
+	}
+	defer src.Close()
+	tmp, _, err := writeTemp(old, src, fileMode)
+	if err != nil {
+		return err
+	}
+	return errors.Wrapf(os.Rename(tmp, old), "failed to rename %s to %s", tmp, old)
+}
+
+// restore puts the backup of path back in place, or removes path if it did not replace a file.
+func restore(path string, replaced bool) error {
+	if !replaced {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to remove %s", path)
+		}
+		return nil
+	}
+	old := path + BackupSuffix
+	return errors.Wrapf(os.Rename(old, path), "failed to rename %s to %s", old, path)
+}
+
+// writeTemp writes the content of r to a synced temporary file in the directory of dest
+// and returns its path and the hex encoded sha256 sum of the content.
+func writeTemp(dest string, r io.Reader, mode os.FileMode) (tmp, sum string, err error) {
+	dir, name := filepath.Dir(dest), filepath.Base(dest)
+	f, err := os.CreateTemp(dir, "."+name+".tmp-*")
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to create temporary file for %s", dest)
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+	h := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(f, h), r); err != nil {
+		return "", "", errors.Wrapf(err, "failed to write %s", f.Name())
+	}
+	if err = f.Chmod(mode); err != nil {
+		return "", "", errors.Wrapf(err, "failed to set mode of %s", f.Name())
+	}
+	if err = f.Sync(); err != nil {
+		return "", "", errors.Wrapf(err, "failed to sync %s", f.Name())
+	}
+	if err = f.Close(); err != nil {
+		return "", "", errors.Wrapf(err, "failed to close %s", f.Name())
+	}
+	return f.Name(), fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// syncDirs syncs the directories of the paths so that renames in them are durable.
+func syncDirs(paths []string) error {
+	if runtime.GOOS == "windows" {
+		// directory handles can't be flushed on Windows
+		return nil
+	}
+	seen := map[string]struct{}{}
+	for _, p := range paths {
+		dir := filepath.Dir(p)
+		if _, ok := seen[dir]; ok {
+			continue
+		}
+		seen[dir] = struct{}{}
+		d, err := os.Open(dir)
+		if err != nil {
+			return errors.Wrapf(err, "failed to open directory %s", dir)
+		}
+		err = d.Sync()
+		d.Close()
+		if err != nil {
+			return errors.Wrapf(err, "failed to sync directory %s", dir)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package install
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), fileMode))
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(b)
+}
+
+// stage stages the content for each destination and returns the transaction.
+func stage(t *testing.T, files map[string]string) *Transaction {
+	t.Helper()
+	tx := &Transaction{}
+	for dest, content := range files {
+		_, err := tx.Stage(filepath.Base(dest), dest, strings.NewReader(content))
+		require.NoError(t, err)
+	}
+	return tx
+}
+
+// temps returns the temporary files left in dir.
+func temps(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, ".*.tmp-*"))
+	require.NoError(t, err)
+	return matches
+}
+
+func TestCommit(t *testing.T) {
+	dir := t.TempDir()
+	existing, added := filepath.Join(dir, "azure-vnet"), filepath.Join(dir, "azure-vnet-ipam")
+	writeFile(t, existing, "v1")
+
+	tx := stage(t, map[string]string{existing: "v2", added: "new"})
+	previous, err := tx.Commit(zap.NewNop())
+	require.NoError(t, err)
+
+	assert.Equal(t, "v2", readFile(t, existing))
+	assert.Equal(t, "new", readFile(t, added))
+	assert.Equal(t, "v1", readFile(t, existing+BackupSuffix))
+	assert.NoFileExists(t, added+BackupSuffix)
+	require.Len(t, previous, 1)
+	assert.Equal(t, existing, previous[0].Path)
+	assert.Empty(t, temps(t, dir))
+}
+
+func TestCommitRestoresOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	first, second, third := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c")
+	writeFile(t, first, "a1")
+	writeFile(t, second, "b1")
+
+	tx := &Transaction{}
+	for _, dest := range []string{first, second, third} {
+		_, err := tx.Stage(filepath.Base(dest), dest, strings.NewReader(filepath.Base(dest)+"2"))
+		require.NoError(t, err)
+	}
+	// the second rename fails after the first file has been replaced
+	require.NoError(t, os.Remove(tx.staged[1].tmp))
+
+	_, err := tx.Commit(zap.NewNop())
+	require.Error(t, err)
+
+	assert.Equal(t, "a1", readFile(t, first))
+	assert.Equal(t, "b1", readFile(t, second))
+	assert.NoFileExists(t, third)
+	assert.NoFileExists(t, first+BackupSuffix)
+	assert.Empty(t, temps(t, dir))
+}
+
+func TestRollback(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  func(t *testing.T, existing string)
+		wantErr bool
+	}{
+		{
+			name: "restores the previous files",
+		},
+		{
+			name: "refuses a modified backup",
+			tamper: func(t *testing.T, existing string) {
+				writeFile(t, existing+BackupSuffix, "tampered")
+			},
+			wantErr: true,
+		},
+		{
+			name: "refuses a missing backup",
+			tamper: func(t *testing.T, existing string) {
+				require.NoError(t, os.Remove(existing+BackupSuffix))
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			existing, added := filepath.Join(dir, "azure-vnet"), filepath.Join(dir, "azure-vnet-ipam")
+			writeFile(t, existing, "v1")
+			m := &Manifest{}
+			m.Record("v1", []File{{Path: existing, SHA256: "ignored"}}, nil, time.Now())
+
+			tx := stage(t, map[string]string{existing: "v2", added: "new"})
+			previous, err := tx.Commit(zap.NewNop())
+			require.NoError(t, err)
+			m.Record("v2", []File{{Path: existing}, {Path: added}}, previous, time.Now())
+			if tt.tamper != nil {
+				tt.tamper(t, existing)
+			}
+
+			err = Rollback(zap.NewNop(), m)
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.Equal(t, "v2", readFile(t, existing))
+				assert.Equal(t, "v2", m.Current.Version)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "v1", readFile(t, existing))
+			assert.NoFileExists(t, added)
+			assert.Equal(t, "v1", m.Current.Version)
+			assert.Nil(t, m.Previous)
+			require.ErrorIs(t, Rollback(zap.NewNop(), m), ErrNoPrevious)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// Package signature verifies detached signatures over the dropgz payload checksums.
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrUnsigned         = errors.New("payload is not signed")
+	ErrInvalidSignature = errors.New("invalid payload signature")
+)
+
+// ParsePublicKey parses a PKIX public key, either PEM encoded or as base64 encoded DER.
+// Ed25519, ECDSA and RSA keys are supported.
+func ParsePublicKey(b []byte) (crypto.PublicKey, error) {
+	der := b
+	if block, _ := pem.Decode(b); block != nil {
+		der = block.Bytes
+	} else {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+		if err != nil {
+			return nil, errors.Wrap(err, "public key is neither PEM nor base64 encoded")
+		}
+		der = decoded
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse public key")
+	}
+	switch key.(type) {
+	case ed25519.PublicKey, *ecdsa.PublicKey, *rsa.PublicKey:
+		return key, nil
+	default:
+		return nil, errors.Errorf("unsupported public key type %T", key)
+	}
+}
+
+// Verify checks the signature of the message with the key. Ed25519 signatures are over the message,
+// ECDSA (ASN.1) and RSA (PKCS #1 v1.5) signatures are over its sha256 digest, as produced by
+// "openssl dgst -sha256 -sign".
+func Verify(key crypto.PublicKey, message, sig []byte) error {
+	if len(sig) == 0 {
+		return ErrUnsigned
+	}
+	digest := sha256.Sum256(message)
+	valid := false
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(k, message, sig)
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(k, digest[:], sig)
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil
+	default:
+		return errors.Errorf("unsupported public key type %T", key)
+	}
+	if !valid {
+		return ErrInvalidSignature
+	}
+	return nil
+}
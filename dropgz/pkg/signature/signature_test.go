@@ -0,0 +1,169 @@
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var message = []byte("0123456789abcdef  azure-vnet\n")
+
+type signer struct {
+	public crypto.PublicKey
+	sign   func(message []byte) []byte
+}
+
+func newSigners(t *testing.T) map[string]signer {
+	t.Helper()
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ecPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return map[string]signer{
+		"ed25519": {
+			public: edPub,
+			sign:   func(m []byte) []byte { return ed25519.Sign(edPriv, m) },
+		},
+		"ecdsa": {
+			public: &ecPriv.PublicKey,
+			sign: func(m []byte) []byte {
+				digest := sha256.Sum256(m)
+				sig, err := ecdsa.SignASN1(rand.Reader, ecPriv, digest[:])
+				require.NoError(t, err)
+				return sig
+			},
+		},
+		"rsa": {
+			public: &rsaPriv.PublicKey,
+			sign: func(m []byte) []byte {
+				digest := sha256.Sum256(m)
+				sig, err := rsa.SignPKCS1v15(rand.Reader, rsaPriv, crypto.SHA256, digest[:])
+				require.NoError(t, err)
+				return sig
+			},
+		},
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(edPub)
+	require.NoError(t, err)
+	x25519, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	x25519DER, err := x509.MarshalPKIXPublicKey(x25519.PublicKey())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		key     []byte
+		wantErr bool
+	}{
+		{
+			name: "PEM",
+			key:  pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}),
+		},
+		{
+			name: "base64 DER",
+			key:  []byte(base64.StdEncoding.EncodeToString(der) + "\n"),
+		},
+		{
+			name:    "not PEM nor base64",
+			key:     []byte("not a key!"),
+			wantErr: true,
+		},
+		{
+			name:    "malformed DER",
+			key:     []byte(base64.StdEncoding.EncodeToString(der[:len(der)-4])),
+			wantErr: true,
+		},
+		{
+			name:    "unsupported key type",
+			key:     []byte(base64.StdEncoding.EncodeToString(x25519DER)),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParsePublicKey(tt.key)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, edPub, key)
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	signers := newSigners(t)
+	for name, s := range signers {
+		other := signers["ed25519"].public
+		if name == "ed25519" {
+			other = signers["rsa"].public
+		}
+		tampered := append([]byte{}, message...)
+		tampered[0] = 'f'
+
+		tests := []struct {
+			name    string
+			key     crypto.PublicKey
+			message []byte
+			sig     []byte
+			wantErr error
+		}{
+			{
+				name:    "valid signature",
+				key:     s.public,
+				message: message,
+				sig:     s.sign(message),
+			},
+			{
+				name:    "tampered message",
+				key:     s.public,
+				message: tampered,
+				sig:     s.sign(message),
+				wantErr: ErrInvalidSignature,
+			},
+			{
+				name:    "wrong key",
+				key:     other,
+				message: message,
+				sig:     s.sign(message),
+				wantErr: ErrInvalidSignature,
+			},
+			{
+				name:    "missing signature",
+				key:     s.public,
+				message: message,
+				wantErr: ErrUnsigned,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				err := Verify(tt.key, tt.message, tt.sig)
+				if tt.wantErr != nil {
+					require.ErrorIs(t, err, tt.wantErr)
+					return
+				}
+				require.NoError(t, err)
+			})
+		}
+	}
+}
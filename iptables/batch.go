@@ -0,0 +1,432 @@
+package iptables
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	iptablesSave     = "iptables-save"
+	ip6tablesSave    = "ip6tables-save"
+	iptablesRestore  = "iptables-restore"
+	ip6tablesRestore = "ip6tables-restore"
+	createChain      = "N"
+	// batchAttempts is how often a table is checked and restored before giving up, see applyTable
+	batchAttempts = 2
+)
+
+// batchMu serializes the batches of a process, so two of them never check and restore the same chains at once.
+// CNI holds its own lock across processes while it programs endpoints, which covers concurrent CNI invocations.
+var batchMu sync.Mutex
+
+// BatchOp is a single operation gathered by a Batch.
+type BatchOp struct {
+	Version string
+	Table   string
+	Chain   string
+	// Action is one of Insert, Append, Delete, or "N" for creating a chain.
+	Action string
+	Match  string
+	Target string
+}
+
+// Batch gathers iptables operations so they can be applied with a single iptables-restore per table and family
+// instead of one iptables process per rule.
+type Batch struct {
+	Ops []BatchOp
+}
+
+// NewBatch returns an empty batch.
+func NewBatch() *Batch {
+	return &Batch{}
+}
+
+// CreateChain adds a chain to the batch. It is skipped if the chain already exists.
+func (b *Batch) CreateChain(version, tableName, chainName string) *Batch {
+	b.Ops = append(b.Ops, BatchOp{Version: version, Table: tableName, Chain: chainName, Action: createChain})
+	return b
+}
+
+// InsertRule inserts a rule at the beginning of the chain. It is skipped if the rule already exists.
+func (b *Batch) InsertRule(version, tableName, chainName, match, target string) *Batch {
+	b.Ops = append(b.Ops, BatchOp{Version: version, Table: tableName, Chain: chainName, Action: Insert, Match: match, Target: target})
+	return b
+}
+
+// AppendRule appends a rule at the end of the chain. It is skipped if the rule already exists.
+func (b *Batch) AppendRule(version, tableName, chainName, match, target string) *Batch {
+	b.Ops = append(b.Ops, BatchOp{Version: version, Table: tableName, Chain: chainName, Action: Append, Match: match, Target: target})
+	return b
+}
+
+// DeleteRule deletes a rule from the chain. It is skipped if the rule does not exist.
+func (b *Batch) DeleteRule(version, tableName, chainName, match, target string) *Batch {
+	b.Ops = append(b.Ops, BatchOp{Version: version, Table: tableName, Chain: chainName, Action: Delete, Match: match, Target: target})
+	return b
+}
+
+// ApplyBatch applies the batch with one iptables-restore --noflush per table and family.
+// Idempotency is checked against an iptables-save snapshot of the table taken right before it is restored, so
+// existing chains are not redeclared (which would flush them), existing rules are not added twice and missing
+// rules are not deleted. Rules are compared after normalizing to the form iptables-save prints; rules with matches
+// the normalizer does not understand are checked with iptables -C instead.
+func (c *Client) ApplyBatch(b *Batch) error {
+	batchMu.Lock()
+	defer batchMu.Unlock()
+
+	for _, version := range b.versions() {
+		for _, table := range b.tables(version) {
+			if err := c.applyTable(b, version, table); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// applyTable restores the operations of the batch for a table which its snapshot says are still needed.
+// The snapshot and the restore are not atomic, so a restore fails if another process changed the table in
+// between, e.g. created one of the chains or deleted one of the rules. It is then retried on a fresh snapshot.
+func (c *Client) applyTable(b *Batch, version, table string) error {
+	var err error
+	for attempt := 1; attempt <= batchAttempts; attempt++ {
+		var snapshot *ruleSnapshot
+		if snapshot, err = c.save(version, table); err != nil {
+			return err
+		}
+
+		input := b.restoreInput(version, table, snapshot, c.RuleExists)
+		if input == "" {
+			logger.Info("All iptables rules already programmed", zap.String("version", version), zap.String("table", table))
+			return nil
+		}
+		if err = c.restore(version, input); err == nil {
+			return nil
+		}
+		logger.Info("Restoring iptables batch failed, checking the table again",
+			zap.String("version", version), zap.String("table", table), zap.Int("attempt", attempt), zap.Error(err))
+	}
+
+	return errors.Wrapf(err, "failed to restore %s table for ipv%s", table, version)
+}
+
+// versions returns the families used by the batch in order of first appearance.
+func (b *Batch) versions() []string {
+	var versions []string
+	seen := map[string]bool{}
+	for _, op := range b.Ops {
+		if !seen[op.Version] {
+			seen[op.Version] = true
+			versions = append(versions, op.Version)
+		}
+	}
+	return versions
+}
+
+// tables returns the tables used by the batch for a family in order of first appearance.
+func (b *Batch) tables(version string) []string {
+	var tables []string
+	seen := map[string]bool{}
+	for _, op := range b.Ops {
+		if op.Version == version && !seen[op.Table] {
+			seen[op.Table] = true
+			tables = append(tables, op.Table)
+		}
+	}
+	return tables
+}
+
+// restoreInput renders the operations for a table that still need to be applied on top of the snapshot.
+// ruleExists is asked about rules which cannot be looked up in the snapshot.
+// It returns an empty string if there is nothing to do.
+func (b *Batch) restoreInput(version, table string, snapshot *ruleSnapshot, ruleExists func(version, table, chain, match, target string) bool) string {
+	hasRule := func(op BatchOp, spec string) bool {
+		if snapshot.hasRule(table, op.Chain, spec) {
+			return true
+		}
+		if snapshot.isRemoved(table, op.Chain, spec) {
+			return false
+		}
+		return !canNormalize(spec) && ruleExists(version, table, op.Chain, op.Match, op.Target)
+	}
+
+	var chains, rules []string
+	for _, op := range b.Ops {
+		if op.Version != version || op.Table != table {
+			continue
+		}
+
+		switch op.Action {
+		case createChain:
+			if snapshot.hasChain(table, op.Chain) {
+				continue
+			}
+			snapshot.addChain(table, op.Chain)
+			chains = append(chains, fmt.Sprintf(":%s - [0:0]", op.Chain))
+		case Insert, Append:
+			spec := ruleSpec(op.Match, op.Target)
+			if hasRule(op, spec) {
+				continue
+			}
+			snapshot.addRule(table, op.Chain, spec)
+			if op.Action == Insert {
+				rules = append(rules, fmt.Sprintf("-I %s 1 %s", op.Chain, spec))
+			} else {
+				rules = append(rules, fmt.Sprintf("-A %s %s", op.Chain, spec))
+			}
+		case Delete:
+			spec := ruleSpec(op.Match, op.Target)
+			if !hasRule(op, spec) {
+				logger.Info("Skipping delete of missing iptables rule", zap.String("chain", op.Chain), zap.String("rule", spec))
+				continue
+			}
+			snapshot.removeRule(table, op.Chain, spec)
+			rules = append(rules, fmt.Sprintf("-D %s %s", op.Chain, spec))
+		}
+	}
+
+	if len(chains) == 0 && len(rules) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "*%s\n", table)
+	for _, line := range append(chains, rules...) {
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+	sb.WriteString("COMMIT\n")
+	return sb.String()
+}
+
+func (c *Client) save(version, table string) (*ruleSnapshot, error) {
+	saveCmd := iptablesSave
+	if version == V6 {
+		saveCmd = ip6tablesSave
+	}
+
+	cmd := fmt.Sprintf("%s -t %s", saveCmd, table)
+	out, err := c.pl.ExecuteRawCommand(cmd)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to run %s", cmd)
+	}
+	return parseSnapshot(out), nil
+}
+
+func (c *Client) restore(version, input string) error {
+	iptCmd := iptablesRestore
+	if version == V6 {
+		iptCmd = ip6tablesRestore
+	}
+
+	f, err := os.CreateTemp("", "iptables-restore-*.txt")
+	if err != nil {
+		return errors.Wrap(err, "failed to create restore file")
+	}
+	defer os.Remove(f.Name())
+
+	if _, err = f.WriteString(input); err != nil {
+		f.Close()
+		return errors.Wrap(err, "failed to write restore file")
+	}
+	if err = f.Close(); err != nil {
+		return errors.Wrap(err, "failed to close restore file")
+	}
+
+	var cmd string
+	if DisableIPTableLock {
+		cmd = fmt.Sprintf("%s --noflush < %s", iptCmd, f.Name())
+	} else {
+		cmd = fmt.Sprintf("%s -w %d --noflush < %s", iptCmd, lockTimeout, f.Name())
+	}
+
+	logger.Info("Applying iptables batch", zap.String("input", input))
+	if _, err := c.pl.ExecuteRawCommand(cmd); err != nil {
+		return errors.Wrapf(err, "failed to run %s", iptCmd)
+	}
+	return nil
+}
+
+// ruleSnapshot indexes the chains and rules of an iptables-save dump by table.
+// Rules the batch deletes are remembered, so rules which are only checked with iptables -C are not deleted twice.
+type ruleSnapshot struct {
+	chains  map[string]map[string]bool
+	rules   map[string]map[string]bool
+	removed map[string]map[string]bool
+}
+
+func parseSnapshot(dump string) *ruleSnapshot {
+	s := &ruleSnapshot{
+		chains:  map[string]map[string]bool{},
+		rules:   map[string]map[string]bool{},
+		removed: map[string]map[string]bool{},
+	}
+
+	var table string
+	for _, line := range strings.Split(dump, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "*"):
+			table = strings.TrimPrefix(line, "*")
+		case strings.HasPrefix(line, ":"):
+			chain, _, _ := strings.Cut(strings.TrimPrefix(line, ":"), " ")
+			s.addChain(table, chain)
+		case strings.HasPrefix(line, "-A "):
+			chain, spec, _ := strings.Cut(strings.TrimPrefix(line, "-A "), " ")
+			s.addRule(table, chain, spec)
+		}
+	}
+	return s
+}
+
+func (s *ruleSnapshot) hasChain(table, chain string) bool {
+	return s.chains[table][chain]
+}
+
+func (s *ruleSnapshot) addChain(table, chain string) {
+	if s.chains[table] == nil {
+		s.chains[table] = map[string]bool{}
+	}
+	s.chains[table][chain] = true
+}
+
+func (s *ruleSnapshot) hasRule(table, chain, spec string) bool {
+	return s.rules[table][chain+" "+normalizeRuleSpec(spec)]
+}
+
+func (s *ruleSnapshot) addRule(table, chain, spec string) {
+	if s.rules[table] == nil {
+		s.rules[table] = map[string]bool{}
+	}
+	key := chain + " " + normalizeRuleSpec(spec)
+	s.rules[table][key] = true
+	delete(s.removed[table], key)
+}
+
+func (s *ruleSnapshot) removeRule(table, chain, spec string) {
+	if s.removed[table] == nil {
+		s.removed[table] = map[string]bool{}
+	}
+	key := chain + " " + normalizeRuleSpec(spec)
+	delete(s.rules[table], key)
+	s.removed[table][key] = true
+}
+
+func (s *ruleSnapshot) isRemoved(table, chain, spec string) bool {
+	return s.removed[table][chain+" "+normalizeRuleSpec(spec)]
+}
+
+func ruleSpec(match, target string) string {
+	return strings.TrimSpace(fmt.Sprintf("%s -j %s", strings.TrimSpace(match), target))
+}
+
+// normalizeRuleSpec rewrites a rule in the canonical form printed by iptables-save for the matches
+// this repo programs: bare addresses get a host prefix, protocol port matches load the protocol module,
+// conntrack state lists are sorted and MARK --set-mark is printed as --set-xmark.
+func normalizeRuleSpec(spec string) string {
+	fields := strings.Fields(spec)
+	out := make([]string, 0, len(fields))
+	for i := 0; i < len(fields); i++ {
+		field := strings.Trim(fields[i], `"'`)
+		switch field {
+		case "-s", "-d", "--source", "--destination":
+			out = append(out, field)
+			if i+1 < len(fields) {
+				i++
+				out = append(out, withHostPrefix(strings.Trim(fields[i], `"'`)))
+			}
+			continue
+		case "-p", "--protocol":
+			out = append(out, "-p")
+			if i+1 < len(fields) {
+				i++
+				proto := fields[i]
+				out = append(out, proto)
+				if (proto == TCP || proto == UDP) && i+1 < len(fields) && fields[i+1] != "-m" &&
+					(fields[i+1] == "--dport" || fields[i+1] == "--sport") {
+					out = append(out, "-m", proto)
+				}
+			}
+			continue
+		case "--state", "--ctstate":
+			out = append(out, field)
+			if i+1 < len(fields) {
+				i++
+				states := strings.Split(fields[i], ",")
+				sort.Strings(states)
+				out = append(out, strings.Join(states, ","))
+			}
+			continue
+		case "--set-mark":
+			if i+1 < len(fields) {
+				i++
+				out = append(out, "--set-xmark", xmark(fields[i]))
+				continue
+			}
+		}
+		out = append(out, field)
+	}
+	return strings.Join(out, " ")
+}
+
+// normalizedOptions are the options whose canonical iptables-save form normalizeRuleSpec knows, and
+// normalizedModules the match modules whose options it knows.
+var (
+	normalizedOptions = map[string]bool{
+		"-s": true, "-d": true, "--source": true, "--destination": true, "-p": true, "--protocol": true,
+		"-i": true, "-o": true, "-m": true, "-j": true, "--dport": true, "--sport": true,
+		"--state": true, "--ctstate": true, "--set-mark": true, "--set-xmark": true,
+		"--to-source": true, "--to-destination": true,
+	}
+	normalizedModules = map[string]bool{TCP: true, UDP: true, "state": true, "conntrack": true}
+)
+
+// canNormalize returns true if normalizeRuleSpec turns spec into the form iptables-save prints, so the rule
+// can be looked up in a snapshot. Negations, quoting and other options are printed in forms it does not know.
+func canNormalize(spec string) bool {
+	fields := strings.Fields(spec)
+	for i, field := range fields {
+		if field == "!" || strings.ContainsAny(field, `"'`) {
+			return false
+		}
+		if !strings.HasPrefix(field, "-") {
+			continue
+		}
+		if !normalizedOptions[field] {
+			return false
+		}
+		if field == "-m" && (i+1 >= len(fields) || !normalizedModules[fields[i+1]]) {
+			return false
+		}
+	}
+	return true
+}
+
+func withHostPrefix(addr string) string {
+	if strings.Contains(addr, "/") {
+		return addr
+	}
+	if strings.Contains(addr, ":") {
+		return addr + "/128"
+	}
+	return addr + "/32"
+}
+
+func xmark(mark string) string {
+	if strings.Contains(mark, "/") {
+		return mark
+	}
+	value, err := strconv.ParseUint(mark, 0, 32)
+	if err != nil {
+		return mark
+	}
+	return fmt.Sprintf("%#x/0xffffffff", value)
+}
@@ -0,0 +1,215 @@
+package iptables
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSnapshot = `# Generated by iptables-save
+*filter
+:INPUT ACCEPT [0:0]
+:OUTPUT ACCEPT [0:0]
+:AZURECNIOUTPUT - [0:0]
+-A OUTPUT -j AZURECNIOUTPUT
+-A AZURECNIOUTPUT -s 169.254.0.1/32 -d 169.254.0.4/32 -j ACCEPT
+-A AZURECNIOUTPUT -s 169.254.0.1/32 -d 169.254.0.9/32 -j ACCEPT
+COMMIT
+*mangle
+:PREROUTING ACCEPT [0:0]
+-A PREROUTING -j MARK --set-xmark 0x65/0xffffffff
+COMMIT
+`
+
+// tableDump returns the part of an iptables-save dump for a table, like iptables-save -t does.
+func tableDump(dump, table string) string {
+	var sb strings.Builder
+	in := false
+	for _, line := range strings.SplitAfter(dump, "\n") {
+		if strings.HasPrefix(line, "*") {
+			in = strings.TrimSpace(line) == "*"+table
+		}
+		if in {
+			sb.WriteString(line)
+		}
+		if strings.TrimSpace(line) == "COMMIT" {
+			in = false
+		}
+	}
+	return sb.String()
+}
+
+// batchExecClient serves the snapshot for iptables-save and records the input of every iptables-restore.
+// Rules checked with iptables -C are looked up in existing.
+type batchExecClient struct {
+	*platform.MockExecClient
+	saves    []string
+	checks   []string
+	restores []string
+}
+
+func newBatchExecClient(t *testing.T, snapshot string, existing map[string]bool, restoreErrs ...error) *batchExecClient {
+	c := &batchExecClient{MockExecClient: platform.NewMockExecClient(false)}
+	c.SetExecRawCommand(func(cmd string) (string, error) {
+		for _, save := range []string{iptablesSave, ip6tablesSave} {
+			if table, ok := strings.CutPrefix(cmd, save+" -t "); ok {
+				c.saves = append(c.saves, cmd)
+				return tableDump(snapshot, table), nil
+			}
+		}
+		if check, ok := strings.CutPrefix(cmd, "iptables -w 60 "); ok {
+			c.checks = append(c.checks, check)
+			if existing[check] {
+				return "", nil
+			}
+			return "", errors.New("iptables: Bad rule (does a matching rule exist in that chain?)")
+		}
+		require.True(t, strings.HasPrefix(cmd, "iptables-restore -w 60 --noflush < ") ||
+			strings.HasPrefix(cmd, "ip6tables-restore -w 60 --noflush < "), "unexpected command %s", cmd)
+		_, file, _ := strings.Cut(cmd, "< ")
+		input, err := os.ReadFile(file)
+		require.NoError(t, err)
+		c.restores = append(c.restores, string(input))
+		if len(restoreErrs) > 0 {
+			err, restoreErrs = restoreErrs[0], restoreErrs[1:]
+			return "", err
+		}
+		return "", nil
+	})
+	return c
+}
+
+func TestApplyBatch(t *testing.T) {
+	pl := newBatchExecClient(t, testSnapshot, nil)
+	client := &Client{pl: pl}
+
+	b := NewBatch().
+		CreateChain(V4, Filter, CNIOutputChain).
+		CreateChain(V4, Filter, CNIInputChain).
+		InsertRule(V4, Filter, Output, "", CNIOutputChain).
+		InsertRule(V4, Filter, CNIOutputChain, "-s 169.254.0.1 -d 169.254.0.4", Accept).
+		InsertRule(V4, Filter, CNIInputChain, "-i azSnatbr -m state --state ESTABLISHED,RELATED", Accept).
+		AppendRule(V4, Filter, CNIInputChain, "-i azSnatbr -m state --state ESTABLISHED,RELATED", Accept).
+		DeleteRule(V4, Filter, CNIOutputChain, "-s 169.254.0.1 -d 169.254.0.9", Accept).
+		DeleteRule(V4, Filter, CNIOutputChain, "-s 169.254.0.1 -d 169.254.0.10", Accept).
+		InsertRule(V4, Mangle, Prerouting, "", "MARK --set-mark 101").
+		InsertRule(V6, Mangle, Prerouting, "-i eth0.1", Accept)
+
+	require.NoError(t, client.ApplyBatch(b))
+	require.Equal(t, []string{
+		"*filter\n" +
+			":AZURECNIINPUT - [0:0]\n" +
+			"-I AZURECNIINPUT 1 -i azSnatbr -m state --state ESTABLISHED,RELATED -j ACCEPT\n" +
+			"-D AZURECNIOUTPUT -s 169.254.0.1 -d 169.254.0.9 -j ACCEPT\n" +
+			"COMMIT\n",
+		"*mangle\n" +
+			"-I PREROUTING 1 -i eth0.1 -j ACCEPT\n" +
+			"COMMIT\n",
+	}, pl.restores, "existing chains and rules must be skipped and missing rules not deleted")
+	assert.Equal(t, []string{"iptables-save -t filter", "iptables-save -t mangle", "ip6tables-save -t mangle"}, pl.saves,
+		"only the tables of the batch must be saved")
+	assert.Empty(t, pl.checks, "rules which can be normalized must not be checked with iptables -C")
+}
+
+func TestApplyBatchNothingToDo(t *testing.T) {
+	pl := newBatchExecClient(t, testSnapshot, nil)
+	client := &Client{pl: pl}
+
+	b := NewBatch().
+		CreateChain(V4, Filter, CNIOutputChain).
+		InsertRule(V4, Filter, Output, "", CNIOutputChain)
+
+	require.NoError(t, client.ApplyBatch(b))
+	assert.Empty(t, pl.restores)
+}
+
+func TestApplyBatchChecksRulesWhichCannotBeNormalized(t *testing.T) {
+	existing := map[string]bool{
+		"-t filter -C AZURECNIOUTPUT -m comment --comment kept -j ACCEPT":       true,
+		"-t filter -C AZURECNIOUTPUT ! -s 169.254.0.1 -d 169.254.0.4 -j ACCEPT": true,
+	}
+	pl := newBatchExecClient(t, testSnapshot, existing)
+	client := &Client{pl: pl}
+
+	b := NewBatch().
+		InsertRule(V4, Filter, CNIOutputChain, "-m comment --comment kept", Accept).
+		InsertRule(V4, Filter, CNIOutputChain, "-m comment --comment added", Accept).
+		InsertRule(V4, Filter, CNIOutputChain, "-m comment --comment added", Accept).
+		DeleteRule(V4, Filter, CNIOutputChain, "! -s 169.254.0.1 -d 169.254.0.4", Accept).
+		DeleteRule(V4, Filter, CNIOutputChain, "! -s 169.254.0.1 -d 169.254.0.4", Accept).
+		DeleteRule(V4, Filter, CNIOutputChain, "-m comment --comment missing", Accept)
+
+	require.NoError(t, client.ApplyBatch(b))
+	require.Equal(t, []string{
+		"*filter\n" +
+			"-I AZURECNIOUTPUT 1 -m comment --comment added -j ACCEPT\n" +
+			"-D AZURECNIOUTPUT ! -s 169.254.0.1 -d 169.254.0.4 -j ACCEPT\n" +
+			"COMMIT\n",
+	}, pl.restores, "rules must be added and deleted once, as iptables -C finds them")
+	assert.Equal(t, []string{
+		"-t filter -C AZURECNIOUTPUT -m comment --comment kept -j ACCEPT",
+		"-t filter -C AZURECNIOUTPUT -m comment --comment added -j ACCEPT",
+		"-t filter -C AZURECNIOUTPUT ! -s 169.254.0.1 -d 169.254.0.4 -j ACCEPT",
+		"-t filter -C AZURECNIOUTPUT -m comment --comment missing -j ACCEPT",
+	}, pl.checks)
+}
+
+func TestApplyBatchRetriesOnFreshSnapshot(t *testing.T) {
+	pl := newBatchExecClient(t, testSnapshot, nil, errors.New("iptables-restore: line 2 failed"))
+	client := &Client{pl: pl}
+
+	require.NoError(t, client.ApplyBatch(NewBatch().CreateChain(V4, Filter, CNIInputChain)))
+	assert.Equal(t, []string{"iptables-save -t filter", "iptables-save -t filter"}, pl.saves)
+	assert.Len(t, pl.restores, 2)
+
+	pl = newBatchExecClient(t, testSnapshot, nil, errors.New("first"), errors.New("second"))
+	client = &Client{pl: pl}
+	require.Error(t, client.ApplyBatch(NewBatch().CreateChain(V4, Filter, CNIInputChain)))
+	assert.Len(t, pl.restores, batchAttempts)
+}
+
+func TestApplyBatchSaveFails(t *testing.T) {
+	client := &Client{pl: platform.NewMockExecClient(true)}
+	require.Error(t, client.ApplyBatch(NewBatch().CreateChain(V4, Filter, CNIInputChain)))
+}
+
+func TestCanNormalize(t *testing.T) {
+	tests := []struct {
+		spec string
+		want bool
+	}{
+		{spec: "-s 10.0.0.1 -d 10.0.0.0/24 -j ACCEPT", want: true},
+		{spec: "-p udp --dport 53 -j DROP", want: true},
+		{spec: "-i azSnatbr -m state --state ESTABLISHED,RELATED -j ACCEPT", want: true},
+		{spec: "-j MARK --set-mark 333", want: true},
+		{spec: "! -s 10.0.0.1 -j ACCEPT", want: false},
+		{spec: "-m comment --comment \"azure cni\" -j ACCEPT", want: false},
+		{spec: "-m mark --mark 333 -j ACCEPT", want: false},
+		{spec: "-m addrtype ! --dst-type LOCAL -j MASQUERADE", want: false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, canNormalize(tt.spec), tt.spec)
+	}
+}
+
+func TestNormalizeRuleSpec(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{spec: "-s 10.0.0.1 -d 10.0.0.0/24 -j ACCEPT", want: "-s 10.0.0.1/32 -d 10.0.0.0/24 -j ACCEPT"},
+		{spec: "-d fd00::1 -j ACCEPT", want: "-d fd00::1/128 -j ACCEPT"},
+		{spec: "-p udp --dport 53 -j DROP", want: "-p udp -m udp --dport 53 -j DROP"},
+		{spec: "-p udp -m udp --dport 53 -j DROP", want: "-p udp -m udp --dport 53 -j DROP"},
+		{spec: " -o azSnatbr  -m state --state RELATED,ESTABLISHED -j ACCEPT", want: "-o azSnatbr -m state --state ESTABLISHED,RELATED -j ACCEPT"},
+		{spec: "-j MARK --set-mark 101", want: "-j MARK --set-xmark 0x65/0xffffffff"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, normalizeRuleSpec(tt.spec), tt.spec)
+	}
+}
@@ -0,0 +1,56 @@
+package iptables
+
+import "errors"
+
+// ErrMockIPTables is returned by MockClient when it is configured to fail.
+var ErrMockIPTables = errors.New("mock iptables error")
+
+// MockClient records the rules and batches programmed through it.
+type MockClient struct {
+	returnError bool
+	// Ops has every operation applied so far, single rule calls included, in order.
+	Ops []BatchOp
+}
+
+func NewMockClient(returnError bool) *MockClient {
+	return &MockClient{returnError: returnError}
+}
+
+func (m *MockClient) record(op BatchOp) error {
+	if m.returnError {
+		return ErrMockIPTables
+	}
+	m.Ops = append(m.Ops, op)
+	return nil
+}
+
+func (m *MockClient) InsertIptableRule(version, tableName, chainName, match, target string) error {
+	return m.record(BatchOp{Version: version, Table: tableName, Chain: chainName, Action: Insert, Match: match, Target: target})
+}
+
+func (m *MockClient) AppendIptableRule(version, tableName, chainName, match, target string) error {
+	return m.record(BatchOp{Version: version, Table: tableName, Chain: chainName, Action: Append, Match: match, Target: target})
+}
+
+func (m *MockClient) DeleteIptableRule(version, tableName, chainName, match, target string) error {
+	return m.record(BatchOp{Version: version, Table: tableName, Chain: chainName, Action: Delete, Match: match, Target: target})
+}
+
+func (m *MockClient) CreateChain(version, tableName, chainName string) error {
+	return m.record(BatchOp{Version: version, Table: tableName, Chain: chainName, Action: createChain})
+}
+
+func (m *MockClient) RunCmd(_, _ string) error {
+	if m.returnError {
+		return ErrMockIPTables
+	}
+	return nil
+}
+
+func (m *MockClient) ApplyBatch(b *Batch) error {
+	if m.returnError {
+		return ErrMockIPTables
+	}
+	m.Ops = append(m.Ops, b.Ops...)
+	return nil
+}
@@ -232,7 +232,12 @@ func setIpRoute(route *Route, add bool) error {
 
 	msg := newRtMsg(route.Family)
 	msg.Tos = uint8(route.Tos)
-	msg.Table = uint8(route.Table)
+	// the header only holds 8 bit table ids, larger ones go in the RTA_TABLE attribute
+	if route.Table > unix.RT_TABLE_LOCAL {
+		msg.Table = unix.RT_TABLE_UNSPEC
+	} else {
+		msg.Table = uint8(route.Table)
+	}
 
 	if route.Protocol != 0 {
 		msg.Protocol = uint8(route.Protocol)
@@ -276,6 +281,10 @@ func setIpRoute(route *Route, add bool) error {
 		req.addPayload(newAttributeUint32(unix.RTA_IIF, uint32(route.ILinkIndex)))
 	}
 
+	if route.Table > unix.RT_TABLE_LOCAL {
+		req.addPayload(newAttributeUint32(unix.RTA_TABLE, uint32(route.Table)))
+	}
+
 	return s.sendAndWaitForAck(req)
 }
 
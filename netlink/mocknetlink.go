@@ -25,6 +25,9 @@ type MockNetlink struct {
 	DeleteLinkFn             func(name string) error
 	SetOrRemoveLinkAddressFn func(linkInfo LinkInfo, mode, flags int) error
 	SetLinkNetNsByIndexFn    func(index int, fd uintptr) error
+	SetLinkMTUFn             func(name string, mtu int) error
+	SetLinkNameFn            func(name, newName string) error
+	SetLinkNetNsFn           func(name string, fd uintptr) error
 }
 
 func NewMockNetlink(returnError bool, errorString string) *MockNetlink {
@@ -54,6 +57,9 @@ func (f *MockNetlink) AddLink(l Link) error {
 }
 
 func (f *MockNetlink) SetLinkMTU(name string, mtu int) error {
+	if f.SetLinkMTUFn != nil {
+		return f.SetLinkMTUFn(name, mtu)
+	}
 	return f.error()
 }
 
@@ -64,7 +70,10 @@ func (f *MockNetlink) DeleteLink(name string) error {
 	return f.error()
 }
 
-func (f *MockNetlink) SetLinkName(string, string) error {
+func (f *MockNetlink) SetLinkName(name, newName string) error {
+	if f.SetLinkNameFn != nil {
+		return f.SetLinkNameFn(name, newName)
+	}
 	return f.error()
 }
 
@@ -80,7 +89,10 @@ func (f *MockNetlink) SetLinkMaster(string, string) error {
 	return f.error()
 }
 
-func (f *MockNetlink) SetLinkNetNs(string, uintptr) error {
+func (f *MockNetlink) SetLinkNetNs(name string, fd uintptr) error {
+	if f.SetLinkNetNsFn != nil {
+		return f.SetLinkNetNsFn(name, fd)
+	}
 	return f.error()
 }
 
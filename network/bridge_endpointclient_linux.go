@@ -64,6 +64,12 @@ func (client *LinuxBridgeEndpointClient) AddEndpoints(epInfo *EndpointInfo) erro
 		return err
 	}
 
+	if epInfo.MTU > 0 {
+		if err := client.nuc.SetVethPairMTU(client.hostVethName, client.containerVethName, epInfo.MTU); err != nil {
+			return err
+		}
+	}
+
 	containerIf, err := net.InterfaceByName(client.containerVethName)
 	if err != nil {
 		return err
@@ -96,6 +96,9 @@ type EndpointInfo struct {
 	NATInfo                  []policy.NATInfo // windows only
 	NICType                  cns.NICType
 	SkipDefaultRoutes        bool
+	SourceBasedRouting       bool   // linux secondary NICs only: routes go in a table of the NIC's own
+	MTU                      int    // zero keeps the endpoint client default
+	PodIfName                string // name of a secondary NIC inside the pod; empty keeps the host NIC name
 	HNSEndpointID            string
 	HNSNetworkID             string
 	HostIfName               string // unused in windows, and in linux
@@ -129,17 +132,19 @@ type RouteInfo struct {
 
 // InterfaceInfo contains information for secondary interfaces
 type InterfaceInfo struct {
-	Name              string
-	MacAddress        net.HardwareAddr
-	IPConfigs         []*IPConfig
-	Routes            []RouteInfo
-	DNS               DNSInfo
-	NICType           cns.NICType
-	SkipDefaultRoutes bool
-	HostSubnetPrefix  net.IPNet // Move this field from ipamAddResult
-	NCResponse        *cns.GetNetworkContainerResponse
-	PnPID             string
-	EndpointPolicies  []policy.Policy
+	Name               string
+	MacAddress         net.HardwareAddr
+	IPConfigs          []*IPConfig
+	Routes             []RouteInfo
+	DNS                DNSInfo
+	NICType            cns.NICType
+	SkipDefaultRoutes  bool
+	SourceBasedRouting bool
+	HostSubnetPrefix   net.IPNet // Move this field from ipamAddResult
+	NCResponse         *cns.GetNetworkContainerResponse
+	PnPID              string
+	EndpointPolicies   []policy.Policy
+	MTU                int
 	// these fields will be required for swiftv2 apipa nic
 	NetworkContainerID         string
 	AllowNCToHostCommunication bool
@@ -269,22 +274,33 @@ func (nw *network) getEndpointByPOD(podName string, podNameSpace string, doExact
 	logger.Info("Trying to retrieve endpoint for pod name in namespace", zap.String("podName", podName), zap.String("podNameSpace", podNameSpace))
 
 	var ep *endpoint
+	// secondary NICs of a multi-NIC pod are not the pod's endpoint in the network,
+	// so the infra endpoint is picked if the pod has more than one
+	var infraEp *endpoint
+	infraEpCount := 0
+	matches := 0
 
 	for _, endpoint := range nw.Endpoints {
 		if podNameMatches(endpoint.PODName, podName, doExactMatchForPodName) && endpoint.PODNameSpace == podNameSpace {
-			if ep == nil {
-				ep = endpoint
-			} else {
-				return nil, errMultipleEndpointsFound
+			ep = endpoint
+			matches++
+			if endpoint.NICType.IsInfraOrLegacy() {
+				infraEp = endpoint
+				infraEpCount++
 			}
 		}
 	}
 
-	if ep == nil {
+	switch {
+	case matches == 0:
 		return nil, errEndpointNotFound
+	case matches == 1:
+		return ep, nil
+	case infraEpCount == 1:
+		return infraEp, nil
+	default:
+		return nil, errMultipleEndpointsFound
 	}
-
-	return ep, nil
 }
 
 func podNameMatches(source string, actualValue string, doExactMatch bool) bool {
@@ -126,6 +126,47 @@ var _ = Describe("Test Endpoint", func() {
 				Expect(ep.PODName).To(Equal(podName))
 			})
 		})
+
+		Context("When the pod also has secondary NIC endpoints", func() {
+			It("Should return the infra endpoint", func() {
+				podName := "test"
+				podNS := "ns"
+				nw := &network{
+					Endpoints: map[string]*endpoint{},
+				}
+				nw.Endpoints["pod"] = &endpoint{
+					PODName:      podName,
+					PODNameSpace: podNS,
+					NICType:      cns.InfraNIC,
+				}
+				nw.Endpoints["pod-net1"] = &endpoint{
+					PODName:      podName,
+					PODNameSpace: podNS,
+					NICType:      cns.NodeNetworkInterfaceFrontendNIC,
+				}
+				ep, err := nw.getEndpointByPOD(podName, podNS, true)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ep.NICType).To(Equal(cns.InfraNIC))
+			})
+		})
+
+		Context("When the only endpoint of the pod is a secondary NIC", func() {
+			It("Should return that endpoint", func() {
+				podName := "test"
+				podNS := "ns"
+				nw := &network{
+					Endpoints: map[string]*endpoint{},
+				}
+				nw.Endpoints["pod-net1"] = &endpoint{
+					PODName:      podName,
+					PODNameSpace: podNS,
+					NICType:      cns.NodeNetworkInterfaceFrontendNIC,
+				}
+				ep, err := nw.getEndpointByPOD(podName, podNS, true)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ep.NICType).To(Equal(cns.NodeNetworkInterfaceFrontendNIC))
+			})
+		})
 	})
 
 	Describe("Test podNameMatches", func() {
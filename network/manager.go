@@ -17,6 +17,7 @@ import (
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/netio"
 	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/ovsctl"
 	"github.com/Azure/azure-container-networking/platform"
 	"github.com/Azure/azure-container-networking/store"
 	"github.com/pkg/errors"
@@ -88,6 +89,7 @@ type networkManager struct {
 	nsClient           NamespaceClientInterface
 	iptablesClient     ipTablesClient
 	dhcpClient         dhcpClient
+	ovsctlClient       ovsctl.OvsInterface
 	sync.Mutex
 }
 
@@ -126,7 +128,7 @@ type NetworkManager interface {
 
 // Creates a new network manager.
 func NewNetworkManager(nl netlink.NetlinkInterface, plc platform.ExecClient, netioCli netio.NetIOInterface, nsc NamespaceClientInterface,
-	iptc ipTablesClient, dhcpc dhcpClient,
+	iptc ipTablesClient, dhcpc dhcpClient, ovsc ovsctl.OvsInterface,
 ) (NetworkManager, error) {
 	nm := &networkManager{
 		ExternalInterfaces: make(map[string]*externalInterface),
@@ -136,6 +138,7 @@ func NewNetworkManager(nl netlink.NetlinkInterface, plc platform.ExecClient, net
 		nsClient:           nsc,
 		iptablesClient:     iptc,
 		dhcpClient:         dhcpc,
+		ovsctlClient:       ovsc,
 	}
 
 	return nm, nil
@@ -406,6 +409,7 @@ func (nm *networkManager) createEndpoint(cli apipaClient, networkID string, epIn
 		}
 	}()
 
+	nm.reconcileOVSFlows()
 	return ep, nil
 }
 
@@ -505,6 +509,8 @@ func (nm *networkManager) DeleteEndpoint(networkID, endpointID string, epInfo *E
 	}
 
 	err = nw.deleteEndpoint(nm.netlink, nm.plClient, nm.netio, nm.nsClient, nm.iptablesClient, nm.dhcpClient, endpointID, mode)
+	// reconcile even if the delete failed so flows of endpoints that are gone from the store do not accumulate
+	nm.reconcileOVSFlows()
 	if err != nil {
 		return err
 	}
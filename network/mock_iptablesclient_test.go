@@ -1,5 +1,7 @@
 package network
 
+import "github.com/Azure/azure-container-networking/iptables"
+
 // mockIPTablesClient is a mock for the ipTablesClient interface that tracks calls.
 type mockIPTablesClient struct {
 	insertCalls []iptablesCall
@@ -22,3 +24,13 @@ func (c *mockIPTablesClient) AppendIptableRule(_, _, _, _, _ string) error { ret
 func (c *mockIPTablesClient) DeleteIptableRule(_, _, _, _, _ string) error { return nil }
 func (c *mockIPTablesClient) CreateChain(_, _, _ string) error             { return nil }
 func (c *mockIPTablesClient) RunCmd(_, _ string) error                     { return nil }
+
+// ApplyBatch records inserted rules alongside the single rule inserts.
+func (c *mockIPTablesClient) ApplyBatch(b *iptables.Batch) error {
+	for _, op := range b.Ops {
+		if op.Action == iptables.Insert {
+			c.insertCalls = append(c.insertCalls, iptablesCall{op.Version, op.Table, op.Chain, op.Match, op.Target})
+		}
+	}
+	return nil
+}
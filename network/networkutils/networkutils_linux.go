@@ -94,6 +94,19 @@ func (nu NetworkUtils) CreateEndpoint(hostVethName, containerVethName string, ma
 	return nil
 }
 
+// SetVethPairMTU applies mtu to both ends of a veth pair so the pod and host sides agree.
+func (nu NetworkUtils) SetVethPairMTU(hostVethName, containerVethName string, mtu int) error {
+	logger.Info("Setting mtu on veth pair", zap.Int("MTU", mtu), zap.String("hostVethName", hostVethName),
+		zap.String("containerVethName", containerVethName))
+	if err := nu.netlink.SetLinkMTU(hostVethName, mtu); err != nil {
+		return errors.Wrapf(err, "failed to set mtu %d on %s", mtu, hostVethName)
+	}
+	if err := nu.netlink.SetLinkMTU(containerVethName, mtu); err != nil {
+		return errors.Wrapf(err, "failed to set mtu %d on %s", mtu, containerVethName)
+	}
+	return nil
+}
+
 func (nu NetworkUtils) SetupContainerInterface(containerVethName, targetIfName string) error {
 	// Interface needs to be down before renaming.
 	if err := nu.netlink.SetLinkState(containerVethName, false); err != nil {
@@ -0,0 +1,124 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	stderrors "errors"
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/ovsctl"
+	"github.com/Azure/azure-container-networking/telemetry"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// reconcileOVSFlows converges the managed flows on every OVS bridge to the flows required by the endpoints in the store.
+// Failures are logged and never fail the calling CNI operation; the next reconciliation will retry.
+//
+// Only the per endpoint IP SNAT and MAC DNAT flows carry the managed cookie. The ARP flows of the bridge and the
+// endpoints, and the flows of infra vnet endpoints, whose container mac is not in the store, are programmed without
+// it: the reconciler neither restores them when they are missing nor deletes them.
+func (nm *networkManager) reconcileOVSFlows() {
+	if err := reconcileOVSFlows(nm.ExternalInterfaces, nm.ovsctlClient); err != nil {
+		logger.Error("[ovs] Failed to reconcile flows", zap.Error(err))
+	}
+}
+
+func reconcileOVSFlows(extIfs map[string]*externalInterface, ovs ovsctl.OvsInterface) error {
+	var errs []error
+	for _, extIf := range extIfs {
+		if !hasOVSNetwork(extIf) {
+			continue
+		}
+
+		desired, err := desiredOVSFlows(extIf, ovs)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		current, err := ovs.DumpManagedFlowCookies(extIf.BridgeName)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to dump flows on bridge %s", extIf.BridgeName))
+			continue
+		}
+
+		diff := ovsctl.DiffFlows(desired, current)
+		if diff.Empty() {
+			continue
+		}
+
+		logger.Info("[ovs] Reconciling flows", zap.String("bridge", extIf.BridgeName), zap.Int("desired", len(desired)),
+			zap.Int("missing", len(diff.Add)), zap.Int("stale", len(diff.Delete)))
+		if err := ovs.ApplyFlowDiff(extIf.BridgeName, diff); err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to apply flows on bridge %s", extIf.BridgeName))
+			continue
+		}
+		reportOVSFlowDrift(extIf.BridgeName, len(desired), diff)
+	}
+
+	return stderrors.Join(errs...)
+}
+
+// reportOVSFlowDrift sends the flows a reconciliation had to fix to CNI telemetry. CNI is too short lived to
+// export metrics of its own, so drift is only visible in telemetry and the CNI log.
+func reportOVSFlowDrift(bridgeName string, desired int, diff ovsctl.FlowDiff) {
+	telemetry.AIClient.SendEvent(fmt.Sprintf("[ovs] Reconciled flows on bridge %s: %d desired, %d missing added, %d stale deleted",
+		bridgeName, desired, len(diff.Add), len(diff.Delete)))
+	dims := map[string]string{telemetry.BridgeStr: bridgeName}
+	telemetry.AIClient.SendMetric(telemetry.CNIOVSFlowsAddedStr, float64(len(diff.Add)), dims)
+	telemetry.AIClient.SendMetric(telemetry.CNIOVSFlowsDeletedStr, float64(len(diff.Delete)), dims)
+}
+
+// hasOVSNetwork returns true if any network on the external interface is backed by an OVS bridge.
+func hasOVSNetwork(extIf *externalInterface) bool {
+	if extIf.BridgeName == "" {
+		return false
+	}
+	for _, nw := range extIf.Networks {
+		if isOVSNetwork(nw) {
+			return true
+		}
+	}
+	return false
+}
+
+func isOVSNetwork(nw *network) bool {
+	return nw.VlanId != 0 && nw.Mode != opModeTransparentVlan
+}
+
+// desiredOVSFlows computes the SNAT and DNAT flows for every OVS endpoint on the external interface's bridge.
+// Endpoints whose host veth is no longer attached to the bridge are skipped so their flows are removed.
+func desiredOVSFlows(extIf *externalInterface, ovs ovsctl.OvsInterface) ([]ovsctl.Flow, error) {
+	hostPort, err := ovs.GetOVSPortNumber(extIf.Name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get ovs port for %s", extIf.Name)
+	}
+
+	var flows []ovsctl.Flow
+	for _, nw := range extIf.Networks {
+		if !isOVSNetwork(nw) {
+			continue
+		}
+		for _, ep := range nw.Endpoints {
+			if ep.VlanID == 0 {
+				continue
+			}
+
+			containerPort, err := ovs.GetOVSPortNumber(ep.HostIfName)
+			if err != nil || containerPort == "" {
+				logger.Info("[ovs] Skipping endpoint without an ovs port", zap.String("endpoint", ep.Id),
+					zap.String("hostIfName", ep.HostIfName), zap.Error(err))
+				continue
+			}
+
+			for _, ipAddr := range ep.IPAddresses {
+				flows = append(flows, ovsctl.IPSnatFlows(ipAddr.IP, ep.VlanID, containerPort, extIf.MacAddress.String(), hostPort)...)
+				flows = append(flows, ovsctl.MacDnatFlow(hostPort, ipAddr.IP, ep.MacAddress.String(), ep.VlanID, containerPort))
+			}
+		}
+	}
+
+	return flows, nil
+}
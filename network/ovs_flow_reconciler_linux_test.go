@@ -0,0 +1,118 @@
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/ovsctl"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errNoOVSPort = errors.New("no ovs port")
+
+// fakeFlowOvsctl serves ovs ports and managed cookies from maps and records the applied diffs.
+type fakeFlowOvsctl struct {
+	ovsctl.MockOvsctl
+	ports   map[string]string
+	cookies []uint64
+	applied map[string]ovsctl.FlowDiff
+}
+
+func (f *fakeFlowOvsctl) GetOVSPortNumber(interfaceName string) (string, error) {
+	port, ok := f.ports[interfaceName]
+	if !ok {
+		return "", errNoOVSPort
+	}
+	return port, nil
+}
+
+func (f *fakeFlowOvsctl) DumpManagedFlowCookies(string) ([]uint64, error) {
+	return f.cookies, nil
+}
+
+func (f *fakeFlowOvsctl) ApplyFlowDiff(bridgeName string, diff ovsctl.FlowDiff) error {
+	f.applied[bridgeName] = diff
+	return nil
+}
+
+func TestReconcileOVSFlows(t *testing.T) {
+	hostMac, _ := net.ParseMAC("00:0d:3a:00:00:01")
+	epMac, _ := net.ParseMAC("00:0d:3a:00:00:02")
+	ip := net.IPNet{IP: net.ParseIP("10.240.0.5"), Mask: net.CIDRMask(16, 32)}
+
+	newExtIfs := func(mode string) map[string]*externalInterface {
+		extIf := &externalInterface{
+			Name:       "eth0",
+			BridgeName: "azure0",
+			MacAddress: hostMac,
+			Networks: map[string]*network{
+				"nw": {
+					Mode:   mode,
+					VlanId: 1,
+					Endpoints: map[string]*endpoint{
+						"ep1": {Id: "ep1", HostIfName: "azv1", VlanID: 1, MacAddress: epMac, IPAddresses: []net.IPNet{ip}},
+						"ep2": {Id: "ep2", HostIfName: "azv2", VlanID: 1, MacAddress: epMac, IPAddresses: []net.IPNet{ip}},
+					},
+				},
+			},
+		}
+		return map[string]*externalInterface{"eth0": extIf}
+	}
+
+	wantFlows := append(ovsctl.IPSnatFlows(ip.IP, 1, "2", hostMac.String(), "1"),
+		ovsctl.MacDnatFlow("1", ip.IP, epMac.String(), 1, "2"))
+	stale := ovsctl.MacDnatFlow("1", ip.IP, epMac.String(), 1, "9").Cookie()
+
+	t.Run("adds missing and removes stale flows", func(t *testing.T) {
+		// ep2 is no longer attached to the bridge, so it only contributes stale flows
+		ovs := &fakeFlowOvsctl{
+			ports:   map[string]string{"eth0": "1", "azv1": "2"},
+			cookies: []uint64{wantFlows[0].Cookie(), stale},
+			applied: map[string]ovsctl.FlowDiff{},
+		}
+		require.NoError(t, reconcileOVSFlows(newExtIfs(opModeTunnel), ovs))
+
+		diff := ovs.applied["azure0"]
+		assert.ElementsMatch(t, wantFlows[1:], diff.Add)
+		assert.Equal(t, []uint64{stale}, diff.Delete)
+	})
+
+	t.Run("converged bridge is not touched", func(t *testing.T) {
+		ovs := &fakeFlowOvsctl{
+			ports:   map[string]string{"eth0": "1", "azv1": "2"},
+			cookies: []uint64{wantFlows[0].Cookie(), wantFlows[1].Cookie(), wantFlows[2].Cookie()},
+			applied: map[string]ovsctl.FlowDiff{},
+		}
+		require.NoError(t, reconcileOVSFlows(newExtIfs(opModeTunnel), ovs))
+		assert.Empty(t, ovs.applied)
+	})
+
+	t.Run("infra vnet flows are left alone", func(t *testing.T) {
+		// the infra vnet flows of ep1 are unmanaged, so they are neither dumped nor desired
+		extIfs := newExtIfs(opModeTunnel)
+		ep1 := extIfs["eth0"].Networks["nw"].Endpoints["ep1"]
+		ep1.EnableInfraVnet = true
+		ep1.InfraVnetIP = net.IPNet{IP: net.ParseIP("169.254.0.5"), Mask: net.CIDRMask(16, 32)}
+		ovs := &fakeFlowOvsctl{
+			ports:   map[string]string{"eth0": "1", "azv1": "2", "azvifvep1": "3"},
+			cookies: []uint64{wantFlows[0].Cookie(), wantFlows[1].Cookie(), wantFlows[2].Cookie()},
+			applied: map[string]ovsctl.FlowDiff{},
+		}
+		require.NoError(t, reconcileOVSFlows(extIfs, ovs))
+		assert.Empty(t, ovs.applied)
+	})
+
+	t.Run("transparent vlan networks are skipped", func(t *testing.T) {
+		ovs := &fakeFlowOvsctl{applied: map[string]ovsctl.FlowDiff{}}
+		require.NoError(t, reconcileOVSFlows(newExtIfs(opModeTransparentVlan), ovs))
+		assert.Empty(t, ovs.applied)
+	})
+
+	t.Run("missing host port fails the bridge", func(t *testing.T) {
+		ovs := &fakeFlowOvsctl{ports: map[string]string{}, applied: map[string]ovsctl.FlowDiff{}}
+		require.Error(t, reconcileOVSFlows(newExtIfs(opModeTunnel), ovs))
+		assert.Empty(t, ovs.applied)
+	})
+}
@@ -0,0 +1,7 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+// reconcileOVSFlows is a no-op on Windows where there are no OVS bridges.
+func (nm *networkManager) reconcileOVSFlows() {}
@@ -89,15 +89,17 @@ func (client *OVSInfraVnetClient) CreateInfraVnetRules(
 		return err
 	}
 
+	// the container infra mac is not in the endpoint store, so the flow reconciliation can't compute these flows
+	// and they are added without the managed cookie for it to leave them alone.
 	// 0 signifies not to add vlan tag to this traffic
-	if err := ovs.AddIPSnatRule(bridgeName, infraIP.IP, 0, infraContainerPort, hostPrimaryMac, hostPort); err != nil {
+	if err := ovs.AddUnmanagedIPSnatRule(bridgeName, infraIP.IP, 0, infraContainerPort, hostPrimaryMac, hostPort); err != nil {
 		logger.Error("[ovs] AddIpSnatRule failed with", zap.Error(err))
 		return err
 	}
 
 	// 0 signifies not to match traffic based on vlan tag
-	if err := ovs.AddMacDnatRule(bridgeName, hostPort, infraIP.IP, client.containerInfraMac, 0, infraContainerPort); err != nil {
-		logger.Error("[ovs] AddMacDnatRule failed with", zap.Error(err))
+	if err := ovs.AddUnmanagedMacDnatRule(bridgeName, hostPort, infraIP.IP, client.containerInfraMac, 0, infraContainerPort); err != nil {
+		logger.Error("[ovs] AddUnmanagedMacDnatRule failed with", zap.Error(err))
 		return err
 	}
 
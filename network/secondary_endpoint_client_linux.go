@@ -2,6 +2,8 @@ package network
 
 import (
 	"context"
+	stderrors "errors"
+	"net"
 	"os"
 	"strings"
 	"time"
@@ -13,13 +15,59 @@ import (
 	"github.com/Azure/azure-container-networking/network/networkutils"
 	"github.com/Azure/azure-container-networking/platform"
 	"github.com/pkg/errors"
+	vishnetlink "github.com/vishvananda/netlink"
 	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
 )
 
 const (
 	NetworkNotReadyErrorMsg = "network is not ready"
+	// secondaryRouteTableBase is added to the ifindex of a secondary NIC that doesn't carry the pod's
+	// default route to get the routing table holding that NIC's routes inside the pod.
+	secondaryRouteTableBase = 1000
+	// secondaryRulePriority places the source based rules of secondary NICs ahead of the main table.
+	secondaryRulePriority = 1000
+	// hostIfNameAliasPrefix tags the link alias recording the host name of a NIC renamed inside the pod.
+	hostIfNameAliasPrefix = "acn-host-ifname:"
 )
 
+// secondaryNetlinkClient abstracts the vishvananda/netlink link alias and policy-routing
+// rule operations so that unit tests can avoid touching real netlink sockets.
+type secondaryNetlinkClient interface {
+	LinkAlias(name string) (string, error)
+	LinkSetAlias(name, alias string) error
+	RuleAdd(rule *vishnetlink.Rule) error
+}
+
+// defaultSecondaryNetlinkClient delegates to the real vishvananda/netlink package.
+type defaultSecondaryNetlinkClient struct{}
+
+func (defaultSecondaryNetlinkClient) LinkAlias(name string) (string, error) {
+	link, err := vishnetlink.LinkByName(name)
+	if err != nil {
+		return "", errors.Wrapf(err, "netlink LinkByName %s failed", name)
+	}
+	return link.Attrs().Alias, nil
+}
+
+func (defaultSecondaryNetlinkClient) LinkSetAlias(name, alias string) error {
+	link, err := vishnetlink.LinkByName(name)
+	if err != nil {
+		return errors.Wrapf(err, "netlink LinkByName %s failed", name)
+	}
+	if err := vishnetlink.LinkSetAlias(link, alias); err != nil {
+		return errors.Wrap(err, "netlink LinkSetAlias failed")
+	}
+	return nil
+}
+
+func (defaultSecondaryNetlinkClient) RuleAdd(rule *vishnetlink.Rule) error {
+	if err := vishnetlink.RuleAdd(rule); err != nil {
+		return errors.Wrap(err, "netlink RuleAdd failed")
+	}
+	return nil
+}
+
 var errorSecondaryEndpointClient = errors.New("SecondaryEndpointClient Error")
 
 func newErrorSecondaryEndpointClient(err error) error {
@@ -33,6 +81,7 @@ type SecondaryEndpointClient struct {
 	netUtilsClient networkutils.NetworkUtils
 	nsClient       NamespaceClientInterface
 	dhcpClient     dhcpClient
+	nlClient       secondaryNetlinkClient
 	ep             *endpoint
 	// ifIndex is the ifindex of the interface this endpoint refers to, resolved in
 	// AddEndpoints. Used to act on the device by index rather than by name.
@@ -54,6 +103,7 @@ func NewSecondaryEndpointClient(
 		netUtilsClient: networkutils.NewNetworkUtils(nl, plc),
 		nsClient:       nsc,
 		dhcpClient:     dhcpClient,
+		nlClient:       defaultSecondaryNetlinkClient{},
 		ep:             endpoint,
 	}
 
@@ -101,11 +151,12 @@ func (client *SecondaryEndpointClient) AddEndpoints(epInfo *EndpointInfo) error
 	}
 
 	client.ep.SecondaryInterfaces[master.Name] = &InterfaceInfo{
-		Name:              master.Name,
-		MacAddress:        epInfo.MacAddress,
-		IPConfigs:         ipconfigs,
-		NICType:           epInfo.NICType,
-		SkipDefaultRoutes: epInfo.SkipDefaultRoutes,
+		Name:               master.Name,
+		MacAddress:         epInfo.MacAddress,
+		IPConfigs:          ipconfigs,
+		NICType:            epInfo.NICType,
+		SkipDefaultRoutes:  epInfo.SkipDefaultRoutes,
+		SourceBasedRouting: epInfo.SourceBasedRouting,
 	}
 
 	return nil
@@ -137,11 +188,49 @@ func (client *SecondaryEndpointClient) MoveEndpointsToContainerNS(epInfo *Endpoi
 }
 
 func (client *SecondaryEndpointClient) SetupContainerInterfaces(epInfo *EndpointInfo) error {
+	if epInfo.PodIfName != "" && epInfo.PodIfName != epInfo.IfName {
+		if err := client.renameContainerInterface(epInfo); err != nil {
+			return err
+		}
+	}
+
 	logger.Info("[net] Setting link state up.", zap.String("IfName", epInfo.IfName))
 	if err := client.netlink.SetLinkState(epInfo.IfName, true); err != nil {
 		return newErrorSecondaryEndpointClient(err)
 	}
 
+	if epInfo.MTU > 0 {
+		logger.Info("[net] Setting link mtu.", zap.String("IfName", epInfo.IfName), zap.Int("MTU", epInfo.MTU))
+		if err := client.netlink.SetLinkMTU(epInfo.IfName, epInfo.MTU); err != nil {
+			return newErrorSecondaryEndpointClient(err)
+		}
+	}
+
+	return nil
+}
+
+// renameContainerInterface gives the NIC the name requested for it inside the pod. The host name is
+// kept in the link alias so that DeleteEndpoints can restore it before handing the NIC back.
+func (client *SecondaryEndpointClient) renameContainerInterface(epInfo *EndpointInfo) error {
+	hostIfName := epInfo.IfName
+	logger.Info("[net] Renaming link.", zap.String("IfName", hostIfName), zap.String("PodIfName", epInfo.PodIfName))
+	if err := client.nlClient.LinkSetAlias(hostIfName, hostIfNameAliasPrefix+hostIfName); err != nil {
+		return newErrorSecondaryEndpointClient(err)
+	}
+
+	if err := client.netlink.SetLinkName(hostIfName, epInfo.PodIfName); err != nil {
+		return newErrorSecondaryEndpointClient(err)
+	}
+
+	if ifInfo, exists := client.ep.SecondaryInterfaces[hostIfName]; exists {
+		delete(client.ep.SecondaryInterfaces, hostIfName)
+		ifInfo.Name = epInfo.PodIfName
+		client.ep.SecondaryInterfaces[epInfo.PodIfName] = ifInfo
+	}
+	// the endpoint is looked up by its name inside the pod from now on, on DEL in particular
+	client.ep.IfName = epInfo.PodIfName
+	epInfo.IfName = epInfo.PodIfName
+
 	return nil
 }
 
@@ -166,7 +255,13 @@ func (client *SecondaryEndpointClient) ConfigureContainerInterfacesAndRoutes(epI
 		}
 	}
 
-	if err := addRoutes(client.netlink, client.netioshim, epInfo.IfName, epInfo.Routes); err != nil {
+	// the routes of a NIC which doesn't carry the pod's default route go in a table of its own, used for
+	// traffic sourced from the NIC's IPs, so that replies leave through the NIC the request came in on
+	if epInfo.SourceBasedRouting {
+		if err := client.addSourceBasedRouting(epInfo); err != nil {
+			return newErrorSecondaryEndpointClient(err)
+		}
+	} else if err := addRoutes(client.netlink, client.netioshim, epInfo.IfName, epInfo.Routes); err != nil {
 		return newErrorSecondaryEndpointClient(err)
 	}
 
@@ -188,6 +283,42 @@ func (client *SecondaryEndpointClient) ConfigureContainerInterfacesAndRoutes(epI
 	return nil
 }
 
+// addSourceBasedRouting adds the interface's routes to its own routing table and the rules looking
+// up that table for traffic sourced from the interface's IPs.
+func (client *SecondaryEndpointClient) addSourceBasedRouting(epInfo *EndpointInfo) error {
+	iface, err := client.netioshim.GetNetworkInterfaceByName(epInfo.IfName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get interface %s", epInfo.IfName)
+	}
+	table := secondaryRouteTableBase + iface.Index
+
+	for i := range epInfo.Routes {
+		epInfo.Routes[i].Table = table
+	}
+	if err := addRoutes(client.netlink, client.netioshim, epInfo.IfName, epInfo.Routes); err != nil {
+		return err
+	}
+
+	for _, ipAddr := range epInfo.IPAddresses {
+		bits := net.IPv6len * 8
+		if ipAddr.IP.To4() != nil {
+			bits = net.IPv4len * 8
+		}
+		rule := vishnetlink.NewRule()
+		rule.Src = &net.IPNet{IP: ipAddr.IP, Mask: net.CIDRMask(bits, bits)}
+		rule.Table = table
+		rule.Priority = secondaryRulePriority
+		rule.Family = netlink.GetIPAddressFamily(ipAddr.IP)
+
+		logger.Info("Adding source based rule", zap.String("src", rule.Src.String()), zap.Int("table", table), zap.String("ifName", epInfo.IfName))
+		if err := client.nlClient.RuleAdd(rule); err != nil && !errors.Is(err, unix.EEXIST) {
+			return errors.Wrapf(err, "failed to add rule for %s", rule.Src)
+		}
+	}
+
+	return nil
+}
+
 func (client *SecondaryEndpointClient) DeleteEndpoints(ep *endpoint) error {
 	// Get VM namespace
 	vmns, err := netns.New().Get()
@@ -227,22 +358,60 @@ func (client *SecondaryEndpointClient) DeleteEndpoints(ep *endpoint) error {
 			logger.Error("Failed to exit netns with", zap.Error(newErrorSecondaryEndpointClient(err)))
 		}
 	}()
+	// Every interface is handed back even if another one fails, so that one broken NIC of a multi-NIC
+	// pod doesn't keep the others stuck in its namespace.
+	var errs []error
 	// For stateless cni linux, check if delegated vmnic type, and if so, move the interface back to host network namespace using this *endpoint* struct's ifname
 	if ep.NICType == cns.NodeNetworkInterfaceFrontendNIC {
-		if err := client.netlink.SetLinkNetNs(ep.IfName, uintptr(vmns)); err != nil {
-			wrappedErr := newErrorSecondaryEndpointClient(err)
-			logger.Error("Failed to move interface", zap.String("IfName", ep.IfName), zap.Error(wrappedErr))
-			return wrappedErr
+		if err := client.moveInterfaceToHostNS(ep.IfName, uintptr(vmns)); err != nil {
+			logger.Error("Failed to move interface", zap.String("IfName", ep.IfName), zap.Error(err))
+			errs = append(errs, err)
+		} else {
+			delete(ep.SecondaryInterfaces, ep.IfName)
 		}
 	}
 	for iface := range ep.SecondaryInterfaces {
-		if err := client.netlink.SetLinkNetNs(iface, uintptr(vmns)); err != nil {
-			logger.Error("Failed to move interface", zap.String("IfName", iface), zap.Error(newErrorSecondaryEndpointClient(err)))
+		if ep.NICType == cns.NodeNetworkInterfaceFrontendNIC && iface == ep.IfName {
+			continue
+		}
+		if err := client.moveInterfaceToHostNS(iface, uintptr(vmns)); err != nil {
+			logger.Error("Failed to move interface", zap.String("IfName", iface), zap.Error(err))
+			errs = append(errs, err)
 			continue
 		}
 
 		delete(ep.SecondaryInterfaces, iface)
 	}
 
+	if len(errs) > 0 {
+		return newErrorSecondaryEndpointClient(stderrors.Join(errs...))
+	}
+
+	return nil
+}
+
+// moveInterfaceToHostNS moves an interface from the pod namespace, which must have been entered,
+// to the host one, restoring the host name of an interface renamed by SetupContainerInterfaces.
+func (client *SecondaryEndpointClient) moveInterfaceToHostNS(ifName string, vmns uintptr) error {
+	alias, err := client.nlClient.LinkAlias(ifName)
+	if err != nil {
+		logger.Error("Failed to get link alias", zap.String("IfName", ifName), zap.Error(err))
+	}
+
+	if hostIfName := strings.TrimPrefix(alias, hostIfNameAliasPrefix); hostIfName != alias && hostIfName != ifName {
+		// a link can't be renamed while it is up
+		if err := client.netlink.SetLinkState(ifName, false); err != nil {
+			return errors.Wrapf(err, "failed to set %s down", ifName)
+		}
+		if err := client.netlink.SetLinkName(ifName, hostIfName); err != nil {
+			return errors.Wrapf(err, "failed to rename %s back to %s", ifName, hostIfName)
+		}
+		ifName = hostIfName
+	}
+
+	if err := client.netlink.SetLinkNetNs(ifName, vmns); err != nil {
+		return errors.Wrapf(err, "failed to move %s to the host namespace", ifName)
+	}
+
 	return nil
 }
@@ -15,6 +15,7 @@ import (
 	"github.com/Azure/azure-container-networking/platform"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
+	vishnetlink "github.com/vishvananda/netlink"
 )
 
 // mockDHCPFail is a mock DHCP client that always returns an error
@@ -24,6 +25,30 @@ func (m *mockDHCPFail) DiscoverRequest(context.Context, net.HardwareAddr, string
 	return errors.New("mock DHCP discover request failed")
 }
 
+// mockSecondaryNetlinkClient stubs vishvananda/netlink link aliases and rules so tests
+// never touch real netlink sockets or require CAP_NET_ADMIN.
+type mockSecondaryNetlinkClient struct {
+	aliases map[string]string
+	rules   []*vishnetlink.Rule
+}
+
+func (m *mockSecondaryNetlinkClient) LinkAlias(name string) (string, error) {
+	return m.aliases[name], nil
+}
+
+func (m *mockSecondaryNetlinkClient) LinkSetAlias(name, alias string) error {
+	if m.aliases == nil {
+		m.aliases = make(map[string]string)
+	}
+	m.aliases[name] = alias
+	return nil
+}
+
+func (m *mockSecondaryNetlinkClient) RuleAdd(rule *vishnetlink.Rule) error {
+	m.rules = append(m.rules, rule)
+	return nil
+}
+
 func TestSecondaryAddEndpoints(t *testing.T) {
 	nl := netlink.NewMockNetlink(false, "")
 	plc := platform.NewMockExecClient(false)
@@ -133,6 +158,44 @@ func TestSecondaryMoveEndpointsUsesMasterIfIndex(t *testing.T) {
 	require.Equal(t, masterIndex, gotIndex, "move should dispatch by the recorded master ifindex")
 }
 
+func TestSecondarySetupContainerInterfacesMTU(t *testing.T) {
+	plc := platform.NewMockExecClient(false)
+
+	t.Run("applies mtu when set", func(t *testing.T) {
+		nl := netlink.NewMockNetlink(false, "")
+		var gotName string
+		var gotMTU int
+		nl.SetLinkMTUFn = func(name string, mtu int) error {
+			gotName, gotMTU = name, mtu
+			return nil
+		}
+		client := &SecondaryEndpointClient{
+			netlink:        nl,
+			plClient:       plc,
+			netUtilsClient: networkutils.NewNetworkUtils(nl, plc),
+		}
+
+		require.NoError(t, client.SetupContainerInterfaces(&EndpointInfo{IfName: "eth1", MTU: 9000}))
+		require.Equal(t, "eth1", gotName)
+		require.Equal(t, 9000, gotMTU)
+	})
+
+	t.Run("leaves mtu alone when unset", func(t *testing.T) {
+		nl := netlink.NewMockNetlink(false, "")
+		nl.SetLinkMTUFn = func(string, int) error {
+			t.Fatal("SetLinkMTU should not be called")
+			return nil
+		}
+		client := &SecondaryEndpointClient{
+			netlink:        nl,
+			plClient:       plc,
+			netUtilsClient: networkutils.NewNetworkUtils(nl, plc),
+		}
+
+		require.NoError(t, client.SetupContainerInterfaces(&EndpointInfo{IfName: "eth1"}))
+	})
+}
+
 func TestSecondaryDeleteEndpoints(t *testing.T) {
 	nl := netlink.NewMockNetlink(false, "")
 	plc := platform.NewMockExecClient(false)
@@ -151,6 +214,7 @@ func TestSecondaryDeleteEndpoints(t *testing.T) {
 				netUtilsClient: networkutils.NewNetworkUtils(nl, plc),
 				netioshim:      netio.NewMockNetIO(false, 0),
 				nsClient:       NewMockNamespaceClient(),
+				nlClient:       &mockSecondaryNetlinkClient{},
 			},
 			ep: &endpoint{
 				NetworkNameSpace: "testns",
@@ -174,6 +238,7 @@ func TestSecondaryDeleteEndpoints(t *testing.T) {
 				netUtilsClient: networkutils.NewNetworkUtils(nl, plc),
 				netioshim:      netio.NewMockNetIO(false, 0),
 				nsClient:       NewMockNamespaceClient(),
+				nlClient:       &mockSecondaryNetlinkClient{},
 			},
 			ep: &endpoint{
 				SecondaryInterfaces: map[string]*InterfaceInfo{
@@ -196,6 +261,7 @@ func TestSecondaryDeleteEndpoints(t *testing.T) {
 				netUtilsClient: networkutils.NewNetworkUtils(nl, plc),
 				netioshim:      netio.NewMockNetIO(false, 0),
 				nsClient:       NewMockNamespaceClient(),
+				nlClient:       &mockSecondaryNetlinkClient{},
 			},
 			ep: &endpoint{
 				NetworkNameSpace: failToEnterNamespaceName,
@@ -220,6 +286,7 @@ func TestSecondaryDeleteEndpoints(t *testing.T) {
 				netUtilsClient: networkutils.NewNetworkUtils(nl, plc),
 				netioshim:      netio.NewMockNetIO(false, 0),
 				nsClient:       NewMockNamespaceClient(),
+				nlClient:       &mockSecondaryNetlinkClient{},
 			},
 			ep: &endpoint{
 				NetworkNameSpace: failToEnterNamespaceName,
@@ -246,6 +313,7 @@ func TestSecondaryDeleteEndpoints(t *testing.T) {
 				netUtilsClient: networkutils.NewNetworkUtils(nl, plc),
 				netioshim:      netio.NewMockNetIO(false, 0),
 				nsClient:       NewMockNamespaceClient(),
+				nlClient:       &mockSecondaryNetlinkClient{},
 			},
 			// revisit in future, but currently the struct looks like this (with duplicated fields)
 			ep: &endpoint{
@@ -453,3 +521,155 @@ func TestSecondaryConfigureContainerInterfacesAndRoutes(t *testing.T) {
 		})
 	}
 }
+
+func TestSecondarySetupContainerInterfacesRename(t *testing.T) {
+	nl := netlink.NewMockNetlink(false, "")
+	plc := platform.NewMockExecClient(false)
+	var renamed [2]string
+	nl.SetLinkNameFn = func(name, newName string) error {
+		renamed = [2]string{name, newName}
+		return nil
+	}
+	nlClient := &mockSecondaryNetlinkClient{}
+	ep := &endpoint{IfName: "eth1", SecondaryInterfaces: map[string]*InterfaceInfo{"eth1": {Name: "eth1"}}}
+	client := &SecondaryEndpointClient{
+		netlink:        nl,
+		plClient:       plc,
+		netUtilsClient: networkutils.NewNetworkUtils(nl, plc),
+		nlClient:       nlClient,
+		ep:             ep,
+	}
+
+	epInfo := &EndpointInfo{IfName: "eth1", PodIfName: "net1"}
+	require.NoError(t, client.SetupContainerInterfaces(epInfo))
+	require.Equal(t, [2]string{"eth1", "net1"}, renamed)
+	require.Equal(t, hostIfNameAliasPrefix+"eth1", nlClient.aliases["eth1"], "the host name should be kept on the link")
+	require.Equal(t, "net1", epInfo.IfName)
+	require.Equal(t, "net1", ep.IfName)
+	require.Contains(t, ep.SecondaryInterfaces, "net1")
+	require.NotContains(t, ep.SecondaryInterfaces, "eth1")
+}
+
+func TestSecondaryConfigureSourceBasedRouting(t *testing.T) {
+	nl := netlink.NewMockNetlink(false, "")
+	plc := platform.NewMockExecClient(false)
+	var routes []*netlink.Route
+	nl.SetAddRouteValidationFn(func(route *netlink.Route) error {
+		routes = append(routes, route)
+		return nil
+	})
+	nioc := netio.NewMockNetIO(false, 0)
+	nioc.SetGetInterfaceValidatonFn(func(name string) (*net.Interface, error) {
+		return &net.Interface{Name: name, Index: 5}, nil
+	})
+	nlClient := &mockSecondaryNetlinkClient{}
+	client := &SecondaryEndpointClient{
+		netlink:        nl,
+		plClient:       plc,
+		netUtilsClient: networkutils.NewNetworkUtils(nl, plc),
+		netioshim:      nioc,
+		dhcpClient:     &mockDHCP{},
+		nlClient:       nlClient,
+		ep:             &endpoint{SecondaryInterfaces: map[string]*InterfaceInfo{"net1": {Name: "net1"}}},
+	}
+
+	_, defaultDst, _ := net.ParseCIDR("0.0.0.0/0")
+	epInfo := &EndpointInfo{
+		IfName:             "net1",
+		SourceBasedRouting: true,
+		IPAddresses: []net.IPNet{
+			{
+				IP:   net.ParseIP("192.168.0.4"),
+				Mask: net.CIDRMask(subnetv4Mask, ipv4Bits),
+			},
+		},
+		Routes: []RouteInfo{
+			{
+				Dst: net.IPNet{IP: net.ParseIP("169.254.1.1"), Mask: net.CIDRMask(ipv4FullMask, ipv4Bits)},
+			},
+			{
+				Dst: *defaultDst,
+				Gw:  net.ParseIP("169.254.1.1"),
+			},
+		},
+	}
+	require.NoError(t, client.ConfigureContainerInterfacesAndRoutes(epInfo))
+
+	// both routes go in the interface's own table rather than the main one
+	require.Len(t, routes, 2)
+	for _, route := range routes {
+		require.Equal(t, secondaryRouteTableBase+5, route.Table)
+	}
+	require.Len(t, nlClient.rules, 1)
+	require.Equal(t, "192.168.0.4/32", nlClient.rules[0].Src.String())
+	require.Equal(t, secondaryRouteTableBase+5, nlClient.rules[0].Table)
+}
+
+func TestSecondaryDeleteEndpointsMultiNIC(t *testing.T) {
+	plc := platform.NewMockExecClient(false)
+
+	t.Run("restores the host name", func(t *testing.T) {
+		nl := netlink.NewMockNetlink(false, "")
+		var renamed [2]string
+		nl.SetLinkNameFn = func(name, newName string) error {
+			renamed = [2]string{name, newName}
+			return nil
+		}
+		var moved []string
+		nl.SetLinkNetNsFn = func(name string, _ uintptr) error {
+			moved = append(moved, name)
+			return nil
+		}
+		client := &SecondaryEndpointClient{
+			netlink:        nl,
+			plClient:       plc,
+			netUtilsClient: networkutils.NewNetworkUtils(nl, plc),
+			netioshim:      netio.NewMockNetIO(false, 0),
+			nsClient:       NewMockNamespaceClient(),
+			nlClient:       &mockSecondaryNetlinkClient{aliases: map[string]string{"net1": hostIfNameAliasPrefix + "eth1"}},
+		}
+		ep := &endpoint{
+			NetworkNameSpace:    "testns",
+			IfName:              "net1",
+			NICType:             cns.NodeNetworkInterfaceFrontendNIC,
+			SecondaryInterfaces: map[string]*InterfaceInfo{"net1": {Name: "net1"}},
+		}
+
+		require.NoError(t, client.DeleteEndpoints(ep))
+		require.Equal(t, [2]string{"net1", "eth1"}, renamed)
+		require.Equal(t, []string{"eth1"}, moved, "the interface should be moved once, under its host name")
+		require.Empty(t, ep.SecondaryInterfaces)
+	})
+
+	t.Run("moves the remaining interfaces when one fails", func(t *testing.T) {
+		nl := netlink.NewMockNetlink(false, "")
+		nl.SetLinkNetNsFn = func(name string, _ uintptr) error {
+			if name == "eth1" {
+				return errors.New("move failed")
+			}
+			return nil
+		}
+		client := &SecondaryEndpointClient{
+			netlink:        nl,
+			plClient:       plc,
+			netUtilsClient: networkutils.NewNetworkUtils(nl, plc),
+			netioshim:      netio.NewMockNetIO(false, 0),
+			nsClient:       NewMockNamespaceClient(),
+			nlClient:       &mockSecondaryNetlinkClient{},
+		}
+		ep := &endpoint{
+			NetworkNameSpace: "testns",
+			IfName:           "eth1",
+			NICType:          cns.NodeNetworkInterfaceFrontendNIC,
+			SecondaryInterfaces: map[string]*InterfaceInfo{
+				"eth1": {Name: "eth1"},
+				"eth2": {Name: "eth2"},
+			},
+		}
+
+		err := client.DeleteEndpoints(ep)
+		require.ErrorContains(t, err, "move failed")
+		require.Contains(t, ep.SecondaryInterfaces, "eth1", "the failed interface should be kept for a retry")
+		require.NotContains(t, ep.SecondaryInterfaces, "eth2")
+	})
+}
@@ -37,6 +37,7 @@ type ipTablesClient interface {
 	AppendIptableRule(version, tableName, chainName, match, target string) error
 	DeleteIptableRule(version, tableName, chainName, match, target string) error
 	CreateChain(version, tableName, chainName string) error
+	ApplyBatch(b *iptables.Batch) error
 }
 
 var errorSnatClient = errors.New("SnatClient Error")
@@ -187,43 +188,19 @@ func getNCLocalAndGatewayIP(client *Client) (brIP, contIP net.IP) {
 func (client *Client) AllowInboundFromHostToNC() error {
 	bridgeIP, containerIP := getNCLocalAndGatewayIP(client)
 
-	// Create CNI Output chain
-	if err := client.ipTablesClient.CreateChain(iptables.V4, iptables.Filter, iptables.CNIOutputChain); err != nil {
-		logger.Error("AllowInboundFromHostToNC: Creating failed with", zap.Any("CNIOutputChain", iptables.CNIOutputChain), zap.Error(err))
-		return newErrorSnatClient(err.Error())
-	}
-
-	// Forward traffic from Ouptut chain to CNI Output chain
-	if err := client.ipTablesClient.InsertIptableRule(iptables.V4, iptables.Filter, iptables.Output, "", iptables.CNIOutputChain); err != nil {
-		logger.Error("AllowInboundFromHostToNC: Creating failed with", zap.Any("CNIOutputChain", iptables.CNIOutputChain), zap.Error(err))
-		return newErrorSnatClient(err.Error())
-	}
-
-	// Allow connection from Host to NC
-	matchCondition := fmt.Sprintf("-s %s -d %s", bridgeIP.String(), containerIP.String())
-	err := client.ipTablesClient.InsertIptableRule(iptables.V4, iptables.Filter, iptables.CNIOutputChain, matchCondition, iptables.Accept)
-	if err != nil {
-		logger.Error("AllowInboundFromHostToNC: Inserting output rule failed with ", zap.Error(err))
-		return newErrorSnatClient(err.Error())
-	}
-
-	// Create cniinput chain
-	if err = client.ipTablesClient.CreateChain(iptables.V4, iptables.Filter, iptables.CNIInputChain); err != nil {
-		logger.Error("AllowInboundFromHostToNC: Creating failed with", zap.Any("CNIOutputChain", iptables.CNIOutputChain), zap.Error(err))
-		return newErrorSnatClient(err.Error())
-	}
-
-	// Forward from Input to cniinput chain
-	if err = client.ipTablesClient.InsertIptableRule(iptables.V4, iptables.Filter, iptables.Input, "", iptables.CNIInputChain); err != nil {
-		logger.Error("AllowInboundFromHostToNC: Inserting forward rule to failed with", zap.Any("CNIOutputChain", iptables.CNIOutputChain), zap.Error(err))
-		return newErrorSnatClient(err.Error())
-	}
-
-	// Accept packets from NC only if established connection
-	matchCondition = fmt.Sprintf(" -i %s -m state --state %s,%s", SnatBridgeName, iptables.Established, iptables.Related)
-	err = client.ipTablesClient.InsertIptableRule(iptables.V4, iptables.Filter, iptables.CNIInputChain, matchCondition, iptables.Accept)
-	if err != nil {
-		logger.Error("AllowInboundFromHostToNC: Inserting input rule failed with", zap.Error(err))
+	// Create the CNI Output and Input chains and forward Output and Input traffic to them.
+	// Allow connection from Host to NC, and accept packets from NC only if established connection.
+	batch := iptables.NewBatch().
+		CreateChain(iptables.V4, iptables.Filter, iptables.CNIOutputChain).
+		InsertRule(iptables.V4, iptables.Filter, iptables.Output, "", iptables.CNIOutputChain).
+		InsertRule(iptables.V4, iptables.Filter, iptables.CNIOutputChain,
+			fmt.Sprintf("-s %s -d %s", bridgeIP.String(), containerIP.String()), iptables.Accept).
+		CreateChain(iptables.V4, iptables.Filter, iptables.CNIInputChain).
+		InsertRule(iptables.V4, iptables.Filter, iptables.Input, "", iptables.CNIInputChain).
+		InsertRule(iptables.V4, iptables.Filter, iptables.CNIInputChain,
+			fmt.Sprintf(" -i %s -m state --state %s,%s", SnatBridgeName, iptables.Established, iptables.Related), iptables.Accept)
+	if err := client.ipTablesClient.ApplyBatch(batch); err != nil {
+		logger.Error("AllowInboundFromHostToNC: Programming iptables rules failed with", zap.Error(err))
 		return newErrorSnatClient(err.Error())
 	}
 
@@ -283,47 +260,19 @@ func (client *Client) DeleteInboundFromHostToNC() error {
 func (client *Client) AllowInboundFromNCToHost() error {
 	bridgeIP, containerIP := getNCLocalAndGatewayIP(client)
 
-	// Create CNI Input chain
-	if err := client.ipTablesClient.CreateChain(iptables.V4, iptables.Filter, iptables.CNIInputChain); err != nil {
-		logger.Error("AllowInboundFromHostToNC: Creating failed with", zap.String("CNIInputChain", iptables.CNIInputChain),
-			zap.Error(err))
-		return err
-	}
-
-	// Forward traffic from Input to cniinput chain
-	if err := client.ipTablesClient.InsertIptableRule(iptables.V4, iptables.Filter, iptables.Input, "", iptables.CNIInputChain); err != nil {
-		logger.Error("AllowInboundFromHostToNC: Inserting forward rule to failed with", zap.String("CNIInputChain", iptables.CNIInputChain),
-			zap.Error(err))
-		return err
-	}
-
-	// Allow NC to Host connection
-	matchCondition := fmt.Sprintf("-s %s -d %s", containerIP.String(), bridgeIP.String())
-	err := client.ipTablesClient.InsertIptableRule(iptables.V4, iptables.Filter, iptables.CNIInputChain, matchCondition, iptables.Accept)
-	if err != nil {
-		logger.Error("AllowInboundFromHostToNC: Inserting output rule failed with", zap.Error(err))
-		return err
-	}
-
-	// Create CNI output chain
-	if err = client.ipTablesClient.CreateChain(iptables.V4, iptables.Filter, iptables.CNIOutputChain); err != nil {
-		logger.Error("AllowInboundFromHostToNC: Creating failed with", zap.String("CNIInputChain", iptables.CNIInputChain),
-			zap.Error(err))
-		return err
-	}
-
-	// Forward traffic from Output to CNI Output chain
-	if err = client.ipTablesClient.InsertIptableRule(iptables.V4, iptables.Filter, iptables.Output, "", iptables.CNIOutputChain); err != nil {
-		logger.Error("AllowInboundFromHostToNC: Inserting forward rule to failed with", zap.String("CNIInputChain", iptables.CNIInputChain),
-			zap.Error(err))
-		return err
-	}
-
-	// Accept packets from Host only if established connection
-	matchCondition = fmt.Sprintf(" -o %s -m state --state %s,%s", SnatBridgeName, iptables.Established, iptables.Related)
-	err = client.ipTablesClient.InsertIptableRule(iptables.V4, iptables.Filter, iptables.CNIOutputChain, matchCondition, iptables.Accept)
-	if err != nil {
-		logger.Error("AllowInboundFromHostToNC: Inserting input rule failed with", zap.Error(err))
+	// Create the CNI Input and Output chains and forward Input and Output traffic to them.
+	// Allow NC to Host connection, and accept packets from Host only if established connection.
+	batch := iptables.NewBatch().
+		CreateChain(iptables.V4, iptables.Filter, iptables.CNIInputChain).
+		InsertRule(iptables.V4, iptables.Filter, iptables.Input, "", iptables.CNIInputChain).
+		InsertRule(iptables.V4, iptables.Filter, iptables.CNIInputChain,
+			fmt.Sprintf("-s %s -d %s", containerIP.String(), bridgeIP.String()), iptables.Accept).
+		CreateChain(iptables.V4, iptables.Filter, iptables.CNIOutputChain).
+		InsertRule(iptables.V4, iptables.Filter, iptables.Output, "", iptables.CNIOutputChain).
+		InsertRule(iptables.V4, iptables.Filter, iptables.CNIOutputChain,
+			fmt.Sprintf(" -o %s -m state --state %s,%s", SnatBridgeName, iptables.Established, iptables.Related), iptables.Accept)
+	if err := client.ipTablesClient.ApplyBatch(batch); err != nil {
+		logger.Error("AllowInboundFromNCToHost: Programming iptables rules failed with", zap.Error(err))
 		return err
 	}
 
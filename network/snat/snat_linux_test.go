@@ -2,8 +2,10 @@ package snat
 
 import (
 	"os"
+	"reflect"
 	"testing"
 
+	"github.com/Azure/azure-container-networking/iptables"
 	"github.com/Azure/azure-container-networking/netio"
 	"github.com/Azure/azure-container-networking/netlink"
 )
@@ -28,6 +30,10 @@ func (c mockIPTablesClient) CreateChain(_, _, _ string) error {
 	return nil
 }
 
+func (c mockIPTablesClient) ApplyBatch(_ *iptables.Batch) error {
+	return nil
+}
+
 func TestMain(m *testing.M) {
 	exitCode := m.Run()
 
@@ -144,3 +150,29 @@ func TestAllowInboundFromNCToHost(t *testing.T) {
 		t.Errorf("Expected error when interface not found in allow nc to host but got nil")
 	}
 }
+
+func TestAllowInboundFromHostToNCBatchesRules(t *testing.T) {
+	iptc := iptables.NewMockClient(false)
+	client := GetTestClient(netlink.NewMockNetlink(false, ""), iptc, netio.NewMockNetIO(false, 0))
+
+	if err := client.AllowInboundFromHostToNC(); err != nil {
+		t.Fatalf("Error adding inbound rule: %v", err)
+	}
+
+	want := []iptables.BatchOp{
+		{Version: iptables.V4, Table: iptables.Filter, Chain: iptables.CNIOutputChain, Action: "N"},
+		{Version: iptables.V4, Table: iptables.Filter, Chain: iptables.Output, Action: iptables.Insert, Target: iptables.CNIOutputChain},
+		{Version: iptables.V4, Table: iptables.Filter, Chain: iptables.CNIOutputChain, Action: iptables.Insert, Match: "-s 169.254.0.1 -d 169.254.0.4", Target: iptables.Accept},
+		{Version: iptables.V4, Table: iptables.Filter, Chain: iptables.CNIInputChain, Action: "N"},
+		{Version: iptables.V4, Table: iptables.Filter, Chain: iptables.Input, Action: iptables.Insert, Target: iptables.CNIInputChain},
+		{Version: iptables.V4, Table: iptables.Filter, Chain: iptables.CNIInputChain, Action: iptables.Insert, Match: " -i azSnatbr -m state --state ESTABLISHED,RELATED", Target: iptables.Accept},
+	}
+	if !reflect.DeepEqual(want, iptc.Ops) {
+		t.Errorf("Expected rules %+v to be applied in one batch but got %+v", want, iptc.Ops)
+	}
+
+	client.ipTablesClient = iptables.NewMockClient(true)
+	if err := client.AllowInboundFromHostToNC(); err == nil {
+		t.Errorf("Expected error when the batch fails but got nil")
+	}
+}
@@ -122,13 +122,19 @@ func (client *TransparentEndpointClient) AddEndpoints(epInfo *EndpointInfo) erro
 
 	client.hostVethMac = hostVethIf.HardwareAddr
 
-	logger.Info("Setting mtu on veth interface", zap.Int("MTU", primaryIf.MTU), zap.String("hostVethName", client.hostVethName))
-	if err := client.netlink.SetLinkMTU(client.hostVethName, primaryIf.MTU); err != nil {
+	// prefer the mtu computed for this endpoint, falling back to the host primary interface mtu
+	mtu := primaryIf.MTU
+	if epInfo.MTU > 0 {
+		mtu = epInfo.MTU
+	}
+
+	logger.Info("Setting mtu on veth interface", zap.Int("MTU", mtu), zap.String("hostVethName", client.hostVethName))
+	if err := client.netlink.SetLinkMTU(client.hostVethName, mtu); err != nil {
 		logger.Error("Setting mtu failed for hostveth", zap.String("hostVethName", client.hostVethName),
 			zap.Error(err))
 	}
 
-	if err := client.netlink.SetLinkMTU(client.containerVethName, primaryIf.MTU); err != nil {
+	if err := client.netlink.SetLinkMTU(client.containerVethName, mtu); err != nil {
 		logger.Error("Setting mtu failed for containerveth", zap.String("containerVethName", client.containerVethName),
 			zap.Error(err))
 	}
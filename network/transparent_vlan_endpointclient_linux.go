@@ -27,6 +27,8 @@ const (
 	tunnelingTable        = 2                                         // Packets not entering on the vlan interface go to this routing table
 	tunnelingMark         = 333                                       // The packets that are to tunnel will be marked with this number
 	DisableRPFilterCmd    = "sysctl -w net.ipv4.conf.all.rp_filter=0" // Command to disable the rp filter for tunneling
+	enableProxyNdpCmd     = "sysctl -w net.ipv6.conf.%s.proxy_ndp=1"  // Command to enable the ndp proxy on an interface
+	addProxyNeighborCmd   = "ip -6 neigh replace proxy %s dev %s"     // Command to answer neighbor solicitations for an address
 	numRetries            = 5
 	sleepInMs             = 100
 )
@@ -394,6 +396,15 @@ func (client *TransparentVlanEndpointClient) PopulateVM(epInfo *EndpointInfo) er
 		return errors.Wrap(err, "failed to disable RA on container veth, deleting")
 	}
 
+	if epInfo.MTU > 0 {
+		if err = client.netUtilsClient.SetVethPairMTU(client.vnetVethName, client.containerVethName, epInfo.MTU); err != nil {
+			if delErr := client.netlink.DeleteLink(client.vnetVethName); delErr != nil {
+				logger.Error("Deleting vnet veth failed on addendpoint failure with", zap.Error(delErr))
+			}
+			return errors.Wrap(err, "failed to set mtu on veth pair, deleting")
+		}
+	}
+
 	if err = client.setLinkNetNSAndConfirm(client.vnetVethName, uintptr(client.vnetNSFileDescriptor), client.vnetNSName); err != nil {
 		if delErr := client.netlink.DeleteLink(client.vnetVethName); delErr != nil {
 			logger.Error("Deleting vnet veth failed on addendpoint failure with", zap.Error(delErr))
@@ -447,6 +458,21 @@ func (client *TransparentVlanEndpointClient) setArpProxy(ifName string) error {
 	return nil
 }
 
+// Set NDP proxy on the specified interface to respond to neighbor solicitations for the IPv6 gateway IP.
+// Unlike proxy_arp, proxy_ndp only answers for addresses that have an explicit proxy neighbor entry.
+func (client *TransparentVlanEndpointClient) setNdpProxy(ifName string) error {
+	if _, err := client.plClient.ExecuteRawCommand(fmt.Sprintf(enableProxyNdpCmd, ifName)); err != nil {
+		logger.Error("Failed to enable NDP proxy", zap.String("interface", ifName), zap.Error(err))
+		return errors.Wrap(err, "failed to enable ndp proxy")
+	}
+	gwIP, _, _ := net.ParseCIDR(virtualv6GwString)
+	if _, err := client.plClient.ExecuteRawCommand(fmt.Sprintf(addProxyNeighborCmd, gwIP.String(), ifName)); err != nil {
+		logger.Error("Failed to add NDP proxy entry", zap.String("interface", ifName), zap.Error(err))
+		return errors.Wrap(err, "failed to add ndp proxy entry for ipv6 gateway")
+	}
+	return nil
+}
+
 func (client *TransparentVlanEndpointClient) AddEndpointRules(epInfo *EndpointInfo) error {
 	if err := client.AddSnatEndpointRules(); err != nil {
 		return errors.Wrap(err, "failed to add snat endpoint rules")
@@ -459,7 +485,16 @@ func (client *TransparentVlanEndpointClient) AddEndpointRules(epInfo *EndpointIn
 
 		// Set ARP proxy on vnet veth (inside vnet namespace)
 		logger.Info("calling setArpProxy for", zap.String("vnetVethName", client.vnetVethName))
-		return client.setArpProxy(client.vnetVethName)
+		if err := client.setArpProxy(client.vnetVethName); err != nil {
+			return err
+		}
+
+		// IPv6 has no proxy_arp equivalent, so set NDP proxy on vnet veth (inside vnet namespace)
+		if epInfo.IsIPv6Enabled {
+			logger.Info("calling setNdpProxy for", zap.String("vnetVethName", client.vnetVethName))
+			return client.setNdpProxy(client.vnetVethName)
+		}
+		return nil
 	})
 
 	return err
@@ -491,13 +526,12 @@ func (client *TransparentVlanEndpointClient) AddVnetRules(epInfo *EndpointInfo)
 // family is the vishvananda/netlink address family (FAMILY_V4 or FAMILY_V6).
 func (client *TransparentVlanEndpointClient) addVnetMangleAndTunnelingRules(version string, family int) error {
 	markOption := fmt.Sprintf("MARK --set-mark %d", tunnelingMark)
-	if err := client.iptablesClient.InsertIptableRule(version, "mangle", "PREROUTING", "", markOption); err != nil {
-		return errors.Wrapf(err, "unable to insert %s mangle mark rule", version)
-	}
-
 	match := "-i " + client.vlanIfName
-	if err := client.iptablesClient.InsertIptableRule(version, "mangle", "PREROUTING", match, "ACCEPT"); err != nil {
-		return errors.Wrapf(err, "unable to insert %s mangle accept rule for vlan interface", version)
+	batch := iptables.NewBatch().
+		InsertRule(version, "mangle", "PREROUTING", "", markOption).
+		InsertRule(version, "mangle", "PREROUTING", match, "ACCEPT")
+	if err := client.iptablesClient.ApplyBatch(batch); err != nil {
+		return errors.Wrapf(err, "unable to insert %s mangle mark and accept rules for vlan interface", version)
 	}
 
 	// Add ip rule: marked packets go to the tunneling table
@@ -652,6 +686,11 @@ func (client *TransparentVlanEndpointClient) ConfigureVnetInterfacesAndRoutesImp
 	if err = client.addDefaultRoutesHelper(client.vlanIfName, tunnelingTable, virtualGwIPVlanString, defaultGwCidr); err != nil {
 		return errors.Wrap(err, "failed vnet ns add outbound routing table routes for tunneling (idempotent)")
 	}
+	if epInfo.IsIPv6Enabled {
+		if err = client.addDefaultRoutesHelper(client.vlanIfName, tunnelingTable, virtualv6GwString, defaultIPv6Prefix); err != nil {
+			return errors.Wrap(err, "failed vnet ns add ipv6 outbound routing table routes for tunneling (idempotent)")
+		}
+	}
 	// Return to ConfigureContainerInterfacesAndRoutes
 	return err
 }
@@ -1349,6 +1349,147 @@ func TestAddVnetRulesIPTables(t *testing.T) {
 	})
 }
 
+func TestSetNdpProxy(t *testing.T) {
+	t.Run("enables proxy_ndp and adds gateway proxy entry", func(t *testing.T) {
+		var cmds []string
+		plc := platform.NewMockExecClient(false)
+		plc.SetExecRawCommand(func(cmd string) (string, error) {
+			cmds = append(cmds, cmd)
+			return "", nil
+		})
+		client := &TransparentVlanEndpointClient{plClient: plc}
+
+		err := client.setNdpProxy("A1veth0")
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			"sysctl -w net.ipv6.conf.A1veth0.proxy_ndp=1",
+			"ip -6 neigh replace proxy fe80::1234:5678:9abc dev A1veth0",
+		}, cmds)
+	})
+
+	t.Run("fails when sysctl fails", func(t *testing.T) {
+		client := &TransparentVlanEndpointClient{plClient: platform.NewMockExecClient(true)}
+
+		err := client.setNdpProxy("A1veth0")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to enable ndp proxy")
+	})
+}
+
+func TestTransparentVlanConfigureVnetInterfacesAndRoutesDualStack(t *testing.T) {
+	type addedRoute struct {
+		dst   string
+		gw    string
+		table int
+	}
+	var routes []addedRoute
+	nl := netlink.NewMockNetlink(false, "")
+	nl.SetAddRouteValidationFn(func(r *netlink.Route) error {
+		routes = append(routes, addedRoute{dst: r.Dst.String(), gw: r.Gw.String(), table: r.Table})
+		return nil
+	})
+	neighbors := map[string]string{}
+	nl.SetOrRemoveLinkAddressFn = func(linkInfo netlink.LinkInfo, mode, _ int) error {
+		require.Equal(t, netlink.ADD, mode)
+		neighbors[linkInfo.IPAddr.String()] = linkInfo.MacAddress.String()
+		return nil
+	}
+	client := &TransparentVlanEndpointClient{
+		primaryHostIfName: "eth0",
+		vlanIfName:        "eth0.1",
+		vnetVethName:      "A1veth0",
+		containerVethName: "B1veth0",
+		vnetNSName:        "az_ns_1",
+		netlink:           nl,
+		plClient:          platform.NewMockExecClient(false),
+		netioshim:         netio.NewMockNetIO(false, 0),
+	}
+	epInfo := &EndpointInfo{
+		IsIPv6Enabled: true,
+		IPAddresses: []net.IPNet{
+			{IP: net.ParseIP("192.168.0.4"), Mask: net.CIDRMask(subnetv4Mask, ipv4Bits)},
+			{IP: net.ParseIP("fd00::4"), Mask: net.CIDRMask(ipv6FullMask, ipv6Bits)},
+		},
+	}
+
+	err := client.ConfigureVnetInterfacesAndRoutesImpl(epInfo)
+	require.NoError(t, err)
+
+	// the ipv6 gateway must resolve to the azure mac, and be the default route of the main and tunneling tables
+	require.Equal(t, azureMac, neighbors["fe80::1234:5678:9abc"])
+	require.Equal(t, azureMac, neighbors["169.254.2.1"])
+	for _, table := range []int{0, tunnelingTable} {
+		require.Contains(t, routes, addedRoute{dst: "::/0", gw: "fe80::1234:5678:9abc", table: table}, "no ipv6 default route in table %d", table)
+		require.Contains(t, routes, addedRoute{dst: "0.0.0.0/0", gw: "169.254.2.1", table: table}, "no ipv4 default route in table %d", table)
+	}
+	require.Contains(t, routes, addedRoute{dst: "fd00::4/128", gw: "<nil>", table: 0})
+
+	t.Run("ipv4 only programs no ipv6 routes", func(t *testing.T) {
+		routes = nil
+		neighbors = map[string]string{}
+		epInfo := &EndpointInfo{
+			IPAddresses: []net.IPNet{{IP: net.ParseIP("192.168.0.4"), Mask: net.CIDRMask(subnetv4Mask, ipv4Bits)}},
+		}
+
+		err := client.ConfigureVnetInterfacesAndRoutesImpl(epInfo)
+		require.NoError(t, err)
+		require.NotContains(t, neighbors, "fe80::1234:5678:9abc")
+		for _, route := range routes {
+			require.NotEqual(t, "::/0", route.dst)
+		}
+	})
+}
+
+func TestTransparentVlanAddEndpointRulesNdpProxy(t *testing.T) {
+	tests := []struct {
+		name          string
+		isIPv6Enabled bool
+		wantCmds      []string
+	}{
+		{
+			name:          "dual-stack sets arp and ndp proxy",
+			isIPv6Enabled: true,
+			wantCmds: []string{
+				"echo 1 > /proc/sys/net/ipv4/conf/A1veth0/proxy_arp",
+				"sysctl -w net.ipv6.conf.A1veth0.proxy_ndp=1",
+				"ip -6 neigh replace proxy fe80::1234:5678:9abc dev A1veth0",
+			},
+		},
+		{
+			name: "ipv4 only sets arp proxy only",
+			wantCmds: []string{
+				"echo 1 > /proc/sys/net/ipv4/conf/A1veth0/proxy_arp",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cmds []string
+			plc := platform.NewMockExecClient(false)
+			plc.SetExecRawCommand(func(cmd string) (string, error) {
+				cmds = append(cmds, cmd)
+				return "", nil
+			})
+			nl := netlink.NewMockNetlink(false, "")
+			client := &TransparentVlanEndpointClient{
+				vlanIfName:     "eth0.1",
+				vnetVethName:   "A1veth0",
+				vnetNSName:     "az_ns_1",
+				netlink:        nl,
+				plClient:       plc,
+				netUtilsClient: networkutils.NewNetworkUtils(nl, plc),
+				nsClient:       NewMockNamespaceClient(),
+				iptablesClient: &mockIPTablesClient{},
+				nlRuleClient:   &mockNetlinkRuleClient{},
+			}
+
+			err := client.AddEndpointRules(&EndpointInfo{IsIPv6Enabled: tt.isIPv6Enabled})
+			require.NoError(t, err)
+			require.Equal(t, tt.wantCmds, cmds)
+		})
+	}
+}
+
 func TestRunWithRetries(t *testing.T) {
 	errMock := errors.New("mock error")
 	runs := 4
@@ -34,6 +34,7 @@ func NewClient(c Config) (*Client, error) {
 			// nolint:gomnd // the base parameter is explained in the function
 			Cooldown: internal.Exponential(1*time.Second, 2),
 		},
+		resilience: newResilience(c),
 	}
 
 	return client, nil
@@ -51,10 +52,20 @@ type Client struct {
 	retrier interface {
 		Do(context.Context, func() error) error
 	}
+
+	// resilience is nil for clients built without NewClient, which disables
+	// circuit breaking, request coalescing and caching.
+	resilience *resilience
 }
 
 // JoinNetwork joins a node to a customer's virtual network.
 func (c *Client) JoinNetwork(ctx context.Context, jnr JoinNetworkRequest) error {
+	return guarded(ctx, c, EndpointJoinNetwork, func() error {
+		return c.joinNetwork(ctx, jnr)
+	})
+}
+
+func (c *Client) joinNetwork(ctx context.Context, jnr JoinNetworkRequest) error {
 	req, err := c.buildRequest(ctx, jnr)
 	if err != nil {
 		return errors.Wrap(err, "building request")
@@ -78,6 +89,12 @@ func (c *Client) JoinNetwork(ctx context.Context, jnr JoinNetworkRequest) error
 
 // DeleteNetwork deletes a customer network and it's associated subnets.
 func (c *Client) DeleteNetwork(ctx context.Context, dnr DeleteNetworkRequest) error {
+	return guarded(ctx, c, EndpointDeleteNetwork, func() error {
+		return c.deleteNetwork(ctx, dnr)
+	})
+}
+
+func (c *Client) deleteNetwork(ctx context.Context, dnr DeleteNetworkRequest) error {
 	req, err := c.buildRequest(ctx, dnr)
 	if err != nil {
 		return errors.Wrap(err, "building request")
@@ -99,6 +116,16 @@ func (c *Client) DeleteNetwork(ctx context.Context, dnr DeleteNetworkRequest) er
 // network. Only subnets which have been delegated will be returned.
 func (c *Client) GetNetworkConfiguration(ctx context.Context, gncr GetNetworkConfigRequest) (VirtualNetwork, error) {
 	var out VirtualNetwork
+	err := guarded(ctx, c, EndpointGetNetworkConfig, func() error {
+		var err error
+		out, err = c.getNetworkConfiguration(ctx, gncr)
+		return err
+	})
+	return out, err
+}
+
+func (c *Client) getNetworkConfiguration(ctx context.Context, gncr GetNetworkConfigRequest) (VirtualNetwork, error) {
+	var out VirtualNetwork
 
 	req, err := c.buildRequest(ctx, gncr)
 	if err != nil {
@@ -139,6 +166,16 @@ func (c *Client) GetNetworkConfiguration(ctx context.Context, gncr GetNetworkCon
 // Provisioning OwningServiceInstanceId property. The authentication token must
 // match the token on the subnet containing the Network Container address.
 func (c *Client) GetNCVersion(ctx context.Context, ncvr NCVersionRequest) (NCVersion, error) {
+	var out NCVersion
+	err := guarded(ctx, c, EndpointGetNCVersion, func() error {
+		var err error
+		out, err = c.getNCVersion(ctx, ncvr)
+		return err
+	})
+	return out, err
+}
+
+func (c *Client) getNCVersion(ctx context.Context, ncvr NCVersionRequest) (NCVersion, error) {
 	req, err := c.buildRequest(ctx, ncvr)
 	if err != nil {
 		return NCVersion{}, errors.Wrap(err, "building request")
@@ -166,6 +203,12 @@ func (c *Client) GetNCVersion(ctx context.Context, ncvr NCVersionRequest) (NCVer
 // PutNetworkContainer applies a Network Container goal state and publishes it
 // to PubSub.
 func (c *Client) PutNetworkContainer(ctx context.Context, pncr *PutNetworkContainerRequest) error {
+	return guarded(ctx, c, EndpointPutNetworkContainer, func() error {
+		return c.putNetworkContainer(ctx, pncr)
+	})
+}
+
+func (c *Client) putNetworkContainer(ctx context.Context, pncr *PutNetworkContainerRequest) error {
 	req, err := c.buildRequest(ctx, pncr)
 	if err != nil {
 		return errors.Wrap(err, "building request")
@@ -186,6 +229,12 @@ func (c *Client) PutNetworkContainer(ctx context.Context, pncr *PutNetworkContai
 // SupportedAPIs retrieves the capabilities of the nmagent running on
 // the node. This is useful for detecting if GRE Keys are supported.
 func (c *Client) SupportedAPIs(ctx context.Context) ([]string, error) {
+	return shared(ctx, c, EndpointSupportedAPIs, true, func(ctx context.Context) ([]string, error) {
+		return c.supportedAPIs(ctx)
+	})
+}
+
+func (c *Client) supportedAPIs(ctx context.Context) ([]string, error) {
 	sar := &SupportedAPIsRequest{}
 	req, err := c.buildRequest(ctx, sar)
 	if err != nil {
@@ -213,6 +262,12 @@ func (c *Client) SupportedAPIs(ctx context.Context) ([]string, error) {
 // DeleteNetworkContainer removes a Network Container, its associated IP
 // addresses, and network policies from an interface.
 func (c *Client) DeleteNetworkContainer(ctx context.Context, dcr DeleteContainerRequest) error {
+	return guarded(ctx, c, EndpointDeleteNetworkContainer, func() error {
+		return c.deleteNetworkContainer(ctx, dcr)
+	})
+}
+
+func (c *Client) deleteNetworkContainer(ctx context.Context, dcr DeleteContainerRequest) error {
 	req, err := c.buildRequest(ctx, dcr)
 	if err != nil {
 		return errors.Wrap(err, "building request")
@@ -232,6 +287,12 @@ func (c *Client) DeleteNetworkContainer(ctx context.Context, dcr DeleteContainer
 }
 
 func (c *Client) GetNCVersionList(ctx context.Context) (NCVersionList, error) {
+	return shared(ctx, c, EndpointGetNCVersionList, true, func(ctx context.Context) (NCVersionList, error) {
+		return c.getNCVersionList(ctx)
+	})
+}
+
+func (c *Client) getNCVersionList(ctx context.Context) (NCVersionList, error) {
 	req, err := c.buildRequest(ctx, &NCVersionListRequest{})
 	if err != nil {
 		return NCVersionList{}, errors.Wrap(err, "building request")
@@ -258,6 +319,12 @@ func (c *Client) GetNCVersionList(ctx context.Context) (NCVersionList, error) {
 
 // GetHomeAz gets node's home az from nmagent
 func (c *Client) GetHomeAz(ctx context.Context) (AzResponse, error) {
+	return shared(ctx, c, EndpointGetHomeAz, true, func(ctx context.Context) (AzResponse, error) {
+		return c.getHomeAz(ctx)
+	})
+}
+
+func (c *Client) getHomeAz(ctx context.Context) (AzResponse, error) {
 	getHomeAzRequest := &GetHomeAzRequest{}
 	var homeAzResponse AzResponse
 	req, err := c.buildRequest(ctx, getHomeAzRequest)
@@ -285,6 +352,12 @@ func (c *Client) GetHomeAz(ctx context.Context) (AzResponse, error) {
 
 // GetInterfaceIPInfo fetches the node's interface IP information from nmagent
 func (c *Client) GetInterfaceIPInfo(ctx context.Context) (Interfaces, error) {
+	return shared(ctx, c, EndpointGetInterfaceIPInfo, false, func(ctx context.Context) (Interfaces, error) {
+		return c.getInterfaceIPInfo(ctx)
+	})
+}
+
+func (c *Client) getInterfaceIPInfo(ctx context.Context) (Interfaces, error) {
 	req, err := c.buildRequest(ctx, &GetSecondaryIPsRequest{})
 	var out Interfaces
 
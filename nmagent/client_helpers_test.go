@@ -22,3 +22,11 @@ func NewTestClient(transport http.RoundTripper) *Client {
 		},
 	}
 }
+
+// NewTestClientWithResilience is like NewTestClient but enables circuit
+// breaking, request coalescing and caching with the given settings.
+func NewTestClientWithResilience(transport http.RoundTripper, c Config) *Client {
+	client := NewTestClient(transport)
+	client.resilience = newResilience(c)
+	return client
+}
@@ -5,6 +5,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-container-networking/nmagent/internal"
 	"github.com/pkg/errors"
@@ -22,6 +23,15 @@ type Config struct {
 	// Optional Config //
 	/////////////////////
 	UseTLS bool // forces all connections to use TLS
+
+	// Resiliency settings. Zero values select the defaults.
+	BreakerFailureThreshold int           // consecutive failures that open an endpoint's circuit breaker
+	BreakerCooldown         time.Duration // how long a breaker stays open before a probe request is let through
+	CacheTTL                time.Duration // how long idempotent GET responses are cached, negative disables caching
+	SharedCallTimeout       time.Duration // how long a coalesced request may take, it is not cancelled by its callers
+	// OnBreakerStateChange is called whenever an endpoint's circuit breaker
+	// changes state. It must not call back into the Client.
+	OnBreakerStateChange func(endpoint string, state BreakerState)
 }
 
 // Validate reports whether this configuration is a valid configuration for a
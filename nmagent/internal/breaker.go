@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	ErrCircuitOpen = Error("circuit breaker is open")
+)
+
+// BreakerState is the state of a CircuitBreaker.
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// CircuitBreaker stops requests to a degraded endpoint after a number of
+// consecutive failures. Once the cooldown has elapsed a single probe request
+// is let through: if it succeeds the breaker closes, otherwise it opens again.
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+	// OnStateChange, if set, is called with the new state on every transition.
+	OnStateChange func(BreakerState)
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+	now      func() time.Time
+}
+
+func (b *CircuitBreaker) clock() time.Time {
+	if b.now != nil {
+		return b.now()
+	}
+	return time.Now()
+}
+
+// Allow reports whether a request may be sent. It returns ErrCircuitOpen
+// while the breaker is open or a probe is already in flight.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.current() {
+	case BreakerOpen:
+		if b.clock().Sub(b.openedAt) < b.Cooldown {
+			return ErrCircuitOpen
+		}
+		b.transition(BreakerHalfOpen)
+		b.probing = true
+		return nil
+	case BreakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Success records a successful request and closes the breaker.
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.probing = false
+	b.transition(BreakerClosed)
+}
+
+// Failure records a failed request, opening the breaker once the threshold is
+// reached or when a probe fails.
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.current() == BreakerHalfOpen || b.failures >= b.Threshold {
+		b.openedAt = b.clock()
+		b.transition(BreakerOpen)
+	}
+}
+
+// Release gives up a request permitted by Allow without recording an outcome,
+// for example because the caller's context was cancelled.
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// State returns the current state of the breaker.
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.current()
+}
+
+func (b *CircuitBreaker) current() BreakerState {
+	if b.state == "" {
+		return BreakerClosed
+	}
+	return b.state
+}
+
+func (b *CircuitBreaker) transition(to BreakerState) {
+	if b.current() == to {
+		return
+	}
+	b.state = to
+	if b.OnStateChange != nil {
+		b.OnStateChange(to)
+	}
+}
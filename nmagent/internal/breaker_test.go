@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Unix(0, 0)
+	var transitions []BreakerState
+	b := &CircuitBreaker{
+		Threshold:     2,
+		Cooldown:      time.Minute,
+		OnStateChange: func(s BreakerState) { transitions = append(transitions, s) },
+		now:           func() time.Time { return now },
+	}
+
+	if got := b.State(); got != BreakerClosed {
+		t.Fatal("expected new breaker to be closed but got", got)
+	}
+
+	// one failure is below the threshold
+	if err := b.Allow(); err != nil {
+		t.Fatal("unexpected error: err:", err)
+	}
+	b.Failure()
+	if err := b.Allow(); err != nil {
+		t.Fatal("unexpected error: err:", err)
+	}
+	b.Failure()
+
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatal("expected open breaker to reject requests but got", err)
+	}
+
+	// after the cooldown a single probe is let through
+	now = now.Add(time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatal("expected probe to be allowed: err:", err)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatal("expected concurrent probe to be rejected but got", err)
+	}
+
+	// a failed probe reopens the breaker
+	b.Failure()
+	if got := b.State(); got != BreakerOpen {
+		t.Fatal("expected failed probe to reopen the breaker but got", got)
+	}
+
+	// a successful probe closes it
+	now = now.Add(time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatal("expected probe to be allowed: err:", err)
+	}
+	b.Success()
+	if got := b.State(); got != BreakerClosed {
+		t.Fatal("expected successful probe to close the breaker but got", got)
+	}
+
+	exp := []BreakerState{BreakerOpen, BreakerHalfOpen, BreakerOpen, BreakerHalfOpen, BreakerClosed}
+	if len(transitions) != len(exp) {
+		t.Fatalf("expected transitions %v but got %v", exp, transitions)
+	}
+	for i := range exp {
+		if transitions[i] != exp[i] {
+			t.Fatalf("expected transitions %v but got %v", exp, transitions)
+		}
+	}
+}
+
+func TestCircuitBreakerRelease(t *testing.T) {
+	b := &CircuitBreaker{Threshold: 1, Cooldown: 0}
+	b.Failure()
+
+	if err := b.Allow(); err != nil {
+		t.Fatal("expected probe to be allowed: err:", err)
+	}
+	b.Release()
+	if err := b.Allow(); err != nil {
+		t.Fatal("expected released probe to allow another: err:", err)
+	}
+}
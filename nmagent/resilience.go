@@ -0,0 +1,245 @@
+package nmagent
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/nmagent/internal"
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrCircuitOpen is returned without contacting NMAgent while the circuit
+// breaker for the requested endpoint is open.
+var ErrCircuitOpen = internal.ErrCircuitOpen
+
+// BreakerState is the state of an endpoint's circuit breaker.
+type BreakerState = internal.BreakerState
+
+const (
+	BreakerClosed   = internal.BreakerClosed
+	BreakerOpen     = internal.BreakerOpen
+	BreakerHalfOpen = internal.BreakerHalfOpen
+)
+
+// Endpoint names used to key circuit breakers and reported by BreakerStates.
+const (
+	EndpointJoinNetwork            = "JoinNetwork"
+	EndpointDeleteNetwork          = "DeleteNetwork"
+	EndpointGetNetworkConfig       = "GetNetworkConfiguration"
+	EndpointGetNCVersion           = "GetNCVersion"
+	EndpointPutNetworkContainer    = "PutNetworkContainer"
+	EndpointSupportedAPIs          = "SupportedAPIs"
+	EndpointDeleteNetworkContainer = "DeleteNetworkContainer"
+	EndpointGetNCVersionList       = "GetNCVersionList"
+	EndpointGetHomeAz              = "GetHomeAz"
+	EndpointGetInterfaceIPInfo     = "GetInterfaceIPInfo"
+)
+
+const (
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerCooldown         = 30 * time.Second
+	defaultCacheTTL                = 2 * time.Second
+	defaultSharedCallTimeout       = 30 * time.Second
+)
+
+type cacheEntry struct {
+	value   any
+	expires time.Time
+}
+
+// resilience is shared by all callers of a Client. It keeps a circuit breaker
+// per endpoint, coalesces identical in-flight requests and caches the
+// responses of idempotent GETs for a short time.
+type resilience struct {
+	threshold     int
+	cooldown      time.Duration
+	cacheTTL      time.Duration
+	callTimeout   time.Duration
+	onStateChange func(endpoint string, state BreakerState)
+	now           func() time.Time
+
+	group singleflight.Group
+
+	mu       sync.Mutex
+	breakers map[string]*internal.CircuitBreaker
+	cache    map[string]cacheEntry
+}
+
+func newResilience(c Config) *resilience {
+	r := &resilience{
+		threshold:     c.BreakerFailureThreshold,
+		cooldown:      c.BreakerCooldown,
+		cacheTTL:      c.CacheTTL,
+		callTimeout:   c.SharedCallTimeout,
+		onStateChange: c.OnBreakerStateChange,
+		now:           time.Now,
+		breakers:      map[string]*internal.CircuitBreaker{},
+		cache:         map[string]cacheEntry{},
+	}
+	if r.threshold <= 0 {
+		r.threshold = defaultBreakerFailureThreshold
+	}
+	if r.cooldown <= 0 {
+		r.cooldown = defaultBreakerCooldown
+	}
+	if r.cacheTTL == 0 {
+		r.cacheTTL = defaultCacheTTL
+	}
+	if r.callTimeout <= 0 {
+		r.callTimeout = defaultSharedCallTimeout
+	}
+	return r
+}
+
+func (r *resilience) breaker(endpoint string) *internal.CircuitBreaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.breakers[endpoint]
+	if !ok {
+		b = &internal.CircuitBreaker{
+			Threshold: r.threshold,
+			Cooldown:  r.cooldown,
+		}
+		if r.onStateChange != nil {
+			b.OnStateChange = func(state BreakerState) {
+				r.onStateChange(endpoint, state)
+			}
+		}
+		r.breakers[endpoint] = b
+	}
+	return b
+}
+
+func (r *resilience) cached(endpoint string) (any, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.cache[endpoint]
+	if !ok || r.now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+func (r *resilience) store(endpoint string, value any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cache[endpoint] = cacheEntry{value: value, expires: r.now().Add(r.cacheTTL)}
+}
+
+// states returns a snapshot of the state of every breaker created so far.
+func (r *resilience) states() map[string]BreakerState {
+	r.mu.Lock()
+	breakers := make(map[string]*internal.CircuitBreaker, len(r.breakers))
+	for endpoint, b := range r.breakers {
+		breakers[endpoint] = b
+	}
+	r.mu.Unlock()
+
+	out := make(map[string]BreakerState, len(breakers))
+	for endpoint, b := range breakers {
+		out[endpoint] = b.State()
+	}
+	return out
+}
+
+// guard runs fn behind the endpoint's circuit breaker.
+func (r *resilience) guard(ctx context.Context, endpoint string, fn func() error) error {
+	b := r.breaker(endpoint)
+	if err := b.Allow(); err != nil {
+		return err //nolint:wrapcheck // callers match on ErrCircuitOpen
+	}
+
+	err := fn()
+	switch {
+	case err == nil || !isEndpointFailure(err):
+		b.Success()
+	case ctx.Err() != nil:
+		// the caller gave up, which says nothing about NMAgent's health
+		b.Release()
+	default:
+		b.Failure()
+	}
+	return err
+}
+
+// isEndpointFailure reports whether err indicates that NMAgent or the
+// wireserver is degraded, as opposed to a rejected request.
+func isEndpointFailure(err error) bool {
+	var nmaErr Error
+	if errors.As(err, &nmaErr) {
+		return nmaErr.Code >= http.StatusInternalServerError
+	}
+	return true
+}
+
+// guarded runs fn behind the endpoint's circuit breaker. When the client was
+// built without resilience (as in tests) fn is called directly.
+func guarded(ctx context.Context, c *Client, endpoint string, fn func() error) error {
+	if c.resilience == nil {
+		return fn()
+	}
+	return c.resilience.guard(ctx, endpoint, fn)
+}
+
+// shared coalesces identical in-flight requests to the endpoint and, when
+// cache is set, serves the response from a short-lived cache. It runs behind
+// the endpoint's circuit breaker.
+//
+// The coalesced request is not bound to the caller which happened to start it:
+// it runs on a context which keeps the values of the caller's but is only
+// cancelled by the shared call timeout, and every caller stops waiting for it
+// when its own context is done.
+func shared[T any](ctx context.Context, c *Client, endpoint string, cache bool, fn func(context.Context) (T, error)) (T, error) {
+	if c.resilience == nil {
+		return fn(ctx)
+	}
+
+	r := c.resilience
+	if cache && r.cacheTTL > 0 {
+		if v, ok := r.cached(endpoint); ok {
+			return v.(T), nil
+		}
+	}
+
+	ch := r.group.DoChan(endpoint, func() (any, error) {
+		// a timeout of the shared call is NMAgent being slow, which the breaker
+		// should count, so it guards on the context without the timeout
+		sharedCtx := context.WithoutCancel(ctx)
+		callCtx, cancel := context.WithTimeout(sharedCtx, r.callTimeout)
+		defer cancel()
+
+		var out T
+		err := r.guard(sharedCtx, endpoint, func() error {
+			var err error
+			out, err = fn(callCtx)
+			return err
+		})
+		if err == nil && cache && r.cacheTTL > 0 {
+			r.store(endpoint, out)
+		}
+		return out, err
+	})
+
+	select {
+	case res := <-ch:
+		return res.Val.(T), res.Err //nolint:wrapcheck // errors are already wrapped by fn
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err() //nolint:wrapcheck // callers match on the context error
+	}
+}
+
+// BreakerStates returns the state of the circuit breaker of every endpoint
+// that has been called.
+func (c *Client) BreakerStates() map[string]BreakerState {
+	if c.resilience == nil {
+		return map[string]BreakerState{}
+	}
+	return c.resilience.states()
+}
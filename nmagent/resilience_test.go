@@ -0,0 +1,199 @@
+package nmagent_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/nmagent"
+)
+
+func homeAzTripper(calls *int32, status string, block <-chan struct{}) *TestTripper {
+	return &TestTripper{
+		RoundTripF: func(_ *http.Request) (*http.Response, error) {
+			atomic.AddInt32(calls, 1)
+			if block != nil {
+				<-block
+			}
+			rr := httptest.NewRecorder()
+			_ = json.NewEncoder(rr).Encode(map[string]interface{}{
+				"httpStatusCode": status,
+				"HomeAz":         1,
+			})
+			rr.WriteHeader(http.StatusOK)
+			return rr.Result(), nil
+		},
+	}
+}
+
+func TestResilienceCachesIdempotentGets(t *testing.T) {
+	var calls int32
+	client := nmagent.NewTestClientWithResilience(homeAzTripper(&calls, "200", nil), nmagent.Config{CacheTTL: time.Minute})
+
+	for i := 0; i < 3; i++ {
+		got, err := client.GetHomeAz(context.Background())
+		if err != nil {
+			t.Fatal("unexpected error: err:", err)
+		}
+		if got.HomeAz != 1 {
+			t.Fatal("unexpected home az:", got.HomeAz)
+		}
+	}
+
+	if calls != 1 {
+		t.Fatal("expected one request to nmagent but got", calls)
+	}
+}
+
+func TestResilienceCoalescesInflightRequests(t *testing.T) {
+	var calls int32
+	block := make(chan struct{})
+	client := nmagent.NewTestClientWithResilience(homeAzTripper(&calls, "200", block), nmagent.Config{CacheTTL: -1})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.GetHomeAz(context.Background()); err != nil {
+				t.Error("unexpected error: err:", err)
+			}
+		}()
+	}
+
+	// wait for the first request to reach the transport, give the others time to join it
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(block)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatal("expected concurrent requests to be coalesced into one but got", calls)
+	}
+}
+
+func TestResilienceOpensBreaker(t *testing.T) {
+	var calls int32
+	var transitions []nmagent.BreakerState
+	client := nmagent.NewTestClientWithResilience(homeAzTripper(&calls, "500", nil), nmagent.Config{
+		BreakerFailureThreshold: 2,
+		BreakerCooldown:         time.Hour,
+		OnBreakerStateChange: func(endpoint string, state nmagent.BreakerState) {
+			if endpoint != nmagent.EndpointGetHomeAz {
+				t.Error("unexpected endpoint:", endpoint)
+			}
+			transitions = append(transitions, state)
+		},
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetHomeAz(context.Background()); err == nil {
+			t.Fatal("expected error but received none")
+		}
+	}
+
+	_, err := client.GetHomeAz(context.Background())
+	if !errors.Is(err, nmagent.ErrCircuitOpen) {
+		t.Fatal("expected open circuit error but got", err)
+	}
+	if calls != 2 {
+		t.Fatal("expected open breaker to stop requests to nmagent but got", calls)
+	}
+
+	states := client.BreakerStates()
+	if states[nmagent.EndpointGetHomeAz] != nmagent.BreakerOpen {
+		t.Fatal("expected GetHomeAz breaker to be open but got", states)
+	}
+	if len(transitions) != 1 || transitions[0] != nmagent.BreakerOpen {
+		t.Fatal("expected a single transition to open but got", transitions)
+	}
+}
+
+func TestResilienceIgnoresClientErrors(t *testing.T) {
+	var calls int32
+	client := nmagent.NewTestClientWithResilience(homeAzTripper(&calls, "404", nil), nmagent.Config{BreakerFailureThreshold: 1})
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.GetHomeAz(context.Background()); errors.Is(err, nmagent.ErrCircuitOpen) {
+			t.Fatal("4xx responses must not open the breaker")
+		}
+	}
+	if calls != 3 {
+		t.Fatal("expected every request to reach nmagent but got", calls)
+	}
+}
+
+func TestResilienceCoalescedRequestOutlivesItsLeader(t *testing.T) {
+	var calls int32
+	block := make(chan struct{})
+	client := nmagent.NewTestClientWithResilience(homeAzTripper(&calls, "200", block), nmagent.Config{CacheTTL: -1})
+
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := client.GetHomeAz(leaderCtx)
+		leaderErr <- err
+	}()
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	followerErr := make(chan error, 1)
+	go func() {
+		_, err := client.GetHomeAz(context.Background())
+		followerErr <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// the leader gives up, which must return it right away and leave the request running for the follower
+	cancelLeader()
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Fatal("expected the leader to return its context error but got", err)
+	}
+	close(block)
+	if err := <-followerErr; err != nil {
+		t.Fatal("expected the follower to get the response but got", err)
+	}
+	if calls != 1 {
+		t.Fatal("expected one request to nmagent but got", calls)
+	}
+}
+
+func TestResilienceSharedCallTimeout(t *testing.T) {
+	var calls int32
+	block := make(chan struct{})
+	defer close(block)
+	tripper := &TestTripper{
+		RoundTripF: func(req *http.Request) (*http.Response, error) {
+			atomic.AddInt32(&calls, 1)
+			select {
+			case <-block:
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			}
+			return nil, errors.New("unreachable")
+		},
+	}
+	client := nmagent.NewTestClientWithResilience(tripper, nmagent.Config{
+		CacheTTL:                -1,
+		SharedCallTimeout:       20 * time.Millisecond,
+		BreakerFailureThreshold: 1,
+		BreakerCooldown:         time.Hour,
+	})
+
+	_, err := client.GetHomeAz(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("expected the shared call to time out but got", err)
+	}
+	if state := client.BreakerStates()[nmagent.EndpointGetHomeAz]; state != nmagent.BreakerOpen {
+		t.Fatal("expected a timed out call to count against the breaker but it is", state)
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	npmconfig "github.com/Azure/azure-container-networking/npm/config"
+	"github.com/Azure/azure-container-networking/npm/http/api"
+	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/common"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/debug"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+var (
+	errUnknownOutputFormat = errors.New("output format must be text or json")
+	errUnexpectedCacheType = errors.New("unexpected NPM cache type")
+)
+
+func newAnalyzePoliciesCmd() *cobra.Command {
+	analyzePoliciesCmd := &cobra.Command{
+		Use:   "analyzepolicies",
+		Short: "Report pods selected by no policy, unused policies, shadowed rules and ipBlocks overlapping pod or service CIDRs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			npmCacheF, _ := cmd.Flags().GetString("cache-file")
+			policiesF, _ := cmd.Flags().GetString("policies-file")
+			kubeConfigPath, _ := cmd.Flags().GetString(flagKubeConfigPath)
+			output, _ := cmd.Flags().GetString("output")
+			if output != "text" && output != "json" {
+				return errUnknownOutputFormat
+			}
+			podCIDRs, err := parseCIDRFlag(cmd, "pod-cidr")
+			if err != nil {
+				return err
+			}
+			serviceCIDRs, err := parseCIDRFlag(cmd, "service-cidr")
+			if err != nil {
+				return err
+			}
+
+			config := &npmconfig.Config{}
+			if err := viper.Unmarshal(config); err != nil {
+				return fmt.Errorf("failed to load config with err %w", err)
+			}
+
+			c := &debug.Converter{
+				NPMDebugEndpointHost: "http://localhost",
+				NPMDebugEndpointPort: api.DefaultHttpPort,
+				EnableV2NPM:          config.Toggles.EnableV2NPM,
+			}
+			if npmCacheF == "" {
+				err = c.NpmCache()
+			} else {
+				err = c.NpmCacheFromFile(npmCacheF)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to get NPM cache: %w", err)
+			}
+			cache, ok := c.NPMCache.(*common.Cache)
+			if !ok {
+				return fmt.Errorf("%w: %T", errUnexpectedCacheType, c.NPMCache)
+			}
+
+			var netpols []*networkingv1.NetworkPolicy
+			if policiesF == "" {
+				netpols, err = listNetworkPolicies(cmd.Context(), kubeConfigPath)
+			} else {
+				netpols, err = readNetworkPolicies(policiesF)
+			}
+			if err != nil {
+				return err
+			}
+
+			analysis := debug.AnalyzePolicies(cache, netpols, podCIDRs, serviceCIDRs)
+			return debug.PrintPolicyAnalysis(os.Stdout, analysis, output == "json")
+		},
+	}
+
+	analyzePoliciesCmd.Flags().StringP("cache-file", "c", "", "Set the NPM cache file path (optional, the cache of the local NPM is used by default)")
+	analyzePoliciesCmd.Flags().StringP("policies-file", "p", "", "Set the path of a YAML or JSON file of NetworkPolicies (optional, the policies of the cluster are used by default)")
+	analyzePoliciesCmd.Flags().String(flagKubeConfigPath, flagDefaults[flagKubeConfigPath], "path to kubeconfig, used to list the policies of the cluster")
+	analyzePoliciesCmd.Flags().StringSlice("pod-cidr", nil, "pod CIDRs to check ipBlocks against")
+	analyzePoliciesCmd.Flags().StringSlice("service-cidr", nil, "service CIDRs to check ipBlocks against")
+	analyzePoliciesCmd.Flags().StringP("output", "o", "text", "output format, text or json")
+
+	return analyzePoliciesCmd
+}
+
+func parseCIDRFlag(cmd *cobra.Command, name string) ([]*net.IPNet, error) {
+	values, _ := cmd.Flags().GetStringSlice(name)
+	cidrs := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		_, cidr, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s %s: %w", name, value, err)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return cidrs, nil
+}
+
+// listNetworkPolicies lists the NetworkPolicies of all namespaces, with the in cluster config if no kubeconfig is passed.
+func listNetworkPolicies(ctx context.Context, kubeConfigPath string) ([]*networkingv1.NetworkPolicy, error) {
+	var k8sConfig *rest.Config
+	var err error
+	if kubeConfigPath == "" {
+		k8sConfig, err = rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load in cluster config: %w", err)
+		}
+	} else {
+		k8sConfig, err = clientcmd.BuildConfigFromFlags("", kubeConfigPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load kubeconfig [%s] with err config: %w", kubeConfigPath, err)
+		}
+	}
+	clientset, err := kubernetes.NewForConfig(k8sConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate clientset with cluster config: %w", err)
+	}
+	list, err := clientset.NetworkingV1().NetworkPolicies(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network policies: %w", err)
+	}
+	netpols := make([]*networkingv1.NetworkPolicy, 0, len(list.Items))
+	for i := range list.Items {
+		netpols = append(netpols, &list.Items[i])
+	}
+	return netpols, nil
+}
+
+// readNetworkPolicies reads the NetworkPolicies of a file of YAML documents or JSON objects, each a NetworkPolicy or a
+// list of them, as written by kubectl get -o yaml.
+func readNetworkPolicies(path string) ([]*networkingv1.NetworkPolicy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policies file: %w", err)
+	}
+	var netpols []*networkingv1.NetworkPolicy
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(b), 4096) //nolint:gomnd // buffer size
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				return netpols, nil
+			}
+			return nil, fmt.Errorf("failed to decode policies file: %w", err)
+		}
+		decoded, err := decodeNetworkPolicies(raw)
+		if err != nil {
+			return nil, err
+		}
+		netpols = append(netpols, decoded...)
+	}
+}
+
+func decodeNetworkPolicies(raw json.RawMessage) ([]*networkingv1.NetworkPolicy, error) {
+	if len(bytes.TrimSpace(raw)) == 0 || bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		return nil, nil
+	}
+	var meta metav1.TypeMeta
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return nil, fmt.Errorf("failed to decode policies file: %w", err)
+	}
+	switch meta.Kind {
+	case "List", "NetworkPolicyList":
+		var list struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", meta.Kind, err)
+		}
+		var netpols []*networkingv1.NetworkPolicy
+		for _, item := range list.Items {
+			decoded, err := decodeNetworkPolicies(item)
+			if err != nil {
+				return nil, err
+			}
+			netpols = append(netpols, decoded...)
+		}
+		return netpols, nil
+	case "NetworkPolicy":
+		netpol := &networkingv1.NetworkPolicy{}
+		if err := json.Unmarshal(raw, netpol); err != nil {
+			return nil, fmt.Errorf("failed to decode NetworkPolicy: %w", err)
+		}
+		if netpol.Namespace == "" {
+			netpol.Namespace = metav1.NamespaceDefault
+		}
+		return []*networkingv1.NetworkPolicy{netpol}, nil
+	default:
+		// other objects, like the pods of a manifest, are skipped
+		return nil, nil
+	}
+}
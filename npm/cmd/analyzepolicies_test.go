@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestAnalyzePoliciesCmd(t *testing.T) {
+	baseArgs := []string{debugCmdString, analyzePoliciesCmdString, npmCacheFlag, npmCacheFile}
+
+	tests := []*testCases{
+		{
+			name:    "policies file",
+			args:    concatArgs(baseArgs, policiesFileFlag, policiesFile),
+			wantErr: false,
+		},
+		{
+			name:    "json output with cidrs",
+			args:    concatArgs(baseArgs, policiesFileFlag, policiesFile, "-o", "json", "--pod-cidr", "10.224.0.0/16", "--service-cidr", "10.0.0.0/16"),
+			wantErr: false,
+		},
+		{
+			name:    "unknown output format",
+			args:    concatArgs(baseArgs, policiesFileFlag, policiesFile, "-o", "yaml"),
+			wantErr: true,
+		},
+		{
+			name:    "bad cidr",
+			args:    concatArgs(baseArgs, policiesFileFlag, policiesFile, "--pod-cidr", "10.224.0.0"),
+			wantErr: true,
+		},
+		{
+			name:    "bad cache file",
+			args:    []string{debugCmdString, analyzePoliciesCmdString, npmCacheFlag, nonExistingFile, policiesFileFlag, policiesFile},
+			wantErr: true,
+		},
+		{
+			name:    "bad policies file",
+			args:    concatArgs(baseArgs, policiesFileFlag, nonExistingFile),
+			wantErr: true,
+		},
+	}
+
+	testCommand(t, tests)
+}
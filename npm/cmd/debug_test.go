@@ -14,9 +14,11 @@ const (
 	iptableSaveFile = "../pkg/dataplane/testdata/iptablesave-v1"
 	npmCacheFile    = "../pkg/dataplane/testdata/npmcachev1.json"
 	nonExistingFile = "non-existing-iptables-file"
+	policiesFile    = "../pkg/dataplane/testdata/netpol.yaml"
 
 	npmCacheFlag         = "-c"
 	iptablesSaveFileFlag = "-i"
+	policiesFileFlag     = "-p"
 	dstFlag              = "-d"
 	srcFlag              = "-s"
 	unknownShorthandFlag = "-z"
@@ -24,10 +26,11 @@ const (
 	testIP1 = "10.224.0.87" // from npmCacheWithCustomFormat.json
 	testIP2 = "10.224.0.20" // ditto
 
-	debugCmdString          = "debug"
-	convertIPTableCmdString = "convertiptable"
-	getTuplesCmdString      = "gettuples"
-	parseIPTableCmdString   = "parseiptable"
+	debugCmdString           = "debug"
+	convertIPTableCmdString  = "convertiptable"
+	getTuplesCmdString       = "gettuples"
+	parseIPTableCmdString    = "parseiptable"
+	analyzePoliciesCmdString = "analyzepolicies"
 )
 
 type testCases struct {
@@ -5,6 +5,10 @@ package main
 import (
 	"fmt"
 	"math/rand"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/Azure/azure-container-networking/common"
@@ -177,6 +181,13 @@ func start(config npmconfig.Config, flags npmconfig.Flags) error {
 			npmV2DataplaneCfg.ApplyInterval = time.Duration(npmconfig.DefaultConfig.ApplyIntervalInMilliseconds * int(time.Millisecond))
 		}
 
+		npmV2DataplaneCfg.PolicyHitsInterval = time.Duration(config.PolicyHitsIntervalInSeconds) * time.Second
+		if config.MaxPolicyHitSeries > 0 {
+			npmV2DataplaneCfg.MaxPolicyHitSeries = config.MaxPolicyHitSeries
+		} else {
+			npmV2DataplaneCfg.MaxPolicyHitSeries = npmconfig.DefaultConfig.MaxPolicyHitSeries
+		}
+
 		if config.WindowsNetworkName == "" {
 			npmV2DataplaneCfg.NetworkName = util.AzureNetworkName
 		} else {
@@ -184,6 +195,7 @@ func start(config npmconfig.Config, flags npmconfig.Flags) error {
 		}
 
 		npmV2DataplaneCfg.PlaceAzureChainFirst = config.Toggles.PlaceAzureChainFirst
+		npmV2DataplaneCfg.GracefulHandover = config.Toggles.EnableGracefulHandover
 		if config.Toggles.ApplyIPSetsOnNeed {
 			npmV2DataplaneCfg.IPSetMode = ipsets.ApplyOnNeed
 		} else {
@@ -201,7 +213,17 @@ func start(config npmconfig.Config, flags npmconfig.Flags) error {
 		}
 		npmV2DataplaneCfg.NodeIP = nodeIP
 
-		dp, err = dataplane.NewDataPlane(models.GetNodeName(), common.NewIOShim(), npmV2DataplaneCfg, stopChannel)
+		if config.Toggles.EnableDryRun {
+			var closeDryRunOutput func()
+			dp, closeDryRunOutput, err = newDryRunDataPlane(config.DryRunOutputPath, stopChannel)
+			if err == nil {
+				// start only returns on errors, once NPM runs the output is closed when the pod is stopped
+				defer closeDryRunOutput()
+				go closeOnTermination(closeDryRunOutput)
+			}
+		} else {
+			dp, err = dataplane.NewDataPlane(models.GetNodeName(), common.NewIOShim(), npmV2DataplaneCfg, stopChannel)
+		}
 		if err != nil {
 			metrics.SendErrorLogAndMetric(util.NpmID, "error: failed to create dataplane with error %v", err)
 			return fmt.Errorf("failed to create dataplane with error %w", err)
@@ -223,6 +245,45 @@ func start(config npmconfig.Config, flags npmconfig.Flags) error {
 	select {}
 }
 
+// newDryRunDataPlane creates a dataplane which writes the changes it would make to outputPath, or stdout if it is empty.
+// The returned func flushes and closes the output file once, and must be called when the dataplane is no longer used.
+func newDryRunDataPlane(outputPath string, stopChannel <-chan struct{}) (dataplane.GenericDataplane, func(), error) {
+	out := os.Stdout
+	closeOutput := func() {}
+	if outputPath != "" {
+		f, err := os.OpenFile(outputPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open dry-run output file %s: %w", outputPath, err)
+		}
+		out = f
+		closeOutput = sync.OnceFunc(func() {
+			if err := f.Sync(); err != nil {
+				klog.Errorf("failed to flush dry-run output file %s: %v", outputPath, err)
+			}
+			if err := f.Close(); err != nil {
+				klog.Errorf("failed to close dry-run output file %s: %v", outputPath, err)
+			}
+		})
+	}
+	metrics.SendLog(util.NpmID, "starting NPM dataplane in dry-run mode. no changes will be applied", metrics.PrintLog)
+	dp, err := dataplane.NewDryRunDataPlane(models.GetNodeName(), common.NewIOShim(), npmV2DataplaneCfg, stopChannel, out)
+	if err != nil {
+		closeOutput()
+		return nil, nil, err
+	}
+	return dp, closeOutput, nil
+}
+
+// closeOnTermination waits for the process to be asked to terminate, calls closeFn and exits
+func closeOnTermination(closeFn func()) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+	sig := <-sigs
+	klog.Infof("received %s, closing the dry-run output", sig)
+	closeFn()
+	os.Exit(0)
+}
+
 func initLogging() error {
 	log.SetName("azure-npm")
 	log.SetLevel(log.LevelInfo)
@@ -9,6 +9,7 @@ const (
 	defaultMaxBatchedACLsPerPod = 30
 	defaultMaxPendingNetPols    = 100
 	defaultNetPolInterval       = 500
+	defaultMaxPolicyHitSeries   = 1000
 	defaultListeningPort        = 10091
 	defaultGrpcPort             = 10092
 	defaultGrpcServicePort      = 9002
@@ -40,6 +41,8 @@ var DefaultConfig = Config{
 	MaxPendingNetPols:            defaultMaxPendingNetPols,
 	NetPolInvervalInMilliseconds: defaultNetPolInterval,
 
+	MaxPolicyHitSeries: defaultMaxPolicyHitSeries,
+
 	Toggles: Toggles{
 		EnablePrometheusMetrics: true,
 		EnablePprof:             true,
@@ -86,6 +89,14 @@ type Config struct {
 	NetPolInvervalInMilliseconds int     `json:"NetPolInvervalInMilliseconds,omitempty"`
 	Toggles                      Toggles `json:"Toggles,omitempty"`
 	LogLevel                     string  `json:"LogLevel,omitempty"`
+	// PolicyHitsIntervalInSeconds is how often NPM exports the packets and bytes matched by the iptables rules of each policy.
+	// Zero disables the export. Linux only.
+	PolicyHitsIntervalInSeconds int `json:"PolicyHitsIntervalInSeconds,omitempty"`
+	// MaxPolicyHitSeries bounds the number of series of each policy hit metric. Hits beyond it are exported as an overflow series.
+	MaxPolicyHitSeries int `json:"MaxPolicyHitSeries,omitempty"`
+	// DryRunOutputPath is the file the changes NPM would make are written to when EnableDryRun is true.
+	// The empty string writes them to stdout.
+	DryRunOutputPath string `json:"DryRunOutputPath,omitempty"`
 }
 
 type Toggles struct {
@@ -100,6 +111,11 @@ type Toggles struct {
 	// NetPolInBackground
 	NetPolInBackground bool
 	EnableNPMLite      bool
+	// EnableDryRun makes NPM v2 compute the iptables and ipset changes it would make without applying them. Linux only.
+	EnableDryRun bool
+	// EnableGracefulHandover makes NPM v2 build its iptables chains and ipsets next to those of the previous NPM (v1 or v2),
+	// and swap the jump from the FORWARD chain once the controllers have synced, so policies are enforced throughout an upgrade. Linux only.
+	EnableGracefulHandover bool
 }
 
 type Flags struct {
@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	policyHitPacketsName = "policy_hit_packets_total"
+	policyHitPacketsHelp = "Number of packets matched by the iptables rules of a network policy by direction and verdict"
+	policyHitBytesName   = "policy_hit_bytes_total"
+	policyHitBytesHelp   = "Number of bytes matched by the iptables rules of a network policy by direction and verdict"
+
+	namespaceLabel = "namespace"
+	policyLabel    = "policy"
+	directionLabel = "direction"
+	verdictLabel   = "verdict"
+
+	// OverflowLabelValue is the namespace and policy of the hits of policies beyond the series limit.
+	OverflowLabelValue = "__overflow__"
+	// DefaultMaxPolicyHitSeries is the default limit on the number of series of each policy hit metric.
+	DefaultMaxPolicyHitSeries = 1000
+)
+
+// linux metrics for policy hits
+var (
+	policyHitPackets *prometheus.CounterVec
+	policyHitBytes   *prometheus.CounterVec
+	policyHitLabels  = []string{namespaceLabel, policyLabel, directionLabel, verdictLabel}
+	policyHitSeries  = &seriesLimiter{series: make(map[policyHitSeriesKey]struct{}), max: DefaultMaxPolicyHitSeries}
+)
+
+type policyHitSeriesKey struct {
+	namespace, policy, direction, verdict string
+}
+
+// seriesLimiter bounds the cardinality of the policy hit metrics.
+// Once the limit is reached, the hits of new series are added to the overflow series of their direction and verdict.
+type seriesLimiter struct {
+	sync.Mutex
+	series     map[policyHitSeriesKey]struct{}
+	max        int
+	overflowed bool
+}
+
+// SetMaxPolicyHitSeries sets the limit on the number of series of each policy hit metric.
+// Series already exported are kept.
+func SetMaxPolicyHitSeries(max int) {
+	policyHitSeries.Lock()
+	defer policyHitSeries.Unlock()
+	policyHitSeries.max = max
+}
+
+// AddPolicyHits adds packets and bytes matched by the rules of a policy to the policy hit metrics.
+func AddPolicyHits(namespace, policy, direction, verdict string, packets, bytes uint64) {
+	key := policyHitSeries.admit(policyHitSeriesKey{namespace: namespace, policy: policy, direction: direction, verdict: verdict})
+	labels := prometheus.Labels{
+		namespaceLabel: key.namespace,
+		policyLabel:    key.policy,
+		directionLabel: key.direction,
+		verdictLabel:   key.verdict,
+	}
+	policyHitPackets.With(labels).Add(float64(packets))
+	policyHitBytes.With(labels).Add(float64(bytes))
+}
+
+// DeletePolicyHits deletes the series of a policy from the policy hit metrics, e.g. after the policy is removed.
+func DeletePolicyHits(namespace, policy string) {
+	policyHitSeries.Lock()
+	for key := range policyHitSeries.series {
+		if key.namespace == namespace && key.policy == policy {
+			delete(policyHitSeries.series, key)
+		}
+	}
+	policyHitSeries.Unlock()
+
+	labels := prometheus.Labels{namespaceLabel: namespace, policyLabel: policy}
+	policyHitPackets.DeletePartialMatch(labels)
+	policyHitBytes.DeletePartialMatch(labels)
+}
+
+func (l *seriesLimiter) reset() {
+	l.Lock()
+	defer l.Unlock()
+	l.series = make(map[policyHitSeriesKey]struct{})
+	l.overflowed = false
+}
+
+// admit returns the key itself if it is exported or there is room for it, and its overflow series otherwise.
+func (l *seriesLimiter) admit(key policyHitSeriesKey) policyHitSeriesKey {
+	l.Lock()
+	defer l.Unlock()
+	if _, ok := l.series[key]; ok {
+		return key
+	}
+	if len(l.series) < l.max {
+		l.series[key] = struct{}{}
+		return key
+	}
+	if !l.overflowed {
+		l.overflowed = true
+		SendLog(util.IptmID, fmt.Sprintf("policy hit series limit of %d reached. hits of new policies are exported with namespace and policy %s", l.max, OverflowLabelValue), PrintLog)
+	}
+	return policyHitSeriesKey{namespace: OverflowLabelValue, policy: OverflowLabelValue, direction: key.direction, verdict: key.verdict}
+}
+
+// GetPolicyHitPackets returns the number of packets counted for the series.
+// This function is slow.
+func GetPolicyHitPackets(namespace, policy, direction, verdict string) (int, error) {
+	return counterValue(policyHitPackets.With(prometheus.Labels{
+		namespaceLabel: namespace,
+		policyLabel:    policy,
+		directionLabel: direction,
+		verdictLabel:   verdict,
+	}))
+}
+
+// GetPolicyHitBytes returns the number of bytes counted for the series.
+// This function is slow.
+func GetPolicyHitBytes(namespace, policy, direction, verdict string) (int, error) {
+	return counterValue(policyHitBytes.With(prometheus.Labels{
+		namespaceLabel: namespace,
+		policyLabel:    policy,
+		directionLabel: direction,
+		verdictLabel:   verdict,
+	}))
+}
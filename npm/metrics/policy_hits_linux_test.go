@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddPolicyHits(t *testing.T) {
+	InitializeLinuxMetrics()
+
+	AddPolicyHits("x", "deny-all", "ingress", "dropped", 3, 180)
+	AddPolicyHits("x", "deny-all", "ingress", "dropped", 2, 120)
+
+	packets, err := GetPolicyHitPackets("x", "deny-all", "ingress", "dropped")
+	require.NoError(t, err)
+	require.Equal(t, 5, packets)
+	bytes, err := GetPolicyHitBytes("x", "deny-all", "ingress", "dropped")
+	require.NoError(t, err)
+	require.Equal(t, 300, bytes)
+
+	DeletePolicyHits("x", "deny-all")
+	packets, err = GetPolicyHitPackets("x", "deny-all", "ingress", "dropped")
+	require.NoError(t, err)
+	require.Equal(t, 0, packets, "series should restart after being deleted")
+}
+
+func TestPolicyHitSeriesLimit(t *testing.T) {
+	InitializeLinuxMetrics()
+	SetMaxPolicyHitSeries(2)
+	defer SetMaxPolicyHitSeries(DefaultMaxPolicyHitSeries)
+
+	AddPolicyHits("x", "a", "ingress", "allowed", 1, 10)
+	AddPolicyHits("x", "b", "ingress", "allowed", 1, 10)
+	// beyond the limit
+	AddPolicyHits("x", "c", "ingress", "allowed", 1, 10)
+	AddPolicyHits("y", "d", "ingress", "allowed", 2, 20)
+	// exported series keep being updated
+	AddPolicyHits("x", "a", "ingress", "allowed", 1, 10)
+
+	packets, err := GetPolicyHitPackets("x", "a", "ingress", "allowed")
+	require.NoError(t, err)
+	require.Equal(t, 2, packets)
+	packets, err = GetPolicyHitPackets(OverflowLabelValue, OverflowLabelValue, "ingress", "allowed")
+	require.NoError(t, err)
+	require.Equal(t, 3, packets)
+
+	// deleting a policy makes room for another
+	DeletePolicyHits("x", "b")
+	AddPolicyHits("x", "c", "ingress", "allowed", 4, 40)
+	packets, err = GetPolicyHitPackets("x", "c", "ingress", "allowed")
+	require.NoError(t, err)
+	require.Equal(t, 4, packets)
+}
@@ -196,6 +196,8 @@ func InitializeAll() {
 		register(itpablesRestoreLatency, "iptables_restore_latency_seconds", NodeMetrics)
 		register(iptablesDeleteLatency, "iptables_delete_latency_seconds", NodeMetrics)
 		register(iptablesRestoreFailures, "iptables_restore_failure_total", NodeMetrics)
+		register(policyHitPackets, policyHitPacketsName, NodeMetrics)
+		register(policyHitBytes, policyHitBytesName, NodeMetrics)
 	}
 
 	log.Logf("Finished initializing all Prometheus metrics")
@@ -354,6 +356,27 @@ func InitializeLinuxMetrics() {
 		},
 		[]string{operationLabel},
 	)
+
+	policyHitPackets = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      policyHitPacketsName,
+			Subsystem: linuxPrefix,
+			Help:      policyHitPacketsHelp,
+		},
+		policyHitLabels,
+	)
+
+	policyHitBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      policyHitBytesName,
+			Subsystem: linuxPrefix,
+			Help:      policyHitBytesHelp,
+		},
+		policyHitLabels,
+	)
+	policyHitSeries.reset()
 }
 
 // GetHandler returns the HTTP handler for the metrics endpoint
@@ -9,6 +9,7 @@ import (
 
 	npmconfig "github.com/Azure/azure-container-networking/npm/config"
 	"github.com/Azure/azure-container-networking/npm/ipsm"
+	"github.com/Azure/azure-container-networking/npm/metrics"
 	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/common"
 	controllersv1 "github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/v1"
 	controllersv2 "github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/v2"
@@ -30,6 +31,14 @@ var aiMetadata string //nolint // aiMetadata is set in Makefile
 // So with a 3 minute wait, the dataplane can process about 600 (6*maxBatches) NetworkPolicies before starting the Pod controller
 var waitDurationAfterStartingNetPolController = 3 * time.Minute
 
+// With EnableGracefulHandover, the controllers have processed the initial state of the cluster once their queues have been empty
+// for handoverSettledPolls polls in a row, or once maxWaitBeforeHandover has passed.
+var (
+	handoverPollInterval  = 5 * time.Second
+	handoverSettledPolls  = 3
+	maxWaitBeforeHandover = 10 * time.Minute
+)
+
 // NetworkPolicyManager contains informers for pod, namespace and networkpolicy.
 type NetworkPolicyManager struct {
 	config npmconfig.Config
@@ -224,6 +233,10 @@ func (npMgr *NetworkPolicyManager) Start(config npmconfig.Config, stopCh <-chan
 		go npMgr.PodControllerV2.Run(stopCh)
 		go npMgr.NamespaceControllerV2.Run(stopCh)
 
+		if config.Toggles.EnableGracefulHandover {
+			go npMgr.completeHandoverAfterInitialSync(stopCh)
+		}
+
 		return nil
 	}
 
@@ -236,6 +249,38 @@ func (npMgr *NetworkPolicyManager) Start(config npmconfig.Config, stopCh <-chan
 	return nil
 }
 
+// completeHandoverAfterInitialSync takes over the dataplane from the previous NPM once this NPM enforces the same policies,
+// retrying until it succeeds. Until then, the previous NPM's chains and ipsets stay in effect.
+func (npMgr *NetworkPolicyManager) completeHandoverAfterInitialSync(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(handoverPollInterval)
+	defer ticker.Stop()
+	deadline := time.Now().Add(maxWaitBeforeHandover)
+	settledPolls := 0
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		if settledPolls < handoverSettledPolls && time.Now().Before(deadline) {
+			if npMgr.PodControllerV2.LengthOfQueue() == 0 && npMgr.NamespaceControllerV2.LengthOfQueue() == 0 && npMgr.NetPolControllerV2.LengthOfQueue() == 0 {
+				settledPolls++
+			} else {
+				settledPolls = 0
+			}
+			continue
+		}
+
+		if err := npMgr.Dataplane.CompleteHandover(); err != nil {
+			metrics.SendErrorLogAndMetric(util.NpmID, "error: failed to complete handover from the previous NPM. will retry. err: %v", err)
+			continue
+		}
+		metrics.SendLog(util.NpmID, "completed handover from the previous NPM", metrics.PrintLog)
+		return
+	}
+}
+
 // GetAIMetadata returns ai metadata number
 func GetAIMetadata() string {
 	return aiMetadata
@@ -83,6 +83,11 @@ func (n *NamespaceController) GetCache() map[string]*common.Namespace {
 	return n.npmNamespaceCache.GetCache()
 }
 
+// LengthOfQueue returns the number of Namespaces waiting to be processed, excluding the one being processed.
+func (n *NamespaceController) LengthOfQueue() int {
+	return n.workqueue.Len()
+}
+
 // filter this event if we do not need to handle this event
 func (nsc *NamespaceController) needSync(obj interface{}, event string) (string, bool) {
 	needSync := false
@@ -67,6 +67,11 @@ func (c *NetworkPolicyController) LengthOfRawNpMap() int {
 	return len(c.rawNpSpecMap)
 }
 
+// LengthOfQueue returns the number of NetworkPolicies waiting to be processed, excluding the one being processed.
+func (c *NetworkPolicyController) LengthOfQueue() int {
+	return c.workqueue.Len()
+}
+
 // getNetworkPolicyKey returns namespace/name of network policy object if it is valid network policy object and has valid namespace/name.
 // If not, it returns error.
 func (c *NetworkPolicyController) getNetworkPolicyKey(obj interface{}) (string, error) {
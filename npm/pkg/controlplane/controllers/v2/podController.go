@@ -83,6 +83,11 @@ func (c *PodController) LengthOfPodMap() int {
 	return len(c.podMap)
 }
 
+// LengthOfQueue returns the number of Pods waiting to be processed, excluding the one being processed.
+func (c *PodController) LengthOfQueue() int {
+	return c.workqueue.Len()
+}
+
 // needSync filters the event if the event is not required to handle
 func (c *PodController) needSync(eventType string, obj interface{}) (string, bool) {
 	needSync := false
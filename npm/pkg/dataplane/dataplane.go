@@ -25,6 +25,7 @@ const (
 	contextAddNetPolBootup     = "BOOTUP-ADD-NETPOL"
 	contextAddNetPolPrecaution = "ADD-NETPOL-PRECAUTION"
 	contextDelNetPol           = "DEL-NETPOL"
+	contextHandover            = "HANDOVER"
 )
 
 var (
@@ -46,6 +47,11 @@ type Config struct {
 	MaxPendingNetPols  int
 	NetPolInterval     time.Duration
 	EnableNPMLite      bool
+	// PolicyHitsInterval is how often the counters of the policy rules are exported to Prometheus. Zero disables the export.
+	// PolicyHitsInterval is only used in Linux.
+	PolicyHitsInterval time.Duration
+	// MaxPolicyHitSeries bounds the number of series of each policy hit metric.
+	MaxPolicyHitSeries int
 	*ipsets.IPSetManagerCfg
 	*policies.PolicyManagerCfg
 }
@@ -75,7 +81,10 @@ type DataPlane struct {
 	// removePolicyInfo tracks when a policy was removed yet had ApplyIPSet failures.
 	// This field is only relevant for Linux.
 	removePolicyInfo removePolicyInfo
-	stopChannel      <-chan struct{}
+	// naming is the naming generation of the chains and ipsets, settled on during bootup.
+	// This field is only relevant for Linux.
+	naming      *util.Naming
+	stopChannel <-chan struct{}
 }
 
 func NewDataPlane(nodeName string, ioShim *common.IOShim, cfg *Config, stopChannel <-chan struct{}) (*DataPlane, error) {
@@ -154,6 +163,21 @@ func (dp *DataPlane) FinishBootupPhase() {
 	dp.applyInfo.inBootupPhase = false
 }
 
+// CompleteHandover activates the dataplane of this NPM after a graceful handover bootup,
+// and cleans up the iptables chains and ipsets of the previous NPM. It is a no-op otherwise.
+// Should be called once the controllers have processed the initial state of the cluster.
+func (dp *DataPlane) CompleteHandover() error {
+	if dp.netPolInBackground {
+		// the previous NPM must not be replaced before this NPM enforces all NetPols
+		dp.netPolQueue.Lock()
+		if dp.netPolQueue.len() > 0 {
+			dp.addPoliciesWithRetry(contextHandover)
+		}
+		dp.netPolQueue.Unlock()
+	}
+	return dp.completeHandover()
+}
+
 // RunPeriodicTasks runs periodic tasks. Should only be called once.
 func (dp *DataPlane) RunPeriodicTasks() {
 	go func() {
@@ -204,6 +228,28 @@ func (dp *DataPlane) RunPeriodicTasks() {
 		}()
 	}
 
+	if dp.PolicyHitsInterval > 0 && !util.IsWindowsDP() {
+		if dp.MaxPolicyHitSeries > 0 {
+			metrics.SetMaxPolicyHitSeries(dp.MaxPolicyHitSeries)
+		}
+		collector := newPolicyHitCollector(dp.policyMgr.GetPolicyHits)
+		go func() {
+			ticker := time.NewTicker(dp.PolicyHitsInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-dp.stopChannel:
+					return
+				case <-ticker.C:
+					if err := collector.collect(); err != nil {
+						klog.Errorf("[DataPlane] failed to collect policy hits: %v", err)
+					}
+				}
+			}
+		}()
+	}
+
 	if !dp.applyInBackground {
 		return
 	}
@@ -2,6 +2,7 @@ package dataplane
 
 import (
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/util"
 	npmerrors "github.com/Azure/azure-container-networking/npm/util/errors"
 )
 
@@ -24,9 +25,32 @@ func (dp *DataPlane) bootupDataPlane() error {
 	if err := dp.policyMgr.Bootup(nil); err != nil {
 		return npmerrors.ErrorWrapper(npmerrors.BootupDataplane, false, "failed to reset policy dataplane", err)
 	}
+	// the ipsets must be named after the generation of the chains
+	dp.naming = dp.policyMgr.Naming()
+	dp.ipsetMgr.SetNaming(dp.naming)
 	if err := dp.ipsetMgr.ResetIPSets(); err != nil {
 		return npmerrors.ErrorWrapper(npmerrors.BootupDataplane, false, "failed to reset ipsets dataplane", err)
 	}
+	if !dp.GracefulHandover {
+		// the chains of the second generation were flushed by the policy bootup, so its sets are unreferenced now
+		if err := dp.ipsetMgr.DestroyIPSetsOfGeneration(util.NamingGenerationB); err != nil {
+			return npmerrors.ErrorWrapper(npmerrors.BootupDataplane, false, "failed to clean up ipsets of previous NPM", err)
+		}
+	}
+	return nil
+}
+
+func (dp *DataPlane) completeHandover() error {
+	if !dp.GracefulHandover {
+		return nil
+	}
+	// It is important to keep order to swap and clean-up chains before ipsets. Otherwise we won't be able to delete ipsets referenced by rules
+	if err := dp.policyMgr.CompleteHandover(); err != nil {
+		return npmerrors.ErrorWrapper(npmerrors.CompleteHandover, false, "failed to complete handover of policy dataplane", err)
+	}
+	if err := dp.ipsetMgr.DestroyIPSetsOfGeneration(dp.naming.Generation.Other()); err != nil {
+		return npmerrors.ErrorWrapper(npmerrors.CompleteHandover, false, "failed to clean up ipsets of previous NPM", err)
+	}
 	return nil
 }
 
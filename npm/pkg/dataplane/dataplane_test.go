@@ -463,7 +463,8 @@ func TestUpdatePodCache(t *testing.T) {
 }
 
 func getBootupTestCalls() []testutils.TestCmd {
-	return append(policies.GetBootupTestCalls(), ipsets.GetResetTestCalls()...)
+	calls := append(policies.GetBootupTestCalls(), ipsets.GetResetTestCalls()...)
+	return append(calls, ipsets.GetDestroyIPSetsOfGenerationTestCalls(util.NamingGenerationB)...)
 }
 
 func getAddPolicyTestCallsForDP(networkPolicy *policies.NPMNetworkPolicy) []testutils.TestCmd {
@@ -138,6 +138,11 @@ func (dp *DataPlane) bootupDataPlane() error {
 	return nil
 }
 
+func (dp *DataPlane) completeHandover() error {
+	// NOOP in Windows
+	return nil
+}
+
 func (dp *DataPlane) shouldUpdatePod() bool {
 	return true
 }
@@ -0,0 +1,515 @@
+package debug
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+
+	common "github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/common"
+	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/translation"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/util"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// PolicyAnalysis is the result of analyzing the NetworkPolicies of a cluster against the pods in the NPM cache.
+type PolicyAnalysis struct {
+	// UnselectedPods are the pods ("namespace/name") which no NetworkPolicy selects.
+	UnselectedPods []string `json:"unselectedPods"`
+	// UnusedPolicies are the NetworkPolicies ("namespace/name") whose pod selector matches no pod.
+	UnusedPolicies []string `json:"unusedPolicies"`
+	// ShadowedRules are the rules which allow nothing that broader rules in the same namespace don't already allow.
+	ShadowedRules []ShadowedRule `json:"shadowedRules"`
+	// OverlappingIPBlocks are the ipBlock CIDRs which overlap a pod or service CIDR.
+	OverlappingIPBlocks []IPBlockOverlap `json:"overlappingIPBlocks"`
+	// UntranslatedPolicies are the NetworkPolicies NPM fails to translate, which were left out of the analysis.
+	UntranslatedPolicies []PolicyError `json:"untranslatedPolicies,omitempty"`
+}
+
+// RuleRef identifies an ingress or egress rule of a NetworkPolicy by its index in the spec.
+type RuleRef struct {
+	Policy    string `json:"policy"`
+	Direction string `json:"direction"`
+	Index     int    `json:"index"`
+}
+
+func (r RuleRef) String() string {
+	return fmt.Sprintf("%s %s[%d]", r.Policy, strings.ToLower(r.Direction), r.Index)
+}
+
+func (r RuleRef) less(other RuleRef) bool {
+	if r.Policy != other.Policy {
+		return r.Policy < other.Policy
+	}
+	if r.Direction != other.Direction {
+		return r.Direction < other.Direction
+	}
+	return r.Index < other.Index
+}
+
+// ShadowedRule is a rule whose traffic is all allowed by the rules in ShadowedBy.
+type ShadowedRule struct {
+	Rule       RuleRef   `json:"rule"`
+	ShadowedBy []RuleRef `json:"shadowedBy"`
+}
+
+// IPBlockOverlap is an ipBlock CIDR of a rule which overlaps a pod or service CIDR.
+type IPBlockOverlap struct {
+	Rule     RuleRef `json:"rule"`
+	CIDR     string  `json:"cidr"`
+	Overlaps string  `json:"overlaps"`
+}
+
+// PolicyError is a NetworkPolicy which could not be analyzed.
+type PolicyError struct {
+	Policy string `json:"policy"`
+	Error  string `json:"error"`
+}
+
+// analyzedRule is one ingress or egress rule translated on its own.
+type analyzedRule struct {
+	ref    RuleRef
+	policy *analyzedPolicy
+	acls   []*policies.ACLPolicy
+}
+
+type analyzedPolicy struct {
+	key  string
+	spec *networkingv1.NetworkPolicy
+	// podSelector are the conditions a pod must meet to be selected by the policy
+	podSelector []policies.SetInfo
+	// sets are the translated ipsets of the policy by name
+	sets  map[string]*ipsets.TranslatedIPSet
+	rules []*analyzedRule
+}
+
+// AnalyzePolicies reports the pods selected by no policy, the policies selecting no pod, the rules shadowed by broader
+// rules and the ipBlocks overlapping the pod or service CIDRs. Policies are translated like NPM does, so selectors and
+// rules are compared in terms of the ipsets NPM would program.
+func AnalyzePolicies(cache *common.Cache, netpols []*networkingv1.NetworkPolicy, podCIDRs, serviceCIDRs []*net.IPNet) *PolicyAnalysis {
+	analysis := &PolicyAnalysis{
+		UnselectedPods:      []string{},
+		UnusedPolicies:      []string{},
+		ShadowedRules:       []ShadowedRule{},
+		OverlappingIPBlocks: []IPBlockOverlap{},
+	}
+
+	analyzed := make([]*analyzedPolicy, 0, len(netpols))
+	for _, netpol := range netpols {
+		p, err := analyzePolicy(netpol)
+		if err != nil {
+			analysis.UntranslatedPolicies = append(analysis.UntranslatedPolicies, PolicyError{Policy: netpol.Namespace + "/" + netpol.Name, Error: err.Error()})
+			continue
+		}
+		analyzed = append(analyzed, p)
+	}
+	sort.Slice(analyzed, func(i, j int) bool { return analyzed[i].key < analyzed[j].key })
+
+	selected := map[string]struct{}{}
+	for _, p := range analyzed {
+		matches := 0
+		for podKey, pod := range cache.PodMap {
+			if pod.PodIP == "" {
+				continue
+			}
+			if p.selects(cache, pod) {
+				selected[podKey] = struct{}{}
+				matches++
+			}
+		}
+		if matches == 0 {
+			analysis.UnusedPolicies = append(analysis.UnusedPolicies, p.key)
+		}
+	}
+	for podKey, pod := range cache.PodMap {
+		if _, ok := selected[podKey]; !ok && pod.PodIP != "" {
+			analysis.UnselectedPods = append(analysis.UnselectedPods, podKey)
+		}
+	}
+	sort.Strings(analysis.UnselectedPods)
+
+	analysis.ShadowedRules = shadowedRules(analyzed)
+	analysis.OverlappingIPBlocks = overlappingIPBlocks(analyzed, podCIDRs, serviceCIDRs)
+	return analysis
+}
+
+// analyzePolicy translates the pod selector of the policy and each of its rules on its own, so that the ACLs of a rule
+// are known.
+func analyzePolicy(netpol *networkingv1.NetworkPolicy) (*analyzedPolicy, error) {
+	selectorOnly := netpol.DeepCopy()
+	selectorOnly.Spec.PolicyTypes = nil
+	translated, err := translation.TranslatePolicy(selectorOnly, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to translate pod selector: %w", err)
+	}
+	p := &analyzedPolicy{
+		key:         translated.PolicyKey,
+		spec:        netpol,
+		podSelector: translated.PodSelectorList,
+		sets:        map[string]*ipsets.TranslatedIPSet{},
+	}
+	p.addSets(translated)
+
+	for _, policyType := range policyTypes(netpol) {
+		var count int
+		if policyType == networkingv1.PolicyTypeIngress {
+			count = len(netpol.Spec.Ingress)
+		} else {
+			count = len(netpol.Spec.Egress)
+		}
+		for i := 0; i < count; i++ {
+			single := netpol.DeepCopy()
+			single.Spec.PolicyTypes = []networkingv1.PolicyType{policyType}
+			if policyType == networkingv1.PolicyTypeIngress {
+				single.Spec.Ingress = single.Spec.Ingress[i : i+1]
+			} else {
+				single.Spec.Egress = single.Spec.Egress[i : i+1]
+			}
+			translated, err := translation.TranslatePolicy(single, false)
+			if err != nil {
+				return nil, fmt.Errorf("failed to translate %s rule %d: %w", strings.ToLower(string(policyType)), i, err)
+			}
+			p.addSets(translated)
+			rule := &analyzedRule{ref: RuleRef{Policy: p.key, Direction: string(policyType), Index: i}, policy: p}
+			for _, acl := range translated.ACLs {
+				// the default drop ACL is shared by all the rules of a direction
+				if acl.Target == policies.Allowed {
+					rule.acls = append(rule.acls, acl)
+				}
+			}
+			p.rules = append(p.rules, rule)
+		}
+	}
+	return p, nil
+}
+
+// policyTypes are the policy types of the policy, defaulted like the API server does for policies read from a file.
+func policyTypes(netpol *networkingv1.NetworkPolicy) []networkingv1.PolicyType {
+	if len(netpol.Spec.PolicyTypes) > 0 {
+		return netpol.Spec.PolicyTypes
+	}
+	types := []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+	if len(netpol.Spec.Egress) > 0 {
+		types = append(types, networkingv1.PolicyTypeEgress)
+	}
+	return types
+}
+
+func (p *analyzedPolicy) addSets(translated *policies.NPMNetworkPolicy) {
+	for _, set := range append(translated.AllPodSelectorIPSets(), translated.RuleIPSets...) {
+		p.sets[set.Metadata.GetPrefixName()] = set
+	}
+}
+
+// selects is true if the pod meets all the pod selector conditions of the policy.
+func (p *analyzedPolicy) selects(cache *common.Cache, pod *common.NpmPod) bool {
+	for _, cond := range p.podSelector {
+		if p.podInSet(cache, pod, cond.IPSet) != cond.Included {
+			return false
+		}
+	}
+	return true
+}
+
+// podInSet is true if NPM would add the pod to the ipset.
+func (p *analyzedPolicy) podInSet(cache *common.Cache, pod *common.NpmPod, set *ipsets.IPSetMetadata) bool {
+	switch set.Type {
+	case ipsets.Namespace:
+		return pod.Namespace == set.Name
+	case ipsets.KeyLabelOfPod:
+		_, ok := pod.Labels[set.Name]
+		return ok
+	case ipsets.KeyValueLabelOfPod:
+		key, value, _ := strings.Cut(set.Name, ":")
+		v, ok := pod.Labels[key]
+		return ok && v == value
+	case ipsets.NestedLabelOfPod:
+		translated, ok := p.sets[set.GetPrefixName()]
+		if !ok {
+			return false
+		}
+		for _, member := range translated.Members {
+			if p.podInSet(cache, pod, ipsets.NewIPSetMetadata(member, ipsets.KeyValueLabelOfPod)) {
+				return true
+			}
+		}
+		return false
+	case ipsets.KeyLabelOfNamespace:
+		if set.Name == util.KubeAllNamespacesFlag {
+			return true
+		}
+		_, ok := namespaceLabels(cache, pod.Namespace)[set.Name]
+		return ok
+	case ipsets.KeyValueLabelOfNamespace:
+		key, value, _ := strings.Cut(set.Name, ":")
+		v, ok := namespaceLabels(cache, pod.Namespace)[key]
+		return ok && v == value
+	default:
+		return false
+	}
+}
+
+// namespaceLabels returns the labels of the namespace. The v1 cache keys namespaces by their ipset name.
+func namespaceLabels(cache *common.Cache, namespace string) map[string]string {
+	if ns, ok := cache.NsMap[namespace]; ok {
+		return ns.LabelsMap
+	}
+	if ns, ok := cache.NsMap[util.NamespacePrefix+namespace]; ok {
+		return ns.LabelsMap
+	}
+	return nil
+}
+
+// shadowedRules finds the rules all of whose ACLs are covered by ACLs of other rules.
+func shadowedRules(analyzed []*analyzedPolicy) []ShadowedRule {
+	byNamespace := map[string][]*analyzedRule{}
+	for _, p := range analyzed {
+		byNamespace[p.spec.Namespace] = append(byNamespace[p.spec.Namespace], p.rules...)
+	}
+
+	shadowed := []ShadowedRule{}
+	for _, rules := range byNamespace {
+		for _, rule := range rules {
+			if len(rule.acls) == 0 {
+				continue
+			}
+			by := map[RuleRef]struct{}{}
+			allCovered := true
+			for _, acl := range rule.acls {
+				coveredBy, ok := findCoveringRule(rule, acl, rules)
+				if !ok {
+					allCovered = false
+					break
+				}
+				by[coveredBy] = struct{}{}
+			}
+			if !allCovered {
+				continue
+			}
+			s := ShadowedRule{Rule: rule.ref}
+			for ref := range by {
+				s.ShadowedBy = append(s.ShadowedBy, ref)
+			}
+			sort.Slice(s.ShadowedBy, func(i, j int) bool { return s.ShadowedBy[i].less(s.ShadowedBy[j]) })
+			shadowed = append(shadowed, s)
+		}
+	}
+	sort.Slice(shadowed, func(i, j int) bool { return shadowed[i].Rule.less(shadowed[j].Rule) })
+	return shadowed
+}
+
+// findCoveringRule returns another rule with an ACL allowing all the traffic the ACL allows. Of two equivalent rules,
+// only the later one is reported as shadowed.
+func findCoveringRule(rule *analyzedRule, acl *policies.ACLPolicy, rules []*analyzedRule) (RuleRef, bool) {
+	for _, other := range rules {
+		if other == rule || other.ref.Direction != rule.ref.Direction {
+			continue
+		}
+		for _, otherACL := range other.acls {
+			if !covers(other, otherACL, rule, acl) {
+				continue
+			}
+			if covers(rule, acl, other, otherACL) && rule.ref.less(other.ref) {
+				continue
+			}
+			return other.ref, true
+		}
+	}
+	return RuleRef{}, false
+}
+
+// covers is true if the traffic matched by the ACL a of rule ra is a superset of the traffic matched by b of rule rb:
+// every condition of a is implied by a condition of b, and a's ports include b's.
+func covers(ra *analyzedRule, a *policies.ACLPolicy, rb *analyzedRule, b *policies.ACLPolicy) bool {
+	if !portsCover(a, b) {
+		return false
+	}
+	bConds := conditions(rb, b)
+	for _, ca := range conditions(ra, a) {
+		implied := false
+		for _, cb := range bConds {
+			if ca.implies(cb) {
+				implied = true
+				break
+			}
+		}
+		if !implied {
+			return false
+		}
+	}
+	return true
+}
+
+func portsCover(a, b *policies.ACLPolicy) bool {
+	if a.Protocol != "" && a.Protocol != policies.UnspecifiedProtocol && a.Protocol != b.Protocol {
+		return false
+	}
+	if a.DstPorts.Port == 0 {
+		return true
+	}
+	if b.DstPorts.Port == 0 {
+		return false
+	}
+	aEnd, bEnd := a.DstPorts.EndPort, b.DstPorts.EndPort
+	if aEnd == 0 {
+		aEnd = a.DstPorts.Port
+	}
+	if bEnd == 0 {
+		bEnd = b.DstPorts.Port
+	}
+	return a.DstPorts.Port <= b.DstPorts.Port && bEnd <= aEnd
+}
+
+// condition is an ipset match of an ACL or of the pod selector of its policy.
+type condition struct {
+	set        *ipsets.IPSetMetadata
+	cidrs      []*net.IPNet
+	exceptions bool
+	included   bool
+	matchType  policies.MatchType
+}
+
+func conditions(rule *analyzedRule, acl *policies.ACLPolicy) []condition {
+	infos := make([]policies.SetInfo, 0, len(rule.policy.podSelector)+len(acl.SrcList)+len(acl.DstList))
+	infos = append(infos, rule.policy.podSelector...)
+	infos = append(infos, acl.SrcList...)
+	infos = append(infos, acl.DstList...)
+	conds := make([]condition, 0, len(infos))
+	for _, info := range infos {
+		c := condition{set: info.IPSet, included: info.Included, matchType: info.MatchType}
+		if info.IPSet.Type == ipsets.CIDRBlocks {
+			if translated, ok := rule.policy.sets[info.IPSet.GetPrefixName()]; ok {
+				for _, member := range translated.Members {
+					if strings.HasSuffix(member, util.IpsetNomatch) {
+						c.exceptions = true
+						continue
+					}
+					if _, cidr, err := net.ParseCIDR(member); err == nil {
+						c.cidrs = append(c.cidrs, cidr)
+					}
+				}
+			}
+		}
+		conds = append(conds, c)
+	}
+	return conds
+}
+
+// implies is true if any traffic meeting other also meets c.
+func (c condition) implies(other condition) bool {
+	if c.included != other.included || c.matchType != other.matchType || c.set.Type != other.set.Type {
+		return false
+	}
+	if c.set.Type != ipsets.CIDRBlocks {
+		return c.set.Name == other.set.Name
+	}
+	// CIDR sets are named after their policy, so compare their contents. A set with exceptions only implies itself.
+	if !c.included || c.exceptions || other.exceptions {
+		return c.set.Name == other.set.Name
+	}
+	for _, inner := range other.cidrs {
+		contained := false
+		for _, outer := range c.cidrs {
+			if cidrContains(outer, inner) {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			return false
+		}
+	}
+	return len(other.cidrs) > 0
+}
+
+func cidrContains(outer, inner *net.IPNet) bool {
+	outerOnes, outerBits := outer.Mask.Size()
+	innerOnes, innerBits := inner.Mask.Size()
+	return outerBits == innerBits && outerOnes <= innerOnes && outer.Contains(inner.IP)
+}
+
+func cidrsOverlap(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+func overlappingIPBlocks(analyzed []*analyzedPolicy, podCIDRs, serviceCIDRs []*net.IPNet) []IPBlockOverlap {
+	overlaps := []IPBlockOverlap{}
+	check := func(ref RuleRef, peers []networkingv1.NetworkPolicyPeer) {
+		for _, peer := range peers {
+			if peer.IPBlock == nil {
+				continue
+			}
+			_, cidr, err := net.ParseCIDR(peer.IPBlock.CIDR)
+			if err != nil {
+				continue
+			}
+			for _, podCIDR := range podCIDRs {
+				if cidrsOverlap(cidr, podCIDR) {
+					overlaps = append(overlaps, IPBlockOverlap{Rule: ref, CIDR: peer.IPBlock.CIDR, Overlaps: "pod CIDR " + podCIDR.String()})
+				}
+			}
+			for _, serviceCIDR := range serviceCIDRs {
+				if cidrsOverlap(cidr, serviceCIDR) {
+					overlaps = append(overlaps, IPBlockOverlap{Rule: ref, CIDR: peer.IPBlock.CIDR, Overlaps: "service CIDR " + serviceCIDR.String()})
+				}
+			}
+		}
+	}
+	for _, p := range analyzed {
+		for i := range p.spec.Spec.Ingress {
+			check(RuleRef{Policy: p.key, Direction: string(networkingv1.PolicyTypeIngress), Index: i}, p.spec.Spec.Ingress[i].From)
+		}
+		for i := range p.spec.Spec.Egress {
+			check(RuleRef{Policy: p.key, Direction: string(networkingv1.PolicyTypeEgress), Index: i}, p.spec.Spec.Egress[i].To)
+		}
+	}
+	return overlaps
+}
+
+// PrintPolicyAnalysis writes the analysis as indented JSON, or as text for people if asJSON is false.
+func PrintPolicyAnalysis(w io.Writer, analysis *PolicyAnalysis, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(analysis); err != nil {
+			return fmt.Errorf("failed to encode policy analysis: %w", err)
+		}
+		return nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Pods selected by no policy (%d):\n", len(analysis.UnselectedPods))
+	for _, pod := range analysis.UnselectedPods {
+		fmt.Fprintf(&sb, "\t%s\n", pod)
+	}
+	fmt.Fprintf(&sb, "Policies selecting no pod (%d):\n", len(analysis.UnusedPolicies))
+	for _, policy := range analysis.UnusedPolicies {
+		fmt.Fprintf(&sb, "\t%s\n", policy)
+	}
+	fmt.Fprintf(&sb, "Rules shadowed by broader rules (%d):\n", len(analysis.ShadowedRules))
+	for _, s := range analysis.ShadowedRules {
+		by := make([]string, 0, len(s.ShadowedBy))
+		for _, ref := range s.ShadowedBy {
+			by = append(by, ref.String())
+		}
+		fmt.Fprintf(&sb, "\t%s is shadowed by %s\n", s.Rule, strings.Join(by, ", "))
+	}
+	fmt.Fprintf(&sb, "ipBlocks overlapping pod or service CIDRs (%d):\n", len(analysis.OverlappingIPBlocks))
+	for _, o := range analysis.OverlappingIPBlocks {
+		fmt.Fprintf(&sb, "\t%s: %s overlaps %s\n", o.Rule, o.CIDR, o.Overlaps)
+	}
+	if len(analysis.UntranslatedPolicies) > 0 {
+		fmt.Fprintf(&sb, "Policies NPM fails to translate (%d):\n", len(analysis.UntranslatedPolicies))
+		for _, e := range analysis.UntranslatedPolicies {
+			fmt.Fprintf(&sb, "\t%s: %s\n", e.Policy, e.Error)
+		}
+	}
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return fmt.Errorf("failed to write policy analysis: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package debug
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+
+	common "github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func analyzerTestCache() *common.Cache {
+	pod := func(namespace, name, ip string, labels map[string]string) *common.NpmPod {
+		return &common.NpmPod{Name: name, Namespace: namespace, PodIP: ip, Labels: labels}
+	}
+	return &common.Cache{
+		NodeName: "node",
+		NsMap: map[string]*common.Namespace{
+			"x": {Name: "x", LabelsMap: map[string]string{"team": "a"}},
+			"y": {Name: "y", LabelsMap: map[string]string{"team": "b"}},
+		},
+		PodMap: map[string]*common.NpmPod{
+			"x/web":     pod("x", "web", "10.224.0.10", map[string]string{"app": "web"}),
+			"x/db":      pod("x", "db", "10.224.0.11", map[string]string{"app": "db"}),
+			"y/client":  pod("y", "client", "10.224.0.12", map[string]string{"app": "client"}),
+			"y/pending": pod("y", "pending", "", map[string]string{"app": "client"}),
+		},
+	}
+}
+
+func ingressPolicy(name, namespace string, podSelector metav1.LabelSelector, rules ...networkingv1.NetworkPolicyIngressRule) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: podSelector,
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress:     rules,
+		},
+	}
+}
+
+func tcpPort(port int) []networkingv1.NetworkPolicyPort {
+	tcp := corev1.ProtocolTCP
+	p := intstr.FromInt(port)
+	return []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &p}}
+}
+
+func ipBlockPeer(cidr string) []networkingv1.NetworkPolicyPeer {
+	return []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: cidr}}}
+}
+
+func selector(labels map[string]string) metav1.LabelSelector {
+	return metav1.LabelSelector{MatchLabels: labels}
+}
+
+func TestAnalyzePolicies(t *testing.T) {
+	fromTeamB := []networkingv1.NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "b"}}}}
+	netpols := []*networkingv1.NetworkPolicy{
+		// allows team b to all ports of web, which shadows the rules of web-80
+		ingressPolicy("web-all", "x", selector(map[string]string{"app": "web"}),
+			networkingv1.NetworkPolicyIngressRule{From: fromTeamB},
+			networkingv1.NetworkPolicyIngressRule{From: ipBlockPeer("10.0.0.0/8")},
+		),
+		ingressPolicy("web-80", "x", selector(map[string]string{"app": "web"}),
+			networkingv1.NetworkPolicyIngressRule{From: fromTeamB, Ports: tcpPort(80)},
+			networkingv1.NetworkPolicyIngressRule{From: ipBlockPeer("10.1.0.0/16"), Ports: tcpPort(80)},
+		),
+		// a rule for all pods of the namespace shadows narrower rules of policies selecting fewer pods
+		ingressPolicy("x-all", "x", metav1.LabelSelector{},
+			networkingv1.NetworkPolicyIngressRule{From: ipBlockPeer("192.168.0.0/24")},
+		),
+		ingressPolicy("web-192", "x", selector(map[string]string{"app": "web"}),
+			networkingv1.NetworkPolicyIngressRule{From: ipBlockPeer("192.168.0.0/28")},
+		),
+		// but not rules allowing more peers
+		ingressPolicy("db-192", "x", selector(map[string]string{"app": "db"}),
+			networkingv1.NetworkPolicyIngressRule{From: ipBlockPeer("192.168.0.0/16")},
+		),
+		ingressPolicy("nothing", "x", selector(map[string]string{"app": "missing"})),
+		// pods of y are only selected while their IP is known
+		ingressPolicy("pending", "y", selector(map[string]string{"app": "client"})),
+	}
+	_, podCIDR, _ := net.ParseCIDR("10.224.0.0/16")
+	_, serviceCIDR, _ := net.ParseCIDR("10.0.0.0/16")
+
+	analysis := AnalyzePolicies(analyzerTestCache(), netpols, []*net.IPNet{podCIDR}, []*net.IPNet{serviceCIDR})
+
+	assert.Equal(t, []string{}, analysis.UnselectedPods)
+	assert.Equal(t, []string{"x/nothing"}, analysis.UnusedPolicies)
+	assert.Equal(t, []ShadowedRule{
+		{
+			Rule:       RuleRef{Policy: "x/web-192", Direction: "Ingress", Index: 0},
+			ShadowedBy: []RuleRef{{Policy: "x/x-all", Direction: "Ingress", Index: 0}},
+		},
+		{
+			Rule:       RuleRef{Policy: "x/web-80", Direction: "Ingress", Index: 0},
+			ShadowedBy: []RuleRef{{Policy: "x/web-all", Direction: "Ingress", Index: 0}},
+		},
+		{
+			Rule:       RuleRef{Policy: "x/web-80", Direction: "Ingress", Index: 1},
+			ShadowedBy: []RuleRef{{Policy: "x/web-all", Direction: "Ingress", Index: 1}},
+		},
+	}, analysis.ShadowedRules)
+	assert.Equal(t, []IPBlockOverlap{
+		{Rule: RuleRef{Policy: "x/web-all", Direction: "Ingress", Index: 1}, CIDR: "10.0.0.0/8", Overlaps: "pod CIDR 10.224.0.0/16"},
+		{Rule: RuleRef{Policy: "x/web-all", Direction: "Ingress", Index: 1}, CIDR: "10.0.0.0/8", Overlaps: "service CIDR 10.0.0.0/16"},
+	}, analysis.OverlappingIPBlocks)
+}
+
+func TestAnalyzePoliciesUnselected(t *testing.T) {
+	netpols := []*networkingv1.NetworkPolicy{
+		ingressPolicy("web", "x", selector(map[string]string{"app": "web"})),
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "not-db", Namespace: "x"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "app", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"db"}}},
+				},
+			},
+		},
+	}
+	analysis := AnalyzePolicies(analyzerTestCache(), netpols, nil, nil)
+	assert.Equal(t, []string{"x/db", "y/client"}, analysis.UnselectedPods)
+	assert.Equal(t, []string{}, analysis.UnusedPolicies)
+}
+
+func TestEquivalentRulesShadowOnce(t *testing.T) {
+	rule := networkingv1.NetworkPolicyIngressRule{From: ipBlockPeer("172.16.0.0/12"), Ports: tcpPort(443)}
+	netpols := []*networkingv1.NetworkPolicy{
+		ingressPolicy("a", "x", selector(map[string]string{"app": "db"}), rule),
+		ingressPolicy("b", "x", selector(map[string]string{"app": "db"}), rule),
+	}
+	analysis := AnalyzePolicies(analyzerTestCache(), netpols, nil, nil)
+	assert.Equal(t, []ShadowedRule{
+		{
+			Rule:       RuleRef{Policy: "x/b", Direction: "Ingress", Index: 0},
+			ShadowedBy: []RuleRef{{Policy: "x/a", Direction: "Ingress", Index: 0}},
+		},
+	}, analysis.ShadowedRules)
+}
+
+func TestPrintPolicyAnalysis(t *testing.T) {
+	analysis := &PolicyAnalysis{
+		UnselectedPods: []string{"x/db"},
+		UnusedPolicies: []string{},
+		ShadowedRules: []ShadowedRule{{
+			Rule:       RuleRef{Policy: "x/b", Direction: "Ingress", Index: 0},
+			ShadowedBy: []RuleRef{{Policy: "x/a", Direction: "Ingress", Index: 0}},
+		}},
+		OverlappingIPBlocks: []IPBlockOverlap{},
+	}
+
+	var text bytes.Buffer
+	require.NoError(t, PrintPolicyAnalysis(&text, analysis, false))
+	assert.Contains(t, text.String(), "Pods selected by no policy (1):\n\tx/db\n")
+	assert.Contains(t, text.String(), "\tx/b ingress[0] is shadowed by x/a ingress[0]\n")
+
+	var out bytes.Buffer
+	require.NoError(t, PrintPolicyAnalysis(&out, analysis, true))
+	var decoded PolicyAnalysis
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, *analysis, decoded)
+}
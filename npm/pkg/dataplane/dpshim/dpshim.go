@@ -52,6 +52,11 @@ func (dp *DPShim) FinishBootupPhase() {
 	// No-op
 }
 
+func (dp *DPShim) CompleteHandover() error {
+	// No-op
+	return nil
+}
+
 // HydrateClients is used in DPShim to hydrate a restarted Daemon Client
 func (dp *DPShim) HydrateClients() (*protos.Events, error) {
 	dp.lock()
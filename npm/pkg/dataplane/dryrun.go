@@ -0,0 +1,145 @@
+package dataplane
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/util"
+	"k8s.io/klog"
+)
+
+var ErrDryRunUnsupported = errors.New("dry-run dataplane is only supported on Linux")
+
+// DryRunDataPlane is a GenericDataplane that computes every change NPM would make to the dataplane but never applies it.
+// Each call from the controllers is written to the output, followed by the iptables and ipset commands
+// (including the contents of iptables-restore and ipset restore files) that it would have run.
+// Commands which only read the dataplane still run, so the output is what this NPM would program on top of the live rules.
+// A shadow NPM running with this dataplane can be diffed against the NPM actually programming the node.
+type DryRunDataPlane struct {
+	*DataPlane
+	log *dryRunLog
+}
+
+// NewDryRunDataPlane creates a DataPlane whose mutating commands are written to out instead of being run.
+// Like NewDataPlane, it boots up the dataplane, so the output starts with the bootup changes.
+func NewDryRunDataPlane(nodeName string, ioShim *common.IOShim, cfg *Config, stopChannel <-chan struct{}, out io.Writer) (*DryRunDataPlane, error) {
+	if util.IsWindowsDP() {
+		return nil, ErrDryRunUnsupported
+	}
+
+	log := &dryRunLog{w: out}
+	dryRunShim := *ioShim
+	dryRunShim.Exec = &dryRunExec{Interface: ioShim.Exec, log: log}
+
+	klog.Info("[DataPlane] creating dataplane in dry-run mode. no changes will be applied")
+	log.call("BootupDataplane")
+	dp, err := NewDataPlane(nodeName, &dryRunShim, cfg, stopChannel)
+	if err != nil {
+		return nil, err
+	}
+	return &DryRunDataPlane{DataPlane: dp, log: log}, nil
+}
+
+func (dp *DryRunDataPlane) CompleteHandover() error {
+	dp.log.call("CompleteHandover")
+	return dp.DataPlane.CompleteHandover()
+}
+
+func (dp *DryRunDataPlane) CreateIPSets(setMetadatas []*ipsets.IPSetMetadata) {
+	dp.log.call("CreateIPSets %s", setNames(setMetadatas))
+	dp.DataPlane.CreateIPSets(setMetadatas)
+}
+
+func (dp *DryRunDataPlane) DeleteIPSet(setMetadata *ipsets.IPSetMetadata, deleteOption util.DeleteOption) {
+	dp.log.call("DeleteIPSet %s force=%t", setMetadata.GetPrefixName(), deleteOption == util.ForceDelete)
+	dp.DataPlane.DeleteIPSet(setMetadata, deleteOption)
+}
+
+func (dp *DryRunDataPlane) AddToSets(setMetadatas []*ipsets.IPSetMetadata, podMetadata *PodMetadata) error {
+	dp.log.call("AddToSets %s pod=%s ip=%s", setNames(setMetadatas), podMetadata.PodKey, podMetadata.PodIP)
+	return dp.DataPlane.AddToSets(setMetadatas, podMetadata)
+}
+
+func (dp *DryRunDataPlane) RemoveFromSets(setMetadatas []*ipsets.IPSetMetadata, podMetadata *PodMetadata) error {
+	dp.log.call("RemoveFromSets %s pod=%s ip=%s", setNames(setMetadatas), podMetadata.PodKey, podMetadata.PodIP)
+	return dp.DataPlane.RemoveFromSets(setMetadatas, podMetadata)
+}
+
+func (dp *DryRunDataPlane) AddToLists(listMetadatas, setMetadatas []*ipsets.IPSetMetadata) error {
+	dp.log.call("AddToLists %s members=%s", setNames(listMetadatas), setNames(setMetadatas))
+	return dp.DataPlane.AddToLists(listMetadatas, setMetadatas)
+}
+
+func (dp *DryRunDataPlane) RemoveFromList(listMetadata *ipsets.IPSetMetadata, setMetadatas []*ipsets.IPSetMetadata) error {
+	dp.log.call("RemoveFromList %s members=%s", listMetadata.GetPrefixName(), setNames(setMetadatas))
+	return dp.DataPlane.RemoveFromList(listMetadata, setMetadatas)
+}
+
+func (dp *DryRunDataPlane) ApplyDataPlane() error {
+	dp.log.call("ApplyDataPlane")
+	return dp.DataPlane.ApplyDataPlane()
+}
+
+func (dp *DryRunDataPlane) AddPolicy(policy *policies.NPMNetworkPolicy) error {
+	dp.log.call("AddPolicy %s", policy.PolicyKey)
+	return dp.DataPlane.AddPolicy(policy)
+}
+
+func (dp *DryRunDataPlane) RemovePolicy(policyKey string) error {
+	dp.log.call("RemovePolicy %s", policyKey)
+	return dp.DataPlane.RemovePolicy(policyKey)
+}
+
+func (dp *DryRunDataPlane) UpdatePolicy(policy *policies.NPMNetworkPolicy) error {
+	dp.log.call("UpdatePolicy %s", policy.PolicyKey)
+	return dp.DataPlane.UpdatePolicy(policy)
+}
+
+func setNames(setMetadatas []*ipsets.IPSetMetadata) string {
+	names := make([]string, 0, len(setMetadatas))
+	for _, setMetadata := range setMetadatas {
+		names = append(names, setMetadata.GetPrefixName())
+	}
+	return "[" + strings.Join(names, " ") + "]"
+}
+
+// dryRunLog serializes the calls and commands of the dry-run dataplane into its output.
+// Calls are prefixed with "#" and commands with "$". The stdin of a command follows it.
+type dryRunLog struct {
+	sync.Mutex
+	w io.Writer
+}
+
+func (l *dryRunLog) call(format string, args ...any) {
+	l.write("# " + fmt.Sprintf(format, args...) + "\n")
+}
+
+func (l *dryRunLog) command(cmd string, args []string, stdin []byte) {
+	var sb strings.Builder
+	sb.WriteString("$ " + cmd)
+	for _, arg := range args {
+		sb.WriteString(" " + arg)
+	}
+	sb.WriteString("\n")
+	if len(stdin) > 0 {
+		sb.Write(stdin)
+		if stdin[len(stdin)-1] != '\n' {
+			sb.WriteString("\n")
+		}
+	}
+	l.write(sb.String())
+}
+
+func (l *dryRunLog) write(s string) {
+	l.Lock()
+	defer l.Unlock()
+	if _, err := io.WriteString(l.w, s); err != nil {
+		klog.Errorf("[DataPlane] failed to write dry-run output: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package dataplane
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/Azure/azure-container-networking/npm/util/ioutil"
+	utilexec "k8s.io/utils/exec"
+)
+
+// iptablesMutatingFlags are the iptables operations which change the ruleset.
+var iptablesMutatingFlags = map[string]struct{}{
+	util.IptablesChainCreationFlag: {},
+	util.IptablesInsertionFlag:     {},
+	util.IptablesAppendFlag:        {},
+	util.IptablesDeletionFlag:      {},
+	util.IptablesFlushFlag:         {},
+	util.IptablesDestroyFlag:       {},
+	"-R":                           {},
+	"-P":                           {},
+	"-E":                           {},
+	"-Z":                           {},
+}
+
+// ipsetReadOnlyOperations are the ipset operations which don't change any set.
+var ipsetReadOnlyOperations = map[string]struct{}{
+	"list":    {},
+	"save":    {},
+	"test":    {},
+	"version": {},
+	"-L":      {},
+	"-S":      {},
+	"-T":      {},
+	"-n":      {},
+	"-t":      {},
+	"-v":      {},
+}
+
+// dryRunExec runs the commands which only read the dataplane and writes the others to the dry-run log.
+type dryRunExec struct {
+	utilexec.Interface
+	log *dryRunLog
+}
+
+func (e *dryRunExec) Command(cmd string, args ...string) utilexec.Cmd {
+	if !isMutatingCommand(cmd, args) {
+		return e.Interface.Command(cmd, args...)
+	}
+	return &dryRunCmd{log: e.log, cmd: cmd, args: args}
+}
+
+func (e *dryRunExec) CommandContext(ctx context.Context, cmd string, args ...string) utilexec.Cmd {
+	if !isMutatingCommand(cmd, args) {
+		return e.Interface.CommandContext(ctx, cmd, args...)
+	}
+	return &dryRunCmd{log: e.log, cmd: cmd, args: args}
+}
+
+// isMutatingCommand is true for any command which could change the dataplane. Unknown commands are assumed to.
+func isMutatingCommand(cmd string, args []string) bool {
+	name := filepath.Base(cmd)
+	switch {
+	case name == ioutil.Grep:
+		return false
+	case strings.HasPrefix(name, "iptables") || strings.HasPrefix(name, "ip6tables"):
+		if strings.HasSuffix(name, "-save") {
+			return false
+		}
+		if strings.HasSuffix(name, "-restore") {
+			return true
+		}
+		for _, arg := range args {
+			if _, ok := iptablesMutatingFlags[arg]; ok {
+				return true
+			}
+		}
+		return false
+	case name == "ipset":
+		if len(args) == 0 {
+			return true
+		}
+		_, ok := ipsetReadOnlyOperations[args[0]]
+		return !ok
+	default:
+		return true
+	}
+}
+
+// dryRunCmd is a command which is written to the dry-run log, with its stdin, instead of being run. It always succeeds
+// without output.
+type dryRunCmd struct {
+	log   *dryRunLog
+	cmd   string
+	args  []string
+	stdin io.Reader
+	done  bool
+}
+
+func (c *dryRunCmd) run() {
+	if c.done {
+		return
+	}
+	c.done = true
+	var stdin []byte
+	if c.stdin != nil {
+		var buf bytes.Buffer
+		_, _ = buf.ReadFrom(c.stdin)
+		stdin = buf.Bytes()
+	}
+	c.log.command(c.cmd, c.args, stdin)
+}
+
+func (c *dryRunCmd) Run() error {
+	c.run()
+	return nil
+}
+
+func (c *dryRunCmd) CombinedOutput() ([]byte, error) {
+	c.run()
+	return nil, nil
+}
+
+func (c *dryRunCmd) Output() ([]byte, error) {
+	c.run()
+	return nil, nil
+}
+
+func (c *dryRunCmd) SetDir(string) {}
+
+func (c *dryRunCmd) SetStdin(in io.Reader) {
+	c.stdin = in
+}
+
+func (c *dryRunCmd) SetStdout(io.Writer) {}
+
+func (c *dryRunCmd) SetStderr(io.Writer) {}
+
+func (c *dryRunCmd) SetEnv([]string) {}
+
+func (c *dryRunCmd) StdoutPipe() (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader("")), nil
+}
+
+func (c *dryRunCmd) StderrPipe() (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader("")), nil
+}
+
+func (c *dryRunCmd) Start() error {
+	c.run()
+	return nil
+}
+
+func (c *dryRunCmd) Wait() error {
+	return nil
+}
+
+func (c *dryRunCmd) Stop() {}
@@ -0,0 +1,82 @@
+package dataplane
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/util"
+	testutils "github.com/Azure/azure-container-networking/test/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readOnlyCalls drops the calls which the dry-run dataplane must not run.
+func readOnlyCalls(calls []testutils.TestCmd) []testutils.TestCmd {
+	out := make([]testutils.TestCmd, 0, len(calls))
+	for _, call := range calls {
+		if !isMutatingCommand(call.Cmd[0], call.Cmd[1:]) {
+			out = append(out, call)
+		}
+	}
+	return out
+}
+
+func TestDryRunApplyPolicy(t *testing.T) {
+	metrics.InitializeAll()
+
+	allCalls := append(getBootupTestCalls(), getAddPolicyTestCallsForDP(&testPolicyobj)...)
+	calls := readOnlyCalls(allCalls)
+	require.Less(t, len(calls), len(allCalls))
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	stopCh := make(chan struct{}, 1)
+	var out bytes.Buffer
+	dp, err := NewDryRunDataPlane("testnode", ioshim, dpCfg, stopCh, &out)
+	require.NoError(t, err)
+	defer func() {
+		stopCh <- struct{}{}
+		time.Sleep(100 * time.Millisecond)
+	}()
+
+	require.NoError(t, dp.AddPolicy(&testPolicyobj))
+
+	output := out.String()
+	bootup, addPolicy, found := strings.Cut(output, "# AddPolicy ns1/testpolicy\n")
+	require.True(t, found, output)
+	assert.True(t, strings.HasPrefix(bootup, "# BootupDataplane\n"), output)
+	assert.Contains(t, bootup, "$ "+util.IptablesRestore)
+	assert.Contains(t, addPolicy, "$ ipset restore\n")
+	assert.Contains(t, addPolicy, "-N azure-npm-")
+	assert.Contains(t, addPolicy, "$ "+util.IptablesRestore)
+	assert.Contains(t, addPolicy, ":AZURE-NPM-EGRESS-")
+	assert.Contains(t, addPolicy, "COMMIT\n")
+}
+
+func TestIsMutatingCommand(t *testing.T) {
+	tests := []struct {
+		cmd  []string
+		want bool
+	}{
+		{cmd: []string{"iptables-nft-save", "-t", "filter"}, want: false},
+		{cmd: []string{"iptables-nft-restore", "-w", "60", "-T", "filter", "--noflush"}, want: true},
+		{cmd: []string{"iptables-nft", "-w", "60", "-C", "FORWARD", "-j", "AZURE-NPM"}, want: false},
+		{cmd: []string{"iptables-nft", "-w", "60", "-n", "-L", "FORWARD", "--line-numbers"}, want: false},
+		{cmd: []string{"iptables-legacy", "-w", "60", "-D", "FORWARD", "-j", "AZURE-NPM"}, want: true},
+		{cmd: []string{"iptables-nft", "-w", "60", "-I", "FORWARD", "1", "-j", "AZURE-NPM"}, want: true},
+		{cmd: []string{"ipset", "list", "--name"}, want: false},
+		{cmd: []string{"ipset", "save"}, want: false},
+		{cmd: []string{"ipset", "-w", "-exist", "-restore"}, want: true},
+		{cmd: []string{"grep", "azure-npm-"}, want: false},
+		{cmd: []string{"bash", "-c", "ipset flush azure-npm-1 && ipset destroy azure-npm-1"}, want: true},
+		{cmd: []string{"unknown"}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.cmd, " "), func(t *testing.T) {
+			assert.Equal(t, tt.want, isMutatingCommand(tt.cmd[0], tt.cmd[1:]))
+		})
+	}
+}
@@ -42,6 +42,15 @@ func (setMetadata *IPSetMetadata) GetHashedName() string {
 	return util.GetHashedName(prefixedName)
 }
 
+// GetHashedNameFor returns the name of the set in the kernel under the given naming generation.
+func (setMetadata *IPSetMetadata) GetHashedNameFor(naming *util.Naming) string {
+	prefixedName := setMetadata.GetPrefixName()
+	if prefixedName == Unknown {
+		return Unknown
+	}
+	return naming.HashedName(prefixedName)
+}
+
 // TODO join with colon instead of dash for easier readability?
 func (setMetadata *IPSetMetadata) GetPrefixName() string {
 	switch setMetadata.Type {
@@ -227,11 +236,16 @@ type IPSet struct {
 }
 
 func NewIPSet(setMetadata *IPSetMetadata) *IPSet {
+	return newIPSet(setMetadata, util.GetHashedName(setMetadata.GetPrefixName()))
+}
+
+// newIPSet creates a set with the kernel name hashedName.
+func newIPSet(setMetadata *IPSetMetadata, hashedName string) *IPSet {
 	prefixedName := setMetadata.GetPrefixName()
 	set := &IPSet{
 		Name:           prefixedName,
 		unprefixedName: setMetadata.Name,
-		HashedName:     util.GetHashedName(prefixedName),
+		HashedName:     hashedName,
 		SetProperties: SetProperties{
 			Type: setMetadata.Type,
 			Kind: setMetadata.GetSetKind(),
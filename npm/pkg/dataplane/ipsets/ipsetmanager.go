@@ -54,6 +54,8 @@ type IPSetManager struct {
 	kernelNameOwner map[string]string
 	dirtyCache      dirtyCacheInterface
 	ioShim          *common.IOShim
+	// naming determines the kernel names of the sets. Only used in Linux.
+	naming *util.Naming
 	// consecutiveApplyFailures is used in Linux to count the number of consecutive failures to apply ipsets
 	// if this count exceeds a threshold, we will panic
 	consecutiveApplyFailures int
@@ -78,11 +80,28 @@ func NewIPSetManager(iMgrCfg *IPSetManagerCfg, ioShim *common.IOShim) *IPSetMana
 		kernelNameOwner: make(map[string]string),
 		dirtyCache:      newDirtyCache(),
 		ioShim:          ioShim,
+		naming:          util.NewNaming(util.NamingGenerationA),
 		// set to 0 to avoid lint error for windows
 		consecutiveApplyFailures: 0,
 	}
 }
 
+// SetNaming sets the naming generation of the kernel names of the sets.
+// Must be called before any ipsets are created, since the kernel name of a set is computed once.
+func (iMgr *IPSetManager) SetNaming(naming *util.Naming) {
+	iMgr.Lock()
+	defer iMgr.Unlock()
+	iMgr.naming = naming
+}
+
+// hashedName returns the kernel name of the prefixed set name.
+func (iMgr *IPSetManager) hashedName(prefixedName string) string {
+	if prefixedName == Unknown {
+		return Unknown
+	}
+	return iMgr.naming.HashedName(prefixedName)
+}
+
 /*
 Reconcile removes empty/unreferenced sets from the cache.
 For ApplyAllIPSets mode, those sets are added to the toDeleteCache.
@@ -151,11 +170,12 @@ func (iMgr *IPSetManager) createAndGetIPSet(setMetadata *IPSetMetadata) (*IPSet,
 		return set, nil
 	}
 
-	if err := iMgr.claimKernelName(prefixedName, setMetadata.GetHashedName()); err != nil {
+	hashedName := iMgr.hashedName(prefixedName)
+	if err := iMgr.claimKernelName(prefixedName, hashedName); err != nil {
 		return nil, err
 	}
 
-	set = NewIPSet(setMetadata)
+	set = newIPSet(setMetadata, hashedName)
 	iMgr.setMap[prefixedName] = set
 	metrics.IncNumIPSets()
 	if iMgr.iMgrCfg.IPSetMode == ApplyAllIPSets {
@@ -167,7 +187,7 @@ func (iMgr *IPSetManager) createAndGetIPSet(setMetadata *IPSetMetadata) (*IPSet,
 	if iMgr.iMgrCfg.AddEmptySetToLists && (set.Type == KeyLabelOfNamespace || set.Type == KeyValueLabelOfNamespace) {
 		if iMgr.emptySet == nil {
 			// duplicate of code chunk above
-			emptySet := NewIPSet(emptySetMetadata)
+			emptySet := newIPSet(emptySetMetadata, iMgr.hashedName(emptySetPrefixName))
 			if err := iMgr.claimKernelName(emptySetPrefixName, emptySet.HashedName); err != nil {
 				return nil, err
 			}
@@ -17,8 +17,8 @@ import (
 const (
 	ipsetFlushAndDestroyString = "ipset flush && ipset destroy"
 
-	azureNPMPrefix        = "azure-npm-"
-	azureNPMRegex         = "azure-npm-[0-9a-z]+"
+	hashedDigestRegex     = "[0-9a-z]+"
+	azureNPMRegex         = util.AzureNpmPrefix + hashedDigestRegex
 	positiveRefsRegex     = "References: [1-9]"
 	referenceGrepLookBack = "5"
 	maxLinesToPrint       = 10
@@ -59,6 +59,8 @@ const (
 )
 
 var (
+	errCurrentNamingGeneration = errors.New("cannot destroy the ipsets of the current naming generation")
+
 	// creator variables
 	setDoesntExistDefinition       = ioutil.NewErrorDefinition("The set with the given name does not exist")
 	setInUseByKernelDefinition     = ioutil.NewErrorDefinition("Set cannot be destroyed: it is in use by a kernel component")
@@ -101,15 +103,35 @@ prometheus metrics:
 	If a flush fails, we could update the num entries for that set, but that would be a lot of overhead.
 */
 func (iMgr *IPSetManager) resetIPSets() error {
-	if success := iMgr.resetWithoutRestore(); success {
+	return iMgr.destroySetsWithPrefix(iMgr.naming.IPSetPrefix)
+}
+
+// DestroyIPSetsOfGeneration flushes and destroys the sets of a previous naming generation once NPM has taken over from it.
+// The sets must no longer be referenced by iptables rules. Sets of the current generation and their metrics are untouched.
+func (iMgr *IPSetManager) DestroyIPSetsOfGeneration(g util.NamingGeneration) error {
+	iMgr.Lock()
+	defer iMgr.Unlock()
+	if g == iMgr.naming.Generation {
+		return fmt.Errorf("%w: %s", errCurrentNamingGeneration, g)
+	}
+	if err := iMgr.destroySetsWithPrefix(g.IPSetPrefix()); err != nil {
+		return fmt.Errorf("failed to destroy ipsets of naming generation %s: %w", g, err)
+	}
+	return nil
+}
+
+// destroySetsWithPrefix flushes and destroys the sets of a naming generation as described for resetIPSets().
+// Sets of the other generation count as non-azure sets.
+func (iMgr *IPSetManager) destroySetsWithPrefix(prefix string) error {
+	if success := iMgr.resetWithoutRestore(prefix); success {
 		return nil
 	}
 
 	// get current NPM ipsets
 	listNamesCommand := iMgr.ioShim.Exec.Command(ipsetCommand, ipsetListFlag, ipsetNameFlag)
-	grepCommand := iMgr.ioShim.Exec.Command(ioutil.Grep, azureNPMPrefix)
+	grepCommand := iMgr.ioShim.Exec.Command(ioutil.Grep, prefix)
 	// TODO: Refactor non-error/warning klogs with Zap and set the following logs to "debug" level
-	// klog.Infof("running this command while resetting ipsets: [%s %s %s | %s %s]", ipsetCommand, ipsetListFlag, ipsetNameFlag, ioutil.Grep, azureNPMRegex)
+	// klog.Infof("running this command while resetting ipsets: [%s %s %s | %s %s]", ipsetCommand, ipsetListFlag, ipsetNameFlag, ioutil.Grep, prefix)
 	azureIPSets, haveAzureNPMIPSets, commandError := ioutil.PipeCommandToGrep(listNamesCommand, grepCommand)
 	if commandError != nil {
 		return npmerrors.SimpleErrorWrapper("failed to run ipset list for resetting IPSets (prometheus metrics may be off now)", commandError)
@@ -130,7 +152,7 @@ func (iMgr *IPSetManager) resetIPSets() error {
 	}
 
 	// destroy all NPM sets
-	creator, destroyFailureCount := iMgr.fileCreatorForDestroyAll(names, failedNames, iMgr.setsWithReferences())
+	creator, destroyFailureCount := iMgr.fileCreatorForDestroyAll(names, failedNames, iMgr.setsWithReferences(prefix))
 	destroyError := creator.RunCommandWithFile(ipsetCommand, ipsetRestoreFlag)
 	if destroyError != nil {
 		klog.Errorf(
@@ -143,11 +165,11 @@ func (iMgr *IPSetManager) resetIPSets() error {
 }
 
 // resetWithoutRestore will return true (success) if able to reset without restore
-func (iMgr *IPSetManager) resetWithoutRestore() bool {
+func (iMgr *IPSetManager) resetWithoutRestore(prefix string) bool {
 	listNamesCommand := iMgr.ioShim.Exec.Command(ipsetCommand, ipsetListFlag, ipsetNameFlag)
-	grepCommand := iMgr.ioShim.Exec.Command(ioutil.Grep, ioutil.GrepQuietFlag, ioutil.GrepAntiMatchFlag, azureNPMPrefix)
+	grepCommand := iMgr.ioShim.Exec.Command(ioutil.Grep, ioutil.GrepQuietFlag, ioutil.GrepAntiMatchFlag, prefix)
 	// TODO: Refactor non-error/warning klogs with Zap and set the following logs to "debug" level
-	// commandString := fmt.Sprintf(" [%s %s %s | %s %s %s %s]", ipsetCommand, ipsetListFlag, ipsetNameFlag, ioutil.Grep, ioutil.GrepQuietFlag, ioutil.GrepAntiMatchFlag, azureNPMPrefix)
+	// commandString := fmt.Sprintf(" [%s %s %s | %s %s %s %s]", ipsetCommand, ipsetListFlag, ipsetNameFlag, ioutil.Grep, ioutil.GrepQuietFlag, ioutil.GrepAntiMatchFlag, prefix)
 	// klog.Infof("running this command while resetting ipsets: [%s]", commandString)
 	_, haveNonAzureNPMIPSets, commandError := ioutil.PipeCommandToGrep(listNamesCommand, grepCommand)
 	if commandError != nil {
@@ -222,14 +244,19 @@ func (iMgr *IPSetManager) fileCreatorForFlushAll(ipsetListOutput []byte) (creato
 	return creator, names, failedNames
 }
 
-func (iMgr *IPSetManager) setsWithReferences() map[string]struct{} {
+// azureSetRegex matches the hashed set names with the prefix of a naming generation.
+func azureSetRegex(prefix string) string {
+	return prefix + hashedDigestRegex
+}
+
+func (iMgr *IPSetManager) setsWithReferences(prefix string) map[string]struct{} {
 	listAllCommand := iMgr.ioShim.Exec.Command(ipsetCommand, ipsetListFlag)
 	grep1 := iMgr.ioShim.Exec.Command(ioutil.Grep, ioutil.GrepBeforeFlag, referenceGrepLookBack, ioutil.GrepRegexFlag, positiveRefsRegex)
-	grep2 := iMgr.ioShim.Exec.Command(ioutil.Grep, ioutil.GrepOnlyMatchingFlag, ioutil.GrepRegexFlag, azureNPMRegex)
+	grep2 := iMgr.ioShim.Exec.Command(ioutil.Grep, ioutil.GrepOnlyMatchingFlag, ioutil.GrepRegexFlag, azureSetRegex(prefix))
 	// TODO: Refactor non-error/warning klogs with Zap and set the following logs to "debug" level
 	// klog.Infof("running this command while resetting ipsets: [%s %s | %s %s %s %s %s | %s %s %s %s]", ipsetCommand, ipsetListFlag,
 	// 	ioutil.Grep, ioutil.GrepBeforeFlag, referenceGrepLookBack, ioutil.GrepRegexFlag, positiveRefsRegex,
-	// 	ioutil.Grep, ioutil.GrepOnlyMatchingFlag, ioutil.GrepRegexFlag, azureNPMRegex)
+	// 	ioutil.Grep, ioutil.GrepOnlyMatchingFlag, ioutil.GrepRegexFlag, azureSetRegex(prefix))
 	setsWithReferencesBytes, haveRefsStill, err := ioutil.DoublePipeToGrep(listAllCommand, grep1, grep2)
 
 	var setsWithReferences map[string]struct{}
@@ -443,7 +470,7 @@ func (iMgr *IPSetManager) applyIPSets() error {
 
 func (iMgr *IPSetManager) ipsetSave() ([]byte, error) {
 	command := iMgr.ioShim.Exec.Command(ipsetCommand, ipsetSaveFlag)
-	grepCommand := iMgr.ioShim.Exec.Command(ioutil.Grep, azureNPMPrefix)
+	grepCommand := iMgr.ioShim.Exec.Command(ioutil.Grep, iMgr.naming.IPSetPrefix)
 	saveFile, haveAzureSets, err := ioutil.PipeCommandToGrep(command, grepCommand)
 	if err != nil {
 		return nil, npmerrors.SimpleErrorWrapper("failed to run ipset save", err)
@@ -729,7 +756,7 @@ func (iMgr *IPSetManager) flushSetForApply(creator *ioutil.FileCreator, prefixed
 		},
 	}
 	sectionID := sectionID(destroySectionPrefix, prefixedName)
-	hashedName := util.GetHashedName(prefixedName)
+	hashedName := iMgr.hashedName(prefixedName)
 	creator.AddLine(sectionID, errorHandlers, ipsetFlushFlag, hashedName) // flush set
 }
 
@@ -752,7 +779,7 @@ func (iMgr *IPSetManager) destroySetForApply(creator *ioutil.FileCreator, prefix
 		},
 	}
 	sectionID := sectionID(destroySectionPrefix, prefixedName)
-	hashedName := util.GetHashedName(prefixedName)
+	hashedName := iMgr.hashedName(prefixedName)
 	creator.AddLine(sectionID, errorHandlers, ipsetDestroyFlag, hashedName) // destroy set
 }
 
@@ -702,6 +702,34 @@ func TestDestroyNPMIPSets(t *testing.T) {
 	}
 }
 
+func TestDestroyIPSetsOfGeneration(t *testing.T) {
+	metrics.ReinitializeAll()
+	calls := []testutils.TestCmd{
+		{Cmd: []string{"ipset", "list", "--name"}, PipedToCommand: true},
+		{Cmd: []string{"grep", "-q", "-v", "azure-npm-"}, ExitCode: 0}, // sets of the current generation exist
+		{Cmd: []string{"ipset", "list", "--name"}, PipedToCommand: true},
+		{Cmd: []string{"grep", "azure-npm-"}, Stdout: resetIPSetsListOutputString},
+		fakeRestoreSuccessCommand,
+		{Cmd: []string{"ipset", "list"}, PipedToCommand: true},
+		{Cmd: []string{"grep", "-B", "5", "-P", "References: [1-9]"}, PipedToCommand: true},
+		{Cmd: []string{"grep", "-o", "-P", "azure-npm-[0-9a-z]+"}, ExitCode: 1},
+		fakeRestoreSuccessCommand,
+	}
+	ioShim := common.NewMockIOShim(calls)
+	defer ioShim.VerifyCalls(t, calls)
+	iMgr := NewIPSetManager(applyAlwaysCfg, ioShim)
+	iMgr.SetNaming(util.NewNaming(util.NamingGenerationB))
+	iMgr.CreateIPSets([]*IPSetMetadata{namespaceSet})
+
+	require.NoError(t, iMgr.DestroyIPSetsOfGeneration(util.NamingGenerationA))
+	require.Error(t, iMgr.DestroyIPSetsOfGeneration(util.NamingGenerationB), "must not destroy the sets of the current generation")
+
+	// sets of the current generation are untouched
+	set := iMgr.GetIPSet(namespaceSet.GetPrefixName())
+	require.NotNil(t, set)
+	require.True(t, strings.HasPrefix(set.HashedName, "azure-npb-"))
+}
+
 // identical to TestResetIPSets in ipsetmanager_test.go except an error occurs
 // makes sure that the cache and metrics are reset despite error
 func TestResetIPSetsOnFailure(t *testing.T) {
@@ -1,6 +1,9 @@
 package ipsets
 
-import testutils "github.com/Azure/azure-container-networking/test/utils"
+import (
+	"github.com/Azure/azure-container-networking/npm/util"
+	testutils "github.com/Azure/azure-container-networking/test/utils"
+)
 
 var (
 	ipsetRestoreStringSlice = []string{"ipset", "restore"}
@@ -43,3 +46,12 @@ func GetResetTestCalls() []testutils.TestCmd {
 		{Cmd: []string{"bash", "-c", "ipset flush && ipset destroy"}},
 	}
 }
+
+// GetDestroyIPSetsOfGenerationTestCalls assumes there are no sets of any other generation left.
+func GetDestroyIPSetsOfGenerationTestCalls(g util.NamingGeneration) []testutils.TestCmd {
+	return []testutils.TestCmd{
+		{Cmd: []string{"ipset", "list", "--name"}, PipedToCommand: true},
+		{Cmd: []string{"grep", "-q", "-v", g.IPSetPrefix()}, ExitCode: 1}, // grep didn't find anything
+		{Cmd: []string{"bash", "-c", "ipset flush && ipset destroy"}},
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/network/hnswrapper"
+	"github.com/Azure/azure-container-networking/npm/util"
 	testutils "github.com/Azure/azure-container-networking/test/utils"
 	"github.com/Microsoft/hcsshim/hcn"
 	"github.com/stretchr/testify/require"
@@ -34,3 +35,7 @@ func GetApplyIPSetsFailureTestCalls() []testutils.TestCmd {
 func GetResetTestCalls() []testutils.TestCmd {
 	return []testutils.TestCmd{}
 }
+
+func GetDestroyIPSetsOfGenerationTestCalls(_ util.NamingGeneration) []testutils.TestCmd {
+	return []testutils.TestCmd{}
+}
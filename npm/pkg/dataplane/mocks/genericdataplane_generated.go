@@ -110,6 +110,20 @@ func (mr *MockGenericDataplaneMockRecorder) BootupDataplane() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BootupDataplane", reflect.TypeOf((*MockGenericDataplane)(nil).BootupDataplane))
 }
 
+// CompleteHandover mocks base method.
+func (m *MockGenericDataplane) CompleteHandover() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteHandover")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompleteHandover indicates an expected call of CompleteHandover.
+func (mr *MockGenericDataplaneMockRecorder) CompleteHandover() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteHandover", reflect.TypeOf((*MockGenericDataplane)(nil).CompleteHandover))
+}
+
 // CreateIPSets mocks base method.
 func (m *MockGenericDataplane) CreateIPSets(setMetadatas []*ipsets.IPSetMetadata) {
 	m.ctrl.T.Helper()
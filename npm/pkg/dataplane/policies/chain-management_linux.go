@@ -26,27 +26,6 @@ const (
 )
 
 var (
-	// Must loop through a slice because we need a deterministic order for fexec commands for UTs.
-	iptablesAzureChains = []string{
-		util.IptablesAzureChain,
-		util.IptablesAzureIngressChain,
-		util.IptablesAzureIngressAllowMarkChain,
-		util.IptablesAzureEgressChain,
-		util.IptablesAzureAcceptChain,
-	}
-	// Should not be used directly. Initialized from iptablesAzureChains on first use of isAzureChain().
-	iptablesAzureChainsMap map[string]struct{}
-
-	jumpToAzureChainArgs = []string{
-		util.IptablesJumpFlag,
-		util.IptablesAzureChain,
-		util.IptablesModuleFlag,
-		util.IptablesCtstateModuleFlag,
-		util.IptablesCtstateFlag,
-		util.IptablesNewState,
-	}
-	jumpFromForwardToAzureChainArgs = append([]string{util.IptablesForwardChain}, jumpToAzureChainArgs...)
-
 	removeDeprecatedJumpIgnoredErrors = []*exitErrorInfo{
 		{
 			// doesNotExistErrorCode happens when AZURE-NPM chain exists, but this jump rule doesn't exist
@@ -84,7 +63,7 @@ var (
 	deprecatedJumpFromForwardToAzureChainArgs = []string{
 		util.IptablesForwardChain,
 		util.IptablesJumpFlag,
-		util.IptablesAzureChain,
+		util.NamingGenerationA.ChainPrefix(),
 	}
 
 	listHintChainArgs   = []string{"KUBE-IPTABLES-HINT", util.IptablesTableFlag, util.IptablesMangleTable, util.IptablesNumericFlag}
@@ -101,12 +80,16 @@ type exitErrorInfo struct {
 
 type staleChains struct {
 	chainsToCleanup map[string]struct{}
+	// baseChains are the base chains of the naming generation in use, which are never stale.
+	baseChains map[string]struct{}
 }
 
 func newStaleChains() *staleChains {
-	return &staleChains{
+	s := &staleChains{
 		chainsToCleanup: make(map[string]struct{}),
 	}
+	s.setBaseChains(baseChains(util.NewNaming(util.NamingGenerationA)))
+	return s
 }
 
 // forceLock stops reconciling if it is running, and then locks the reconcileManager
@@ -125,14 +108,21 @@ func (rm *reconcileManager) forceUnlock() {
 	rm.Unlock()
 }
 
-// Adds the chain if it isn't one of the iptablesAzureChains.
+// Adds the chain if it isn't one of the base chains.
 // This protects against trying to delete any core NPM chain.
 func (s *staleChains) add(chain string) {
-	if !isBaseChain(chain) {
+	if _, ok := s.baseChains[chain]; !ok {
 		s.chainsToCleanup[chain] = struct{}{}
 	}
 }
 
+func (s *staleChains) setBaseChains(chains []string) {
+	s.baseChains = make(map[string]struct{}, len(chains))
+	for _, chain := range chains {
+		s.baseChains[chain] = struct{}{}
+	}
+}
+
 func (s *staleChains) remove(chain string) {
 	delete(s.chainsToCleanup, chain)
 }
@@ -152,15 +142,36 @@ func (s *staleChains) empty() {
 	s.chainsToCleanup = make(map[string]struct{})
 }
 
-func isBaseChain(chain string) bool {
-	if iptablesAzureChainsMap == nil {
-		iptablesAzureChainsMap = make(map[string]struct{})
-		for _, chain := range iptablesAzureChains {
-			iptablesAzureChainsMap[chain] = struct{}{}
-		}
+// baseChains returns the base chains of the naming.
+// Must return a slice because we need a deterministic order for fexec commands for UTs.
+func baseChains(naming *util.Naming) []string {
+	return []string{
+		naming.AzureChain,
+		naming.IngressChain,
+		naming.IngressAllowMarkChain,
+		naming.EgressChain,
+		naming.AcceptChain,
+	}
+}
+
+// jumpToAzureChainArgs returns the args of the jump to the AZURE-NPM chain of the naming in use, excluding the FORWARD chain.
+func (pMgr *PolicyManager) jumpToAzureChainArgs() []string {
+	return jumpToChainArgs(pMgr.naming.AzureChain)
+}
+
+func jumpToChainArgs(azureChain string) []string {
+	return []string{
+		util.IptablesJumpFlag,
+		azureChain,
+		util.IptablesModuleFlag,
+		util.IptablesCtstateModuleFlag,
+		util.IptablesCtstateFlag,
+		util.IptablesNewState,
 	}
-	_, exist := iptablesAzureChainsMap[chain]
-	return exist
+}
+
+func (pMgr *PolicyManager) jumpFromForwardToAzureChainArgs() []string {
+	return append([]string{util.IptablesForwardChain}, pMgr.jumpToAzureChainArgs()...)
 }
 
 /*
@@ -181,6 +192,9 @@ Like the rest of PolicyManager, minimizes the number of OS calls by consolidatin
     - delete old v2 policy chains
 
 3. Add/reposition the jump from FORWARD chain to AZURE-NPM chain.
+4. Remove the jumps to and the chains of the second naming generation, left by an NPM with GracefulHandover (see handover_linux.go).
+
+With GracefulHandover, steps 1-3 are replaced by bootupForHandover() (see handover_linux.go).
 
 TODO: could use one grep call instead of separate calls for getting jump line nums and for getting deprecated chains and old v2 policy chains
   - would use a grep pattern like so: <line num...AZURE-NPM>|<Chain AZURE-NPM>
@@ -203,15 +217,42 @@ func (pMgr *PolicyManager) bootup(_ []string) error {
 		return npmerrors.SimpleErrorWrapper("failed to cleanup other iptables chains", err)
 	}
 
+	if pMgr.GracefulHandover {
+		return pMgr.bootupForHandover()
+	}
+
+	pMgr.setNaming(util.NewNaming(util.NamingGenerationA))
 	if err := pMgr.bootupAfterDetectAndCleanup(); err != nil {
 		return err
 	}
 
+	// 4. cleanup the second naming generation
+	if err := pMgr.cleanupNamingGeneration(util.NamingGenerationB); err != nil {
+		return npmerrors.SimpleErrorWrapper("failed to cleanup chains of naming generation "+util.NamingGenerationB.String(), err)
+	}
+
 	return nil
 }
 
 func (pMgr *PolicyManager) bootupAfterDetectAndCleanup() error {
 	// 1. delete the deprecated jump to AZURE-NPM
+	pMgr.deleteDeprecatedJump()
+
+	// 2. cleanup old NPM chains, and configure base chains and their rules.
+	if err := pMgr.resetChains(); err != nil {
+		return err
+	}
+
+	// 3. add/reposition the jump to AZURE-NPM
+	if err := pMgr.positionAzureChainJumpRule(); err != nil {
+		baseErrString := "failed to add/reposition jump from FORWARD chain to AZURE-NPM chain"
+		metrics.SendErrorLogAndMetric(util.IptmID, "error: %s with error: %s", baseErrString, err.Error())
+		return npmerrors.SimpleErrorWrapper(baseErrString, err) // we used to ignore this error in v1
+	}
+	return nil
+}
+
+func (pMgr *PolicyManager) deleteDeprecatedJump() {
 	deprecatedErrCode, deprecatedErr := pMgr.ignoreErrorsAndRunIPTablesCommand(removeDeprecatedJumpIgnoredErrors, util.IptablesDeletionFlag, deprecatedJumpFromForwardToAzureChainArgs...)
 	if deprecatedErrCode == 0 {
 		klog.Infof("deleted deprecated jump rule from FORWARD chain to AZURE-NPM chain")
@@ -220,26 +261,21 @@ func (pMgr *PolicyManager) bootupAfterDetectAndCleanup() error {
 			"failed to delete deprecated jump rule from FORWARD chain to AZURE-NPM chain for unexpected reason with exit code %d and error: %s",
 			deprecatedErrCode, deprecatedErr.Error())
 	}
+}
 
-	currentChains, err := ioutil.AllCurrentAzureChains(pMgr.ioShim.Exec, util.IptablesDefaultWaitTime)
+// resetChains flushes the chains of the current naming generation, marks all but the base chains as stale, and configures the base chains.
+func (pMgr *PolicyManager) resetChains() error {
+	currentChains, err := ioutil.AllAzureChainsWithPrefix(pMgr.ioShim.Exec, util.IptablesDefaultWaitTime, pMgr.naming.AzureChain)
 	if err != nil {
 		return npmerrors.SimpleErrorWrapper("failed to get current chains for bootup", err)
 	}
 
 	klog.Infof("found %d current chains in the default iptables", len(currentChains))
 
-	// 2. cleanup old NPM chains, and configure base chains and their rules.
 	creator := pMgr.creatorForBootup(currentChains)
 	if err := restore(creator); err != nil {
 		return npmerrors.SimpleErrorWrapper("failed to run iptables-restore for bootup", err)
 	}
-
-	// 3. add/reposition the jump to AZURE-NPM
-	if err := pMgr.positionAzureChainJumpRule(); err != nil {
-		baseErrString := "failed to add/reposition jump from FORWARD chain to AZURE-NPM chain"
-		metrics.SendErrorLogAndMetric(util.IptmID, "error: %s with error: %s", baseErrString, err.Error())
-		return npmerrors.SimpleErrorWrapper(baseErrString, err) // we used to ignore this error in v1
-	}
 	return nil
 }
 
@@ -329,7 +365,7 @@ func (pMgr *PolicyManager) cleanupOtherIptables() error {
 	}
 
 	// 1.2. delete the jump to AZURE-NPM
-	errCode, err = pMgr.ignoreErrorsAndRunIPTablesCommand(removeDeprecatedJumpIgnoredErrors, util.IptablesDeletionFlag, jumpFromForwardToAzureChainArgs...)
+	errCode, err = pMgr.ignoreErrorsAndRunIPTablesCommand(removeDeprecatedJumpIgnoredErrors, util.IptablesDeletionFlag, pMgr.jumpFromForwardToAzureChainArgs()...)
 	if errCode == 0 {
 		deletedJumpRule = true
 		klog.Infof("[cleanup] deleted jump rule from FORWARD chain to AZURE-NPM chain")
@@ -340,7 +376,7 @@ func (pMgr *PolicyManager) cleanupOtherIptables() error {
 	}
 
 	// 2. get current chains
-	currentChains, err := ioutil.AllCurrentAzureChains(pMgr.ioShim.Exec, util.IptablesDefaultWaitTime)
+	currentChains, err := ioutil.AllAzureChainsWithPrefix(pMgr.ioShim.Exec, util.IptablesDefaultWaitTime, pMgr.naming.AzureChain)
 	if err != nil {
 		return npmerrors.SimpleErrorWrapper("[cleanup] failed to get current chains for bootup", err)
 	}
@@ -354,13 +390,13 @@ func (pMgr *PolicyManager) cleanupOtherIptables() error {
 
 	// 3.1. try to flush all chains at once
 	chains := make([]string, 0, len(currentChains))
-	_, hasAzureChain := currentChains[util.IptablesAzureChain]
+	_, hasAzureChain := currentChains[pMgr.naming.AzureChain]
 	if hasAzureChain {
 		// putting AZURE-NPM chain first is required for proper unit testing (for determinancy in destroying chains)
-		chains = append(chains, util.IptablesAzureChain)
+		chains = append(chains, pMgr.naming.AzureChain)
 	}
 	for chain := range currentChains {
-		if chain == util.IptablesAzureChain {
+		if chain == pMgr.naming.AzureChain {
 			// putting AZURE-NPM chain first is required for proper unit testing (for determinancy in destroying chains)
 			continue
 		}
@@ -375,8 +411,8 @@ func (pMgr *PolicyManager) cleanupOtherIptables() error {
 
 		// 3.2. if we failed to flush all chains, then try to flush and delete them one by one
 		var aggregateError error
-		if _, ok := currentChains[util.IptablesAzureChain]; ok {
-			_, err := pMgr.runIPTablesCommand(util.IptablesFlushFlag, util.IptablesAzureChain)
+		if _, ok := currentChains[pMgr.naming.AzureChain]; ok {
+			_, err := pMgr.runIPTablesCommand(util.IptablesFlushFlag, pMgr.naming.AzureChain)
 			aggregateError = err
 			if err != nil && !deletedJumpRule {
 				// fixes #3088
@@ -389,7 +425,7 @@ func (pMgr *PolicyManager) cleanupOtherIptables() error {
 		}
 
 		for chain := range currentChains {
-			if chain == util.IptablesAzureChain {
+			if chain == pMgr.naming.AzureChain {
 				// already flushed above
 				continue
 			}
@@ -455,7 +491,10 @@ func (pMgr *PolicyManager) creatorForCleanup(chains []string) *ioutil.FileCreato
 // - creates the jump rule from FORWARD chain to AZURE-NPM chain (if it does not exist) and makes sure it's after the jumps to KUBE-FORWARD & KUBE-SERVICES chains (if they exist).
 // - cleans up stale policy chains. It can be forced to stop this process if reconcileManager.forceLock() is called.
 func (pMgr *PolicyManager) reconcile() {
-	if err := pMgr.positionAzureChainJumpRule(); err != nil {
+	if pMgr.handoverPending.Load() {
+		// the jump is added when the handover completes
+		klog.Info("skipping reconcile of jump rule to Azure-NPM while waiting to complete the handover from the previous NPM")
+	} else if err := pMgr.positionAzureChainJumpRule(); err != nil {
 		msg := fmt.Sprintf("failed to reconcile jump rule to Azure-NPM due to %s", err.Error())
 		metrics.SendErrorLogAndMetric(util.IptmID, "error: %s", msg)
 		klog.Error(msg)
@@ -547,8 +586,9 @@ func (pMgr *PolicyManager) ignoreErrorsAndRunIPTablesCommand(ignored []*exitErro
 // Writes the restore file for bootup, and marks the following as stale: deprecated chains and old v2 policy chains.
 // This is a separate function to help with UTs.
 func (pMgr *PolicyManager) creatorForBootup(currentChains map[string]struct{}) *ioutil.FileCreator {
-	chainsToCreate := make([]string, 0, len(iptablesAzureChains))
-	for _, chain := range iptablesAzureChains {
+	chains := baseChains(pMgr.naming)
+	chainsToCreate := make([]string, 0, len(chains))
+	for _, chain := range chains {
 		_, exists := currentChains[chain]
 		if !exists {
 			chainsToCreate = append(chainsToCreate, chain)
@@ -566,31 +606,31 @@ func (pMgr *PolicyManager) creatorForBootup(currentChains map[string]struct{}) *
 	}
 
 	// add AZURE-NPM-INGRESS chain rules
-	ingressDropSpecs := []string{util.IptablesAppendFlag, util.IptablesAzureIngressChain, util.IptablesJumpFlag, util.IptablesDrop}
+	ingressDropSpecs := []string{util.IptablesAppendFlag, pMgr.naming.IngressChain, util.IptablesJumpFlag, util.IptablesDrop}
 	ingressDropSpecs = append(ingressDropSpecs, onMarkSpecs(util.IptablesAzureIngressDropMarkHex)...)
 	ingressDropSpecs = append(ingressDropSpecs, commentSpecs(fmt.Sprintf("DROP-ON-INGRESS-DROP-MARK-%s", util.IptablesAzureIngressDropMarkHex))...)
 	creator.AddLine("", nil, ingressDropSpecs...)
 
 	// add AZURE-NPM-INGRESS-ALLOW-MARK chain
-	markIngressAllowSpecs := []string{util.IptablesAppendFlag, util.IptablesAzureIngressAllowMarkChain}
+	markIngressAllowSpecs := []string{util.IptablesAppendFlag, pMgr.naming.IngressAllowMarkChain}
 	markIngressAllowSpecs = append(markIngressAllowSpecs, setMarkSpecs(util.IptablesAzureIngressAllowMarkHex)...)
 	markIngressAllowSpecs = append(markIngressAllowSpecs, commentSpecs(fmt.Sprintf("SET-INGRESS-ALLOW-MARK-%s", util.IptablesAzureIngressAllowMarkHex))...)
 	creator.AddLine("", nil, markIngressAllowSpecs...)
-	creator.AddLine("", nil, util.IptablesAppendFlag, util.IptablesAzureIngressAllowMarkChain, util.IptablesJumpFlag, util.IptablesAzureEgressChain)
+	creator.AddLine("", nil, util.IptablesAppendFlag, pMgr.naming.IngressAllowMarkChain, util.IptablesJumpFlag, pMgr.naming.EgressChain)
 
 	// add AZURE-NPM-EGRESS chain rules
-	egressDropSpecs := []string{util.IptablesAppendFlag, util.IptablesAzureEgressChain, util.IptablesJumpFlag, util.IptablesDrop}
+	egressDropSpecs := []string{util.IptablesAppendFlag, pMgr.naming.EgressChain, util.IptablesJumpFlag, util.IptablesDrop}
 	egressDropSpecs = append(egressDropSpecs, onMarkSpecs(util.IptablesAzureEgressDropMarkHex)...)
 	egressDropSpecs = append(egressDropSpecs, commentSpecs(fmt.Sprintf("DROP-ON-EGRESS-DROP-MARK-%s", util.IptablesAzureEgressDropMarkHex))...)
 	creator.AddLine("", nil, egressDropSpecs...)
 
-	jumpOnIngressMatchSpecs := []string{util.IptablesAppendFlag, util.IptablesAzureEgressChain, util.IptablesJumpFlag, util.IptablesAzureAcceptChain}
+	jumpOnIngressMatchSpecs := []string{util.IptablesAppendFlag, pMgr.naming.EgressChain, util.IptablesJumpFlag, pMgr.naming.AcceptChain}
 	jumpOnIngressMatchSpecs = append(jumpOnIngressMatchSpecs, onMarkSpecs(util.IptablesAzureIngressAllowMarkHex)...)
 	jumpOnIngressMatchSpecs = append(jumpOnIngressMatchSpecs, commentSpecs(fmt.Sprintf("ACCEPT-ON-INGRESS-ALLOW-MARK-%s", util.IptablesAzureIngressAllowMarkHex))...)
 	creator.AddLine("", nil, jumpOnIngressMatchSpecs...)
 
 	// add AZURE-NPM-ACCEPT chain rules
-	creator.AddLine("", nil, util.IptablesAppendFlag, util.IptablesAzureAcceptChain, util.IptablesJumpFlag, util.IptablesAccept)
+	creator.AddLine("", nil, util.IptablesAppendFlag, pMgr.naming.AcceptChain, util.IptablesJumpFlag, util.IptablesAccept)
 	creator.AddLine("", nil, util.IptablesRestoreCommit)
 	return creator
 }
@@ -600,7 +640,7 @@ func (pMgr *PolicyManager) creatorForBootup(currentChains map[string]struct{}) *
 // option 2) jump to AZURE-NPM chain should be after the jump to KUBE-SERVICES chain
 func (pMgr *PolicyManager) positionAzureChainJumpRule() error {
 	// get the line number for the azure jump
-	azureChainLineNum, err := pMgr.chainLineNumber(util.IptablesAzureChain)
+	azureChainLineNum, err := pMgr.chainLineNumber(pMgr.naming.AzureChain)
 	if err != nil {
 		baseErrString := "failed to get index of jump from FORWARD chain to AZURE-NPM chain"
 		metrics.SendErrorLogAndMetric(util.IptmID, "error: %s: %s", baseErrString, err.Error())
@@ -637,7 +677,7 @@ func (pMgr *PolicyManager) positionAzureChainJumpRule() error {
 	// delete the azure jump if it exists and update the target index
 	if azureChainLineNum != 0 {
 		metrics.SendErrorLogAndMetric(util.IptmID, "Info: Reconciler deleting and re-adding jump from FORWARD chain to AZURE-NPM chain table.")
-		if deleteErrCode, deleteErr := pMgr.runIPTablesCommand(util.IptablesDeletionFlag, jumpFromForwardToAzureChainArgs...); deleteErr != nil {
+		if deleteErrCode, deleteErr := pMgr.runIPTablesCommand(util.IptablesDeletionFlag, pMgr.jumpFromForwardToAzureChainArgs()...); deleteErr != nil {
 			baseErrString := "failed to delete jump from FORWARD chain to AZURE-NPM chain"
 			metrics.SendErrorLogAndMetric(util.IptmID, "error: %s with error code %d and error %s", baseErrString, deleteErrCode, deleteErr.Error())
 			return npmerrors.SimpleErrorWrapper(baseErrString, deleteErr)
@@ -656,10 +696,10 @@ func (pMgr *PolicyManager) positionAzureChainJumpRule() error {
 	var args []string
 	if targetIndex == 1 {
 		// when no index is provided, index of 1 is implied
-		args = jumpFromForwardToAzureChainArgs
+		args = pMgr.jumpFromForwardToAzureChainArgs()
 	} else {
 		args = []string{util.IptablesForwardChain, strconv.Itoa(targetIndex)}
-		args = append(args, jumpToAzureChainArgs...)
+		args = append(args, pMgr.jumpToAzureChainArgs()...)
 	}
 	if insertErrCode, err := pMgr.runIPTablesCommand(util.IptablesInsertionFlag, args...); err != nil {
 		baseErrString := "failed to insert jump from FORWARD chain to AZURE-NPM chain"
@@ -0,0 +1,207 @@
+package policies
+
+// This file contains code for handing over the dataplane from a previous NPM without a gap in enforcement
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/util"
+	npmerrors "github.com/Azure/azure-container-networking/npm/util/errors"
+	"github.com/Azure/azure-container-networking/npm/util/ioutil"
+	"k8s.io/klog"
+)
+
+/*
+bootupForHandover replaces steps 1-3 of bootup() when GracefulHandover is enabled.
+The previous NPM (v1 or v2) keeps enforcing its policies until the new chains are complete:
+
+1. Find the naming generation which the FORWARD chain jumps to (if any) and switch to the other one.
+2. Cleanup leftover chains of the new generation, and configure its base chains and their rules (step 2 of bootup()).
+The deprecated jump from NPM v1 only refers to the first generation, so it's deleted (step 1 of bootup()) only if that is the new generation.
+3. Leave the FORWARD chain alone. CompleteHandover() swaps the jump once the controllers have synced.
+
+Must be called before any ipsets are created so that they're named after the new generation.
+*/
+func (pMgr *PolicyManager) bootupForHandover() error {
+	previous, err := pMgr.referencedNamingGeneration()
+	if err != nil {
+		return npmerrors.SimpleErrorWrapper("failed to determine the naming generation of the previous NPM", err)
+	}
+	next := previous.Other()
+	klog.Infof("booting up iptables Azure chains for a handover from naming generation %s to %s", previous, next)
+	pMgr.setNaming(util.NewNaming(next))
+
+	if next == util.NamingGenerationA {
+		pMgr.deleteDeprecatedJump()
+	}
+
+	if err := pMgr.resetChains(); err != nil {
+		return err
+	}
+
+	pMgr.handoverPending.Store(true)
+	return nil
+}
+
+// setNaming switches the chain names in use, including the base chains which are never stale.
+func (pMgr *PolicyManager) setNaming(naming *util.Naming) {
+	pMgr.naming = naming
+	pMgr.staleChains.setBaseChains(baseChains(naming))
+}
+
+// referencedNamingGeneration returns the generation of the AZURE-NPM chain which the FORWARD chain jumps to.
+// Returns the second generation if there is no jump so that NPM boots up with the first generation.
+func (pMgr *PolicyManager) referencedNamingGeneration() (util.NamingGeneration, error) {
+	for _, g := range []util.NamingGeneration{util.NamingGenerationB, util.NamingGenerationA} {
+		lineNum, err := pMgr.chainLineNumber(g.ChainPrefix())
+		if err != nil {
+			return g, err
+		}
+		if lineNum != 0 {
+			return g, nil
+		}
+	}
+	return util.NamingGenerationB, nil
+}
+
+/*
+CompleteHandover activates the chains built by a graceful handover bootup and cleans up the chains of the previous naming generation.
+
+1. Via one iptables-restore --noflush, insert the jump to the current AZURE-NPM chain in place of the first jump to the previous AZURE-NPM chain,
+and delete all jumps to the previous AZURE-NPM chain (including the deprecated jump from NPM v1).
+2. Reposition the jump if needed (see positionAzureChainJumpRule()).
+3. Flush the chains of the previous generation, and delete them in the background.
+
+The ipsets of the previous generation are unreferenced afterwards.
+It is a no-op if bootup was not a graceful handover or the handover is already complete.
+*/
+func (pMgr *PolicyManager) CompleteHandover() error {
+	if !pMgr.handoverPending.Load() {
+		return nil
+	}
+
+	pMgr.reconcileManager.forceLock()
+	defer pMgr.reconcileManager.forceUnlock()
+
+	previous := pMgr.naming.Generation.Other()
+	klog.Infof("completing handover of iptables Azure chains from naming generation %s to %s", previous, pMgr.naming.Generation)
+
+	// 1. swap the jumps
+	lineNums, err := pMgr.jumpLineNumbers(previous.ChainPrefix())
+	if err != nil {
+		return npmerrors.SimpleErrorWrapper("failed to get the jumps from FORWARD chain to the previous AZURE-NPM chain", err)
+	}
+	if len(lineNums) > 0 {
+		if err := restore(pMgr.creatorForHandover(lineNums)); err != nil {
+			return npmerrors.SimpleErrorWrapper("failed to run iptables-restore to swap the jump from FORWARD chain", err)
+		}
+	}
+	pMgr.handoverPending.Store(false)
+
+	// 2. reconcile would also reposition the jump, but do it now in case there was no previous jump
+	if err := pMgr.positionAzureChainJumpRule(); err != nil {
+		metrics.SendErrorLogAndMetric(util.IptmID, "error: failed to add/reposition jump from FORWARD chain to AZURE-NPM chain after handover: %s", err.Error())
+	}
+
+	// 3. cleanup the previous generation
+	return pMgr.flushChainsOfGeneration(previous)
+}
+
+// cleanupNamingGeneration is called by bootup without GracefulHandover, which always uses the first naming generation.
+// If a previous NPM had handed over to the second generation, it deletes the jumps to it and cleans up its chains.
+// Its ipsets are destroyed by the dataplane once the chains no longer reference them.
+func (pMgr *PolicyManager) cleanupNamingGeneration(g util.NamingGeneration) error {
+	lineNums, err := pMgr.jumpLineNumbers(g.ChainPrefix())
+	if err != nil {
+		return err
+	}
+	if len(lineNums) == 0 {
+		return nil
+	}
+
+	klog.Infof("deleting %d jumps from FORWARD chain to chains of naming generation %s", len(lineNums), g)
+	creator := pMgr.newCreatorWithChains(nil)
+	for i := len(lineNums) - 1; i >= 0; i-- {
+		creator.AddLine("", nil, util.IptablesDeletionFlag, util.IptablesForwardChain, strconv.Itoa(lineNums[i]))
+	}
+	creator.AddLine("", nil, util.IptablesRestoreCommit)
+	if err := restore(creator); err != nil {
+		return npmerrors.SimpleErrorWrapper("failed to delete jumps from FORWARD chain", err)
+	}
+	return pMgr.flushChainsOfGeneration(g)
+}
+
+// flushChainsOfGeneration flushes all chains of a naming generation which isn't in use, and marks them as stale so they're deleted in the background.
+// The caller must lock the reconcileManager.
+func (pMgr *PolicyManager) flushChainsOfGeneration(g util.NamingGeneration) error {
+	currentChains, err := ioutil.AllAzureChainsWithPrefix(pMgr.ioShim.Exec, util.IptablesDefaultWaitTime, g.ChainPrefix())
+	if err != nil {
+		return npmerrors.SimpleErrorWrapper("failed to get the chains of naming generation "+g.String(), err)
+	}
+	if len(currentChains) == 0 {
+		return nil
+	}
+
+	chains := make([]string, 0, len(currentChains))
+	for chain := range currentChains {
+		chains = append(chains, chain)
+	}
+	// sorted for determinism in UTs
+	sort.Strings(chains)
+	klog.Infof("cleaning up %d chains of naming generation %s", len(chains), g)
+	if err := restore(pMgr.creatorForCleanup(chains)); err != nil {
+		return npmerrors.SimpleErrorWrapper("failed to flush the chains of naming generation "+g.String(), err)
+	}
+	for _, chain := range chains {
+		pMgr.staleChains.add(chain)
+	}
+	return nil
+}
+
+// creatorForHandover writes the restore file which swaps the jumps at lineNums in the FORWARD chain for a jump to the current AZURE-NPM chain.
+// lineNums must be in increasing order.
+func (pMgr *PolicyManager) creatorForHandover(lineNums []int) *ioutil.FileCreator {
+	creator := pMgr.newCreatorWithChains(nil)
+	insertSpecs := []string{util.IptablesInsertionFlag, util.IptablesForwardChain, strconv.Itoa(lineNums[0])}
+	insertSpecs = append(insertSpecs, pMgr.jumpToAzureChainArgs()...)
+	creator.AddLine("", nil, insertSpecs...)
+	// the insert shifts the previous jumps down by one. delete from the bottom up so that line numbers don't change in between
+	for i := len(lineNums) - 1; i >= 0; i-- {
+		creator.AddLine("", nil, util.IptablesDeletionFlag, util.IptablesForwardChain, strconv.Itoa(lineNums[i]+1))
+	}
+	creator.AddLine("", nil, util.IptablesRestoreCommit)
+	return creator
+}
+
+// jumpLineNumbers returns the line numbers of all jumps from FORWARD chain to the chain, in increasing order.
+func (pMgr *PolicyManager) jumpLineNumbers(chain string) ([]int, error) {
+	listForwardEntriesCommand := pMgr.ioShim.Exec.Command(util.Iptables, listForwardEntriesArgs...)
+	grepCommand := pMgr.ioShim.Exec.Command(ioutil.Grep, chain)
+	searchResults, gotMatches, err := ioutil.PipeCommandToGrep(listForwardEntriesCommand, grepCommand)
+	if err != nil {
+		return nil, npmerrors.SimpleErrorWrapper(fmt.Sprintf("failed to list jumps from FORWARD chain to %s chain", chain), err)
+	}
+	if !gotMatches {
+		return nil, nil
+	}
+
+	var lineNums []int
+	for _, line := range strings.Split(string(searchResults), "\n") {
+		// line of the form "1    AZURE-NPM  all  --  0.0.0.0/0            0.0.0.0/0            ctstate NEW"
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[1] != chain {
+			continue
+		}
+		lineNum, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, npmerrors.SimpleErrorWrapper(fmt.Sprintf("unable to parse line number. line: [%s]", line), errNoLineNumber)
+		}
+		lineNums = append(lineNums, lineNum)
+	}
+	sort.Ints(lineNums)
+	return lineNums, nil
+}
@@ -0,0 +1,163 @@
+package policies
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/common"
+	dptestutils "github.com/Azure/azure-container-networking/npm/pkg/dataplane/testutils"
+	"github.com/Azure/azure-container-networking/npm/util"
+	testutils "github.com/Azure/azure-container-networking/test/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBootupForHandover(t *testing.T) {
+	tests := []struct {
+		name           string
+		calls          []testutils.TestCmd
+		wantGeneration util.NamingGeneration
+	}{
+		{
+			name: "v1 or v2 prior",
+			calls: []testutils.TestCmd{
+				{Cmd: listLineNumbersCommandStrings, PipedToCommand: true},
+				{Cmd: []string{"grep", "AZURE-NPB"}, ExitCode: 1},
+				{Cmd: listLineNumbersCommandStrings, PipedToCommand: true},
+				{Cmd: []string{"grep", "AZURE-NPM"}, Stdout: "1    AZURE-NPM  all  --  0.0.0.0/0            0.0.0.0/0    ..."},
+				// the deprecated jump is left in place
+				{Cmd: []string{"iptables-nft", "-w", "60", "-t", "filter", "-n", "-L"}, PipedToCommand: true},
+				{Cmd: []string{"grep", "Chain AZURE-NPB"}, ExitCode: 1},
+				fakeIPTablesRestoreCommand,
+			},
+			wantGeneration: util.NamingGenerationB,
+		},
+		{
+			name: "second generation prior",
+			calls: []testutils.TestCmd{
+				{Cmd: listLineNumbersCommandStrings, PipedToCommand: true},
+				{Cmd: []string{"grep", "AZURE-NPB"}, Stdout: "1    AZURE-NPB  all  --  0.0.0.0/0            0.0.0.0/0    ..."},
+				{Cmd: []string{"iptables-nft", "-w", "60", "-D", "FORWARD", "-j", "AZURE-NPM"}, ExitCode: 2}, //nolint // AZURE-NPM chain didn't exist
+				{Cmd: []string{"iptables-nft", "-w", "60", "-t", "filter", "-n", "-L"}, PipedToCommand: true},
+				{Cmd: []string{"grep", "Chain AZURE-NPM"}, Stdout: grepOutputAzureChainsWithoutPolicies},
+				fakeIPTablesRestoreCommand,
+			},
+			wantGeneration: util.NamingGenerationA,
+		},
+		{
+			name: "no NPM prior",
+			calls: []testutils.TestCmd{
+				{Cmd: listLineNumbersCommandStrings, PipedToCommand: true},
+				{Cmd: []string{"grep", "AZURE-NPB"}, ExitCode: 1},
+				{Cmd: listLineNumbersCommandStrings, PipedToCommand: true},
+				{Cmd: []string{"grep", "AZURE-NPM"}, ExitCode: 1},
+				{Cmd: []string{"iptables-nft", "-w", "60", "-D", "FORWARD", "-j", "AZURE-NPM"}, ExitCode: 2}, //nolint // AZURE-NPM chain didn't exist
+				{Cmd: []string{"iptables-nft", "-w", "60", "-t", "filter", "-n", "-L"}, PipedToCommand: true},
+				{Cmd: []string{"grep", "Chain AZURE-NPM"}, ExitCode: 1},
+				fakeIPTablesRestoreCommand,
+			},
+			wantGeneration: util.NamingGenerationA,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ioshim := common.NewMockIOShim(tt.calls)
+			defer ioshim.VerifyCalls(t, tt.calls)
+			pMgr := NewPolicyManager(ioshim, ipsetConfig)
+			require.NoError(t, pMgr.bootupForHandover())
+			require.Equal(t, tt.wantGeneration, pMgr.Naming().Generation)
+			require.Equal(t, tt.wantGeneration.ChainPrefix(), pMgr.Naming().AzureChain)
+			require.True(t, pMgr.handoverPending.Load())
+		})
+	}
+}
+
+func TestReconcileSkipsJumpDuringHandover(t *testing.T) {
+	ioshim := common.NewMockIOShim(nil)
+	defer ioshim.VerifyCalls(t, nil)
+	pMgr := NewPolicyManager(ioshim, ipsetConfig)
+	pMgr.handoverPending.Store(true)
+	pMgr.reconcile()
+}
+
+func TestCompleteHandoverFromV1(t *testing.T) {
+	calls := []testutils.TestCmd{
+		// the deprecated jump from v1 and the current jump
+		{Cmd: listLineNumbersCommandStrings, PipedToCommand: true},
+		{
+			Cmd: []string{"grep", "AZURE-NPM"},
+			Stdout: `2    AZURE-NPM  all  --  0.0.0.0/0            0.0.0.0/0
+4    AZURE-NPM  all  --  0.0.0.0/0            0.0.0.0/0            ctstate NEW
+`,
+		},
+		fakeIPTablesRestoreCommand,
+		// reposition the jump to be first
+		{Cmd: listLineNumbersCommandStrings, PipedToCommand: true},
+		{Cmd: []string{"grep", "AZURE-NPB"}, Stdout: "2    AZURE-NPB  all  --  0.0.0.0/0            0.0.0.0/0            ctstate NEW"},
+		{Cmd: []string{"iptables-nft", "-w", "60", "-D", "FORWARD", "-j", "AZURE-NPB", "-m", "conntrack", "--ctstate", "NEW"}},
+		{Cmd: []string{"iptables-nft", "-w", "60", "-I", "FORWARD", "-j", "AZURE-NPB", "-m", "conntrack", "--ctstate", "NEW"}},
+		// cleanup the v1 chains
+		{Cmd: []string{"iptables-nft", "-w", "60", "-t", "filter", "-n", "-L"}, PipedToCommand: true},
+		{Cmd: []string{"grep", "Chain AZURE-NPM"}, Stdout: grepOutputAzureV1Chains},
+		fakeIPTablesRestoreCommand,
+	}
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	pMgr := NewPolicyManager(ioshim, ipsetConfig)
+	pMgr.setNaming(util.NewNaming(util.NamingGenerationB))
+	pMgr.handoverPending.Store(true)
+
+	require.NoError(t, pMgr.CompleteHandover())
+	require.False(t, pMgr.handoverPending.Load())
+	assertStaleChainsContain(t, pMgr.staleChains,
+		"AZURE-NPM",
+		"AZURE-NPM-INGRESS",
+		"AZURE-NPM-INGRESS-DROPS",
+		"AZURE-NPM-INGRESS-TO",
+		"AZURE-NPM-INGRESS-PORTS",
+		"AZURE-NPM-EGRESS",
+		"AZURE-NPM-EGRESS-DROPS",
+		"AZURE-NPM-EGRESS-FROM",
+		"AZURE-NPM-EGRESS-PORTS",
+		"AZURE-NPM-ACCEPT",
+	)
+
+	// no-op once complete
+	require.NoError(t, pMgr.CompleteHandover())
+}
+
+func TestCreatorForHandover(t *testing.T) {
+	pMgr := NewPolicyManager(common.NewMockIOShim(nil), ipsetConfig)
+	pMgr.setNaming(util.NewNaming(util.NamingGenerationB))
+	creator := pMgr.creatorForHandover([]int{2, 4})
+	expectedLines := []string{
+		"*filter",
+		"-I FORWARD 2 -j AZURE-NPB -m conntrack --ctstate NEW",
+		"-D FORWARD 5",
+		"-D FORWARD 3",
+		"COMMIT",
+		"",
+	}
+	dptestutils.AssertEqualLines(t, expectedLines, strings.Split(creator.ToString(), "\n"))
+}
+
+func TestCleanupNamingGeneration(t *testing.T) {
+	calls := []testutils.TestCmd{
+		{Cmd: listLineNumbersCommandStrings, PipedToCommand: true},
+		{Cmd: []string{"grep", "AZURE-NPB"}, Stdout: "3    AZURE-NPB  all  --  0.0.0.0/0            0.0.0.0/0            ctstate NEW"},
+		fakeIPTablesRestoreCommand,
+		{Cmd: []string{"iptables-nft", "-w", "60", "-t", "filter", "-n", "-L"}, PipedToCommand: true},
+		{
+			Cmd: []string{"grep", "Chain AZURE-NPB"},
+			Stdout: `Chain AZURE-NPB (1 references)
+Chain AZURE-NPB-INGRESS (1 references)
+`,
+		},
+		fakeIPTablesRestoreCommand,
+	}
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	pMgr := NewPolicyManager(ioshim, ipsetConfig)
+	require.NoError(t, pMgr.cleanupNamingGeneration(util.NamingGenerationB))
+	assertStaleChainsContain(t, pMgr.staleChains, "AZURE-NPB", "AZURE-NPB-INGRESS")
+}
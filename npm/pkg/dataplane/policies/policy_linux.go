@@ -36,12 +36,12 @@ func (networkPolicy *NPMNetworkPolicy) hasIngressAndEgress() (hasIngress, hasEgr
 	return
 }
 
-func (networkPolicy *NPMNetworkPolicy) egressChainName() string {
-	return networkPolicy.chainName(util.IptablesAzureEgressPolicyChainPrefix)
+func (networkPolicy *NPMNetworkPolicy) egressChainName(naming *util.Naming) string {
+	return networkPolicy.chainName(naming.EgressPolicyChainPrefix)
 }
 
-func (networkPolicy *NPMNetworkPolicy) ingressChainName() string {
-	return networkPolicy.chainName(util.IptablesAzureIngressPolicyChainPrefix)
+func (networkPolicy *NPMNetworkPolicy) ingressChainName(naming *util.Naming) string {
+	return networkPolicy.chainName(naming.IngressPolicyChainPrefix)
 }
 
 func (networkPolicy *NPMNetworkPolicy) chainName(prefix string) string {
@@ -91,13 +91,13 @@ func (info SetInfo) comment() string {
 	return "!" + name
 }
 
-func (info SetInfo) matchSetSpecs(matchString string) []string {
+func (info SetInfo) matchSetSpecs(naming *util.Naming, matchString string) []string {
 	specs := make([]string, 0, maxLengthForMatchSetSpecs)
 	specs = append(specs, util.IptablesModuleFlag, util.IptablesSetModuleFlag)
 	if !info.Included {
 		specs = append(specs, util.IptablesNotFlag)
 	}
-	hashedSetName := info.IPSet.GetHashedName()
+	hashedSetName := info.IPSet.GetHashedNameFor(naming)
 	specs = append(specs, util.IptablesMatchSetFlag, hashedSetName, matchString)
 	return specs
 }
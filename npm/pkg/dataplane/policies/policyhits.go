@@ -0,0 +1,14 @@
+package policies
+
+// PolicyHitKey identifies the rules of a policy with the same direction and verdict.
+type PolicyHitKey struct {
+	PolicyKey string
+	Direction Direction
+	Verdict   Verdict
+}
+
+// PolicyHitCounts are the packets and bytes matched by rules, as counted by the kernel since the rules were programmed.
+type PolicyHitCounts struct {
+	Packets uint64
+	Bytes   uint64
+}
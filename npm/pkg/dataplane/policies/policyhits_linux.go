@@ -0,0 +1,112 @@
+package policies
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/npm/util"
+	npmerrors "github.com/Azure/azure-container-networking/npm/util/errors"
+	"k8s.io/klog"
+)
+
+// GetPolicyHits reads the counters of the rules in the policy chains and sums them by policy, direction and verdict.
+// Counters restart from zero when a policy's chain is reprogrammed, e.g. on an update of the policy.
+func (pMgr *PolicyManager) GetPolicyHits() (map[PolicyHitKey]PolicyHitCounts, error) {
+	pMgr.policyMap.RLock()
+	chainOwners := make(map[string]string, len(pMgr.chainNameOwner))
+	for chain, policyKey := range pMgr.chainNameOwner {
+		chainOwners[chain] = policyKey
+	}
+	pMgr.policyMap.RUnlock()
+
+	if len(chainOwners) == 0 {
+		return map[PolicyHitKey]PolicyHitCounts{}, nil
+	}
+
+	cmd := pMgr.ioShim.Exec.Command(util.IptablesSave, util.IptablesCountersFlag, util.IptablesTableFlag, util.IptablesFilterTable)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, npmerrors.SimpleErrorWrapper("failed to save iptables with counters", err)
+	}
+	return parsePolicyHits(pMgr.naming, output, chainOwners), nil
+}
+
+// parsePolicyHits parses iptables-save output with counters, i.e. lines like:
+// [10:840] -A AZURE-NPM-INGRESS-123 -j AZURE-NPM-INGRESS-ALLOW-MARK -m set --match-set ...
+// Only rules of the chains in chainOwners are counted.
+func parsePolicyHits(naming *util.Naming, output []byte, chainOwners map[string]string) map[PolicyHitKey]PolicyHitCounts {
+	hits := make(map[PolicyHitKey]PolicyHitCounts)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), 1024*1024) //nolint:gomnd // rules with many sets can be long
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "[") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[1] != util.IptablesAppendFlag {
+			continue
+		}
+		policyKey, ok := chainOwners[fields[2]]
+		if !ok {
+			continue
+		}
+		counts, ok := parseCounters(fields[0])
+		if !ok {
+			klog.Warningf("[PolicyManager] ignoring unexpected counters in iptables-save line: %s", line)
+			continue
+		}
+
+		direction := Egress
+		if strings.HasPrefix(fields[2], naming.IngressPolicyChainPrefix) {
+			direction = Ingress
+		}
+		verdict, ok := ruleVerdict(naming, fields[3:])
+		if !ok {
+			continue
+		}
+
+		key := PolicyHitKey{PolicyKey: policyKey, Direction: direction, Verdict: verdict}
+		total := hits[key]
+		total.Packets += counts.Packets
+		total.Bytes += counts.Bytes
+		hits[key] = total
+	}
+	return hits
+}
+
+// parseCounters parses "[packets:bytes]".
+func parseCounters(s string) (PolicyHitCounts, bool) {
+	packets, bytesCount, ok := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"), ":")
+	if !ok {
+		return PolicyHitCounts{}, false
+	}
+	p, err := strconv.ParseUint(packets, 10, 64)
+	if err != nil {
+		return PolicyHitCounts{}, false
+	}
+	b, err := strconv.ParseUint(bytesCount, 10, 64)
+	if err != nil {
+		return PolicyHitCounts{}, false
+	}
+	return PolicyHitCounts{Packets: p, Bytes: b}, true
+}
+
+// ruleVerdict returns the verdict of a rule written by writeNetworkPolicyRules:
+// allowed rules jump to the allow chains and dropped rules set the drop mark.
+func ruleVerdict(naming *util.Naming, specs []string) (Verdict, bool) {
+	for i := 0; i < len(specs)-1; i++ {
+		if specs[i] != util.IptablesJumpFlag {
+			continue
+		}
+		switch specs[i+1] {
+		case naming.IngressAllowMarkChain, naming.AcceptChain:
+			return Allowed, true
+		case util.IptablesMark:
+			return Dropped, true
+		}
+	}
+	return "", false
+}
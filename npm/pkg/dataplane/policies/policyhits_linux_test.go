@@ -0,0 +1,62 @@
+package policies
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/common"
+	testutils "github.com/Azure/azure-container-networking/test/utils"
+	"github.com/stretchr/testify/require"
+)
+
+const iptablesSaveWithCounters = `# Generated by iptables-nft-save v1.8.4 on Mon Jan  5 10:00:00 2026
+*filter
+:AZURE-NPM-INGRESS - [0:0]
+:AZURE-NPM-INGRESS-123 - [0:0]
+:AZURE-NPM-EGRESS-456 - [0:0]
+:AZURE-NPM-INGRESS-789 - [0:0]
+[50:4000] -A AZURE-NPM-INGRESS -j AZURE-NPM-INGRESS-123 -m set --match-set azure-npm-1 dst
+[10:840] -A AZURE-NPM-INGRESS-123 -j AZURE-NPM-INGRESS-ALLOW-MARK -p TCP --dport 80 -m set --match-set azure-npm-2 src -m comment --comment "ALLOW-FROM-..."
+[5:420] -A AZURE-NPM-INGRESS-123 -j AZURE-NPM-INGRESS-ALLOW-MARK -p TCP --dport 81 -m set --match-set azure-npm-3 src -m comment --comment "ALLOW-FROM-..."
+[7:588] -A AZURE-NPM-INGRESS-123 -j MARK --set-xmark 0x4000/0xffffffff -m comment --comment "DROP-ALL"
+[3:252] -A AZURE-NPM-EGRESS-456 -j AZURE-NPM-ACCEPT -m comment --comment "ALLOW-ALL"
+[1:84] -A AZURE-NPM-INGRESS-789 -j AZURE-NPM-INGRESS-ALLOW-MARK
+COMMIT
+`
+
+func TestParsePolicyHits(t *testing.T) {
+	chainOwners := map[string]string{
+		"AZURE-NPM-INGRESS-123": "x/web",
+		"AZURE-NPM-EGRESS-456":  "x/egress",
+	}
+	require.Equal(t, map[PolicyHitKey]PolicyHitCounts{
+		{PolicyKey: "x/web", Direction: Ingress, Verdict: Allowed}:   {Packets: 15, Bytes: 1260},
+		{PolicyKey: "x/web", Direction: Ingress, Verdict: Dropped}:   {Packets: 7, Bytes: 588},
+		{PolicyKey: "x/egress", Direction: Egress, Verdict: Allowed}: {Packets: 3, Bytes: 252},
+	}, parsePolicyHits(namingA, []byte(iptablesSaveWithCounters), chainOwners))
+}
+
+func TestGetPolicyHits(t *testing.T) {
+	calls := []testutils.TestCmd{
+		{Cmd: []string{"iptables-nft-save", "-c", "-t", "filter"}, Stdout: iptablesSaveWithCounters},
+	}
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	pMgr := NewPolicyManager(ioshim, ipsetConfig)
+	pMgr.chainNameOwner["AZURE-NPM-EGRESS-456"] = "x/egress"
+
+	hits, err := pMgr.GetPolicyHits()
+	require.NoError(t, err)
+	require.Equal(t, map[PolicyHitKey]PolicyHitCounts{
+		{PolicyKey: "x/egress", Direction: Egress, Verdict: Allowed}: {Packets: 3, Bytes: 252},
+	}, hits)
+}
+
+func TestGetPolicyHitsWithoutPolicies(t *testing.T) {
+	ioshim := common.NewMockIOShim(nil)
+	defer ioshim.VerifyCalls(t, nil)
+	pMgr := NewPolicyManager(ioshim, ipsetConfig)
+
+	hits, err := pMgr.GetPolicyHits()
+	require.NoError(t, err)
+	require.Empty(t, hits)
+}
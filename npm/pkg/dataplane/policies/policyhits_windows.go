@@ -0,0 +1,10 @@
+package policies
+
+import "errors"
+
+var ErrPolicyHitsUnsupported = errors.New("policy hit counters are only supported on Linux")
+
+// GetPolicyHits is unsupported in Windows since HNS does not count the packets matched by ACLs.
+func (pMgr *PolicyManager) GetPolicyHits() (map[PolicyHitKey]PolicyHitCounts, error) {
+	return nil, ErrPolicyHitsUnsupported
+}
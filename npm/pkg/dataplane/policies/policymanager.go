@@ -3,6 +3,7 @@ package policies
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 
 	"github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/npm/metrics"
@@ -39,6 +40,9 @@ type PolicyManagerCfg struct {
 	// The zero value is valid.
 	// A NetworkPolicy's ACLs are always in the same batch, and there will be at least one NetworkPolicy per batch.
 	MaxBatchedACLsPerPod int
+	// GracefulHandover only affects Linux. When true, bootup builds the chains under the naming generation not in use
+	// and leaves the previous NPM's chains enforced until CompleteHandover() is called.
+	GracefulHandover bool
 }
 
 type PolicyMap struct {
@@ -65,6 +69,11 @@ type PolicyManager struct {
 	// chainNameOwner maps an enforcement chain name to the policy key that owns it, so two
 	// distinct policies can't resolve to the same chain. Only used on Linux.
 	chainNameOwner map[string]string
+	// handoverPending is true between a graceful handover bootup and CompleteHandover().
+	// Reconciling must not add the jump to the AZURE-NPM chain meanwhile. Only used on Linux.
+	handoverPending atomic.Bool
+	// naming holds the chain and ipset names of the generation in use. Only used on Linux.
+	naming *util.Naming
 	*PolicyManagerCfg
 }
 
@@ -79,6 +88,7 @@ func NewPolicyManager(ioShim *common.IOShim, cfg *PolicyManagerCfg) *PolicyManag
 			releaseLockSignal: make(chan struct{}, 1),
 		},
 		chainNameOwner:   make(map[string]string),
+		naming:           util.NewNaming(util.NamingGenerationA),
 		PolicyManagerCfg: cfg,
 	}
 }
@@ -92,6 +102,12 @@ func (pMgr *PolicyManager) commitChainNames(chainOwners map[string]string) {
 	}
 }
 
+// Naming returns the naming generation the policy manager settled on during bootup.
+// The ipset manager must create its sets under the same generation.
+func (pMgr *PolicyManager) Naming() *util.Naming {
+	return pMgr.naming
+}
+
 func (pMgr *PolicyManager) ResetEndpoint(epID string) error {
 	if util.IsWindowsDP() {
 		return pMgr.bootup([]string{epID})
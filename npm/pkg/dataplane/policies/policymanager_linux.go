@@ -44,7 +44,7 @@ Known errors that we should retry on:
 
 func (pMgr *PolicyManager) addPolicies(networkPolicies []*NPMNetworkPolicy, _ map[string]string) error {
 	// 1. Add rules for the network policies and activate NPM (if necessary).
-	chainsToCreate := chainNames(networkPolicies)
+	chainsToCreate := chainNames(pMgr.naming, networkPolicies)
 	creator := pMgr.creatorForNewNetworkPolicies(chainsToCreate, networkPolicies)
 
 	// Stop reconciling so we don't contend for iptables, and so reconcile doesn't delete chainsToCreate.
@@ -67,7 +67,7 @@ func (pMgr *PolicyManager) addPolicies(networkPolicies []*NPMNetworkPolicy, _ ma
 }
 
 func (pMgr *PolicyManager) removePolicy(networkPolicy *NPMNetworkPolicy, _ map[string]string) error {
-	chainsToDelete := chainNames([]*NPMNetworkPolicy{networkPolicy})
+	chainsToDelete := chainNames(pMgr.naming, []*NPMNetworkPolicy{networkPolicy})
 	creator := pMgr.creatorForRemovingPolicies(chainsToDelete)
 
 	// Stop reconciling so we don't contend for iptables, and so we don't update the staleChains at the same time as reconcile()
@@ -110,7 +110,7 @@ func (pMgr *PolicyManager) creatorForRemovingPolicies(allChainNames []string) *i
 	creator := pMgr.newCreatorWithChains(nil)
 	// 1. Deactivate NPM (if necessary).
 	if pMgr.isLastPolicy() {
-		creator.AddLine("", nil, util.IptablesFlushFlag, util.IptablesAzureChain)
+		creator.AddLine("", nil, util.IptablesFlushFlag, pMgr.naming.AzureChain)
 	}
 
 	// 2. Flush the policy chains.
@@ -122,16 +122,16 @@ func (pMgr *PolicyManager) creatorForRemovingPolicies(allChainNames []string) *i
 }
 
 // returns ingress and egress chain names for the policies
-func chainNames(networkPolicies []*NPMNetworkPolicy) []string {
+func chainNames(naming *util.Naming, networkPolicies []*NPMNetworkPolicy) []string {
 	chainNames := make([]string, 0)
 	for _, networkPolicy := range networkPolicies {
 		hasIngress, hasEgress := networkPolicy.hasIngressAndEgress()
 
 		if hasIngress {
-			chainNames = append(chainNames, networkPolicy.ingressChainName())
+			chainNames = append(chainNames, networkPolicy.ingressChainName(naming))
 		}
 		if hasEgress {
-			chainNames = append(chainNames, networkPolicy.egressChainName())
+			chainNames = append(chainNames, networkPolicy.egressChainName(naming))
 		}
 	}
 	return chainNames
@@ -146,7 +146,7 @@ func chainNames(networkPolicies []*NPMNetworkPolicy) []string {
 func (pMgr *PolicyManager) checkChainNameCollisions(networkPolicies []*NPMNetworkPolicy) (map[string]string, error) {
 	pending := make(map[string]string)
 	for _, networkPolicy := range networkPolicies {
-		for _, chain := range chainNames([]*NPMNetworkPolicy{networkPolicy}) {
+		for _, chain := range chainNames(pMgr.naming, []*NPMNetworkPolicy{networkPolicy}) {
 			if owner, ok := pMgr.chainNameOwner[chain]; ok && owner != networkPolicy.PolicyKey {
 				return nil, npmerrors.Errorf(npmerrors.AddPolicy, false,
 					fmt.Sprintf("policy %q resolves to chain %s already owned by policy %q", networkPolicy.PolicyKey, chain, owner))
@@ -164,7 +164,7 @@ func (pMgr *PolicyManager) checkChainNameCollisions(networkPolicies []*NPMNetwor
 // releaseChainNames drops a policy's chain-name ownership so the chains can be reused. Callers
 // must hold the policyMap lock.
 func (pMgr *PolicyManager) releaseChainNames(networkPolicy *NPMNetworkPolicy) {
-	for _, chain := range chainNames([]*NPMNetworkPolicy{networkPolicy}) {
+	for _, chain := range chainNames(pMgr.naming, []*NPMNetworkPolicy{networkPolicy}) {
 		delete(pMgr.chainNameOwner, chain)
 	}
 }
@@ -204,13 +204,13 @@ func (pMgr *PolicyManager) deleteJumpRule(policy *NPMNetworkPolicy, direction Un
 	var baseChainName string
 	var chainName string
 	if direction == forIngress {
-		specs = ingressJumpSpecs(policy)
-		baseChainName = util.IptablesAzureIngressChain
-		chainName = policy.ingressChainName()
+		specs = ingressJumpSpecs(pMgr.naming, policy)
+		baseChainName = pMgr.naming.IngressChain
+		chainName = policy.ingressChainName(pMgr.naming)
 	} else {
-		specs = egressJumpSpecs(policy)
-		baseChainName = util.IptablesAzureEgressChain
-		chainName = policy.egressChainName()
+		specs = egressJumpSpecs(pMgr.naming, policy)
+		baseChainName = pMgr.naming.EgressChain
+		chainName = policy.egressChainName(pMgr.naming)
 	}
 
 	specs = append([]string{baseChainName}, specs...)
@@ -226,18 +226,18 @@ func (pMgr *PolicyManager) deleteJumpRule(policy *NPMNetworkPolicy, direction Un
 	return nil
 }
 
-func ingressJumpSpecs(networkPolicy *NPMNetworkPolicy) []string {
-	chainName := networkPolicy.ingressChainName()
+func ingressJumpSpecs(naming *util.Naming, networkPolicy *NPMNetworkPolicy) []string {
+	chainName := networkPolicy.ingressChainName(naming)
 	specs := []string{util.IptablesJumpFlag, chainName}
-	specs = append(specs, matchSetSpecsForNetworkPolicy(networkPolicy, DstMatch)...)
+	specs = append(specs, matchSetSpecsForNetworkPolicy(naming, networkPolicy, DstMatch)...)
 	specs = append(specs, commentSpecs(networkPolicy.commentForJumpToIngress())...)
 	return specs
 }
 
-func egressJumpSpecs(networkPolicy *NPMNetworkPolicy) []string {
-	chainName := networkPolicy.egressChainName()
+func egressJumpSpecs(naming *util.Naming, networkPolicy *NPMNetworkPolicy) []string {
+	chainName := networkPolicy.egressChainName(naming)
 	specs := []string{util.IptablesJumpFlag, chainName}
-	specs = append(specs, matchSetSpecsForNetworkPolicy(networkPolicy, SrcMatch)...)
+	specs = append(specs, matchSetSpecsForNetworkPolicy(naming, networkPolicy, SrcMatch)...)
 	specs = append(specs, commentSpecs(networkPolicy.commentForJumpToEgress())...)
 	return specs
 }
@@ -247,10 +247,10 @@ func (pMgr *PolicyManager) creatorForNewNetworkPolicies(policyChains []string, n
 
 	// 1. Activate NPM if necessary
 	if pMgr.isFirstPolicy() {
-		creator.AddLine("", nil, util.IptablesFlushFlag, util.IptablesAzureChain) // flush just in case there are old rules
-		creator.AddLine("", nil, util.IptablesAppendFlag, util.IptablesAzureChain, util.IptablesJumpFlag, util.IptablesAzureIngressChain)
-		creator.AddLine("", nil, util.IptablesAppendFlag, util.IptablesAzureChain, util.IptablesJumpFlag, util.IptablesAzureEgressChain)
-		creator.AddLine("", nil, util.IptablesAppendFlag, util.IptablesAzureChain, util.IptablesJumpFlag, util.IptablesAzureAcceptChain)
+		creator.AddLine("", nil, util.IptablesFlushFlag, pMgr.naming.AzureChain) // flush just in case there are old rules
+		creator.AddLine("", nil, util.IptablesAppendFlag, pMgr.naming.AzureChain, util.IptablesJumpFlag, pMgr.naming.IngressChain)
+		creator.AddLine("", nil, util.IptablesAppendFlag, pMgr.naming.AzureChain, util.IptablesJumpFlag, pMgr.naming.EgressChain)
+		creator.AddLine("", nil, util.IptablesAppendFlag, pMgr.naming.AzureChain, util.IptablesJumpFlag, pMgr.naming.AcceptChain)
 	}
 
 	// 2. Add all rules for the network policies
@@ -258,17 +258,17 @@ func (pMgr *PolicyManager) creatorForNewNetworkPolicies(policyChains []string, n
 	egressJumpLineNumber := 1
 	for _, networkPolicy := range networkPolicies {
 		// 2.1 add all rules for the policy chain(s)
-		writeNetworkPolicyRules(creator, networkPolicy)
+		writeNetworkPolicyRules(pMgr.naming, creator, networkPolicy)
 
 		// 2.2 add jump rule(s) to the policy chain(s)
 		hasIngress, hasEgress := networkPolicy.hasIngressAndEgress()
 		if hasIngress {
-			ingressJumpSpecs := insertSpecs(util.IptablesAzureIngressChain, ingressJumpLineNumber, ingressJumpSpecs(networkPolicy))
+			ingressJumpSpecs := insertSpecs(pMgr.naming.IngressChain, ingressJumpLineNumber, ingressJumpSpecs(pMgr.naming, networkPolicy))
 			creator.AddLine("", nil, ingressJumpSpecs...) // TODO error handler
 			ingressJumpLineNumber++
 		}
 		if hasEgress {
-			egressJumpSpecs := insertSpecs(util.IptablesAzureEgressChain, egressJumpLineNumber, egressJumpSpecs(networkPolicy))
+			egressJumpSpecs := insertSpecs(pMgr.naming.EgressChain, egressJumpLineNumber, egressJumpSpecs(pMgr.naming, networkPolicy))
 			creator.AddLine("", nil, egressJumpSpecs...) // TODO error handler
 			egressJumpLineNumber++
 		}
@@ -278,40 +278,40 @@ func (pMgr *PolicyManager) creatorForNewNetworkPolicies(policyChains []string, n
 }
 
 // write rules for the policy chain(s)
-func writeNetworkPolicyRules(creator *ioutil.FileCreator, networkPolicy *NPMNetworkPolicy) {
+func writeNetworkPolicyRules(naming *util.Naming, creator *ioutil.FileCreator, networkPolicy *NPMNetworkPolicy) {
 	for _, aclPolicy := range networkPolicy.ACLs {
 		var chainName string
 		var actionSpecs []string
 		if aclPolicy.hasIngress() {
-			chainName = networkPolicy.ingressChainName()
+			chainName = networkPolicy.ingressChainName(naming)
 			if aclPolicy.Target == Allowed {
-				actionSpecs = []string{util.IptablesJumpFlag, util.IptablesAzureIngressAllowMarkChain}
+				actionSpecs = []string{util.IptablesJumpFlag, naming.IngressAllowMarkChain}
 			} else {
 				actionSpecs = setMarkSpecs(util.IptablesAzureIngressDropMarkHex)
 			}
 		} else {
-			chainName = networkPolicy.egressChainName()
+			chainName = networkPolicy.egressChainName(naming)
 			if aclPolicy.Target == Allowed {
-				actionSpecs = []string{util.IptablesJumpFlag, util.IptablesAzureAcceptChain}
+				actionSpecs = []string{util.IptablesJumpFlag, naming.AcceptChain}
 			} else {
 				actionSpecs = setMarkSpecs(util.IptablesAzureEgressDropMarkHex)
 			}
 		}
 		line := []string{"-A", chainName}
 		line = append(line, actionSpecs...)
-		line = append(line, iptablesRuleSpecs(aclPolicy)...)
+		line = append(line, iptablesRuleSpecs(naming, aclPolicy)...)
 		creator.AddLine("", nil, line...) // TODO add error handler
 	}
 }
 
-func iptablesRuleSpecs(aclPolicy *ACLPolicy) []string {
+func iptablesRuleSpecs(naming *util.Naming, aclPolicy *ACLPolicy) []string {
 	specs := make([]string, 0)
 	if aclPolicy.Protocol != UnspecifiedProtocol {
 		specs = append(specs, util.IptablesProtFlag, string(aclPolicy.Protocol))
 	}
 	specs = append(specs, dstPortSpecs(aclPolicy.DstPorts)...)
-	specs = append(specs, matchSetSpecsFromSetInfo(aclPolicy.SrcList)...)
-	specs = append(specs, matchSetSpecsFromSetInfo(aclPolicy.DstList)...)
+	specs = append(specs, matchSetSpecsFromSetInfo(naming, aclPolicy.SrcList)...)
+	specs = append(specs, matchSetSpecsFromSetInfo(naming, aclPolicy.DstList)...)
 	specs = append(specs, commentSpecs(aclPolicy.comment())...)
 	return specs
 }
@@ -323,20 +323,20 @@ func dstPortSpecs(portRange Ports) []string {
 	return []string{util.IptablesDstPortFlag, portRange.toIPTablesString()}
 }
 
-func matchSetSpecsForNetworkPolicy(networkPolicy *NPMNetworkPolicy, matchType MatchType) []string {
+func matchSetSpecsForNetworkPolicy(naming *util.Naming, networkPolicy *NPMNetworkPolicy, matchType MatchType) []string {
 	specs := make([]string, 0, maxLengthForMatchSetSpecs*len(networkPolicy.PodSelectorList))
 	matchString := matchType.toIPTablesString()
 	for _, setInfo := range networkPolicy.PodSelectorList {
-		specs = append(specs, setInfo.matchSetSpecs(matchString)...)
+		specs = append(specs, setInfo.matchSetSpecs(naming, matchString)...)
 	}
 	return specs
 }
 
-func matchSetSpecsFromSetInfo(setInfoList []SetInfo) []string {
+func matchSetSpecsFromSetInfo(naming *util.Naming, setInfoList []SetInfo) []string {
 	specs := make([]string, 0, maxLengthForMatchSetSpecs*len(setInfoList))
 	for _, setInfo := range setInfoList {
 		matchString := setInfo.MatchType.toIPTablesString()
-		specs = append(specs, setInfo.matchSetSpecs(matchString)...)
+		specs = append(specs, setInfo.matchSetSpecs(naming, matchString)...)
 	}
 	return specs
 }
@@ -168,10 +168,12 @@ const (
 
 // iptable rule variables for NetworkPolicies
 var (
-	bothDirectionsNetPolIngressChain = bothDirectionsNetPol.ingressChainName()
-	bothDirectionsNetPolEgressChain  = bothDirectionsNetPol.egressChainName()
-	ingressNetPolChain               = ingressNetPol.ingressChainName()
-	egressNetPolChain                = egressNetPol.egressChainName()
+	namingA = util.NewNaming(util.NamingGenerationA)
+
+	bothDirectionsNetPolIngressChain = bothDirectionsNetPol.ingressChainName(namingA)
+	bothDirectionsNetPolEgressChain  = bothDirectionsNetPol.egressChainName(namingA)
+	ingressNetPolChain               = ingressNetPol.ingressChainName(namingA)
+	egressNetPolChain                = egressNetPol.egressChainName(namingA)
 
 	ingressEgressNetPolIngressJump = fmt.Sprintf(
 		"-j %s -m set --match-set %s dst -m comment --comment %s",
@@ -199,17 +201,24 @@ var allTestNetworkPolicies = []*NPMNetworkPolicy{bothDirectionsNetPol, ingressNe
 
 func TestChainNames(t *testing.T) {
 	expectedName := fmt.Sprintf("AZURE-NPM-INGRESS-%s", util.GetHashedChainName(bothDirectionsNetPol.PolicyKey))
-	require.Equal(t, expectedName, bothDirectionsNetPol.ingressChainName())
+	require.Equal(t, expectedName, bothDirectionsNetPol.ingressChainName(namingA))
 	expectedName = fmt.Sprintf("AZURE-NPM-EGRESS-%s", util.GetHashedChainName(bothDirectionsNetPol.PolicyKey))
-	require.Equal(t, expectedName, bothDirectionsNetPol.egressChainName())
+	require.Equal(t, expectedName, bothDirectionsNetPol.egressChainName(namingA))
 
 	// Chain names must stay within the 28-character iptables limit.
-	require.LessOrEqual(t, len(bothDirectionsNetPol.ingressChainName()), 28, "ingress chain name must fit the iptables limit")
-	require.LessOrEqual(t, len(bothDirectionsNetPol.egressChainName()), 28, "egress chain name must fit the iptables limit")
+	require.LessOrEqual(t, len(bothDirectionsNetPol.ingressChainName(namingA)), 28, "ingress chain name must fit the iptables limit")
+	require.LessOrEqual(t, len(bothDirectionsNetPol.egressChainName(namingA)), 28, "egress chain name must fit the iptables limit")
 
 	// Distinct policies must map to distinct chain names.
-	require.NotEqual(t, ingressNetPol.ingressChainName(), egressNetPol.ingressChainName(),
+	require.NotEqual(t, ingressNetPol.ingressChainName(namingA), egressNetPol.ingressChainName(namingA),
 		"distinct policies must produce distinct chain names")
+
+	// The second naming generation only differs in the prefix.
+	namingB := util.NewNaming(util.NamingGenerationB)
+	expectedName = fmt.Sprintf("AZURE-NPB-INGRESS-%s", util.GetHashedChainName(bothDirectionsNetPol.PolicyKey))
+	require.Equal(t, expectedName, bothDirectionsNetPol.ingressChainName(namingB))
+	expectedName = fmt.Sprintf("AZURE-NPB-EGRESS-%s", util.GetHashedChainName(bothDirectionsNetPol.PolicyKey))
+	require.Equal(t, expectedName, bothDirectionsNetPol.egressChainName(namingB))
 }
 
 // TestChainNameOwnershipGuard verifies the fail-closed invariant: an enforcement chain is
@@ -219,7 +228,7 @@ func TestChainNames(t *testing.T) {
 func TestChainNameOwnershipGuard(t *testing.T) {
 	metrics.ReinitializeAll()
 	victim := ingressNetPol
-	chain := victim.ingressChainName()
+	chain := victim.ingressChainName(namingA)
 
 	// A different policy that resolves to the victim's chain is rejected (fail closed) and the
 	// existing owner is left untouched.
@@ -278,7 +287,7 @@ func TestChainNameOwnershipLifecycle(t *testing.T) {
 	util.SetIptablesToNft()
 
 	require.NoError(t, pMgr.AddPolicies([]*NPMNetworkPolicy{testNetPol}, epList))
-	for _, chain := range chainNames([]*NPMNetworkPolicy{testNetPol}) {
+	for _, chain := range chainNames(namingA, []*NPMNetworkPolicy{testNetPol}) {
 		require.Equal(t, testNetPol.PolicyKey, pMgr.chainNameOwner[chain], "successful add must commit chain ownership")
 	}
 
@@ -309,7 +318,7 @@ func TestCreatorForAddPolicies(t *testing.T) {
 
 	// 1. test with activation
 	policies := []*NPMNetworkPolicy{allTestNetworkPolicies[0]}
-	creator := pMgr.creatorForNewNetworkPolicies(chainNames(policies), policies)
+	creator := pMgr.creatorForNewNetworkPolicies(chainNames(namingA, policies), policies)
 	actualLines := strings.Split(creator.ToString(), "\n")
 	expectedLines := []string{
 		"*filter",
@@ -336,7 +345,7 @@ func TestCreatorForAddPolicies(t *testing.T) {
 	// 2. test without activation
 	// add a policy to the cache so that we don't activate (the cache doesn't impact creatorForNewNetworkPolicies)
 	require.NoError(t, pMgr.AddPolicies([]*NPMNetworkPolicy{allTestNetworkPolicies[0]}, nil))
-	creator = pMgr.creatorForNewNetworkPolicies(chainNames(allTestNetworkPolicies), allTestNetworkPolicies)
+	creator = pMgr.creatorForNewNetworkPolicies(chainNames(namingA, allTestNetworkPolicies), allTestNetworkPolicies)
 	actualLines = strings.Split(creator.ToString(), "\n")
 	expectedLines = []string{
 		"*filter",
@@ -372,7 +381,7 @@ func TestCreatorForRemovePolicies(t *testing.T) {
 
 	// 1. test without deactivation (i.e. flushing azure chain when removing the last policy)
 	// hack: the cache is empty (and len(cache) != len(allTestNetworkPolicies)), so shouldDeactivate will be false
-	creator := pMgr.creatorForRemovingPolicies(chainNames(allTestNetworkPolicies))
+	creator := pMgr.creatorForRemovingPolicies(chainNames(namingA, allTestNetworkPolicies))
 	actualLines := strings.Split(creator.ToString(), "\n")
 	expectedLines := []string{
 		"*filter",
@@ -389,7 +398,7 @@ func TestCreatorForRemovePolicies(t *testing.T) {
 	// add to the cache so that we deactivate
 	policy := TestNetworkPolicies[0]
 	require.NoError(t, pMgr.AddPolicies([]*NPMNetworkPolicy{policy}, nil))
-	creator = pMgr.creatorForRemovingPolicies(chainNames([]*NPMNetworkPolicy{policy}))
+	creator = pMgr.creatorForRemovingPolicies(chainNames(namingA, []*NPMNetworkPolicy{policy}))
 	actualLines = strings.Split(creator.ToString(), "\n")
 	expectedLines = []string{
 		"*filter",
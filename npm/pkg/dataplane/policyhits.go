@@ -0,0 +1,86 @@
+package dataplane
+
+import (
+	"strings"
+
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	npmerrors "github.com/Azure/azure-container-networking/npm/util/errors"
+)
+
+// policyHitCollector exports the increase of the policy rule counters since its last collection to Prometheus.
+// The kernel counters restart from zero when a policy's chain is reprogrammed, so a counter lower than its last
+// value is counted from zero.
+type policyHitCollector struct {
+	getHits func() (map[policies.PolicyHitKey]policies.PolicyHitCounts, error)
+	last    map[policies.PolicyHitKey]policies.PolicyHitCounts
+}
+
+func newPolicyHitCollector(getHits func() (map[policies.PolicyHitKey]policies.PolicyHitCounts, error)) *policyHitCollector {
+	return &policyHitCollector{
+		getHits: getHits,
+		last:    make(map[policies.PolicyHitKey]policies.PolicyHitCounts),
+	}
+}
+
+func (c *policyHitCollector) collect() error {
+	hits, err := c.getHits()
+	if err != nil {
+		return npmerrors.SimpleErrorWrapper("failed to get policy hits", err)
+	}
+
+	current := make(map[string]struct{}, len(hits))
+	for key, counts := range hits {
+		current[key.PolicyKey] = struct{}{}
+		last := c.last[key]
+		if counts.Packets < last.Packets || counts.Bytes < last.Bytes {
+			last = policies.PolicyHitCounts{}
+		}
+		packets, bytes := counts.Packets-last.Packets, counts.Bytes-last.Bytes
+		if packets == 0 && bytes == 0 {
+			if _, ok := c.last[key]; ok {
+				continue
+			}
+		}
+		namespace, name := splitPolicyKey(key.PolicyKey)
+		metrics.AddPolicyHits(namespace, name, directionLabel(key.Direction), verdictLabel(key.Verdict), packets, bytes)
+	}
+
+	deleted := make(map[string]struct{})
+	for key := range c.last {
+		if _, ok := current[key.PolicyKey]; ok {
+			continue
+		}
+		if _, ok := deleted[key.PolicyKey]; ok {
+			continue
+		}
+		deleted[key.PolicyKey] = struct{}{}
+		namespace, name := splitPolicyKey(key.PolicyKey)
+		metrics.DeletePolicyHits(namespace, name)
+	}
+
+	c.last = hits
+	return nil
+}
+
+func splitPolicyKey(policyKey string) (namespace, name string) {
+	namespace, name, ok := strings.Cut(policyKey, "/")
+	if !ok {
+		return "", policyKey
+	}
+	return namespace, name
+}
+
+func directionLabel(direction policies.Direction) string {
+	if direction == policies.Ingress {
+		return "ingress"
+	}
+	return "egress"
+}
+
+func verdictLabel(verdict policies.Verdict) string {
+	if verdict == policies.Allowed {
+		return "allowed"
+	}
+	return "dropped"
+}
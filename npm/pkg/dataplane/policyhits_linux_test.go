@@ -0,0 +1,61 @@
+package dataplane
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyHitCollector(t *testing.T) {
+	metrics.ReinitializeAll()
+
+	allowed := policies.PolicyHitKey{PolicyKey: "x/web", Direction: policies.Ingress, Verdict: policies.Allowed}
+	dropped := policies.PolicyHitKey{PolicyKey: "x/web", Direction: policies.Ingress, Verdict: policies.Dropped}
+	egress := policies.PolicyHitKey{PolicyKey: "y/egress", Direction: policies.Egress, Verdict: policies.Allowed}
+
+	var hits map[policies.PolicyHitKey]policies.PolicyHitCounts
+	c := newPolicyHitCollector(func() (map[policies.PolicyHitKey]policies.PolicyHitCounts, error) {
+		return hits, nil
+	})
+
+	requireHits := func(namespace, policy, direction, verdict string, packets, bytes int) {
+		t.Helper()
+		gotPackets, err := metrics.GetPolicyHitPackets(namespace, policy, direction, verdict)
+		require.NoError(t, err)
+		require.Equal(t, packets, gotPackets)
+		gotBytes, err := metrics.GetPolicyHitBytes(namespace, policy, direction, verdict)
+		require.NoError(t, err)
+		require.Equal(t, bytes, gotBytes)
+	}
+
+	hits = map[policies.PolicyHitKey]policies.PolicyHitCounts{
+		allowed: {Packets: 10, Bytes: 1000},
+		dropped: {Packets: 0, Bytes: 0},
+		egress:  {Packets: 4, Bytes: 400},
+	}
+	require.NoError(t, c.collect())
+	requireHits("x", "web", "ingress", "allowed", 10, 1000)
+	requireHits("x", "web", "ingress", "dropped", 0, 0)
+
+	// the chain of x/web was reprogrammed, so its counters restarted
+	hits = map[policies.PolicyHitKey]policies.PolicyHitCounts{
+		allowed: {Packets: 3, Bytes: 300},
+		dropped: {Packets: 2, Bytes: 200},
+		egress:  {Packets: 6, Bytes: 600},
+	}
+	require.NoError(t, c.collect())
+	requireHits("x", "web", "ingress", "allowed", 13, 1300)
+	requireHits("x", "web", "ingress", "dropped", 2, 200)
+	requireHits("y", "egress", "egress", "allowed", 6, 600)
+
+	// y/egress was removed, so its series are deleted
+	hits = map[policies.PolicyHitKey]policies.PolicyHitCounts{
+		allowed: {Packets: 3, Bytes: 300},
+		dropped: {Packets: 2, Bytes: 200},
+	}
+	require.NoError(t, c.collect())
+	requireHits("x", "web", "ingress", "allowed", 13, 1300)
+	requireHits("y", "egress", "egress", "allowed", 0, 0)
+}
@@ -13,6 +13,7 @@ import (
 type GenericDataplane interface {
 	BootupDataplane() error
 	FinishBootupPhase()
+	CompleteHandover() error
 	RunPeriodicTasks()
 	GetAllIPSets() map[string]string
 	GetIPSet(setName string) *ipsets.IPSet
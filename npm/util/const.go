@@ -54,6 +54,7 @@ const (
 	IptablesCheckFlag          string = "-C"
 	IptablesDestroyFlag        string = "-X"
 	IptablesJumpFlag           string = "-j"
+	IptablesCountersFlag       string = "-c"
 	IptablesWaitFlag           string = "-w"
 	IptablesDefaultWaitTime    string = "60"
 	IptablesAccept             string = "ACCEPT"
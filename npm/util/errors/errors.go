@@ -45,6 +45,7 @@ const (
 	InitializeDataPlane     = "InitializeDataPlane"
 	BootupDataplane         = "BootupDataplane"
 	BootupPolicyMgr         = "BootupPolicyManager"
+	CompleteHandover        = "CompleteHandover"
 	ResetIPSets             = "ResetIPSets"
 	CreateIPSet             = "CreateIPSet"
 	AppendIPSet             = "AppendIPSet"
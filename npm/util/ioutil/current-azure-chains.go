@@ -16,18 +16,20 @@ import (
 // the minimum number of sections when "Chain NAME (1 references)" is split on spaces (" ")
 const minSpacedSectionsForChainLine int = 2
 
-var (
-	azureChainGrepPattern   = fmt.Sprintf("Chain %s", util.IptablesAzureChain)
-	minAzureChainNameLength = len(util.IptablesAzureChain)
-	errInvalidGrepResult    = errors.New("unexpectedly got no lines while grepping for current Azure chains")
-)
+var errInvalidGrepResult = errors.New("unexpectedly got no lines while grepping for current Azure chains")
 
+// AllCurrentAzureChains returns the chains of the first naming generation, i.e. those starting with AZURE-NPM.
 func AllCurrentAzureChains(exec utilexec.Interface, lockWaitTimeSeconds string) (map[string]struct{}, error) {
+	return AllAzureChainsWithPrefix(exec, lockWaitTimeSeconds, util.IptablesAzureChain)
+}
+
+// AllAzureChainsWithPrefix returns the chains whose name starts with the AZURE-NPM chain of a naming generation.
+func AllAzureChainsWithPrefix(exec utilexec.Interface, lockWaitTimeSeconds, azureChain string) (map[string]struct{}, error) {
 	iptablesListCommand := exec.Command(util.Iptables,
 		util.IptablesWaitFlag, lockWaitTimeSeconds, util.IptablesTableFlag, util.IptablesFilterTable,
 		util.IptablesNumericFlag, util.IptablesListFlag,
 	)
-	grepCommand := exec.Command(Grep, azureChainGrepPattern)
+	grepCommand := exec.Command(Grep, fmt.Sprintf("Chain %s", azureChain))
 	searchResults, gotMatches, err := PipeCommandToGrep(iptablesListCommand, grepCommand)
 	if err != nil {
 		return nil, npmerrors.SimpleErrorWrapper("failed to get policy chain names", err)
@@ -52,7 +54,7 @@ func AllCurrentAzureChains(exec utilexec.Interface, lockWaitTimeSeconds string)
 	for _, line := range lines {
 		// line of the form "Chain NAME (1 references)"
 		spaceSeparatedLine := strings.Split(line, " ")
-		if len(spaceSeparatedLine) < minSpacedSectionsForChainLine || len(spaceSeparatedLine[1]) < minAzureChainNameLength {
+		if len(spaceSeparatedLine) < minSpacedSectionsForChainLine || len(spaceSeparatedLine[1]) < len(azureChain) {
 			metrics.SendErrorLogAndMetric(util.IptmID, "while grepping for current Azure chains, got unexpected line [%s] for all current azure chains. full grep output: [%s]", line, string(searchResults))
 		} else {
 			chainNames[spaceSeparatedLine[1]] = struct{}{}
@@ -0,0 +1,82 @@
+package util
+
+// NamingGeneration is the naming of the iptables chains and ipsets of NPM v2 in Linux.
+// A new NPM instance builds its chains and ipsets under the generation which isn't in use,
+// so that it can take over from the previous instance by swapping the jump from the FORWARD chain.
+type NamingGeneration int
+
+const (
+	// NamingGenerationA is the naming NPM v1 and v2 have always used.
+	NamingGenerationA NamingGeneration = iota
+	NamingGenerationB
+
+	// the prefixes of each generation must have the same length to keep chain and ipset names within the kernel limits
+	azureChainPrefixB string = "AZURE-NPB"
+	azureSetPrefixB   string = "azure-npb-"
+)
+
+// ChainPrefix is the name of the AZURE-NPM chain of the generation, which prefixes all its other chains.
+func (g NamingGeneration) ChainPrefix() string {
+	if g == NamingGenerationB {
+		return azureChainPrefixB
+	}
+	return IptablesAzureChain
+}
+
+// IPSetPrefix prefixes the ipset names of the generation.
+func (g NamingGeneration) IPSetPrefix() string {
+	if g == NamingGenerationB {
+		return azureSetPrefixB
+	}
+	return AzureNpmPrefix
+}
+
+// Other returns the generation to hand over to from g, or the one to take over from.
+func (g NamingGeneration) Other() NamingGeneration {
+	if g == NamingGenerationB {
+		return NamingGenerationA
+	}
+	return NamingGenerationB
+}
+
+func (g NamingGeneration) String() string {
+	return g.ChainPrefix()
+}
+
+// Naming holds the names of the iptables chains and ipsets of a NamingGeneration.
+// For NamingGenerationA, the names are the Iptables* and AzureNpmPrefix constants.
+type Naming struct {
+	Generation NamingGeneration
+
+	AzureChain            string
+	AcceptChain           string
+	IngressChain          string
+	IngressAllowMarkChain string
+	EgressChain           string
+
+	IngressPolicyChainPrefix string
+	EgressPolicyChainPrefix  string
+
+	IPSetPrefix string
+}
+
+// NewNaming returns the names of the generation.
+func NewNaming(g NamingGeneration) *Naming {
+	prefix := g.ChainPrefix()
+	return &Naming{
+		Generation:               g,
+		AzureChain:               prefix,
+		AcceptChain:              prefix + "-ACCEPT",
+		IngressChain:             prefix + "-INGRESS",
+		IngressAllowMarkChain:    prefix + "-INGRESS-ALLOW-MARK",
+		EgressChain:              prefix + "-EGRESS",
+		IngressPolicyChainPrefix: prefix + "-INGRESS",
+		EgressPolicyChainPrefix:  prefix + "-EGRESS",
+		IPSetPrefix:              g.IPSetPrefix(),
+	}
+}
+
+// HashedName returns the kernel ipset name of the prefixed name in this naming, as GetHashedName does for NamingGenerationA.
+func (n *Naming) HashedName(name string) string {
+	return n.IPSetPrefix + hashedNameDigest(name)
+}
@@ -194,6 +194,10 @@ func AppendMap(base, new map[string]string) map[string]string {
 // length-constrained) so distinct ipset names map to distinct kernel names. The result is
 // AzureNpmPrefix (10) + hashedNameDigestLen (20) = 30 chars, within the 31-char kernel ipset name limit.
 func GetHashedName(name string) string {
+	return AzureNpmPrefix + hashedNameDigest(name)
+}
+
+func hashedNameDigest(name string) string {
 	sum := sha256.Sum256([]byte(name))
 	// Text(36) omits leading zeros, so a small digest could be shorter than
 	// hashedNameDigestLen; left-pad to a fixed width before slicing so the result is always
@@ -202,7 +206,7 @@ func GetHashedName(name string) string {
 	if len(digest) < hashedNameDigestLen {
 		digest = strings.Repeat("0", hashedNameDigestLen-len(digest)) + digest
 	}
-	return AzureNpmPrefix + digest[:hashedNameDigestLen]
+	return digest[:hashedNameDigestLen]
 }
 
 // CompareK8sVer compares two k8s versions.
@@ -514,3 +514,33 @@ func TestHashedNameGoldenVectors(t *testing.T) {
 		require.Equal(t, want, GetHashedChainName(in), "GetHashedChainName(%q) golden vector", in)
 	}
 }
+
+func TestNaming(t *testing.T) {
+	namingA := NewNaming(NamingGenerationA)
+	require.Equal(t, &Naming{
+		Generation:               NamingGenerationA,
+		AzureChain:               IptablesAzureChain,
+		AcceptChain:              IptablesAzureAcceptChain,
+		IngressChain:             IptablesAzureIngressChain,
+		IngressAllowMarkChain:    IptablesAzureIngressAllowMarkChain,
+		EgressChain:              IptablesAzureEgressChain,
+		IngressPolicyChainPrefix: IptablesAzureIngressPolicyChainPrefix,
+		EgressPolicyChainPrefix:  IptablesAzureEgressPolicyChainPrefix,
+		IPSetPrefix:              AzureNpmPrefix,
+	}, namingA)
+	require.Equal(t, GetHashedName("ns-test"), namingA.HashedName("ns-test"))
+
+	namingB := NewNaming(NamingGenerationB)
+	require.Equal(t, NamingGenerationA, namingB.Generation.Other())
+	require.Equal(t, "AZURE-NPB", namingB.AzureChain)
+	require.Equal(t, "AZURE-NPB-ACCEPT", namingB.AcceptChain)
+	require.Equal(t, "AZURE-NPB-INGRESS", namingB.IngressChain)
+	require.Equal(t, "AZURE-NPB-INGRESS-ALLOW-MARK", namingB.IngressAllowMarkChain)
+	require.Equal(t, "AZURE-NPB-EGRESS", namingB.EgressChain)
+	require.Equal(t, "AZURE-NPB-INGRESS", namingB.IngressPolicyChainPrefix)
+	require.Equal(t, "AZURE-NPB-EGRESS", namingB.EgressPolicyChainPrefix)
+
+	// names must stay within the kernel limits
+	require.Equal(t, "azure-npb-"+strings.TrimPrefix(GetHashedName("ns-test"), AzureNpmPrefix), namingB.HashedName("ns-test"))
+	require.Len(t, namingB.IngressAllowMarkChain, len(IptablesAzureIngressAllowMarkChain))
+}
@@ -0,0 +1,168 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ovsctl
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Flows programmed by this package carry a cookie whose high 16 bits are managedCookiePrefix.
+// The low 48 bits are a hash of the flow itself, so a flow's cookie changes whenever its match or actions change.
+const (
+	managedCookiePrefix uint64 = 0xac4e << 48
+	managedCookieMask   uint64 = 0xffff << 48
+	flowHashMask        uint64 = 1<<48 - 1
+
+	// defaultPriority is the priority ovs-ofctl assigns when none is given.
+	defaultPriority = 32768
+)
+
+// Flow is an OpenFlow rule in ovs-ofctl syntax.
+type Flow struct {
+	Table    int
+	Priority int
+	Match    string
+	Actions  string
+}
+
+func (f Flow) spec() string {
+	return fmt.Sprintf("table=%d,priority=%d,%s,actions=%s", f.Table, f.Priority, f.Match, f.Actions)
+}
+
+// Cookie returns the managed cookie that identifies this flow.
+func (f Flow) Cookie() uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(f.spec()))
+	return managedCookiePrefix | (h.Sum64() & flowHashMask)
+}
+
+// String returns the flow in the form accepted by ovs-ofctl add-flow.
+func (f Flow) String() string {
+	return fmt.Sprintf("cookie=%#x,%s", f.Cookie(), f.spec())
+}
+
+// IsManagedCookie reports whether cookie belongs to a flow programmed by this package.
+func IsManagedCookie(cookie uint64) bool {
+	return cookie&managedCookieMask == managedCookiePrefix
+}
+
+// ParseFlowCookies extracts the managed cookies from ovs-ofctl dump-flows output.
+// Flows without a managed cookie are ignored.
+func ParseFlowCookies(dump string) ([]uint64, error) {
+	var cookies []uint64
+	for _, line := range strings.Split(dump, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "cookie=") {
+			continue
+		}
+		field, _, _ := strings.Cut(strings.TrimPrefix(line, "cookie="), ",")
+		cookie, err := strconv.ParseUint(strings.TrimSpace(field), 0, 64)
+		if err != nil {
+			return nil, newErrorOvsctl(fmt.Sprintf("failed to parse cookie in flow %q: %v", line, err))
+		}
+		if IsManagedCookie(cookie) {
+			cookies = append(cookies, cookie)
+		}
+	}
+	return cookies, nil
+}
+
+// FlowDiff is the set of changes needed to converge the managed flows on a bridge.
+type FlowDiff struct {
+	Add    []Flow
+	Delete []uint64
+}
+
+// Empty reports whether the bridge is already converged.
+func (d FlowDiff) Empty() bool {
+	return len(d.Add) == 0 && len(d.Delete) == 0
+}
+
+// bundle renders the diff as an ovs-ofctl add-flows file. Deletes come first so that
+// a flow replaced by one with the same match never loses its replacement.
+func (d FlowDiff) bundle() string {
+	var sb strings.Builder
+	for _, cookie := range d.Delete {
+		fmt.Fprintf(&sb, "delete cookie=%#x/-1\n", cookie)
+	}
+	for _, flow := range d.Add {
+		fmt.Fprintf(&sb, "add %s\n", flow.String())
+	}
+	return sb.String()
+}
+
+// DiffFlows compares the desired flows against the managed cookies currently on the bridge.
+// Unmanaged flows are never touched.
+func DiffFlows(desired []Flow, current []uint64) FlowDiff {
+	currentSet := make(map[uint64]struct{}, len(current))
+	for _, cookie := range current {
+		currentSet[cookie] = struct{}{}
+	}
+
+	var diff FlowDiff
+	desiredSet := make(map[uint64]struct{}, len(desired))
+	for _, flow := range desired {
+		cookie := flow.Cookie()
+		if _, seen := desiredSet[cookie]; seen {
+			continue
+		}
+		desiredSet[cookie] = struct{}{}
+		if _, ok := currentSet[cookie]; !ok {
+			diff.Add = append(diff.Add, flow)
+		}
+	}
+	for cookie := range currentSet {
+		if _, ok := desiredSet[cookie]; !ok {
+			diff.Delete = append(diff.Delete, cookie)
+		}
+	}
+	sort.Slice(diff.Delete, func(i, j int) bool { return diff.Delete[i] < diff.Delete[j] })
+	return diff
+}
+
+// IPSnatFlows returns the flows that change the source mac of packets from a container port to the VM mac,
+// and drop anything else from that port so containers cannot spoof their ip.
+func IPSnatFlows(ip net.IP, vlanID int, port, mac, outport string) []Flow {
+	if outport == "" {
+		outport = "normal"
+	}
+
+	actions := fmt.Sprintf("mod_dl_src:%s,strip_vlan,%v", mac, outport)
+	if vlanID != 0 {
+		actions = fmt.Sprintf("mod_dl_src:%s,mod_vlan_vid:%v,%v", mac, vlanID, outport)
+	}
+
+	return []Flow{
+		{
+			Priority: high,
+			Match:    fmt.Sprintf("ip,nw_src=%s,in_port=%s,vlan_tci=0", ip.String(), port),
+			Actions:  actions,
+		},
+		{
+			Priority: low,
+			Match:    "ip,in_port=" + port,
+			Actions:  "drop",
+		},
+	}
+}
+
+// MacDnatFlow returns the flow that changes the destination mac to the container mac based on the ip and vlanid,
+// and forwards the packet to the container host veth port.
+func MacDnatFlow(port string, ip net.IP, mac string, vlanid int, containerPort string) Flow {
+	match := fmt.Sprintf("ip,nw_dst=%s,in_port=%s", ip.String(), port)
+	if vlanid != 0 {
+		match = fmt.Sprintf("%s,dl_vlan=%v", match, vlanid)
+	}
+
+	return Flow{
+		Priority: defaultPriority,
+		Match:    match,
+		Actions:  fmt.Sprintf("mod_dl_dst:%s,strip_vlan,%s", mac, containerPort),
+	}
+}
@@ -0,0 +1,101 @@
+package ovsctl
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlowCookie(t *testing.T) {
+	ip := net.ParseIP("10.0.0.5")
+	flow := MacDnatFlow("1", ip, "12:34:56:78:9a:bc", 10, "2")
+
+	assert.True(t, IsManagedCookie(flow.Cookie()))
+	assert.Equal(t, flow.Cookie(), MacDnatFlow("1", ip, "12:34:56:78:9a:bc", 10, "2").Cookie(), "cookie should be stable")
+	assert.NotEqual(t, flow.Cookie(), MacDnatFlow("1", ip, "12:34:56:78:9a:bd", 10, "2").Cookie(), "cookie should change with actions")
+	assert.False(t, IsManagedCookie(0x0))
+}
+
+func TestParseFlowCookies(t *testing.T) {
+	managed := MacDnatFlow("1", net.ParseIP("10.0.0.5"), "12:34:56:78:9a:bc", 10, "2").Cookie()
+	dump := "NXST_FLOW reply (xid=0x4):\n" +
+		" cookie=0x0, priority=20,arp,arp_op=1 actions=IN_PORT\n" +
+		" " + MacDnatFlow("1", net.ParseIP("10.0.0.5"), "12:34:56:78:9a:bc", 10, "2").String() + "\n"
+
+	cookies, err := ParseFlowCookies(dump)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{managed}, cookies)
+
+	_, err = ParseFlowCookies("cookie=zzz, priority=1 actions=drop")
+	require.Error(t, err)
+}
+
+func TestDiffFlows(t *testing.T) {
+	ip := net.ParseIP("10.0.0.5")
+	kept := MacDnatFlow("1", ip, "12:34:56:78:9a:bc", 10, "2")
+	missing := IPSnatFlows(ip, 10, "2", "aa:bb:cc:dd:ee:ff", "1")
+	stale := MacDnatFlow("1", net.ParseIP("10.0.0.6"), "12:34:56:78:9a:bc", 10, "3").Cookie()
+
+	desired := append([]Flow{kept, kept}, missing...)
+	diff := DiffFlows(desired, []uint64{kept.Cookie(), stale})
+
+	assert.Equal(t, missing, diff.Add)
+	assert.Equal(t, []uint64{stale}, diff.Delete)
+	assert.True(t, DiffFlows([]Flow{kept}, []uint64{kept.Cookie()}).Empty())
+}
+
+func TestApplyFlowDiff(t *testing.T) {
+	flow := MacDnatFlow("1", net.ParseIP("10.0.0.5"), "12:34:56:78:9a:bc", 0, "2")
+	diff := FlowDiff{Add: []Flow{flow}, Delete: []uint64{managedCookiePrefix | 1}}
+
+	var cmds []string
+	execcli := platform.NewMockExecClient(false)
+	execcli.SetExecRawCommand(func(cmd string) (string, error) {
+		cmds = append(cmds, cmd)
+		return "", nil
+	})
+	o := Ovsctl{execcli: execcli}
+	require.NoError(t, o.ApplyFlowDiff("br0", diff))
+	require.Len(t, cmds, 1)
+	assert.Contains(t, cmds[0], "ovs-ofctl --bundle add-flows br0 ")
+
+	assert.Equal(t, "delete cookie=0xac4e000000000001/-1\nadd "+flow.String()+"\n", diff.bundle())
+
+	cmds = nil
+	require.NoError(t, o.ApplyFlowDiff("br0", FlowDiff{}))
+	assert.Empty(t, cmds, "empty diff should not touch the bridge")
+}
+
+func TestUnmanagedFlowsAreNotReconciled(t *testing.T) {
+	// a fake bridge which dumps the flows added to it, with cookie=0x0 when they have none
+	var dump strings.Builder
+	execcli := platform.NewMockExecClient(false)
+	execcli.SetExecRawCommand(func(cmd string) (string, error) {
+		if flow, ok := strings.CutPrefix(cmd, "ovs-ofctl add-flow br0 "); ok {
+			if !strings.HasPrefix(flow, "cookie=") {
+				flow = "cookie=0x0," + flow
+			}
+			dump.WriteString(" " + flow + "\n")
+			return "", nil
+		}
+		return dump.String(), nil
+	})
+	o := Ovsctl{execcli: execcli}
+
+	// an endpoint, with managed flows, and an infra vnet endpoint, with unmanaged ones
+	ip, infraIP := net.ParseIP("10.0.0.5"), net.ParseIP("169.254.0.5")
+	require.NoError(t, o.AddIPSnatRule("br0", ip, 10, "2", "aa:bb:cc:dd:ee:ff", "1"))
+	require.NoError(t, o.AddMacDnatRule("br0", "1", ip, "12:34:56:78:9a:bc", 10, "2"))
+	require.NoError(t, o.AddUnmanagedIPSnatRule("br0", infraIP, 0, "3", "aa:bb:cc:dd:ee:ff", "1"))
+	require.NoError(t, o.AddUnmanagedMacDnatRule("br0", "1", infraIP, "12:34:56:78:9a:bd", 0, "3"))
+	assert.Contains(t, dump.String(), "nw_dst="+infraIP.String())
+
+	current, err := o.DumpManagedFlowCookies("br0")
+	require.NoError(t, err)
+	desired := append(IPSnatFlows(ip, 10, "2", "aa:bb:cc:dd:ee:ff", "1"), MacDnatFlow("1", ip, "12:34:56:78:9a:bc", 10, "2"))
+	assert.True(t, DiffFlows(desired, current).Empty(), "the infra vnet flows should be neither desired nor stale")
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"os"
 	"strings"
 
 	"github.com/Azure/azure-container-networking/cni/log"
@@ -47,10 +48,14 @@ type OvsInterface interface {
 	AddFakeArpReply(bridgeName string, ip net.IP) error
 	AddArpReplyRule(bridgeName string, port string, ip net.IP, mac string, vlanid int, mode string) error
 	AddMacDnatRule(bridgeName string, port string, ip net.IP, mac string, vlanid int, containerPort string) error
+	AddUnmanagedIPSnatRule(bridgeName string, ip net.IP, vlanID int, port string, mac string, outport string) error
+	AddUnmanagedMacDnatRule(bridgeName string, port string, ip net.IP, mac string, vlanid int, containerPort string) error
 	DeleteArpReplyRule(bridgeName string, port string, ip net.IP, vlanid int)
 	DeleteIPSnatRule(bridgeName string, port string)
 	DeleteMacDnatRule(bridgeName string, port string, ip net.IP, vlanid int)
 	DeletePortFromOVS(bridgeName string, interfaceName string) error
+	DumpManagedFlowCookies(bridgeName string) ([]uint64, error)
+	ApplyFlowDiff(bridgeName string, diff FlowDiff) error
 }
 
 type Ovsctl struct {
@@ -134,32 +139,16 @@ func (o Ovsctl) AddArpSnatRule(bridgeName, mac, macHex, ofport string) error {
 
 // IP SNAT Rule - Change src mac to VM Mac for packets coming from container host veth port.
 func (o Ovsctl) AddIPSnatRule(bridgeName string, ip net.IP, vlanID int, port, mac, outport string) error {
-	var cmd string
-	if outport == "" {
-		outport = "normal"
-	}
+	// The first flow also checks if packets are coming from the right source ip based on the ovs port
+	// to prevent ip spoofing. The second drops the packets which don't satisfy that condition.
+	flows := IPSnatFlows(ip, vlanID, port, mac, outport)
 
-	commonPrefix := fmt.Sprintf("ovs-ofctl add-flow %v priority=%d,ip,nw_src=%s,in_port=%s,vlan_tci=0,actions=mod_dl_src:%s", bridgeName, high, ip.String(), port, mac)
-
-	// This rule also checks if packets coming from right source ip based on the ovs port to prevent ip spoofing.
-	// Otherwise it drops the packet.
-	if vlanID != 0 {
-		cmd = fmt.Sprintf("%s,mod_vlan_vid:%v,%v", commonPrefix, vlanID, outport)
-	} else {
-		cmd = fmt.Sprintf("%s,strip_vlan,%v", commonPrefix, outport)
-	}
-
-	_, err := o.execcli.ExecuteRawCommand(cmd)
-	if err != nil {
+	if err := o.addFlow(bridgeName, flows[0]); err != nil {
 		logger.Error("Adding IP SNAT rule failed with", zap.Error(err))
 		return newErrorOvsctl(err.Error())
 	}
 
-	// Drop other packets which doesn't satisfy above condition
-	cmd = fmt.Sprintf("ovs-ofctl add-flow %v priority=%d,ip,in_port=%s,actions=drop",
-		bridgeName, low, port)
-	_, err = o.execcli.ExecuteRawCommand(cmd)
-	if err != nil {
+	if err := o.addFlow(bridgeName, flows[1]); err != nil {
 		logger.Error("Dropping vlantag packet rule failed with", zap.Error(err))
 		return newErrorOvsctl(err.Error())
 	}
@@ -231,19 +220,33 @@ func (o Ovsctl) AddArpReplyRule(bridgeName, port string, ip net.IP, mac string,
 
 // Add MAC DNAT rule based on dst ip and vlanid
 func (o Ovsctl) AddMacDnatRule(bridgeName, port string, ip net.IP, mac string, vlanid int, containerPort string) error {
-	var cmd string
 	// This rule changes the destination mac to speciifed mac based on the ip and vlanid.
 	// and forwards the packet to corresponding container hostveth port
+	if err := o.addFlow(bridgeName, MacDnatFlow(port, ip, mac, vlanid, containerPort)); err != nil {
+		logger.Error("Adding MAC DNAT rule failed with", zap.Error(err))
+		return newErrorOvsctl(err.Error())
+	}
 
-	commonPrefix := fmt.Sprintf("ovs-ofctl add-flow %s ip,nw_dst=%s,in_port=%s", bridgeName, ip.String(), port)
-	if vlanid != 0 {
-		cmd = fmt.Sprintf("%s,dl_vlan=%v,actions=mod_dl_dst:%s,strip_vlan,%s", commonPrefix, vlanid, mac, containerPort)
-	} else {
-		cmd = fmt.Sprintf("%s,actions=mod_dl_dst:%s,strip_vlan,%s", commonPrefix, mac, containerPort)
+	return nil
+}
+
+// AddUnmanagedIPSnatRule adds the flows of AddIPSnatRule without the managed cookie, for endpoints the flow
+// reconciliation can't compute the flows of, so that it never deletes them.
+func (o Ovsctl) AddUnmanagedIPSnatRule(bridgeName string, ip net.IP, vlanID int, port, mac, outport string) error {
+	for _, flow := range IPSnatFlows(ip, vlanID, port, mac, outport) {
+		if err := o.addUnmanagedFlow(bridgeName, flow); err != nil {
+			logger.Error("Adding unmanaged IP SNAT rule failed with", zap.Error(err))
+			return newErrorOvsctl(err.Error())
+		}
 	}
-	_, err := o.execcli.ExecuteRawCommand(cmd)
-	if err != nil {
-		logger.Error("Adding MAC DNAT rule failed with", zap.Error(err))
+
+	return nil
+}
+
+// AddUnmanagedMacDnatRule adds the flow of AddMacDnatRule without the managed cookie.
+func (o Ovsctl) AddUnmanagedMacDnatRule(bridgeName, port string, ip net.IP, mac string, vlanid int, containerPort string) error {
+	if err := o.addUnmanagedFlow(bridgeName, MacDnatFlow(port, ip, mac, vlanid, containerPort)); err != nil {
+		logger.Error("Adding unmanaged MAC DNAT rule failed with", zap.Error(err))
 		return newErrorOvsctl(err.Error())
 	}
 
@@ -303,3 +306,59 @@ func (o Ovsctl) DeletePortFromOVS(bridgeName, interfaceName string) error {
 
 	return nil
 }
+
+// addFlow programs a single managed flow on the bridge.
+func (o Ovsctl) addFlow(bridgeName string, flow Flow) error {
+	cmd := fmt.Sprintf("ovs-ofctl add-flow %s %s", bridgeName, flow.String())
+	_, err := o.execcli.ExecuteRawCommand(cmd)
+	return err //nolint:wrapcheck // callers wrap with newErrorOvsctl
+}
+
+// addUnmanagedFlow programs a single flow on the bridge without the managed cookie.
+func (o Ovsctl) addUnmanagedFlow(bridgeName string, flow Flow) error {
+	cmd := fmt.Sprintf("ovs-ofctl add-flow %s %s", bridgeName, flow.spec())
+	_, err := o.execcli.ExecuteRawCommand(cmd)
+	return err //nolint:wrapcheck // callers wrap with newErrorOvsctl
+}
+
+// DumpManagedFlowCookies returns the cookies of all flows on the bridge that were programmed by this package.
+func (o Ovsctl) DumpManagedFlowCookies(bridgeName string) ([]uint64, error) {
+	cmd := fmt.Sprintf("ovs-ofctl dump-flows --no-stats %s cookie=%#x/%#x", bridgeName, managedCookiePrefix, managedCookieMask)
+	out, err := o.execcli.ExecuteRawCommand(cmd)
+	if err != nil {
+		logger.Error("Dumping flows failed with", zap.String("bridge", bridgeName), zap.Error(err))
+		return nil, newErrorOvsctl(err.Error())
+	}
+
+	return ParseFlowCookies(out)
+}
+
+// ApplyFlowDiff applies all the deletes and adds in the diff as a single OpenFlow bundle,
+// so the bridge either converges completely or is left untouched.
+func (o Ovsctl) ApplyFlowDiff(bridgeName string, diff FlowDiff) error {
+	if diff.Empty() {
+		return nil
+	}
+
+	f, err := os.CreateTemp("", "ovs-flows-*.txt")
+	if err != nil {
+		return newErrorOvsctl(err.Error())
+	}
+	defer os.Remove(f.Name())
+
+	if _, err = f.WriteString(diff.bundle()); err != nil {
+		f.Close()
+		return newErrorOvsctl(err.Error())
+	}
+	if err = f.Close(); err != nil {
+		return newErrorOvsctl(err.Error())
+	}
+
+	cmd := fmt.Sprintf("ovs-ofctl --bundle add-flows %s %s", bridgeName, f.Name())
+	if _, err = o.execcli.ExecuteRawCommand(cmd); err != nil {
+		logger.Error("Applying flow bundle failed with", zap.String("bridge", bridgeName), zap.Error(err))
+		return newErrorOvsctl(err.Error())
+	}
+
+	return nil
+}
@@ -92,6 +92,20 @@ func (m MockOvsctl) AddMacDnatRule(bridgeName string, port string, ip net.IP, ma
 	return nil
 }
 
+func (m MockOvsctl) AddUnmanagedIPSnatRule(bridgeName string, ip net.IP, vlanID int, port string, mac string, outport string) error {
+	if m.returnError {
+		return newErrorOvsctl(m.errorStr)
+	}
+	return nil
+}
+
+func (m MockOvsctl) AddUnmanagedMacDnatRule(bridgeName string, port string, ip net.IP, mac string, vlanid int, containerPort string) error {
+	if m.returnError {
+		return newErrorOvsctl(m.errorStr)
+	}
+	return nil
+}
+
 func (MockOvsctl) DeleteArpReplyRule(bridgeName string, port string, ip net.IP, vlanid int) {}
 
 func (MockOvsctl) DeleteIPSnatRule(bridgeName string, port string) {}
@@ -104,3 +118,17 @@ func (m MockOvsctl) DeletePortFromOVS(bridgeName string, interfaceName string) e
 	}
 	return nil
 }
+
+func (m MockOvsctl) DumpManagedFlowCookies(bridgeName string) ([]uint64, error) {
+	if m.returnError {
+		return nil, newErrorOvsctl(m.errorStr)
+	}
+	return nil, nil
+}
+
+func (m MockOvsctl) ApplyFlowDiff(bridgeName string, diff FlowDiff) error {
+	if m.returnError {
+		return newErrorOvsctl(m.errorStr)
+	}
+	return nil
+}
@@ -16,6 +16,8 @@ type TlsSettings struct {
 	UseMTLS                            bool
 	MinTLSVersion                      string
 	MtlsClientCertSubjectName          string
+	TLSCABundlePath                    string
+	TLSRefreshInterval                 time.Duration
 }
 
 func GetTlsCertificateRetriever(settings TlsSettings) (TlsCertificateRetriever, error) {
@@ -9,6 +9,8 @@ const (
 	CNIDelTimeMetricStr    = "CNIDelTimeMs"
 	CNIUpdateTimeMetricStr = "CNIUpdateTimeMs"
 	CNILockTimeoutStr      = "CNILockTimeoutError"
+	CNIOVSFlowsAddedStr    = "CNIOVSFlowsAdded"
+	CNIOVSFlowsDeletedStr  = "CNIOVSFlowsDeleted"
 
 	// Dimension Names
 	ContextStr        = "Context"
@@ -20,6 +22,7 @@ const (
 	CNIModeStr        = "CNIMode"
 	CNINetworkModeStr = "CNINetworkMode"
 	OSTypeStr         = "OSType"
+	BridgeStr         = "Bridge"
 
 	// Values
 	SucceededStr     = "Succeeded"
@@ -38,10 +38,19 @@ const (
 	FlagEnableExactMatchForPodName = "enableexactmatchforpodname"
 	FlagNetworkName                = "networkname"
 
+	// Doctor flags
+	FlagOutput    = "output"
+	FlagStateFile = "statefile"
+	FlagTimeout   = "timeout"
+
 	// os flags
 	Linux   = "linux"
 	Windows = "windows"
 
+	// output flags
+	OutputText = "text"
+	OutputJSON = "json"
+
 	// arch flags
 	Amd64 = "amd64"
 
@@ -76,6 +85,10 @@ const (
 	DefaultCNSUrl                     = "http://localhost:10090"
 	DefaultEnableExactMatchForPodName = "false"
 	DefaultNetworkName                = "azure"
+
+	// Doctor defaults
+	DefaultStateFile = "/var/run/azure-vnet.json"
+	DefaultTimeout   = "10s"
 )
 
 var (
@@ -111,6 +124,9 @@ var (
 		EnvCNIDestinationBinDir:        DefaultBinDirLinux,
 		EnvCNIDestinationConflistDir:   DefaultConflistDirLinux,
 		FlagNetworkName:                DefaultNetworkName,
+		FlagOutput:                     OutputText,
+		FlagStateFile:                  DefaultStateFile,
+		FlagTimeout:                    DefaultTimeout,
 	}
 
 	DefaultToggles = map[string]bool{
@@ -0,0 +1,70 @@
+//go:build !ignore_uncovered
+// +build !ignore_uncovered
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	c "github.com/Azure/azure-container-networking/tools/acncli/api"
+	"github.com/Azure/azure-container-networking/tools/acncli/doctor"
+	"github.com/spf13/cobra"
+)
+
+// DoctorCmd runs the checks of the network setup of the node
+func DoctorCmd(version string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Diagnoses the network setup of the node",
+		Long: "The doctor command checks the conflist, the CNI binaries, CNS, the CNI statefile against CNS, " +
+			"the pod veths and routes, the iptables chains and the NPM ipsets of the node, and prints how to fix what is broken",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags := cmd.Flags()
+			output, _ := flags.GetString(c.FlagOutput)
+			if output != c.OutputText && output != c.OutputJSON {
+				return fmt.Errorf("invalid output %q, options are %s and %s", output, c.OutputText, c.OutputJSON)
+			}
+			timeoutFlag, _ := flags.GetString(c.FlagTimeout)
+			timeout, err := time.ParseDuration(timeoutFlag)
+			if err != nil {
+				return fmt.Errorf("invalid timeout %q: %w", timeoutFlag, err)
+			}
+
+			cfg := doctor.Config{Timeout: timeout}
+			cfg.ConflistDir, _ = flags.GetString(c.FlagConflistDirectory)
+			cfg.BinDir, _ = flags.GetString(c.FlagBinDirectory)
+			cfg.CNSURL, _ = flags.GetString(c.FlagCNSUrl)
+			cfg.StateFile, _ = flags.GetString(c.FlagStateFile)
+
+			d, err := doctor.New(cfg)
+			if err != nil {
+				return err
+			}
+			report := d.Run(cmd.Context(), version)
+
+			if output == c.OutputJSON {
+				err = report.WriteJSON(os.Stdout)
+			} else {
+				err = report.WriteText(os.Stdout)
+			}
+			if err != nil {
+				return err
+			}
+			if failed := report.Failed(); failed > 0 {
+				return fmt.Errorf("%d checks failed", failed)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP(c.FlagOutput, "o", c.Defaults[c.FlagOutput], fmt.Sprintf("Format of the report, options are %s and %s", c.OutputText, c.OutputJSON))
+	cmd.Flags().String(c.FlagConflistDirectory, c.Defaults[c.FlagConflistDirectory], "Directory of the conflist of the node")
+	cmd.Flags().String(c.FlagBinDirectory, c.Defaults[c.FlagBinDirectory], "Directory of the CNI binaries of the node")
+	cmd.Flags().String(c.FlagCNSUrl, c.Defaults[c.FlagCNSUrl], "URL of the CNS of the node")
+	cmd.Flags().String(c.FlagStateFile, c.Defaults[c.FlagStateFile], "Path of the Azure CNI statefile")
+	cmd.Flags().String(c.FlagTimeout, c.Defaults[c.FlagTimeout], "Timeout of each check")
+
+	return cmd
+}
@@ -0,0 +1,412 @@
+//go:build !ignore_uncovered
+// +build !ignore_uncovered
+
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/containernetworking/cni/pkg/version"
+)
+
+const (
+	azureCNIBin  = "azure-vnet"
+	azureIPAMBin = "azure-vnet-ipam"
+	// IPAMs which are served by CNS. azure-cns is a mode of azure-vnet, not a binary.
+	cnsIPAM      = "azure-cns"
+	azureIPAMCNS = "azure-ipam"
+)
+
+// conflistExtensions are the extensions of the files the container runtime loads network configs from
+var conflistExtensions = []string{".conf", ".conflist", ".json"}
+
+// modes are the valid modes of the azure-vnet plugin
+var modes = []string{"", "bridge", "transparent", "transparent-vlan"}
+
+type rawConflist struct {
+	Name       string            `json:"name"`
+	CNIVersion string            `json:"cniVersion"`
+	Plugins    []json.RawMessage `json:"plugins"`
+}
+
+type rawPlugin struct {
+	Type string `json:"type"`
+	IPAM struct {
+		Type string `json:"type"`
+	} `json:"ipam"`
+}
+
+// checkConflist validates the conflist the container runtime uses, which is the first one by name
+func (d *Doctor) checkConflist(_ context.Context) Result {
+	hint := "Reinstall the conflist with 'acncli cni install cni', or check the logs of the CNS or cni-installer init container which writes it"
+	entries, err := d.fs.ReadDir(d.cfg.ConflistDir)
+	if err != nil {
+		return fail(hint, nil, "failed to read conflist directory %s: %v", d.cfg.ConflistDir, err)
+	}
+	var files []string
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		for _, e := range conflistExtensions {
+			if !entry.IsDir() && ext == e {
+				files = append(files, entry.Name())
+			}
+		}
+	}
+	if len(files) == 0 {
+		return fail(hint, nil, "no network config in %s", d.cfg.ConflistDir)
+	}
+	sort.Strings(files)
+	d.conflist = filepath.Join(d.cfg.ConflistDir, files[0])
+
+	var problems, warnings []string
+	for _, ignored := range files[1:] {
+		warnings = append(warnings, fmt.Sprintf("%s is ignored, the container runtime only uses %s", ignored, files[0]))
+	}
+
+	b, err := d.fs.ReadFile(d.conflist)
+	if err != nil {
+		return fail(hint, nil, "failed to read %s: %v", d.conflist, err)
+	}
+	var conflist rawConflist
+	if err := json.Unmarshal(b, &conflist); err != nil {
+		return fail(hint, nil, "%s is not valid JSON: %v", d.conflist, err)
+	}
+	if filepath.Ext(d.conflist) != ".conflist" {
+		// a .conf is a single plugin
+		conflist.Plugins = []json.RawMessage{b}
+	}
+
+	if conflist.Name == "" {
+		problems = append(problems, "the network has no name")
+	}
+	if !isSupportedVersion(conflist.CNIVersion) {
+		problems = append(problems, fmt.Sprintf("cniVersion %q is not one of %v", conflist.CNIVersion, version.All.SupportedVersions()))
+	}
+	if len(conflist.Plugins) == 0 {
+		problems = append(problems, "there are no plugins")
+	}
+
+	d.pluginTypes = nil
+	d.ipamTypes = nil
+	for i, raw := range conflist.Plugins {
+		var plugin rawPlugin
+		if err := json.Unmarshal(raw, &plugin); err != nil {
+			problems = append(problems, fmt.Sprintf("plugin %d is invalid: %v", i, err))
+			continue
+		}
+		if plugin.Type == "" {
+			problems = append(problems, fmt.Sprintf("plugin %d has no type", i))
+			continue
+		}
+		d.pluginTypes = appendUnique(d.pluginTypes, plugin.Type)
+		if plugin.IPAM.Type != "" {
+			d.ipamTypes = appendUnique(d.ipamTypes, plugin.IPAM.Type)
+		}
+		if plugin.Type != azureCNIBin {
+			continue
+		}
+
+		netConfig, err := cni.ParseNetworkConfig(raw)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s plugin is invalid: %v", azureCNIBin, err))
+			continue
+		}
+		d.netConfig = netConfig
+		if !contains(modes, netConfig.Mode) {
+			problems = append(problems, fmt.Sprintf("%s mode %q is not one of %v", azureCNIBin, netConfig.Mode, modes[1:]))
+		}
+		if netConfig.IPAM.Type == "" {
+			problems = append(problems, fmt.Sprintf("%s plugin has no ipam type", azureCNIBin))
+		}
+		for _, field := range unknownFields(raw) {
+			warnings = append(warnings, fmt.Sprintf("%s plugin field %q is unknown and ignored, check for typos", azureCNIBin, field))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fail(hint, append(problems, warnings...), "%s is invalid", d.conflist)
+	}
+	if len(warnings) > 0 {
+		return warn("Remove the network configs which are not used, and fix the fields of the one which is", warnings, "%s is valid, with warnings", d.conflist)
+	}
+	return pass("%s is valid, plugins %v", d.conflist, d.pluginTypes)
+}
+
+func isSupportedVersion(v string) bool {
+	return contains(version.All.SupportedVersions(), v)
+}
+
+// unknownFields returns the fields of the plugin which cni.NetworkConfig does not have
+func unknownFields(raw json.RawMessage) []string {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil
+	}
+	known := map[string]bool{
+		// set by the container runtime for plugins which support it
+		"capabilities": true,
+	}
+	t := reflect.TypeOf(cni.NetworkConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		// encoding/json matches field names case insensitively
+		known[strings.ToLower(name)] = true
+	}
+	var unknown []string
+	for field := range fields {
+		if !known[strings.ToLower(field)] {
+			unknown = append(unknown, field)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// usesCNS returns whether pod IPs are assigned by CNS, according to the conflist
+func (d *Doctor) usesCNS() bool {
+	if d.netConfig != nil && d.netConfig.MultiTenancy {
+		return true
+	}
+	return contains(d.ipamTypes, cnsIPAM) || contains(d.ipamTypes, azureIPAMCNS)
+}
+
+// checkBinaries checks that the binaries of the plugins of the conflist are installed, and reports their versions
+func (d *Doctor) checkBinaries(ctx context.Context) Result {
+	hint := fmt.Sprintf("Reinstall the binaries to %s with 'acncli cni install cni', or check the logs of the cni-installer init container", d.cfg.BinDir)
+	binaries := append([]string(nil), d.pluginTypes...)
+	if len(binaries) == 0 {
+		binaries = []string{azureCNIBin}
+	}
+	for _, ipam := range d.ipamTypes {
+		if ipam != cnsIPAM {
+			binaries = appendUnique(binaries, ipam)
+		}
+	}
+
+	var problems, details []string
+	versions := map[string]string{}
+	for _, bin := range binaries {
+		path := filepath.Join(d.cfg.BinDir, bin+exeExt)
+		info, err := d.fs.Stat(path)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", bin, err))
+			continue
+		}
+		if runtime.GOOS != "windows" && info.Mode()&0o111 == 0 {
+			problems = append(problems, fmt.Sprintf("%s is not executable, mode %s", path, info.Mode()))
+			continue
+		}
+		if bin != azureCNIBin && bin != azureIPAMBin {
+			details = append(details, fmt.Sprintf("%s installed", bin))
+			continue
+		}
+		v, err := d.binaryVersion(ctx, path)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: failed to get version: %v", bin, err))
+			continue
+		}
+		versions[bin] = v
+		details = append(details, fmt.Sprintf("%s %s", bin, v))
+	}
+
+	if len(problems) > 0 {
+		return fail(hint, append(problems, details...), "%d of %d binaries are missing or broken", len(problems), len(binaries))
+	}
+	if cniVersion, ipamVersion := versions[azureCNIBin], versions[azureIPAMBin]; cniVersion != "" && ipamVersion != "" && cniVersion != ipamVersion {
+		return warn("Install the binaries of the same release, a partial upgrade leaves mismatched versions", details,
+			"%s %s and %s %s are from different releases", azureCNIBin, cniVersion, azureIPAMBin, ipamVersion)
+	}
+	return Result{Status: StatusPass, Message: fmt.Sprintf("%d binaries installed in %s", len(binaries), d.cfg.BinDir), Details: details}
+}
+
+// binaryVersion returns the version an azure binary prints, such as "Azure CNI Version v1.6.0"
+func (d *Doctor) binaryVersion(ctx context.Context, path string) (string, error) {
+	output, err := d.run(ctx, path, "-v")
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return "", errors.New("no version printed")
+	}
+	return fields[len(fields)-1], nil
+}
+
+// checkCNS checks that CNS is reachable and can reach NMAgent
+func (d *Doctor) checkCNS(ctx context.Context) Result {
+	report, err := d.cns.GetHealthReport(ctx)
+	if err != nil {
+		if !d.usesCNS() {
+			return skip("CNS is not reachable at %s, and the conflist does not use it", d.cfg.CNSURL)
+		}
+		return fail("Check that the azure-cns pod of the node is running with 'kubectl -n kube-system get pods -l k8s-app=azure-cns -o wide', and its logs",
+			[]string{err.Error()}, "CNS is not reachable at %s, pods cannot get IPs", d.cfg.CNSURL)
+	}
+	d.cnsReachable = true
+
+	var broken []string
+	for endpoint, state := range report.NMAgentCircuitBreakers {
+		if state != "closed" {
+			broken = append(broken, fmt.Sprintf("NMAgent %s: circuit breaker %s", endpoint, state))
+		}
+	}
+	if len(broken) > 0 {
+		sort.Strings(broken)
+		return warn("CNS is failing to call NMAgent, check that the node can reach the wireserver at 168.63.129.16", broken,
+			"CNS at %s is reachable, but NMAgent calls are failing", d.cfg.CNSURL)
+	}
+	return pass("CNS at %s is healthy", d.cfg.CNSURL)
+}
+
+// cniState is the part of the CNI statefile the checks use
+type cniState struct {
+	Network struct {
+		ExternalInterfaces map[string]struct {
+			Networks map[string]struct {
+				Endpoints map[string]cniEndpoint
+			}
+		}
+	}
+}
+
+type cniEndpoint struct {
+	Id           string //nolint:revive // the field name of the statefile
+	HostIfName   string
+	ContainerID  string
+	PODName      string
+	PODNameSpace string
+	IPAddresses  []net.IPNet
+}
+
+func (ep *cniEndpoint) pod() string {
+	if ep.PODName == "" {
+		return ep.Id
+	}
+	return ep.PODNameSpace + "/" + ep.PODName
+}
+
+// endpoints returns the endpoints of all networks of the statefile
+func (s *cniState) endpoints() []cniEndpoint {
+	var endpoints []cniEndpoint
+	for _, extIf := range s.Network.ExternalInterfaces {
+		for _, nw := range extIf.Networks {
+			for _, ep := range nw.Endpoints {
+				endpoints = append(endpoints, ep)
+			}
+		}
+	}
+	return endpoints
+}
+
+// loadState reads the CNI statefile once
+func (d *Doctor) loadState() (*cniState, error) {
+	if d.state != nil || d.stateErr != nil {
+		return d.state, d.stateErr
+	}
+	b, err := d.fs.ReadFile(d.cfg.StateFile)
+	if err != nil {
+		d.stateErr = err
+		return nil, err
+	}
+	state := &cniState{}
+	if err := json.Unmarshal(b, state); err != nil {
+		d.stateErr = fmt.Errorf("failed to parse %s: %w", d.cfg.StateFile, err)
+		return nil, d.stateErr
+	}
+	d.state = state
+	return state, nil
+}
+
+// checkEndpointState compares the pod IPs of the CNI statefile with the IPs CNS has assigned
+func (d *Doctor) checkEndpointState(ctx context.Context) Result {
+	state, err := d.loadState()
+	if errors.Is(err, os.ErrNotExist) {
+		return skip("no statefile at %s, CNI is stateless or has not added any pods", d.cfg.StateFile)
+	}
+	if err != nil {
+		return fail("CNI fails to add and delete pods until its statefile is valid, drain the node and move the statefile aside, or reimage the node",
+			[]string{err.Error()}, "the CNI statefile is corrupt")
+	}
+	endpoints := state.endpoints()
+
+	if !d.usesCNS() || (d.netConfig != nil && d.netConfig.MultiTenancy) {
+		return pass("%d endpoints in %s, their IPs are not assigned by CNS", len(endpoints), d.cfg.StateFile)
+	}
+	if !d.cnsReachable {
+		return skip("%d endpoints in %s, CNS is not reachable to compare them with", len(endpoints), d.cfg.StateFile)
+	}
+
+	assigned, err := d.cns.GetIPAddressesMatchingStates(ctx, types.Assigned)
+	if err != nil {
+		return fail("Check the logs of the azure-cns pod of the node", []string{err.Error()}, "failed to get the assigned IPs from CNS")
+	}
+	cnsPods := map[string]string{}
+	for i := range assigned {
+		pod := "unknown pod"
+		if podInfo := assigned[i].PodInfo; podInfo != nil {
+			pod = podInfo.Namespace() + "/" + podInfo.Name()
+		}
+		cnsPods[assigned[i].IPAddress] = pod
+	}
+	cniPods := map[string]string{}
+	for i := range endpoints {
+		for _, ip := range endpoints[i].IPAddresses {
+			cniPods[ip.IP.String()] = endpoints[i].pod()
+		}
+	}
+
+	var conflicts, leaked []string
+	for ip, pod := range cniPods {
+		cnsPod, ok := cnsPods[ip]
+		switch {
+		case !ok:
+			conflicts = append(conflicts, fmt.Sprintf("%s of %s is not assigned in CNS, it can be given to another pod", ip, pod))
+		case cnsPod != pod:
+			conflicts = append(conflicts, fmt.Sprintf("%s is of %s in CNI, but of %s in CNS", ip, pod, cnsPod))
+		}
+	}
+	for ip, pod := range cnsPods {
+		if _, ok := cniPods[ip]; !ok {
+			leaked = append(leaked, fmt.Sprintf("%s is assigned to %s in CNS, but no CNI endpoint has it", ip, pod))
+		}
+	}
+	sort.Strings(conflicts)
+	sort.Strings(leaked)
+
+	hint := "Rerun to rule out pods which were being added or deleted. CNS reconciles its IPs with the CNI state when it restarts, so restarting the azure-cns pod of the node fixes a persistent mismatch"
+	if len(conflicts) > 0 {
+		return fail(hint, append(conflicts, leaked...), "%d CNI endpoint IPs are inconsistent with CNS", len(conflicts))
+	}
+	if len(leaked) > 0 {
+		return warn(hint, leaked, "%d IPs assigned in CNS are not used by any CNI endpoint", len(leaked))
+	}
+	return pass("%d IPs of %d endpoints match CNS", len(cniPods), len(endpoints))
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUnique(values []string, v string) []string {
+	if contains(values, v) {
+		return values
+	}
+	return append(values, v)
+}
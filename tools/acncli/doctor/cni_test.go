@@ -0,0 +1,375 @@
+//go:build !ignore_uncovered
+// +build !ignore_uncovered
+
+package doctor
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	conflistDir = "/etc/cni/net.d"
+	binDir      = "/opt/cni/bin"
+	stateFile   = "/var/run/azure-vnet.json"
+)
+
+// fakeFS is a fileSystem over a fstest.MapFS, whose paths are the absolute paths without the leading slash
+type fakeFS struct {
+	files fstest.MapFS
+}
+
+func (f fakeFS) path(name string) string {
+	return strings.TrimPrefix(filepath.ToSlash(name), "/")
+}
+
+func (f fakeFS) ReadDir(name string) ([]os.DirEntry, error) { return fs.ReadDir(f.files, f.path(name)) }
+
+func (f fakeFS) ReadFile(name string) ([]byte, error) { return fs.ReadFile(f.files, f.path(name)) }
+
+func (f fakeFS) Stat(name string) (os.FileInfo, error) { return fs.Stat(f.files, f.path(name)) }
+
+// fakeCNS returns the health report and assigned IPs, or err
+type fakeCNS struct {
+	health   *cns.HealthReportResponse
+	assigned []cns.IPConfigurationStatus
+	err      error
+}
+
+func (f *fakeCNS) GetHealthReport(context.Context) (*cns.HealthReportResponse, error) {
+	return f.health, f.err
+}
+
+func (f *fakeCNS) GetIPAddressesMatchingStates(context.Context, ...types.IPState) ([]cns.IPConfigurationStatus, error) {
+	return f.assigned, f.err
+}
+
+func newTestDoctor(files fstest.MapFS) *Doctor {
+	return &Doctor{
+		cfg: Config{ConflistDir: conflistDir, BinDir: binDir, StateFile: stateFile},
+		cns: &fakeCNS{},
+		fs:  fakeFS{files: files},
+		run: func(_ context.Context, name string, _ ...string) ([]byte, error) {
+			return nil, errors.New("unexpected command " + name)
+		},
+	}
+}
+
+const validConflist = `{
+	"cniVersion": "0.3.0",
+	"name": "azure",
+	"plugins": [
+		{"type": "azure-vnet", "mode": "transparent", "ipam": {"type": "azure-cns"}},
+		{"type": "portmap", "capabilities": {"portMappings": true}}
+	]
+}`
+
+func TestCheckConflist(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       fstest.MapFS
+		wantStatus  Status
+		wantPlugins []string
+		wantIPAM    []string
+		wantDetail  string
+	}{
+		{
+			name:       "missing directory",
+			files:      fstest.MapFS{},
+			wantStatus: StatusFail,
+		},
+		{
+			name:       "no network config",
+			files:      fstest.MapFS{"etc/cni/net.d/README": {}},
+			wantStatus: StatusFail,
+		},
+		{
+			name:        "valid conflist",
+			files:       fstest.MapFS{"etc/cni/net.d/10-azure.conflist": {Data: []byte(validConflist)}},
+			wantStatus:  StatusPass,
+			wantPlugins: []string{"azure-vnet", "portmap"},
+			wantIPAM:    []string{"azure-cns"},
+		},
+		{
+			name: "valid conf",
+			files: fstest.MapFS{"etc/cni/net.d/10-azure.conf": {Data: []byte(
+				`{"cniVersion": "0.3.0", "name": "azure", "type": "azure-vnet", "ipam": {"type": "azure-vnet-ipam"}}`)}},
+			wantStatus:  StatusPass,
+			wantPlugins: []string{"azure-vnet"},
+			wantIPAM:    []string{"azure-vnet-ipam"},
+		},
+		{
+			name: "ignored conflist",
+			files: fstest.MapFS{
+				"etc/cni/net.d/10-azure.conflist": {Data: []byte(validConflist)},
+				"etc/cni/net.d/20-other.conflist": {Data: []byte(validConflist)},
+			},
+			wantStatus:  StatusWarn,
+			wantPlugins: []string{"azure-vnet", "portmap"},
+			wantIPAM:    []string{"azure-cns"},
+			wantDetail:  "20-other.conflist is ignored",
+		},
+		{
+			name: "unknown field",
+			files: fstest.MapFS{"etc/cni/net.d/10-azure.conflist": {Data: []byte(
+				`{"cniVersion": "0.3.0", "name": "azure", "plugins": [{"type": "azure-vnet", "mdoe": "transparent", "ipam": {"type": "azure-cns"}}]}`)}},
+			wantStatus:  StatusWarn,
+			wantPlugins: []string{"azure-vnet"},
+			wantIPAM:    []string{"azure-cns"},
+			wantDetail:  `field "mdoe" is unknown`,
+		},
+		{
+			name:       "invalid JSON",
+			files:      fstest.MapFS{"etc/cni/net.d/10-azure.conflist": {Data: []byte(`{"plugins": [`)}},
+			wantStatus: StatusFail,
+		},
+		{
+			name: "unsupported cniVersion",
+			files: fstest.MapFS{"etc/cni/net.d/10-azure.conflist": {Data: []byte(
+				`{"cniVersion": "9.9.9", "name": "azure", "plugins": [{"type": "azure-vnet", "ipam": {"type": "azure-cns"}}]}`)}},
+			wantStatus:  StatusFail,
+			wantPlugins: []string{"azure-vnet"},
+			wantIPAM:    []string{"azure-cns"},
+			wantDetail:  `cniVersion "9.9.9"`,
+		},
+		{
+			name: "invalid mode and no ipam",
+			files: fstest.MapFS{"etc/cni/net.d/10-azure.conflist": {Data: []byte(
+				`{"cniVersion": "0.3.0", "name": "azure", "plugins": [{"type": "azure-vnet", "mode": "bridged"}]}`)}},
+			wantStatus:  StatusFail,
+			wantPlugins: []string{"azure-vnet"},
+			wantDetail:  "has no ipam type",
+		},
+		{
+			name: "plugin without type",
+			files: fstest.MapFS{"etc/cni/net.d/10-azure.conflist": {Data: []byte(
+				`{"cniVersion": "0.3.0", "name": "azure", "plugins": [{"ipam": {"type": "azure-cns"}}]}`)}},
+			wantStatus: StatusFail,
+			wantDetail: "plugin 0 has no type",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDoctor(tt.files)
+			result := d.checkConflist(context.Background())
+			assert.Equal(t, tt.wantStatus, result.Status, "%+v", result)
+			assert.Equal(t, tt.wantPlugins, d.pluginTypes)
+			assert.Equal(t, tt.wantIPAM, d.ipamTypes)
+			if tt.wantDetail != "" {
+				assert.Contains(t, strings.Join(result.Details, "\n"), tt.wantDetail)
+			}
+			if tt.wantStatus != StatusPass {
+				assert.NotEmpty(t, result.Hint)
+			}
+		})
+	}
+}
+
+func TestCheckBinaries(t *testing.T) {
+	tests := []struct {
+		name       string
+		files      fstest.MapFS
+		versions   map[string]string
+		wantStatus Status
+	}{
+		{
+			name: "same release",
+			files: fstest.MapFS{
+				"opt/cni/bin/azure-vnet":      {Mode: 0o755},
+				"opt/cni/bin/azure-vnet-ipam": {Mode: 0o755},
+				"opt/cni/bin/portmap":         {Mode: 0o755},
+			},
+			versions:   map[string]string{"azure-vnet": "v1.6.0", "azure-vnet-ipam": "v1.6.0"},
+			wantStatus: StatusPass,
+		},
+		{
+			name: "different releases",
+			files: fstest.MapFS{
+				"opt/cni/bin/azure-vnet":      {Mode: 0o755},
+				"opt/cni/bin/azure-vnet-ipam": {Mode: 0o755},
+				"opt/cni/bin/portmap":         {Mode: 0o755},
+			},
+			versions:   map[string]string{"azure-vnet": "v1.6.0", "azure-vnet-ipam": "v1.5.0"},
+			wantStatus: StatusWarn,
+		},
+		{
+			name: "missing binary",
+			files: fstest.MapFS{
+				"opt/cni/bin/azure-vnet":      {Mode: 0o755},
+				"opt/cni/bin/azure-vnet-ipam": {Mode: 0o755},
+			},
+			versions:   map[string]string{"azure-vnet": "v1.6.0", "azure-vnet-ipam": "v1.6.0"},
+			wantStatus: StatusFail,
+		},
+		{
+			name: "version fails",
+			files: fstest.MapFS{
+				"opt/cni/bin/azure-vnet":      {Mode: 0o755},
+				"opt/cni/bin/azure-vnet-ipam": {Mode: 0o755},
+				"opt/cni/bin/portmap":         {Mode: 0o755},
+			},
+			versions:   map[string]string{"azure-vnet": "v1.6.0"},
+			wantStatus: StatusFail,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDoctor(tt.files)
+			d.pluginTypes = []string{"azure-vnet", "portmap"}
+			d.ipamTypes = []string{"azure-vnet-ipam"}
+			d.run = func(_ context.Context, name string, args ...string) ([]byte, error) {
+				require.Equal(t, []string{"-v"}, args)
+				v, ok := tt.versions[filepath.Base(name)]
+				if !ok {
+					return []byte("exec format error"), errors.New("exit status 1")
+				}
+				return []byte("Azure CNI Version " + v + "\n"), nil
+			}
+			result := d.checkBinaries(context.Background())
+			assert.Equal(t, tt.wantStatus, result.Status, "%+v", result)
+		})
+	}
+}
+
+const statefile = `{
+	"Network": {
+		"ExternalInterfaces": {
+			"eth0": {
+				"Networks": {
+					"azure": {
+						"Endpoints": {
+							"abc-eth0": {
+								"Id": "abc-eth0",
+								"PODName": "web",
+								"PODNameSpace": "default",
+								"IPAddresses": [{"IP": "10.240.0.4", "Mask": "//8AAA=="}]
+							},
+							"def-eth0": {
+								"Id": "def-eth0",
+								"PODName": "dns",
+								"PODNameSpace": "kube-system",
+								"IPAddresses": [{"IP": "10.240.0.5", "Mask": "//8AAA=="}]
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}`
+
+func assignedIP(ip, namespace, name string) cns.IPConfigurationStatus {
+	return cns.IPConfigurationStatus{IPAddress: ip, PodInfo: cns.NewPodInfo("", "", name, namespace)}
+}
+
+func TestCheckEndpointState(t *testing.T) {
+	withState := fstest.MapFS{"var/run/azure-vnet.json": {Data: []byte(statefile)}}
+	tests := []struct {
+		name         string
+		files        fstest.MapFS
+		ipamTypes    []string
+		cnsReachable bool
+		cns          *fakeCNS
+		wantStatus   Status
+		wantDetails  []string
+	}{
+		{
+			name:       "no statefile",
+			files:      fstest.MapFS{},
+			ipamTypes:  []string{cnsIPAM},
+			wantStatus: StatusSkip,
+		},
+		{
+			name:       "corrupt statefile",
+			files:      fstest.MapFS{"var/run/azure-vnet.json": {Data: []byte(`{"Network": `)}},
+			ipamTypes:  []string{cnsIPAM},
+			wantStatus: StatusFail,
+		},
+		{
+			name:       "IPs not assigned by CNS",
+			files:      withState,
+			ipamTypes:  []string{azureIPAMBin},
+			wantStatus: StatusPass,
+		},
+		{
+			name:       "CNS not reachable",
+			files:      withState,
+			ipamTypes:  []string{cnsIPAM},
+			wantStatus: StatusSkip,
+		},
+		{
+			name:         "CNS fails",
+			files:        withState,
+			ipamTypes:    []string{cnsIPAM},
+			cnsReachable: true,
+			cns:          &fakeCNS{err: errors.New("connection refused")},
+			wantStatus:   StatusFail,
+		},
+		{
+			name:         "CNS matches",
+			files:        withState,
+			ipamTypes:    []string{cnsIPAM},
+			cnsReachable: true,
+			cns: &fakeCNS{assigned: []cns.IPConfigurationStatus{
+				assignedIP("10.240.0.4", "default", "web"),
+				assignedIP("10.240.0.5", "kube-system", "dns"),
+			}},
+			wantStatus: StatusPass,
+		},
+		{
+			name:         "IPs leaked in CNS",
+			files:        withState,
+			ipamTypes:    []string{azureIPAMCNS},
+			cnsReachable: true,
+			cns: &fakeCNS{assigned: []cns.IPConfigurationStatus{
+				assignedIP("10.240.0.4", "default", "web"),
+				assignedIP("10.240.0.5", "kube-system", "dns"),
+				assignedIP("10.240.0.6", "default", "gone"),
+			}},
+			wantStatus:  StatusWarn,
+			wantDetails: []string{"10.240.0.6 is assigned to default/gone in CNS, but no CNI endpoint has it"},
+		},
+		{
+			name:         "IPs inconsistent with CNS",
+			files:        withState,
+			ipamTypes:    []string{cnsIPAM},
+			cnsReachable: true,
+			cns: &fakeCNS{assigned: []cns.IPConfigurationStatus{
+				assignedIP("10.240.0.4", "default", "api"),
+				assignedIP("10.240.0.6", "default", "gone"),
+			}},
+			wantStatus: StatusFail,
+			wantDetails: []string{
+				"10.240.0.4 is of default/web in CNI, but of default/api in CNS",
+				"10.240.0.5 of kube-system/dns is not assigned in CNS, it can be given to another pod",
+				"10.240.0.6 is assigned to default/gone in CNS, but no CNI endpoint has it",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDoctor(tt.files)
+			d.ipamTypes = tt.ipamTypes
+			d.cnsReachable = tt.cnsReachable
+			if tt.cns != nil {
+				d.cns = tt.cns
+			}
+			result := d.checkEndpointState(context.Background())
+			assert.Equal(t, tt.wantStatus, result.Status, "%+v", result)
+			if tt.wantDetails != nil {
+				assert.Equal(t, tt.wantDetails, result.Details)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+//go:build !ignore_uncovered
+// +build !ignore_uncovered
+
+// Package doctor runs checks of the Azure CNI setup of a node and reports what is broken and how to fix it.
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/cns"
+	cnsclient "github.com/Azure/azure-container-networking/cns/client"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/vishvananda/netlink"
+)
+
+// Status is the outcome of a check
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+	// StatusSkip is for checks which do not apply to the node, or depend on a check which failed
+	StatusSkip Status = "skip"
+)
+
+// Result is the outcome of a check, with what was found and how to fix it
+type Result struct {
+	Name    string   `json:"name"`
+	Status  Status   `json:"status"`
+	Message string   `json:"message"`
+	Details []string `json:"details,omitempty"`
+	Hint    string   `json:"hint,omitempty"`
+}
+
+// Report is the outcome of all checks
+type Report struct {
+	Hostname string         `json:"hostname"`
+	Time     time.Time      `json:"time"`
+	Version  string         `json:"version"`
+	Summary  map[Status]int `json:"summary"`
+	Results  []Result       `json:"results"`
+	Config   Config         `json:"config"`
+}
+
+// Failed returns the number of failed checks
+func (r *Report) Failed() int {
+	return r.Summary[StatusFail]
+}
+
+// Config of the checks
+type Config struct {
+	ConflistDir string        `json:"conflistDirectory"`
+	BinDir      string        `json:"binDirectory"`
+	CNSURL      string        `json:"cnsURL"`
+	StateFile   string        `json:"stateFile"`
+	Timeout     time.Duration `json:"-"`
+}
+
+// fileSystem is the part of the os package the checks read the node with
+type fileSystem interface {
+	ReadDir(name string) ([]os.DirEntry, error)
+	ReadFile(name string) ([]byte, error)
+	Stat(name string) (os.FileInfo, error)
+}
+
+type osFileSystem struct{}
+
+func (osFileSystem) ReadDir(name string) ([]os.DirEntry, error) { return os.ReadDir(name) }
+
+func (osFileSystem) ReadFile(name string) ([]byte, error) { return os.ReadFile(name) }
+
+func (osFileSystem) Stat(name string) (os.FileInfo, error) { return os.Stat(name) }
+
+// runFunc runs a command and returns its combined output
+type runFunc func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, args...).CombinedOutput()
+}
+
+// netlinkClient is the part of netlink the checks list the links and routes of the node with
+type netlinkClient interface {
+	LinkList() ([]netlink.Link, error)
+	RouteList(link netlink.Link, family int) ([]netlink.Route, error)
+}
+
+type hostNetlink struct{}
+
+func (hostNetlink) LinkList() ([]netlink.Link, error) { return netlink.LinkList() }
+
+func (hostNetlink) RouteList(link netlink.Link, family int) ([]netlink.Route, error) {
+	return netlink.RouteList(link, family)
+}
+
+// cnsClient is the part of the CNS client the checks use
+type cnsClient interface {
+	GetHealthReport(ctx context.Context) (*cns.HealthReportResponse, error)
+	GetIPAddressesMatchingStates(ctx context.Context, stateFilter ...types.IPState) ([]cns.IPConfigurationStatus, error)
+}
+
+// Doctor runs the checks. Later checks use what earlier ones found, such as the IPAM of the conflist
+// and the endpoints of the statefile.
+type Doctor struct {
+	cfg Config
+	cns cnsClient
+	fs  fileSystem
+	run runFunc
+	nl  netlinkClient
+
+	// conflist is the path of the conflist the container runtime uses
+	conflist string
+	// netConfig is the azure-vnet plugin of the conflist, nil if there is none
+	netConfig *cni.NetworkConfig
+	// pluginTypes and ipamTypes are the plugins of the conflist
+	pluginTypes []string
+	ipamTypes   []string
+
+	cnsReachable bool
+
+	state    *cniState
+	stateErr error
+}
+
+// New returns a Doctor with cfg
+func New(cfg Config) (*Doctor, error) {
+	cns, err := cnsclient.New(cfg.CNSURL, cfg.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CNS client: %w", err)
+	}
+	return &Doctor{cfg: cfg, cns: cns, fs: osFileSystem{}, run: runCommand, nl: hostNetlink{}}, nil
+}
+
+type check struct {
+	name string
+	run  func(context.Context) Result
+}
+
+// checks are run in order
+func (d *Doctor) checks() []check {
+	return []check{
+		{"conflist", d.checkConflist},
+		{"binaries", d.checkBinaries},
+		{"cns", d.checkCNS},
+		{"endpoint-state", d.checkEndpointState},
+		{"host-interfaces", d.checkHostInterfaces},
+		{"iptables", d.checkIPTables},
+		{"ipsets", d.checkIPSets},
+	}
+}
+
+// Run runs all checks
+func (d *Doctor) Run(ctx context.Context, version string) *Report {
+	return d.runChecks(ctx, version, d.checks())
+}
+
+// runChecks runs the checks in order and sums up their results
+func (d *Doctor) runChecks(ctx context.Context, version string, checks []check) *Report {
+	hostname, _ := os.Hostname()
+	report := &Report{
+		Hostname: hostname,
+		Time:     time.Now().UTC(),
+		Version:  version,
+		Summary:  map[Status]int{},
+		Config:   d.cfg,
+	}
+	for _, c := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, d.cfg.Timeout)
+		result := c.run(checkCtx)
+		cancel()
+		result.Name = c.name
+		report.Results = append(report.Results, result)
+		report.Summary[result.Status]++
+	}
+	return report
+}
+
+var statusMarks = map[Status]string{
+	StatusPass: "✅",
+	StatusWarn: "⚠️ ",
+	StatusFail: "❌",
+	StatusSkip: "⏭️ ",
+}
+
+// WriteText writes the report for people to read
+func (r *Report) WriteText(w io.Writer) error {
+	for i := range r.Results {
+		result := &r.Results[i]
+		if _, err := fmt.Fprintf(w, "%s %s: %s\n", statusMarks[result.Status], result.Name, result.Message); err != nil {
+			return err
+		}
+		for _, detail := range result.Details {
+			fmt.Fprintf(w, "     - %s\n", detail)
+		}
+		if result.Hint != "" && (result.Status == StatusFail || result.Status == StatusWarn) {
+			fmt.Fprintf(w, "     💡 %s\n", result.Hint)
+		}
+	}
+	_, err := fmt.Fprintf(w, "\n%d passed, %d warnings, %d failed, %d skipped\n",
+		r.Summary[StatusPass], r.Summary[StatusWarn], r.Summary[StatusFail], r.Summary[StatusSkip])
+	return err
+}
+
+// WriteJSON writes the report as JSON, for support bundles
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func pass(format string, a ...any) Result {
+	return Result{Status: StatusPass, Message: fmt.Sprintf(format, a...)}
+}
+
+func skip(format string, a ...any) Result {
+	return Result{Status: StatusSkip, Message: fmt.Sprintf(format, a...)}
+}
+
+func warn(hint string, details []string, format string, a ...any) Result {
+	return Result{Status: StatusWarn, Message: fmt.Sprintf(format, a...), Details: details, Hint: hint}
+}
+
+func fail(hint string, details []string, format string, a ...any) Result {
+	return Result{Status: StatusFail, Message: fmt.Sprintf(format, a...), Details: details, Hint: hint}
+}
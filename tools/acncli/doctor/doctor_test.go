@@ -0,0 +1,107 @@
+//go:build !ignore_uncovered
+// +build !ignore_uncovered
+
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunChecks(t *testing.T) {
+	tests := []struct {
+		name        string
+		results     []Result
+		wantSummary map[Status]int
+		wantFailed  int
+		wantText    []string
+		wantNoText  []string
+	}{
+		{
+			name:        "no checks",
+			wantSummary: map[Status]int{},
+			wantText:    []string{"0 passed, 0 warnings, 0 failed, 0 skipped"},
+		},
+		{
+			name: "all pass",
+			results: []Result{
+				pass("conflist is valid"),
+				pass("CNS is healthy"),
+			},
+			wantSummary: map[Status]int{StatusPass: 2},
+			wantText:    []string{"✅ a: conflist is valid", "2 passed, 0 warnings, 0 failed, 0 skipped"},
+		},
+		{
+			name: "mixed",
+			results: []Result{
+				pass("conflist is valid"),
+				warn("remove it", []string{"20-other.conflist is ignored"}, "conflist is valid, with warnings"),
+				fail("restart CNS", []string{"connection refused"}, "CNS is not reachable"),
+				skip("no statefile"),
+				fail("reinstall", nil, "binaries are missing"),
+			},
+			wantSummary: map[Status]int{StatusPass: 1, StatusWarn: 1, StatusFail: 2, StatusSkip: 1},
+			wantFailed:  2,
+			wantText: []string{
+				"⚠️  b: conflist is valid, with warnings",
+				"     - 20-other.conflist is ignored",
+				"     💡 remove it",
+				"❌ c: CNS is not reachable",
+				"     💡 restart CNS",
+				"⏭️  d: no statefile",
+				"1 passed, 1 warnings, 2 failed, 1 skipped",
+			},
+		},
+		{
+			name:        "hints of passed checks are not shown",
+			results:     []Result{{Status: StatusPass, Message: "ok", Hint: "nothing to do"}},
+			wantSummary: map[Status]int{StatusPass: 1},
+			wantNoText:  []string{"nothing to do"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &Doctor{cfg: Config{Timeout: time.Second}}
+			checks := make([]check, len(tt.results))
+			for i := range tt.results {
+				result := tt.results[i]
+				checks[i] = check{name: string(rune('a' + i)), run: func(ctx context.Context) Result {
+					_, ok := ctx.Deadline()
+					assert.True(t, ok, "checks run with the timeout")
+					return result
+				}}
+			}
+
+			report := d.runChecks(context.Background(), "v1.0.0", checks)
+			assert.Equal(t, "v1.0.0", report.Version)
+			assert.Equal(t, tt.wantSummary, report.Summary)
+			assert.Equal(t, tt.wantFailed, report.Failed())
+			require.Len(t, report.Results, len(tt.results))
+			for i := range report.Results {
+				assert.Equal(t, checks[i].name, report.Results[i].Name)
+			}
+
+			var text bytes.Buffer
+			require.NoError(t, report.WriteText(&text))
+			for _, want := range tt.wantText {
+				assert.Contains(t, text.String(), want+"\n")
+			}
+			for _, unwanted := range tt.wantNoText {
+				assert.NotContains(t, text.String(), unwanted)
+			}
+
+			var out bytes.Buffer
+			require.NoError(t, report.WriteJSON(&out))
+			var decoded Report
+			require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+			assert.Equal(t, report.Summary, decoded.Summary)
+			assert.Len(t, decoded.Results, len(tt.results))
+		})
+	}
+}
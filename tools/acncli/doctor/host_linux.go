@@ -0,0 +1,276 @@
+//go:build !ignore_uncovered
+// +build !ignore_uncovered
+
+package doctor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/iptables"
+	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	exeExt = ""
+	// hostVethPrefix is the prefix of the host side of the veth pairs of the pods
+	hostVethPrefix = "azv"
+	// maxIPSets is the most sets the kernel can have, IPSET_INVALID_ID
+	maxIPSets = 65535
+)
+
+// checkHostInterfaces looks for host veths and routes of pods which are not in the CNI statefile
+func (d *Doctor) checkHostInterfaces(_ context.Context) Result {
+	links, err := d.nl.LinkList()
+	if err != nil {
+		return fail("", []string{err.Error()}, "failed to list links")
+	}
+	veths := map[int]string{}
+	for _, link := range links {
+		if link.Type() == "veth" && strings.HasPrefix(link.Attrs().Name, hostVethPrefix) {
+			veths[link.Attrs().Index] = link.Attrs().Name
+		}
+	}
+
+	state, err := d.loadState()
+	if err != nil {
+		return skip("%d pod veths, no CNI statefile to compare them with: %v", len(veths), err)
+	}
+
+	knownVeths := map[string]bool{}
+	knownIPs := map[string]bool{}
+	var missing []string
+	vethNames := map[string]bool{}
+	for _, name := range veths {
+		vethNames[name] = true
+	}
+	endpoints := state.endpoints()
+	for i := range endpoints {
+		ep := &endpoints[i]
+		for _, ip := range ep.IPAddresses {
+			knownIPs[ip.IP.String()] = true
+		}
+		if !strings.HasPrefix(ep.HostIfName, hostVethPrefix) {
+			continue
+		}
+		knownVeths[ep.HostIfName] = true
+		if !vethNames[ep.HostIfName] {
+			missing = append(missing, fmt.Sprintf("endpoint of %s has no host veth %s", ep.pod(), ep.HostIfName))
+		}
+	}
+
+	var dangling []string
+	for _, name := range veths {
+		if !knownVeths[name] {
+			dangling = append(dangling, fmt.Sprintf("veth %s is of no endpoint", name))
+		}
+	}
+
+	routes, err := d.nl.RouteList(nil, netlink.FAMILY_ALL)
+	if err != nil {
+		return fail("", []string{err.Error()}, "failed to list routes")
+	}
+	var stale []string
+	for i := range routes {
+		route := &routes[i]
+		veth, ok := veths[route.LinkIndex]
+		if !ok || route.Dst == nil {
+			continue
+		}
+		if ones, bits := route.Dst.Mask.Size(); ones != bits {
+			continue
+		}
+		if !knownIPs[route.Dst.IP.String()] {
+			stale = append(stale, fmt.Sprintf("route to %s via %s is of no endpoint", route.Dst.IP, veth))
+		}
+	}
+
+	sort.Strings(missing)
+	sort.Strings(dangling)
+	sort.Strings(stale)
+	details := append(append(dangling, stale...), missing...)
+	if len(details) > 0 {
+		return warn("Rerun to rule out pods which were being added or deleted. Once no pod uses them, delete dangling veths with 'ip link del <veth>' and stale routes with 'ip route del <ip>'",
+			details, "%d dangling veths, %d stale routes and %d endpoints without a veth", len(dangling), len(stale), len(missing))
+	}
+	return pass("%d pod veths match the %d endpoints of the CNI statefile", len(veths), len(endpoints))
+}
+
+var (
+	iptablesTables = []string{iptables.Filter, iptables.Nat, iptables.Mangle}
+	// ownedChainPrefixes are the prefixes of the chains of ACN components
+	ownedChainPrefixes = []string{
+		"AZURECNI",
+		iptables.Swift,
+		util.NamingGenerationA.ChainPrefix(),
+		util.NamingGenerationB.ChainPrefix(),
+		"IP-MASQ",
+	}
+	// iptablesBackends are the commands of the iptables backends, both of which the kernel evaluates
+	iptablesBackends = []string{"iptables-nft", "iptables-legacy", "ip6tables-nft", "ip6tables-legacy"}
+)
+
+// iptablesRules are the rules of a table listed with cmd -S
+type iptablesRules struct {
+	cmd   string
+	table string
+	rules []byte
+}
+
+// listIPTables lists the rules of each table with each of cmds, and returns the cmds which are installed
+func (d *Doctor) listIPTables(ctx context.Context, cmds []string) (installed []string, listed []iptablesRules, problems []string) {
+	for _, cmd := range cmds {
+		found := true
+		for _, table := range iptablesTables {
+			output, err := d.run(ctx, cmd, "-t", table, "-S")
+			if errors.Is(err, exec.ErrNotFound) {
+				found = false
+				break
+			}
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s -t %s -S: %v: %s", cmd, table, err, strings.TrimSpace(string(output))))
+				continue
+			}
+			listed = append(listed, iptablesRules{cmd: cmd, table: table, rules: output})
+		}
+		if found {
+			installed = append(installed, cmd)
+		}
+	}
+	return installed, listed, problems
+}
+
+// checkIPTables checks that the chains of ACN components are jumped to, and are in one backend only
+func (d *Doctor) checkIPTables(ctx context.Context) Result {
+	commands, listed, problems := d.listIPTables(ctx, iptablesBackends)
+	if len(commands) == 0 {
+		// older images only have the default backend
+		commands, listed, problems = d.listIPTables(ctx, []string{"iptables", "ip6tables"})
+	}
+	if len(commands) == 0 {
+		return skip("iptables is not installed")
+	}
+
+	var orphans []string
+	chains := 0
+	// backends of each owned chain prefix, by ip family
+	backends := map[string][]string{}
+	for _, l := range listed {
+		family := strings.TrimSuffix(strings.TrimSuffix(l.cmd, "-nft"), "-legacy")
+		owned, unreferenced := ownedChains(l.rules)
+		chains += len(owned)
+		for _, chain := range unreferenced {
+			orphans = append(orphans, fmt.Sprintf("%s %s chain %s is not jumped to", l.cmd, l.table, chain))
+		}
+		for _, chain := range owned {
+			key := family + " " + chainOwner(chain)
+			backends[key] = appendUnique(backends[key], l.cmd)
+		}
+	}
+
+	var split []string
+	for key, cmds := range backends {
+		if len(cmds) > 1 {
+			split = append(split, fmt.Sprintf("%s chains are in %v, the kernel evaluates both", key, cmds))
+		}
+	}
+	sort.Strings(orphans)
+	sort.Strings(split)
+
+	if len(problems) > 0 {
+		return fail("Check that the iptables of the node match the backend kube-proxy and the ACN components use, iptables-nft or iptables-legacy",
+			append(problems, append(split, orphans...)...), "failed to list iptables rules")
+	}
+	if len(split) > 0 || len(orphans) > 0 {
+		return warn("Chains in the backend an ACN component no longer uses, and chains nothing jumps to, are left over from an older version. Restart the component which owns them to clean them up",
+			append(split, orphans...), "%d chains in both backends and %d chains which are not jumped to", len(split), len(orphans))
+	}
+	return pass("%d ACN chains, all jumped to, in %v", chains, commands)
+}
+
+// ownedChains returns the chains of ACN components in the rules of a table listed with -S, and those of them no rule jumps to
+func ownedChains(rules []byte) (owned, unreferenced []string) {
+	referenced := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(rules))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		if fields[0] == "-N" && chainOwner(fields[1]) != "" {
+			owned = append(owned, fields[1])
+			continue
+		}
+		for i := 0; i+1 < len(fields); i++ {
+			if fields[i] == "-j" || fields[i] == "-g" {
+				referenced[fields[i+1]] = true
+			}
+		}
+	}
+	for _, chain := range owned {
+		if !referenced[chain] {
+			unreferenced = append(unreferenced, chain)
+		}
+	}
+	return owned, unreferenced
+}
+
+// chainOwner returns the prefix of the ACN component of chain, or "" if it is not of one
+func chainOwner(chain string) string {
+	for _, prefix := range ownedChainPrefixes {
+		if strings.HasPrefix(chain, prefix) {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// checkIPSets counts the ipsets of NPM, which fails to add policies once the kernel limit is reached
+func (d *Doctor) checkIPSets(ctx context.Context) Result {
+	output, err := d.run(ctx, "ipset", "list", "-name")
+	if errors.Is(err, exec.ErrNotFound) {
+		return skip("ipset is not installed")
+	}
+	if err != nil {
+		return fail("", []string{fmt.Sprintf("%v: %s", err, strings.TrimSpace(string(output)))}, "failed to list ipsets")
+	}
+
+	total := 0
+	generations := map[util.NamingGeneration]int{}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		if name == "" {
+			continue
+		}
+		total++
+		for _, g := range []util.NamingGeneration{util.NamingGenerationA, util.NamingGenerationB} {
+			if strings.HasPrefix(name, g.IPSetPrefix()) {
+				generations[g]++
+			}
+		}
+	}
+	npm := generations[util.NamingGenerationA] + generations[util.NamingGenerationB]
+	details := []string{fmt.Sprintf("%d of %d ipsets are NPM's", npm, total)}
+	for g, count := range generations {
+		details = append(details, fmt.Sprintf("%d %s* ipsets", count, g.IPSetPrefix()))
+	}
+	sort.Strings(details[1:])
+
+	if total >= maxIPSets*9/10 {
+		return fail("Reduce the number of network policies and the distinct pod selectors and namespaces they use",
+			details, "%d ipsets, the kernel limit is %d", total, maxIPSets)
+	}
+	if len(generations) > 1 {
+		return warn("NPM hands over between naming generations when it is upgraded. If no handover is running, restart the NPM pod of the node to clean up the sets of the old generation",
+			details, "%d NPM ipsets of both naming generations", npm)
+	}
+	return Result{Status: StatusPass, Message: fmt.Sprintf("%d ipsets, %d of NPM", total, npm), Details: details}
+}
@@ -0,0 +1,300 @@
+//go:build !ignore_uncovered
+// +build !ignore_uncovered
+
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vishvananda/netlink"
+)
+
+// fakeNetlink returns the links and routes, or err
+type fakeNetlink struct {
+	links  []netlink.Link
+	routes []netlink.Route
+	err    error
+}
+
+func (f *fakeNetlink) LinkList() ([]netlink.Link, error) { return f.links, f.err }
+
+func (f *fakeNetlink) RouteList(netlink.Link, int) ([]netlink.Route, error) { return f.routes, f.err }
+
+// fakeRun returns the output of the commands by their command line, and exec.ErrNotFound for the others
+func fakeRun(outputs map[string]string) runFunc {
+	return func(_ context.Context, name string, args ...string) ([]byte, error) {
+		output, ok := outputs[strings.Join(append([]string{name}, args...), " ")]
+		if !ok {
+			return nil, &exec.Error{Name: name, Err: exec.ErrNotFound}
+		}
+		return []byte(output), nil
+	}
+}
+
+const vethStatefile = `{
+	"Network": {
+		"ExternalInterfaces": {
+			"eth0": {
+				"Networks": {
+					"azure": {
+						"Endpoints": {
+							"abc-eth0": {
+								"Id": "abc-eth0",
+								"HostIfName": "azvabc",
+								"PODName": "web",
+								"PODNameSpace": "default",
+								"IPAddresses": [{"IP": "10.240.0.4", "Mask": "//8AAA=="}]
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}`
+
+func veth(name string, index int) netlink.Link {
+	return &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: name, Index: index}}
+}
+
+func hostRoute(ip string, linkIndex int) netlink.Route {
+	return netlink.Route{LinkIndex: linkIndex, Dst: &net.IPNet{IP: net.ParseIP(ip).To4(), Mask: net.CIDRMask(32, 32)}}
+}
+
+func TestCheckHostInterfaces(t *testing.T) {
+	withState := fstest.MapFS{"var/run/azure-vnet.json": {Data: []byte(vethStatefile)}}
+	eth0 := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth0", Index: 2}}
+	tests := []struct {
+		name        string
+		files       fstest.MapFS
+		nl          *fakeNetlink
+		wantStatus  Status
+		wantDetails []string
+	}{
+		{
+			name:       "links fail",
+			files:      withState,
+			nl:         &fakeNetlink{err: errors.New("operation not permitted")},
+			wantStatus: StatusFail,
+		},
+		{
+			name:       "no statefile",
+			files:      fstest.MapFS{},
+			nl:         &fakeNetlink{links: []netlink.Link{eth0, veth("azvabc", 10)}},
+			wantStatus: StatusSkip,
+		},
+		{
+			name:  "veths match",
+			files: withState,
+			nl: &fakeNetlink{
+				links: []netlink.Link{eth0, veth("azvabc", 10)},
+				routes: []netlink.Route{
+					hostRoute("10.240.0.4", 10),
+					// routes of other links and subnet routes are not of pods
+					{LinkIndex: 2, Dst: &net.IPNet{IP: net.IPv4(10, 240, 0, 0).To4(), Mask: net.CIDRMask(16, 32)}},
+					hostRoute("168.63.129.16", 2),
+				},
+			},
+			wantStatus: StatusPass,
+		},
+		{
+			name:  "dangling veth and stale route",
+			files: withState,
+			nl: &fakeNetlink{
+				links: []netlink.Link{eth0, veth("azvabc", 10), veth("azvdef", 11), veth("vethother", 12)},
+				routes: []netlink.Route{
+					hostRoute("10.240.0.4", 10),
+					hostRoute("10.240.0.9", 11),
+				},
+			},
+			wantStatus: StatusWarn,
+			wantDetails: []string{
+				"veth azvdef is of no endpoint",
+				"route to 10.240.0.9 via azvdef is of no endpoint",
+			},
+		},
+		{
+			name:       "endpoint without veth",
+			files:      withState,
+			nl:         &fakeNetlink{links: []netlink.Link{eth0}},
+			wantStatus: StatusWarn,
+			wantDetails: []string{
+				"endpoint of default/web has no host veth azvabc",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDoctor(tt.files)
+			d.nl = tt.nl
+			result := d.checkHostInterfaces(context.Background())
+			assert.Equal(t, tt.wantStatus, result.Status, "%+v", result)
+			if tt.wantDetails != nil {
+				assert.Equal(t, tt.wantDetails, result.Details)
+			}
+		})
+	}
+}
+
+func TestOwnedChains(t *testing.T) {
+	rules := []byte(`-P INPUT ACCEPT
+-N AZURE-NPM
+-N AZURE-NPM-INGRESS
+-N KUBE-SERVICES
+-N SWIFT-POSTROUTING
+-A FORWARD -j AZURE-NPM
+-A AZURE-NPM -g AZURE-NPM-INGRESS
+-A OUTPUT -j KUBE-SERVICES
+`)
+	owned, unreferenced := ownedChains(rules)
+	assert.Equal(t, []string{"AZURE-NPM", "AZURE-NPM-INGRESS", "SWIFT-POSTROUTING"}, owned)
+	assert.Equal(t, []string{"SWIFT-POSTROUTING"}, unreferenced)
+
+	owned, unreferenced = ownedChains(nil)
+	assert.Empty(t, owned)
+	assert.Empty(t, unreferenced)
+}
+
+// iptablesOutputs lists the rules for each table with each of cmds
+func iptablesOutputs(rules map[string]string, cmds ...string) map[string]string {
+	outputs := map[string]string{}
+	for _, cmd := range cmds {
+		for _, table := range iptablesTables {
+			outputs[fmt.Sprintf("%s -t %s -S", cmd, table)] = rules[cmd+" "+table]
+		}
+	}
+	return outputs
+}
+
+func TestCheckIPTables(t *testing.T) {
+	tests := []struct {
+		name        string
+		outputs     map[string]string
+		wantStatus  Status
+		wantDetails []string
+	}{
+		{
+			name:       "not installed",
+			outputs:    map[string]string{},
+			wantStatus: StatusSkip,
+		},
+		{
+			name: "chains jumped to in one backend",
+			outputs: iptablesOutputs(map[string]string{
+				"iptables-nft filter": "-N AZURE-NPM\n-A FORWARD -j AZURE-NPM\n",
+			}, "iptables-nft", "iptables-legacy", "ip6tables-nft", "ip6tables-legacy"),
+			wantStatus: StatusPass,
+		},
+		{
+			name: "default backend of older images",
+			outputs: iptablesOutputs(map[string]string{
+				"iptables nat": "-N SWIFT\n-A POSTROUTING -j SWIFT\n",
+			}, "iptables"),
+			wantStatus: StatusPass,
+		},
+		{
+			name: "chains in both backends",
+			outputs: iptablesOutputs(map[string]string{
+				"iptables-nft filter":    "-N AZURE-NPM\n-A FORWARD -j AZURE-NPM\n",
+				"iptables-legacy filter": "-N AZURE-NPM\n-A FORWARD -j AZURE-NPM\n",
+			}, "iptables-nft", "iptables-legacy"),
+			wantStatus:  StatusWarn,
+			wantDetails: []string{"iptables AZURE-NPM chains are in [iptables-nft iptables-legacy], the kernel evaluates both"},
+		},
+		{
+			name: "chain not jumped to",
+			outputs: iptablesOutputs(map[string]string{
+				"iptables-nft nat": "-N IP-MASQ-AGENT\n",
+			}, "iptables-nft"),
+			wantStatus:  StatusWarn,
+			wantDetails: []string{"iptables-nft nat chain IP-MASQ-AGENT is not jumped to"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDoctor(fstest.MapFS{})
+			d.run = fakeRun(tt.outputs)
+			result := d.checkIPTables(context.Background())
+			assert.Equal(t, tt.wantStatus, result.Status, "%+v", result)
+			if tt.wantDetails != nil {
+				assert.Equal(t, tt.wantDetails, result.Details)
+			}
+		})
+	}
+}
+
+func TestCheckIPTablesFails(t *testing.T) {
+	d := newTestDoctor(fstest.MapFS{})
+	d.run = func(_ context.Context, name string, args ...string) ([]byte, error) {
+		if name != "iptables-nft" {
+			return nil, exec.ErrNotFound
+		}
+		return []byte("iptables v1.8.7 (nf_tables): table `" + args[1] + "' is incompatible"), errors.New("exit status 1")
+	}
+	result := d.checkIPTables(context.Background())
+	assert.Equal(t, StatusFail, result.Status, "%+v", result)
+	assert.Len(t, result.Details, len(iptablesTables))
+}
+
+func TestCheckIPSets(t *testing.T) {
+	many := make([]string, maxIPSets*9/10)
+	for i := range many {
+		many[i] = fmt.Sprintf("azure-npm-%d", i)
+	}
+	tests := []struct {
+		name        string
+		outputs     map[string]string
+		wantStatus  Status
+		wantDetails []string
+	}{
+		{
+			name:       "not installed",
+			outputs:    map[string]string{},
+			wantStatus: StatusSkip,
+		},
+		{
+			name:       "one generation",
+			outputs:    map[string]string{"ipset list -name": "azure-npm-1\nazure-npm-2\nKUBE-CLUSTER-IP\n\n"},
+			wantStatus: StatusPass,
+			wantDetails: []string{
+				"2 of 3 ipsets are NPM's",
+				"2 azure-npm-* ipsets",
+			},
+		},
+		{
+			name:       "both generations",
+			outputs:    map[string]string{"ipset list -name": "azure-npm-1\nazure-npb-1\nazure-npb-2\n"},
+			wantStatus: StatusWarn,
+			wantDetails: []string{
+				"3 of 3 ipsets are NPM's",
+				"1 azure-npm-* ipsets",
+				"2 azure-npb-* ipsets",
+			},
+		},
+		{
+			name:       "near the kernel limit",
+			outputs:    map[string]string{"ipset list -name": strings.Join(many, "\n")},
+			wantStatus: StatusFail,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDoctor(fstest.MapFS{})
+			d.run = fakeRun(tt.outputs)
+			result := d.checkIPSets(context.Background())
+			require.Equal(t, tt.wantStatus, result.Status, "%+v", result)
+			if tt.wantDetails != nil {
+				assert.Equal(t, tt.wantDetails, result.Details)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !ignore_uncovered
+// +build !ignore_uncovered
+
+package doctor
+
+import "context"
+
+const exeExt = ".exe"
+
+func (d *Doctor) checkHostInterfaces(_ context.Context) Result {
+	return skip("pod interfaces are HNS endpoints on windows, which are not checked")
+}
+
+func (d *Doctor) checkIPTables(_ context.Context) Result {
+	return skip("there is no iptables on windows")
+}
+
+func (d *Doctor) checkIPSets(_ context.Context) Result {
+	return skip("there are no ipsets on windows")
+}
@@ -4,6 +4,8 @@
 package main
 
 import (
+	"os"
+
 	"github.com/Azure/azure-container-networking/tools/acncli/cmd"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -18,7 +20,9 @@ var (
 )
 
 func main() {
-	rootCmd.Execute()
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
 }
 
 func init() {